@@ -24,6 +24,12 @@ Check
         "task": "build",
         "hash": "d41ea11c7c15a7ae",
         "command": "echo 'building' \u003e foo",
+        "inputs": [
+          ".gitignore",
+          "package-lock.json",
+          "package.json",
+          "turbo.json"
+        ],
         "outputs": [
           "foo"
         ],
@@ -34,3 +40,8 @@ Check
       }
     ]
   }
+
+The dry run hash matches the hash of the real run
+  $ DRY_HASH=$(${TURBO} run build --dry=json --single-package | grep '"hash"' | sed -E 's/.*"hash": "([0-9a-f]+)".*/\1/')
+  $ ${TURBO} run build --single-package | grep -c "cache miss, executing ${DRY_HASH}"
+  1
@@ -7,10 +7,14 @@ package cache
 
 import (
 	"errors"
+	"fmt"
+	"os"
+	"strconv"
 	"sync"
 
 	"github.com/spf13/pflag"
 	"github.com/vercel/turbo/cli/internal/analytics"
+	"github.com/vercel/turbo/cli/internal/cacheitem"
 	"github.com/vercel/turbo/cli/internal/fs"
 	"github.com/vercel/turbo/cli/internal/turbopath"
 	"github.com/vercel/turbo/cli/internal/util"
@@ -19,9 +23,9 @@ import (
 
 // Cache is abstracted way to cache/fetch previously run tasks
 type Cache interface {
-	// Fetch returns true if there is a cache it. It is expected to move files
+	// Fetch returns which caches, if any, the hash was found in. It is expected to move files
 	// into their correct position as a side effect
-	Fetch(anchor turbopath.AbsoluteSystemPath, hash string, files []string) (bool, []turbopath.AnchoredSystemPath, int, error)
+	Fetch(anchor turbopath.AbsoluteSystemPath, hash string, files []string) (ItemStatus, []turbopath.AnchoredSystemPath, int, error)
 	Exists(hash string) (ItemStatus, error)
 	// Put caches files for a given hash
 	Put(anchor turbopath.AbsoluteSystemPath, hash string, duration int, files []turbopath.AnchoredSystemPath) error
@@ -37,6 +41,11 @@ type ItemStatus struct {
 	Remote bool `json:"remote"`
 }
 
+// Hit returns true if artifacts exist in any cache
+func (s ItemStatus) Hit() bool {
+	return s.Local || s.Remote
+}
+
 const cacheEventHit = "HIT"
 const cacheEventMiss = "MISS"
 
@@ -63,11 +72,43 @@ var ErrNoCachesEnabled = errors.New("no caches are enabled")
 // Opts holds configuration options for the cache
 // TODO(gsoltis): further refactor this into fs cache opts and http cache opts
 type Opts struct {
-	OverrideDir     string
-	SkipRemote      bool
-	SkipFilesystem  bool
-	Workers         int
-	RemoteCacheOpts fs.RemoteCacheOptions
+	OverrideDir    string
+	SkipRemote     bool
+	SkipFilesystem bool
+	// SkipLocalReads and SkipLocalWrites stop artifacts from being read from, or
+	// written to, the filesystem cache. SkipRemoteReads and SkipRemoteWrites do
+	// the same for the remote cache. A cache that can be neither read nor written
+	// isn't used at all.
+	SkipLocalReads   bool
+	SkipLocalWrites  bool
+	SkipRemoteReads  bool
+	SkipRemoteWrites bool
+	Workers          int
+	RemoteCacheOpts  fs.RemoteCacheOptions
+	// TransferStats, if set, records the bytes transferred to and from the remote cache
+	TransferStats *TransferStats
+	// Compression is how new artifacts are compressed. Artifacts are restored
+	// however they were compressed.
+	Compression cacheitem.Compression
+	// Incremental writes each artifact before Put returns, rather than queueing it
+	// up for the workers, so that an interrupted run keeps the artifacts of the
+	// tasks that finished
+	Incremental bool
+	// MaxSize, if positive, is how many bytes of artifacts the filesystem cache
+	// can hold. Writing an artifact that makes it larger evicts the least
+	// recently used artifacts.
+	MaxSize int64
+}
+
+// UsesFilesystem returns true if artifacts can be read from or written to the
+// filesystem cache
+func (o *Opts) UsesFilesystem() bool {
+	return !o.SkipFilesystem && !(o.SkipLocalReads && o.SkipLocalWrites)
+}
+
+// UsesRemote returns true if artifacts can be read from or written to the remote cache
+func (o *Opts) UsesRemote() bool {
+	return !o.SkipRemote && !(o.SkipRemoteReads && o.SkipRemoteWrites)
 }
 
 // resolveCacheDir calculates the location turbo should use to cache artifacts,
@@ -76,27 +117,108 @@ func (o *Opts) resolveCacheDir(repoRoot turbopath.AbsoluteSystemPath) turbopath.
 	if o.OverrideDir != "" {
 		return fs.ResolveUnknownPath(repoRoot, o.OverrideDir)
 	}
+	if dir := os.Getenv(_envCacheDir); dir != "" {
+		return fs.ResolveUnknownPath(repoRoot, dir)
+	}
 	return DefaultLocation(repoRoot)
 }
 
+// _envCacheDir overrides the filesystem cache directory, unless --cache-dir is set
+const _envCacheDir = "TURBO_CACHE_DIR"
+
 var _remoteOnlyHelp = `Ignore the local filesystem cache for all tasks. Only
 allow reading and caching artifacts using the remote cache.`
 
+var _localCacheReadHelp = `Restore artifacts from the filesystem cache. Use
+--local-cache-read=false to only write to it.`
+
+var _localCacheWriteHelp = `Save artifacts to the filesystem cache. Use
+--local-cache-write=false to only read from it.`
+
+var _remoteCacheReadHelp = `Restore artifacts from the remote cache. Use
+--remote-cache-read=false to only write to it.`
+
+var _remoteCacheWriteHelp = `Save artifacts to the remote cache. Use
+--remote-cache-write=false to only read from it.`
+
+var _cacheCompressionHelp = `Compress new cache artifacts with none, gzip or zstd.
+Artifacts are restored however they were compressed.`
+
+var _cacheIncrementalHelp = `Write each task's artifact to the cache as soon as the task
+finishes, so that an interrupted run leaves the tasks that
+finished cached. Ignores --cache-workers.`
+
+var _cacheMaxSizeHelp = `Limit the filesystem cache to this many bytes of artifacts,
+evicting the least recently used artifacts once it grows
+larger. Defaults to 0, which is unlimited.`
+
+var _cacheCompressionLevelHelp = `Compression level of --cache-compression, from 1 to 9
+for gzip and 1 to 20 for zstd. Defaults to the algorithm's
+default level.`
+
 // AddFlags adds cache-related flags to the given FlagSet
 func AddFlags(opts *Opts, flags *pflag.FlagSet) {
 	// skipping remote caching not currently a flag
 	flags.BoolVar(&opts.SkipFilesystem, "remote-only", false, _remoteOnlyHelp)
-	flags.StringVar(&opts.OverrideDir, "cache-dir", "", "Override the filesystem cache directory.")
+	addAllowFlag(flags, "local-cache-read", &opts.SkipLocalReads, _localCacheReadHelp)
+	addAllowFlag(flags, "local-cache-write", &opts.SkipLocalWrites, _localCacheWriteHelp)
+	addAllowFlag(flags, "remote-cache-read", &opts.SkipRemoteReads, _remoteCacheReadHelp)
+	addAllowFlag(flags, "remote-cache-write", &opts.SkipRemoteWrites, _remoteCacheWriteHelp)
+	flags.StringVar(&opts.OverrideDir, "cache-dir", "", "Override the filesystem cache directory. Defaults to $TURBO_CACHE_DIR, if set.")
 	flags.IntVar(&opts.Workers, "cache-workers", 10, "Set the number of concurrent cache operations")
+	flags.StringVar(&opts.Compression.Algorithm, "cache-compression", cacheitem.CompressionZstd, _cacheCompressionHelp)
+	flags.IntVar(&opts.Compression.Level, "cache-compression-level", 0, _cacheCompressionLevelHelp)
+	flags.BoolVar(&opts.Incremental, "cache-incremental", false, _cacheIncrementalHelp)
+	flags.Int64Var(&opts.MaxSize, "cache-max-size", 0, _cacheMaxSizeHelp)
+}
+
+// allowValue is the value of a flag, like --local-cache-read, that allows
+// something by default, and sets the option that skips it when it is false
+type allowValue struct {
+	skip *bool
+}
+
+func (v *allowValue) String() string {
+	return strconv.FormatBool(!*v.skip)
+}
+
+func (v *allowValue) Set(value string) error {
+	allow, err := strconv.ParseBool(value)
+	if err != nil {
+		return err
+	}
+	*v.skip = !allow
+	return nil
+}
+
+func (v *allowValue) Type() string {
+	return "bool"
+}
+
+// addAllowFlag adds a boolean flag that defaults to true, and sets skip when it is false
+func addAllowFlag(flags *pflag.FlagSet, name string, skip *bool, usage string) {
+	flags.AddFlag(&pflag.Flag{
+		Name:        name,
+		Usage:       usage,
+		Value:       &allowValue{skip: skip},
+		DefValue:    "true",
+		NoOptDefVal: "true",
+	})
 }
 
 // New creates a new cache
 func New(opts Opts, repoRoot turbopath.AbsoluteSystemPath, client client, recorder analytics.Recorder, onCacheRemoved OnCacheRemoved) (Cache, error) {
+	if err := opts.Compression.Validate(); err != nil {
+		return nil, err
+	}
+	if opts.MaxSize < 0 {
+		return nil, fmt.Errorf("invalid --cache-max-size %v: must not be negative", opts.MaxSize)
+	}
 	c, err := newSyncCache(opts, repoRoot, client, recorder, onCacheRemoved)
 	if err != nil && !errors.Is(err, ErrNoCachesEnabled) {
 		return nil, err
 	}
-	if opts.Workers > 0 {
+	if opts.Workers > 0 && !opts.Incremental {
 		return newAsyncCache(c, opts), err
 	}
 	return c, err
@@ -105,8 +227,8 @@ func New(opts Opts, repoRoot turbopath.AbsoluteSystemPath, client client, record
 // newSyncCache can return an error with a usable noopCache.
 func newSyncCache(opts Opts, repoRoot turbopath.AbsoluteSystemPath, client client, recorder analytics.Recorder, onCacheRemoved OnCacheRemoved) (Cache, error) {
 	// Check to see if the user has turned off particular cache implementations.
-	useFsCache := !opts.SkipFilesystem
-	useHTTPCache := !opts.SkipRemote
+	useFsCache := opts.UsesFilesystem()
+	useHTTPCache := opts.UsesRemote()
 
 	// Since the above two flags are not mutually exclusive it is possible to configure
 	// yourself out of having a cache. We should tell you about it but we shouldn't fail
@@ -241,7 +363,7 @@ func (mplex *cacheMultiplexer) removeCache(removal *cacheRemoval) {
 	}
 }
 
-func (mplex *cacheMultiplexer) Fetch(anchor turbopath.AbsoluteSystemPath, key string, files []string) (bool, []turbopath.AnchoredSystemPath, int, error) {
+func (mplex *cacheMultiplexer) Fetch(anchor turbopath.AbsoluteSystemPath, key string, files []string) (ItemStatus, []turbopath.AnchoredSystemPath, int, error) {
 	// Make a shallow copy of the caches, since storeUntil can call removeCache
 	mplex.mu.RLock()
 	caches := make([]Cache, len(mplex.caches))
@@ -251,7 +373,7 @@ func (mplex *cacheMultiplexer) Fetch(anchor turbopath.AbsoluteSystemPath, key st
 	// Retrieve from caches sequentially; if we did them simultaneously we could
 	// easily write the same file from two goroutines at once.
 	for i, cache := range caches {
-		ok, actualFiles, duration, err := cache.Fetch(anchor, key, files)
+		status, actualFiles, duration, err := cache.Fetch(anchor, key, files)
 		if err != nil {
 			cd := &util.CacheDisabledError{}
 			if errors.As(err, &cd) {
@@ -265,16 +387,16 @@ func (mplex *cacheMultiplexer) Fetch(anchor turbopath.AbsoluteSystemPath, key st
 			// the operation. Future work that plumbs UI / Logging into the cache system
 			// should probably log this at least.
 		}
-		if ok {
+		if status.Hit() {
 			// Store this into other caches. We can ignore errors here because we know
 			// we have previously successfully stored in a higher-priority cache, and so the overall
 			// result is a success at fetching. Storing in lower-priority caches is an optimization.
 			_ = mplex.storeUntil(anchor, key, duration, actualFiles, i)
-			return ok, actualFiles, duration, err
+			return status, actualFiles, duration, err
 		}
 	}
 
-	return false, nil, 0, nil
+	return ItemStatus{}, nil, 0, nil
 }
 
 func (mplex *cacheMultiplexer) Exists(target string) (ItemStatus, error) {
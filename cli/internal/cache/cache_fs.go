@@ -7,7 +7,11 @@ package cache
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"os"
+	"time"
 
 	"github.com/vercel/turbo/cli/internal/analytics"
 	"github.com/vercel/turbo/cli/internal/cacheitem"
@@ -18,8 +22,20 @@ import (
 type fsCache struct {
 	cacheDirectory turbopath.AbsoluteSystemPath
 	recorder       analytics.Recorder
+	compression    cacheitem.Compression
+	// skipReads and skipWrites stop artifacts from being restored from, or saved
+	// to, the cache
+	skipReads  bool
+	skipWrites bool
+	// maxSize, if positive, is how many bytes of artifacts the cache can hold
+	// before the least recently used are evicted
+	maxSize int64
 }
 
+// artifactExtensions are the extensions of the artifacts that can be in the
+// cache, whichever compression they were written with
+var artifactExtensions = []string{".tar", ".tar.zst", ".tar.gz"}
+
 // newFsCache creates a new filesystem cache
 func newFsCache(opts Opts, recorder analytics.Recorder, repoRoot turbopath.AbsoluteSystemPath) (*fsCache, error) {
 	cacheDir := opts.resolveCacheDir(repoRoot)
@@ -29,60 +45,111 @@ func newFsCache(opts Opts, recorder analytics.Recorder, repoRoot turbopath.Absol
 	return &fsCache{
 		cacheDirectory: cacheDir,
 		recorder:       recorder,
+		compression:    opts.Compression,
+		skipReads:      opts.SkipLocalReads,
+		skipWrites:     opts.SkipLocalWrites,
+		maxSize:        opts.MaxSize,
 	}, nil
 }
 
 // Fetch returns true if items are cached. It moves them into position as a side effect.
-func (f *fsCache) Fetch(anchor turbopath.AbsoluteSystemPath, hash string, _unusedOutputGlobs []string) (bool, []turbopath.AnchoredSystemPath, int, error) {
-	uncompressedCachePath := f.cacheDirectory.UntypedJoin(hash + ".tar")
-	compressedCachePath := f.cacheDirectory.UntypedJoin(hash + ".tar.zst")
-
-	var actualCachePath turbopath.AbsoluteSystemPath
-	if uncompressedCachePath.FileExists() {
-		actualCachePath = uncompressedCachePath
-	} else if compressedCachePath.FileExists() {
-		actualCachePath = compressedCachePath
-	} else {
+func (f *fsCache) Fetch(anchor turbopath.AbsoluteSystemPath, hash string, _unusedOutputGlobs []string) (ItemStatus, []turbopath.AnchoredSystemPath, int, error) {
+	if f.skipReads {
+		return ItemStatus{}, nil, 0, nil
+	}
+	cacheItem, actualCachePath, meta, err := f.openArtifact(hash)
+	if err != nil {
+		return ItemStatus{}, nil, 0, err
+	} else if cacheItem == nil {
 		// It's not in the cache, bail now
 		f.logFetch(false, hash, 0)
-		return false, nil, 0, nil
+		return ItemStatus{}, nil, 0, nil
 	}
-
-	cacheItem, openErr := cacheitem.Open(actualCachePath)
-	if openErr != nil {
-		return false, nil, 0, openErr
+	// Artifacts written before checksums were recorded can't be verified
+	if meta.Checksum != "" {
+		checksum, err := fileChecksum(actualCachePath)
+		if errors.Is(err, os.ErrNotExist) {
+			_ = cacheItem.Close()
+			f.logFetch(false, hash, 0)
+			return ItemStatus{}, nil, 0, nil
+		} else if err != nil {
+			_ = cacheItem.Close()
+			return ItemStatus{}, nil, 0, err
+		}
+		if checksum != meta.Checksum {
+			_ = cacheItem.Close()
+			log.Printf("[WARNING] Ignoring corrupted artifact %v in local cache: checksum %v does not match expected checksum %v", hash, checksum, meta.Checksum)
+			f.logFetch(false, hash, 0)
+			return ItemStatus{}, nil, 0, nil
+		}
 	}
 
 	restoredFiles, restoreErr := cacheItem.Restore(anchor)
 	if restoreErr != nil {
 		_ = cacheItem.Close()
-		return false, nil, 0, restoreErr
-	}
-
-	meta, err := ReadCacheMetaFile(f.cacheDirectory.UntypedJoin(hash + "-meta.json"))
-	if err != nil {
-		_ = cacheItem.Close()
-		return false, nil, 0, fmt.Errorf("error reading cache metadata: %w", err)
+		return ItemStatus{}, nil, 0, restoreErr
 	}
 	f.logFetch(true, hash, meta.Duration)
 
 	// Wait to see what happens with close.
 	closeErr := cacheItem.Close()
 	if closeErr != nil {
-		return false, restoredFiles, 0, closeErr
+		return ItemStatus{}, restoredFiles, 0, closeErr
 	}
-	return true, restoredFiles, meta.Duration, nil
+	return ItemStatus{Local: true}, restoredFiles, meta.Duration, nil
 }
 
-func (f *fsCache) Exists(hash string) (ItemStatus, error) {
-	uncompressedCachePath := f.cacheDirectory.UntypedJoin(hash + ".tar")
-	compressedCachePath := f.cacheDirectory.UntypedJoin(hash + ".tar.zst")
+// openArtifact opens the artifact for the hash, along with its metadata, and marks
+// it as used. It returns a nil CacheItem if there is no artifact for the hash. Another
+// run can't evict the artifact while it is being opened.
+func (f *fsCache) openArtifact(hash string) (*cacheitem.CacheItem, turbopath.AbsoluteSystemPath, *CacheMetadata, error) {
+	unlock, err := f.lockEviction()
+	if err != nil {
+		return nil, "", nil, err
+	}
+	defer func() { _ = unlock() }()
 
-	if compressedCachePath.FileExists() || uncompressedCachePath.FileExists() {
-		return ItemStatus{Local: true}, nil
+	path, ok := f.artifactPath(hash)
+	if !ok {
+		return nil, "", nil, nil
+	}
+	meta, err := ReadCacheMetaFile(f.cacheDirectory.UntypedJoin(hash + "-meta.json"))
+	if errors.Is(err, os.ErrNotExist) {
+		// Another run evicted the artifact
+		return nil, "", nil, nil
+	} else if err != nil {
+		return nil, "", nil, fmt.Errorf("error reading cache metadata: %w", err)
+	}
+	cacheItem, err := cacheitem.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, "", nil, nil
+	} else if err != nil {
+		return nil, "", nil, err
 	}
+	// Artifacts are evicted in the order they were last used
+	now := time.Now()
+	_ = os.Chtimes(path.ToString(), now, now)
+	return cacheItem, path, meta, nil
+}
+
+func (f *fsCache) Exists(hash string) (ItemStatus, error) {
+	if f.skipReads {
+		return ItemStatus{}, nil
+	}
+	_, ok := f.artifactPath(hash)
+	return ItemStatus{Local: ok}, nil
+}
 
-	return ItemStatus{Local: false}, nil
+// artifactPath returns the path of the artifact for the hash, and whether there
+// is one
+func (f *fsCache) artifactPath(hash string) (turbopath.AbsoluteSystemPath, bool) {
+	for _, ext := range artifactExtensions {
+		path := f.cacheDirectory.UntypedJoin(hash + ext)
+		if path.FileExists() {
+			return path, true
+		}
+	}
+	return "", false
 }
 
 func (f *fsCache) logFetch(hit bool, hash string, duration int) {
@@ -102,9 +169,32 @@ func (f *fsCache) logFetch(hit bool, hash string, duration int) {
 }
 
 func (f *fsCache) Put(anchor turbopath.AbsoluteSystemPath, hash string, duration int, files []turbopath.AnchoredSystemPath) error {
-	cachePath := f.cacheDirectory.UntypedJoin(hash + ".tar.zst")
-	cacheItem, err := cacheitem.Create(cachePath)
+	if f.skipWrites {
+		return nil
+	}
+	cachePath := f.cacheDirectory.UntypedJoin(hash + f.compression.Extension())
+	// The artifact is written next to where it belongs, and only moved into place
+	// once it is complete, so that a write that is interrupted, e.g. by turbo
+	// being killed, doesn't leave a partial artifact in the cache. Its name is
+	// unique, so that runs sharing the cache directory can write the same hash.
+	tmpFile, err := os.CreateTemp(f.cacheDirectory.ToString(), hash+f.compression.Extension()+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := turbopath.AbsoluteSystemPathFromUpstream(tmpFile.Name())
+	// Temporary files are only readable by their owner, but artifacts are read by
+	// everyone who shares the cache directory
+	err = tmpFile.Chmod(0644)
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = tmpPath.Remove()
+		return err
+	}
+	cacheItem, err := cacheitem.CreateCompressed(tmpPath, f.compression)
 	if err != nil {
+		_ = tmpPath.Remove()
 		return err
 	}
 
@@ -112,21 +202,47 @@ func (f *fsCache) Put(anchor turbopath.AbsoluteSystemPath, hash string, duration
 		err := cacheItem.AddFile(anchor, file)
 		if err != nil {
 			_ = cacheItem.Close()
+			_ = tmpPath.Remove()
 			return err
 		}
 	}
 
-	writeErr := WriteCacheMetaFile(f.cacheDirectory.UntypedJoin(hash+"-meta.json"), &CacheMetadata{
+	// The checksum covers the finished artifact, so it can only be computed once it is closed
+	if err := cacheItem.Close(); err != nil {
+		_ = tmpPath.Remove()
+		return err
+	}
+	checksum, err := fileChecksum(tmpPath)
+	if err != nil {
+		_ = tmpPath.Remove()
+		return err
+	}
+
+	// Another run can't evict the artifact between writing its metadata and moving
+	// it into place
+	unlock, err := f.lockEviction()
+	if err != nil {
+		_ = tmpPath.Remove()
+		return err
+	}
+	err = WriteCacheMetaFile(f.cacheDirectory.UntypedJoin(hash+"-meta.json"), &CacheMetadata{
 		Duration: duration,
 		Hash:     hash,
+		Checksum: checksum,
 	})
-
-	if writeErr != nil {
-		_ = cacheItem.Close()
-		return writeErr
+	if err != nil {
+		_ = unlock()
+		_ = tmpPath.Remove()
+		return err
 	}
-
-	return cacheItem.Close()
+	if err := tmpPath.Rename(cachePath); err != nil {
+		_ = unlock()
+		return err
+	}
+	if err := unlock(); err != nil {
+		return err
+	}
+	return f.evict()
 }
 
 func (f *fsCache) Clean(anchor turbopath.AbsoluteSystemPath) {
@@ -144,6 +260,8 @@ func (f *fsCache) Shutdown() {}
 type CacheMetadata struct {
 	Hash     string `json:"hash"`
 	Duration int    `json:"duration"`
+	// Checksum is the SHA-256 of the stored artifact, used to detect corruption
+	Checksum string `json:"checksum,omitempty"`
 }
 
 // WriteCacheMetaFile writes cache metadata file at a path
@@ -1,7 +1,10 @@
 package cache
 
 import (
+	"bytes"
+	"fmt"
 	"path/filepath"
+	"sync"
 	"testing"
 
 	"github.com/vercel/turbo/cli/internal/analytics"
@@ -216,9 +219,9 @@ func TestFetch(t *testing.T) {
 
 	outputDir := turbopath.AbsoluteSystemPath(t.TempDir())
 	dstOutputPath := "some-package"
-	hit, files, _, err := cache.Fetch(outputDir, "the-hash", []string{})
+	status, files, _, err := cache.Fetch(outputDir, "the-hash", []string{})
 	assert.NilError(t, err, "Fetch")
-	if !hit {
+	if !status.Local {
 		t.Error("Fetch got false, want true")
 	}
 	if len(files) != len(inputFiles) {
@@ -250,3 +253,184 @@ func TestFetch(t *testing.T) {
 	assert.NilError(t, circleReadlinkErr, "Circle Readlink")
 	assert.Equal(t, circleTarget, srcCircleLinkTarget.ToString())
 }
+
+func TestFetchCorruptedArtifact(t *testing.T) {
+	srcDir := turbopath.AbsoluteSystemPath(t.TempDir())
+	cacheDir := turbopath.AbsoluteSystemPath(t.TempDir())
+	filePath := srcDir.UntypedJoin("some-package", "file")
+	assert.NilError(t, filePath.EnsureDir(), "EnsureDir")
+	assert.NilError(t, filePath.WriteFile([]byte("contents"), 0644), "WriteFile")
+
+	cache := &fsCache{
+		cacheDirectory: cacheDir,
+		recorder:       &dummyRecorder{},
+	}
+	inputFiles := []turbopath.AnchoredSystemPath{
+		turbopath.AnchoredUnixPath("some-package/file").ToSystemPath(),
+	}
+	assert.NilError(t, cache.Put(srcDir, "the-hash", 0, inputFiles), "Put")
+
+	meta, err := ReadCacheMetaFile(cacheDir.UntypedJoin("the-hash-meta.json"))
+	assert.NilError(t, err, "ReadCacheMetaFile")
+	assert.Assert(t, meta.Checksum != "", "expected a checksum in the cache metadata")
+
+	// Flip a byte in the stored artifact
+	artifactPath := cacheDir.UntypedJoin("the-hash.tar.zst")
+	artifact, err := artifactPath.ReadFile()
+	assert.NilError(t, err, "ReadFile")
+	artifact[len(artifact)/2] ^= 0xff
+	assert.NilError(t, artifactPath.WriteFile(artifact, 0644), "WriteFile")
+
+	outputDir := turbopath.AbsoluteSystemPath(t.TempDir())
+	status, files, _, err := cache.Fetch(outputDir, "the-hash", []string{})
+	assert.NilError(t, err, "Fetch")
+	assert.Equal(t, status, ItemStatus{})
+	assert.Equal(t, len(files), 0)
+	assert.Assert(t, !outputDir.UntypedJoin("some-package").Exists(), "corrupted artifact was restored")
+}
+
+func TestPutAndFetchCompressed(t *testing.T) {
+	srcDir := turbopath.AbsoluteSystemPath(t.TempDir())
+	contents := map[string][]byte{
+		"some-package/dist/index.js":   []byte("console.log('hello')\n"),
+		"some-package/dist/empty":      {},
+		"some-package/dist/data/a.bin": {0x28, 0xb5, 0x2f, 0xfd, 0x1f, 0x8b, 0x00, 0xff},
+	}
+	inputFiles := []turbopath.AnchoredSystemPath{
+		turbopath.AnchoredUnixPath("some-package/dist/").ToSystemPath(),
+		turbopath.AnchoredUnixPath("some-package/dist/data/").ToSystemPath(),
+	}
+	for name, data := range contents {
+		filePath := srcDir.UntypedJoin(filepath.FromSlash(name))
+		assert.NilError(t, filePath.EnsureDir(), "EnsureDir")
+		assert.NilError(t, filePath.WriteFile(data, 0644), "WriteFile")
+		inputFiles = append(inputFiles, turbopath.AnchoredUnixPath(name).ToSystemPath())
+	}
+
+	tests := []struct {
+		compression cacheitem.Compression
+		artifact    string
+	}{
+		{cacheitem.Compression{Algorithm: cacheitem.CompressionNone}, "the-hash.tar"},
+		{cacheitem.Compression{Algorithm: cacheitem.CompressionGzip}, "the-hash.tar.gz"},
+		{cacheitem.Compression{Algorithm: cacheitem.CompressionGzip, Level: 1}, "the-hash.tar.gz"},
+		{cacheitem.Compression{Algorithm: cacheitem.CompressionZstd}, "the-hash.tar.zst"},
+		{cacheitem.Compression{Algorithm: cacheitem.CompressionZstd, Level: 19}, "the-hash.tar.zst"},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%v level %v", tt.compression.Algorithm, tt.compression.Level), func(t *testing.T) {
+			assert.NilError(t, tt.compression.Validate(), "Validate")
+			cacheDir := turbopath.AbsoluteSystemPath(t.TempDir())
+			cache := &fsCache{
+				cacheDirectory: cacheDir,
+				recorder:       &dummyRecorder{},
+				compression:    tt.compression,
+			}
+			assert.NilError(t, cache.Put(srcDir, "the-hash", 0, inputFiles), "Put")
+			assert.Assert(t, cacheDir.UntypedJoin(tt.artifact).FileExists(), "expected %v", tt.artifact)
+
+			// The compression is detected from the artifact, not from the options
+			cache.compression = cacheitem.Compression{Algorithm: cacheitem.CompressionNone}
+			outputDir := turbopath.AbsoluteSystemPath(t.TempDir())
+			status, files, _, err := cache.Fetch(outputDir, "the-hash", []string{})
+			assert.NilError(t, err, "Fetch")
+			assert.Equal(t, status, ItemStatus{Local: true})
+			assert.Equal(t, len(files), len(inputFiles))
+			for name, data := range contents {
+				restored, err := outputDir.UntypedJoin(filepath.FromSlash(name)).ReadFile()
+				assert.NilError(t, err, "ReadFile")
+				assert.DeepEqual(t, restored, data)
+			}
+		})
+	}
+}
+
+func TestCompressionValidate(t *testing.T) {
+	assert.NilError(t, cacheitem.Compression{}.Validate())
+	assert.ErrorContains(t, cacheitem.Compression{Algorithm: "brotli"}.Validate(), `invalid compression "brotli"`)
+	assert.ErrorContains(t, cacheitem.Compression{Algorithm: cacheitem.CompressionGzip, Level: 10}.Validate(), "invalid gzip compression level 10")
+	assert.ErrorContains(t, cacheitem.Compression{Algorithm: cacheitem.CompressionZstd, Level: 21}.Validate(), "invalid zstd compression level 21")
+	assert.ErrorContains(t, cacheitem.Compression{Algorithm: cacheitem.CompressionNone, Level: 3}.Validate(), "without compression")
+}
+
+func TestInterruptedPut(t *testing.T) {
+	srcDir := turbopath.AbsoluteSystemPath(t.TempDir())
+	cacheDir := turbopath.AbsoluteSystemPath(t.TempDir())
+	filePath := srcDir.UntypedJoin("some-package", "file")
+	assert.NilError(t, filePath.EnsureDir(), "EnsureDir")
+	assert.NilError(t, filePath.WriteFile([]byte("contents"), 0644), "WriteFile")
+
+	// A write that was interrupted leaves only its temporary file behind
+	partialPath := cacheDir.UntypedJoin("the-hash.tar.zst.123456.tmp")
+	assert.NilError(t, partialPath.WriteFile([]byte{0x28, 0xb5}, 0644), "WriteFile")
+
+	cache := &fsCache{
+		cacheDirectory: cacheDir,
+		recorder:       &dummyRecorder{},
+	}
+	status, err := cache.Exists("the-hash")
+	assert.NilError(t, err, "Exists")
+	assert.Equal(t, status, ItemStatus{})
+	outputDir := turbopath.AbsoluteSystemPath(t.TempDir())
+	status, files, _, err := cache.Fetch(outputDir, "the-hash", []string{})
+	assert.NilError(t, err, "Fetch")
+	assert.Equal(t, status, ItemStatus{})
+	assert.Equal(t, len(files), 0)
+
+	inputFiles := []turbopath.AnchoredSystemPath{
+		turbopath.AnchoredUnixPath("some-package/file").ToSystemPath(),
+	}
+	assert.NilError(t, cache.Put(srcDir, "the-hash", 0, inputFiles), "Put")
+	tmpFiles, err := filepath.Glob(cacheDir.UntypedJoin("*.tmp").ToString())
+	assert.NilError(t, err, "Glob")
+	assert.DeepEqual(t, tmpFiles, []string{partialPath.ToString()})
+	status, files, _, err = cache.Fetch(outputDir, "the-hash", []string{})
+	assert.NilError(t, err, "Fetch")
+	assert.Equal(t, status, ItemStatus{Local: true})
+	assert.Equal(t, len(files), 1)
+}
+
+func TestConcurrentPutSameHash(t *testing.T) {
+	srcDir := turbopath.AbsoluteSystemPath(t.TempDir())
+	cacheDir := turbopath.AbsoluteSystemPath(t.TempDir())
+	contents := bytes.Repeat([]byte("contents"), 1<<14)
+	filePath := srcDir.UntypedJoin("some-package", "file")
+	assert.NilError(t, filePath.EnsureDir(), "EnsureDir")
+	assert.NilError(t, filePath.WriteFile(contents, 0644), "WriteFile")
+	inputFiles := []turbopath.AnchoredSystemPath{
+		turbopath.AnchoredUnixPath("some-package/file").ToSystemPath(),
+	}
+
+	// Runs that share the cache directory write the same artifact at once
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		run := &fsCache{
+			cacheDirectory: cacheDir,
+			recorder:       &dummyRecorder{},
+			compression:    cacheitem.Compression{Algorithm: cacheitem.CompressionNone},
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- run.Put(srcDir, "the-hash", 0, inputFiles)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		assert.NilError(t, err, "Put")
+	}
+
+	cache := &fsCache{cacheDirectory: cacheDir, recorder: &dummyRecorder{}}
+	outputDir := turbopath.AbsoluteSystemPath(t.TempDir())
+	status, _, _, err := cache.Fetch(outputDir, "the-hash", nil)
+	assert.NilError(t, err, "Fetch")
+	assert.Equal(t, status, ItemStatus{Local: true})
+	restored, err := outputDir.UntypedJoin("some-package", "file").ReadFile()
+	assert.NilError(t, err, "ReadFile")
+	assert.DeepEqual(t, restored, contents)
+	tmpFiles, err := filepath.Glob(cacheDir.UntypedJoin("*.tmp").ToString())
+	assert.NilError(t, err, "Glob")
+	assert.Equal(t, len(tmpFiles), 0)
+}
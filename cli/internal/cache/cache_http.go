@@ -17,9 +17,8 @@ import (
 	"strconv"
 	"time"
 
-	"github.com/DataDog/zstd"
-
 	"github.com/vercel/turbo/cli/internal/analytics"
+	"github.com/vercel/turbo/cli/internal/cacheitem"
 	"github.com/vercel/turbo/cli/internal/tarpatch"
 	"github.com/vercel/turbo/cli/internal/turbopath"
 )
@@ -32,12 +31,17 @@ type client interface {
 }
 
 type httpCache struct {
-	writable       bool
+	// skipReads and skipWrites stop artifacts from being restored from, or saved
+	// to, the cache
+	skipReads      bool
+	skipWrites     bool
 	client         client
 	requestLimiter limiter
 	recorder       analytics.Recorder
 	signerVerifier *ArtifactSignatureAuthentication
 	repoRoot       turbopath.AbsoluteSystemPath
+	transferStats  *TransferStats
+	compression    cacheitem.Compression
 }
 
 type limiter chan struct{}
@@ -57,7 +61,9 @@ var mtime = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
 const nobody = 65534
 
 func (cache *httpCache) Put(anchor turbopath.AbsoluteSystemPath, hash string, duration int, files []turbopath.AnchoredSystemPath) error {
-	// if cache.writable {
+	if cache.skipWrites {
+		return nil
+	}
 	cache.requestLimiter.acquire()
 	defer cache.requestLimiter.release()
 
@@ -78,16 +84,29 @@ func (cache *httpCache) Put(anchor turbopath.AbsoluteSystemPath, hash string, du
 			return fmt.Errorf("failed to store files in HTTP cache: %w", err)
 		}
 	}
-	return cache.client.PutArtifact(hash, artifactBody, duration, tag)
+	if err := cache.client.PutArtifact(hash, artifactBody, duration, tag); err != nil {
+		return err
+	}
+	cache.transferStats.recordUpload(hash, int64(len(artifactBody)))
+	return nil
 }
 
 // write writes a series of files into the given Writer.
-func (cache *httpCache) write(w io.WriteCloser, hash string, files []turbopath.AnchoredSystemPath) {
+func (cache *httpCache) write(w *io.PipeWriter, hash string, files []turbopath.AnchoredSystemPath) {
 	defer w.Close()
 	defer func() { _ = w.Close() }()
-	zw := zstd.NewWriter(w)
-	defer func() { _ = zw.Close() }()
-	tw := tar.NewWriter(zw)
+	var tw *tar.Writer
+	zw, err := cache.compression.NewWriter(w)
+	if err != nil {
+		_ = w.CloseWithError(err)
+		return
+	}
+	if zw != nil {
+		defer func() { _ = zw.Close() }()
+		tw = tar.NewWriter(zw)
+	} else {
+		tw = tar.NewWriter(w)
+	}
 	defer func() { _ = tw.Close() }()
 	for _, file := range files {
 		// log.Printf("caching file %v", file)
@@ -143,19 +162,25 @@ func (cache *httpCache) storeFile(tw *tar.Writer, repoRelativePath turbopath.Anc
 	return err
 }
 
-func (cache *httpCache) Fetch(anchor turbopath.AbsoluteSystemPath, key string, _unusedOutputGlobs []string) (bool, []turbopath.AnchoredSystemPath, int, error) {
+func (cache *httpCache) Fetch(anchor turbopath.AbsoluteSystemPath, key string, _unusedOutputGlobs []string) (ItemStatus, []turbopath.AnchoredSystemPath, int, error) {
+	if cache.skipReads {
+		return ItemStatus{}, nil, 0, nil
+	}
 	cache.requestLimiter.acquire()
 	defer cache.requestLimiter.release()
 	hit, files, duration, err := cache.retrieve(key)
 	if err != nil {
 		// TODO: analytics event?
-		return false, files, duration, fmt.Errorf("failed to retrieve files from HTTP cache: %w", err)
+		return ItemStatus{}, files, duration, fmt.Errorf("failed to retrieve files from HTTP cache: %w", err)
 	}
 	cache.logFetch(hit, key, duration)
-	return hit, files, duration, err
+	return ItemStatus{Remote: hit}, files, duration, err
 }
 
 func (cache *httpCache) Exists(key string) (ItemStatus, error) {
+	if cache.skipReads {
+		return ItemStatus{}, nil
+	}
 	cache.requestLimiter.acquire()
 	defer cache.requestLimiter.release()
 	hit, err := cache.exists(key)
@@ -198,6 +223,7 @@ func (cache *httpCache) exists(hash string) (bool, error) {
 }
 
 func (cache *httpCache) retrieve(hash string) (bool, []turbopath.AnchoredSystemPath, int, error) {
+	start := time.Now()
 	resp, err := cache.client.FetchArtifact(hash)
 	if err != nil {
 		return false, nil, 0, err
@@ -221,28 +247,40 @@ func (cache *httpCache) retrieve(hash string) (bool, []turbopath.AnchoredSystemP
 	var tarReader io.Reader
 
 	defer func() { _ = resp.Body.Close() }()
-	if cache.signerVerifier.isEnabled() {
-		expectedTag := resp.Header.Get("x-artifact-tag")
-		if expectedTag == "" {
-			// If the verifier is enabled all incoming artifact downloads must have a signature
-			return false, nil, 0, errors.New("artifact verification failed: Downloaded artifact is missing required x-artifact-tag header")
-		}
-		b, err := ioutil.ReadAll(resp.Body)
+	body := &countingReader{r: resp.Body}
+	defer func() { cache.transferStats.recordDownload(hash, body.n, time.Since(start)) }()
+	// Artifacts uploaded before checksums were sent, or by other clients, can't be verified
+	expectedChecksum := resp.Header.Get("x-artifact-checksum")
+	if cache.signerVerifier.isEnabled() || expectedChecksum != "" {
+		b, err := ioutil.ReadAll(body)
 		if err != nil {
 			return false, nil, 0, fmt.Errorf("artifact verification failed: %w", err)
 		}
-		isValid, err := cache.signerVerifier.validate(hash, b, expectedTag)
-		if err != nil {
-			return false, nil, 0, fmt.Errorf("artifact verification failed: %w", err)
+		if expectedChecksum != "" {
+			if checksum := artifactChecksum(b); checksum != expectedChecksum {
+				log.Printf("[WARNING] Ignoring corrupted artifact %v from remote cache: checksum %v does not match expected checksum %v", hash, checksum, expectedChecksum)
+				return false, nil, 0, nil
+			}
 		}
-		if !isValid {
-			err = fmt.Errorf("artifact verification failed: artifact tag does not match expected tag %s", expectedTag)
-			return false, nil, 0, err
+		if cache.signerVerifier.isEnabled() {
+			expectedTag := resp.Header.Get("x-artifact-tag")
+			if expectedTag == "" {
+				// If the verifier is enabled all incoming artifact downloads must have a signature
+				return false, nil, 0, errors.New("artifact verification failed: Downloaded artifact is missing required x-artifact-tag header")
+			}
+			isValid, err := cache.signerVerifier.validate(hash, b, expectedTag)
+			if err != nil {
+				return false, nil, 0, fmt.Errorf("artifact verification failed: %w", err)
+			}
+			if !isValid {
+				err = fmt.Errorf("artifact verification failed: artifact tag does not match expected tag %s", expectedTag)
+				return false, nil, 0, err
+			}
 		}
 		// The artifact has been verified and the body can be read and untarred
 		tarReader = bytes.NewReader(b)
 	} else {
-		tarReader = resp.Body
+		tarReader = body
 	}
 	files, err := restoreTar(cache.repoRoot, tarReader)
 	if err != nil {
@@ -258,7 +296,10 @@ func (cache *httpCache) retrieve(hash string) (bool, []turbopath.AnchoredSystemP
 func restoreTar(root turbopath.AbsoluteSystemPath, reader io.Reader) ([]turbopath.AnchoredSystemPath, error) {
 	files := []turbopath.AnchoredSystemPath{}
 	missingLinks := []*tar.Header{}
-	zr := zstd.NewReader(reader)
+	zr, err := cacheitem.Decompress(reader)
+	if err != nil {
+		return nil, err
+	}
 	var closeError error
 	defer func() { closeError = zr.Close() }()
 	tr := tar.NewReader(zr)
@@ -361,10 +402,13 @@ func (cache *httpCache) Shutdown() {}
 
 func newHTTPCache(opts Opts, client client, recorder analytics.Recorder) *httpCache {
 	return &httpCache{
-		writable:       true,
+		skipReads:      opts.SkipRemoteReads,
+		skipWrites:     opts.SkipRemoteWrites,
 		client:         client,
 		requestLimiter: make(limiter, 20),
 		recorder:       recorder,
+		transferStats:  opts.TransferStats,
+		compression:    opts.Compression,
 		signerVerifier: &ArtifactSignatureAuthentication{
 			// TODO(Gaspar): this should use RemoteCacheOptions.TeamId once we start
 			// enforcing team restrictions for repositories.
@@ -4,11 +4,13 @@ import (
 	"archive/tar"
 	"bytes"
 	"errors"
+	"io"
 	"net/http"
 	"testing"
 
 	"github.com/DataDog/zstd"
 
+	"github.com/vercel/turbo/cli/internal/cacheitem"
 	"github.com/vercel/turbo/cli/internal/fs"
 	"github.com/vercel/turbo/cli/internal/turbopath"
 	"github.com/vercel/turbo/cli/internal/util"
@@ -243,3 +245,123 @@ func TestRestoreInvalidTar(t *testing.T) {
 // Note that testing Put will require mocking the filesystem and is not currently the most
 // interesting test. The current implementation directly returns the error from PutArtifact.
 // We should still add the test once feasible to avoid future breakage.
+
+type artifactResp struct {
+	artifact []byte
+	uploaded []byte
+	checksum string
+}
+
+func (ar *artifactResp) PutArtifact(hash string, body []byte, duration int, tag string) error {
+	ar.uploaded = body
+	return nil
+}
+
+func (ar *artifactResp) FetchArtifact(hash string) (*http.Response, error) {
+	header := http.Header{}
+	if ar.checksum != "" {
+		header.Set("x-artifact-checksum", ar.checksum)
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     header,
+		Body:       io.NopCloser(bytes.NewReader(ar.artifact)),
+	}, nil
+}
+
+func (ar *artifactResp) ArtifactExists(hash string) (*http.Response, error) {
+	return nil, nil
+}
+
+func (ar *artifactResp) GetTeamID() string {
+	return ""
+}
+
+func TestTransferStats(t *testing.T) {
+	client := &artifactResp{artifact: makeValidTar(t).Bytes()}
+	stats := NewTransferStats()
+	cache := &httpCache{
+		client:         client,
+		requestLimiter: make(limiter, 20),
+		signerVerifier: &ArtifactSignatureAuthentication{},
+		repoRoot:       fs.AbsoluteSystemPathFromUpstream(t.TempDir()),
+		recorder:       &nullRecorder{},
+		transferStats:  stats,
+	}
+
+	status, _, _, err := cache.Fetch(cache.repoRoot, "download-hash", nil)
+	assert.NilError(t, err, "Fetch")
+	assert.Equal(t, status, ItemStatus{Remote: true})
+	assert.Equal(t, stats.Downloaded("download-hash"), int64(len(client.artifact)))
+	assert.Assert(t, stats.DownloadTime("download-hash") > 0)
+
+	err = cache.Put(cache.repoRoot, "upload-hash", 0, nil)
+	assert.NilError(t, err, "Put")
+	assert.Equal(t, stats.Uploaded("upload-hash"), int64(len(client.uploaded)))
+	assert.Assert(t, len(client.uploaded) > 0)
+
+	assert.Equal(t, stats.Uploaded("download-hash"), int64(0))
+	assert.Equal(t, stats.Downloaded("other-hash"), int64(0))
+}
+
+func TestFetchCorruptedRemoteArtifact(t *testing.T) {
+	artifact := makeValidTar(t).Bytes()
+	client := &artifactResp{artifact: artifact, checksum: artifactChecksum(artifact)}
+	cache := &httpCache{
+		client:         client,
+		requestLimiter: make(limiter, 20),
+		signerVerifier: &ArtifactSignatureAuthentication{},
+		repoRoot:       fs.AbsoluteSystemPathFromUpstream(t.TempDir()),
+		recorder:       &nullRecorder{},
+		transferStats:  NewTransferStats(),
+	}
+
+	status, files, _, err := cache.Fetch(cache.repoRoot, "some-hash", nil)
+	assert.NilError(t, err, "Fetch")
+	assert.Equal(t, status, ItemStatus{Remote: true})
+	assert.Assert(t, len(files) > 0)
+
+	corrupted := append([]byte{}, artifact...)
+	corrupted[len(corrupted)/2] ^= 0xff
+	client.artifact = corrupted
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	cache.repoRoot = repoRoot
+
+	status, files, _, err = cache.Fetch(repoRoot, "some-hash", nil)
+	assert.NilError(t, err, "Fetch")
+	assert.Equal(t, status, ItemStatus{})
+	assert.Equal(t, len(files), 0)
+	assert.Assert(t, !repoRoot.UntypedJoin("my-pkg").Exists(), "corrupted artifact was restored")
+}
+
+func TestWriteAndRestoreCompressed(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	filePath := repoRoot.UntypedJoin("my-pkg", "some-file")
+	assert.NilError(t, filePath.EnsureDir(), "EnsureDir")
+	assert.NilError(t, filePath.WriteFile([]byte("some-file-contents"), 0644), "WriteFile")
+	files := []turbopath.AnchoredSystemPath{
+		turbopath.AnchoredUnixPath("my-pkg/").ToSystemPath(),
+		turbopath.AnchoredUnixPath("my-pkg/some-file").ToSystemPath(),
+	}
+
+	for _, algorithm := range []string{cacheitem.CompressionNone, cacheitem.CompressionGzip, cacheitem.CompressionZstd} {
+		t.Run(algorithm, func(t *testing.T) {
+			cache := &httpCache{
+				repoRoot:    repoRoot,
+				compression: cacheitem.Compression{Algorithm: algorithm},
+			}
+			r, w := io.Pipe()
+			go cache.write(w, "some-hash", files)
+			artifact, err := io.ReadAll(r)
+			assert.NilError(t, err, "ReadAll")
+
+			root := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+			restored, err := restoreTar(root, bytes.NewReader(artifact))
+			assert.NilError(t, err, "restoreTar")
+			assert.Equal(t, len(restored), len(files))
+			contents, err := root.UntypedJoin("my-pkg", "some-file").ReadFile()
+			assert.NilError(t, err, "ReadFile")
+			assert.DeepEqual(t, contents, []byte("some-file-contents"))
+		})
+	}
+}
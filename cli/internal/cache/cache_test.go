@@ -1,7 +1,9 @@
 package cache
 
 import (
+	"context"
 	"net/http"
+	"os"
 	"reflect"
 	"sync/atomic"
 	"testing"
@@ -17,16 +19,16 @@ type testCache struct {
 	entries     map[string][]turbopath.AnchoredSystemPath
 }
 
-func (tc *testCache) Fetch(anchor turbopath.AbsoluteSystemPath, hash string, files []string) (bool, []turbopath.AnchoredSystemPath, int, error) {
+func (tc *testCache) Fetch(anchor turbopath.AbsoluteSystemPath, hash string, files []string) (ItemStatus, []turbopath.AnchoredSystemPath, int, error) {
 	if tc.disabledErr != nil {
-		return false, nil, 0, tc.disabledErr
+		return ItemStatus{}, nil, 0, tc.disabledErr
 	}
 	foundFiles, ok := tc.entries[hash]
 	if ok {
 		duration := 5
-		return true, foundFiles, duration, nil
+		return ItemStatus{Local: true}, foundFiles, duration, nil
 	}
-	return false, nil, 0, nil
+	return ItemStatus{}, nil, 0, nil
 }
 
 func (tc *testCache) Exists(hash string) (ItemStatus, error) {
@@ -106,11 +108,11 @@ func TestPutCachingDisabled(t *testing.T) {
 	mplex.mu.RUnlock()
 
 	// subsequent Fetch should still work
-	hit, _, _, err := mplex.Fetch("unused-target", "some-hash", []string{"unused", "files"})
+	status, _, _, err := mplex.Fetch("unused-target", "some-hash", []string{"unused", "files"})
 	if err != nil {
 		t.Errorf("got error fetching files: %v", err)
 	}
-	if !hit {
+	if !status.Hit() {
 		t.Error("failed to find previously stored files")
 	}
 
@@ -191,12 +193,12 @@ func TestFetchCachingDisabled(t *testing.T) {
 		},
 	}
 
-	hit, _, _, err := mplex.Fetch("unused-target", "some-hash", []string{"unused", "files"})
+	status, _, _, err := mplex.Fetch("unused-target", "some-hash", []string{"unused", "files"})
 	if err != nil {
 		// don't leak the cache removal
 		t.Errorf("Fetch got error %v, want <nil>", err)
 	}
-	if hit {
+	if status.Hit() {
 		t.Error("hit on empty cache, expected miss")
 	}
 
@@ -320,3 +322,154 @@ func TestNew(t *testing.T) {
 		})
 	}
 }
+
+func TestIncrementalPut(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	for _, name := range []string{"first", "second"} {
+		path := repoRoot.UntypedJoin(name, "dist", "out")
+		if err := path.EnsureDir(); err != nil {
+			t.Fatalf("EnsureDir: %v", err)
+		}
+		if err := path.WriteFile([]byte(name), 0644); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+	}
+	opts := Opts{
+		OverrideDir: repoRoot.UntypedJoin("cache").ToString(),
+		SkipRemote:  true,
+		Workers:     10,
+		Incremental: true,
+	}
+	c, err := New(opts, repoRoot, &fakeClient{}, &nullRecorder{}, func(Cache, error) {})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	// The run is canceled after the first of two tasks finishes, so the second
+	// is never cached, and the cache is never shut down
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for _, name := range []string{"first", "second"} {
+		if ctx.Err() != nil {
+			break
+		}
+		files := []turbopath.AnchoredSystemPath{turbopath.AnchoredUnixPath(name + "/dist/out").ToSystemPath()}
+		if err := c.Put(repoRoot, name+"-hash", 0, files); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+		cancel()
+	}
+
+	// The next run finds the first task cached
+	next, err := New(opts, repoRoot, &fakeClient{}, &nullRecorder{}, func(Cache, error) {})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer next.Shutdown()
+	status, err := next.Exists("first-hash")
+	if err != nil {
+		t.Fatalf("Exists: %v", err)
+	}
+	if !status.Local {
+		t.Error("expected the task that finished before the run was canceled to be cached")
+	}
+	status, err = next.Exists("second-hash")
+	if err != nil {
+		t.Fatalf("Exists: %v", err)
+	}
+	if status.Local {
+		t.Error("expected the task that never ran not to be cached")
+	}
+}
+
+// recordingClient records the requests made to the remote cache
+type recordingClient struct {
+	fetches int32
+	exists  int32
+	puts    int32
+}
+
+func (c *recordingClient) FetchArtifact(hash string) (*http.Response, error) {
+	atomic.AddInt32(&c.fetches, 1)
+	return &http.Response{StatusCode: http.StatusNotFound, Body: http.NoBody}, nil
+}
+
+func (c *recordingClient) ArtifactExists(hash string) (*http.Response, error) {
+	atomic.AddInt32(&c.exists, 1)
+	return &http.Response{StatusCode: http.StatusNotFound, Body: http.NoBody}, nil
+}
+
+func (c *recordingClient) GetTeamID() string {
+	return "fake-team-id"
+}
+
+func (c *recordingClient) PutArtifact(hash string, body []byte, duration int, tag string) error {
+	atomic.AddInt32(&c.puts, 1)
+	return nil
+}
+
+func TestCachePermissions(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	path := repoRoot.UntypedJoin("dist", "out")
+	if err := path.EnsureDir(); err != nil {
+		t.Fatalf("EnsureDir: %v", err)
+	}
+	if err := path.WriteFile([]byte("out"), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	files := []turbopath.AnchoredSystemPath{turbopath.AnchoredUnixPath("dist/out").ToSystemPath()}
+
+	t.Run("a write-only remote cache is never read", func(t *testing.T) {
+		client := &recordingClient{}
+		c, err := New(Opts{SkipFilesystem: true, SkipRemoteReads: true}, repoRoot, client, &nullRecorder{}, func(Cache, error) {})
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		if err := c.Put(repoRoot, "some-hash", 0, files); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+		if status, _, _, err := c.Fetch(repoRoot, "some-hash", nil); err != nil || status.Hit() {
+			t.Errorf("Fetch() = %v, %v, want a miss", status, err)
+		}
+		if status, err := c.Exists("some-hash"); err != nil || status.Hit() {
+			t.Errorf("Exists() = %v, %v, want a miss", status, err)
+		}
+		if client.fetches != 0 || client.exists != 0 {
+			t.Errorf("remote cache got %v GETs and %v HEADs, want none", client.fetches, client.exists)
+		}
+		if client.puts != 1 {
+			t.Errorf("remote cache got %v PUTs, want 1", client.puts)
+		}
+	})
+
+	t.Run("a read-only local cache is never written", func(t *testing.T) {
+		cacheDir := repoRoot.UntypedJoin("read-only-cache")
+		opts := Opts{OverrideDir: cacheDir.ToString(), SkipRemote: true, SkipLocalWrites: true}
+		c, err := New(opts, repoRoot, &fakeClient{}, &nullRecorder{}, func(Cache, error) {})
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		if err := c.Put(repoRoot, "some-hash", 0, files); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+		entries, err := os.ReadDir(cacheDir.ToString())
+		if err != nil {
+			t.Fatalf("ReadDir: %v", err)
+		}
+		if len(entries) != 0 {
+			t.Errorf("read-only cache has %v entries, want none", len(entries))
+		}
+
+		// Artifacts written by something else can still be restored
+		writable, err := New(Opts{OverrideDir: cacheDir.ToString(), SkipRemote: true}, repoRoot, &fakeClient{}, &nullRecorder{}, func(Cache, error) {})
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		if err := writable.Put(repoRoot, "some-hash", 0, files); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+		if status, _, _, err := c.Fetch(repoRoot, "some-hash", nil); err != nil || !status.Local {
+			t.Errorf("Fetch() = %v, %v, want a local hit", status, err)
+		}
+	})
+}
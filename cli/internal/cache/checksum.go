@@ -0,0 +1,29 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+// artifactChecksum returns the hex-encoded SHA-256 of an artifact's contents
+func artifactChecksum(artifact []byte) string {
+	sum := sha256.Sum256(artifact)
+	return hex.EncodeToString(sum[:])
+}
+
+// fileChecksum returns the hex-encoded SHA-256 of the contents of the file at path
+func fileChecksum(path turbopath.AbsoluteSystemPath) (string, error) {
+	f, err := path.Open()
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
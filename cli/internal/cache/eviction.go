@@ -0,0 +1,160 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nightlyone/lockfile"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+const (
+	// cacheLockTimeout is how long to wait for other runs sharing the cache
+	// directory to finish evicting artifacts
+	cacheLockTimeout = 30 * time.Second
+	// cacheLockRetryInterval is how often the lock on the cache directory is retried
+	cacheLockRetryInterval = 10 * time.Millisecond
+)
+
+// evictMu keeps caches in this process from evicting, or from adding and using
+// artifacts while another evicts, since the lock file on a cache directory only
+// keeps other processes out
+var evictMu sync.Mutex
+
+// cachedArtifact is an artifact in the filesystem cache
+type cachedArtifact struct {
+	hash     string
+	path     turbopath.AbsoluteSystemPath
+	size     int64
+	lastUsed time.Time
+}
+
+// evict removes the least recently used artifacts, along with their metadata,
+// until the artifacts and their metadata fit in the cache's maximum size. An
+// artifact is used when it is written or restored. Runs that share the cache
+// directory take turns to evict through a lock file in it.
+func (f *fsCache) evict() error {
+	if f.maxSize <= 0 {
+		return nil
+	}
+	unlock, err := f.lockEviction()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = unlock() }()
+
+	artifacts, err := f.listArtifacts()
+	if err != nil {
+		return err
+	}
+	size := int64(0)
+	for _, artifact := range artifacts {
+		size += artifact.size
+	}
+	sort.Slice(artifacts, func(i, j int) bool {
+		if artifacts[i].lastUsed.Equal(artifacts[j].lastUsed) {
+			return artifacts[i].hash < artifacts[j].hash
+		}
+		return artifacts[i].lastUsed.Before(artifacts[j].lastUsed)
+	})
+	for _, artifact := range artifacts {
+		if size <= f.maxSize {
+			break
+		}
+		if err := artifact.path.Remove(); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("evicting %v from the cache: %w", artifact.hash, err)
+		}
+		if err := f.cacheDirectory.UntypedJoin(artifact.hash + "-meta.json").Remove(); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("evicting %v from the cache: %w", artifact.hash, err)
+		}
+		size -= artifact.size
+	}
+	return nil
+}
+
+// lockEviction takes the lock on the cache directory if the cache evicts artifacts,
+// and returns how to release it. Besides evicting, it is held while an artifact is
+// moved into place with its metadata, and while one is opened to be restored, so
+// that it isn't evicted halfway through.
+func (f *fsCache) lockEviction() (func() error, error) {
+	if f.maxSize <= 0 {
+		return func() error { return nil }, nil
+	}
+	evictMu.Lock()
+	unlock, err := lockCacheDir(f.cacheDirectory)
+	if err != nil {
+		evictMu.Unlock()
+		return nil, err
+	}
+	return func() error {
+		defer evictMu.Unlock()
+		return unlock()
+	}, nil
+}
+
+// listArtifacts returns the complete artifacts in the cache directory. The size of
+// each includes its metadata file.
+func (f *fsCache) listArtifacts() ([]cachedArtifact, error) {
+	entries, err := os.ReadDir(f.cacheDirectory.ToString())
+	if err != nil {
+		return nil, err
+	}
+	artifacts := []cachedArtifact{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		for _, ext := range artifactExtensions {
+			hash := strings.TrimSuffix(entry.Name(), ext)
+			if hash == entry.Name() {
+				continue
+			}
+			info, err := entry.Info()
+			if os.IsNotExist(err) {
+				break
+			} else if err != nil {
+				return nil, err
+			}
+			size := info.Size()
+			if metaInfo, err := f.cacheDirectory.UntypedJoin(hash + "-meta.json").Lstat(); err == nil {
+				size += metaInfo.Size()
+			} else if !os.IsNotExist(err) {
+				return nil, err
+			}
+			artifacts = append(artifacts, cachedArtifact{
+				hash:     hash,
+				path:     f.cacheDirectory.UntypedJoin(entry.Name()),
+				size:     size,
+				lastUsed: info.ModTime(),
+			})
+			break
+		}
+	}
+	return artifacts, nil
+}
+
+// lockCacheDir takes the lock on the cache directory, waiting up to
+// cacheLockTimeout for another run to release it, and returns how to release it
+func lockCacheDir(cacheDir turbopath.AbsoluteSystemPath) (func() error, error) {
+	lock, err := lockfile.New(cacheDir.UntypedJoin(".turbo-cache.lock").ToString())
+	if err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(cacheLockTimeout)
+	for {
+		err := lock.TryLock()
+		if err == nil {
+			return lock.Unlock, nil
+		}
+		var temporary interface{ Temporary() bool }
+		if !errors.As(err, &temporary) || !temporary.Temporary() || time.Now().After(deadline) {
+			return nil, fmt.Errorf("failed to lock the cache directory %v: %w", cacheDir, err)
+		}
+		time.Sleep(cacheLockRetryInterval)
+	}
+}
@@ -0,0 +1,120 @@
+package cache
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vercel/turbo/cli/internal/cacheitem"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"gotest.tools/v3/assert"
+)
+
+// setupEvictionTest returns a package with a file to cache, and an uncompressed
+// cache that is unlimited until its maxSize is set
+func setupEvictionTest(t *testing.T) (turbopath.AbsoluteSystemPath, []turbopath.AnchoredSystemPath, *fsCache) {
+	srcDir := turbopath.AbsoluteSystemPath(t.TempDir())
+	filePath := srcDir.UntypedJoin("some-package", "file")
+	assert.NilError(t, filePath.EnsureDir(), "EnsureDir")
+	assert.NilError(t, filePath.WriteFile([]byte("contents"), 0644), "WriteFile")
+	files := []turbopath.AnchoredSystemPath{
+		turbopath.AnchoredUnixPath("some-package/file").ToSystemPath(),
+	}
+	cache := &fsCache{
+		cacheDirectory: turbopath.AbsoluteSystemPath(t.TempDir()),
+		recorder:       &dummyRecorder{},
+		compression:    cacheitem.Compression{Algorithm: cacheitem.CompressionNone},
+	}
+	return srcDir, files, cache
+}
+
+func TestEvictLeastRecentlyUsed(t *testing.T) {
+	srcDir, files, cache := setupEvictionTest(t)
+	for i, hash := range []string{"hash-a", "hash-b", "hash-c"} {
+		assert.NilError(t, cache.Put(srcDir, hash, 0, files), "Put")
+		// The earlier an artifact is written, the longer ago it was used
+		usedAt := time.Now().Add(time.Duration(i-3) * time.Hour)
+		assert.NilError(t, os.Chtimes(cache.cacheDirectory.UntypedJoin(hash+".tar").ToString(), usedAt, usedAt), "Chtimes")
+	}
+	info, err := os.Stat(cache.cacheDirectory.UntypedJoin("hash-a.tar").ToString())
+	assert.NilError(t, err, "Stat")
+	artifactSize := info.Size()
+
+	// Restoring the oldest artifact makes it the most recently used
+	status, _, _, err := cache.Fetch(turbopath.AbsoluteSystemPath(t.TempDir()), "hash-a", nil)
+	assert.NilError(t, err, "Fetch")
+	assert.Equal(t, status, ItemStatus{Local: true})
+
+	// With room for two and a half artifacts, writing a fourth evicts the two
+	// least recently used
+	cache.maxSize = artifactSize*2 + artifactSize/2
+	assert.NilError(t, cache.Put(srcDir, "hash-d", 0, files), "Put")
+	for hash, want := range map[string]bool{"hash-a": true, "hash-b": false, "hash-c": false, "hash-d": true} {
+		status, err := cache.Exists(hash)
+		assert.NilError(t, err, "Exists")
+		assert.Equal(t, status.Local, want, "artifact %v", hash)
+		assert.Equal(t, cache.cacheDirectory.UntypedJoin(hash+"-meta.json").FileExists(), want, "metadata of %v", hash)
+	}
+}
+
+func TestEvictCountsMetadata(t *testing.T) {
+	srcDir, files, cache := setupEvictionTest(t)
+	assert.NilError(t, cache.Put(srcDir, "hash-a", 0, files), "Put")
+	info, err := os.Stat(cache.cacheDirectory.UntypedJoin("hash-a.tar").ToString())
+	assert.NilError(t, err, "Stat")
+	artifacts, err := cache.listArtifacts()
+	assert.NilError(t, err, "listArtifacts")
+	assert.Equal(t, len(artifacts), 1)
+	assert.Assert(t, artifacts[0].size > info.Size(), "size %v doesn't include the metadata", artifacts[0].size)
+
+	// There is room for two artifacts, but not for their metadata too
+	cache.maxSize = info.Size() * 2
+	assert.NilError(t, cache.Put(srcDir, "hash-b", 0, files), "Put")
+	artifacts, err = cache.listArtifacts()
+	assert.NilError(t, err, "listArtifacts")
+	assert.Equal(t, len(artifacts), 1)
+}
+
+func TestEvictConcurrently(t *testing.T) {
+	srcDir, files, cache := setupEvictionTest(t)
+	assert.NilError(t, cache.Put(srcDir, "first", 0, files), "Put")
+	info, err := os.Stat(cache.cacheDirectory.UntypedJoin("first.tar").ToString())
+	assert.NilError(t, err, "Stat")
+
+	// Caches of separate runs share the directory
+	maxSize := info.Size() * 3
+	var wg sync.WaitGroup
+	errs := make(chan error, 40)
+	for i := 0; i < 20; i++ {
+		run := &fsCache{
+			cacheDirectory: cache.cacheDirectory,
+			recorder:       &dummyRecorder{},
+			compression:    cache.compression,
+			maxSize:        maxSize,
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- run.Put(srcDir, fmt.Sprintf("hash-%v", i), 0, files)
+		}(i)
+		// Restoring an artifact while others are written either hits, or misses
+		// cleanly once it is evicted
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, _, err := run.Fetch(turbopath.AbsoluteSystemPath(t.TempDir()), "first", nil)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		assert.NilError(t, err)
+	}
+
+	artifacts, err := cache.listArtifacts()
+	assert.NilError(t, err, "listArtifacts")
+	assert.Assert(t, len(artifacts) <= 3, "expected at most 3 artifacts, found %v", len(artifacts))
+}
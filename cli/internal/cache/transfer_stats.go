@@ -0,0 +1,88 @@
+package cache
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// TransferStats records the number of bytes uploaded to and downloaded from the
+// remote cache for each hash, and how long downloads took. It is safe for
+// concurrent use, and a nil *TransferStats records nothing.
+type TransferStats struct {
+	mu           sync.Mutex
+	uploaded     map[string]int64
+	downloaded   map[string]int64
+	downloadTime map[string]time.Duration
+}
+
+// NewTransferStats creates an empty TransferStats
+func NewTransferStats() *TransferStats {
+	return &TransferStats{
+		uploaded:     make(map[string]int64),
+		downloaded:   make(map[string]int64),
+		downloadTime: make(map[string]time.Duration),
+	}
+}
+
+func (s *TransferStats) recordUpload(hash string, bytes int64) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.uploaded[hash] += bytes
+}
+
+func (s *TransferStats) recordDownload(hash string, bytes int64, elapsed time.Duration) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.downloaded[hash] += bytes
+	s.downloadTime[hash] += elapsed
+}
+
+// Uploaded returns the number of bytes uploaded to the remote cache for the given hash
+func (s *TransferStats) Uploaded(hash string) int64 {
+	if s == nil {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.uploaded[hash]
+}
+
+// Downloaded returns the number of bytes downloaded from the remote cache for the given hash
+func (s *TransferStats) Downloaded(hash string) int64 {
+	if s == nil {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.downloaded[hash]
+}
+
+// DownloadTime returns how long it took to download the artifact for the given
+// hash from the remote cache, including the time to restore its files
+func (s *TransferStats) DownloadTime(hash string) time.Duration {
+	if s == nil {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.downloadTime[hash]
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -29,11 +29,11 @@ type CacheItem struct {
 	Anchor turbopath.AbsoluteSystemPath
 
 	// For creation.
-	tw         *tar.Writer
-	zw         io.WriteCloser
-	fileBuffer *bufio.Writer
-	handle     *os.File
-	compressed bool
+	tw          *tar.Writer
+	zw          io.WriteCloser
+	fileBuffer  *bufio.Writer
+	handle      *os.File
+	compression Compression
 }
 
 // Close any open pipes
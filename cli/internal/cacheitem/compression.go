@@ -0,0 +1,131 @@
+package cacheitem
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/DataDog/zstd"
+)
+
+const (
+	// CompressionNone writes a plain tar
+	CompressionNone = "none"
+	// CompressionGzip compresses the tar with gzip
+	CompressionGzip = "gzip"
+	// CompressionZstd compresses the tar with zstd
+	CompressionZstd = "zstd"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// Compression is how the tar of a CacheItem is compressed. The zero value is
+// zstd at its default level, which artifacts have always been compressed with.
+type Compression struct {
+	// Algorithm is one of CompressionNone, CompressionGzip or CompressionZstd, or
+	// empty for CompressionZstd
+	Algorithm string
+	// Level is the compression level of the algorithm, or 0 for its default
+	Level int
+}
+
+// Validate returns an error if the algorithm is unknown, or the level is out of
+// its range
+func (c Compression) Validate() error {
+	switch c.Algorithm {
+	case CompressionNone:
+		if c.Level != 0 {
+			return fmt.Errorf("a compression level can't be set without compression")
+		}
+	case CompressionGzip:
+		if c.Level != 0 && (c.Level < gzip.BestSpeed || c.Level > gzip.BestCompression) {
+			return fmt.Errorf("invalid gzip compression level %v: expected %v to %v", c.Level, gzip.BestSpeed, gzip.BestCompression)
+		}
+	case "", CompressionZstd:
+		if c.Level != 0 && (c.Level < zstd.BestSpeed || c.Level > zstd.BestCompression) {
+			return fmt.Errorf("invalid zstd compression level %v: expected %v to %v", c.Level, zstd.BestSpeed, zstd.BestCompression)
+		}
+	default:
+		return fmt.Errorf("invalid compression %q: expected %v, %v or %v", c.Algorithm, CompressionNone, CompressionGzip, CompressionZstd)
+	}
+	return nil
+}
+
+// Extension returns the file extension of a CacheItem with this compression
+func (c Compression) Extension() string {
+	switch c.Algorithm {
+	case CompressionGzip:
+		return ".tar.gz"
+	case CompressionNone:
+		return ".tar"
+	default:
+		return ".tar.zst"
+	}
+}
+
+// compressionForPath returns the compression, at its default level, that the
+// extension of the path names
+func compressionForPath(path string) Compression {
+	switch {
+	case strings.HasSuffix(path, ".zst"):
+		return Compression{Algorithm: CompressionZstd}
+	case strings.HasSuffix(path, ".gz"):
+		return Compression{Algorithm: CompressionGzip}
+	default:
+		return Compression{Algorithm: CompressionNone}
+	}
+}
+
+// NewWriter returns a writer that compresses into w, or nil if there is no
+// compression. Closing it flushes the compressed data, but doesn't close w.
+func (c Compression) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	switch c.Algorithm {
+	case CompressionGzip:
+		level := c.Level
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		return gzip.NewWriterLevel(w, level)
+	case CompressionNone:
+		return nil, nil
+	default:
+		if c.Level == 0 {
+			return zstd.NewWriter(w), nil
+		}
+		return zstd.NewWriterLevel(w, c.Level), nil
+	}
+}
+
+// nopReadCloser is an uncompressed reader that has nothing to close
+type nopReadCloser struct {
+	io.Reader
+}
+
+func (nopReadCloser) Close() error { return nil }
+
+// Decompress returns a reader of the tar in r, detecting how it is compressed
+// from its first bytes rather than from how it was named.
+func Decompress(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	// Peek errors for tars shorter than the magic number, which can't be
+	// compressed, so they are read as they are
+	magic, _ := br.Peek(len(zstdMagic))
+	switch {
+	case bytes.HasPrefix(magic, zstdMagic):
+		// The `Close` function for compression effectively just returns the singular
+		// error field on the decompressor instance. This is extremely unlikely to be
+		// set without triggering one of the numerous other errors, but we should still
+		// handle that possible edge case.
+		return zstd.NewReader(br), nil
+	case bytes.HasPrefix(magic, gzipMagic):
+		return gzip.NewReader(br)
+	default:
+		return nopReadCloser{br}, nil
+	}
+}
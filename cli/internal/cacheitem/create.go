@@ -5,42 +5,52 @@ import (
 	"bufio"
 	"io"
 	"os"
-	"strings"
 	"time"
 
-	"github.com/DataDog/zstd"
-
 	"github.com/moby/sys/sequential"
 	"github.com/vercel/turbo/cli/internal/tarpatch"
 	"github.com/vercel/turbo/cli/internal/turbopath"
 )
 
-// Create makes a new CacheItem at the specified path.
+// Create makes a new CacheItem at the specified path, compressed as its
+// extension names.
 func Create(path turbopath.AbsoluteSystemPath) (*CacheItem, error) {
+	return CreateCompressed(path, compressionForPath(path.ToString()))
+}
+
+// CreateCompressed makes a new CacheItem at the specified path, with the given
+// compression regardless of the path's extension.
+func CreateCompressed(path turbopath.AbsoluteSystemPath, compression Compression) (*CacheItem, error) {
 	handle, err := path.OpenFile(os.O_WRONLY|os.O_CREATE|os.O_TRUNC|os.O_APPEND, 0644)
 	if err != nil {
 		return nil, err
 	}
 
 	cacheItem := &CacheItem{
-		Path:       path,
-		handle:     handle,
-		compressed: strings.HasSuffix(path.ToString(), ".zst"),
+		Path:        path,
+		handle:      handle,
+		compression: compression,
 	}
 
-	cacheItem.init()
+	if err := cacheItem.init(); err != nil {
+		_ = handle.Close()
+		return nil, err
+	}
 	return cacheItem, nil
 }
 
 // init prepares the CacheItem for writing.
 // Wires all the writers end-to-end:
-// tar.Writer -> zstd.Writer -> fileBuffer -> file
-func (ci *CacheItem) init() {
+// tar.Writer -> compression Writer -> fileBuffer -> file
+func (ci *CacheItem) init() error {
 	fileBuffer := bufio.NewWriterSize(ci.handle, 2^20) // Flush to disk in 1mb chunks.
 
+	zw, err := ci.compression.NewWriter(fileBuffer)
+	if err != nil {
+		return err
+	}
 	var tw *tar.Writer
-	if ci.compressed {
-		zw := zstd.NewWriter(fileBuffer)
+	if zw != nil {
 		tw = tar.NewWriter(zw)
 		ci.zw = zw
 	} else {
@@ -49,6 +59,7 @@ func (ci *CacheItem) init() {
 
 	ci.tw = tw
 	ci.fileBuffer = fileBuffer
+	return nil
 }
 
 // AddFile adds a user-cached item to the tar.
@@ -8,8 +8,6 @@ import (
 	"runtime"
 	"strings"
 
-	"github.com/DataDog/zstd"
-
 	"github.com/moby/sys/sequential"
 	"github.com/vercel/turbo/cli/internal/turbopath"
 )
@@ -22,30 +20,22 @@ func Open(path turbopath.AbsoluteSystemPath) (*CacheItem, error) {
 	}
 
 	return &CacheItem{
-		Path:       path,
-		handle:     handle,
-		compressed: strings.HasSuffix(path.ToString(), ".zst"),
+		Path:   path,
+		handle: handle,
 	}, nil
 }
 
 // Restore extracts a cache to a specified disk location.
 func (ci *CacheItem) Restore(anchor turbopath.AbsoluteSystemPath) ([]turbopath.AnchoredSystemPath, error) {
-	var tr *tar.Reader
 	var closeError error
 
-	// We're reading a tar, possibly wrapped in zstd.
-	if ci.compressed {
-		zr := zstd.NewReader(ci.handle)
-
-		// The `Close` function for compression effectively just returns the singular
-		// error field on the decompressor instance. This is extremely unlikely to be
-		// set without triggering one of the numerous other errors, but we should still
-		// handle that possible edge case.
-		defer func() { closeError = zr.Close() }()
-		tr = tar.NewReader(zr)
-	} else {
-		tr = tar.NewReader(ci.handle)
+	// We're reading a tar, possibly compressed with whatever it was created with.
+	zr, err := Decompress(ci.handle)
+	if err != nil {
+		return nil, err
 	}
+	defer func() { closeError = zr.Close() }()
+	tr := tar.NewReader(zr)
 
 	// On first attempt to restore it's possible that a link target doesn't exist.
 	// Save them and topsort them.
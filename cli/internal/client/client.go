@@ -2,7 +2,9 @@ package client
 
 import (
 	"context"
+	"crypto/sha256"
 	"crypto/x509"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -35,6 +37,8 @@ type ApiClient struct {
 	teamSlug   string
 	// Whether or not to send preflight requests before uploads
 	usePreflight bool
+	// Additional headers to send with every request to the artifacts API
+	headers map[string]string
 }
 
 // ErrTooManyFailures is returned from remote cache API methods after `maxRemoteFailCount` errors have occurred
@@ -60,11 +64,33 @@ type RemoteConfig struct {
 // Opts holds values for configuring the behavior of the API client
 type Opts struct {
 	UsePreflight bool
+	// Headers are sent with every request to the artifacts API, alongside
+	// the headers the client sets itself
+	Headers map[string]string
 }
 
 // AddFlags adds flags specific to the api client to the given flagset
 func AddFlags(opts *Opts, flags *pflag.FlagSet) {
 	flags.BoolVar(&opts.UsePreflight, "preflight", false, "When enabled, turbo will precede HTTP requests with an OPTIONS request for authorization")
+	flags.StringToStringVar(&opts.Headers, "remote-header", nil, "An additional header, as key=value, to send with every remote cache request. Can be repeated")
+}
+
+// ParseHeaders parses a comma-separated list of key=value pairs, as found in
+// the TURBO_REMOTE_HEADERS environment variable
+func ParseHeaders(value string) (map[string]string, error) {
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, headerValue, ok := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid header %q, expected key=value", pair)
+		}
+		headers[key] = strings.TrimSpace(headerValue)
+	}
+	return headers, nil
 }
 
 // New creates a new ApiClient
@@ -86,6 +112,7 @@ func NewClient(remoteConfig RemoteConfig, logger hclog.Logger, turboVersion stri
 		teamID:       remoteConfig.TeamID,
 		teamSlug:     remoteConfig.TeamSlug,
 		usePreflight: opts.UsePreflight,
+		headers:      opts.Headers,
 	}
 	client.HttpClient.CheckRetry = client.checkRetry
 	return client
@@ -179,6 +206,18 @@ func (c *ApiClient) UserAgent() string {
 	return fmt.Sprintf("turbo %v %v %v (%v)", c.turboVersion, runtime.Version(), runtime.GOOS, runtime.GOARCH)
 }
 
+// addCustomHeaders adds the configured headers to the given request. Headers
+// the client has already set, and Authorization, are never replaced, so custom
+// headers can't change how the request is authenticated.
+func (c *ApiClient) addCustomHeaders(req *retryablehttp.Request) {
+	for key, value := range c.headers {
+		if http.CanonicalHeaderKey(key) == "Authorization" || req.Header.Get(key) != "" {
+			continue
+		}
+		req.Header.Set(key, value)
+	}
+}
+
 // doPreflight returns response with closed body, latest request url, and any errors to the caller
 func (c *ApiClient) doPreflight(requestURL string, requestMethod string, requestHeaders string) (*http.Response, string, error) {
 	req, err := retryablehttp.NewRequest(http.MethodOptions, requestURL, nil)
@@ -189,6 +228,7 @@ func (c *ApiClient) doPreflight(requestURL string, requestMethod string, request
 	if err != nil {
 		return nil, requestURL, fmt.Errorf("[WARNING] Invalid cache URL: %w", err)
 	}
+	c.addCustomHeaders(req)
 
 	// If resp is not nil, ignore any errors
 	//  because most likely unimportant for preflight to handle.
@@ -265,7 +305,7 @@ func (c *ApiClient) PutArtifact(hash string, artifactBody []byte, duration int,
 	requestURL := c.makeUrl("/v8/artifacts/" + hash + encoded)
 	allowAuth := true
 	if c.usePreflight {
-		resp, latestRequestURL, err := c.doPreflight(requestURL, http.MethodPut, "Content-Type, x-artifact-duration, Authorization, User-Agent, x-artifact-tag")
+		resp, latestRequestURL, err := c.doPreflight(requestURL, http.MethodPut, "Content-Type, x-artifact-duration, x-artifact-checksum, Authorization, User-Agent, x-artifact-tag")
 		if err != nil {
 			return fmt.Errorf("pre-flight request failed before trying to store in HTTP cache: %w", err)
 		}
@@ -277,6 +317,9 @@ func (c *ApiClient) PutArtifact(hash string, artifactBody []byte, duration int,
 	req, err := retryablehttp.NewRequest(http.MethodPut, requestURL, artifactBody)
 	req.Header.Set("Content-Type", "application/octet-stream")
 	req.Header.Set("x-artifact-duration", fmt.Sprintf("%v", duration))
+	// The checksum lets downloads detect an artifact corrupted in storage or in transit
+	checksum := sha256.Sum256(artifactBody)
+	req.Header.Set("x-artifact-checksum", hex.EncodeToString(checksum[:]))
 	if allowAuth {
 		req.Header.Set("Authorization", "Bearer "+c.token)
 	}
@@ -287,6 +330,7 @@ func (c *ApiClient) PutArtifact(hash string, artifactBody []byte, duration int,
 	if err != nil {
 		return fmt.Errorf("[WARNING] Invalid cache URL: %w", err)
 	}
+	c.addCustomHeaders(req)
 
 	resp, err := c.HttpClient.Do(req)
 	if err != nil {
@@ -350,6 +394,7 @@ func (c *ApiClient) getArtifact(hash string, httpMethod string) (*http.Response,
 	if err != nil {
 		return nil, fmt.Errorf("invalid cache URL: %w", err)
 	}
+	c.addCustomHeaders(req)
 
 	resp, err := c.HttpClient.Do(req)
 	if err != nil {
@@ -398,6 +443,7 @@ func (c *ApiClient) RecordAnalyticsEvents(events []map[string]interface{}) error
 		req.Header.Set("Authorization", "Bearer "+c.token)
 	}
 	req.Header.Set("User-Agent", c.UserAgent())
+	c.addCustomHeaders(req)
 	resp, err := c.HttpClient.Do(req)
 	if resp != nil && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
 		b, _ := ioutil.ReadAll(resp.Body)
@@ -157,3 +157,75 @@ func Test_FetchWhenCachingDisabled(t *testing.T) {
 		t.Errorf("response got %v, want <nil>", resp)
 	}
 }
+
+func Test_CustomHeaders(t *testing.T) {
+	type received struct {
+		method        string
+		proxyAuth     string
+		authorization string
+	}
+	ch := make(chan received, 3)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		defer func() { _ = req.Body.Close() }()
+		ch <- received{
+			method:        req.Method,
+			proxyAuth:     req.Header.Get("X-Proxy-Auth"),
+			authorization: req.Header.Get("Authorization"),
+		}
+		w.WriteHeader(200)
+	}))
+	defer ts.Close()
+
+	remoteConfig := RemoteConfig{
+		TeamSlug: "my-team-slug",
+		APIURL:   ts.URL,
+		Token:    "my-token",
+	}
+	apiClient := NewClient(remoteConfig, hclog.Default(), "v1", Opts{
+		Headers: map[string]string{
+			"X-Proxy-Auth":  "proxy-secret",
+			"Authorization": "Bearer not-my-token",
+		},
+	})
+
+	if err := apiClient.PutArtifact("hash", []byte("My string artifact"), 500, ""); err != nil {
+		t.Fatalf("PutArtifact: %v", err)
+	}
+	resp, err := apiClient.FetchArtifact("hash")
+	if err != nil {
+		t.Fatalf("FetchArtifact: %v", err)
+	}
+	_ = resp.Body.Close()
+	resp, err = apiClient.ArtifactExists("hash")
+	if err != nil {
+		t.Fatalf("ArtifactExists: %v", err)
+	}
+	_ = resp.Body.Close()
+
+	for _, method := range []string{http.MethodPut, http.MethodGet, http.MethodHead} {
+		got := <-ch
+		if got.method != method {
+			t.Errorf("method got %v, want %v", got.method, method)
+		}
+		if got.proxyAuth != "proxy-secret" {
+			t.Errorf("%v X-Proxy-Auth got %q, want %q", method, got.proxyAuth, "proxy-secret")
+		}
+		if got.authorization != "Bearer my-token" {
+			t.Errorf("%v Authorization got %q, want %q", method, got.authorization, "Bearer my-token")
+		}
+	}
+}
+
+func Test_ParseHeaders(t *testing.T) {
+	headers, err := ParseHeaders("X-Proxy-Auth=a=b, X-Region=us-east-1,")
+	if err != nil {
+		t.Fatalf("ParseHeaders: %v", err)
+	}
+	expected := map[string]string{"X-Proxy-Auth": "a=b", "X-Region": "us-east-1"}
+	if !reflect.DeepEqual(headers, expected) {
+		t.Errorf("ParseHeaders got %v, want %v", headers, expected)
+	}
+	if _, err := ParseHeaders("X-Proxy-Auth"); err == nil {
+		t.Error("expected an error for a header without a value")
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/vercel/turbo/cli/internal/cmdutil"
 	"github.com/vercel/turbo/cli/internal/daemon"
 	"github.com/vercel/turbo/cli/internal/login"
+	"github.com/vercel/turbo/cli/internal/ls"
 	"github.com/vercel/turbo/cli/internal/process"
 	"github.com/vercel/turbo/cli/internal/prune"
 	"github.com/vercel/turbo/cli/internal/run"
@@ -140,7 +141,8 @@ func getCmd(helper *cmdutil.Helper, signalWatcher *signals.Watcher) *cobra.Comma
 	cmd.AddCommand(auth.LogoutCmd(helper))
 	cmd.AddCommand(auth.UnlinkCmd(helper))
 	cmd.AddCommand(info.BinCmd(helper))
-	cmd.AddCommand(daemon.GetCmd(helper, signalWatcher))
+	cmd.AddCommand(daemon.GetCmd(helper, signalWatcher, run.TaskGraph))
+	cmd.AddCommand(ls.GetCmd(helper))
 	cmd.AddCommand(prune.GetCmd(helper))
 	cmd.AddCommand(run.GetCmd(helper, signalWatcher))
 	return cmd
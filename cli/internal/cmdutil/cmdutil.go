@@ -23,6 +23,8 @@ import (
 const (
 	// _envLogLevel is the environment log level
 	_envLogLevel = "TURBO_LOG_LEVEL"
+	// _envRemoteHeaders holds additional headers for remote cache requests
+	_envRemoteHeaders = "TURBO_REMOTE_HEADERS"
 )
 
 // Helper is a struct used to hold configuration values passed via flag, env vars,
@@ -123,6 +125,27 @@ func (h *Helper) getLogger() (hclog.Logger, error) {
 	}), nil
 }
 
+// getClientOpts returns the api client options, with the remote cache headers from
+// the environment merged in. Headers passed via --remote-header take precedence.
+func (h *Helper) getClientOpts() (client.Opts, error) {
+	opts := h.clientOpts
+	if v := os.Getenv(_envRemoteHeaders); v != "" {
+		envHeaders, err := client.ParseHeaders(v)
+		if err != nil {
+			return opts, fmt.Errorf("%s: %w", _envRemoteHeaders, err)
+		}
+		headers := make(map[string]string, len(envHeaders)+len(opts.Headers))
+		for key, value := range envHeaders {
+			headers[key] = value
+		}
+		for key, value := range opts.Headers {
+			headers[key] = value
+		}
+		opts.Headers = headers
+	}
+	return opts, nil
+}
+
 // AddFlags adds common flags for all turbo commands to the given flagset and binds
 // them to this instance of Helper
 func (h *Helper) AddFlags(flags *pflag.FlagSet) {
@@ -184,11 +207,15 @@ func (h *Helper) GetCmdBase(flags *pflag.FlagSet) (*CmdBase, error) {
 			remoteConfig.TeamID = vercelArtifactsOwner
 		}
 	}
+	clientOpts, err := h.getClientOpts()
+	if err != nil {
+		return nil, err
+	}
 	apiClient := client.NewClient(
 		remoteConfig,
 		logger,
 		h.TurboVersion,
-		h.clientOpts,
+		clientOpts,
 	)
 
 	return &CmdBase{
@@ -39,3 +39,22 @@ func TestTokenEnvVar(t *testing.T) {
 		})
 	}
 }
+
+func TestRemoteHeadersEnvVar(t *testing.T) {
+	t.Setenv("TURBO_REMOTE_HEADERS", "X-Proxy-Auth=from-env, X-Region=us-east-1")
+	flags := pflag.NewFlagSet("test-flags", pflag.ContinueOnError)
+	h := NewHelper("test-version")
+	h.AddFlags(flags)
+	assert.NilError(t, flags.Parse([]string{"--remote-header", "X-Proxy-Auth=from-flag"}))
+
+	opts, err := h.getClientOpts()
+	assert.NilError(t, err)
+	assert.DeepEqual(t, opts.Headers, map[string]string{
+		"X-Proxy-Auth": "from-flag",
+		"X-Region":     "us-east-1",
+	})
+
+	t.Setenv("TURBO_REMOTE_HEADERS", "X-Proxy-Auth")
+	_, err = h.getClientOpts()
+	assert.ErrorContains(t, err, "TURBO_REMOTE_HEADERS")
+}
@@ -44,6 +44,18 @@ func (c *ColorCache) colorForKey(key string) colorFn {
 	return colorFn
 }
 
+// PaletteSize returns the number of colors task output is prefixed with
+func PaletteSize() int {
+	return len(getTerminalPackageColors())
+}
+
+// PrefixWithColorIndex returns a string consisting of the provided prefix in the
+// color at the given index of the palette
+func (c *ColorCache) PrefixWithColorIndex(index int, prefix string) string {
+	colorFn := c.TermColors[util.PositiveMod(index, len(c.TermColors))]
+	return colorFn("%s: ", prefix)
+}
+
 // PrefixWithColor returns a string consisting of the provided prefix in a consistent
 // color based on the cacheKey
 func (c *ColorCache) PrefixWithColor(cacheKey string, prefix string) string {
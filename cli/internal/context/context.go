@@ -268,6 +268,7 @@ func (c *Context) populateTopologicGraphForPackageJSON(pkg *fs.PackageJSON, root
 	externalUnresolvedDepsSet := make(dag.Set)
 	externalDepSet := mapset.NewSet()
 	pkg.UnresolvedExternalDeps = make(map[string]string)
+	pkg.InternalDepProtocols = make(map[string]string)
 
 	for dep, version := range pkg.DevDependencies {
 		depMap[dep] = version
@@ -285,6 +286,9 @@ func (c *Context) populateTopologicGraphForPackageJSON(pkg *fs.PackageJSON, root
 	for depName, depVersion := range depMap {
 		if item, ok := c.PackageInfos[depName]; ok && isWorkspaceReference(item.Version, depVersion, pkg.Dir.ToStringDuringMigration(), rootpath) {
 			internalDepsSet.Add(depName)
+			// Record the protocol, if any, so runs can be restricted to a single one
+			protocol, _ := parseDependencyProtocol(depVersion)
+			pkg.InternalDepProtocols[depName] = protocol
 			c.TopologicalGraph.Connect(dag.BasicEdge(vertexName, depName))
 		} else {
 			externalUnresolvedDepsSet.Add(depName)
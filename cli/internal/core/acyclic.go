@@ -0,0 +1,81 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/vercel/turbo/cli/internal/util"
+
+	"github.com/pyr-sh/dag"
+)
+
+// CheckAcyclic validates that a dependency map, from each task to the tasks it
+// depends on, has no cycles, without building an Engine. It is a cheap check
+// for tooling to run on a proposed configuration before Prepare. If there is a
+// cycle, the returned error includes its path, e.g. `a -> b -> a`.
+func CheckAcyclic(deps map[string][]string) error {
+	graph := &dag.AcyclicGraph{}
+	names := make([]string, 0, len(deps))
+	for name := range deps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		graph.Add(name)
+		for _, dep := range deps[name] {
+			if dep == name {
+				return fmt.Errorf("Invalid task dependency graph, found a cycle: %v -> %v", name, name)
+			}
+			graph.Add(dep)
+			graph.Connect(dag.BasicEdge(name, dep))
+		}
+	}
+
+	cycles := graph.Cycles()
+	if len(cycles) == 0 {
+		return nil
+	}
+	// Report the cycle that starts with the lowest task, so the error is stable
+	var cycle util.Set
+	start := ""
+	for _, vertices := range cycles {
+		members := make(util.Set)
+		for _, v := range vertices {
+			members.Add(dag.VertexName(v))
+		}
+		for _, member := range members.UnsafeListOfStrings() {
+			if cycle == nil || member < start {
+				cycle = members
+				start = member
+			}
+		}
+	}
+	return fmt.Errorf("Invalid task dependency graph, found a cycle: %v", strings.Join(cyclePath(deps, cycle, start), " -> "))
+}
+
+// cyclePath returns a path through the dependencies from start back to itself,
+// visiting only tasks in the given strongly connected set
+func cyclePath(deps map[string][]string, cycle util.Set, start string) []string {
+	visited := make(util.Set)
+	var visit func(name string, path []string) []string
+	visit = func(name string, path []string) []string {
+		path = append(path, name)
+		next := append([]string{}, deps[name]...)
+		sort.Strings(next)
+		for _, dep := range next {
+			if dep == start {
+				return append(path, start)
+			}
+			if !cycle.Includes(dep) || visited.Includes(dep) {
+				continue
+			}
+			visited.Add(dep)
+			if found := visit(dep, path); found != nil {
+				return found
+			}
+		}
+		return nil
+	}
+	return visit(start, nil)
+}
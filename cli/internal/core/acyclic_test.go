@@ -0,0 +1,33 @@
+package core
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestCheckAcyclic(t *testing.T) {
+	err := CheckAcyclic(map[string][]string{
+		"web#build":  {"ui#build"},
+		"web#test":   {"web#build", "ui#build"},
+		"ui#build":   {},
+		"docs#build": nil,
+	})
+	assert.NilError(t, err)
+}
+
+func TestCheckAcyclicCycle(t *testing.T) {
+	err := CheckAcyclic(map[string][]string{
+		"web#build":   {"ui#build"},
+		"ui#build":    {"utils#build"},
+		"utils#build": {"web#build", "config#build"},
+	})
+	assert.Error(t, err, "Invalid task dependency graph, found a cycle: ui#build -> utils#build -> web#build -> ui#build")
+}
+
+func TestCheckAcyclicSelfDependency(t *testing.T) {
+	err := CheckAcyclic(map[string][]string{
+		"web#build": {"web#build"},
+	})
+	assert.Error(t, err, "Invalid task dependency graph, found a cycle: web#build -> web#build")
+}
@@ -0,0 +1,154 @@
+package core
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/pyr-sh/dag"
+)
+
+// taskTiming is when a task's visitor started and finished during an execution
+type taskTiming struct {
+	start time.Time
+	end   time.Time
+}
+
+func (e *Engine) recordTiming(taskID string, start time.Time, end time.Time) {
+	e.timingsMu.Lock()
+	defer e.timingsMu.Unlock()
+	e.timings[taskID] = taskTiming{start: start, end: end}
+}
+
+// chromeTraceEvent is an event in the Chrome trace event format, see
+// https://docs.google.com/document/d/1CvAClvFfyA5R-PhYUmn5OOQtYMH4h6I0nSsKchNAySU
+type chromeTraceEvent struct {
+	Name     string `json:"name"`
+	Category string `json:"cat"`
+	Phase    string `json:"ph"`
+	// Timestamp and Duration are in microseconds
+	Timestamp int64 `json:"ts"`
+	Duration  int64 `json:"dur,omitempty"`
+	PID       int   `json:"pid"`
+	TID       int   `json:"tid"`
+	// ID and BindingPoint connect the start and end of a flow event
+	ID           int    `json:"id,omitempty"`
+	BindingPoint string `json:"bp,omitempty"`
+	// Args are shown alongside a task's event, such as its cache status
+	Args map[string]string `json:"args,omitempty"`
+}
+
+// WriteChromeTrace writes the timing of each task run during the last execution
+// in the Chrome trace event format, for viewing in Perfetto or chrome://tracing.
+// Each task is a duration event on a lane (tid) that no other task overlapping
+// with it uses, so the number of lanes shows how many tasks ran at once. Each
+// dependency between two tasks that ran is a flow event from the end of the
+// dependency to the start of the dependent task. If taskArgs is set, the args it
+// returns for each task are attached to the task's event.
+func (e *Engine) WriteChromeTrace(w io.Writer, taskArgs func(taskID string) map[string]string) error {
+	e.timingsMu.Lock()
+	timings := make(map[string]taskTiming, len(e.timings))
+	for taskID, timing := range e.timings {
+		timings[taskID] = timing
+	}
+	e.timingsMu.Unlock()
+
+	taskIDs := make([]string, 0, len(timings))
+	for taskID := range timings {
+		taskIDs = append(taskIDs, taskID)
+	}
+	sort.Slice(taskIDs, func(i, j int) bool {
+		a, b := timings[taskIDs[i]], timings[taskIDs[j]]
+		if !a.start.Equal(b.start) {
+			return a.start.Before(b.start)
+		}
+		return taskIDs[i] < taskIDs[j]
+	})
+	var origin time.Time
+	if len(taskIDs) > 0 {
+		origin = timings[taskIDs[0]].start
+	}
+	micros := func(t time.Time) int64 {
+		return t.Sub(origin).Microseconds()
+	}
+
+	events := []chromeTraceEvent{}
+	// laneEnds holds when the last task on each lane finished
+	laneEnds := []time.Time{}
+	lanes := make(map[string]int, len(taskIDs))
+	for _, taskID := range taskIDs {
+		timing := timings[taskID]
+		lane := -1
+		for i, end := range laneEnds {
+			if !end.After(timing.start) {
+				lane = i
+				break
+			}
+		}
+		if lane == -1 {
+			lane = len(laneEnds)
+			laneEnds = append(laneEnds, timing.end)
+		} else {
+			laneEnds[lane] = timing.end
+		}
+		lanes[taskID] = lane
+		event := chromeTraceEvent{
+			Name:      taskID,
+			Category:  "task",
+			Phase:     "X",
+			Timestamp: micros(timing.start),
+			Duration:  timing.end.Sub(timing.start).Microseconds(),
+			PID:       1,
+			TID:       lane,
+		}
+		if taskArgs != nil {
+			event.Args = taskArgs(taskID)
+		}
+		events = append(events, event)
+	}
+
+	flowID := 0
+	for _, taskID := range taskIDs {
+		deps := []string{}
+		for _, dep := range e.TaskGraph.DownEdges(taskID) {
+			depTaskID := dag.VertexName(dep)
+			if _, ok := timings[depTaskID]; ok {
+				deps = append(deps, depTaskID)
+			}
+		}
+		sort.Strings(deps)
+		for _, depTaskID := range deps {
+			flowID++
+			events = append(events, chromeTraceEvent{
+				Name:      "dependency",
+				Category:  "dependency",
+				Phase:     "s",
+				Timestamp: micros(timings[depTaskID].end),
+				PID:       1,
+				TID:       lanes[depTaskID],
+				ID:        flowID,
+			}, chromeTraceEvent{
+				Name:         "dependency",
+				Category:     "dependency",
+				Phase:        "f",
+				Timestamp:    micros(timings[taskID].start),
+				PID:          1,
+				TID:          lanes[taskID],
+				ID:           flowID,
+				BindingPoint: "e",
+			})
+		}
+	}
+
+	trace := struct {
+		TraceEvents     []chromeTraceEvent `json:"traceEvents"`
+		DisplayTimeUnit string             `json:"displayTimeUnit"`
+	}{
+		TraceEvents:     events,
+		DisplayTimeUnit: "ms",
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(trace)
+}
@@ -0,0 +1,70 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/vercel/turbo/cli/internal/util"
+	"gotest.tools/v3/assert"
+
+	"github.com/pyr-sh/dag"
+)
+
+func TestWriteChromeTrace(t *testing.T) {
+	graph := &dag.AcyclicGraph{}
+	graph.Add("app")
+	graph.Add("lib")
+	graph.Add("docs")
+	graph.Add(ROOT_NODE_NAME)
+	graph.Connect(dag.BasicEdge("app", "lib"))
+	graph.Connect(dag.BasicEdge("lib", ROOT_NODE_NAME))
+	graph.Connect(dag.BasicEdge("docs", ROOT_NODE_NAME))
+
+	p := NewEngine(graph)
+	topoDeps := make(util.Set)
+	topoDeps.Add("build")
+	p.AddTask(&Task{Name: "build", TopoDeps: topoDeps, Deps: make(util.Set)})
+	err := p.Prepare(&EngineBuildingOptions{
+		Packages:  []string{"app", "lib", "docs"},
+		TaskNames: []string{"build"},
+	})
+	assert.NilError(t, err, "Prepare")
+
+	// lib and docs build at the same time, then app builds once lib is done
+	origin := time.Now()
+	p.recordTiming("lib#build", origin, origin.Add(2*time.Second))
+	p.recordTiming("docs#build", origin.Add(time.Second), origin.Add(3*time.Second))
+	p.recordTiming("app#build", origin.Add(2*time.Second), origin.Add(4*time.Second))
+
+	var buf bytes.Buffer
+	cacheStatus := map[string]string{"lib#build": "HitLocal", "app#build": "Miss"}
+	assert.NilError(t, p.WriteChromeTrace(&buf, func(taskID string) map[string]string {
+		if status, ok := cacheStatus[taskID]; ok {
+			return map[string]string{"cache": status}
+		}
+		return nil
+	}))
+	trace := struct {
+		TraceEvents []chromeTraceEvent `json:"traceEvents"`
+	}{}
+	assert.NilError(t, json.Unmarshal(buf.Bytes(), &trace))
+
+	assert.DeepEqual(t, trace.TraceEvents, []chromeTraceEvent{
+		{Name: "lib#build", Category: "task", Phase: "X", Timestamp: 0, Duration: 2000000, PID: 1, TID: 0, Args: map[string]string{"cache": "HitLocal"}},
+		{Name: "docs#build", Category: "task", Phase: "X", Timestamp: 1000000, Duration: 2000000, PID: 1, TID: 1},
+		// app reuses lib's lane, which is free once lib finishes
+		{Name: "app#build", Category: "task", Phase: "X", Timestamp: 2000000, Duration: 2000000, PID: 1, TID: 0, Args: map[string]string{"cache": "Miss"}},
+		{Name: "dependency", Category: "dependency", Phase: "s", Timestamp: 2000000, PID: 1, TID: 0, ID: 1},
+		{Name: "dependency", Category: "dependency", Phase: "f", Timestamp: 2000000, PID: 1, TID: 0, ID: 1, BindingPoint: "e"},
+	})
+}
+
+func TestWriteChromeTraceWithoutExecution(t *testing.T) {
+	p := NewEngine(&dag.AcyclicGraph{})
+
+	var buf bytes.Buffer
+	assert.NilError(t, p.WriteChromeTrace(&buf, nil))
+	assert.Equal(t, buf.String(), "{\n  \"traceEvents\": [],\n  \"displayTimeUnit\": \"ms\"\n}\n")
+}
@@ -0,0 +1,66 @@
+package core
+
+import (
+	"fmt"
+	"os/exec"
+
+	"github.com/kballard/go-shellquote"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// CommandTransformer rewrites the command that runs a task before it is started,
+// e.g. to wrap it in nice or cgexec. cmd is the shell-quoted command line, and
+// the returned command line is split the same way, without running a shell. It
+// doesn't affect the hash of the task. If it returns an error, the task fails
+// without being started.
+type CommandTransformer = func(task *Task, cmd string) (string, error)
+
+// TransformCommand returns the command and arguments that run the given task,
+// rewritten by the CommandTransformer of the current execution, if there is one
+func (e *Engine) TransformCommand(taskID string, command string, args []string) (string, []string, error) {
+	if e.commandTransformer == nil {
+		return command, args, nil
+	}
+	pkg, taskName := util.GetPackageTaskFromId(taskID)
+	task, err := e.getTaskDefinition(pkg, taskName, taskID)
+	if err != nil {
+		return "", nil, err
+	}
+	cmd, err := e.commandTransformer(task, shellquote.Join(append([]string{command}, args...)...))
+	if err != nil {
+		return "", nil, fmt.Errorf("transforming the command of %v: %w", taskID, err)
+	}
+	argv, err := shellquote.Split(cmd)
+	if err != nil {
+		return "", nil, fmt.Errorf("transforming the command of %v: %w", taskID, err)
+	}
+	if len(argv) == 0 {
+		return "", nil, fmt.Errorf("transforming the command of %v: the command is empty", taskID)
+	}
+	return argv[0], argv[1:], nil
+}
+
+// transformingRunner returns a CommandRunner that runs the commands of the given
+// one, rewritten by the CommandTransformer of the current execution
+func (e *Engine) transformingRunner(r *CommandRunner) *CommandRunner {
+	return &CommandRunner{
+		Command: func(taskID string) (*exec.Cmd, error) {
+			cmd, err := r.Command(taskID)
+			if err != nil {
+				return nil, err
+			}
+			command, args, err := e.TransformCommand(taskID, cmd.Args[0], cmd.Args[1:])
+			if err != nil {
+				return nil, err
+			}
+			transformed := exec.Command(command, args...)
+			transformed.Dir = cmd.Dir
+			transformed.Env = cmd.Env
+			transformed.Stdin = cmd.Stdin
+			transformed.Stdout = cmd.Stdout
+			transformed.Stderr = cmd.Stderr
+			transformed.SysProcAttr = cmd.SysProcAttr
+			return transformed, nil
+		},
+	}
+}
@@ -0,0 +1,58 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// CompletionData holds the values shell completions can suggest for a repository
+type CompletionData struct {
+	// Tasks are the distinct names of the tasks that can be run
+	Tasks []string `json:"tasks"`
+	// Workspaces are the names of the workspaces in the repository, excluding the root
+	Workspaces []string `json:"workspaces"`
+	// PackageTasks are the workspace#task combinations that can be run
+	PackageTasks []string `json:"packageTasks"`
+}
+
+// CompletionData returns the tasks and workspaces that can be suggested by shell
+// completions. A task can be run in a workspace if the workspace has a script for
+// it and the task is configured in the pipeline. It only requires that tasks have
+// been added to the Engine, not that it has been prepared.
+func (e *Engine) CompletionData(packageInfos map[interface{}]*fs.PackageJSON) CompletionData {
+	tasks := make(util.Set)
+	workspaces := make(util.Set)
+	packageTasks := make(util.Set)
+	for key, pkg := range packageInfos {
+		pkgName := fmt.Sprintf("%v", key)
+		isRootPkg := pkgName == util.RootPkgName
+		if !isRootPkg {
+			workspaces.Add(pkgName)
+		}
+		for script := range pkg.Scripts {
+			if isRootPkg && !e.rootEnabledTasks.Includes(script) {
+				continue
+			}
+			taskID := util.GetTaskId(pkgName, script)
+			if _, err := e.getTaskDefinition(pkgName, script, taskID); err != nil {
+				continue
+			}
+			tasks.Add(script)
+			packageTasks.Add(taskID)
+		}
+	}
+	return CompletionData{
+		Tasks:        sortedStrings(tasks),
+		Workspaces:   sortedStrings(workspaces),
+		PackageTasks: sortedStrings(packageTasks),
+	}
+}
+
+func sortedStrings(set util.Set) []string {
+	list := set.UnsafeListOfStrings()
+	sort.Strings(list)
+	return list
+}
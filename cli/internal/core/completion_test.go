@@ -0,0 +1,31 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/util"
+	"gotest.tools/v3/assert"
+
+	"github.com/pyr-sh/dag"
+)
+
+func TestCompletionData(t *testing.T) {
+	p := NewEngine(&dag.AcyclicGraph{})
+	p.AddTask(&Task{Name: "build"})
+	p.AddTask(&Task{Name: "web#deploy"})
+	p.AddTask(&Task{Name: util.RootTaskID("format")})
+	packageInfos := map[interface{}]*fs.PackageJSON{
+		util.RootPkgName: {Scripts: map[string]string{"format": "prettier", "build": "turbo run build"}},
+		"web":            {Scripts: map[string]string{"build": "next build", "deploy": "vercel", "dev": "next dev"}},
+		"docs":           {Scripts: map[string]string{"build": "next build", "deploy": "vercel"}},
+		"ui":             {},
+	}
+
+	data := p.CompletionData(packageInfos)
+	assert.DeepEqual(t, data, CompletionData{
+		Tasks:        []string{"build", "deploy", "format"},
+		Workspaces:   []string{"docs", "ui", "web"},
+		PackageTasks: []string{"//#format", "docs#build", "web#build", "web#deploy"},
+	})
+}
@@ -0,0 +1,81 @@
+package core
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pyr-sh/dag"
+)
+
+// CriticalPath returns the longest chain of dependent tasks in the TaskGraph,
+// starting with the task that runs first. A chain is as long as the sum of the
+// durations of its tasks, so it is the chain that bounds how fast a run can be.
+// Tasks missing from durations take no time. If there are no durations at all,
+// every task counts the same, and the chain with the most edges is returned.
+// Ties are broken by task id.
+func (e *Engine) CriticalPath(durations map[string]time.Duration) []string {
+	weight := func(taskID string) time.Duration {
+		if len(durations) == 0 {
+			return 1
+		}
+		return durations[taskID]
+	}
+
+	// lengths holds the length of the longest chain ending at each task
+	lengths := make(map[string]time.Duration)
+	var lengthOf func(taskID string) time.Duration
+	lengthOf = func(taskID string) time.Duration {
+		if length, ok := lengths[taskID]; ok {
+			return length
+		}
+		longest := time.Duration(0)
+		for _, dep := range e.taskDependencies(taskID) {
+			if length := lengthOf(dep); length > longest {
+				longest = length
+			}
+		}
+		lengths[taskID] = longest + weight(taskID)
+		return lengths[taskID]
+	}
+
+	taskIDs := []string{}
+	for _, v := range e.TaskGraph.Vertices() {
+		taskID := dag.VertexName(v)
+		if strings.Contains(taskID, ROOT_NODE_NAME) {
+			continue
+		}
+		taskIDs = append(taskIDs, taskID)
+	}
+	last := longestTask(taskIDs, lengthOf)
+	if last == "" {
+		return []string{}
+	}
+
+	path := []string{last}
+	for {
+		dep := longestTask(e.taskDependencies(path[len(path)-1]), lengthOf)
+		if dep == "" {
+			break
+		}
+		path = append(path, dep)
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
+
+// longestTask returns the task with the longest chain ending at it, or the first
+// by task id if several are as long
+func longestTask(taskIDs []string, lengthOf func(taskID string) time.Duration) string {
+	sorted := append([]string{}, taskIDs...)
+	sort.Strings(sorted)
+	longest := ""
+	for _, taskID := range sorted {
+		if longest == "" || lengthOf(taskID) > lengthOf(longest) {
+			longest = taskID
+		}
+	}
+	return longest
+}
@@ -0,0 +1,73 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vercel/turbo/cli/internal/util"
+	"gotest.tools/v3/assert"
+
+	"github.com/pyr-sh/dag"
+)
+
+func newCriticalPathTestEngine(t *testing.T) *Engine {
+	t.Helper()
+	// A diamond: app depends on ui and utils, which both depend on config
+	graph := &dag.AcyclicGraph{}
+	graph.Add("app")
+	graph.Add("ui")
+	graph.Add("utils")
+	graph.Add("config")
+	graph.Connect(dag.BasicEdge("app", "ui"))
+	graph.Connect(dag.BasicEdge("app", "utils"))
+	graph.Connect(dag.BasicEdge("ui", "config"))
+	graph.Connect(dag.BasicEdge("utils", "config"))
+
+	tasks := []*Task{
+		{Name: "build", TopoDeps: util.SetFromStrings([]string{"build"})},
+		{Name: "lint"},
+	}
+	return prepareTestEngine(t, graph, tasks, &EngineBuildingOptions{
+		Packages:  []string{"app", "ui", "utils", "config"},
+		TaskNames: []string{"build", "lint"},
+	})
+}
+
+func TestCriticalPath(t *testing.T) {
+	p := newCriticalPathTestEngine(t)
+
+	path := p.CriticalPath(map[string]time.Duration{
+		"config#build": time.Second,
+		"ui#build":     2 * time.Second,
+		"utils#build":  5 * time.Second,
+		"app#build":    time.Second,
+		"app#lint":     6 * time.Second,
+	})
+	assert.DeepEqual(t, path, []string{"config#build", "utils#build", "app#build"})
+
+	path = p.CriticalPath(map[string]time.Duration{
+		"config#build": time.Second,
+		"ui#build":     5 * time.Second,
+		"utils#build":  2 * time.Second,
+		"app#build":    time.Second,
+		"app#lint":     6 * time.Second,
+	})
+	assert.DeepEqual(t, path, []string{"config#build", "ui#build", "app#build"})
+
+	// A single long task is longer than the chain through the diamond
+	path = p.CriticalPath(map[string]time.Duration{
+		"config#build": time.Second,
+		"ui#build":     5 * time.Second,
+		"utils#build":  2 * time.Second,
+		"app#build":    time.Second,
+		"app#lint":     10 * time.Second,
+	})
+	assert.DeepEqual(t, path, []string{"app#lint"})
+}
+
+func TestCriticalPathWithoutDurations(t *testing.T) {
+	p := newCriticalPathTestEngine(t)
+
+	// Both sides of the diamond have as many edges, so the first by task id wins
+	assert.DeepEqual(t, p.CriticalPath(nil), []string{"config#build", "ui#build", "app#build"})
+}
@@ -0,0 +1,75 @@
+package core
+
+import (
+	"sort"
+
+	"github.com/pyr-sh/dag"
+)
+
+// ValidateNoCycles returns a *CycleError naming the tasks in a cycle of dependencies
+// in the TaskGraph, in order, if there is one. Such tasks could never run, since each
+// waits on the next to finish.
+func (e *Engine) ValidateNoCycles() error {
+	cycle := e.findCycle()
+	if cycle == nil {
+		return nil
+	}
+	return &CycleError{Path: cycle}
+}
+
+// findCycle returns the task ids of the first cycle found in the TaskGraph, with
+// the first task repeated at the end, or nil if the TaskGraph has no cycles.
+// Tasks and their dependencies are searched in sorted order, so the same graph
+// always gives the same cycle.
+func (e *Engine) findCycle() []string {
+	const (
+		unvisited = iota
+		inProgress
+		done
+	)
+	state := make(map[string]int)
+	// path is the chain of dependencies from the task the search started at
+	path := []string{}
+	var visit func(taskID string) []string
+	visit = func(taskID string) []string {
+		state[taskID] = inProgress
+		path = append(path, taskID)
+		deps := []string{}
+		for _, dep := range e.TaskGraph.DownEdges(taskID) {
+			deps = append(deps, dag.VertexName(dep))
+		}
+		sort.Strings(deps)
+		for _, dep := range deps {
+			switch state[dep] {
+			case inProgress:
+				for i, pathTaskID := range path {
+					if pathTaskID == dep {
+						return append(append([]string{}, path[i:]...), dep)
+					}
+				}
+			case unvisited:
+				if cycle := visit(dep); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		state[taskID] = done
+		return nil
+	}
+
+	taskIDs := []string{}
+	for _, v := range e.TaskGraph.Vertices() {
+		taskIDs = append(taskIDs, dag.VertexName(v))
+	}
+	sort.Strings(taskIDs)
+	for _, taskID := range taskIDs {
+		if state[taskID] != unvisited {
+			continue
+		}
+		if cycle := visit(taskID); cycle != nil {
+			return cycle
+		}
+	}
+	return nil
+}
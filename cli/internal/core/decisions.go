@@ -0,0 +1,80 @@
+package core
+
+import (
+	"sort"
+	"time"
+)
+
+// DecisionKind describes what the engine decided to do with a task
+type DecisionKind string
+
+const (
+	// DecisionReady means all of the task's dependencies have finished
+	DecisionReady DecisionKind = "ready"
+	// DecisionWaiting means the task is waiting on something other than its dependencies
+	DecisionWaiting DecisionKind = "waiting"
+	// DecisionPaused means execution paused at the task because it is a breakpoint
+	DecisionPaused DecisionKind = "paused"
+	// DecisionStarted means the task has started running
+	DecisionStarted DecisionKind = "started"
+	// DecisionSkipped means the task will not run
+	DecisionSkipped DecisionKind = "skipped"
+	// DecisionCached means the task's outputs were restored from cache
+	DecisionCached DecisionKind = "cached"
+	// DecisionCacheMiss means the task was not found in cache, and will be executed
+	DecisionCacheMiss DecisionKind = "cache-miss"
+	// DecisionFinished means the task finished successfully
+	DecisionFinished DecisionKind = "finished"
+	// DecisionFailed means the task failed
+	DecisionFailed DecisionKind = "failed"
+	// DecisionRetrying means an attempt at running the task failed, and it will be run again
+	DecisionRetrying DecisionKind = "retrying"
+)
+
+// SchedulingDecision records a single decision the engine made about a task,
+// and why it made it.
+type SchedulingDecision struct {
+	Time   time.Time    `json:"time"`
+	TaskID string       `json:"taskId"`
+	Kind   DecisionKind `json:"kind"`
+	Reason string       `json:"reason"`
+	// GatedBy is the task that caused this decision, if any. For instance, the
+	// last dependency to finish before a task became ready.
+	GatedBy string `json:"gatedBy,omitempty"`
+}
+
+// RecordDecision records a scheduling decision for the given task, if the engine
+// is in explain mode. This allows decisions made outside of the engine, such as
+// whether a task was restored from cache, to be part of the timeline.
+func (e *Engine) RecordDecision(taskID string, kind DecisionKind, reason string) {
+	e.recordDecision(taskID, kind, "", reason)
+}
+
+func (e *Engine) recordDecision(taskID string, kind DecisionKind, gatedBy string, reason string) {
+	if !e.explain {
+		return
+	}
+	e.decisionsMu.Lock()
+	defer e.decisionsMu.Unlock()
+	e.decisions = append(e.decisions, SchedulingDecision{
+		Time:    time.Now(),
+		TaskID:  taskID,
+		Kind:    kind,
+		Reason:  reason,
+		GatedBy: gatedBy,
+	})
+}
+
+// Decisions returns the timeline of scheduling decisions made during the last
+// execution, in the order they were made. It is empty unless the engine was
+// prepared in explain mode.
+func (e *Engine) Decisions() []SchedulingDecision {
+	e.decisionsMu.Lock()
+	defer e.decisionsMu.Unlock()
+	decisions := make([]SchedulingDecision, len(e.decisions))
+	copy(decisions, e.decisions)
+	sort.SliceStable(decisions, func(i, j int) bool {
+		return decisions[i].Time.Before(decisions[j].Time)
+	})
+	return decisions
+}
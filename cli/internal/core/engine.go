@@ -1,9 +1,19 @@
 package core
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"math/rand"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/vercel/turbo/cli/internal/fs"
 	"github.com/vercel/turbo/cli/internal/util"
 
 	"github.com/pyr-sh/dag"
@@ -11,36 +21,205 @@ import (
 
 const ROOT_NODE_NAME = "___ROOT___"
 
+// pathDependencyPrefix marks a dependency on a task in the workspace at a given
+// directory, rather than with a given name (e.g. `path:packages/ui#build`)
+const pathDependencyPrefix = "path:"
+
+// alias so we can mock in tests
+var lookPath = exec.LookPath
+
 type Task struct {
 	Name string
 	// Deps are dependencies between tasks within the same package (e.g. `build` -> `test`)
 	Deps util.Set
 	// TopoDeps are dependencies across packages within the same topological graph (e.g. parent `build` -> child `build`) */
 	TopoDeps util.Set
+	// Shell overrides the shell used to run this task's script. If empty, the
+	// shell from the EngineBuildingOptions is used.
+	Shell string
+	// Outputs are the package-relative globs this task declares as its outputs
+	Outputs []string
+	// Inputs are the package-relative globs this task declares as its inputs
+	Inputs []string
+	// ShouldCache is whether this task's outputs are saved to, and restored from, the cache
+	ShouldCache bool
+	// Port is the port this task listens on, if it is persistent. Zero means
+	// the port isn't declared, or is picked dynamically.
+	Port int
+	// Weight is the number of concurrency slots this task takes up while it runs.
+	// Zero is treated as 1.
+	Weight int
+	// Timeout is how long this task can run for before it is stopped. If zero, it
+	// can run forever, so persistent tasks only time out if they set one.
+	Timeout time.Duration
+	// Retries is how many more times this task is run after failing, before it is
+	// considered failed. Persistent tasks are never retried.
+	Retries int
+	// InputsFromDepOutputs includes the outputs of this task's dependencies in its inputs
+	InputsFromDepOutputs bool
+	// DepQuorum is the minimum number of this task's dependencies that must succeed
+	// for it to run. If zero, all of its dependencies must succeed.
+	DepQuorum int
+	// Persistent tasks are long-running processes, such as dev servers, that never exit.
+	// Other tasks cannot depend on them, unless they AllowDependents.
+	Persistent bool
+	// AllowDependents lets other tasks depend on this persistent task. They start
+	// once it is ready, rather than waiting for it to exit.
+	AllowDependents bool
+	// ReadinessProbe, if set on a persistent task, is polled once it starts, and
+	// the tasks that wait for it to be ready start once the probe passes. If the
+	// probe times out, the task is stopped, and it and those tasks fail.
+	ReadinessProbe *ReadinessProbe
+	// StartsAfter are persistent tasks, as task ids (e.g. `api#dev`) or task names in the
+	// same package, that must be ready before this persistent task is started.
+	StartsAfter []string
+	// StreamingOutputs tasks produce their outputs incrementally, and signal when
+	// enough of them are ready for dependents that consume streaming outputs to start.
+	StreamingOutputs bool
+	// ConsumesStreaming allows this task to start as soon as its dependencies that
+	// have StreamingOutputs are ready, rather than waiting for them to finish.
+	ConsumesStreaming bool
+	// Tags are labels that group this task with others, so that they can be
+	// depended on together with DependsOnTag
+	Tags []string
+	// DependsOnTag are tags whose tasks, in the packages being run, must all
+	// succeed before this task is run
+	DependsOnTag []string
+	// RunWhen is the caching configuration the task runs under: RunWhenAlways,
+	// RunWhenCacheEnabled or RunWhenCacheDisabled. If empty, the task always runs.
+	RunWhen string
+	// Foreground tasks own the terminal while they run. The output of other tasks
+	// is held back until they finish, though the other tasks keep running.
+	Foreground bool
+	// MinTurboVersion is the oldest version of turbo that can run this task
+	MinTurboVersion string
+	// DotEnv are package-relative env files whose contents are part of this task's
+	// hash, and whose vars are loaded into the environment of its process
+	DotEnv []string
+	// Hook is HookPre or HookPost for a root task that runs before or after every
+	// other task in a run, if its script is defined. If empty, the task isn't a hook.
+	Hook string
 }
 
 type Visitor = func(taskID string) error
 
+// BreakpointHandler is called when execution reaches a breakpoint task, before
+// the task is run. Execution of the task resumes once the handler returns. If
+// the handler returns an error, the task is not run and fails with that error.
+type BreakpointHandler = func(taskID string) error
+
 // Engine contains both the DAG for the packages and the tasks and implements the methods to execute tasks in them
 type Engine struct {
 	// TopologicGraph is a graph of workspaces
 	TopologicGraph *dag.AcyclicGraph
 	// TaskGraph is a graph of package-tasks
 	TaskGraph *dag.AcyclicGraph
-	// Tasks are a map of tasks in the engine
-	Tasks            map[string]*Task
+	// hashGraph, if set, is the TaskGraph from before TasksOnly stripped the
+	// dependencies of the tasks in it. See HashGraph.
+	hashGraph *dag.AcyclicGraph
+	// tasks are the tasks added to the engine, by task name or task id
+	tasks            map[string]*Task
 	PackageTaskDeps  map[string][]string
 	rootEnabledTasks util.Set
+	// taskShells holds the shell for each task in the TaskGraph that has one configured
+	taskShells map[string]string
+	// depOutputs holds, for tasks that use their dependencies' outputs as inputs,
+	// the declared outputs of each dependency task
+	depOutputs map[string]map[string][]string
+	// breakpoints holds the task names and task ids that execution pauses at
+	breakpoints util.Set
+	// failedDeps holds, for tasks that ran despite some of their dependencies
+	// failing, the dependencies that failed during the last execution
+	failedDeps   map[string][]string
+	failedDepsMu sync.Mutex
+	// executed holds the tasks whose scripts were run, rather than restored from
+	// the cache, during the last execution
+	executed   util.Set
+	executedMu sync.Mutex
+	// startsAfter holds the resolved task ids of the persistent tasks that must be
+	// ready before each persistent task in the TaskGraph is started
+	startsAfter map[string][]string
+	// streamingDeps holds, for tasks that consume streaming outputs or depend on
+	// persistent tasks that allow dependents, the dependencies that they wait to be
+	// ready for, rather than to finish. These are not edges in the TaskGraph.
+	streamingDeps map[string][]string
+	// readiness tracks when persistent tasks are ready during an execution
+	readiness   map[string]*taskReadiness
+	readinessMu sync.Mutex
+	// taskContexts holds, for each running task with a readiness probe, a context
+	// that is done once the engine stops the task
+	taskContexts   map[string]context.Context
+	taskContextsMu sync.Mutex
+	// commandTransformer rewrites the commands of tasks during the current execution
+	commandTransformer CommandTransformer
+	// explain records scheduling decisions during execution
+	explain     bool
+	decisions   []SchedulingDecision
+	decisionsMu sync.Mutex
+	// warnings are non-fatal issues found while preparing the TaskGraph
+	warnings []string
+	// shuffledOrder, if set, is the order tasks must start in
+	shuffledOrder []string
+	// runWhenDecisions records whether each task with a RunWhen condition was included
+	runWhenDecisions []RunWhenDecision
+	// taskColors holds the color index of each task in the TaskGraph, within a
+	// palette of colorPaletteSize colors
+	taskColors       map[string]int
+	colorPaletteSize int
+	// timings holds when each task run during the last execution started and finished
+	timings   map[string]taskTiming
+	timingsMu sync.Mutex
+	// outputOrdered is whether grouped output is flushed in a configured order.
+	// outputRanks holds the position in that order of each task in the TaskGraph
+	// it applies to, and outputs holds the output of each task during the current
+	// execution.
+	outputOrdered bool
+	outputRanks   map[string]int
+	outputs       map[string]*taskOutput
+	outputMu      sync.Mutex
+	// prepared is set once Prepare has built the TaskGraph
+	prepared bool
+	// attempts holds how many times each task has been started during the last execution
+	attempts   map[string]int
+	attemptsMu sync.Mutex
+}
+
+// taskReadiness is signaled once a persistent task is ready, or has exited
+type taskReadiness struct {
+	once sync.Once
+	ch   chan struct{}
+	// err is set if the task exited unsuccessfully
+	err error
+}
+
+func (r *taskReadiness) signal(err error) {
+	r.once.Do(func() {
+		r.err = err
+		close(r.ch)
+	})
 }
 
 // NewEngine creates a new engine given a topologic graph of workspace package names
 func NewEngine(topologicalGraph *dag.AcyclicGraph) *Engine {
 	return &Engine{
-		Tasks:            make(map[string]*Task),
+		tasks:            make(map[string]*Task),
 		TopologicGraph:   topologicalGraph,
 		TaskGraph:        &dag.AcyclicGraph{},
 		PackageTaskDeps:  map[string][]string{},
 		rootEnabledTasks: make(util.Set),
+		taskShells:       make(map[string]string),
+		depOutputs:       make(map[string]map[string][]string),
+		breakpoints:      make(util.Set),
+		failedDeps:       make(map[string][]string),
+		executed:         make(util.Set),
+		startsAfter:      make(map[string][]string),
+		streamingDeps:    make(map[string][]string),
+		readiness:        make(map[string]*taskReadiness),
+		taskContexts:     make(map[string]context.Context),
+		timings:          make(map[string]taskTiming),
+		attempts:         make(map[string]int),
+		outputRanks:      make(map[string]int),
+		outputs:          make(map[string]*taskOutput),
 	}
 }
 
@@ -50,8 +229,53 @@ type EngineBuildingOptions struct {
 	Packages []string
 	// TaskNames in the execution scope, if nil, all tasks will be executed
 	TaskNames []string
-	// Restrict execution to only the listed task names
+	// Restrict execution to only the listed task names, without the tasks they
+	// depend on, which are assumed to be built
 	TasksOnly bool
+	// Shell is the shell used to run task scripts, unless a task specifies its own.
+	// If empty, the package manager's default shell is used.
+	Shell string
+	// BreakpointTasks are task names (e.g. `build`) or task ids (e.g. `web#build`)
+	// that execution pauses at before running them
+	BreakpointTasks []string
+	// ExplainMode records why each scheduling decision was made during execution.
+	// See Engine.Decisions.
+	ExplainMode bool
+	// WorkspaceProtocol, if set, only keeps dependencies between packages that
+	// reference each other with this protocol (e.g. "workspace") when expanding
+	// topological task dependencies. Protocols are read from PackageInfos.
+	WorkspaceProtocol string
+	// PackageInfos are the package.json files of the workspaces, keyed by name.
+	// Dependencies on a pattern of task names (e.g. "lint:*") expand to the
+	// scripts in them that match.
+	PackageInfos map[interface{}]*fs.PackageJSON
+	// ShuffleSeed, if non-zero, starts tasks in a pseudo-random order that is the
+	// same for every run with the same seed, while still honoring dependencies
+	ShuffleSeed int64
+	// CacheEnabled is whether the run uses a cache, which decides whether tasks
+	// with a RunWhen condition are included in the TaskGraph
+	CacheEnabled bool
+	// ColorPaletteSize is the number of colors tasks are assigned from for
+	// prefixing their logs. If zero, DefaultColorPaletteSize is used.
+	ColorPaletteSize int
+	// TurboVersion is the version of turbo that is running, which must be at least
+	// the MinTurboVersion of each task. If empty, it isn't checked.
+	TurboVersion string
+	// OutputOrder lists task names in the order that their grouped output is
+	// flushed in, regardless of the order they run in. See Engine.GroupedOutput.
+	OutputOrder []string
+	// AllowMissingTasks makes each of the TaskNames that no package in scope
+	// implements a warning, rather than an error. A package implements a task if
+	// the task is configured for it.
+	AllowMissingTasks bool
+}
+
+// weight returns the number of concurrency slots the task takes up while it runs
+func (t *Task) weight() int {
+	if t.Weight <= 0 {
+		return 1
+	}
+	return t.Weight
 }
 
 // Prepare constructs the Task Graph for a list of packages and tasks
@@ -60,56 +284,1138 @@ func (e *Engine) Prepare(options *EngineBuildingOptions) error {
 	tasks := options.TaskNames
 	if len(tasks) == 0 {
 		// TODO(gsoltis): Is this behavior used?
-		for key := range e.Tasks {
+		for key := range e.tasks {
 			tasks = append(tasks, key)
 		}
 	}
+	tasks, err := ExpandTaskNames(tasks, e.taskNames())
+	if err != nil {
+		return err
+	}
+
+	if err := e.resolvePathDependencies(options.PackageInfos); err != nil {
+		return err
+	}
+	e.resolvePackagePatternDependencies(options.PackageInfos)
+	if err := e.resolveRootTaskDependencies(pkgs); err != nil {
+		return err
+	}
+
+	if options.WorkspaceProtocol != "" {
+		e.TopologicGraph = filterTopologicGraphByProtocol(e.TopologicGraph, options.PackageInfos, options.WorkspaceProtocol)
+	}
+
+	// Only the tasks that were asked for have to be implemented
+	if len(options.TaskNames) > 0 {
+		if missing := e.unimplementedTasks(pkgs, tasks); len(missing) > 0 {
+			if !options.AllowMissingTasks {
+				return fmt.Errorf("no workspace in scope implements %v", strings.Join(missing, ", "))
+			}
+			for _, taskName := range missing {
+				e.warnings = append(e.warnings, fmt.Sprintf("no workspace in scope implements %v, so it is skipped", taskName))
+			}
+		}
+	}
+
+	preHooks, postHooks, err := e.hookTasks(options.PackageInfos)
+	if err != nil {
+		return err
+	}
+
+	if err := e.generateTaskGraph(pkgs, tasks, append(preHooks, postHooks...), options.PackageInfos); err != nil {
+		return err
+	}
+
+	if err := e.insertHookEdges(preHooks, postHooks); err != nil {
+		return err
+	}
+
+	if err := e.ValidateNoCycles(); err != nil {
+		return err
+	}
+
+	if err := e.omitConditionalTasks(options.CacheEnabled); err != nil {
+		return err
+	}
+
+	if err := e.validateMinTurboVersions(options.TurboVersion); err != nil {
+		return err
+	}
 
-	if err := e.generateTaskGraph(pkgs, tasks, options.TasksOnly); err != nil {
+	if err := e.validatePorts(); err != nil {
 		return err
 	}
 
+	e.warnUndeclaredInputsAndOutputs()
+
+	if err := e.validateReadinessProbes(); err != nil {
+		return err
+	}
+
+	if err := e.resolveShells(options.Shell); err != nil {
+		return err
+	}
+
+	if err := e.resolveDependencyOutputs(); err != nil {
+		return err
+	}
+
+	if err := e.validateDependencyQuorums(); err != nil {
+		return err
+	}
+
+	if err := e.resolveStartOrdering(); err != nil {
+		return err
+	}
+
+	if err := e.resolveStreamingDependencies(); err != nil {
+		return err
+	}
+
+	if options.ShuffleSeed != 0 {
+		e.shuffledOrder = e.shuffleTasks(options.ShuffleSeed)
+	}
+
+	// Dependencies are only stripped once everything the hashes of the remaining
+	// tasks depend on has been resolved
+	if options.TasksOnly {
+		e.stripDependencies(pkgs, tasks)
+	}
+
+	e.resolveTaskColors(options.ColorPaletteSize)
+	e.resolveOutputOrder(options.OutputOrder)
+
+	for _, breakpoint := range options.BreakpointTasks {
+		e.breakpoints.Add(breakpoint)
+	}
+	e.explain = options.ExplainMode
+	e.prepared = true
+
+	return nil
+}
+
+// unimplementedTasks returns the task names, sorted, that none of the packages
+// implement, because none of them have the task configured
+func (e *Engine) unimplementedTasks(pkgs []string, taskNames []string) []string {
+	missing := []string{}
+	for _, taskName := range taskNames {
+		implemented := false
+		for _, pkg := range pkgs {
+			if pkg == util.RootPkgName && !e.rootEnabledTasks.Includes(taskName) {
+				continue
+			}
+			taskID := util.GetTaskId(pkg, taskName)
+			if _, err := e.getTaskDefinition(pkg, taskName, taskID); err != nil {
+				continue
+			}
+			implemented = true
+			break
+		}
+		if !implemented {
+			missing = append(missing, taskName)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// resolvePathDependencies replaces dependencies on tasks in the workspace at a given
+// directory with the task ids of those tasks
+func (e *Engine) resolvePathDependencies(packageInfos map[interface{}]*fs.PackageJSON) error {
+	for _, task := range e.tasks {
+		if task.Deps == nil {
+			continue
+		}
+		resolved := make(util.Set)
+		for _, dep := range task.Deps.UnsafeListOfStrings() {
+			taskID, err := resolvePathDependency(dep, packageInfos)
+			if err != nil {
+				return fmt.Errorf("%v: %w", task.Name, err)
+			}
+			resolved.Add(taskID)
+		}
+		task.Deps = resolved
+	}
+	for toTaskID, fromTaskIDs := range e.PackageTaskDeps {
+		for i, fromTaskID := range fromTaskIDs {
+			taskID, err := resolvePathDependency(fromTaskID, packageInfos)
+			if err != nil {
+				return fmt.Errorf("%v: %w", toTaskID, err)
+			}
+			fromTaskIDs[i] = taskID
+		}
+	}
+	return nil
+}
+
+// resolvePathDependency returns the task id for a dependency on a task in the
+// workspace at a given directory. Other dependencies are returned unmodified.
+func resolvePathDependency(dep string, packageInfos map[interface{}]*fs.PackageJSON) (string, error) {
+	if !strings.HasPrefix(dep, pathDependencyPrefix) {
+		return dep, nil
+	}
+	if !util.IsPackageTask(dep) {
+		return "", fmt.Errorf("invalid dependency %v: expected %v<directory>#<task>", dep, pathDependencyPrefix)
+	}
+	dir, taskName := util.GetPackageTaskFromId(strings.TrimPrefix(dep, pathDependencyPrefix))
+	dir = path.Clean(filepath.ToSlash(dir))
+
+	matches := []string{}
+	for name, pkg := range packageInfos {
+		if path.Clean(pkg.Dir.ToUnixPath().ToString()) == dir {
+			matches = append(matches, fmt.Sprintf("%v", name))
+		}
+	}
+	sort.Strings(matches)
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("no workspace found at %v for dependency %v", dir, dep)
+	case 1:
+		return util.GetTaskId(matches[0], taskName), nil
+	default:
+		return "", fmt.Errorf("multiple workspaces found at %v for dependency %v: %v", dir, dep, strings.Join(matches, ", "))
+	}
+}
+
+// filterTopologicGraphByProtocol returns a copy of graph without the edges between
+// packages that don't reference each other using the given protocol. Packages that
+// are left without any dependencies are connected to the root node.
+func filterTopologicGraphByProtocol(graph *dag.AcyclicGraph, packageInfos map[interface{}]*fs.PackageJSON, protocol string) *dag.AcyclicGraph {
+	filtered := &dag.AcyclicGraph{}
+	for _, v := range graph.Vertices() {
+		filtered.Add(v)
+	}
+	for _, edge := range graph.Edges() {
+		to := dag.VertexName(edge.Target())
+		if to != ROOT_NODE_NAME {
+			pkg, ok := packageInfos[dag.VertexName(edge.Source())]
+			if !ok {
+				continue
+			}
+			if depProtocol, ok := pkg.InternalDepProtocols[to]; !ok || depProtocol != protocol {
+				continue
+			}
+		}
+		filtered.Connect(edge)
+	}
+	for _, v := range filtered.Vertices() {
+		if dag.VertexName(v) != ROOT_NODE_NAME && filtered.DownEdges(v).Len() == 0 {
+			filtered.Add(ROOT_NODE_NAME)
+			filtered.Connect(dag.BasicEdge(v, ROOT_NODE_NAME))
+		}
+	}
+	return filtered
+}
+
+// shuffleTasks returns the tasks in the TaskGraph in a pseudo-random order
+// determined by seed, in which every task comes after the tasks it depends on,
+// and after the tasks it waits to be ready for
+func (e *Engine) shuffleTasks(seed int64) []string {
+	rng := rand.New(rand.NewSource(seed))
+	pending := make(map[string]int)
+	dependents := make(map[string][]string)
+	for _, v := range e.TaskGraph.Vertices() {
+		taskID := dag.VertexName(v)
+		if strings.Contains(taskID, ROOT_NODE_NAME) {
+			continue
+		}
+		deps := e.taskDependencies(taskID)
+		deps = append(deps, e.startsAfter[taskID]...)
+		deps = append(deps, e.streamingDeps[taskID]...)
+		pending[taskID] = len(deps)
+		for _, dep := range deps {
+			dependents[dep] = append(dependents[dep], taskID)
+		}
+	}
+	ready := []string{}
+	for taskID, count := range pending {
+		if count == 0 {
+			ready = append(ready, taskID)
+		}
+	}
+	order := make([]string, 0, len(pending))
+	for len(ready) > 0 {
+		// Sort so that the choice only depends on the seed
+		sort.Strings(ready)
+		i := rng.Intn(len(ready))
+		taskID := ready[i]
+		ready = append(ready[:i], ready[i+1:]...)
+		order = append(order, taskID)
+		for _, dependent := range dependents[taskID] {
+			pending[dependent]--
+			if pending[dependent] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+	}
+	return order
+}
+
+// ShuffledOrder returns the order tasks start in, if a shuffle seed was given
+func (e *Engine) ShuffledOrder() []string {
+	return e.shuffledOrder
+}
+
+// resolveShells validates that the shell configured for each task in the TaskGraph
+// exists, and records it for use when executing and hashing the task. Package
+// managers run the shell as an executable path, so it can't take arguments, as in
+// "bash -eo pipefail".
+func (e *Engine) resolveShells(defaultShell string) error {
+	for _, v := range e.TaskGraph.Vertices() {
+		taskID := dag.VertexName(v)
+		if strings.Contains(taskID, ROOT_NODE_NAME) {
+			continue
+		}
+		pkg, taskName := util.GetPackageTaskFromId(taskID)
+		task, err := e.getTaskDefinition(pkg, taskName, taskID)
+		if err != nil {
+			return err
+		}
+		shell := defaultShell
+		if task.Shell != "" {
+			shell = task.Shell
+		}
+		if shell == "" {
+			continue
+		}
+		if _, err := lookPath(shell); err != nil {
+			if len(strings.Fields(shell)) > 1 {
+				return fmt.Errorf("shell \"%v\" for task %v has arguments, which package managers can't pass to it. Use a wrapper script instead", shell, taskID)
+			}
+			return fmt.Errorf("cannot find shell \"%v\" for task %v: %w", shell, taskID, err)
+		}
+		e.taskShells[taskID] = shell
+	}
+	return nil
+}
+
+// resolveDependencyOutputs collects the declared outputs of the dependencies of each
+// task in the TaskGraph that uses its dependencies' outputs as inputs.
+func (e *Engine) resolveDependencyOutputs() error {
+	for _, v := range e.TaskGraph.Vertices() {
+		taskID := dag.VertexName(v)
+		if strings.Contains(taskID, ROOT_NODE_NAME) {
+			continue
+		}
+		pkg, taskName := util.GetPackageTaskFromId(taskID)
+		task, err := e.getTaskDefinition(pkg, taskName, taskID)
+		if err != nil {
+			return err
+		}
+		if !task.InputsFromDepOutputs {
+			continue
+		}
+		depOutputs := make(map[string][]string)
+		for _, dep := range e.TaskGraph.DownEdges(taskID) {
+			depTaskID := dag.VertexName(dep)
+			if strings.Contains(depTaskID, ROOT_NODE_NAME) {
+				continue
+			}
+			depPkg, depTaskName := util.GetPackageTaskFromId(depTaskID)
+			depTask, err := e.getTaskDefinition(depPkg, depTaskName, depTaskID)
+			if err != nil {
+				return err
+			}
+			if len(depTask.Outputs) > 0 {
+				depOutputs[depTaskID] = depTask.Outputs
+			}
+		}
+		if len(depOutputs) == 0 {
+			return fmt.Errorf("%v uses the outputs of its dependencies as inputs, but none of its dependencies declare outputs", taskID)
+		}
+		e.depOutputs[taskID] = depOutputs
+	}
 	return nil
 }
 
+// validateDependencyQuorums checks that every task with a dependency quorum
+// has at least as many dependencies as its quorum requires.
+func (e *Engine) validateDependencyQuorums() error {
+	for _, v := range e.TaskGraph.Vertices() {
+		taskID := dag.VertexName(v)
+		if strings.Contains(taskID, ROOT_NODE_NAME) {
+			continue
+		}
+		pkg, taskName := util.GetPackageTaskFromId(taskID)
+		task, err := e.getTaskDefinition(pkg, taskName, taskID)
+		if err != nil {
+			return err
+		}
+		if task.DepQuorum < 0 {
+			return fmt.Errorf("%v has a negative dependency quorum", taskID)
+		}
+		if deps := e.taskDependencies(taskID); task.DepQuorum > len(deps) {
+			return fmt.Errorf("%v requires %v of its dependencies to succeed, but only has %v", taskID, task.DepQuorum, len(deps))
+		}
+	}
+	return nil
+}
+
+// resolveStartOrdering resolves the persistent tasks each persistent task in the
+// TaskGraph starts after, ignoring those that are not part of this run.
+func (e *Engine) resolveStartOrdering() error {
+	for _, v := range e.TaskGraph.Vertices() {
+		taskID := dag.VertexName(v)
+		if strings.Contains(taskID, ROOT_NODE_NAME) {
+			continue
+		}
+		pkg, taskName := util.GetPackageTaskFromId(taskID)
+		task, err := e.getTaskDefinition(pkg, taskName, taskID)
+		if err != nil {
+			return err
+		}
+		if len(task.StartsAfter) == 0 {
+			continue
+		}
+		if !task.Persistent {
+			return fmt.Errorf("%v starts after other tasks, but only persistent tasks can declare a start ordering", taskID)
+		}
+		for _, other := range task.StartsAfter {
+			otherTaskID := other
+			if !util.IsPackageTask(other) {
+				otherTaskID = util.GetTaskId(pkg, other)
+			}
+			if !e.TaskGraph.HasVertex(otherTaskID) {
+				continue
+			}
+			otherPkg, otherTaskName := util.GetPackageTaskFromId(otherTaskID)
+			otherTask, err := e.getTaskDefinition(otherPkg, otherTaskName, otherTaskID)
+			if err != nil {
+				return err
+			}
+			if !otherTask.Persistent {
+				return fmt.Errorf("%v starts after %v, but %v is not a persistent task", taskID, otherTaskID, otherTaskID)
+			}
+			e.startsAfter[taskID] = append(e.startsAfter[taskID], otherTaskID)
+		}
+	}
+
+	// Starting tasks in a cycle would wait on each other forever
+	visited := make(util.Set)
+	inProgress := make(util.Set)
+	var visit func(taskID string, path []string) error
+	visit = func(taskID string, path []string) error {
+		if inProgress.Includes(taskID) {
+			return fmt.Errorf("Invalid start ordering, found a cycle: %v", strings.Join(append(path, taskID), " -> "))
+		}
+		if visited.Includes(taskID) {
+			return nil
+		}
+		inProgress.Add(taskID)
+		for _, other := range e.startsAfter[taskID] {
+			if err := visit(other, append(path, taskID)); err != nil {
+				return err
+			}
+		}
+		inProgress.Delete(taskID)
+		visited.Add(taskID)
+		return nil
+	}
+	taskIDs := make([]string, 0, len(e.startsAfter))
+	for taskID := range e.startsAfter {
+		taskIDs = append(taskIDs, taskID)
+	}
+	sort.Strings(taskIDs)
+	for _, taskID := range taskIDs {
+		if err := visit(taskID, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveStreamingDependencies replaces the TaskGraph edges from tasks that consume
+// streaming outputs to their dependencies that have streaming outputs, and from any
+// task to the persistent tasks that allow dependents, so that those tasks can start
+// as soon as their dependencies are ready.
+func (e *Engine) resolveStreamingDependencies() error {
+	for _, v := range e.TaskGraph.Vertices() {
+		taskID := dag.VertexName(v)
+		if strings.Contains(taskID, ROOT_NODE_NAME) {
+			continue
+		}
+		pkg, taskName := util.GetPackageTaskFromId(taskID)
+		task, err := e.getTaskDefinition(pkg, taskName, taskID)
+		if err != nil {
+			return err
+		}
+		deps := e.taskDependencies(taskID)
+		for _, depTaskID := range deps {
+			depPkg, depTaskName := util.GetPackageTaskFromId(depTaskID)
+			depTask, err := e.getTaskDefinition(depPkg, depTaskName, depTaskID)
+			if err != nil {
+				return err
+			}
+			streaming := task.ConsumesStreaming && depTask.StreamingOutputs
+			if !streaming && !(depTask.Persistent && depTask.AllowDependents) {
+				continue
+			}
+			e.TaskGraph.RemoveEdge(dag.BasicEdge(taskID, depTaskID))
+			e.streamingDeps[taskID] = append(e.streamingDeps[taskID], depTaskID)
+		}
+		sort.Strings(e.streamingDeps[taskID])
+		// Keep tasks without remaining dependencies attached to the root, like any other
+		if len(e.streamingDeps[taskID]) > 0 && len(e.streamingDeps[taskID]) == len(deps) {
+			e.TaskGraph.Add(ROOT_NODE_NAME)
+			e.TaskGraph.Connect(dag.BasicEdge(taskID, ROOT_NODE_NAME))
+		}
+	}
+	return nil
+}
+
+// StreamingDependencies returns the dependencies of the given task that it only
+// waits to be ready for, rather than to finish. These are not edges in the TaskGraph.
+func (e *Engine) StreamingDependencies(taskID string) []string {
+	return e.streamingDeps[taskID]
+}
+
+// ValidatePersistentDependencies checks that no task in the TaskGraph depends on a
+// persistent task, since persistent tasks never exit, unless the persistent task
+// allows dependents. hasScript reports whether a
+// task's package defines a script for it; tasks without one are never run and so
+// are not a problem. A violation is reported as a *PersistentDependencyError, and
+// several as PersistentDependencyErrors, sorted by the task that depends on the
+// persistent task.
+func (e *Engine) ValidatePersistentDependencies(hasScript func(taskID string) bool) error {
+	type violation struct {
+		taskID    string
+		depTaskID string
+	}
+	violations := []violation{}
+	for _, v := range e.TaskGraph.Vertices() {
+		taskID := dag.VertexName(v)
+		if strings.Contains(taskID, ROOT_NODE_NAME) {
+			continue
+		}
+		for _, depTaskID := range e.taskDependencies(taskID) {
+			depPkg, depTaskName := util.GetPackageTaskFromId(depTaskID)
+			depTask, err := e.getTaskDefinition(depPkg, depTaskName, depTaskID)
+			if err != nil {
+				return err
+			}
+			if depTask.Persistent && !depTask.AllowDependents && hasScript(depTaskID) {
+				violations = append(violations, violation{taskID: taskID, depTaskID: depTaskID})
+			}
+		}
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+	sort.Slice(violations, func(i, j int) bool {
+		if violations[i].taskID != violations[j].taskID {
+			return violations[i].taskID < violations[j].taskID
+		}
+		return violations[i].depTaskID < violations[j].depTaskID
+	})
+	errs := make(PersistentDependencyErrors, len(violations))
+	for i, violation := range violations {
+		errs[i] = &PersistentDependencyError{Dependent: violation.taskID, Persistent: violation.depTaskID}
+	}
+	if len(errs) == 1 {
+		return errs[0]
+	}
+	return errs
+}
+
+// MarkReady signals that the given persistent task, or task with streaming outputs,
+// is ready, allowing tasks that wait for it to be started. Tasks that are never
+// marked ready are considered ready when they exit successfully.
+func (e *Engine) MarkReady(taskID string) {
+	e.readinessMu.Lock()
+	readiness, ok := e.readiness[taskID]
+	e.readinessMu.Unlock()
+	if ok {
+		readiness.signal(nil)
+	}
+}
+
+// TaskContext returns a context that is done once the engine stops the given
+// task while it runs, such as when its readiness probe times out. Visitors that
+// run a process for the task should stop it once the context is done.
+func (e *Engine) TaskContext(taskID string) context.Context {
+	e.taskContextsMu.Lock()
+	defer e.taskContextsMu.Unlock()
+	if ctx, ok := e.taskContexts[taskID]; ok {
+		return ctx
+	}
+	return context.Background()
+}
+
+// setTaskContext sets the context returned by TaskContext for the given task, or
+// removes it if ctx is nil
+func (e *Engine) setTaskContext(taskID string, ctx context.Context) {
+	e.taskContextsMu.Lock()
+	defer e.taskContextsMu.Unlock()
+	if ctx == nil {
+		delete(e.taskContexts, taskID)
+		return
+	}
+	e.taskContexts[taskID] = ctx
+}
+
+// taskDependencies returns the ids of the tasks the given task depends on
+func (e *Engine) taskDependencies(taskID string) []string {
+	deps := []string{}
+	for _, dep := range e.TaskGraph.DownEdges(taskID) {
+		depTaskID := dag.VertexName(dep)
+		if strings.Contains(depTaskID, ROOT_NODE_NAME) {
+			continue
+		}
+		deps = append(deps, depTaskID)
+	}
+	return deps
+}
+
+// FailedDependencies returns the dependencies of the given task that failed
+// during the last execution, for tasks that ran because their dependency quorum
+// was still met.
+func (e *Engine) FailedDependencies(taskID string) []string {
+	e.failedDepsMu.Lock()
+	defer e.failedDepsMu.Unlock()
+	return e.failedDeps[taskID]
+}
+
+// MarkExecuted records that the given task's script was run during the current
+// execution, rather than its outputs being restored from the cache
+func (e *Engine) MarkExecuted(taskID string) {
+	e.executedMu.Lock()
+	defer e.executedMu.Unlock()
+	e.executed.Add(taskID)
+}
+
+// ExecutedDependencies returns the direct dependencies of the given task that
+// were marked as executed during the current execution, sorted by task ID
+func (e *Engine) ExecutedDependencies(taskID string) []string {
+	e.executedMu.Lock()
+	defer e.executedMu.Unlock()
+	executed := []string{}
+	for _, dep := range e.taskDependencies(taskID) {
+		if e.executed.Includes(dep) {
+			executed = append(executed, dep)
+		}
+	}
+	sort.Strings(executed)
+	return executed
+}
+
+// DependencyOutputs returns the declared outputs of each dependency of the given task,
+// keyed by dependency task ID, if the task uses its dependencies' outputs as inputs.
+func (e *Engine) DependencyOutputs(taskID string) map[string][]string {
+	return e.depOutputs[taskID]
+}
+
+// TaskShell returns the shell that should be used to run the given task's script,
+// or an empty string if the package manager's default should be used.
+func (e *Engine) TaskShell(taskID string) string {
+	return e.taskShells[taskID]
+}
+
 // EngineExecutionOptions controls a single walk of the task graph
 type EngineExecutionOptions struct {
 	// Parallel is whether to run tasks in parallel
 	Parallel bool
 	// Concurrency is the number of concurrent tasks that can be executed
 	Concurrency int
+	// BreakpointHandler is called when a breakpoint task is reached. If nil,
+	// breakpoints are ignored.
+	BreakpointHandler BreakpointHandler
+	// PauseAllOnBreakpoint prevents any other task from starting while a
+	// BreakpointHandler is running. Tasks that are already running are not
+	// interrupted. If false, independent tasks continue to be scheduled.
+	PauseAllOnBreakpoint bool
+	// ContinueOnError keeps running the tasks that don't depend on a failed task.
+	// If false, no more tasks are started once any task fails.
+	ContinueOnError bool
+	// MaxErrors, if positive, stops starting new tasks once that many tasks have
+	// failed, even if ContinueOnError is set. Tasks that are already running finish.
+	MaxErrors int
+	// Restore, if set, is called for each task once it is ready to start, before it
+	// takes any concurrency slots, so that restoring tasks from the cache doesn't
+	// wait for running tasks. If it returns true, the task was restored and is
+	// finished without being visited. Tasks that pause at a breakpoint aren't restored.
+	Restore func(taskID string) bool
+	// RestoreConcurrency is the number of Restore calls that can run at once. If it
+	// isn't positive, it is the same as Concurrency.
+	RestoreConcurrency int
+	// Runner, if set, runs each task instead of the visitor, with the environment
+	// variables from TaskEnv, if set, and Context, which defaults to context.Background()
+	Runner  TaskRunner
+	TaskEnv func(taskID string) []string
+	Context context.Context
+	// CommandTransformer, if set, rewrites the command of each task before it is
+	// started. Visitors that start tasks themselves apply it with TransformCommand.
+	CommandTransformer CommandTransformer
 }
 
 // Execute executes the pipeline, constructing an internal task graph and walking it accordingly.
+// The visitor can be nil if opts has a Runner.
 func (e *Engine) Execute(visitor Visitor, opts EngineExecutionOptions) []error {
-	var sema = util.NewSemaphore(opts.Concurrency)
-	return e.TaskGraph.Walk(func(v dag.Vertex) error {
+	e.commandTransformer = opts.CommandTransformer
+	if runner, ok := opts.Runner.(*CommandRunner); ok && opts.CommandTransformer != nil {
+		opts.Runner = e.transformingRunner(runner)
+	}
+	if opts.Runner != nil {
+		visitor = runnerVisitor(opts)
+	}
+	var queue = newStartQueue(opts.Concurrency)
+	restoreConcurrency := opts.RestoreConcurrency
+	if restoreConcurrency <= 0 {
+		restoreConcurrency = opts.Concurrency
+	}
+	var restoreSema = util.NewWeightedSemaphore(restoreConcurrency)
+	// paused is write-locked while a breakpoint pauses all execution. Tasks
+	// briefly take a read lock before starting so that they wait it out.
+	var paused sync.RWMutex
+	// handlerMu ensures only one breakpoint is handled at a time
+	var handlerMu sync.Mutex
+
+	// Tasks never report errors to the walk, as that would unconditionally skip
+	// their dependents. Instead, we track which tasks did not succeed so that
+	// each task can decide whether it can run based on its dependency quorum.
+	var resultsMu sync.Mutex
+	var errs []error
+	unsuccessful := make(util.Set)
+	// halted is set once a task fails, unless execution continues on error, or
+	// once opts.MaxErrors tasks have failed
+	halted := false
+	// finished holds tasks in the order they finished, whether successfully or not
+	finished := make(map[string]int)
+	// finished tasks make the dependents whose dependencies have all finished ready
+	// to start. The lock on results must be held.
+	markFinished := func(taskID string) {
+		finished[taskID] = len(finished)
+		queue.leave(taskID)
+		for _, v := range e.TaskGraph.UpEdges(taskID) {
+			dependent := dag.VertexName(v)
+			ready := true
+			for _, dep := range e.taskDependencies(dependent) {
+				if _, ok := finished[dep]; !ok {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				queue.expect(dependent)
+			}
+		}
+	}
+	finish := func(taskID string) {
+		resultsMu.Lock()
+		defer resultsMu.Unlock()
+		markFinished(taskID)
+	}
+	fail := func(taskID string, err error) {
+		resultsMu.Lock()
+		defer resultsMu.Unlock()
+		unsuccessful.Add(taskID)
+		markFinished(taskID)
+		if err != nil {
+			errs = append(errs, err)
+			halted = halted || !opts.ContinueOnError || (opts.MaxErrors > 0 && len(errs) >= opts.MaxErrors)
+		}
+	}
+	isHalted := func() bool {
+		resultsMu.Lock()
+		defer resultsMu.Unlock()
+		return halted
+	}
+	e.decisionsMu.Lock()
+	e.decisions = nil
+	e.decisionsMu.Unlock()
+	e.failedDepsMu.Lock()
+	e.failedDeps = make(map[string][]string)
+	e.failedDepsMu.Unlock()
+	e.executedMu.Lock()
+	e.executed = make(util.Set)
+	e.executedMu.Unlock()
+	e.timingsMu.Lock()
+	e.timings = make(map[string]taskTiming)
+	e.timingsMu.Unlock()
+	e.outputMu.Lock()
+	e.outputs = make(map[string]*taskOutput)
+	e.outputMu.Unlock()
+	e.attemptsMu.Lock()
+	e.attempts = make(map[string]int)
+	e.attemptsMu.Unlock()
+	readiness := make(map[string]*taskReadiness)
+	for _, waitsOn := range []map[string][]string{e.startsAfter, e.streamingDeps} {
+		for _, others := range waitsOn {
+			for _, other := range others {
+				readiness[other] = &taskReadiness{ch: make(chan struct{})}
+			}
+		}
+	}
+	e.readinessMu.Lock()
+	e.readiness = readiness
+	e.readinessMu.Unlock()
+	// signalDone marks a task that others wait to be ready for as ready when it
+	// exits, or as failed if it exits unsuccessfully without having been marked ready
+	signalDone := func(taskID string, err error) {
+		if r, ok := readiness[taskID]; ok {
+			if err != nil {
+				err = fmt.Errorf("%v exited before becoming ready", taskID)
+			}
+			r.signal(err)
+		}
+	}
+	// With a shuffled order, each task takes its turn to start once the task
+	// before it has started or been skipped. turns are closed at the end of a turn.
+	turns := make(map[string]chan struct{}, len(e.shuffledOrder))
+	previous := make(map[string]string, len(e.shuffledOrder))
+	for i, taskID := range e.shuffledOrder {
+		turns[taskID] = make(chan struct{})
+		if i > 0 {
+			previous[taskID] = e.shuffledOrder[i-1]
+		}
+	}
+
+	// Tasks without dependencies are ready to start right away
+	for _, v := range e.TaskGraph.Vertices() {
+		taskID := dag.VertexName(v)
+		if !strings.Contains(taskID, ROOT_NODE_NAME) && len(e.taskDependencies(taskID)) == 0 {
+			queue.expect(taskID)
+		}
+	}
+
+	walkErrs := e.TaskGraph.Walk(func(v dag.Vertex) error {
+		taskID := dag.VertexName(v)
 		// Always return if it is the root node
-		if strings.Contains(dag.VertexName(v), ROOT_NODE_NAME) {
+		if strings.Contains(taskID, ROOT_NODE_NAME) {
 			return nil
 		}
-		// Acquire the semaphore unless parallel
+		// Whether the task ran or was skipped, its place in the output order is done
+		defer e.finishOutput(taskID)
+
+		waitForTurn := func() {
+			if prev, ok := previous[taskID]; ok {
+				<-turns[prev]
+			}
+		}
+		var turnOnce sync.Once
+		endTurn := func() {
+			if turn, ok := turns[taskID]; ok {
+				turnOnce.Do(func() { close(turn) })
+			}
+		}
+		// Tasks that are skipped still take their turn, so the order is kept
+		defer func() {
+			waitForTurn()
+			endTurn()
+		}()
+
+		pkg, taskName := util.GetPackageTaskFromId(taskID)
+		task, err := e.getTaskDefinition(pkg, taskName, taskID)
+		if err != nil {
+			fail(taskID, err)
+			signalDone(taskID, err)
+			return nil
+		}
+		// A task that is never started will never be ready
+		started := false
+		defer func() {
+			if !started {
+				signalDone(taskID, errors.New("not started"))
+			}
+		}()
+		deps := e.taskDependencies(taskID)
+		failedDeps := []string{}
+		// lastDep is the last dependency to finish, which is what the task was waiting on
+		lastDep := ""
+		resultsMu.Lock()
+		for _, dep := range deps {
+			if unsuccessful.Includes(dep) {
+				failedDeps = append(failedDeps, dep)
+			}
+			if lastDep == "" || finished[dep] > finished[lastDep] {
+				lastDep = dep
+			}
+		}
+		resultsMu.Unlock()
+		sort.Strings(failedDeps)
+		if len(failedDeps) > 0 {
+			// A failed persistent task takes down the tasks that depend on it,
+			// regardless of their quorum
+			for _, dep := range failedDeps {
+				if e.IsPersistent(dep) {
+					e.recordDecision(taskID, DecisionSkipped, dep, fmt.Sprintf("persistent dependency %v did not succeed", dep))
+					fail(taskID, nil)
+					return nil
+				}
+			}
+			if task.DepQuorum == 0 {
+				// Like any task with a failed dependency, skip it without
+				// reporting an error, as the dependency's error already explains it.
+				e.recordDecision(taskID, DecisionSkipped, failedDeps[0], fmt.Sprintf("dependency %v did not succeed", failedDeps[0]))
+				fail(taskID, nil)
+				return nil
+			}
+			if succeeded := len(deps) - len(failedDeps); succeeded < task.DepQuorum {
+				e.recordDecision(taskID, DecisionSkipped, lastDep, fmt.Sprintf("only %v of %v dependencies succeeded, but %v are required", succeeded, len(deps), task.DepQuorum))
+				fail(taskID, fmt.Errorf("%v requires %v of its dependencies to succeed, but only %v did", taskID, task.DepQuorum, succeeded))
+				return nil
+			}
+			e.recordDecision(taskID, DecisionReady, lastDep, fmt.Sprintf("%v of %v dependencies succeeded, meeting the quorum of %v; failed: %v", len(deps)-len(failedDeps), len(deps), task.DepQuorum, strings.Join(failedDeps, ", ")))
+			e.failedDepsMu.Lock()
+			e.failedDeps[taskID] = failedDeps
+			e.failedDepsMu.Unlock()
+		} else if len(deps) == 0 {
+			e.recordDecision(taskID, DecisionReady, "", "it has no dependencies")
+		} else {
+			e.recordDecision(taskID, DecisionReady, lastDep, fmt.Sprintf("all %v dependencies finished, the last was %v", len(deps), lastDep))
+		}
+
+		// Wait for the persistent tasks this task starts after, and the streaming
+		// dependencies it consumes, to be ready
+		waitsOn := append([]string{}, e.startsAfter[taskID]...)
+		waitsOn = append(waitsOn, e.streamingDeps[taskID]...)
+		for _, other := range waitsOn {
+			r := readiness[other]
+			select {
+			case <-r.ch:
+			default:
+				e.recordDecision(taskID, DecisionWaiting, other, fmt.Sprintf("waiting for %v to be ready", other))
+				queue.leave(taskID)
+				<-r.ch
+			}
+			if r.err != nil {
+				e.recordDecision(taskID, DecisionSkipped, other, fmt.Sprintf("%v never became ready", other))
+				fail(taskID, fmt.Errorf("cannot start %v: %w", taskID, r.err))
+				return nil
+			}
+		}
+
+		if prev, ok := previous[taskID]; ok {
+			select {
+			case <-turns[prev]:
+			default:
+				e.recordDecision(taskID, DecisionWaiting, prev, fmt.Sprintf("waiting for %v to start first in the shuffled order", prev))
+				queue.leave(taskID)
+				waitForTurn()
+			}
+		}
+
+		// Restore the task before it waits for any concurrency slots. While it is
+		// restored, it doesn't hold back the tasks after it from starting.
+		breakpoint := opts.BreakpointHandler != nil && e.isBreakpoint(taskID)
+		restoreAttempted := false
+		if opts.Restore != nil && !breakpoint && !isHalted() {
+			queue.leave(taskID)
+			restoreSema.Acquire(1)
+			startedAt := time.Now()
+			e.startAttempt(taskID)
+			restored := opts.Restore(taskID)
+			restoreSema.Release(1)
+			restoreAttempted = true
+			if restored {
+				started = true
+				e.recordTiming(taskID, startedAt, time.Now())
+				signalDone(taskID, nil)
+				e.recordDecision(taskID, DecisionFinished, "", "")
+				finish(taskID)
+				return nil
+			}
+			queue.expect(taskID)
+		}
+
+		// Acquire as many concurrency slots as the task weighs, unless parallel. Of
+		// the tasks that are ready at the same time, the first by task id starts first.
 		if !opts.Parallel {
-			sema.Acquire()
-			defer sema.Release()
+			weight := task.weight()
+			if weight > queue.limit {
+				endTurn()
+				e.recordDecision(taskID, DecisionSkipped, "", fmt.Sprintf("its weight of %v exceeds the concurrency of %v", weight, opts.Concurrency))
+				fail(taskID, fmt.Errorf("%v has a weight of %v, which exceeds the concurrency of %v. Raise --concurrency to at least %v, or lower the task's weight", taskID, weight, opts.Concurrency, weight))
+				return nil
+			}
+			if !queue.tryAcquire(taskID, weight) {
+				if weight == 1 {
+					e.recordDecision(taskID, DecisionWaiting, "", fmt.Sprintf("all %v concurrency slots are in use", opts.Concurrency))
+				} else {
+					e.recordDecision(taskID, DecisionWaiting, "", fmt.Sprintf("it needs %v of the %v concurrency slots, but not enough are free", weight, opts.Concurrency))
+				}
+				queue.acquire(taskID, weight)
+			}
+			defer queue.release(weight)
+		}
+		endTurn()
+		if breakpoint {
+			e.recordDecision(taskID, DecisionPaused, "", "it is a breakpoint")
+			handlerMu.Lock()
+			if opts.PauseAllOnBreakpoint {
+				paused.Lock()
+			}
+			err := opts.BreakpointHandler(taskID)
+			if opts.PauseAllOnBreakpoint {
+				paused.Unlock()
+			}
+			handlerMu.Unlock()
+			if err != nil {
+				e.recordDecision(taskID, DecisionSkipped, "", fmt.Sprintf("the breakpoint handler failed: %v", err))
+				fail(taskID, err)
+				return nil
+			}
+		} else {
+			paused.RLock()
+			paused.RUnlock()
+		}
+		if isHalted() {
+			reason := "another task failed, and execution doesn't continue on error"
+			if opts.ContinueOnError {
+				reason = fmt.Sprintf("%v tasks failed, which is the most allowed", opts.MaxErrors)
+			}
+			e.recordDecision(taskID, DecisionSkipped, "", reason)
+			fail(taskID, nil)
+			return nil
 		}
-		return visitor(dag.VertexName(v))
+		started = true
+		e.recordDecision(taskID, DecisionStarted, "", "")
+		startedAt := time.Now()
+		visit := visitor
+		// probeErr is set if the readiness probe of the task times out, by the time
+		// probeDone is closed
+		var probeErr error
+		probeDone := make(chan struct{})
+		stopProbe := func() {}
+		if task.Persistent && task.ReadinessProbe != nil {
+			probeCtx, cancelProbe := context.WithCancel(context.Background())
+			stopProbe = cancelProbe
+			taskCtx, stopTask := context.WithCancel(runnerContext(opts))
+			defer stopTask()
+			e.setTaskContext(taskID, taskCtx)
+			defer e.setTaskContext(taskID, nil)
+			if opts.Runner != nil {
+				taskOpts := opts
+				taskOpts.Context = taskCtx
+				visit = runnerVisitor(taskOpts)
+			}
+			go func() {
+				defer close(probeDone)
+				err := task.ReadinessProbe.wait(probeCtx, taskID)
+				if err == nil {
+					e.MarkReady(taskID)
+					return
+				}
+				if probeCtx.Err() != nil {
+					// The task exited before it became ready
+					return
+				}
+				// The task fails without waiting for it to exit, and is stopped
+				// through its context
+				probeErr = err
+				e.recordDecision(taskID, DecisionFailed, "", err.Error())
+				fail(taskID, err)
+				if r, ok := readiness[taskID]; ok {
+					r.signal(err)
+				}
+				stopTask()
+			}()
+		} else {
+			close(probeDone)
+		}
+		defer func() {
+			stopProbe()
+			<-probeDone
+		}()
+		maxAttempts := task.maxAttempts()
+		for {
+			// A restore that missed the cache is part of the first attempt
+			var attempt int
+			if restoreAttempted {
+				attempt = e.Attempts(taskID)
+				restoreAttempted = false
+			} else {
+				attempt = e.startAttempt(taskID)
+			}
+			err = visit(taskID)
+			if err == nil || attempt >= maxAttempts {
+				break
+			}
+			e.recordDecision(taskID, DecisionRetrying, "", fmt.Sprintf("attempt %v of %v failed: %v", attempt, maxAttempts, err))
+		}
+		e.recordTiming(taskID, startedAt, time.Now())
+		// Wait for the probe to stop, so that a task that was failed by its probe
+		// isn't finished again
+		stopProbe()
+		<-probeDone
+		if probeErr != nil {
+			return nil
+		}
+		signalDone(taskID, err)
+		if err != nil {
+			e.recordDecision(taskID, DecisionFailed, "", err.Error())
+			fail(taskID, err)
+		} else {
+			e.recordDecision(taskID, DecisionFinished, "", "")
+			finish(taskID)
+		}
+		return nil
 	})
+	return append(errs, walkErrs...)
+}
+
+// isBreakpoint returns true if execution should pause before running the given task
+func (e *Engine) isBreakpoint(taskID string) bool {
+	if e.breakpoints.Includes(taskID) {
+		return true
+	}
+	_, taskName := util.GetPackageTaskFromId(taskID)
+	return e.breakpoints.Includes(taskName)
+}
+
+// IsForeground returns true if the given task owns the terminal while it runs
+func (e *Engine) IsForeground(taskID string) bool {
+	pkg, taskName := util.GetPackageTaskFromId(taskID)
+	task, err := e.getTaskDefinition(pkg, taskName, taskID)
+	if err != nil {
+		return false
+	}
+	return task.Foreground
+}
+
+// IsPersistent returns true if the given task is a long-running process that never exits
+func (e *Engine) IsPersistent(taskID string) bool {
+	pkg, taskName := util.GetPackageTaskFromId(taskID)
+	task, err := e.getTaskDefinition(pkg, taskName, taskID)
+	if err != nil {
+		return false
+	}
+	return task.Persistent
+}
+
+// TaskTimeout returns how long the given task can run for before it is stopped,
+// or zero if it can run forever
+func (e *Engine) TaskTimeout(taskID string) time.Duration {
+	pkg, taskName := util.GetPackageTaskFromId(taskID)
+	task, err := e.getTaskDefinition(pkg, taskName, taskID)
+	if err != nil {
+		return 0
+	}
+	return task.Timeout
+}
+
+// TaskDotEnv returns the package-relative env files that the given task loads
+func (e *Engine) TaskDotEnv(taskID string) []string {
+	pkg, taskName := util.GetPackageTaskFromId(taskID)
+	task, err := e.getTaskDefinition(pkg, taskName, taskID)
+	if err != nil {
+		return nil
+	}
+	return task.DotEnv
 }
 
 func (e *Engine) getTaskDefinition(pkg string, taskName string, taskID string) (*Task, error) {
-	if task, ok := e.Tasks[taskID]; ok {
+	if task, ok := e.tasks[taskID]; ok {
 		return task, nil
 	}
-	if task, ok := e.Tasks[taskName]; ok {
+	if task, ok := e.tasks[taskName]; ok {
 		return task, nil
 	}
 
-	return nil, fmt.Errorf("Missing task definition, configure \"%s\" or \"%s\" in turbo.json", taskName, taskID)
+	return nil, &MissingTaskError{TaskID: taskID}
 }
 
-func (e *Engine) generateTaskGraph(pkgs []string, taskNames []string, tasksOnly bool) error {
-	traversalQueue := []string{}
+func (e *Engine) generateTaskGraph(pkgs []string, taskNames []string, hookTaskIDs []string, packageInfos map[interface{}]*fs.PackageJSON) error {
+	traversalQueue := append([]string{}, hookTaskIDs...)
 	for _, pkg := range pkgs {
 		isRootPkg := pkg == util.RootPkgName
 		for _, taskName := range taskNames {
@@ -127,6 +1433,7 @@ func (e *Engine) generateTaskGraph(pkgs []string, taskNames []string, tasksOnly
 	}
 
 	visited := make(util.Set)
+	emptyTags := make(util.Set)
 
 	for len(traversalQueue) > 0 {
 		taskID := traversalQueue[0]
@@ -148,23 +1455,6 @@ func (e *Engine) generateTaskGraph(pkgs []string, taskNames []string, tasksOnly
 
 		visited.Add(taskID)
 
-		// Filter down the tasks if there's a filter in place
-		// https: //turbo.build/repo/docs/reference/command-line-reference#--only
-		if tasksOnly {
-			task.Deps = task.Deps.Filter(func(d interface{}) bool {
-				for _, target := range taskNames {
-					return fmt.Sprintf("%v", d) == target
-				}
-				return false
-			})
-			task.TopoDeps = task.TopoDeps.Filter(func(d interface{}) bool {
-				for _, target := range taskNames {
-					return fmt.Sprintf("%v", d) == target
-				}
-				return false
-			})
-		}
-
 		toTaskID := taskID
 
 		// hasTopoDeps will be true if the task depends on any tasks from dependency packages
@@ -183,27 +1473,48 @@ func (e *Engine) generateTaskGraph(pkgs []string, taskNames []string, tasksOnly
 			hasPackageTaskDeps = true
 		}
 
+		// A dependency on a pattern of task names adds no edges in a workspace
+		// without a matching script, so track whether any were added
+		addedDeps := false
+
 		if hasTopoDeps {
 			depPkgs := e.TopologicGraph.DownEdges(pkg)
 			for _, from := range task.TopoDeps.UnsafeListOfStrings() {
 				// add task dep from all the package deps within repo
 				for depPkg := range depPkgs {
-					fromTaskID := util.GetTaskId(depPkg, from)
-					e.TaskGraph.Add(fromTaskID)
-					e.TaskGraph.Add(toTaskID)
-					e.TaskGraph.Connect(dag.BasicEdge(toTaskID, fromTaskID))
-					traversalQueue = append(traversalQueue, fromTaskID)
+					depPkgName := dag.VertexName(depPkg)
+					// A dependency on a task in specific packages (e.g. `^@acme/ui-*#build`)
+					// only applies to the matching dependency packages
+					fromTask := from
+					if util.IsPackageTask(from) {
+						var pkgPattern string
+						pkgPattern, fromTask = util.GetPackageTaskFromId(from)
+						if !matchesPackage(pkgPattern, depPkgName) {
+							continue
+						}
+					}
+					for _, fromTaskName := range expandScriptPattern(packageInfos, depPkgName, fromTask, "") {
+						fromTaskID := util.GetTaskId(depPkg, fromTaskName)
+						e.TaskGraph.Add(fromTaskID)
+						e.TaskGraph.Add(toTaskID)
+						e.TaskGraph.Connect(dag.BasicEdge(toTaskID, fromTaskID))
+						traversalQueue = append(traversalQueue, fromTaskID)
+						addedDeps = true
+					}
 				}
 			}
 		}
 
 		if hasDeps {
 			for _, from := range task.Deps.UnsafeListOfStrings() {
-				fromTaskID := util.GetTaskId(pkg, from)
-				e.TaskGraph.Add(fromTaskID)
-				e.TaskGraph.Add(toTaskID)
-				e.TaskGraph.Connect(dag.BasicEdge(toTaskID, fromTaskID))
-				traversalQueue = append(traversalQueue, fromTaskID)
+				for _, fromTaskName := range expandScriptPattern(packageInfos, pkg, from, taskName) {
+					fromTaskID := util.GetTaskId(pkg, fromTaskName)
+					e.TaskGraph.Add(fromTaskID)
+					e.TaskGraph.Add(toTaskID)
+					e.TaskGraph.Connect(dag.BasicEdge(toTaskID, fromTaskID))
+					traversalQueue = append(traversalQueue, fromTaskID)
+					addedDeps = true
+				}
 			}
 		}
 
@@ -218,16 +1529,80 @@ func (e *Engine) generateTaskGraph(pkgs []string, taskNames []string, tasksOnly
 			}
 		}
 
-		if !hasDeps && !hasTopoDeps && !hasPackageTaskDeps {
+		// hasTagDeps will be true if the task depends on any tasks carrying a tag
+		// E.g. `release: { dependsOnTag: [ci-critical] }`
+		hasTagDeps := false
+		for _, tag := range task.DependsOnTag {
+			taggedTaskIDs := e.taggedTasks(pkgs, tag)
+			if len(taggedTaskIDs) == 0 {
+				emptyTags.Add(tag)
+			}
+			for _, fromTaskID := range taggedTaskIDs {
+				if fromTaskID == toTaskID {
+					continue
+				}
+				hasTagDeps = true
+				e.TaskGraph.Add(fromTaskID)
+				e.TaskGraph.Add(toTaskID)
+				e.TaskGraph.Connect(dag.BasicEdge(toTaskID, fromTaskID))
+				traversalQueue = append(traversalQueue, fromTaskID)
+			}
+		}
+
+		if !addedDeps && !hasPackageTaskDeps && !hasTagDeps {
 			e.TaskGraph.Add(ROOT_NODE_NAME)
 			e.TaskGraph.Add(toTaskID)
 			e.TaskGraph.Connect(dag.BasicEdge(toTaskID, ROOT_NODE_NAME))
 		}
 	}
 
+	for _, tag := range emptyTags.UnsafeListOfStrings() {
+		e.warnings = append(e.warnings, fmt.Sprintf("no tasks are tagged %q, but it is used in dependsOnTag", tag))
+	}
+	sort.Strings(e.warnings)
+
 	return nil
 }
 
+// taggedTasks returns the ids of the tasks in the given packages whose task
+// definition has the given tag
+func (e *Engine) taggedTasks(pkgs []string, tag string) []string {
+	taskNames := make(util.Set)
+	for name := range e.tasks {
+		if util.IsPackageTask(name) {
+			_, name = util.GetPackageTaskFromId(name)
+		}
+		taskNames.Add(name)
+	}
+
+	taskIDs := []string{}
+	for _, pkg := range pkgs {
+		for _, taskName := range taskNames.UnsafeListOfStrings() {
+			if pkg == util.RootPkgName && !e.rootEnabledTasks.Includes(taskName) {
+				continue
+			}
+			taskID := util.GetTaskId(pkg, taskName)
+			task, err := e.getTaskDefinition(pkg, taskName, taskID)
+			if err != nil {
+				continue
+			}
+			for _, t := range task.Tags {
+				if t == tag {
+					taskIDs = append(taskIDs, taskID)
+					break
+				}
+			}
+		}
+	}
+	sort.Strings(taskIDs)
+	return taskIDs
+}
+
+// Warnings returns the non-fatal issues found while preparing the TaskGraph
+func (e *Engine) Warnings() []string {
+	return e.warnings
+}
+
 // AddTask adds a task to the Engine so it can be looked up later.
 func (e *Engine) AddTask(task *Task) *Engine {
 	// If a root task is added, mark the task name as eligible for
@@ -238,21 +1613,77 @@ func (e *Engine) AddTask(task *Task) *Engine {
 			e.rootEnabledTasks.Add(taskName)
 		}
 	}
-	e.Tasks[task.Name] = task
+	e.tasks[task.Name] = task
 	return e
 }
 
+// Tasks returns a copy of each task added to the engine, sorted by name. Changing
+// the copies doesn't change the engine.
+func (e *Engine) Tasks() []*Task {
+	tasks := make([]*Task, 0, len(e.tasks))
+	for _, task := range e.tasks {
+		tasks = append(tasks, task.clone())
+	}
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].Name < tasks[j].Name })
+	return tasks
+}
+
+// TaskByID returns a copy of the task that configures the given task id (e.g.
+// web#build) or task name. A task id falls back to the task added for its task
+// name. Changing the copy doesn't change the engine.
+func (e *Engine) TaskByID(taskID string) (*Task, bool) {
+	if task, ok := e.tasks[taskID]; ok {
+		return task.clone(), true
+	}
+	if !util.IsPackageTask(taskID) {
+		return nil, false
+	}
+	pkg, taskName := util.GetPackageTaskFromId(taskID)
+	task, err := e.getTaskDefinition(pkg, taskName, taskID)
+	if err != nil {
+		return nil, false
+	}
+	return task.clone(), true
+}
+
+// clone returns a copy of the task that shares none of its sets, slices or readiness probe
+func (t *Task) clone() *Task {
+	clone := *t
+	if t.Deps != nil {
+		clone.Deps = t.Deps.Copy()
+	}
+	if t.TopoDeps != nil {
+		clone.TopoDeps = t.TopoDeps.Copy()
+	}
+	clone.Outputs = copyStrings(t.Outputs)
+	clone.Inputs = copyStrings(t.Inputs)
+	clone.StartsAfter = copyStrings(t.StartsAfter)
+	clone.Tags = copyStrings(t.Tags)
+	clone.DependsOnTag = copyStrings(t.DependsOnTag)
+	clone.DotEnv = copyStrings(t.DotEnv)
+	if t.ReadinessProbe != nil {
+		probe := *t.ReadinessProbe
+		clone.ReadinessProbe = &probe
+	}
+	return &clone
+}
+
+// copyStrings returns a copy of the given slice, which is nil if it is nil
+func copyStrings(s []string) []string {
+	if s == nil {
+		return nil
+	}
+	return append([]string{}, s...)
+}
+
 // AddDep adds tuples from+to task ID combos in tuple format so they can be looked up later.
 func (e *Engine) AddDep(fromTaskID string, toTaskID string) error {
 	fromPkg, _ := util.GetPackageTaskFromId(fromTaskID)
-	if fromPkg != ROOT_NODE_NAME && fromPkg != util.RootPkgName && !e.TopologicGraph.HasVertex(fromPkg) {
+	// Dependencies by path or by pattern are validated once they are resolved in Prepare
+	if !strings.HasPrefix(fromPkg, pathDependencyPrefix) && !isPackagePattern(fromPkg) && fromPkg != ROOT_NODE_NAME && fromPkg != util.RootPkgName && !e.TopologicGraph.HasVertex(fromPkg) {
 		return fmt.Errorf("found reference to unknown package: %v in task %v", fromPkg, fromTaskID)
 	}
 
-	if _, ok := e.PackageTaskDeps[fromTaskID]; !ok {
-		e.PackageTaskDeps[toTaskID] = []string{}
-	}
-
 	e.PackageTaskDeps[toTaskID] = append(e.PackageTaskDeps[toTaskID], fromTaskID)
 
 	return nil
@@ -1,10 +1,16 @@
 package core
 
 import (
+	"errors"
 	"fmt"
+	"sort"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/turbopath"
 	"github.com/vercel/turbo/cli/internal/util"
 	"gotest.tools/v3/assert"
 
@@ -16,6 +22,31 @@ func testVisitor(taskID string) error {
 	return nil
 }
 
+// newTestEngine returns an Engine for the workspace graph with the tasks added.
+// Dependencies that a task leaves unset default to none.
+func newTestEngine(graph *dag.AcyclicGraph, tasks []*Task) *Engine {
+	p := NewEngine(graph)
+	for _, task := range tasks {
+		if task.Deps == nil {
+			task.Deps = make(util.Set)
+		}
+		if task.TopoDeps == nil {
+			task.TopoDeps = make(util.Set)
+		}
+		p.AddTask(task)
+	}
+	return p
+}
+
+// prepareTestEngine returns an Engine for the workspace graph with the tasks
+// added, after preparing its TaskGraph with the options
+func prepareTestEngine(t *testing.T, graph *dag.AcyclicGraph, tasks []*Task, options *EngineBuildingOptions) *Engine {
+	t.Helper()
+	p := newTestEngine(graph, tasks)
+	assert.NilError(t, p.Prepare(options), "Prepare")
+	return p
+}
+
 func TestEngineDefault(t *testing.T) {
 	var g dag.AcyclicGraph
 	g.Add("a")
@@ -47,11 +78,11 @@ func TestEngineDefault(t *testing.T) {
 		Deps: deps,
 	})
 
-	if _, ok := p.Tasks["build"]; !ok {
+	if _, ok := p.tasks["build"]; !ok {
 		t.Fatal("AddTask is not adding tasks (build)")
 	}
 
-	if _, ok := p.Tasks["test"]; !ok {
+	if _, ok := p.tasks["test"]; !ok {
 		t.Fatal("AddTask is not adding tasks (test)")
 	}
 
@@ -220,11 +251,64 @@ func TestRunWithNoTasksFound(t *testing.T) {
 	dependOnBuild.Add("build")
 
 	err := p.Prepare(&EngineBuildingOptions{
-		Packages:  []string{"app", "lib"},
-		TaskNames: []string{"build"},
+		Packages:          []string{"app", "lib"},
+		TaskNames:         []string{"build"},
+		AllowMissingTasks: true,
 	})
 	// should not fail because we have no tasks in the engine
 	assert.NilError(t, err, "Prepare")
+	assert.DeepEqual(t, p.Warnings(), []string{"no workspace in scope implements build, so it is skipped"})
+}
+
+func TestMissingTasks(t *testing.T) {
+	graph := &dag.AcyclicGraph{}
+	graph.Add("app")
+	graph.Add("docs")
+
+	newEngine := func() *Engine {
+		p := NewEngine(graph)
+		p.AddTask(&Task{Name: "build", Deps: make(util.Set), TopoDeps: make(util.Set)})
+		p.AddTask(&Task{Name: "test", Deps: make(util.Set), TopoDeps: make(util.Set)})
+		// Only docs implements deploy, and it isn't in scope
+		p.AddTask(&Task{Name: "docs#deploy", Deps: make(util.Set), TopoDeps: make(util.Set)})
+		p.AddTask(&Task{Name: "//#release", Deps: make(util.Set), TopoDeps: make(util.Set)})
+		return p
+	}
+	taskNames := []string{"build", "test", "deploy", "release", "maybe-missing"}
+
+	// Every missing task is listed
+	p := newEngine()
+	err := p.Prepare(&EngineBuildingOptions{
+		Packages:  []string{"app"},
+		TaskNames: taskNames,
+	})
+	assert.Error(t, err, "no workspace in scope implements deploy, maybe-missing, release")
+
+	// The tasks that are implemented run, and the missing ones are skipped
+	p = newEngine()
+	err = p.Prepare(&EngineBuildingOptions{
+		Packages:          []string{"app"},
+		TaskNames:         taskNames,
+		AllowMissingTasks: true,
+	})
+	assert.NilError(t, err, "Prepare")
+	assert.DeepEqual(t, p.Warnings(), []string{
+		"no workspace in scope implements deploy, so it is skipped",
+		"no workspace in scope implements maybe-missing, so it is skipped",
+		"no workspace in scope implements release, so it is skipped",
+	})
+	order, err := p.ExecutionOrder()
+	assert.NilError(t, err)
+	assert.DeepEqual(t, order, [][]string{{"app#build", "app#test"}})
+
+	// Tasks implemented by a package in scope aren't missing
+	p = newEngine()
+	err = p.Prepare(&EngineBuildingOptions{
+		Packages:  []string{"app", "docs", util.RootPkgName},
+		TaskNames: []string{"deploy", "release"},
+	})
+	assert.NilError(t, err, "Prepare")
+	assert.Equal(t, len(p.Warnings()), 0)
 }
 
 func TestIncludeRootTasks(t *testing.T) {
@@ -415,11 +499,11 @@ func TestEngineTasksOnly(t *testing.T) {
 		Name: "prepare",
 	})
 
-	if _, ok := p.Tasks["build"]; !ok {
+	if _, ok := p.tasks["build"]; !ok {
 		t.Fatal("AddTask is not adding tasks (build)")
 	}
 
-	if _, ok := p.Tasks["test"]; !ok {
+	if _, ok := p.tasks["test"]; !ok {
 		t.Fatal("AddTask is not adding tasks (test)")
 	}
 
@@ -448,6 +532,46 @@ func TestEngineTasksOnly(t *testing.T) {
 	}
 }
 
+func TestEngineTasksOnlyAssumesDependenciesBuilt(t *testing.T) {
+	graph := &dag.AcyclicGraph{}
+	graph.Add("workspace-a")
+	graph.Add("workspace-b")
+	graph.Add("workspace-c")
+	graph.Connect(dag.BasicEdge("workspace-a", "workspace-c"))
+	graph.Connect(dag.BasicEdge("workspace-c", "workspace-b"))
+
+	p := NewEngine(graph)
+	topoDeps := make(util.Set)
+	topoDeps.Add("build")
+	p.AddTask(&Task{Name: "build", TopoDeps: topoDeps, Deps: make(util.Set)})
+
+	err := p.Prepare(&EngineBuildingOptions{
+		Packages:  []string{"workspace-a"},
+		TaskNames: []string{"build"},
+		TasksOnly: true,
+	})
+	assert.NilError(t, err, "Prepare")
+
+	var executed []string
+	var mu sync.Mutex
+	errs := p.Execute(func(taskID string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		executed = append(executed, taskID)
+		return nil
+	}, EngineExecutionOptions{Concurrency: 10})
+	assert.Equal(t, len(errs), 0)
+	assert.DeepEqual(t, executed, []string{"workspace-a#build"})
+
+	// The dependencies are still part of the hash of workspace-a#build
+	assert.DeepEqual(t, p.AssumedBuilt(), []string{"workspace-b#build", "workspace-c#build"})
+	deps := []string{}
+	for _, dep := range p.HashGraph().DownEdges("workspace-a#build") {
+		deps = append(deps, dag.VertexName(dep))
+	}
+	assert.DeepEqual(t, deps, []string{"workspace-c#build"})
+}
+
 const leafStringAll = `
 ___ROOT___
 a#build
@@ -479,3 +603,1600 @@ b#test
 c#test
   ___ROOT___
 `
+
+func TestEngineShell(t *testing.T) {
+	oldLookPath := lookPath
+	defer func() { lookPath = oldLookPath }()
+	lookPath = func(file string) (string, error) {
+		if file == "bash" || file == "zsh" {
+			return "/bin/" + file, nil
+		}
+		return "", fmt.Errorf("%v not found", file)
+	}
+
+	graph := &dag.AcyclicGraph{}
+	graph.Add("app1")
+	graph.Add("libA")
+	graph.Connect(dag.BasicEdge("app1", "libA"))
+
+	p := NewEngine(graph)
+	dependOnBuild := make(util.Set)
+	dependOnBuild.Add("build")
+	p.AddTask(&Task{
+		Name:     "build",
+		TopoDeps: dependOnBuild,
+		Deps:     make(util.Set),
+	})
+	p.AddTask(&Task{
+		Name:     "app1#build",
+		TopoDeps: dependOnBuild,
+		Deps:     make(util.Set),
+		Shell:    "zsh",
+	})
+	err := p.Prepare(&EngineBuildingOptions{
+		Packages:  []string{"app1"},
+		TaskNames: []string{"build"},
+		Shell:     "bash",
+	})
+	assert.NilError(t, err, "Prepare")
+	assert.Equal(t, p.TaskShell("libA#build"), "bash")
+	assert.Equal(t, p.TaskShell("app1#build"), "zsh")
+}
+
+func TestEngineDefaultShell(t *testing.T) {
+	graph := &dag.AcyclicGraph{}
+	graph.Add("app1")
+
+	p := NewEngine(graph)
+	p.AddTask(&Task{
+		Name:     "build",
+		TopoDeps: make(util.Set),
+		Deps:     make(util.Set),
+	})
+	err := p.Prepare(&EngineBuildingOptions{
+		Packages:  []string{"app1"},
+		TaskNames: []string{"build"},
+	})
+	assert.NilError(t, err, "Prepare")
+	assert.Equal(t, p.TaskShell("app1#build"), "")
+}
+
+func TestEngineMissingShell(t *testing.T) {
+	oldLookPath := lookPath
+	defer func() { lookPath = oldLookPath }()
+	lookPath = func(file string) (string, error) {
+		return "", fmt.Errorf("%v not found", file)
+	}
+
+	graph := &dag.AcyclicGraph{}
+	graph.Add("app1")
+
+	p := NewEngine(graph)
+	p.AddTask(&Task{
+		Name:     "build",
+		TopoDeps: make(util.Set),
+		Deps:     make(util.Set),
+		Shell:    "not-a-shell",
+	})
+	err := p.Prepare(&EngineBuildingOptions{
+		Packages:  []string{"app1"},
+		TaskNames: []string{"build"},
+	})
+	assert.ErrorContains(t, err, "cannot find shell \"not-a-shell\" for task app1#build")
+}
+
+func TestEngineShellWithArguments(t *testing.T) {
+	oldLookPath := lookPath
+	defer func() { lookPath = oldLookPath }()
+	lookPath = func(file string) (string, error) {
+		if file == "bash" {
+			return "/bin/bash", nil
+		}
+		return "", fmt.Errorf("%v not found", file)
+	}
+
+	graph := &dag.AcyclicGraph{}
+	graph.Add("app1")
+
+	p := NewEngine(graph)
+	p.AddTask(&Task{
+		Name:     "build",
+		TopoDeps: make(util.Set),
+		Deps:     make(util.Set),
+		Shell:    "bash -eo pipefail",
+	})
+	err := p.Prepare(&EngineBuildingOptions{
+		Packages:  []string{"app1"},
+		TaskNames: []string{"build"},
+	})
+	assert.ErrorContains(t, err, "shell \"bash -eo pipefail\" for task app1#build has arguments")
+}
+
+func TestInputsFromDependencyOutputs(t *testing.T) {
+	graph := &dag.AcyclicGraph{}
+	graph.Add("app1")
+	graph.Add("libA")
+	graph.Add("libB")
+	graph.Connect(dag.BasicEdge("app1", "libA"))
+	graph.Connect(dag.BasicEdge("app1", "libB"))
+
+	p := NewEngine(graph)
+	dependOnBuild := make(util.Set)
+	dependOnBuild.Add("build")
+	p.AddTask(&Task{
+		Name:     "build",
+		TopoDeps: make(util.Set),
+		Deps:     make(util.Set),
+		Outputs:  []string{"dist/**"},
+	})
+	p.AddTask(&Task{
+		Name:     "libB#build",
+		TopoDeps: make(util.Set),
+		Deps:     make(util.Set),
+	})
+	p.AddTask(&Task{
+		Name:                 "lint-all",
+		TopoDeps:             dependOnBuild,
+		Deps:                 make(util.Set),
+		InputsFromDepOutputs: true,
+	})
+	err := p.Prepare(&EngineBuildingOptions{
+		Packages:  []string{"app1"},
+		TaskNames: []string{"lint-all"},
+	})
+	assert.NilError(t, err, "Prepare")
+	// libB#build doesn't declare any outputs, so it doesn't contribute
+	assert.DeepEqual(t, p.DependencyOutputs("app1#lint-all"), map[string][]string{
+		"libA#build": {"dist/**"},
+	})
+	assert.Assert(t, p.DependencyOutputs("libA#build") == nil)
+}
+
+func TestInputsFromDependencyOutputsWithoutOutputs(t *testing.T) {
+	graph := &dag.AcyclicGraph{}
+	graph.Add("app1")
+	graph.Add("libA")
+	graph.Connect(dag.BasicEdge("app1", "libA"))
+
+	p := NewEngine(graph)
+	dependOnBuild := make(util.Set)
+	dependOnBuild.Add("build")
+	p.AddTask(&Task{
+		Name:     "build",
+		TopoDeps: make(util.Set),
+		Deps:     make(util.Set),
+	})
+	p.AddTask(&Task{
+		Name:                 "lint-all",
+		TopoDeps:             dependOnBuild,
+		Deps:                 make(util.Set),
+		InputsFromDepOutputs: true,
+	})
+	err := p.Prepare(&EngineBuildingOptions{
+		Packages:  []string{"app1"},
+		TaskNames: []string{"lint-all"},
+	})
+	assert.ErrorContains(t, err, "app1#lint-all uses the outputs of its dependencies as inputs, but none of its dependencies declare outputs")
+}
+
+func TestEngineBreakpoint(t *testing.T) {
+	graph := &dag.AcyclicGraph{}
+	graph.Add("app1")
+	graph.Add("libA")
+	graph.Connect(dag.BasicEdge("app1", "libA"))
+
+	p := NewEngine(graph)
+	dependOnBuild := make(util.Set)
+	dependOnBuild.Add("build")
+	p.AddTask(&Task{
+		Name:     "build",
+		TopoDeps: dependOnBuild,
+		Deps:     make(util.Set),
+	})
+	err := p.Prepare(&EngineBuildingOptions{
+		Packages:        []string{"app1"},
+		TaskNames:       []string{"build"},
+		BreakpointTasks: []string{"app1#build"},
+	})
+	assert.NilError(t, err, "Prepare")
+
+	var order []string
+	errs := p.Execute(func(taskID string) error {
+		order = append(order, taskID)
+		return nil
+	}, EngineExecutionOptions{
+		Concurrency: 10,
+		BreakpointHandler: func(taskID string) error {
+			order = append(order, "breakpoint:"+taskID)
+			return nil
+		},
+	})
+	assert.Equal(t, len(errs), 0)
+	assert.DeepEqual(t, order, []string{"libA#build", "breakpoint:app1#build", "app1#build"})
+
+	// An error from the handler fails the task without running it
+	order = nil
+	errs = p.Execute(func(taskID string) error {
+		order = append(order, taskID)
+		return nil
+	}, EngineExecutionOptions{
+		Concurrency: 10,
+		BreakpointHandler: func(taskID string) error {
+			return errors.New("aborted")
+		},
+	})
+	assert.Equal(t, len(errs), 1)
+	assert.ErrorContains(t, errs[0], "aborted")
+	assert.DeepEqual(t, order, []string{"libA#build"})
+}
+
+func TestEngineBreakpointPauseAll(t *testing.T) {
+	graph := &dag.AcyclicGraph{}
+	graph.Add("app1")
+	graph.Add("libA")
+
+	p := NewEngine(graph)
+	dependOnPrepare := make(util.Set)
+	dependOnPrepare.Add("prepare")
+	p.AddTask(&Task{
+		Name:     "prepare",
+		TopoDeps: make(util.Set),
+		Deps:     make(util.Set),
+	})
+	p.AddTask(&Task{
+		Name:     "build",
+		TopoDeps: make(util.Set),
+		Deps:     dependOnPrepare,
+	})
+	err := p.Prepare(&EngineBuildingOptions{
+		Packages:        []string{"app1", "libA"},
+		TaskNames:       []string{"build"},
+		BreakpointTasks: []string{"app1#prepare"},
+	})
+	assert.NilError(t, err, "Prepare")
+
+	var mu sync.Mutex
+	var order []string
+	record := func(entry string) {
+		mu.Lock()
+		defer mu.Unlock()
+		order = append(order, entry)
+	}
+	breakpointReached := make(chan struct{})
+	errs := p.Execute(func(taskID string) error {
+		if taskID == "libA#prepare" {
+			// let libA#build become ready while the breakpoint is being handled
+			<-breakpointReached
+		}
+		record(taskID)
+		return nil
+	}, EngineExecutionOptions{
+		Concurrency:          10,
+		PauseAllOnBreakpoint: true,
+		BreakpointHandler: func(taskID string) error {
+			close(breakpointReached)
+			time.Sleep(50 * time.Millisecond)
+			record("resume")
+			return nil
+		},
+	})
+	assert.Equal(t, len(errs), 0)
+	resumeIndex := -1
+	for i, entry := range order {
+		if entry == "resume" {
+			resumeIndex = i
+		}
+		if entry == "libA#build" {
+			assert.Assert(t, resumeIndex != -1, "libA#build started while execution was paused")
+		}
+	}
+}
+
+func setupQuorumEngine(t *testing.T, quorum int) *Engine {
+	graph := &dag.AcyclicGraph{}
+	graph.Add("app1")
+	graph.Add("libA")
+	graph.Add("libB")
+	graph.Add("libC")
+	graph.Connect(dag.BasicEdge("app1", "libA"))
+	graph.Connect(dag.BasicEdge("app1", "libB"))
+	graph.Connect(dag.BasicEdge("app1", "libC"))
+
+	tasks := []*Task{
+		{Name: "build"},
+		{Name: "app1#aggregate", TopoDeps: util.SetFromStrings([]string{"build"}), DepQuorum: quorum},
+	}
+	return prepareTestEngine(t, graph, tasks, &EngineBuildingOptions{
+		Packages:  []string{"app1"},
+		TaskNames: []string{"aggregate"},
+	})
+}
+
+func failingVisitor(failing []string, visited *sync.Map) Visitor {
+	return func(taskID string) error {
+		visited.Store(taskID, true)
+		for _, f := range failing {
+			if f == taskID {
+				return fmt.Errorf("%v failed", taskID)
+			}
+		}
+		return nil
+	}
+}
+
+func TestDependencyQuorum(t *testing.T) {
+	p := setupQuorumEngine(t, 2)
+
+	visited := &sync.Map{}
+	errs := p.Execute(failingVisitor([]string{"libC#build"}, visited), EngineExecutionOptions{Concurrency: 10, ContinueOnError: true})
+	assert.Equal(t, len(errs), 1)
+	assert.ErrorContains(t, errs[0], "libC#build failed")
+	_, ran := visited.Load("app1#aggregate")
+	assert.Assert(t, ran, "expected app1#aggregate to run with 2 of 3 dependencies succeeding")
+	assert.DeepEqual(t, p.FailedDependencies("app1#aggregate"), []string{"libC#build"})
+
+	visited = &sync.Map{}
+	errs = p.Execute(failingVisitor([]string{"libB#build", "libC#build"}, visited), EngineExecutionOptions{Concurrency: 10, ContinueOnError: true})
+	assert.Equal(t, len(errs), 3)
+	_, ran = visited.Load("app1#aggregate")
+	assert.Assert(t, !ran, "expected app1#aggregate not to run with only 1 of 3 dependencies succeeding")
+	found := false
+	for _, err := range errs {
+		if strings.Contains(err.Error(), "app1#aggregate requires 2 of its dependencies to succeed, but only 1 did") {
+			found = true
+		}
+	}
+	assert.Assert(t, found, "expected a quorum error, got %v", errs)
+}
+
+func TestDependencyQuorumDefault(t *testing.T) {
+	p := setupQuorumEngine(t, 0)
+
+	visited := &sync.Map{}
+	errs := p.Execute(failingVisitor([]string{"libC#build"}, visited), EngineExecutionOptions{Concurrency: 10})
+	// The skipped task does not report an error of its own
+	assert.Equal(t, len(errs), 1)
+	_, ran := visited.Load("app1#aggregate")
+	assert.Assert(t, !ran, "expected app1#aggregate not to run with a failed dependency")
+}
+
+func TestDependencyQuorumTooLarge(t *testing.T) {
+	graph := &dag.AcyclicGraph{}
+	graph.Add("app1")
+	graph.Add("libA")
+	graph.Connect(dag.BasicEdge("app1", "libA"))
+
+	dependOnBuild := util.SetFromStrings([]string{"build"})
+	p := newTestEngine(graph, []*Task{
+		{Name: "build", TopoDeps: dependOnBuild},
+		{Name: "app1#build", TopoDeps: dependOnBuild, DepQuorum: 2},
+	})
+	err := p.Prepare(&EngineBuildingOptions{
+		Packages:  []string{"app1"},
+		TaskNames: []string{"build"},
+	})
+	assert.ErrorContains(t, err, "app1#build requires 2 of its dependencies to succeed, but only has 1")
+}
+
+func setupStartOrderingEngine(t *testing.T, startsAfter []string) *Engine {
+	graph := &dag.AcyclicGraph{}
+	graph.Add("api")
+	graph.Add("web")
+
+	tasks := []*Task{
+		{Name: "dev", Persistent: true},
+		{Name: "web#dev", Persistent: true, StartsAfter: startsAfter},
+	}
+	return prepareTestEngine(t, graph, tasks, &EngineBuildingOptions{
+		Packages:  []string{"api", "web"},
+		TaskNames: []string{"dev"},
+	})
+}
+
+func TestStartsAfter(t *testing.T) {
+	p := setupStartOrderingEngine(t, []string{"api#dev"})
+
+	var mu sync.Mutex
+	var order []string
+	record := func(entry string) {
+		mu.Lock()
+		defer mu.Unlock()
+		order = append(order, entry)
+	}
+	webStarted := make(chan struct{})
+	errs := p.Execute(func(taskID string) error {
+		record(taskID)
+		if taskID == "api#dev" {
+			// Give web#dev a chance to start early if it isn't waiting on us
+			time.Sleep(20 * time.Millisecond)
+			record("api#dev ready")
+			p.MarkReady(taskID)
+			// Keep running, like a dev server, until web#dev has started
+			<-webStarted
+		} else {
+			close(webStarted)
+		}
+		return nil
+	}, EngineExecutionOptions{Concurrency: 10})
+	assert.Equal(t, len(errs), 0)
+	assert.DeepEqual(t, order, []string{"api#dev", "api#dev ready", "web#dev"})
+}
+
+func TestStartsAfterFailure(t *testing.T) {
+	p := setupStartOrderingEngine(t, []string{"api#dev"})
+
+	visited := &sync.Map{}
+	errs := p.Execute(failingVisitor([]string{"api#dev"}, visited), EngineExecutionOptions{Concurrency: 10})
+	assert.Equal(t, len(errs), 2)
+	_, ran := visited.Load("web#dev")
+	assert.Assert(t, !ran, "expected web#dev not to start after api#dev failed")
+	found := false
+	for _, err := range errs {
+		if strings.Contains(err.Error(), "cannot start web#dev: api#dev exited before becoming ready") {
+			found = true
+		}
+	}
+	assert.Assert(t, found, "expected a start ordering error, got %v", errs)
+}
+
+func TestStartsAfterValidation(t *testing.T) {
+	graph := &dag.AcyclicGraph{}
+	graph.Add("api")
+	graph.Add("web")
+
+	p := newTestEngine(graph, []*Task{
+		{Name: "dev", Persistent: true},
+		{Name: "api#dev", Persistent: true, StartsAfter: []string{"web#dev"}},
+		{Name: "web#dev", Persistent: true, StartsAfter: []string{"api#dev"}},
+	})
+	err := p.Prepare(&EngineBuildingOptions{
+		Packages:  []string{"api", "web"},
+		TaskNames: []string{"dev"},
+	})
+	assert.ErrorContains(t, err, "Invalid start ordering, found a cycle: api#dev -> web#dev -> api#dev")
+
+	p = newTestEngine(graph, []*Task{
+		{Name: "build"},
+		{Name: "dev", Persistent: true, StartsAfter: []string{"build"}},
+	})
+	err = p.Prepare(&EngineBuildingOptions{
+		Packages:  []string{"api"},
+		TaskNames: []string{"dev", "build"},
+	})
+	assert.ErrorContains(t, err, "api#dev starts after api#build, but api#build is not a persistent task")
+}
+
+func TestValidatePersistentDependencies(t *testing.T) {
+	graph := &dag.AcyclicGraph{}
+	graph.Add("app1")
+	graph.Add("libA")
+	graph.Add("libB")
+	graph.Connect(dag.BasicEdge("app1", "libA"))
+	graph.Connect(dag.BasicEdge("app1", "libB"))
+	graph.Connect(dag.BasicEdge("libB", "libA"))
+
+	tasks := []*Task{{Name: "dev", TopoDeps: util.SetFromStrings([]string{"dev"}), Persistent: true}}
+	p := prepareTestEngine(t, graph, tasks, &EngineBuildingOptions{
+		Packages:  []string{"app1"},
+		TaskNames: []string{"dev"},
+	})
+
+	// Every violation is reported, sorted by the dependent task
+	err := p.ValidatePersistentDependencies(func(taskID string) bool { return true })
+	var violations PersistentDependencyErrors
+	assert.Assert(t, errors.As(err, &violations), "%v", err)
+	assert.DeepEqual(t, violations, PersistentDependencyErrors{
+		{Dependent: "app1#dev", Persistent: "libA#dev"},
+		{Dependent: "app1#dev", Persistent: "libB#dev"},
+		{Dependent: "libB#dev", Persistent: "libA#dev"},
+	})
+	assert.Error(t, err, "\"libA#dev\" is a persistent task, \"app1#dev\" cannot depend on it\n"+
+		"\"libB#dev\" is a persistent task, \"app1#dev\" cannot depend on it\n"+
+		"\"libA#dev\" is a persistent task, \"libB#dev\" cannot depend on it")
+	// The first violation can be found on its own too
+	var violation *PersistentDependencyError
+	assert.Assert(t, errors.As(err, &violation), "%v", err)
+	assert.DeepEqual(t, *violation, PersistentDependencyError{Dependent: "app1#dev", Persistent: "libA#dev"})
+
+	// Persistent tasks that aren't defined in their package are never run
+	err = p.ValidatePersistentDependencies(func(taskID string) bool { return taskID != "libA#dev" })
+	assert.Assert(t, errors.As(err, &violation), "%v", err)
+	assert.DeepEqual(t, *violation, PersistentDependencyError{Dependent: "app1#dev", Persistent: "libB#dev"})
+	err = p.ValidatePersistentDependencies(func(taskID string) bool { return taskID == "app1#dev" })
+	assert.NilError(t, err)
+}
+
+func TestAllowDependentsTopological(t *testing.T) {
+	graph := &dag.AcyclicGraph{}
+	graph.Add("app1")
+	graph.Add("libA")
+	graph.Connect(dag.BasicEdge("app1", "libA"))
+
+	p := NewEngine(graph)
+	dependOnDev := make(util.Set)
+	dependOnDev.Add("dev")
+	p.AddTask(&Task{
+		Name:            "dev",
+		TopoDeps:        dependOnDev,
+		Deps:            make(util.Set),
+		Persistent:      true,
+		AllowDependents: true,
+	})
+	err := p.Prepare(&EngineBuildingOptions{
+		Packages:  []string{"app1"},
+		TaskNames: []string{"dev"},
+	})
+	assert.NilError(t, err, "Prepare")
+
+	err = p.ValidatePersistentDependencies(func(taskID string) bool { return true })
+	assert.NilError(t, err)
+	// app1#dev waits for libA#dev to be ready, rather than to exit
+	assert.DeepEqual(t, p.StreamingDependencies("app1#dev"), []string{"libA#dev"})
+	assert.Assert(t, !p.TaskGraph.DownEdges("app1#dev").Include("libA#dev"))
+
+	dependentStarted := make(chan struct{})
+	errs := p.Execute(func(taskID string) error {
+		if taskID == "libA#dev" {
+			p.MarkReady(taskID)
+			select {
+			case <-dependentStarted:
+				return nil
+			case <-time.After(5 * time.Second):
+				return errors.New("app1#dev did not start while libA#dev was running")
+			}
+		}
+		close(dependentStarted)
+		return nil
+	}, EngineExecutionOptions{Concurrency: 10})
+	assert.Equal(t, len(errs), 0, "%v", errs)
+}
+
+func TestAllowDependentsCrossWorkspace(t *testing.T) {
+	graph := &dag.AcyclicGraph{}
+	graph.Add("app1")
+	graph.Add("libA")
+
+	p := NewEngine(graph)
+	p.AddTask(&Task{
+		Name:            "codegen",
+		Deps:            make(util.Set),
+		Persistent:      true,
+		AllowDependents: true,
+	})
+	p.AddTask(&Task{
+		Name:       "watch",
+		Deps:       make(util.Set),
+		Persistent: true,
+	})
+	dependOnCodegen := make(util.Set)
+	dependOnCodegen.Add("libA#codegen")
+	p.AddTask(&Task{
+		Name: "app1#build",
+		Deps: dependOnCodegen,
+	})
+	dependOnWatch := make(util.Set)
+	dependOnWatch.Add("libA#watch")
+	p.AddTask(&Task{
+		Name: "app1#test",
+		Deps: dependOnWatch,
+	})
+	err := p.Prepare(&EngineBuildingOptions{
+		Packages:  []string{"app1"},
+		TaskNames: []string{"build", "test"},
+	})
+	assert.NilError(t, err, "Prepare")
+	assert.DeepEqual(t, p.StreamingDependencies("app1#build"), []string{"libA#codegen"})
+
+	// Depending on a persistent task that doesn't allow dependents is still an error
+	err = p.ValidatePersistentDependencies(func(taskID string) bool { return true })
+	var violation *PersistentDependencyError
+	assert.Assert(t, errors.As(err, &violation), "%v", err)
+	assert.DeepEqual(t, *violation, PersistentDependencyError{Dependent: "app1#test", Persistent: "libA#watch"})
+}
+
+func TestStreamingOutputs(t *testing.T) {
+	graph := &dag.AcyclicGraph{}
+	graph.Add("app1")
+	graph.Add("libA")
+	graph.Connect(dag.BasicEdge("app1", "libA"))
+
+	p := NewEngine(graph)
+	dependOnBuild := make(util.Set)
+	dependOnBuild.Add("build")
+	p.AddTask(&Task{
+		Name:             "build",
+		TopoDeps:         dependOnBuild,
+		Deps:             make(util.Set),
+		StreamingOutputs: true,
+	})
+	p.AddTask(&Task{
+		Name:              "app1#build",
+		TopoDeps:          dependOnBuild,
+		Deps:              make(util.Set),
+		ConsumesStreaming: true,
+	})
+	err := p.Prepare(&EngineBuildingOptions{
+		Packages:  []string{"app1"},
+		TaskNames: []string{"build"},
+	})
+	assert.NilError(t, err, "Prepare")
+	assert.DeepEqual(t, p.StreamingDependencies("app1#build"), []string{"libA#build"})
+	assert.Assert(t, !p.TaskGraph.DownEdges("app1#build").Include("libA#build"))
+
+	consumerStarted := make(chan struct{})
+	errs := p.Execute(func(taskID string) error {
+		if taskID == "libA#build" {
+			p.MarkReady(taskID)
+			// The consumer starts while the producer is still running
+			select {
+			case <-consumerStarted:
+				return nil
+			case <-time.After(5 * time.Second):
+				return errors.New("app1#build did not start before libA#build finished")
+			}
+		}
+		close(consumerStarted)
+		return nil
+	}, EngineExecutionOptions{Concurrency: 10})
+	assert.Equal(t, len(errs), 0, "%v", errs)
+}
+
+func TestExplainMode(t *testing.T) {
+	graph := &dag.AcyclicGraph{}
+	graph.Add("app1")
+	graph.Add("libA")
+	graph.Connect(dag.BasicEdge("app1", "libA"))
+
+	p := NewEngine(graph)
+	dependOnBuild := make(util.Set)
+	dependOnBuild.Add("build")
+	p.AddTask(&Task{
+		Name:     "build",
+		TopoDeps: dependOnBuild,
+		Deps:     make(util.Set),
+	})
+	err := p.Prepare(&EngineBuildingOptions{
+		Packages:    []string{"app1"},
+		TaskNames:   []string{"build"},
+		ExplainMode: true,
+	})
+	assert.NilError(t, err, "Prepare")
+
+	errs := p.Execute(func(taskID string) error {
+		if taskID == "libA#build" {
+			p.RecordDecision(taskID, DecisionCached, "found outputs in cache")
+		}
+		return nil
+	}, EngineExecutionOptions{Concurrency: 10})
+	assert.Equal(t, len(errs), 0)
+
+	type summary struct {
+		TaskID  string
+		Kind    DecisionKind
+		GatedBy string
+	}
+	var decisions []summary
+	for _, decision := range p.Decisions() {
+		decisions = append(decisions, summary{decision.TaskID, decision.Kind, decision.GatedBy})
+	}
+	assert.DeepEqual(t, decisions, []summary{
+		{"libA#build", DecisionReady, ""},
+		{"libA#build", DecisionStarted, ""},
+		{"libA#build", DecisionCached, ""},
+		{"libA#build", DecisionFinished, ""},
+		{"app1#build", DecisionReady, "libA#build"},
+		{"app1#build", DecisionStarted, ""},
+		{"app1#build", DecisionFinished, ""},
+	})
+	assert.Equal(t, p.Decisions()[4].Reason, "all 1 dependencies finished, the last was libA#build")
+}
+
+func TestExplainModeDisabled(t *testing.T) {
+	graph := &dag.AcyclicGraph{}
+	graph.Add("app1")
+
+	p := NewEngine(graph)
+	p.AddTask(&Task{
+		Name:     "build",
+		TopoDeps: make(util.Set),
+		Deps:     make(util.Set),
+	})
+	err := p.Prepare(&EngineBuildingOptions{
+		Packages:  []string{"app1"},
+		TaskNames: []string{"build"},
+	})
+	assert.NilError(t, err, "Prepare")
+
+	errs := p.Execute(testVisitor, EngineExecutionOptions{Concurrency: 10})
+	assert.Equal(t, len(errs), 0)
+	assert.Equal(t, len(p.Decisions()), 0)
+}
+
+func TestWorkspaceProtocol(t *testing.T) {
+	graph := &dag.AcyclicGraph{}
+	graph.Add("app1")
+	graph.Add("libA")
+	graph.Add("libB")
+	graph.Add(ROOT_NODE_NAME)
+	graph.Connect(dag.BasicEdge("app1", "libA"))
+	graph.Connect(dag.BasicEdge("app1", "libB"))
+	graph.Connect(dag.BasicEdge("libA", ROOT_NODE_NAME))
+	graph.Connect(dag.BasicEdge("libB", ROOT_NODE_NAME))
+	packageInfos := map[interface{}]*fs.PackageJSON{
+		"app1": {InternalDepProtocols: map[string]string{"libA": "workspace", "libB": ""}},
+		"libA": {InternalDepProtocols: map[string]string{}},
+		"libB": {InternalDepProtocols: map[string]string{}},
+	}
+
+	p := NewEngine(graph)
+	dependOnBuild := make(util.Set)
+	dependOnBuild.Add("build")
+	p.AddTask(&Task{
+		Name:     "build",
+		TopoDeps: dependOnBuild,
+		Deps:     make(util.Set),
+	})
+	err := p.Prepare(&EngineBuildingOptions{
+		Packages:          []string{"app1", "libA", "libB"},
+		TaskNames:         []string{"build"},
+		WorkspaceProtocol: "workspace",
+		PackageInfos:      packageInfos,
+	})
+	assert.NilError(t, err, "Prepare")
+
+	deps := p.TaskGraph.DownEdges("app1#build")
+	assert.Assert(t, deps.Include("libA#build"))
+	assert.Assert(t, !deps.Include("libB#build"))
+	// The caller's graph is left untouched
+	assert.Assert(t, graph.DownEdges("app1").Include("libB"))
+}
+
+func setupTagEngine(t *testing.T, dependsOnTag []string) *Engine {
+	graph := &dag.AcyclicGraph{}
+	graph.Add("app1")
+	graph.Add("libA")
+	graph.Add(ROOT_NODE_NAME)
+	graph.Connect(dag.BasicEdge("app1", ROOT_NODE_NAME))
+	graph.Connect(dag.BasicEdge("libA", ROOT_NODE_NAME))
+
+	tasks := []*Task{
+		{Name: "lint", Tags: []string{"ci-critical"}},
+		{Name: "test"},
+		{Name: "app1#test", Tags: []string{"ci-critical"}},
+		{Name: "release", DependsOnTag: dependsOnTag},
+	}
+	return prepareTestEngine(t, graph, tasks, &EngineBuildingOptions{
+		Packages:  []string{"app1", "libA"},
+		TaskNames: []string{"release"},
+	})
+}
+
+func TestDependsOnTag(t *testing.T) {
+	p := setupTagEngine(t, []string{"ci-critical"})
+
+	for _, pkg := range []string{"app1", "libA"} {
+		deps := p.TaskGraph.DownEdges(pkg + "#release")
+		assert.Assert(t, deps.Include("app1#lint"))
+		assert.Assert(t, deps.Include("libA#lint"))
+		assert.Assert(t, deps.Include("app1#test"))
+		assert.Assert(t, !deps.Include("libA#test"))
+	}
+	assert.Equal(t, len(p.Warnings()), 0)
+}
+
+func TestDependsOnUnknownTag(t *testing.T) {
+	p := setupTagEngine(t, []string{"nightly"})
+
+	assert.Assert(t, p.TaskGraph.DownEdges("app1#release").Include(ROOT_NODE_NAME))
+	assert.DeepEqual(t, p.Warnings(), []string{`no tasks are tagged "nightly", but it is used in dependsOnTag`})
+}
+
+func setupPathDependencyEngine(t *testing.T, dep string) (*Engine, error) {
+	graph := &dag.AcyclicGraph{}
+	graph.Add("app1")
+	graph.Add("ui")
+	graph.Add("ui-legacy")
+	graph.Add(ROOT_NODE_NAME)
+	graph.Connect(dag.BasicEdge("app1", ROOT_NODE_NAME))
+	graph.Connect(dag.BasicEdge("ui", ROOT_NODE_NAME))
+	graph.Connect(dag.BasicEdge("ui-legacy", ROOT_NODE_NAME))
+	packageInfos := map[interface{}]*fs.PackageJSON{
+		"app1":      {Dir: turbopath.AnchoredUnixPath("apps/app1").ToSystemPath()},
+		"ui":        {Dir: turbopath.AnchoredUnixPath("packages/ui").ToSystemPath()},
+		"ui-legacy": {Dir: turbopath.AnchoredUnixPath("packages/ui").ToSystemPath()},
+	}
+
+	p := newTestEngine(graph, []*Task{
+		{Name: "build"},
+		{Name: "test", Deps: util.SetFromStrings([]string{dep})},
+		{Name: "app1#lint"},
+	})
+	assert.NilError(t, p.AddDep(dep, "app1#lint"), "AddDep")
+	err := p.Prepare(&EngineBuildingOptions{
+		Packages:     []string{"app1"},
+		TaskNames:    []string{"test", "lint"},
+		PackageInfos: packageInfos,
+	})
+	return p, err
+}
+
+func TestPathDependencies(t *testing.T) {
+	p, err := setupPathDependencyEngine(t, "path:apps/app1/#build")
+	assert.NilError(t, err, "Prepare")
+
+	assert.Assert(t, p.TaskGraph.DownEdges("app1#test").Include("app1#build"))
+	assert.Assert(t, p.TaskGraph.DownEdges("app1#lint").Include("app1#build"))
+}
+
+func TestPathDependenciesNoMatch(t *testing.T) {
+	_, err := setupPathDependencyEngine(t, "path:apps/app2#build")
+	assert.ErrorContains(t, err, "no workspace found at apps/app2 for dependency path:apps/app2#build")
+}
+
+func TestPathDependenciesMultipleMatches(t *testing.T) {
+	_, err := setupPathDependencyEngine(t, "path:packages/ui#build")
+	assert.ErrorContains(t, err, "multiple workspaces found at packages/ui for dependency path:packages/ui#build: ui, ui-legacy")
+}
+
+func setupShuffleEngine(t *testing.T, seed int64) *Engine {
+	graph := &dag.AcyclicGraph{}
+	graph.Add(ROOT_NODE_NAME)
+	for _, pkg := range []string{"a", "b", "c", "d", "e", "f"} {
+		graph.Add(pkg)
+		graph.Connect(dag.BasicEdge(pkg, "lib"))
+	}
+	graph.Add("lib")
+	graph.Connect(dag.BasicEdge("lib", ROOT_NODE_NAME))
+
+	tasks := []*Task{{Name: "build", TopoDeps: util.SetFromStrings([]string{"build"})}}
+	return prepareTestEngine(t, graph, tasks, &EngineBuildingOptions{
+		Packages:    []string{"a", "b", "c", "d", "e", "f", "lib"},
+		TaskNames:   []string{"build"},
+		ShuffleSeed: seed,
+	})
+}
+
+func TestShuffleSeed(t *testing.T) {
+	run := func(seed int64, concurrency int) ([]string, []string) {
+		p := setupShuffleEngine(t, seed)
+		var mu sync.Mutex
+		order := []string{}
+		errs := p.Execute(func(taskID string) error {
+			mu.Lock()
+			defer mu.Unlock()
+			order = append(order, taskID)
+			return nil
+		}, EngineExecutionOptions{Concurrency: concurrency})
+		assert.Equal(t, len(errs), 0, "%v", errs)
+		return order, p.ShuffledOrder()
+	}
+
+	order, shuffled := run(42, 1)
+	assert.DeepEqual(t, order, shuffled)
+	assert.Equal(t, order[0], "lib#build")
+	assert.Equal(t, len(order), 7)
+	for i := 0; i < 5; i++ {
+		again, _ := run(42, 1)
+		assert.DeepEqual(t, again, order)
+	}
+	// Starting in order doesn't depend on tasks running one at a time
+	_, shuffledConcurrently := run(42, 10)
+	assert.DeepEqual(t, shuffledConcurrently, shuffled)
+
+	differs := false
+	for seed := int64(1); seed <= 10 && !differs; seed++ {
+		other, _ := run(seed, 1)
+		differs = strings.Join(other, ",") != strings.Join(order, ",")
+	}
+	assert.Assert(t, differs, "expected some seed to give a different order")
+}
+
+func TestExecutedDependencies(t *testing.T) {
+	p := setupQuorumEngine(t, 0)
+
+	// Pretend that libA and libB were executed, but libC was restored from the cache
+	var executed []string
+	errs := p.Execute(func(taskID string) error {
+		if taskID == "libA#build" || taskID == "libB#build" {
+			p.MarkExecuted(taskID)
+		}
+		if taskID == "app1#aggregate" {
+			executed = p.ExecutedDependencies(taskID)
+		}
+		return nil
+	}, EngineExecutionOptions{Concurrency: 10})
+	assert.Equal(t, len(errs), 0)
+	assert.DeepEqual(t, executed, []string{"libA#build", "libB#build"})
+
+	// Each execution starts without any executed tasks
+	errs = p.Execute(func(taskID string) error {
+		if taskID == "app1#aggregate" {
+			executed = p.ExecutedDependencies(taskID)
+		}
+		return nil
+	}, EngineExecutionOptions{Concurrency: 10})
+	assert.Equal(t, len(errs), 0)
+	assert.DeepEqual(t, executed, []string{})
+}
+
+func setupWeightEngine(t *testing.T, testWeight int) *Engine {
+	graph := &dag.AcyclicGraph{}
+	graph.Add("a")
+	graph.Add("b")
+	graph.Add("c")
+	graph.Add(ROOT_NODE_NAME)
+	graph.Connect(dag.BasicEdge("a", ROOT_NODE_NAME))
+	graph.Connect(dag.BasicEdge("b", ROOT_NODE_NAME))
+	graph.Connect(dag.BasicEdge("c", ROOT_NODE_NAME))
+
+	tasks := []*Task{
+		{Name: "lint"},
+		{Name: "test", Weight: testWeight},
+	}
+	return prepareTestEngine(t, graph, tasks, &EngineBuildingOptions{
+		Packages:  []string{"a", "b", "c"},
+		TaskNames: []string{"lint", "test"},
+	})
+}
+
+func TestTaskWeight(t *testing.T) {
+	p := setupWeightEngine(t, 2)
+
+	var mu sync.Mutex
+	running := 0
+	maxRunning := 0
+	errs := p.Execute(func(taskID string) error {
+		weight := 1
+		if strings.HasSuffix(taskID, "#test") {
+			weight = 2
+		}
+		mu.Lock()
+		running += weight
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		running -= weight
+		mu.Unlock()
+		return nil
+	}, EngineExecutionOptions{Concurrency: 3})
+	assert.Equal(t, len(errs), 0)
+	assert.Assert(t, maxRunning <= 3, "running tasks weighed %v, more than the concurrency of 3", maxRunning)
+}
+
+func TestTaskWeightExceedsConcurrency(t *testing.T) {
+	p := setupWeightEngine(t, 4)
+
+	var mu sync.Mutex
+	visited := []string{}
+	errs := p.Execute(func(taskID string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		visited = append(visited, taskID)
+		return nil
+	}, EngineExecutionOptions{Concurrency: 3, ContinueOnError: true})
+	assert.Equal(t, len(errs), 3)
+	assert.ErrorContains(t, errs[0], "has a weight of 4, which exceeds the concurrency of 3")
+
+	// The lint tasks still run
+	assert.Equal(t, len(visited), 3)
+	for _, taskID := range visited {
+		assert.Assert(t, strings.HasSuffix(taskID, "#lint"), taskID)
+	}
+
+	// Running in parallel ignores weights
+	errs = p.Execute(func(taskID string) error { return nil }, EngineExecutionOptions{Parallel: true, Concurrency: 3})
+	assert.Equal(t, len(errs), 0)
+}
+
+func TestCycleDetected(t *testing.T) {
+	graph := &dag.AcyclicGraph{}
+	graph.Add("workspace-a")
+	graph.Add("workspace-b")
+	graph.Add("workspace-c")
+
+	p := NewEngine(graph)
+	p.AddTask(&Task{Name: "build", TopoDeps: make(util.Set), Deps: make(util.Set)})
+	p.AddTask(&Task{Name: "lint", TopoDeps: make(util.Set), Deps: make(util.Set)})
+	assert.NilError(t, p.AddDep("workspace-b#build", "workspace-a#build"), "AddDep")
+	assert.NilError(t, p.AddDep("workspace-c#build", "workspace-b#build"), "AddDep")
+	assert.NilError(t, p.AddDep("workspace-a#build", "workspace-c#build"), "AddDep")
+	// An unrelated dependency that isn't part of the cycle
+	assert.NilError(t, p.AddDep("workspace-a#lint", "workspace-b#build"), "AddDep")
+
+	err := p.Prepare(&EngineBuildingOptions{
+		Packages:  []string{"workspace-a"},
+		TaskNames: []string{"build"},
+	})
+	var cycleErr *CycleError
+	assert.Assert(t, errors.As(err, &cycleErr), "%v", err)
+	assert.DeepEqual(t, cycleErr.Path, []string{"workspace-a#build", "workspace-b#build", "workspace-c#build", "workspace-a#build"})
+	assert.Error(t, err, "cycle detected: workspace-a#build -> workspace-b#build -> workspace-c#build -> workspace-a#build")
+}
+
+func TestSelfDependencyDetected(t *testing.T) {
+	graph := &dag.AcyclicGraph{}
+	graph.Add("workspace-a")
+
+	p := NewEngine(graph)
+	p.AddTask(&Task{Name: "build", TopoDeps: make(util.Set), Deps: make(util.Set)})
+	assert.NilError(t, p.AddDep("workspace-a#build", "workspace-a#build"), "AddDep")
+
+	err := p.Prepare(&EngineBuildingOptions{
+		Packages:  []string{"workspace-a"},
+		TaskNames: []string{"build"},
+	})
+	var cycleErr *CycleError
+	assert.Assert(t, errors.As(err, &cycleErr), "%v", err)
+	assert.DeepEqual(t, cycleErr.Path, []string{"workspace-a#build", "workspace-a#build"})
+}
+
+func TestScriptPatternDependencies(t *testing.T) {
+	graph := &dag.AcyclicGraph{}
+	graph.Add("workspace-a")
+	graph.Add("workspace-b")
+	graph.Add("workspace-c")
+	graph.Connect(dag.BasicEdge("workspace-c", "workspace-a"))
+	graph.Connect(dag.BasicEdge("workspace-c", "workspace-b"))
+
+	p := NewEngine(graph)
+	lintDeps := make(util.Set)
+	lintDeps.Add("lint:*")
+	p.AddTask(&Task{Name: "lint", TopoDeps: make(util.Set), Deps: lintDeps})
+	p.AddTask(&Task{Name: "lint:js", TopoDeps: make(util.Set), Deps: make(util.Set)})
+	p.AddTask(&Task{Name: "lint:css", TopoDeps: make(util.Set), Deps: make(util.Set)})
+	// A task matching its own pattern doesn't depend on itself
+	p.AddTask(&Task{Name: "lint:all", TopoDeps: make(util.Set), Deps: lintDeps})
+	checkTopoDeps := make(util.Set)
+	checkTopoDeps.Add("lint:*")
+	p.AddTask(&Task{Name: "check", TopoDeps: checkTopoDeps, Deps: make(util.Set)})
+
+	err := p.Prepare(&EngineBuildingOptions{
+		Packages:  []string{"workspace-a", "workspace-b", "workspace-c"},
+		TaskNames: []string{"lint", "lint:all", "check"},
+		PackageInfos: map[interface{}]*fs.PackageJSON{
+			"workspace-a": {Scripts: map[string]string{"lint:js": "eslint", "lint:css": "stylelint", "lint:all": "run-s lint:*", "build": "tsc"}},
+			"workspace-b": {Scripts: map[string]string{"lint:js": "eslint"}},
+			"workspace-c": {Scripts: map[string]string{"build": "tsc"}},
+		},
+	})
+	assert.NilError(t, err, "Prepare")
+
+	downEdges := func(taskID string) []string {
+		deps := []string{}
+		for _, dep := range p.TaskGraph.DownEdges(taskID) {
+			deps = append(deps, dag.VertexName(dep))
+		}
+		sort.Strings(deps)
+		return deps
+	}
+	assert.DeepEqual(t, downEdges("workspace-a#lint"), []string{"workspace-a#lint:all", "workspace-a#lint:css", "workspace-a#lint:js"})
+	assert.DeepEqual(t, downEdges("workspace-a#lint:all"), []string{"workspace-a#lint:css", "workspace-a#lint:js"})
+	assert.DeepEqual(t, downEdges("workspace-b#lint"), []string{"workspace-b#lint:js"})
+	// workspace-c has no lint scripts, so its lint task has nothing to wait for
+	assert.DeepEqual(t, downEdges("workspace-c#lint"), []string{"___ROOT___"})
+	assert.DeepEqual(t, downEdges("workspace-c#check"), []string{"workspace-a#lint:all", "workspace-a#lint:css", "workspace-a#lint:js", "workspace-b#lint:js"})
+}
+
+func TestTaskNamePatterns(t *testing.T) {
+	graph := &dag.AcyclicGraph{}
+	graph.Add("web")
+	graph.Add("docs")
+
+	newEngine := func() *Engine {
+		p := NewEngine(graph)
+		p.AddTask(&Task{Name: "build", TopoDeps: make(util.Set), Deps: make(util.Set)})
+		p.AddTask(&Task{Name: "lint:js", TopoDeps: make(util.Set), Deps: make(util.Set)})
+		p.AddTask(&Task{Name: "lint:css", TopoDeps: make(util.Set), Deps: make(util.Set)})
+		// Tasks defined for a single workspace are matched by their name
+		p.AddTask(&Task{Name: "docs#lint:md", TopoDeps: make(util.Set), Deps: make(util.Set)})
+		return p
+	}
+
+	p := newEngine()
+	err := p.Prepare(&EngineBuildingOptions{
+		Packages:  []string{"web", "docs"},
+		TaskNames: []string{"lint:*"},
+	})
+	assert.NilError(t, err, "Prepare")
+	taskIDs := []string{}
+	for _, v := range p.TaskGraph.Vertices() {
+		if taskID := dag.VertexName(v); !strings.Contains(taskID, ROOT_NODE_NAME) {
+			taskIDs = append(taskIDs, taskID)
+		}
+	}
+	sort.Strings(taskIDs)
+	assert.DeepEqual(t, taskIDs, []string{"docs#lint:css", "docs#lint:js", "docs#lint:md", "web#lint:css", "web#lint:js"})
+
+	p = newEngine()
+	err = p.Prepare(&EngineBuildingOptions{
+		Packages:  []string{"web", "docs"},
+		TaskNames: []string{"build", "nope:*"},
+	})
+	assert.ErrorContains(t, err, `no tasks in the pipeline match "nope:*"`)
+}
+
+func TestExpandTaskNames(t *testing.T) {
+	definedNames := []string{"build", "lint:js", "lint:css", "test"}
+
+	// Patterns are expanded in place, and names are kept once
+	taskNames, err := ExpandTaskNames([]string{"build", "lint:*", "lint:js", "t?st"}, definedNames)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, taskNames, []string{"build", "lint:css", "lint:js", "test"})
+
+	_, err = ExpandTaskNames([]string{"nope:*"}, definedNames)
+	assert.Error(t, err, `no tasks in the pipeline match "nope:*"`)
+
+	_, err = ExpandTaskNames([]string{"lint:["}, definedNames)
+	assert.ErrorContains(t, err, `invalid task pattern "lint:["`)
+}
+
+func TestPackagePatternDependencies(t *testing.T) {
+	graph := &dag.AcyclicGraph{}
+	for _, pkg := range []string{"docs", "web", "@acme/ui-button", "@acme/ui-card", "@acme/ui", "@acme/utils"} {
+		graph.Add(pkg)
+	}
+	graph.Connect(dag.BasicEdge("docs", "@acme/ui-button"))
+	graph.Connect(dag.BasicEdge("docs", "@acme/ui"))
+	graph.Connect(dag.BasicEdge("docs", "@acme/utils"))
+	graph.Connect(dag.BasicEdge("web", "@acme/ui-card"))
+
+	p := NewEngine(graph)
+	p.AddTask(&Task{Name: "build", TopoDeps: make(util.Set), Deps: make(util.Set)})
+	p.AddTask(&Task{Name: "test", TopoDeps: make(util.Set), Deps: make(util.Set)})
+	p.AddTask(&Task{Name: "lint", TopoDeps: make(util.Set), Deps: make(util.Set)})
+	// docs#build depends on the build of every @acme/ui-* package in the repo
+	p.AddTask(&Task{Name: "docs#build", TopoDeps: make(util.Set), Deps: make(util.Set)})
+	assert.NilError(t, p.AddDep("@acme/ui-*#build", "docs#build"))
+	// A pattern matching no packages is not an error
+	p.AddTask(&Task{Name: "web#lint", TopoDeps: make(util.Set), Deps: make(util.Set)})
+	assert.NilError(t, p.AddDep("@other/*#lint", "web#lint"))
+	// A task matching its own pattern doesn't depend on itself
+	p.AddTask(&Task{Name: "@acme/ui-card#lint", TopoDeps: make(util.Set), Deps: make(util.Set)})
+	assert.NilError(t, p.AddDep("@acme/ui-*#lint", "@acme/ui-card#lint"))
+	// docs#test depends on the test of its @acme/ui-* dependencies only
+	testTopoDeps := make(util.Set)
+	testTopoDeps.Add("@acme/ui-*#test")
+	p.AddTask(&Task{Name: "docs#test", TopoDeps: testTopoDeps, Deps: make(util.Set)})
+	// web#test has no @acme/utils dependency, so its test has nothing to wait for
+	webTestTopoDeps := make(util.Set)
+	webTestTopoDeps.Add("@acme/utils#test")
+	p.AddTask(&Task{Name: "web#test", TopoDeps: webTestTopoDeps, Deps: make(util.Set)})
+
+	err := p.Prepare(&EngineBuildingOptions{
+		Packages:  []string{"docs", "web", "@acme/ui-card"},
+		TaskNames: []string{"build", "test", "lint"},
+		PackageInfos: map[interface{}]*fs.PackageJSON{
+			"docs":            {},
+			"web":             {},
+			"@acme/ui-button": {},
+			"@acme/ui-card":   {},
+			"@acme/ui":        {},
+			"@acme/utils":     {},
+		},
+	})
+	assert.NilError(t, err, "Prepare")
+
+	downEdges := func(taskID string) []string {
+		deps := []string{}
+		for _, dep := range p.TaskGraph.DownEdges(taskID) {
+			deps = append(deps, dag.VertexName(dep))
+		}
+		sort.Strings(deps)
+		return deps
+	}
+	assert.DeepEqual(t, downEdges("docs#build"), []string{"@acme/ui-button#build", "@acme/ui-card#build"})
+	assert.DeepEqual(t, downEdges("web#lint"), []string{"___ROOT___"})
+	assert.DeepEqual(t, downEdges("@acme/ui-card#lint"), []string{"@acme/ui-button#lint"})
+	assert.DeepEqual(t, downEdges("docs#test"), []string{"@acme/ui-button#test"})
+	assert.DeepEqual(t, downEdges("web#test"), []string{"___ROOT___"})
+}
+
+// setupDiamondEngine prepares the build of app, which depends on libA and libB,
+// which both depend on base. libB's build first runs its prepare task.
+func setupDiamondEngine(t *testing.T) *Engine {
+	graph := &dag.AcyclicGraph{}
+	graph.Add("app")
+	graph.Add("libA")
+	graph.Add("libB")
+	graph.Add("base")
+	graph.Connect(dag.BasicEdge("app", "libA"))
+	graph.Connect(dag.BasicEdge("app", "libB"))
+	graph.Connect(dag.BasicEdge("libA", "base"))
+	graph.Connect(dag.BasicEdge("libB", "base"))
+
+	p := newTestEngine(graph, []*Task{
+		{Name: "build", TopoDeps: util.SetFromStrings([]string{"build"})},
+		{Name: "prepare"},
+	})
+	assert.NilError(t, p.AddDep("libB#prepare", "libB#build"), "AddDep")
+	err := p.Prepare(&EngineBuildingOptions{
+		Packages:  []string{"app"},
+		TaskNames: []string{"build"},
+	})
+	assert.NilError(t, err, "Prepare")
+	return p
+}
+
+// diamondVisitor fails libA's build. libB's prepare task waits for that failure,
+// so that libB's build is only started after it.
+func diamondVisitor(visited *sync.Map) Visitor {
+	libAFailed := make(chan struct{})
+	return func(taskID string) error {
+		visited.Store(taskID, true)
+		switch taskID {
+		case "libA#build":
+			close(libAFailed)
+			return errors.New("libA#build failed")
+		case "libB#prepare":
+			<-libAFailed
+			time.Sleep(20 * time.Millisecond)
+		}
+		return nil
+	}
+}
+
+func TestContinueOnError(t *testing.T) {
+	p := setupDiamondEngine(t)
+
+	visited := &sync.Map{}
+	errs := p.Execute(diamondVisitor(visited), EngineExecutionOptions{Concurrency: 10, ContinueOnError: true})
+	assert.Equal(t, len(errs), 1)
+	assert.ErrorContains(t, errs[0], "libA#build failed")
+
+	// The leg that doesn't depend on the failure runs to completion
+	for _, taskID := range []string{"base#build", "libB#prepare", "libB#build"} {
+		_, ran := visited.Load(taskID)
+		assert.Assert(t, ran, "expected %v to run", taskID)
+	}
+	_, ran := visited.Load("app#build")
+	assert.Assert(t, !ran, "expected app#build not to run after libA#build failed")
+}
+
+func TestStopOnError(t *testing.T) {
+	p := setupDiamondEngine(t)
+
+	visited := &sync.Map{}
+	errs := p.Execute(diamondVisitor(visited), EngineExecutionOptions{Concurrency: 10})
+	assert.Equal(t, len(errs), 1)
+	assert.ErrorContains(t, errs[0], "libA#build failed")
+
+	// libB's build would have started after the failure, so it never does
+	for _, taskID := range []string{"libB#build", "app#build"} {
+		_, ran := visited.Load(taskID)
+		assert.Assert(t, !ran, "expected %v not to run after libA#build failed", taskID)
+	}
+}
+
+func TestFailedPersistentDependencyIgnoresQuorum(t *testing.T) {
+	graph := &dag.AcyclicGraph{}
+	graph.Add("app")
+
+	p := newTestEngine(graph, []*Task{
+		{Name: "app#db", Persistent: true},
+		{Name: "app#seed"},
+		{Name: "app#test", DepQuorum: 1},
+	})
+	assert.NilError(t, p.AddDep("app#db", "app#test"), "AddDep")
+	assert.NilError(t, p.AddDep("app#seed", "app#test"), "AddDep")
+	err := p.Prepare(&EngineBuildingOptions{
+		Packages:  []string{"app"},
+		TaskNames: []string{"test"},
+	})
+	assert.NilError(t, err, "Prepare")
+
+	visited := &sync.Map{}
+	errs := p.Execute(failingVisitor([]string{"app#db"}, visited), EngineExecutionOptions{Concurrency: 10, ContinueOnError: true})
+	assert.Equal(t, len(errs), 1)
+	_, ran := visited.Load("app#test")
+	assert.Assert(t, !ran, "expected app#test not to run after its persistent dependency failed")
+}
+
+func TestEngineTasks(t *testing.T) {
+	var g dag.AcyclicGraph
+	p := NewEngine(&g)
+	deps := make(util.Set)
+	deps.Add("prepare")
+	p.AddTask(&Task{
+		Name:    "test",
+		Deps:    deps,
+		Outputs: []string{"coverage/**"},
+	})
+	p.AddTask(&Task{
+		Name:           "build",
+		Persistent:     true,
+		ReadinessProbe: &ReadinessProbe{Port: 3000},
+	})
+	p.AddTask(&Task{
+		Name: "web#build",
+	})
+
+	tasks := p.Tasks()
+	names := []string{}
+	for _, task := range tasks {
+		names = append(names, task.Name)
+	}
+	assert.DeepEqual(t, names, []string{"build", "test", "web#build"})
+
+	// Changing the returned tasks doesn't change the engine
+	tasks[1].Deps.Add("lint")
+	tasks[1].Outputs[0] = "dist/**"
+	tasks[0].ReadinessProbe.Port = 4000
+	tasks[0] = &Task{Name: "changed"}
+	test, ok := p.TaskByID("test")
+	assert.Assert(t, ok)
+	assert.DeepEqual(t, test.Deps.UnsafeListOfStrings(), []string{"prepare"})
+	assert.DeepEqual(t, test.Outputs, []string{"coverage/**"})
+	assert.Equal(t, p.Tasks()[0].Name, "build")
+	assert.Equal(t, p.Tasks()[0].ReadinessProbe.Port, 3000)
+
+	task, ok := p.TaskByID("web#build")
+	assert.Assert(t, ok)
+	assert.Equal(t, task.Name, "web#build")
+	task, ok = p.TaskByID("docs#test")
+	assert.Assert(t, ok)
+	assert.Equal(t, task.Name, "test")
+	_, ok = p.TaskByID("docs#lint")
+	assert.Assert(t, !ok)
+	_, ok = p.TaskByID("lint")
+	assert.Assert(t, !ok)
+}
+
+func setupRestoreEngine(t *testing.T, pkgs ...string) *Engine {
+	graph := &dag.AcyclicGraph{}
+	for _, pkg := range pkgs {
+		graph.Add(pkg)
+	}
+	return prepareTestEngine(t, graph, []*Task{{Name: "build"}}, &EngineBuildingOptions{
+		Packages:  pkgs,
+		TaskNames: []string{"build"},
+	})
+}
+
+func TestRestoreConcurrency(t *testing.T) {
+	p := setupRestoreEngine(t, "a", "b", "c", "d")
+
+	// Each restore waits until all of them are restoring at once, which only
+	// happens if they overlap beyond the concurrency of 1
+	var mu sync.Mutex
+	restoring := 0
+	maxRestoring := 0
+	allRestoring := make(chan struct{})
+	errs := p.Execute(func(taskID string) error {
+		return fmt.Errorf("%v was restored and shouldn't run", taskID)
+	}, EngineExecutionOptions{
+		Concurrency:        1,
+		RestoreConcurrency: 4,
+		Restore: func(taskID string) bool {
+			mu.Lock()
+			restoring++
+			if restoring > maxRestoring {
+				maxRestoring = restoring
+			}
+			if restoring == 4 {
+				close(allRestoring)
+			}
+			mu.Unlock()
+			select {
+			case <-allRestoring:
+			case <-time.After(5 * time.Second):
+			}
+			mu.Lock()
+			restoring--
+			mu.Unlock()
+			return true
+		},
+	})
+	assert.Equal(t, len(errs), 0)
+	assert.Equal(t, maxRestoring, 4)
+}
+
+func TestRestoreConcurrencyLimit(t *testing.T) {
+	p := setupRestoreEngine(t, "a", "b", "c", "d")
+
+	var mu sync.Mutex
+	restoring := 0
+	maxRestoring := 0
+	errs := p.Execute(testVisitor, EngineExecutionOptions{
+		Concurrency:        4,
+		RestoreConcurrency: 2,
+		Restore: func(taskID string) bool {
+			mu.Lock()
+			restoring++
+			if restoring > maxRestoring {
+				maxRestoring = restoring
+			}
+			mu.Unlock()
+			time.Sleep(20 * time.Millisecond)
+			mu.Lock()
+			restoring--
+			mu.Unlock()
+			return true
+		},
+	})
+	assert.Equal(t, len(errs), 0)
+	assert.Assert(t, maxRestoring <= 2, "restored %v tasks at once", maxRestoring)
+}
+
+func TestSlowRestoreDoesNotDelayOtherTasks(t *testing.T) {
+	p := setupRestoreEngine(t, "a", "b")
+
+	// a#build is restored only once b#build, which missed the cache, has run
+	bRan := make(chan struct{})
+	restoredAfterB := false
+	errs := p.Execute(func(taskID string) error {
+		if taskID == "b#build" {
+			close(bRan)
+		}
+		return nil
+	}, EngineExecutionOptions{
+		Concurrency:        2,
+		RestoreConcurrency: 2,
+		Restore: func(taskID string) bool {
+			if taskID != "a#build" {
+				return false
+			}
+			select {
+			case <-bRan:
+				restoredAfterB = true
+			case <-time.After(5 * time.Second):
+			}
+			return true
+		},
+	})
+	assert.Equal(t, len(errs), 0)
+	assert.Assert(t, restoredAfterB, "b#build waited for a#build to be restored")
+}
+
+func TestRestoreMiss(t *testing.T) {
+	p := setupRestoreEngine(t, "a", "b")
+
+	var mu sync.Mutex
+	events := []string{}
+	record := func(event string) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, event)
+	}
+	errs := p.Execute(func(taskID string) error {
+		record("run " + taskID)
+		return nil
+	}, EngineExecutionOptions{
+		Concurrency: 1,
+		Restore: func(taskID string) bool {
+			record("restore " + taskID)
+			// a was restored, but b missed the cache
+			return taskID == "a#build"
+		},
+	})
+	assert.Equal(t, len(errs), 0)
+	sort.Strings(events)
+	assert.DeepEqual(t, events, []string{"restore a#build", "restore b#build", "run b#build"})
+	// The restore is part of the first attempt
+	assert.Equal(t, p.Attempts("b#build"), 1)
+	assert.Equal(t, p.Attempts("a#build"), 1)
+}
+
+func TestRootTaskDependency(t *testing.T) {
+	for _, dependency := range []string{"//#setup", "^//#setup"} {
+		graph := &dag.AcyclicGraph{}
+		graph.Add("workspace-a")
+		graph.Add("workspace-b")
+		graph.Connect(dag.BasicEdge("workspace-b", "workspace-a"))
+
+		p := NewEngine(graph)
+		deps := make(util.Set)
+		topoDeps := make(util.Set)
+		if strings.HasPrefix(dependency, "^") {
+			topoDeps.Add(strings.TrimPrefix(dependency, "^"))
+		} else {
+			deps.Add(dependency)
+		}
+		p.AddTask(&Task{Name: "build", Deps: deps, TopoDeps: topoDeps})
+		p.AddTask(&Task{Name: "//#setup", Deps: make(util.Set), TopoDeps: make(util.Set)})
+		err := p.Prepare(&EngineBuildingOptions{
+			Packages:  []string{"workspace-a", "workspace-b"},
+			TaskNames: []string{"build"},
+		})
+		assert.NilError(t, err, "Prepare")
+
+		// Both builds depend on the single instance of the root task
+		for _, taskID := range []string{"workspace-a#build", "workspace-b#build"} {
+			assert.Assert(t, p.TaskGraph.DownEdges(taskID).Include("//#setup"), "%v: %v doesn't depend on //#setup", dependency, taskID)
+		}
+		setups := 0
+		for _, v := range p.TaskGraph.Vertices() {
+			if strings.HasSuffix(dag.VertexName(v), "#setup") {
+				setups++
+			}
+		}
+		assert.Equal(t, setups, 1, dependency)
+
+		var mu sync.Mutex
+		finished := util.Set{}
+		errs := p.Execute(func(taskID string) error {
+			mu.Lock()
+			defer mu.Unlock()
+			if taskID == "workspace-a#build" && !finished.Includes("//#setup") {
+				return errors.New("workspace-a#build started before //#setup finished")
+			}
+			finished.Add(taskID)
+			return nil
+		}, EngineExecutionOptions{Concurrency: 10})
+		assert.Equal(t, len(errs), 0, "%v: %v", dependency, errs)
+	}
+}
+
+func TestUndefinedRootTaskDependency(t *testing.T) {
+	graph := &dag.AcyclicGraph{}
+	graph.Add("workspace-a")
+
+	p := NewEngine(graph)
+	deps := make(util.Set)
+	deps.Add("//#setup")
+	p.AddTask(&Task{Name: "build", Deps: deps, TopoDeps: make(util.Set)})
+	err := p.Prepare(&EngineBuildingOptions{
+		Packages:  []string{"workspace-a"},
+		TaskNames: []string{"build"},
+	})
+	assert.ErrorContains(t, err, "build depends on //#setup, which needs an entry in turbo.json because it is a task run from the root package")
+}
+
+func TestMaxErrors(t *testing.T) {
+	const leaves = 50
+	setup := func() *Engine {
+		graph := &dag.AcyclicGraph{}
+		pkgs := []string{}
+		for i := 0; i < leaves; i++ {
+			pkg := fmt.Sprintf("pkg-%02d", i)
+			graph.Add(pkg)
+			pkgs = append(pkgs, pkg)
+		}
+		p := NewEngine(graph)
+		p.AddTask(&Task{Name: "build", Deps: make(util.Set), TopoDeps: make(util.Set)})
+		err := p.Prepare(&EngineBuildingOptions{
+			Packages:    pkgs,
+			TaskNames:   []string{"build"},
+			ExplainMode: true,
+		})
+		assert.NilError(t, err, "Prepare")
+		return p
+	}
+	failing := func(taskID string) error {
+		time.Sleep(time.Millisecond)
+		return fmt.Errorf("%v failed", taskID)
+	}
+
+	// Run one at a time, exactly two tasks fail before the rest are skipped
+	p := setup()
+	errs := p.Execute(failing, EngineExecutionOptions{Concurrency: 1, ContinueOnError: true, MaxErrors: 2})
+	assert.Equal(t, len(errs), 2, "%v", errs)
+	skipped := 0
+	for _, decision := range p.Decisions() {
+		if decision.Kind == DecisionSkipped {
+			assert.Equal(t, decision.Reason, "2 tasks failed, which is the most allowed")
+			skipped++
+		}
+	}
+	assert.Equal(t, skipped, leaves-2)
+
+	// Tasks that are already running finish, but no more start
+	p = setup()
+	var mu sync.Mutex
+	started := 0
+	errs = p.Execute(func(taskID string) error {
+		mu.Lock()
+		started++
+		mu.Unlock()
+		return failing(taskID)
+	}, EngineExecutionOptions{Concurrency: 5, ContinueOnError: true, MaxErrors: 2})
+	assert.Equal(t, len(errs), started)
+	assert.Assert(t, started >= 2 && started < leaves, "%v tasks started", started)
+
+	// Without a limit, every task runs
+	p = setup()
+	errs = p.Execute(failing, EngineExecutionOptions{Concurrency: 5, ContinueOnError: true})
+	assert.Equal(t, len(errs), leaves)
+}
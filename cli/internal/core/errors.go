@@ -0,0 +1,68 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// PersistentDependencyError is returned when a task depends on a persistent task
+// that doesn't allow dependents. Persistent tasks never exit, so the dependent
+// would never start.
+type PersistentDependencyError struct {
+	// Dependent is the id of the task that depends on the persistent task
+	Dependent string
+	// Persistent is the id of the persistent task
+	Persistent string
+}
+
+func (e *PersistentDependencyError) Error() string {
+	return fmt.Sprintf("\"%v\" is a persistent task, \"%v\" cannot depend on it", e.Persistent, e.Dependent)
+}
+
+// PersistentDependencyErrors is returned when more than one task depends on a
+// persistent task, with an error for each, one per line. It unwraps to the first
+// of them, so errors.As finds a *PersistentDependencyError either way.
+type PersistentDependencyErrors []*PersistentDependencyError
+
+func (e PersistentDependencyErrors) Error() string {
+	errs := make([]string, len(e))
+	for i, err := range e {
+		errs[i] = err.Error()
+	}
+	return strings.Join(errs, "\n")
+}
+
+func (e PersistentDependencyErrors) Unwrap() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e[0]
+}
+
+// CycleError is returned when the tasks in the TaskGraph depend on each other in
+// a cycle, so that none of them can ever run
+type CycleError struct {
+	// Path is the ids of the tasks in the cycle, in order, with the first task
+	// repeated at the end
+	Path []string
+}
+
+func (e *CycleError) Error() string {
+	return fmt.Sprintf("cycle detected: %v", strings.Join(e.Path, " -> "))
+}
+
+// MissingTaskError is returned when a task is depended on or run, but neither it
+// nor its task name is configured in turbo.json
+type MissingTaskError struct {
+	TaskID string
+}
+
+func (e *MissingTaskError) Error() string {
+	taskName := e.TaskID
+	if util.IsPackageTask(e.TaskID) {
+		_, taskName = util.GetPackageTaskFromId(e.TaskID)
+	}
+	return fmt.Sprintf("Missing task definition, configure \"%s\" or \"%s\" in turbo.json", taskName, e.TaskID)
+}
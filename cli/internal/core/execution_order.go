@@ -0,0 +1,53 @@
+package core
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/pyr-sh/dag"
+)
+
+// ExecutionOrder returns the task ids in the TaskGraph in batches, in the order
+// that they are visited. Each batch holds the tasks whose dependencies are all in
+// earlier batches, with at least one in the batch just before it, so the tasks
+// in a batch can run at the same time. Tasks within a batch are sorted by task
+// id. Dependencies that a task only waits to be ready for, such as streaming
+// dependencies, are not edges in the TaskGraph and don't affect the batches.
+func (e *Engine) ExecutionOrder() ([][]string, error) {
+	if !e.prepared {
+		return nil, errors.New("the execution order is only known once the engine is prepared")
+	}
+	levels := make(map[string]int)
+	var levelOf func(taskID string) int
+	levelOf = func(taskID string) int {
+		if level, ok := levels[taskID]; ok {
+			return level
+		}
+		level := 0
+		for _, dep := range e.taskDependencies(taskID) {
+			if depLevel := levelOf(dep) + 1; depLevel > level {
+				level = depLevel
+			}
+		}
+		levels[taskID] = level
+		return level
+	}
+
+	batches := [][]string{}
+	for _, v := range e.TaskGraph.Vertices() {
+		taskID := dag.VertexName(v)
+		if strings.Contains(taskID, ROOT_NODE_NAME) {
+			continue
+		}
+		level := levelOf(taskID)
+		for len(batches) <= level {
+			batches = append(batches, []string{})
+		}
+		batches[level] = append(batches[level], taskID)
+	}
+	for _, batch := range batches {
+		sort.Strings(batch)
+	}
+	return batches, nil
+}
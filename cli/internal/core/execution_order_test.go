@@ -0,0 +1,45 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/util"
+	"gotest.tools/v3/assert"
+
+	"github.com/pyr-sh/dag"
+)
+
+func TestExecutionOrder(t *testing.T) {
+	graph := &dag.AcyclicGraph{}
+	graph.Add("app")
+	graph.Add("ui")
+	graph.Add("utils")
+	graph.Connect(dag.BasicEdge("app", "ui"))
+	graph.Connect(dag.BasicEdge("ui", "utils"))
+
+	p := NewEngine(graph)
+	_, err := p.ExecutionOrder()
+	assert.ErrorContains(t, err, "only known once the engine is prepared")
+
+	topoDeps := make(util.Set)
+	topoDeps.Add("build")
+	p.AddTask(&Task{Name: "build", TopoDeps: topoDeps, Deps: make(util.Set)})
+	deps := make(util.Set)
+	deps.Add("build")
+	p.AddTask(&Task{Name: "test", TopoDeps: make(util.Set), Deps: deps})
+	p.AddTask(&Task{Name: "lint", TopoDeps: make(util.Set), Deps: make(util.Set)})
+	err = p.Prepare(&EngineBuildingOptions{
+		Packages:  []string{"app", "ui", "utils"},
+		TaskNames: []string{"build", "test", "lint"},
+	})
+	assert.NilError(t, err, "Prepare")
+
+	order, err := p.ExecutionOrder()
+	assert.NilError(t, err)
+	assert.DeepEqual(t, order, [][]string{
+		{"app#lint", "ui#lint", "utils#build", "utils#lint"},
+		{"ui#build", "utils#test"},
+		{"app#build", "ui#test"},
+		{"app#test"},
+	})
+}
@@ -0,0 +1,36 @@
+package core
+
+import (
+	"sort"
+
+	"github.com/pyr-sh/dag"
+)
+
+// FanInInfo describes a task and how many tasks depend on it directly
+type FanInInfo struct {
+	TaskID     string `json:"taskId"`
+	Dependents int    `json:"dependents"`
+}
+
+// HighFanInTasks returns the tasks in the prepared TaskGraph with more than threshold
+// direct dependents, which are likely to be bottlenecks. They are sorted by the
+// number of dependents, most first, and then by task ID.
+func (e *Engine) HighFanInTasks(threshold int) []FanInInfo {
+	tasks := []FanInInfo{}
+	for _, v := range e.TaskGraph.Vertices() {
+		taskID := dag.VertexName(v)
+		if taskID == ROOT_NODE_NAME {
+			continue
+		}
+		if dependents := e.TaskGraph.UpEdges(taskID).Len(); dependents > threshold {
+			tasks = append(tasks, FanInInfo{TaskID: taskID, Dependents: dependents})
+		}
+	}
+	sort.Slice(tasks, func(i, j int) bool {
+		if tasks[i].Dependents != tasks[j].Dependents {
+			return tasks[i].Dependents > tasks[j].Dependents
+		}
+		return tasks[i].TaskID < tasks[j].TaskID
+	})
+	return tasks
+}
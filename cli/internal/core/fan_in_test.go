@@ -0,0 +1,51 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/util"
+	"gotest.tools/v3/assert"
+
+	"github.com/pyr-sh/dag"
+)
+
+func TestHighFanInTasks(t *testing.T) {
+	// app1 and app2 depend on libA and libB, and everything depends on config
+	graph := &dag.AcyclicGraph{}
+	for _, pkg := range []string{"app1", "app2", "libA", "libB", "config"} {
+		graph.Add(pkg)
+	}
+	graph.Add(ROOT_NODE_NAME)
+	for _, pkg := range []string{"app1", "app2"} {
+		graph.Connect(dag.BasicEdge(pkg, "libA"))
+		graph.Connect(dag.BasicEdge(pkg, "libB"))
+	}
+	for _, pkg := range []string{"app1", "app2", "libA", "libB"} {
+		graph.Connect(dag.BasicEdge(pkg, "config"))
+	}
+	graph.Connect(dag.BasicEdge("config", ROOT_NODE_NAME))
+
+	p := NewEngine(graph)
+	topoDeps := make(util.Set)
+	topoDeps.Add("build")
+	p.AddTask(&Task{
+		Name:     "build",
+		TopoDeps: topoDeps,
+		Deps:     make(util.Set),
+	})
+	err := p.Prepare(&EngineBuildingOptions{
+		Packages:  []string{"app1", "app2", "libA", "libB", "config"},
+		TaskNames: []string{"build"},
+	})
+	assert.NilError(t, err, "Prepare")
+
+	assert.DeepEqual(t, p.HighFanInTasks(1), []FanInInfo{
+		{TaskID: "config#build", Dependents: 4},
+		{TaskID: "libA#build", Dependents: 2},
+		{TaskID: "libB#build", Dependents: 2},
+	})
+	assert.DeepEqual(t, p.HighFanInTasks(2), []FanInInfo{
+		{TaskID: "config#build", Dependents: 4},
+	})
+	assert.DeepEqual(t, p.HighFanInTasks(4), []FanInInfo{})
+}
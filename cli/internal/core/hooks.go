@@ -0,0 +1,128 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pyr-sh/dag"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+const (
+	// HookPre is a root task that runs before every other task in a run
+	HookPre = "pre"
+	// HookPost is a root task that runs after every other task in a run
+	HookPost = "post"
+)
+
+// hookTasks returns the ids of the root tasks that run before and after every
+// other task, sorted. Hooks whose script isn't defined in the root package.json
+// are left out.
+func (e *Engine) hookTasks(packageInfos map[interface{}]*fs.PackageJSON) ([]string, []string, error) {
+	pre := []string{}
+	post := []string{}
+	for name, task := range e.tasks {
+		if task.Hook == "" {
+			continue
+		}
+		if task.Hook != HookPre && task.Hook != HookPost {
+			return nil, nil, fmt.Errorf("%v: invalid hook %q: expected %v or %v", name, task.Hook, HookPre, HookPost)
+		}
+		if !util.IsPackageTask(name) || !strings.HasPrefix(name, util.RootPkgName+util.TaskDelimiter) {
+			return nil, nil, fmt.Errorf("%v: only root tasks, like //#<task>, can be hooks", name)
+		}
+		taskName := util.RootTaskTaskName(name)
+		if task.Persistent {
+			return nil, nil, fmt.Errorf("%v: a hook can't be persistent, since it would never finish", name)
+		}
+		rootPkg, ok := packageInfos[util.RootPkgName]
+		if !ok {
+			continue
+		}
+		if _, ok := rootPkg.Scripts[taskName]; !ok {
+			continue
+		}
+		if task.Hook == HookPre {
+			pre = append(pre, name)
+		} else {
+			post = append(post, name)
+		}
+	}
+	sort.Strings(pre)
+	sort.Strings(post)
+	return pre, post, nil
+}
+
+// insertHookEdges makes every task in the TaskGraph depend on the pre hooks, and
+// the post hooks depend on every task, except for the tasks that the pre hooks
+// depend on and that depend on the post hooks. Post hooks don't wait for
+// persistent tasks that don't allow dependents, since they never finish.
+func (e *Engine) insertHookEdges(pre []string, post []string) error {
+	if len(pre) == 0 && len(post) == 0 {
+		return nil
+	}
+	hooks := make(util.Set)
+	beforePre := make(util.Set)
+	for _, hook := range pre {
+		hooks.Add(hook)
+		deps, err := e.TaskGraph.Ancestors(hook)
+		if err != nil {
+			return err
+		}
+		for _, dep := range deps {
+			beforePre.Add(dag.VertexName(dep))
+		}
+	}
+	afterPost := make(util.Set)
+	for _, hook := range post {
+		hooks.Add(hook)
+		dependents, err := e.TaskGraph.Descendents(hook)
+		if err != nil {
+			return err
+		}
+		for _, dependent := range dependents {
+			afterPost.Add(dag.VertexName(dependent))
+		}
+	}
+
+	for _, v := range e.TaskGraph.Vertices() {
+		taskID := dag.VertexName(v)
+		if strings.Contains(taskID, ROOT_NODE_NAME) || hooks.Includes(taskID) {
+			continue
+		}
+		if !beforePre.Includes(taskID) {
+			for _, hook := range pre {
+				e.connectHook(taskID, hook)
+			}
+		}
+		if afterPost.Includes(taskID) {
+			continue
+		}
+		pkg, taskName := util.GetPackageTaskFromId(taskID)
+		task, err := e.getTaskDefinition(pkg, taskName, taskID)
+		if err != nil {
+			return err
+		}
+		if task.Persistent && !task.AllowDependents {
+			continue
+		}
+		for _, hook := range post {
+			e.connectHook(hook, taskID)
+		}
+	}
+	for _, postHook := range post {
+		for _, preHook := range pre {
+			e.connectHook(postHook, preHook)
+		}
+	}
+	return nil
+}
+
+// connectHook makes taskID depend on depTaskID, which it no longer needs the
+// root node for
+func (e *Engine) connectHook(taskID string, depTaskID string) {
+	e.TaskGraph.RemoveEdge(dag.BasicEdge(taskID, ROOT_NODE_NAME))
+	e.TaskGraph.Connect(dag.BasicEdge(taskID, depTaskID))
+}
@@ -0,0 +1,133 @@
+package core
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/util"
+	"gotest.tools/v3/assert"
+
+	"github.com/pyr-sh/dag"
+)
+
+func newHookTestEngine(rootScripts ...string) (*Engine, map[interface{}]*fs.PackageJSON) {
+	graph := &dag.AcyclicGraph{}
+	graph.Add("app")
+	graph.Add("ui")
+	graph.Connect(dag.BasicEdge("app", "ui"))
+
+	p := newTestEngine(graph, []*Task{
+		{Name: "build", TopoDeps: util.SetFromStrings([]string{"build"})},
+		{Name: "lint"},
+		{Name: "//#predeploy", Hook: HookPre},
+		{Name: "//#postdeploy", Hook: HookPost},
+	})
+
+	scripts := make(map[string]string)
+	for _, script := range rootScripts {
+		scripts[script] = script
+	}
+	packageInfos := map[interface{}]*fs.PackageJSON{
+		util.RootPkgName: {Scripts: scripts},
+		"app":            {Scripts: map[string]string{"build": "build", "lint": "lint"}},
+		"ui":             {Scripts: map[string]string{"build": "build", "lint": "lint"}},
+	}
+	return p, packageInfos
+}
+
+func TestHooks(t *testing.T) {
+	p, packageInfos := newHookTestEngine("predeploy", "postdeploy")
+	err := p.Prepare(&EngineBuildingOptions{
+		Packages:     []string{"app", "ui"},
+		TaskNames:    []string{"build", "lint"},
+		PackageInfos: packageInfos,
+	})
+	assert.NilError(t, err, "Prepare")
+
+	order, err := p.ExecutionOrder()
+	assert.NilError(t, err)
+	assert.DeepEqual(t, order, [][]string{
+		{"//#predeploy"},
+		{"app#lint", "ui#build", "ui#lint"},
+		{"app#build"},
+		{"//#postdeploy"},
+	})
+}
+
+func TestHooksSkippedWithoutScript(t *testing.T) {
+	p, packageInfos := newHookTestEngine("postdeploy")
+	err := p.Prepare(&EngineBuildingOptions{
+		Packages:     []string{"app", "ui"},
+		TaskNames:    []string{"build"},
+		PackageInfos: packageInfos,
+	})
+	assert.NilError(t, err, "Prepare")
+
+	order, err := p.ExecutionOrder()
+	assert.NilError(t, err)
+	assert.DeepEqual(t, order, [][]string{
+		{"ui#build"},
+		{"app#build"},
+		{"//#postdeploy"},
+	})
+}
+
+func TestFailingPreHookHaltsRun(t *testing.T) {
+	p, packageInfos := newHookTestEngine("predeploy", "postdeploy")
+	err := p.Prepare(&EngineBuildingOptions{
+		Packages:     []string{"app", "ui"},
+		TaskNames:    []string{"build", "lint"},
+		PackageInfos: packageInfos,
+	})
+	assert.NilError(t, err, "Prepare")
+
+	visited := &sync.Map{}
+	errs := p.Execute(failingVisitor([]string{"//#predeploy"}, visited), EngineExecutionOptions{Concurrency: 10, ContinueOnError: true})
+	assert.Equal(t, len(errs), 1)
+	assert.ErrorContains(t, errs[0], "//#predeploy failed")
+	visited.Range(func(key, value interface{}) bool {
+		assert.Equal(t, key, "//#predeploy", "expected no task to run after the pre hook failed")
+		return true
+	})
+}
+
+func TestPostHookSkipsPersistentTasks(t *testing.T) {
+	p, packageInfos := newHookTestEngine("postdeploy")
+	p.AddTask(&Task{Name: "dev", TopoDeps: make(util.Set), Deps: make(util.Set), Persistent: true})
+	err := p.Prepare(&EngineBuildingOptions{
+		Packages:     []string{"app", "ui"},
+		TaskNames:    []string{"build", "dev"},
+		PackageInfos: packageInfos,
+	})
+	assert.NilError(t, err, "Prepare")
+	assert.NilError(t, p.ValidatePersistentDependencies(func(taskID string) bool { return true }))
+
+	order, err := p.ExecutionOrder()
+	assert.NilError(t, err)
+	assert.DeepEqual(t, order, [][]string{
+		{"app#dev", "ui#build", "ui#dev"},
+		{"app#build"},
+		{"//#postdeploy"},
+	})
+}
+
+func TestInvalidHooks(t *testing.T) {
+	p, packageInfos := newHookTestEngine("predeploy")
+	p.AddTask(&Task{Name: "//#serve", TopoDeps: make(util.Set), Deps: make(util.Set), Hook: HookPre, Persistent: true})
+	err := p.Prepare(&EngineBuildingOptions{
+		Packages:     []string{"app", "ui"},
+		TaskNames:    []string{"build"},
+		PackageInfos: packageInfos,
+	})
+	assert.ErrorContains(t, err, "//#serve: a hook can't be persistent")
+
+	p, packageInfos = newHookTestEngine("predeploy")
+	p.AddTask(&Task{Name: "app#setup", TopoDeps: make(util.Set), Deps: make(util.Set), Hook: HookPre})
+	err = p.Prepare(&EngineBuildingOptions{
+		Packages:     []string{"app", "ui"},
+		TaskNames:    []string{"build"},
+		PackageInfos: packageInfos,
+	})
+	assert.ErrorContains(t, err, "app#setup: only root tasks, like //#<task>, can be hooks")
+}
@@ -0,0 +1,54 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	"github.com/pyr-sh/dag"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// validateMinTurboVersions returns an error if a task in the TaskGraph requires
+// a newer turbo than turboVersion. Only the tasks being run are checked, so a
+// pipeline entry for a newer turbo doesn't stop the rest of the pipeline from
+// running. If turboVersion isn't a valid version, such as for a development
+// build, nothing is checked.
+func (e *Engine) validateMinTurboVersions(turboVersion string) error {
+	if turboVersion == "" {
+		return nil
+	}
+	current, err := semver.NewVersion(turboVersion)
+	if err != nil {
+		return nil
+	}
+	errs := []string{}
+	for _, v := range e.TaskGraph.Vertices() {
+		taskID := dag.VertexName(v)
+		if strings.Contains(taskID, ROOT_NODE_NAME) {
+			continue
+		}
+		pkg, taskName := util.GetPackageTaskFromId(taskID)
+		task, err := e.getTaskDefinition(pkg, taskName, taskID)
+		if err != nil {
+			return err
+		}
+		if task.MinTurboVersion == "" {
+			continue
+		}
+		required, err := semver.NewVersion(task.MinTurboVersion)
+		if err != nil {
+			return fmt.Errorf("%v: invalid minTurboVersion %q: %w", taskID, task.MinTurboVersion, err)
+		}
+		if current.LessThan(required) {
+			errs = append(errs, fmt.Sprintf("%v requires turbo %v or newer, but turbo %v is running. Upgrade turbo to run it.", taskID, task.MinTurboVersion, turboVersion))
+		}
+	}
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return errors.New(strings.Join(errs, "\n"))
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/util"
+	"gotest.tools/v3/assert"
+
+	"github.com/pyr-sh/dag"
+)
+
+func prepareMinTurboVersionEngine(taskNames []string, turboVersion string) error {
+	graph := &dag.AcyclicGraph{}
+	graph.Add("web")
+	graph.Add(ROOT_NODE_NAME)
+	graph.Connect(dag.BasicEdge("web", ROOT_NODE_NAME))
+
+	p := newTestEngine(graph, []*Task{
+		{Name: "build", MinTurboVersion: "1.8.0"},
+		{Name: "lint"},
+		{Name: "deploy", Deps: util.SetFromStrings([]string{"build"}), MinTurboVersion: "1.9.0"},
+	})
+	return p.Prepare(&EngineBuildingOptions{
+		Packages:     []string{"web"},
+		TaskNames:    taskNames,
+		TurboVersion: turboVersion,
+	})
+}
+
+func TestMinTurboVersion(t *testing.T) {
+	assert.NilError(t, prepareMinTurboVersionEngine([]string{"deploy"}, "1.9.0"))
+	assert.NilError(t, prepareMinTurboVersionEngine([]string{"deploy"}, "2.0.0"))
+	// Tasks that aren't being run don't need a newer turbo
+	assert.NilError(t, prepareMinTurboVersionEngine([]string{"lint"}, "1.7.0"))
+	assert.NilError(t, prepareMinTurboVersionEngine([]string{"build"}, "1.8.2"))
+	// Development builds aren't checked
+	assert.NilError(t, prepareMinTurboVersionEngine([]string{"deploy"}, ""))
+
+	err := prepareMinTurboVersionEngine([]string{"deploy"}, "1.8.2")
+	assert.Error(t, err, "web#deploy requires turbo 1.9.0 or newer, but turbo 1.8.2 is running. Upgrade turbo to run it.")
+
+	err = prepareMinTurboVersionEngine([]string{"deploy"}, "1.8.0-canary.1")
+	assert.Error(t, err, "web#build requires turbo 1.8.0 or newer, but turbo 1.8.0-canary.1 is running. Upgrade turbo to run it.\n"+
+		"web#deploy requires turbo 1.9.0 or newer, but turbo 1.8.0-canary.1 is running. Upgrade turbo to run it.")
+}
+
+func TestMinTurboVersionInvalid(t *testing.T) {
+	graph := &dag.AcyclicGraph{}
+	graph.Add("web")
+	p := newTestEngine(graph, []*Task{{Name: "build", MinTurboVersion: "latest"}})
+
+	err := p.Prepare(&EngineBuildingOptions{
+		Packages:     []string{"web"},
+		TaskNames:    []string{"build"},
+		TurboVersion: "1.8.0",
+	})
+	assert.ErrorContains(t, err, "web#build: invalid minTurboVersion \"latest\"")
+}
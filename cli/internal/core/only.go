@@ -0,0 +1,124 @@
+package core
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pyr-sh/dag"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// stripDependencies removes every task but the given tasks in the given packages
+// from the TaskGraph, so that only they are executed. The tasks they depend on
+// are assumed to be built already, so the TaskGraph from before is kept as the
+// hashGraph, for their hashes to still be included in the hashes of the tasks
+// that depend on them.
+func (e *Engine) stripDependencies(pkgs []string, taskNames []string) {
+	selected := make(util.Set)
+	for _, pkg := range pkgs {
+		for _, taskName := range taskNames {
+			taskID := util.GetTaskId(pkg, taskName)
+			if e.TaskGraph.HasVertex(taskID) {
+				selected.Add(taskID)
+			}
+		}
+	}
+
+	e.hashGraph = e.TaskGraph
+	e.TaskGraph = &dag.AcyclicGraph{}
+	for _, taskID := range selected.UnsafeListOfStrings() {
+		e.TaskGraph.Add(ROOT_NODE_NAME)
+		e.TaskGraph.Add(taskID)
+		e.TaskGraph.Connect(dag.BasicEdge(taskID, ROOT_NODE_NAME))
+	}
+
+	// Nothing waits for the tasks that were removed
+	filter := func(deps map[string][]string) {
+		for taskID, others := range deps {
+			if !selected.Includes(taskID) {
+				delete(deps, taskID)
+				continue
+			}
+			kept := []string{}
+			for _, other := range others {
+				if selected.Includes(other) {
+					kept = append(kept, other)
+				}
+			}
+			if len(kept) == 0 {
+				delete(deps, taskID)
+			} else {
+				deps[taskID] = kept
+			}
+		}
+	}
+	filter(e.startsAfter)
+	filter(e.streamingDeps)
+	if e.shuffledOrder != nil {
+		shuffledOrder := []string{}
+		for _, taskID := range e.shuffledOrder {
+			if selected.Includes(taskID) {
+				shuffledOrder = append(shuffledOrder, taskID)
+			}
+		}
+		e.shuffledOrder = shuffledOrder
+	}
+}
+
+// HashGraph returns the graph of tasks whose hashes a run calculates, with the
+// dependencies that are part of each task's hash. It is the TaskGraph, unless
+// the engine was prepared with TasksOnly, in which case it also holds the tasks
+// that are assumed to be built. See AssumedBuilt.
+func (e *Engine) HashGraph() *dag.AcyclicGraph {
+	if e.hashGraph != nil {
+		return e.hashGraph
+	}
+	return e.TaskGraph
+}
+
+// AssumedBuilt returns the tasks that the tasks in the TaskGraph depend on, but
+// that aren't executed because the engine was prepared with TasksOnly. Each task
+// is ordered after the tasks it depends on, so that their hashes can be
+// calculated in order.
+func (e *Engine) AssumedBuilt() []string {
+	if e.hashGraph == nil {
+		return nil
+	}
+	taskIDs := []string{}
+	for _, v := range e.hashGraph.Vertices() {
+		taskID := dag.VertexName(v)
+		if !e.TaskGraph.HasVertex(taskID) && !strings.Contains(taskID, ROOT_NODE_NAME) {
+			taskIDs = append(taskIDs, taskID)
+		}
+	}
+	sort.Strings(taskIDs)
+
+	assumed := make(util.Set)
+	for _, taskID := range taskIDs {
+		assumed.Add(taskID)
+	}
+	visited := make(util.Set)
+	order := []string{}
+	var visit func(taskID string)
+	visit = func(taskID string) {
+		if visited.Includes(taskID) {
+			return
+		}
+		visited.Add(taskID)
+		deps := []string{}
+		for _, dep := range e.hashGraph.DownEdges(taskID) {
+			deps = append(deps, dag.VertexName(dep))
+		}
+		sort.Strings(deps)
+		for _, dep := range deps {
+			if assumed.Includes(dep) {
+				visit(dep)
+			}
+		}
+		order = append(order, taskID)
+	}
+	for _, taskID := range taskIDs {
+		visit(taskID)
+	}
+	return order
+}
@@ -0,0 +1,147 @@
+package core
+
+import (
+	"bytes"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/pyr-sh/dag"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// taskOutput is the output of a task, held back until it can be flushed in order
+type taskOutput struct {
+	w   io.Writer
+	buf bytes.Buffer
+	// done is set once the task has finished, or been skipped
+	done    bool
+	flushed bool
+}
+
+// resolveOutputOrder ranks each task in the TaskGraph whose name is in the
+// given output order by its position in it. Persistent tasks never finish, so
+// their output is never held back and they are left unranked.
+func (e *Engine) resolveOutputOrder(outputOrder []string) {
+	e.outputRanks = make(map[string]int)
+	if len(outputOrder) == 0 {
+		return
+	}
+	ranks := make(map[string]int, len(outputOrder))
+	for i, taskName := range outputOrder {
+		if _, ok := ranks[taskName]; !ok {
+			ranks[taskName] = i
+		}
+	}
+	for _, v := range e.TaskGraph.Vertices() {
+		taskID := dag.VertexName(v)
+		if strings.Contains(taskID, ROOT_NODE_NAME) {
+			continue
+		}
+		pkg, taskName := util.GetPackageTaskFromId(taskID)
+		rank, ok := ranks[taskName]
+		if !ok {
+			continue
+		}
+		if task, err := e.getTaskDefinition(pkg, taskName, taskID); err != nil || task.Persistent {
+			continue
+		}
+		e.outputRanks[taskID] = rank
+	}
+	e.outputOrdered = true
+}
+
+// GroupedOutput returns a writer for the output of the given task that holds it
+// back until the task has finished, and every task whose name comes earlier in
+// EngineBuildingOptions.OutputOrder has been flushed, then writes it to w in one
+// piece. Tasks whose names aren't in the OutputOrder are flushed as soon as they
+// finish. Without an OutputOrder, and for persistent tasks, w is returned as is.
+func (e *Engine) GroupedOutput(taskID string, w io.Writer) io.Writer {
+	if !e.outputOrdered {
+		return w
+	}
+	pkg, taskName := util.GetPackageTaskFromId(taskID)
+	if task, err := e.getTaskDefinition(pkg, taskName, taskID); err != nil || task.Persistent {
+		return w
+	}
+	e.outputMu.Lock()
+	defer e.outputMu.Unlock()
+	output := e.taskOutput(taskID)
+	output.w = w
+	return &groupedWriter{e: e, output: output}
+}
+
+type groupedWriter struct {
+	e      *Engine
+	output *taskOutput
+}
+
+func (gw *groupedWriter) Write(p []byte) (int, error) {
+	gw.e.outputMu.Lock()
+	defer gw.e.outputMu.Unlock()
+	if gw.output.flushed {
+		return gw.output.w.Write(p)
+	}
+	return gw.output.buf.Write(p)
+}
+
+// taskOutput returns the output of the given task during the current execution.
+// outputMu must be held.
+func (e *Engine) taskOutput(taskID string) *taskOutput {
+	output, ok := e.outputs[taskID]
+	if !ok {
+		output = &taskOutput{}
+		e.outputs[taskID] = output
+	}
+	return output
+}
+
+// finishOutput marks the output of the given task as complete, and flushes the
+// output of every task that no longer has to wait on another
+func (e *Engine) finishOutput(taskID string) {
+	if !e.outputOrdered {
+		return
+	}
+	e.outputMu.Lock()
+	defer e.outputMu.Unlock()
+	e.taskOutput(taskID).done = true
+	e.flushOutputs()
+}
+
+// flushOutputs writes out, in order, the completed outputs that aren't waiting
+// on an unflushed task ranked before them. outputMu must be held.
+func (e *Engine) flushOutputs() {
+	// pendingRank is the lowest rank of a task that hasn't been flushed yet
+	pendingRank := -1
+	for taskID, rank := range e.outputRanks {
+		if output, ok := e.outputs[taskID]; ok && output.flushed {
+			continue
+		}
+		if pendingRank == -1 || rank < pendingRank {
+			pendingRank = rank
+		}
+	}
+	ready := []string{}
+	for taskID, output := range e.outputs {
+		if !output.done || output.flushed {
+			continue
+		}
+		if rank, ok := e.outputRanks[taskID]; ok && rank != pendingRank {
+			continue
+		}
+		ready = append(ready, taskID)
+	}
+	if len(ready) == 0 {
+		return
+	}
+	sort.Strings(ready)
+	for _, taskID := range ready {
+		output := e.outputs[taskID]
+		output.flushed = true
+		if output.w != nil {
+			_, _ = output.buf.WriteTo(output.w)
+		}
+	}
+	// Flushing the last task of a rank releases the tasks of the next one
+	e.flushOutputs()
+}
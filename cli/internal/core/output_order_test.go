@@ -0,0 +1,98 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"testing"
+
+	"gotest.tools/v3/assert"
+
+	"github.com/pyr-sh/dag"
+)
+
+func prepareOutputOrderEngine(t *testing.T, outputOrder []string) *Engine {
+	graph := &dag.AcyclicGraph{}
+	graph.Add("web")
+	graph.Add(ROOT_NODE_NAME)
+	graph.Connect(dag.BasicEdge("web", ROOT_NODE_NAME))
+
+	tasks := []*Task{{Name: "typecheck"}, {Name: "test"}, {Name: "lint"}}
+	return prepareTestEngine(t, graph, tasks, &EngineBuildingOptions{
+		Packages:    []string{"web"},
+		TaskNames:   []string{"typecheck", "test", "lint"},
+		OutputOrder: outputOrder,
+	})
+}
+
+func TestOutputOrder(t *testing.T) {
+	p := prepareOutputOrderEngine(t, []string{"typecheck", "test"})
+
+	var out bytes.Buffer
+	var outMu sync.Mutex
+	w := writerFunc(func(b []byte) (int, error) {
+		outMu.Lock()
+		defer outMu.Unlock()
+		return out.Write(b)
+	})
+	// typecheck finishes last, after test and lint have written their output
+	testDone := make(chan struct{})
+	lintDone := make(chan struct{})
+	errs := p.Execute(func(taskID string) error {
+		taskOut := p.GroupedOutput(taskID, w)
+		switch taskID {
+		case "web#typecheck":
+			<-testDone
+			<-lintDone
+		case "web#test":
+			defer close(testDone)
+		case "web#lint":
+			defer close(lintDone)
+		}
+		_, err := fmt.Fprintf(taskOut, "%v 1\n%v 2\n", taskID, taskID)
+		return err
+	}, EngineExecutionOptions{Parallel: true, Concurrency: 10})
+	assert.Equal(t, len(errs), 0)
+
+	// lint isn't in the output order, so it is shown as soon as it finishes
+	assert.Equal(t, out.String(), "web#lint 1\nweb#lint 2\nweb#typecheck 1\nweb#typecheck 2\nweb#test 1\nweb#test 2\n")
+}
+
+func TestOutputOrderAfterFailure(t *testing.T) {
+	p := prepareOutputOrderEngine(t, []string{"typecheck", "test"})
+
+	var out bytes.Buffer
+	var outMu sync.Mutex
+	w := writerFunc(func(b []byte) (int, error) {
+		outMu.Lock()
+		defer outMu.Unlock()
+		return out.Write(b)
+	})
+	errs := p.Execute(func(taskID string) error {
+		if taskID == "web#typecheck" {
+			return fmt.Errorf("typecheck failed")
+		}
+		if taskID == "web#test" {
+			_, err := fmt.Fprintf(p.GroupedOutput(taskID, w), "%v\n", taskID)
+			return err
+		}
+		return nil
+	}, EngineExecutionOptions{Parallel: true, Concurrency: 10, ContinueOnError: true})
+	assert.Equal(t, len(errs), 1)
+
+	// A task that wrote nothing doesn't hold back the tasks after it
+	assert.Equal(t, out.String(), "web#test\n")
+}
+
+func TestWithoutOutputOrder(t *testing.T) {
+	p := prepareOutputOrderEngine(t, nil)
+
+	var out bytes.Buffer
+	assert.Equal(t, p.GroupedOutput("web#test", &out), &out)
+}
+
+type writerFunc func([]byte) (int, error)
+
+func (f writerFunc) Write(b []byte) (int, error) {
+	return f(b)
+}
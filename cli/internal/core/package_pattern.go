@@ -0,0 +1,68 @@
+package core
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// isPackagePattern returns true if the package of a package-task dependency is a
+// glob pattern, like "@acme/ui-*", rather than the name of a single package
+func isPackagePattern(pkg string) bool {
+	return strings.ContainsAny(pkg, "*?[")
+}
+
+// matchesPackage returns true if the package name matches the package of a
+// package-task dependency, which is either a package name or a pattern
+func matchesPackage(pattern string, pkg string) bool {
+	if !isPackagePattern(pattern) {
+		return pattern == pkg
+	}
+	// A malformed pattern matches no packages
+	matched, _ := path.Match(pattern, pkg)
+	return matched
+}
+
+// expandPackagePattern returns the task ids that a package-task dependency
+// refers to, sorted. A dependency on a single package refers to itself. A
+// dependency on a pattern refers to the task in every matching package, except
+// for the task that declares the dependency, and to nothing without a match.
+func expandPackagePattern(packageInfos map[interface{}]*fs.PackageJSON, taskID string, dependent string) []string {
+	pkgPattern, taskName := util.GetPackageTaskFromId(taskID)
+	if !isPackagePattern(pkgPattern) {
+		return []string{taskID}
+	}
+	matches := []string{}
+	for name := range packageInfos {
+		pkg := fmt.Sprintf("%v", name)
+		if pkg == util.RootPkgName || !matchesPackage(pkgPattern, pkg) {
+			continue
+		}
+		if matchedTaskID := util.GetTaskId(pkg, taskName); matchedTaskID != dependent {
+			matches = append(matches, matchedTaskID)
+		}
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+// resolvePackagePatternDependencies replaces dependencies on a task in every
+// package matching a pattern with the task ids of those tasks
+func (e *Engine) resolvePackagePatternDependencies(packageInfos map[interface{}]*fs.PackageJSON) {
+	for toTaskID, fromTaskIDs := range e.PackageTaskDeps {
+		resolved := []string{}
+		for _, fromTaskID := range fromTaskIDs {
+			resolved = append(resolved, expandPackagePattern(packageInfos, fromTaskID, toTaskID)...)
+		}
+		if len(resolved) == 0 {
+			// Like a task without dependencies, it only depends on the root node
+			delete(e.PackageTaskDeps, toTaskID)
+			continue
+		}
+		e.PackageTaskDeps[toTaskID] = resolved
+	}
+}
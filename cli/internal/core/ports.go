@@ -0,0 +1,47 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pyr-sh/dag"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// validatePorts returns an error if two persistent tasks in the TaskGraph declare
+// the same port, since they would run at the same time and the second to start
+// would fail to listen on it. Tasks that don't declare a port, or pick one
+// dynamically, are not checked.
+func (e *Engine) validatePorts() error {
+	portTasks := make(map[int][]string)
+	for _, v := range e.TaskGraph.Vertices() {
+		taskID := dag.VertexName(v)
+		if strings.Contains(taskID, ROOT_NODE_NAME) {
+			continue
+		}
+		pkg, taskName := util.GetPackageTaskFromId(taskID)
+		task, err := e.getTaskDefinition(pkg, taskName, taskID)
+		if err != nil {
+			return err
+		}
+		if !task.Persistent || task.Port == 0 {
+			continue
+		}
+		portTasks[task.Port] = append(portTasks[task.Port], taskID)
+	}
+	errs := []string{}
+	for port, taskIDs := range portTasks {
+		if len(taskIDs) < 2 {
+			continue
+		}
+		sort.Strings(taskIDs)
+		errs = append(errs, fmt.Sprintf("%v and %v both listen on port %v. Give each persistent task its own port.", strings.Join(taskIDs[:len(taskIDs)-1], ", "), taskIDs[len(taskIDs)-1], port))
+	}
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return errors.New(strings.Join(errs, "\n"))
+	}
+	return nil
+}
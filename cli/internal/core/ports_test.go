@@ -0,0 +1,55 @@
+package core
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+
+	"github.com/pyr-sh/dag"
+)
+
+func preparePortsEngine(tasks ...*Task) error {
+	graph := &dag.AcyclicGraph{}
+	graph.Add("web")
+	graph.Add("docs")
+	graph.Add(ROOT_NODE_NAME)
+	graph.Connect(dag.BasicEdge("web", ROOT_NODE_NAME))
+	graph.Connect(dag.BasicEdge("docs", ROOT_NODE_NAME))
+
+	taskNames := []string{}
+	for _, task := range tasks {
+		taskNames = append(taskNames, task.Name)
+	}
+	return newTestEngine(graph, tasks).Prepare(&EngineBuildingOptions{
+		Packages:  []string{"web", "docs"},
+		TaskNames: taskNames,
+	})
+}
+
+func TestConflictingPorts(t *testing.T) {
+	err := preparePortsEngine(
+		&Task{Name: "web#dev", Persistent: true, Port: 3000},
+		&Task{Name: "docs#dev", Persistent: true, Port: 3000},
+		&Task{Name: "storybook", Persistent: true, Port: 6006},
+	)
+	assert.Error(t, err, "docs#dev and web#dev both listen on port 3000. Give each persistent task its own port.\n"+
+		"docs#storybook and web#storybook both listen on port 6006. Give each persistent task its own port.")
+}
+
+func TestDistinctPorts(t *testing.T) {
+	err := preparePortsEngine(
+		&Task{Name: "web#dev", Persistent: true, Port: 3000},
+		&Task{Name: "docs#dev", Persistent: true, Port: 3001},
+	)
+	assert.NilError(t, err)
+}
+
+func TestPortsExemptions(t *testing.T) {
+	err := preparePortsEngine(
+		// Dynamic ports can't conflict
+		&Task{Name: "dev", Persistent: true, Port: 0},
+		// Tasks that aren't persistent don't run alongside each other for long
+		&Task{Name: "e2e", Port: 4000},
+	)
+	assert.NilError(t, err)
+}
@@ -0,0 +1,157 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pyr-sh/dag"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+const (
+	// DefaultProbeInterval is how long a ReadinessProbe waits between polls by default
+	DefaultProbeInterval = 500 * time.Millisecond
+	// DefaultProbeTimeout is how long a persistent task has to pass its
+	// ReadinessProbe by default
+	DefaultProbeTimeout = time.Minute
+)
+
+// ReadinessProbe is polled once a persistent task starts, and the tasks that wait
+// for it to be ready start once the probe passes. Exactly one of Command, Port,
+// URL and Check must be set.
+type ReadinessProbe struct {
+	// Command is a shell command that exits successfully once the task is ready
+	Command string
+	// Port is a local TCP port that accepts connections once the task is ready
+	Port int
+	// URL is an HTTP URL that responds with a status below 400 once the task is ready
+	URL string
+	// Check, if set, returns nil once the given task is ready
+	Check func(ctx context.Context, taskID string) error
+	// Interval is how long to wait between polls. If zero, it is DefaultProbeInterval.
+	Interval time.Duration
+	// Timeout is how long the task has to pass the probe before it fails, along
+	// with its dependents. If zero, it is DefaultProbeTimeout.
+	Timeout time.Duration
+}
+
+// validate returns an error unless exactly one way of probing is set
+func (p *ReadinessProbe) validate() error {
+	set := 0
+	for _, isSet := range []bool{p.Command != "", p.Port != 0, p.URL != "", p.Check != nil} {
+		if isSet {
+			set++
+		}
+	}
+	if set != 1 {
+		return errors.New("a readiness probe needs exactly one of a command, a port, a URL or a Check function")
+	}
+	if p.Port < 0 || p.Port > 65535 {
+		return fmt.Errorf("invalid readiness probe port %v: must be between 1 and 65535", p.Port)
+	}
+	if p.Interval < 0 || p.Timeout < 0 {
+		return errors.New("the interval and timeout of a readiness probe can't be negative")
+	}
+	return nil
+}
+
+// check polls the probe once, returning nil if the given task is ready
+func (p *ReadinessProbe) check(ctx context.Context, taskID string) error {
+	switch {
+	case p.Check != nil:
+		return p.Check(ctx, taskID)
+	case p.Command != "":
+		var cmd *exec.Cmd
+		if runtime.GOOS == "windows" {
+			cmd = exec.CommandContext(ctx, "cmd", "/C", p.Command)
+		} else {
+			cmd = exec.CommandContext(ctx, "sh", "-c", p.Command)
+		}
+		return cmd.Run()
+	case p.Port != 0:
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort("localhost", strconv.Itoa(p.Port)))
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	default:
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.URL, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode >= 400 {
+			return fmt.Errorf("%v responded with %v", p.URL, resp.Status)
+		}
+		return nil
+	}
+}
+
+// wait polls the probe until the given task is ready, the probe times out, or
+// ctx is done
+func (p *ReadinessProbe) wait(ctx context.Context, taskID string) error {
+	interval := p.Interval
+	if interval == 0 {
+		interval = DefaultProbeInterval
+	}
+	timeout := p.Timeout
+	if timeout == 0 {
+		timeout = DefaultProbeTimeout
+	}
+	deadline, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		err := p.check(deadline, taskID)
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-deadline.Done():
+			return fmt.Errorf("%v did not become ready within %v: %w", taskID, timeout, err)
+		case <-ticker.C:
+		}
+	}
+}
+
+// validateReadinessProbes returns an error if a task in the TaskGraph has a
+// readiness probe that isn't persistent, or that is misconfigured
+func (e *Engine) validateReadinessProbes() error {
+	for _, v := range e.TaskGraph.Vertices() {
+		taskID := dag.VertexName(v)
+		if strings.Contains(taskID, ROOT_NODE_NAME) {
+			continue
+		}
+		pkg, taskName := util.GetPackageTaskFromId(taskID)
+		task, err := e.getTaskDefinition(pkg, taskName, taskID)
+		if err != nil {
+			return err
+		}
+		if task.ReadinessProbe == nil {
+			continue
+		}
+		if !task.Persistent {
+			return fmt.Errorf("%v has a readiness probe, but only persistent tasks can have one", taskID)
+		}
+		if err := task.ReadinessProbe.validate(); err != nil {
+			return fmt.Errorf("%v: %w", taskID, err)
+		}
+	}
+	return nil
+}
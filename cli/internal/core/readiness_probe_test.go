@@ -0,0 +1,154 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vercel/turbo/cli/internal/util"
+	"gotest.tools/v3/assert"
+
+	"github.com/pyr-sh/dag"
+)
+
+// fakeProbe passes once it has been polled the given number of times, or never
+// if that is zero
+type fakeProbe struct {
+	mu        sync.Mutex
+	passAfter int
+	polls     int
+}
+
+func (p *fakeProbe) check(ctx context.Context, taskID string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.polls++
+	if p.passAfter == 0 || p.polls < p.passAfter {
+		return errors.New("not ready")
+	}
+	return nil
+}
+
+// setupProbeEngine returns an engine where app1#build depends on libA#dev, a
+// persistent task with the given readiness probe
+func setupProbeEngine(t *testing.T, probe *ReadinessProbe) *Engine {
+	graph := &dag.AcyclicGraph{}
+	graph.Add("app1")
+	graph.Add("libA")
+
+	tasks := []*Task{
+		{Name: "dev", Persistent: true, AllowDependents: true, ReadinessProbe: probe},
+		{Name: "app1#build", Deps: util.SetFromStrings([]string{"libA#dev"})},
+	}
+	return prepareTestEngine(t, graph, tasks, &EngineBuildingOptions{
+		Packages:  []string{"app1"},
+		TaskNames: []string{"build"},
+	})
+}
+
+// persistentRunner runs persistent tasks until their context is done, and records
+// the other tasks it runs
+type persistentRunner struct {
+	engine *Engine
+	mu     sync.Mutex
+	runs   []string
+}
+
+func (r *persistentRunner) Run(ctx context.Context, taskID string, env []string) (int, error) {
+	if r.engine.IsPersistent(taskID) {
+		<-ctx.Done()
+		return -1, nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.runs = append(r.runs, taskID)
+	return 0, nil
+}
+
+func TestReadinessProbePasses(t *testing.T) {
+	probe := &fakeProbe{passAfter: 3}
+	p := setupProbeEngine(t, &ReadinessProbe{Check: probe.check, Interval: time.Millisecond})
+
+	dependentStarted := make(chan struct{})
+	errs := p.Execute(func(taskID string) error {
+		if taskID == "libA#dev" {
+			select {
+			case <-dependentStarted:
+				return nil
+			case <-time.After(5 * time.Second):
+				return errors.New("app1#build did not start while libA#dev was running")
+			}
+		}
+		// The dependent only starts once the probe has passed
+		probe.mu.Lock()
+		polls := probe.polls
+		probe.mu.Unlock()
+		assert.Equal(t, polls, 3)
+		close(dependentStarted)
+		return nil
+	}, EngineExecutionOptions{Concurrency: 10})
+	assert.Equal(t, len(errs), 0, "%v", errs)
+}
+
+func TestReadinessProbeTimesOut(t *testing.T) {
+	probe := &fakeProbe{}
+	p := setupProbeEngine(t, &ReadinessProbe{Check: probe.check, Interval: time.Millisecond, Timeout: 50 * time.Millisecond})
+
+	runner := &persistentRunner{engine: p}
+	errs := p.Execute(nil, EngineExecutionOptions{Concurrency: 10, Runner: runner})
+
+	// libA#dev is stopped, and it and its dependent fail
+	assert.Equal(t, len(errs), 2, "%v", errs)
+	assert.ErrorContains(t, errs[0], "libA#dev did not become ready within 50ms: not ready")
+	assert.ErrorContains(t, errs[1], "cannot start app1#build: libA#dev did not become ready within 50ms")
+	assert.Equal(t, len(runner.runs), 0)
+	assert.Assert(t, probe.polls > 1)
+}
+
+func TestReadinessProbeTimeoutStopsVisitor(t *testing.T) {
+	probe := &fakeProbe{}
+	p := setupProbeEngine(t, &ReadinessProbe{Check: probe.check, Interval: time.Millisecond, Timeout: 50 * time.Millisecond})
+
+	stopped := make(chan struct{})
+	errs := p.Execute(func(taskID string) error {
+		if taskID == "libA#dev" {
+			select {
+			case <-p.TaskContext(taskID).Done():
+				close(stopped)
+				return nil
+			case <-time.After(5 * time.Second):
+				return errors.New("libA#dev was not stopped when its readiness probe timed out")
+			}
+		}
+		return nil
+	}, EngineExecutionOptions{Concurrency: 10})
+
+	assert.Equal(t, len(errs), 2, "%v", errs)
+	assert.ErrorContains(t, errs[0], "libA#dev did not become ready within 50ms")
+	<-stopped
+	// The context is only for the task while it runs
+	assert.NilError(t, p.TaskContext("libA#dev").Err())
+}
+
+func TestReadinessProbeValidation(t *testing.T) {
+	graph := &dag.AcyclicGraph{}
+	graph.Add("app1")
+
+	p := newTestEngine(graph, []*Task{{Name: "build", ReadinessProbe: &ReadinessProbe{Port: 3000}}})
+	err := p.Prepare(&EngineBuildingOptions{
+		Packages:  []string{"app1"},
+		TaskNames: []string{"build"},
+	})
+	assert.ErrorContains(t, err, "app1#build has a readiness probe, but only persistent tasks can have one")
+
+	p = newTestEngine(graph, []*Task{
+		{Name: "dev", Persistent: true, ReadinessProbe: &ReadinessProbe{Port: 3000, URL: "http://localhost:3000"}},
+	})
+	err = p.Prepare(&EngineBuildingOptions{
+		Packages:  []string{"app1"},
+		TaskNames: []string{"dev"},
+	})
+	assert.ErrorContains(t, err, "app1#dev: a readiness probe needs exactly one of a command, a port, a URL or a Check function")
+}
@@ -0,0 +1,147 @@
+package core
+
+import (
+	"fmt"
+
+	"github.com/pyr-sh/dag"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// RemoveTask removes the task with the given id, or the task added with the
+// given name, from the engine. A task id is removed from the TaskGraph along
+// with its edges, and from everything Prepare resolved for it, so that the
+// engine can be edited in place instead of being rebuilt. Tasks that depended
+// only on the removed task are left with no dependencies.
+func (e *Engine) RemoveTask(id string) error {
+	_, isTask := e.tasks[id]
+	inGraph := id != ROOT_NODE_NAME && e.TaskGraph.HasVertex(id)
+	if !isTask && !inGraph {
+		return fmt.Errorf("task %v not found", id)
+	}
+
+	if isTask {
+		delete(e.tasks, id)
+		if util.IsPackageTask(id) {
+			pkg, taskName := util.GetPackageTaskFromId(id)
+			if pkg == util.RootPkgName {
+				e.rootEnabledTasks.Delete(taskName)
+			}
+		}
+	}
+	delete(e.PackageTaskDeps, id)
+	for toTaskID, fromTaskIDs := range e.PackageTaskDeps {
+		e.PackageTaskDeps[toTaskID] = withoutTaskID(fromTaskIDs, id)
+	}
+	if !inGraph {
+		return nil
+	}
+
+	for _, graph := range e.editableGraphs() {
+		if !graph.HasVertex(id) {
+			continue
+		}
+		dependents := graph.UpEdges(id)
+		graph.Remove(id)
+		for _, dependent := range dependents {
+			connectOrphanToRoot(graph, dag.VertexName(dependent))
+		}
+	}
+	delete(e.taskShells, id)
+	delete(e.depOutputs, id)
+	for _, outputs := range e.depOutputs {
+		delete(outputs, id)
+	}
+	delete(e.startsAfter, id)
+	for taskID, others := range e.startsAfter {
+		e.startsAfter[taskID] = withoutTaskID(others, id)
+	}
+	delete(e.streamingDeps, id)
+	for taskID, deps := range e.streamingDeps {
+		e.streamingDeps[taskID] = withoutTaskID(deps, id)
+	}
+	delete(e.outputRanks, id)
+	delete(e.taskColors, id)
+	e.breakpoints.Delete(id)
+	e.shuffledOrder = withoutTaskID(e.shuffledOrder, id)
+	return nil
+}
+
+// RemoveDep removes the dependency of toTaskID on fromTaskID, undoing AddDep, or
+// removing the edge between the two from the TaskGraph once it is generated.
+// toTaskID is left with no dependencies if fromTaskID was its only one.
+func (e *Engine) RemoveDep(fromTaskID string, toTaskID string) error {
+	fromTaskIDs, isPackageTaskDep := e.PackageTaskDeps[toTaskID]
+	isPackageTaskDep = isPackageTaskDep && len(withoutTaskID(fromTaskIDs, fromTaskID)) < len(fromTaskIDs)
+	inGraph := fromTaskID != ROOT_NODE_NAME && hasEdge(e.TaskGraph, toTaskID, fromTaskID)
+	if !isPackageTaskDep && !inGraph {
+		return fmt.Errorf("%v does not depend on %v", toTaskID, fromTaskID)
+	}
+
+	if isPackageTaskDep {
+		e.PackageTaskDeps[toTaskID] = withoutTaskID(fromTaskIDs, fromTaskID)
+		if len(e.PackageTaskDeps[toTaskID]) == 0 {
+			delete(e.PackageTaskDeps, toTaskID)
+		}
+	}
+	if !inGraph {
+		return nil
+	}
+
+	for _, graph := range e.editableGraphs() {
+		if !hasEdge(graph, toTaskID, fromTaskID) {
+			continue
+		}
+		graph.RemoveEdge(dag.BasicEdge(toTaskID, fromTaskID))
+		connectOrphanToRoot(graph, toTaskID)
+	}
+	if outputs, ok := e.depOutputs[toTaskID]; ok {
+		delete(outputs, fromTaskID)
+	}
+	return nil
+}
+
+// editableGraphs returns the graphs that tasks and their dependencies are
+// removed from: the TaskGraph, and the graph used for hashing if it differs
+func (e *Engine) editableGraphs() []*dag.AcyclicGraph {
+	if e.hashGraph != nil && e.hashGraph != e.TaskGraph {
+		return []*dag.AcyclicGraph{e.TaskGraph, e.hashGraph}
+	}
+	return []*dag.AcyclicGraph{e.TaskGraph}
+}
+
+// hasEdge returns true if taskID depends on depTaskID in the graph
+func hasEdge(graph *dag.AcyclicGraph, taskID string, depTaskID string) bool {
+	if !graph.HasVertex(taskID) {
+		return false
+	}
+	for _, dep := range graph.DownEdges(taskID) {
+		if dag.VertexName(dep) == depTaskID {
+			return true
+		}
+	}
+	return false
+}
+
+// connectOrphanToRoot connects a task without dependencies to the root node, as
+// generateTaskGraph does for tasks that have none
+func connectOrphanToRoot(graph *dag.AcyclicGraph, taskID string) {
+	if graph.DownEdges(taskID).Len() > 0 {
+		return
+	}
+	graph.Add(ROOT_NODE_NAME)
+	graph.Connect(dag.BasicEdge(taskID, ROOT_NODE_NAME))
+}
+
+// withoutTaskID returns the task ids other than the given one
+func withoutTaskID(taskIDs []string, id string) []string {
+	if taskIDs == nil {
+		return nil
+	}
+	filtered := []string{}
+	for _, taskID := range taskIDs {
+		if taskID != id {
+			filtered = append(filtered, taskID)
+		}
+	}
+	return filtered
+}
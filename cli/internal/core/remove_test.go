@@ -0,0 +1,117 @@
+package core
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/util"
+	"gotest.tools/v3/assert"
+
+	"github.com/pyr-sh/dag"
+)
+
+func newRemoveTestEngine() *Engine {
+	graph := &dag.AcyclicGraph{}
+	graph.Add("app")
+	graph.Add("ui")
+	graph.Add("utils")
+	graph.Connect(dag.BasicEdge("app", "ui"))
+	graph.Connect(dag.BasicEdge("ui", "utils"))
+
+	return newTestEngine(graph, []*Task{
+		{Name: "build", TopoDeps: util.SetFromStrings([]string{"build"})},
+		{Name: "test", Deps: util.SetFromStrings([]string{"build"})},
+		{Name: "lint"},
+	})
+}
+
+func TestRemoveTask(t *testing.T) {
+	p := newRemoveTestEngine()
+	err := p.Prepare(&EngineBuildingOptions{
+		Packages:  []string{"app", "ui", "utils"},
+		TaskNames: []string{"build", "test", "lint"},
+	})
+	assert.NilError(t, err, "Prepare")
+
+	assert.NilError(t, p.RemoveTask("ui#build"))
+	order, err := p.ExecutionOrder()
+	assert.NilError(t, err)
+	// app#build depended only on ui#build, so it has no dependencies left
+	assert.DeepEqual(t, order, [][]string{
+		{"app#build", "app#lint", "ui#lint", "ui#test", "utils#build", "utils#lint"},
+		{"app#test", "utils#test"},
+	})
+	assert.NilError(t, p.ValidatePersistentDependencies(func(taskID string) bool { return true }))
+
+	assert.ErrorContains(t, p.RemoveTask("ui#build"), "task ui#build not found")
+	assert.ErrorContains(t, p.RemoveTask(ROOT_NODE_NAME), "not found")
+}
+
+func TestRemoveTaskBeforePrepare(t *testing.T) {
+	p := newRemoveTestEngine()
+	assert.NilError(t, p.RemoveTask("lint"))
+	err := p.Prepare(&EngineBuildingOptions{
+		Packages:  []string{"app", "ui", "utils"},
+		TaskNames: []string{"build", "lint"},
+	})
+	assert.Error(t, err, "no workspace in scope implements lint")
+
+	p = newRemoveTestEngine()
+	assert.NilError(t, p.RemoveTask("lint"))
+	err = p.Prepare(&EngineBuildingOptions{
+		Packages:          []string{"app", "ui", "utils"},
+		TaskNames:         []string{"build", "lint"},
+		AllowMissingTasks: true,
+	})
+	assert.NilError(t, err, "Prepare")
+
+	order, err := p.ExecutionOrder()
+	assert.NilError(t, err)
+	assert.DeepEqual(t, order, [][]string{
+		{"utils#build"},
+		{"ui#build"},
+		{"app#build"},
+	})
+}
+
+func TestRemoveDep(t *testing.T) {
+	p := newRemoveTestEngine()
+	assert.NilError(t, p.AddDep("utils#lint", "app#lint"))
+	assert.NilError(t, p.RemoveDep("utils#lint", "app#lint"))
+	assert.ErrorContains(t, p.RemoveDep("utils#lint", "app#lint"), "app#lint does not depend on utils#lint")
+
+	err := p.Prepare(&EngineBuildingOptions{
+		Packages:  []string{"app", "ui", "utils"},
+		TaskNames: []string{"build", "lint"},
+	})
+	assert.NilError(t, err, "Prepare")
+
+	assert.NilError(t, p.RemoveDep("ui#build", "app#build"))
+	order, err := p.ExecutionOrder()
+	assert.NilError(t, err)
+	assert.DeepEqual(t, order, [][]string{
+		{"app#build", "app#lint", "ui#lint", "utils#build", "utils#lint"},
+		{"ui#build"},
+	})
+	assert.ErrorContains(t, p.RemoveDep("ui#build", "app#build"), "app#build does not depend on ui#build")
+}
+
+func TestRemoveDepOnPersistentTask(t *testing.T) {
+	graph := &dag.AcyclicGraph{}
+	graph.Add("app1")
+	graph.Add("libA")
+	graph.Connect(dag.BasicEdge("app1", "libA"))
+
+	tasks := []*Task{{Name: "dev", TopoDeps: util.SetFromStrings([]string{"dev"}), Persistent: true}}
+	p := prepareTestEngine(t, graph, tasks, &EngineBuildingOptions{
+		Packages:  []string{"app1"},
+		TaskNames: []string{"dev"},
+	})
+
+	hasScript := func(taskID string) bool { return true }
+	var violation *PersistentDependencyError
+	assert.Assert(t, errors.As(p.ValidatePersistentDependencies(hasScript), &violation))
+	assert.DeepEqual(t, *violation, PersistentDependencyError{Dependent: "app1#dev", Persistent: "libA#dev"})
+	assert.NilError(t, p.RemoveDep("libA#dev", "app1#dev"))
+	assert.NilError(t, p.ValidatePersistentDependencies(hasScript))
+}
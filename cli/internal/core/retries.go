@@ -0,0 +1,43 @@
+package core
+
+import "github.com/vercel/turbo/cli/internal/util"
+
+// maxAttempts returns how many times the task is run before it is considered failed
+func (t *Task) maxAttempts() int {
+	if t.Persistent || t.Retries <= 0 {
+		return 1
+	}
+	return t.Retries + 1
+}
+
+// startAttempt records that the given task is being run again, and returns the
+// number of the attempt, starting from 1
+func (e *Engine) startAttempt(taskID string) int {
+	e.attemptsMu.Lock()
+	defer e.attemptsMu.Unlock()
+	e.attempts[taskID]++
+	return e.attempts[taskID]
+}
+
+// Attempts returns how many times the given task has been run during the current
+// or last execution, including a run that is in progress
+func (e *Engine) Attempts(taskID string) int {
+	e.attemptsMu.Lock()
+	defer e.attemptsMu.Unlock()
+	return e.attempts[taskID]
+}
+
+// RetriesLeft returns how many more times the given task will be run if its
+// current attempt fails. A visitor can use it to tell whether a failure is final.
+func (e *Engine) RetriesLeft(taskID string) int {
+	pkg, taskName := util.GetPackageTaskFromId(taskID)
+	task, err := e.getTaskDefinition(pkg, taskName, taskID)
+	if err != nil {
+		return 0
+	}
+	left := task.maxAttempts() - e.Attempts(taskID)
+	if left < 0 {
+		return 0
+	}
+	return left
+}
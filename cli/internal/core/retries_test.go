@@ -0,0 +1,79 @@
+package core
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/util"
+	"gotest.tools/v3/assert"
+
+	"github.com/pyr-sh/dag"
+)
+
+// flakyVisitor fails each task the given number of times before it succeeds,
+// and records every task it visits
+type flakyVisitor struct {
+	mu       sync.Mutex
+	failures map[string]int
+	visited  []string
+}
+
+func (v *flakyVisitor) visit(taskID string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.visited = append(v.visited, taskID)
+	if v.failures[taskID] > 0 {
+		v.failures[taskID]--
+		return fmt.Errorf("%v failed", taskID)
+	}
+	return nil
+}
+
+func setupRetriesEngine(t *testing.T, retries int, persistent bool) *Engine {
+	graph := &dag.AcyclicGraph{}
+	graph.Add("app")
+	graph.Add("lib")
+	graph.Connect(dag.BasicEdge("app", "lib"))
+
+	tasks := []*Task{{Name: "test", TopoDeps: util.SetFromStrings([]string{"test"}), Retries: retries, Persistent: persistent}}
+	return prepareTestEngine(t, graph, tasks, &EngineBuildingOptions{
+		Packages:  []string{"app"},
+		TaskNames: []string{"test"},
+	})
+}
+
+func TestRetriesThenPasses(t *testing.T) {
+	p := setupRetriesEngine(t, 2, false)
+
+	v := &flakyVisitor{failures: map[string]int{"lib#test": 2}}
+	errs := p.Execute(v.visit, EngineExecutionOptions{Concurrency: 10})
+	assert.Equal(t, len(errs), 0, "%v", errs)
+
+	// The dependent runs once lib#test passes on its last attempt
+	assert.DeepEqual(t, v.visited, []string{"lib#test", "lib#test", "lib#test", "app#test"})
+	assert.Equal(t, p.Attempts("lib#test"), 3)
+	assert.Equal(t, p.Attempts("app#test"), 1)
+}
+
+func TestRetriesExhausted(t *testing.T) {
+	p := setupRetriesEngine(t, 2, false)
+
+	v := &flakyVisitor{failures: map[string]int{"lib#test": 3}}
+	errs := p.Execute(v.visit, EngineExecutionOptions{Concurrency: 10})
+	assert.Equal(t, len(errs), 1)
+	assert.ErrorContains(t, errs[0], "lib#test failed")
+
+	assert.DeepEqual(t, v.visited, []string{"lib#test", "lib#test", "lib#test"})
+	assert.Equal(t, p.Attempts("lib#test"), 3)
+	assert.Equal(t, p.RetriesLeft("lib#test"), 0)
+}
+
+func TestRetriesSkippedForPersistentTasks(t *testing.T) {
+	p := setupRetriesEngine(t, 2, true)
+
+	v := &flakyVisitor{failures: map[string]int{"app#test": 1}}
+	errs := p.Execute(v.visit, EngineExecutionOptions{Concurrency: 10})
+	assert.Equal(t, len(errs), 1)
+	assert.Equal(t, p.Attempts("app#test"), 1)
+}
@@ -0,0 +1,57 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// isRootTaskID returns true if the dependency is on a task run from the root
+// package, like "//#setup"
+func isRootTaskID(dep string) bool {
+	return strings.HasPrefix(dep, util.RootPkgName+util.TaskDelimiter)
+}
+
+// resolveRootTaskDependencies replaces the dependencies of tasks on a task run
+// from the root package, whether declared like "//#setup" or "^//#setup", with a
+// dependency of each package's instance of the task on the single root task. It
+// returns an error if the root task isn't defined.
+func (e *Engine) resolveRootTaskDependencies(pkgs []string) error {
+	for _, task := range e.tasks {
+		rootDeps := []string{}
+		for _, deps := range []util.Set{task.Deps, task.TopoDeps} {
+			if deps == nil {
+				continue
+			}
+			for _, dep := range deps.UnsafeListOfStrings() {
+				if isRootTaskID(dep) {
+					deps.Delete(dep)
+					rootDeps = append(rootDeps, dep)
+				}
+			}
+		}
+		for _, dep := range rootDeps {
+			_, taskName := util.GetPackageTaskFromId(dep)
+			if !e.rootEnabledTasks.Includes(taskName) {
+				return fmt.Errorf("%v depends on %v, which needs an entry in turbo.json because it is a task run from the root package", task.Name, dep)
+			}
+			toTaskIDs := []string{task.Name}
+			if !util.IsPackageTask(task.Name) {
+				toTaskIDs = []string{}
+				for _, pkg := range pkgs {
+					toTaskIDs = append(toTaskIDs, util.GetTaskId(pkg, task.Name))
+				}
+			}
+			for _, toTaskID := range toTaskIDs {
+				if toTaskID == dep {
+					continue
+				}
+				if err := e.AddDep(dep, toTaskID); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pyr-sh/dag"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+const (
+	// RunWhenAlways runs the task regardless of the run's caching configuration
+	RunWhenAlways = "always"
+	// RunWhenCacheEnabled only runs the task when the run uses a cache
+	RunWhenCacheEnabled = "cache-enabled"
+	// RunWhenCacheDisabled only runs the task when the run doesn't use a cache
+	RunWhenCacheDisabled = "cache-disabled"
+)
+
+// RunWhenDecision records whether a task with a RunWhen condition was included
+// in the TaskGraph
+type RunWhenDecision struct {
+	TaskID   string `json:"taskId"`
+	RunWhen  string `json:"runWhen"`
+	Included bool   `json:"included"`
+}
+
+// runWhenIncludes returns true if a task with the given RunWhen condition should
+// run, given whether the run uses a cache
+func runWhenIncludes(runWhen string, cacheEnabled bool) (bool, error) {
+	switch runWhen {
+	case "", RunWhenAlways:
+		return true, nil
+	case RunWhenCacheEnabled:
+		return cacheEnabled, nil
+	case RunWhenCacheDisabled:
+		return !cacheEnabled, nil
+	default:
+		return false, fmt.Errorf("invalid runWhen %q: expected %v, %v or %v", runWhen, RunWhenAlways, RunWhenCacheEnabled, RunWhenCacheDisabled)
+	}
+}
+
+// omitConditionalTasks removes the tasks whose RunWhen condition doesn't hold
+// from the TaskGraph. Their dependents depend on their dependencies instead, so
+// that the ordering between the remaining tasks is unchanged.
+func (e *Engine) omitConditionalTasks(cacheEnabled bool) error {
+	e.runWhenDecisions = nil
+	omitted := []string{}
+	for _, v := range e.TaskGraph.Vertices() {
+		taskID := dag.VertexName(v)
+		if strings.Contains(taskID, ROOT_NODE_NAME) {
+			continue
+		}
+		pkg, taskName := util.GetPackageTaskFromId(taskID)
+		task, err := e.getTaskDefinition(pkg, taskName, taskID)
+		if err != nil {
+			return err
+		}
+		if task.RunWhen == "" || task.RunWhen == RunWhenAlways {
+			continue
+		}
+		included, err := runWhenIncludes(task.RunWhen, cacheEnabled)
+		if err != nil {
+			return fmt.Errorf("%v: %w", taskID, err)
+		}
+		e.runWhenDecisions = append(e.runWhenDecisions, RunWhenDecision{
+			TaskID:   taskID,
+			RunWhen:  task.RunWhen,
+			Included: included,
+		})
+		if !included {
+			omitted = append(omitted, taskID)
+		}
+	}
+	sort.Slice(e.runWhenDecisions, func(i, j int) bool {
+		return e.runWhenDecisions[i].TaskID < e.runWhenDecisions[j].TaskID
+	})
+	sort.Strings(omitted)
+
+	for _, taskID := range omitted {
+		deps := e.TaskGraph.DownEdges(taskID)
+		for _, dependent := range e.TaskGraph.UpEdges(taskID) {
+			for _, dep := range deps {
+				e.TaskGraph.Connect(dag.BasicEdge(dependent, dep))
+			}
+		}
+		e.TaskGraph.Remove(taskID)
+	}
+	return nil
+}
+
+// RunWhenDecisions returns, for each task in the prepared TaskGraph's scope with
+// a RunWhen condition, whether it was included, sorted by task id
+func (e *Engine) RunWhenDecisions() []RunWhenDecision {
+	return e.runWhenDecisions
+}
@@ -0,0 +1,73 @@
+package core
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/util"
+	"gotest.tools/v3/assert"
+
+	"github.com/pyr-sh/dag"
+)
+
+func prepareRunWhenEngine(t *testing.T, cacheEnabled bool) *Engine {
+	graph := &dag.AcyclicGraph{}
+	graph.Add("app")
+	graph.Add(ROOT_NODE_NAME)
+	graph.Connect(dag.BasicEdge("app", ROOT_NODE_NAME))
+
+	tasks := []*Task{
+		{Name: "build"},
+		{Name: "report", Deps: util.SetFromStrings([]string{"build"}), RunWhen: RunWhenCacheEnabled},
+		{Name: "deploy", Deps: util.SetFromStrings([]string{"report"}), RunWhen: RunWhenAlways},
+		{Name: "clean", RunWhen: RunWhenCacheDisabled},
+	}
+	return prepareTestEngine(t, graph, tasks, &EngineBuildingOptions{
+		Packages:     []string{"app"},
+		TaskNames:    []string{"deploy", "clean"},
+		CacheEnabled: cacheEnabled,
+	})
+}
+
+func taskGraphVertices(e *Engine) []string {
+	vertices := []string{}
+	for _, v := range e.TaskGraph.Vertices() {
+		vertices = append(vertices, dag.VertexName(v))
+	}
+	sort.Strings(vertices)
+	return vertices
+}
+
+func TestRunWhenCacheEnabled(t *testing.T) {
+	p := prepareRunWhenEngine(t, true)
+
+	assert.DeepEqual(t, taskGraphVertices(p), []string{ROOT_NODE_NAME, "app#build", "app#deploy", "app#report"})
+	assert.DeepEqual(t, p.RunWhenDecisions(), []RunWhenDecision{
+		{TaskID: "app#clean", RunWhen: RunWhenCacheDisabled, Included: false},
+		{TaskID: "app#report", RunWhen: RunWhenCacheEnabled, Included: true},
+	})
+}
+
+func TestRunWhenCacheDisabled(t *testing.T) {
+	p := prepareRunWhenEngine(t, false)
+
+	assert.DeepEqual(t, taskGraphVertices(p), []string{ROOT_NODE_NAME, "app#build", "app#clean", "app#deploy"})
+	// deploy still runs after build, even though report is omitted
+	assert.DeepEqual(t, p.taskDependencies("app#deploy"), []string{"app#build"})
+	assert.DeepEqual(t, p.RunWhenDecisions(), []RunWhenDecision{
+		{TaskID: "app#clean", RunWhen: RunWhenCacheDisabled, Included: true},
+		{TaskID: "app#report", RunWhen: RunWhenCacheEnabled, Included: false},
+	})
+}
+
+func TestRunWhenInvalid(t *testing.T) {
+	graph := &dag.AcyclicGraph{}
+	graph.Add("app")
+	p := newTestEngine(graph, []*Task{{Name: "build", RunWhen: "sometimes"}})
+
+	err := p.Prepare(&EngineBuildingOptions{
+		Packages:  []string{"app"},
+		TaskNames: []string{"build"},
+	})
+	assert.ErrorContains(t, err, "app#build: invalid runWhen \"sometimes\"")
+}
@@ -0,0 +1,96 @@
+package core
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// isScriptPattern returns true if the task name is a glob pattern, like "lint:*",
+// rather than the name of a single task
+func isScriptPattern(taskName string) bool {
+	return strings.ContainsAny(taskName, "*?[")
+}
+
+// expandScriptPattern returns the names of the scripts in the given workspace
+// that a dependency on taskName refers to. A plain task name refers to itself. A
+// pattern refers to every matching script, sorted, except for the task that
+// declares the dependency, and to nothing in a workspace without a match.
+func expandScriptPattern(packageInfos map[interface{}]*fs.PackageJSON, pkg string, taskName string, dependent string) []string {
+	if !isScriptPattern(taskName) {
+		return []string{taskName}
+	}
+	pkgJSON, ok := packageInfos[pkg]
+	if !ok {
+		return nil
+	}
+	matches := []string{}
+	for script := range pkgJSON.Scripts {
+		if script == dependent {
+			continue
+		}
+		// A malformed pattern matches no scripts
+		if matched, _ := path.Match(taskName, script); matched {
+			matches = append(matches, script)
+		}
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+// ExpandTaskNames replaces each of the task names that is a pattern, like "test:*",
+// with the names in definedNames that match it, sorted. Task names that aren't
+// patterns are kept as they are, and each name is only kept once. It returns an
+// error if a pattern matches none of definedNames.
+func ExpandTaskNames(taskNames []string, definedNames []string) ([]string, error) {
+	expanded := []string{}
+	seen := make(util.Set)
+	add := func(taskName string) {
+		if !seen.Includes(taskName) {
+			seen.Add(taskName)
+			expanded = append(expanded, taskName)
+		}
+	}
+	for _, taskName := range taskNames {
+		if !isScriptPattern(taskName) {
+			add(taskName)
+			continue
+		}
+		if _, err := path.Match(taskName, ""); err != nil {
+			return nil, fmt.Errorf("invalid task pattern %q: %w", taskName, err)
+		}
+		matches := []string{}
+		for _, name := range definedNames {
+			if matched, _ := path.Match(taskName, name); matched {
+				matches = append(matches, name)
+			}
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no tasks in the pipeline match %q", taskName)
+		}
+		sort.Strings(matches)
+		for _, match := range matches {
+			add(match)
+		}
+	}
+	return expanded, nil
+}
+
+// taskNames returns the names of the tasks added to the engine, without the
+// workspaces of those added by task id
+func (e *Engine) taskNames() []string {
+	names := make(util.Set)
+	for key := range e.tasks {
+		if util.IsPackageTask(key) {
+			_, taskName := util.GetPackageTaskFromId(key)
+			names.Add(taskName)
+		} else {
+			names.Add(key)
+		}
+	}
+	return names.UnsafeListOfStrings()
+}
@@ -0,0 +1,65 @@
+package core
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pyr-sh/dag"
+)
+
+// SerializedTaskGraph is the prepared task graph, in a form that can be shared
+// with tools that don't use the Engine
+type SerializedTaskGraph struct {
+	Tasks        []SerializedTask       `json:"tasks"`
+	Dependencies []SerializedDependency `json:"dependencies"`
+}
+
+// SerializedTask is a task in the task graph
+type SerializedTask struct {
+	TaskID     string `json:"taskId"`
+	Persistent bool   `json:"persistent"`
+}
+
+// SerializedDependency is a dependency of the task From on the task To
+type SerializedDependency struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Serialize returns the tasks in the task graph, sorted by task id, and the
+// dependencies between them, sorted by dependent and then dependency. The root
+// node that tasks without dependencies depend on, and the tasks of the root of
+// the package graph, are left out.
+func (e *Engine) Serialize() *SerializedTaskGraph {
+	graph := &SerializedTaskGraph{
+		Tasks:        []SerializedTask{},
+		Dependencies: []SerializedDependency{},
+	}
+	for _, v := range e.TaskGraph.Vertices() {
+		taskID := dag.VertexName(v)
+		if strings.Contains(taskID, ROOT_NODE_NAME) {
+			continue
+		}
+		graph.Tasks = append(graph.Tasks, SerializedTask{
+			TaskID:     taskID,
+			Persistent: e.IsPersistent(taskID),
+		})
+	}
+	for _, edge := range e.TaskGraph.Edges() {
+		from := dag.VertexName(edge.Source())
+		to := dag.VertexName(edge.Target())
+		if strings.Contains(from, ROOT_NODE_NAME) || strings.Contains(to, ROOT_NODE_NAME) {
+			continue
+		}
+		graph.Dependencies = append(graph.Dependencies, SerializedDependency{From: from, To: to})
+	}
+	sort.Slice(graph.Tasks, func(i, j int) bool { return graph.Tasks[i].TaskID < graph.Tasks[j].TaskID })
+	sort.Slice(graph.Dependencies, func(i, j int) bool {
+		a, b := graph.Dependencies[i], graph.Dependencies[j]
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		return a.To < b.To
+	})
+	return graph
+}
@@ -0,0 +1,47 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/pyr-sh/dag"
+	"github.com/vercel/turbo/cli/internal/util"
+	"gotest.tools/v3/assert"
+)
+
+func TestSerialize(t *testing.T) {
+	graph := &dag.AcyclicGraph{}
+	graph.Add("web")
+	graph.Add("ui")
+	graph.Connect(dag.BasicEdge("web", "ui"))
+	// Like the package graph of a repo, packages without dependencies depend on the root
+	graph.Add(ROOT_NODE_NAME)
+	graph.Connect(dag.BasicEdge("ui", ROOT_NODE_NAME))
+
+	p := NewEngine(graph)
+	buildTopoDeps := make(util.Set)
+	buildTopoDeps.Add("build")
+	p.AddTask(&Task{Name: "build", TopoDeps: buildTopoDeps, Deps: make(util.Set)})
+	devDeps := make(util.Set)
+	devDeps.Add("build")
+	p.AddTask(&Task{Name: "dev", TopoDeps: make(util.Set), Deps: devDeps, Persistent: true})
+
+	err := p.Prepare(&EngineBuildingOptions{
+		Packages:  []string{"web", "ui"},
+		TaskNames: []string{"build", "dev"},
+	})
+	assert.NilError(t, err, "Prepare")
+
+	assert.DeepEqual(t, p.Serialize(), &SerializedTaskGraph{
+		Tasks: []SerializedTask{
+			{TaskID: "ui#build"},
+			{TaskID: "ui#dev", Persistent: true},
+			{TaskID: "web#build"},
+			{TaskID: "web#dev", Persistent: true},
+		},
+		Dependencies: []SerializedDependency{
+			{From: "ui#dev", To: "ui#build"},
+			{From: "web#build", To: "ui#build"},
+			{From: "web#dev", To: "web#build"},
+		},
+	})
+}
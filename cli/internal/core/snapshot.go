@@ -0,0 +1,174 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pyr-sh/dag"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// Snapshot is a machine-independent description of a prepared TaskGraph, so that
+// the graphs computed on different machines can be compared with CompareSnapshots
+type Snapshot struct {
+	Tasks []SnapshotTask `json:"tasks"`
+	Edges []SnapshotEdge `json:"edges"`
+}
+
+// SnapshotTask is a task in a Snapshot
+type SnapshotTask struct {
+	TaskID     string `json:"taskId"`
+	Persistent bool   `json:"persistent"`
+}
+
+// SnapshotEdge is a dependency in a Snapshot. From depends on To.
+type SnapshotEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+func (edge SnapshotEdge) String() string {
+	return fmt.Sprintf("%v -> %v", edge.From, edge.To)
+}
+
+// SnapshotDiff describes how two snapshots diverge. Each field is sorted.
+type SnapshotDiff struct {
+	// TasksOnlyInA and TasksOnlyInB are the task ids found in only one snapshot
+	TasksOnlyInA []string
+	TasksOnlyInB []string
+	// EdgesOnlyInA and EdgesOnlyInB are the dependencies found in only one snapshot
+	EdgesOnlyInA []SnapshotEdge
+	EdgesOnlyInB []SnapshotEdge
+	// PersistentMismatches are the task ids, found in both snapshots, that are
+	// persistent in only one of them
+	PersistentMismatches []string
+}
+
+// Empty returns true if the snapshots describe the same TaskGraph
+func (d SnapshotDiff) Empty() bool {
+	return len(d.TasksOnlyInA) == 0 && len(d.TasksOnlyInB) == 0 &&
+		len(d.EdgesOnlyInA) == 0 && len(d.EdgesOnlyInB) == 0 &&
+		len(d.PersistentMismatches) == 0
+}
+
+// String describes each divergence on its own line
+func (d SnapshotDiff) String() string {
+	lines := []string{}
+	for _, taskID := range d.TasksOnlyInA {
+		lines = append(lines, fmt.Sprintf("task %v is only in a", taskID))
+	}
+	for _, taskID := range d.TasksOnlyInB {
+		lines = append(lines, fmt.Sprintf("task %v is only in b", taskID))
+	}
+	for _, edge := range d.EdgesOnlyInA {
+		lines = append(lines, fmt.Sprintf("edge %v is only in a", edge))
+	}
+	for _, edge := range d.EdgesOnlyInB {
+		lines = append(lines, fmt.Sprintf("edge %v is only in b", edge))
+	}
+	for _, taskID := range d.PersistentMismatches {
+		lines = append(lines, fmt.Sprintf("task %v is persistent in only one snapshot", taskID))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Snapshot serializes the prepared TaskGraph's tasks, their dependencies and
+// whether they are persistent. The root node is left out, since it is an
+// implementation detail of the graph.
+func (e *Engine) Snapshot() ([]byte, error) {
+	snapshot := Snapshot{
+		Tasks: []SnapshotTask{},
+		Edges: []SnapshotEdge{},
+	}
+	for _, v := range e.TaskGraph.Vertices() {
+		taskID := dag.VertexName(v)
+		if strings.Contains(taskID, ROOT_NODE_NAME) {
+			continue
+		}
+		pkg, taskName := util.GetPackageTaskFromId(taskID)
+		task, err := e.getTaskDefinition(pkg, taskName, taskID)
+		if err != nil {
+			return nil, err
+		}
+		snapshot.Tasks = append(snapshot.Tasks, SnapshotTask{TaskID: taskID, Persistent: task.Persistent})
+		for _, depTaskID := range e.taskDependencies(taskID) {
+			snapshot.Edges = append(snapshot.Edges, SnapshotEdge{From: taskID, To: depTaskID})
+		}
+	}
+	sort.Slice(snapshot.Tasks, func(i, j int) bool {
+		return snapshot.Tasks[i].TaskID < snapshot.Tasks[j].TaskID
+	})
+	sortSnapshotEdges(snapshot.Edges)
+	return json.Marshal(snapshot)
+}
+
+// CompareSnapshots deserializes two snapshots created by Engine.Snapshot and
+// reports where they diverge. The diff is empty if they describe the same TaskGraph.
+func CompareSnapshots(a []byte, b []byte) (SnapshotDiff, error) {
+	diff := SnapshotDiff{}
+	snapshotA := Snapshot{}
+	if err := json.Unmarshal(a, &snapshotA); err != nil {
+		return diff, fmt.Errorf("invalid snapshot a: %w", err)
+	}
+	snapshotB := Snapshot{}
+	if err := json.Unmarshal(b, &snapshotB); err != nil {
+		return diff, fmt.Errorf("invalid snapshot b: %w", err)
+	}
+
+	tasksA := make(map[string]bool, len(snapshotA.Tasks))
+	for _, task := range snapshotA.Tasks {
+		tasksA[task.TaskID] = task.Persistent
+	}
+	tasksB := make(map[string]bool, len(snapshotB.Tasks))
+	for _, task := range snapshotB.Tasks {
+		tasksB[task.TaskID] = task.Persistent
+	}
+	for taskID, persistent := range tasksA {
+		otherPersistent, ok := tasksB[taskID]
+		if !ok {
+			diff.TasksOnlyInA = append(diff.TasksOnlyInA, taskID)
+		} else if persistent != otherPersistent {
+			diff.PersistentMismatches = append(diff.PersistentMismatches, taskID)
+		}
+	}
+	for taskID := range tasksB {
+		if _, ok := tasksA[taskID]; !ok {
+			diff.TasksOnlyInB = append(diff.TasksOnlyInB, taskID)
+		}
+	}
+
+	diff.EdgesOnlyInA = edgesNotIn(snapshotA.Edges, snapshotB.Edges)
+	diff.EdgesOnlyInB = edgesNotIn(snapshotB.Edges, snapshotA.Edges)
+
+	sort.Strings(diff.TasksOnlyInA)
+	sort.Strings(diff.TasksOnlyInB)
+	sort.Strings(diff.PersistentMismatches)
+	return diff, nil
+}
+
+// edgesNotIn returns the edges that aren't in others, sorted
+func edgesNotIn(edges []SnapshotEdge, others []SnapshotEdge) []SnapshotEdge {
+	otherEdges := make(map[SnapshotEdge]bool, len(others))
+	for _, edge := range others {
+		otherEdges[edge] = true
+	}
+	var missing []SnapshotEdge
+	for _, edge := range edges {
+		if !otherEdges[edge] {
+			missing = append(missing, edge)
+		}
+	}
+	sortSnapshotEdges(missing)
+	return missing
+}
+
+func sortSnapshotEdges(edges []SnapshotEdge) {
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].From != edges[j].From {
+			return edges[i].From < edges[j].From
+		}
+		return edges[i].To < edges[j].To
+	})
+}
@@ -0,0 +1,72 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/util"
+	"gotest.tools/v3/assert"
+
+	"github.com/pyr-sh/dag"
+)
+
+func snapshotEngine(t *testing.T, pkgs []string, dependsOnLib bool, persistentDev bool) []byte {
+	graph := &dag.AcyclicGraph{}
+	graph.Add(ROOT_NODE_NAME)
+	for _, pkg := range pkgs {
+		graph.Add(pkg)
+		if pkg == "app" && dependsOnLib {
+			graph.Connect(dag.BasicEdge("app", "lib"))
+		} else {
+			graph.Connect(dag.BasicEdge(pkg, ROOT_NODE_NAME))
+		}
+	}
+
+	p := NewEngine(graph)
+	topoDeps := make(util.Set)
+	topoDeps.Add("build")
+	p.AddTask(&Task{Name: "build", TopoDeps: topoDeps, Deps: make(util.Set)})
+	p.AddTask(&Task{Name: "dev", Deps: make(util.Set), Persistent: persistentDev})
+	err := p.Prepare(&EngineBuildingOptions{
+		Packages:  pkgs,
+		TaskNames: []string{"build", "dev"},
+	})
+	assert.NilError(t, err, "Prepare")
+
+	snapshot, err := p.Snapshot()
+	assert.NilError(t, err, "Snapshot")
+	return snapshot
+}
+
+func TestCompareSnapshotsIdentical(t *testing.T) {
+	a := snapshotEngine(t, []string{"app", "lib"}, true, true)
+	b := snapshotEngine(t, []string{"lib", "app"}, true, true)
+
+	diff, err := CompareSnapshots(a, b)
+	assert.NilError(t, err)
+	assert.Assert(t, diff.Empty(), diff.String())
+}
+
+func TestCompareSnapshotsDivergent(t *testing.T) {
+	a := snapshotEngine(t, []string{"app", "lib", "docs"}, true, true)
+	b := snapshotEngine(t, []string{"app", "lib"}, false, false)
+
+	diff, err := CompareSnapshots(a, b)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, diff, SnapshotDiff{
+		TasksOnlyInA:         []string{"docs#build", "docs#dev"},
+		EdgesOnlyInA:         []SnapshotEdge{{From: "app#build", To: "lib#build"}},
+		PersistentMismatches: []string{"app#dev", "lib#dev"},
+	})
+	assert.Equal(t, diff.String(), `task docs#build is only in a
+task docs#dev is only in a
+edge app#build -> lib#build is only in a
+task app#dev is persistent in only one snapshot
+task lib#dev is persistent in only one snapshot`)
+}
+
+func TestCompareSnapshotsInvalid(t *testing.T) {
+	a := snapshotEngine(t, []string{"app"}, false, false)
+
+	_, err := CompareSnapshots(a, []byte("not json"))
+	assert.ErrorContains(t, err, "invalid snapshot b")
+}
@@ -0,0 +1,112 @@
+package core
+
+import (
+	"sync"
+
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// startQueue hands out concurrency slots to tasks in task id order, so that tasks
+// that become ready at the same time start in the same order in every run. A task
+// that is ready, but hasn't asked for a slot yet, holds back the tasks after it
+// until it does, which only takes as long as it doesn't wait on anything else.
+// Tasks that don't fit in the free slots don't hold back the tasks that do.
+type startQueue struct {
+	limit int
+	used  int
+	// expected holds the tasks that are ready, but haven't asked for a slot yet
+	expected util.Set
+	// waiting holds the weight of each task waiting for a slot
+	waiting map[string]int
+	mu      sync.Mutex
+	cond    *sync.Cond
+}
+
+// newStartQueue creates a startQueue with the given number of slots
+func newStartQueue(n int) *startQueue {
+	if n <= 0 {
+		panic("start queue with limit <=0")
+	}
+	q := &startQueue{
+		limit:    n,
+		expected: make(util.Set),
+		waiting:  make(map[string]int),
+	}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+// expect records that the given task is ready, and will ask for a slot
+func (q *startQueue) expect(taskID string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.expected.Add(taskID)
+}
+
+// leave records that the given task won't ask for a slot, at least not before it
+// has waited on something else
+func (q *startQueue) leave(taskID string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.expected.Delete(taskID)
+	q.cond.Broadcast()
+}
+
+// tryAcquire acquires weight slots for the given task if it is its turn, and
+// otherwise queues it up to acquire them with acquire
+func (q *startQueue) tryAcquire(taskID string, weight int) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.expected.Delete(taskID)
+	q.cond.Broadcast()
+	if q.canStart(taskID, weight) {
+		q.used += weight
+		return true
+	}
+	q.waiting[taskID] = weight
+	return false
+}
+
+// acquire blocks until it is the turn of a task queued up by tryAcquire, and
+// acquires its slots
+func (q *startQueue) acquire(taskID string, weight int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for !q.canStart(taskID, weight) {
+		q.cond.Wait()
+	}
+	delete(q.waiting, taskID)
+	q.used += weight
+	// Tasks after this one may fit in the remaining slots
+	q.cond.Broadcast()
+}
+
+// release returns weight slots
+func (q *startQueue) release(weight int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if weight > q.used {
+		panic("release without an acquire")
+	}
+	q.used -= weight
+	q.cond.Broadcast()
+}
+
+// canStart returns true if the given task fits in the free slots, and no task
+// before it is expected or waiting to fit in them. The lock must be held.
+func (q *startQueue) canStart(taskID string, weight int) bool {
+	if q.used+weight > q.limit {
+		return false
+	}
+	for expected := range q.expected {
+		if expected.(string) < taskID {
+			return false
+		}
+	}
+	for waiting, waitingWeight := range q.waiting {
+		if waiting < taskID && q.used+waitingWeight <= q.limit {
+			return false
+		}
+	}
+	return true
+}
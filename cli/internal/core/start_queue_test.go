@@ -0,0 +1,67 @@
+package core
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/util"
+	"gotest.tools/v3/assert"
+
+	"github.com/pyr-sh/dag"
+)
+
+func TestExecuteStartsReadyTasksInTaskIDOrder(t *testing.T) {
+	// Five independent packages, and y, which depends on a
+	graph := &dag.AcyclicGraph{}
+	for _, pkg := range []string{"e", "c", "a", "z", "b", "y"} {
+		graph.Add(pkg)
+	}
+	graph.Connect(dag.BasicEdge("y", "a"))
+
+	p := NewEngine(graph)
+	topoDeps := make(util.Set)
+	topoDeps.Add("build")
+	p.AddTask(&Task{Name: "build", TopoDeps: topoDeps, Deps: make(util.Set)})
+	err := p.Prepare(&EngineBuildingOptions{
+		Packages:  []string{"e", "c", "a", "z", "b", "y"},
+		TaskNames: []string{"build"},
+	})
+	assert.NilError(t, err, "Prepare")
+
+	for i := 0; i < 20; i++ {
+		var mu sync.Mutex
+		order := []string{}
+		errs := p.Execute(func(taskID string) error {
+			mu.Lock()
+			defer mu.Unlock()
+			order = append(order, taskID)
+			return nil
+		}, EngineExecutionOptions{Concurrency: 1})
+		assert.Equal(t, len(errs), 0)
+		// y is ready once a finishes, and is before z by task id
+		assert.DeepEqual(t, order, []string{"a#build", "b#build", "c#build", "e#build", "y#build", "z#build"})
+	}
+}
+
+func TestStartQueueSkipsTasksThatDontFit(t *testing.T) {
+	q := newStartQueue(2)
+	q.expect("a#build")
+	q.expect("b#build")
+	q.expect("c#build")
+	assert.Assert(t, !q.tryAcquire("c#build", 1), "c#build must wait for a#build and b#build")
+	assert.Assert(t, q.tryAcquire("a#build", 1))
+
+	// b#build needs both slots, so c#build starts in the one that is free
+	assert.Assert(t, !q.tryAcquire("b#build", 2))
+	q.acquire("c#build", 1)
+
+	started := make(chan struct{})
+	go func() {
+		q.acquire("b#build", 2)
+		close(started)
+	}()
+	q.release(1)
+	q.release(1)
+	<-started
+	q.release(2)
+}
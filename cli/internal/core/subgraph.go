@@ -0,0 +1,92 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/pyr-sh/dag"
+)
+
+// Subgraph returns a new Engine whose TaskGraph holds only the given tasks and
+// the tasks they depend on, directly or transitively, including the tasks they
+// wait to be ready for. It shares task definitions with e, and keeps what
+// Prepare resolved for the tasks it holds, so it can be validated, visualized
+// or executed on its own. e must have been prepared.
+func (e *Engine) Subgraph(taskIDs []string) (*Engine, error) {
+	if !e.prepared {
+		return nil, errors.New("a subgraph can only be taken once the engine is prepared")
+	}
+	included := make(map[string]bool)
+	pending := []string{}
+	for _, taskID := range taskIDs {
+		if !e.TaskGraph.HasVertex(taskID) || taskID == ROOT_NODE_NAME {
+			return nil, fmt.Errorf("%v is not in the task graph", taskID)
+		}
+		pending = append(pending, taskID)
+	}
+	for len(pending) > 0 {
+		taskID := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if included[taskID] {
+			continue
+		}
+		included[taskID] = true
+		pending = append(pending, e.taskDependencies(taskID)...)
+		pending = append(pending, e.startsAfter[taskID]...)
+		pending = append(pending, e.streamingDeps[taskID]...)
+	}
+
+	sub := NewEngine(e.TopologicGraph)
+	for name, task := range e.tasks {
+		sub.tasks[name] = task
+	}
+	for name, deps := range e.PackageTaskDeps {
+		sub.PackageTaskDeps[name] = deps
+	}
+	for _, taskName := range e.rootEnabledTasks.UnsafeListOfStrings() {
+		sub.rootEnabledTasks.Add(taskName)
+	}
+	for _, breakpoint := range e.breakpoints.UnsafeListOfStrings() {
+		sub.breakpoints.Add(breakpoint)
+	}
+	for taskID := range included {
+		sub.TaskGraph.Add(taskID)
+		for _, dep := range e.TaskGraph.DownEdges(taskID) {
+			depTaskID := dag.VertexName(dep)
+			sub.TaskGraph.Add(depTaskID)
+			sub.TaskGraph.Connect(dag.BasicEdge(taskID, depTaskID))
+		}
+		if shell, ok := e.taskShells[taskID]; ok {
+			sub.taskShells[taskID] = shell
+		}
+		if outputs, ok := e.depOutputs[taskID]; ok {
+			sub.depOutputs[taskID] = outputs
+		}
+		if others, ok := e.startsAfter[taskID]; ok {
+			sub.startsAfter[taskID] = others
+		}
+		if deps, ok := e.streamingDeps[taskID]; ok {
+			sub.streamingDeps[taskID] = deps
+		}
+		if rank, ok := e.outputRanks[taskID]; ok {
+			sub.outputRanks[taskID] = rank
+		}
+	}
+	for _, taskID := range e.shuffledOrder {
+		if included[taskID] {
+			sub.shuffledOrder = append(sub.shuffledOrder, taskID)
+		}
+	}
+	sub.explain = e.explain
+	sub.warnings = append([]string{}, e.warnings...)
+	sub.runWhenDecisions = e.runWhenDecisions
+	sub.outputOrdered = e.outputOrdered
+	// Colors are kept, so that a task looks the same in either engine
+	sub.taskColors = make(map[string]int)
+	for taskID := range included {
+		sub.taskColors[taskID] = e.taskColors[taskID]
+	}
+	sub.colorPaletteSize = e.colorPaletteSize
+	sub.prepared = true
+	return sub, nil
+}
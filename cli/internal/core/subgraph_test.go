@@ -0,0 +1,73 @@
+package core
+
+import (
+	"errors"
+	"sort"
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/util"
+	"gotest.tools/v3/assert"
+
+	"github.com/pyr-sh/dag"
+)
+
+func TestSubgraph(t *testing.T) {
+	graph := &dag.AcyclicGraph{}
+	graph.Add("workspace-a")
+	graph.Add("workspace-b")
+	graph.Add("workspace-c")
+	graph.Connect(dag.BasicEdge("workspace-a", "workspace-b"))
+
+	p := NewEngine(graph)
+	topoDeps := make(util.Set)
+	topoDeps.Add("build")
+	p.AddTask(&Task{Name: "build", TopoDeps: topoDeps, Deps: make(util.Set)})
+	p.AddTask(&Task{Name: "dev", TopoDeps: make(util.Set), Deps: make(util.Set), Persistent: true})
+	// workspace-c#test depends on a persistent task, which is invalid
+	deps := make(util.Set)
+	deps.Add("workspace-c#dev")
+	p.AddTask(&Task{Name: "workspace-c#test", TopoDeps: make(util.Set), Deps: deps})
+	err := p.Prepare(&EngineBuildingOptions{
+		Packages:  []string{"workspace-a", "workspace-b", "workspace-c"},
+		TaskNames: []string{"build", "dev", "test"},
+	})
+	assert.NilError(t, err, "Prepare")
+	hasScript := func(taskID string) bool { return true }
+	var violation *PersistentDependencyError
+	assert.Assert(t, errors.As(p.ValidatePersistentDependencies(hasScript), &violation))
+	assert.Equal(t, violation.Dependent, "workspace-c#test")
+
+	sub, err := p.Subgraph([]string{"workspace-a#build"})
+	assert.NilError(t, err)
+	taskIDs := []string{}
+	for _, v := range sub.TaskGraph.Vertices() {
+		if taskID := dag.VertexName(v); taskID != ROOT_NODE_NAME {
+			taskIDs = append(taskIDs, taskID)
+		}
+	}
+	sort.Strings(taskIDs)
+	assert.DeepEqual(t, taskIDs, []string{"workspace-a#build", "workspace-b#build"})
+	assert.Assert(t, sub.TaskGraph.DownEdges("workspace-a#build").Include("workspace-b#build"))
+	// The unrelated, invalid tasks in workspace-c aren't part of the subgraph
+	assert.NilError(t, sub.ValidatePersistentDependencies(hasScript))
+
+	// Persistent tasks stay persistent
+	sub, err = p.Subgraph([]string{"workspace-a#build", "workspace-c#test"})
+	assert.NilError(t, err)
+	assert.Assert(t, sub.IsPersistent("workspace-c#dev"))
+	violation = nil
+	assert.Assert(t, errors.As(sub.ValidatePersistentDependencies(hasScript), &violation))
+	assert.Equal(t, violation.Dependent, "workspace-c#test")
+
+	// The original engine is left as it was
+	assert.Assert(t, p.TaskGraph.HasVertex("workspace-c#build"))
+
+	_, err = p.Subgraph([]string{"workspace-d#build"})
+	assert.ErrorContains(t, err, "workspace-d#build is not in the task graph")
+}
+
+func TestSubgraphBeforePrepare(t *testing.T) {
+	p := NewEngine(&dag.AcyclicGraph{})
+	_, err := p.Subgraph([]string{"workspace-a#build"})
+	assert.ErrorContains(t, err, "once the engine is prepared")
+}
@@ -0,0 +1,69 @@
+package core
+
+import (
+	"hash/fnv"
+	"sort"
+	"strings"
+
+	"github.com/pyr-sh/dag"
+)
+
+// DefaultColorPaletteSize is the number of colors task ids are spread across
+// when EngineBuildingOptions doesn't configure a palette size
+const DefaultColorPaletteSize = 5
+
+// hashedColor returns the color index derived from a hash of the task id
+func hashedColor(taskID string, paletteSize int) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(taskID))
+	return int(h.Sum32() % uint32(paletteSize))
+}
+
+// resolveTaskColors assigns each task in the TaskGraph a color index derived from
+// a hash of its task id, so that a task keeps its color across runs. When two
+// tasks hash to the same color and the palette has room, the task whose id sorts
+// later takes the next free color instead.
+func (e *Engine) resolveTaskColors(paletteSize int) {
+	if paletteSize <= 0 {
+		paletteSize = DefaultColorPaletteSize
+	}
+	e.colorPaletteSize = paletteSize
+	e.taskColors = make(map[string]int)
+
+	taskIDs := []string{}
+	for _, v := range e.TaskGraph.Vertices() {
+		taskID := dag.VertexName(v)
+		if strings.Contains(taskID, ROOT_NODE_NAME) {
+			continue
+		}
+		taskIDs = append(taskIDs, taskID)
+	}
+	sort.Strings(taskIDs)
+
+	used := make([]bool, paletteSize)
+	for _, taskID := range taskIDs {
+		color := hashedColor(taskID, paletteSize)
+		// With more tasks than colors, collisions can't be avoided
+		if len(taskIDs) <= paletteSize {
+			for used[color] {
+				color = (color + 1) % paletteSize
+			}
+			used[color] = true
+		}
+		e.taskColors[taskID] = color
+	}
+}
+
+// TaskColor returns the index, within the configured palette, of the color used
+// to prefix the given task's logs. It is the same for a task id in every run of
+// the same TaskGraph.
+func (e *Engine) TaskColor(taskID string) int {
+	if color, ok := e.taskColors[taskID]; ok {
+		return color
+	}
+	paletteSize := e.colorPaletteSize
+	if paletteSize <= 0 {
+		paletteSize = DefaultColorPaletteSize
+	}
+	return hashedColor(taskID, paletteSize)
+}
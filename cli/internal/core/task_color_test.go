@@ -0,0 +1,56 @@
+package core
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+
+	"github.com/pyr-sh/dag"
+)
+
+func prepareColorEngine(t *testing.T, pkgs []string, paletteSize int) *Engine {
+	graph := &dag.AcyclicGraph{}
+	graph.Add(ROOT_NODE_NAME)
+	for _, pkg := range pkgs {
+		graph.Add(pkg)
+		graph.Connect(dag.BasicEdge(pkg, ROOT_NODE_NAME))
+	}
+	return prepareTestEngine(t, graph, []*Task{{Name: "build"}, {Name: "test"}}, &EngineBuildingOptions{
+		Packages:         pkgs,
+		TaskNames:        []string{"build", "test"},
+		ColorPaletteSize: paletteSize,
+	})
+}
+
+func TestTaskColorIsStable(t *testing.T) {
+	a := prepareColorEngine(t, []string{"web", "docs", "ui"}, 32)
+	b := prepareColorEngine(t, []string{"ui", "web", "docs"}, 32)
+
+	for _, taskID := range []string{"web#build", "web#test", "docs#build", "docs#test", "ui#build", "ui#test"} {
+		assert.Equal(t, a.TaskColor(taskID), b.TaskColor(taskID), taskID)
+		assert.Assert(t, a.TaskColor(taskID) >= 0 && a.TaskColor(taskID) < 32, taskID)
+	}
+}
+
+func TestTaskColorAvoidsCollisions(t *testing.T) {
+	p := prepareColorEngine(t, []string{"web", "docs", "ui"}, 6)
+
+	seen := make(map[int]string)
+	for _, taskID := range []string{"web#build", "web#test", "docs#build", "docs#test", "ui#build", "ui#test"} {
+		color := p.TaskColor(taskID)
+		other, ok := seen[color]
+		assert.Assert(t, !ok, "%v and %v share color %v", taskID, other, color)
+		seen[color] = taskID
+	}
+}
+
+func TestTaskColorWithSmallPalette(t *testing.T) {
+	// With more tasks than colors, every task still gets a color in the palette
+	p := prepareColorEngine(t, []string{"web", "docs", "ui"}, 2)
+
+	for _, taskID := range []string{"web#build", "web#test", "docs#build", "docs#test", "ui#build", "ui#test"} {
+		assert.Equal(t, p.TaskColor(taskID), hashedColor(taskID, 2), taskID)
+	}
+	// Tasks outside of the TaskGraph are colored by their hash
+	assert.Equal(t, p.TaskColor("api#build"), hashedColor("api#build", 2))
+}
@@ -0,0 +1,48 @@
+package core
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// TaskForFile returns the ID of the given task in the workspace that contains the
+// given repo-relative file, for running a task in the context of a single file.
+// If workspaces are nested, the innermost one owns the file. The root workspace
+// doesn't own any files, so files outside of every other workspace are an error,
+// as is a workspace without a script and pipeline entry for the task. It only
+// requires that tasks have been added to the Engine, not that it has been prepared.
+func (e *Engine) TaskForFile(file turbopath.AnchoredSystemPath, taskName string, packageInfos map[interface{}]*fs.PackageJSON) (string, error) {
+	file = turbopath.AnchoredSystemPath(filepath.Clean(file.ToString()))
+	owner := ""
+	var ownerDir turbopath.AnchoredSystemPath
+	for key, pkg := range packageInfos {
+		pkgName := fmt.Sprintf("%v", key)
+		if pkgName == util.RootPkgName {
+			continue
+		}
+		dir := turbopath.AnchoredSystemPath(filepath.Clean(pkg.Dir.ToString()))
+		if !file.HasPrefix(dir) {
+			continue
+		}
+		if owner == "" || len(dir) > len(ownerDir) {
+			owner = pkgName
+			ownerDir = dir
+		}
+	}
+	if owner == "" {
+		return "", fmt.Errorf("no workspace contains %v", file)
+	}
+
+	if _, ok := packageInfos[owner].Scripts[taskName]; !ok {
+		return "", fmt.Errorf("workspace %v, which contains %v, has no %q script", owner, file, taskName)
+	}
+	taskID := util.GetTaskId(owner, taskName)
+	if _, err := e.getTaskDefinition(owner, taskName, taskID); err != nil {
+		return "", err
+	}
+	return taskID, nil
+}
@@ -0,0 +1,71 @@
+package core
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/util"
+	"gotest.tools/v3/assert"
+
+	"github.com/pyr-sh/dag"
+)
+
+func setupTaskForFileEngine() (*Engine, map[interface{}]*fs.PackageJSON) {
+	p := NewEngine(&dag.AcyclicGraph{})
+	p.AddTask(&Task{Name: "test"})
+	p.AddTask(&Task{Name: "web#lint"})
+	packageInfos := map[interface{}]*fs.PackageJSON{
+		util.RootPkgName: {Scripts: map[string]string{"test": "jest"}},
+		"web": {
+			Dir:     turbopath.AnchoredSystemPath(filepath.Join("apps", "web")),
+			Scripts: map[string]string{"test": "jest", "lint": "eslint"},
+		},
+		"web-e2e": {
+			Dir:     turbopath.AnchoredSystemPath(filepath.Join("apps", "web", "e2e")),
+			Scripts: map[string]string{"test": "playwright"},
+		},
+		"webapp": {
+			Dir:     turbopath.AnchoredSystemPath(filepath.Join("apps", "webapp")),
+			Scripts: map[string]string{"test": "jest", "lint": "eslint"},
+		},
+	}
+	return p, packageInfos
+}
+
+func TestTaskForFile(t *testing.T) {
+	p, packageInfos := setupTaskForFileEngine()
+
+	taskID, err := p.TaskForFile(turbopath.AnchoredSystemPath(filepath.Join("apps", "web", "src", "index.ts")), "test", packageInfos)
+	assert.NilError(t, err)
+	assert.Equal(t, taskID, "web#test")
+
+	// The innermost workspace owns the file
+	taskID, err = p.TaskForFile(turbopath.AnchoredSystemPath(filepath.Join("apps", "web", "e2e", "home.spec.ts")), "test", packageInfos)
+	assert.NilError(t, err)
+	assert.Equal(t, taskID, "web-e2e#test")
+
+	taskID, err = p.TaskForFile(turbopath.AnchoredSystemPath(filepath.Join("apps", "web", "src", "index.ts")), "lint", packageInfos)
+	assert.NilError(t, err)
+	assert.Equal(t, taskID, "web#lint")
+}
+
+func TestTaskForFileErrors(t *testing.T) {
+	p, packageInfos := setupTaskForFileEngine()
+
+	_, err := p.TaskForFile(turbopath.AnchoredSystemPath("jest.config.js"), "test", packageInfos)
+	assert.Error(t, err, "no workspace contains jest.config.js")
+
+	file := turbopath.AnchoredSystemPath(filepath.Join("apps", "web", "e2e", "home.spec.ts"))
+	_, err = p.TaskForFile(file, "lint", packageInfos)
+	assert.Error(t, err, "workspace web-e2e, which contains "+file.ToString()+", has no \"lint\" script")
+
+	// webapp has a lint script, but the pipeline only configures lint for web
+	_, err = p.TaskForFile(turbopath.AnchoredSystemPath(filepath.Join("apps", "webapp", "index.ts")), "lint", packageInfos)
+	var missing *MissingTaskError
+	assert.Assert(t, errors.As(err, &missing), "%v", err)
+	assert.Equal(t, missing.TaskID, "webapp#lint")
+	assert.Error(t, err, "Missing task definition, configure \"lint\" or \"webapp#lint\" in turbo.json")
+}
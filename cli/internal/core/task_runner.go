@@ -0,0 +1,88 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/vercel/turbo/cli/internal/process"
+)
+
+// TaskRunner runs the command of a task and waits for it to exit. Giving the
+// engine a fake TaskRunner lets execution be tested without spawning processes.
+type TaskRunner interface {
+	// Run runs the given task with the given environment variables, in addition
+	// to its own, and returns the exit code of its process. err is only set if
+	// the task couldn't be run.
+	Run(ctx context.Context, taskID string, env []string) (exitCode int, err error)
+}
+
+// CommandRunner is a TaskRunner that runs each task as a child process
+type CommandRunner struct {
+	// Command returns the command that runs the given task. Its environment
+	// defaults to turbo's own.
+	Command func(taskID string) (*exec.Cmd, error)
+}
+
+var _ TaskRunner = (*CommandRunner)(nil)
+
+// Run implements TaskRunner.Run. The process is killed if ctx is done before it exits.
+func (r *CommandRunner) Run(ctx context.Context, taskID string, env []string) (int, error) {
+	cmd, err := r.Command(taskID)
+	if err != nil {
+		return 0, err
+	}
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	cmd.Env = append(cmd.Env, env...)
+	if err := cmd.Start(); err != nil {
+		return 0, err
+	}
+	exited := make(chan struct{})
+	defer close(exited)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = cmd.Process.Kill()
+		case <-exited:
+		}
+	}()
+	err = cmd.Wait()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), nil
+	}
+	return 0, err
+}
+
+// runnerVisitor returns a Visitor that runs each task with the runner of the
+// given options. A task that exits with a non-zero exit code fails with a
+// *process.ChildExit error.
+func runnerVisitor(opts EngineExecutionOptions) Visitor {
+	ctx := runnerContext(opts)
+	return func(taskID string) error {
+		var env []string
+		if opts.TaskEnv != nil {
+			env = opts.TaskEnv(taskID)
+		}
+		exitCode, err := opts.Runner.Run(ctx, taskID, env)
+		if err != nil {
+			return fmt.Errorf("running %v: %w", taskID, err)
+		}
+		if exitCode != 0 {
+			return &process.ChildExit{ExitCode: exitCode, Command: taskID}
+		}
+		return nil
+	}
+}
+
+// runnerContext returns the context that the runner of the given options runs tasks with
+func runnerContext(opts EngineExecutionOptions) context.Context {
+	if opts.Context == nil {
+		return context.Background()
+	}
+	return opts.Context
+}
@@ -0,0 +1,82 @@
+//go:build !windows
+// +build !windows
+
+package core
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os/exec"
+	"sync"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestCommandRunner(t *testing.T) {
+	runner := &CommandRunner{
+		Command: func(taskID string) (*exec.Cmd, error) {
+			return exec.Command("sh", "-c", `test "$TASK_ID" = "$1" && exit 3`, "sh", taskID), nil
+		},
+	}
+	exitCode, err := runner.Run(context.Background(), "ui#build", []string{"TASK_ID=ui#build"})
+	assert.NilError(t, err)
+	assert.Equal(t, exitCode, 3)
+
+	exitCode, err = runner.Run(context.Background(), "ui#lint", []string{"TASK_ID=ui#build"})
+	assert.NilError(t, err)
+	assert.Equal(t, exitCode, 1)
+}
+
+func TestCommandRunnerTransformer(t *testing.T) {
+	p := setupRunnerEngine(t)
+	var mu sync.Mutex
+	outputs := make(map[string]*bytes.Buffer)
+	runner := &CommandRunner{
+		Command: func(taskID string) (*exec.Cmd, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			outputs[taskID] = &bytes.Buffer{}
+			cmd := exec.Command("sh", "-c", "echo $0 && exit 3", taskID)
+			cmd.Stdout = outputs[taskID]
+			return cmd, nil
+		},
+	}
+
+	// echo prints the command instead of running it
+	var taskNames []string
+	errs := p.Execute(nil, EngineExecutionOptions{
+		Concurrency: 1,
+		Runner:      runner,
+		CommandTransformer: func(task *Task, cmd string) (string, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			taskNames = append(taskNames, task.Name)
+			return "echo wrapped " + cmd, nil
+		},
+	})
+	assert.Equal(t, len(errs), 0)
+	assert.DeepEqual(t, taskNames, []string{"build", "build", "build"})
+	assert.Equal(t, outputs["app#build"].String(), "wrapped sh -c echo $0 && exit 3 app#build\n")
+
+	// Without a transformer, the original command runs
+	errs = p.Execute(nil, EngineExecutionOptions{
+		Concurrency: 1,
+		Runner:      runner,
+	})
+	assert.Equal(t, len(errs), 1)
+	assert.Equal(t, outputs["util#build"].String(), "util#build\n")
+
+	// A task whose command can't be transformed fails without being started
+	errs = p.Execute(nil, EngineExecutionOptions{
+		Concurrency: 1,
+		Runner:      runner,
+		CommandTransformer: func(task *Task, cmd string) (string, error) {
+			return "", errors.New("no wrapper")
+		},
+	})
+	assert.Equal(t, len(errs), 1)
+	assert.ErrorContains(t, errs[0], "transforming the command of util#build: no wrapper")
+	assert.Equal(t, outputs["util#build"].String(), "")
+}
@@ -0,0 +1,83 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/process"
+	"github.com/vercel/turbo/cli/internal/util"
+	"gotest.tools/v3/assert"
+
+	"github.com/pyr-sh/dag"
+)
+
+// fakeRunner records the tasks it runs instead of spawning processes
+type fakeRunner struct {
+	mu        sync.Mutex
+	exitCodes map[string]int
+	runs      []string
+	envs      map[string][]string
+}
+
+func (r *fakeRunner) Run(ctx context.Context, taskID string, env []string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.runs = append(r.runs, taskID)
+	if r.envs == nil {
+		r.envs = make(map[string][]string)
+	}
+	r.envs[taskID] = env
+	return r.exitCodes[taskID], nil
+}
+
+func setupRunnerEngine(t *testing.T) *Engine {
+	// app depends on lib, which depends on util
+	graph := &dag.AcyclicGraph{}
+	graph.Add("app")
+	graph.Add("lib")
+	graph.Add("util")
+	graph.Connect(dag.BasicEdge("app", "lib"))
+	graph.Connect(dag.BasicEdge("lib", "util"))
+
+	tasks := []*Task{{Name: "build", TopoDeps: util.SetFromStrings([]string{"build"})}}
+	return prepareTestEngine(t, graph, tasks, &EngineBuildingOptions{
+		Packages:  []string{"app", "lib", "util"},
+		TaskNames: []string{"build"},
+	})
+}
+
+func TestTaskRunner(t *testing.T) {
+	p := setupRunnerEngine(t)
+	runner := &fakeRunner{}
+	errs := p.Execute(nil, EngineExecutionOptions{
+		Concurrency: 10,
+		Runner:      runner,
+		TaskEnv: func(taskID string) []string {
+			return []string{"TASK_ID=" + taskID}
+		},
+		// lib was restored from the cache
+		Restore: func(taskID string) bool {
+			return taskID == "lib#build"
+		},
+	})
+	assert.Equal(t, len(errs), 0)
+	assert.DeepEqual(t, runner.runs, []string{"util#build", "app#build"})
+	assert.DeepEqual(t, runner.envs["app#build"], []string{"TASK_ID=app#build"})
+}
+
+func TestTaskRunnerExitCode(t *testing.T) {
+	p := setupRunnerEngine(t)
+	runner := &fakeRunner{exitCodes: map[string]int{"lib#build": 2}}
+	errs := p.Execute(nil, EngineExecutionOptions{
+		Concurrency: 10,
+		Runner:      runner,
+	})
+	assert.Equal(t, len(errs), 1)
+	var exitErr *process.ChildExit
+	assert.Assert(t, errors.As(errs[0], &exitErr))
+	assert.Equal(t, exitErr.ExitCode, 2)
+	// app isn't run, since lib failed
+	assert.DeepEqual(t, runner.runs, []string{"util#build", "lib#build"})
+}
@@ -0,0 +1,36 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pyr-sh/dag"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// warnUndeclaredInputsAndOutputs adds a warning for each cached, non-persistent
+// task in the TaskGraph that declares neither inputs nor outputs. Nothing the
+// task produces is cached, so a cache hit only replays its logs, which is rarely
+// what was intended. Each task definition is warned about once, however many
+// workspaces it runs in.
+func (e *Engine) warnUndeclaredInputsAndOutputs() {
+	warned := make(util.Set)
+	for _, v := range e.TaskGraph.Vertices() {
+		taskID := dag.VertexName(v)
+		if strings.Contains(taskID, ROOT_NODE_NAME) {
+			continue
+		}
+		pkg, taskName := util.GetPackageTaskFromId(taskID)
+		task, err := e.getTaskDefinition(pkg, taskName, taskID)
+		if err != nil || !task.ShouldCache || task.Persistent {
+			continue
+		}
+		if len(task.Inputs) > 0 || len(task.Outputs) > 0 || warned.Includes(task.Name) {
+			continue
+		}
+		warned.Add(task.Name)
+		e.warnings = append(e.warnings, fmt.Sprintf("%q is cached, but declares neither inputs nor outputs. Configure its \"inputs\" or \"outputs\", or set \"cache\": false", task.Name))
+	}
+	sort.Strings(e.warnings)
+}
@@ -0,0 +1,39 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/util"
+	"gotest.tools/v3/assert"
+
+	"github.com/pyr-sh/dag"
+)
+
+func TestWarnUndeclaredInputsAndOutputs(t *testing.T) {
+	graph := &dag.AcyclicGraph{}
+	graph.Add("web")
+	graph.Add("docs")
+	graph.Add(ROOT_NODE_NAME)
+	graph.Connect(dag.BasicEdge("web", ROOT_NODE_NAME))
+	graph.Connect(dag.BasicEdge("docs", ROOT_NODE_NAME))
+
+	p := NewEngine(graph)
+	p.AddTask(&Task{Name: "build", Deps: make(util.Set), ShouldCache: true, Outputs: []string{"dist/**"}})
+	p.AddTask(&Task{Name: "typecheck", Deps: make(util.Set), ShouldCache: true, Inputs: []string{"src/**/*.ts"}})
+	p.AddTask(&Task{Name: "lint", Deps: make(util.Set), ShouldCache: true})
+	p.AddTask(&Task{Name: "test", Deps: make(util.Set), ShouldCache: true})
+	p.AddTask(&Task{Name: "dev", Deps: make(util.Set), ShouldCache: true, Persistent: true})
+	p.AddTask(&Task{Name: "deploy", Deps: make(util.Set), ShouldCache: false})
+
+	err := p.Prepare(&EngineBuildingOptions{
+		Packages:  []string{"web", "docs"},
+		TaskNames: []string{"build", "typecheck", "lint", "test", "dev", "deploy"},
+	})
+	assert.NilError(t, err, "Prepare")
+
+	// lint and test are warned about once each, even though they run in two workspaces
+	assert.DeepEqual(t, p.Warnings(), []string{
+		`"lint" is cached, but declares neither inputs nor outputs. Configure its "inputs" or "outputs", or set "cache": false`,
+		`"test" is cached, but declares neither inputs nor outputs. Configure its "inputs" or "outputs", or set "cache": false`,
+	})
+}
@@ -0,0 +1,333 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pyr-sh/dag"
+	"github.com/vercel/turbo/cli/internal/doublestar"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// CompleteGraph is the repo that the TaskGraph is validated against
+type CompleteGraph struct {
+	// PackageInfos are the package.json files of the workspaces, keyed by
+	// workspace name, with the root package.json under util.RootPkgName
+	PackageInfos map[interface{}]*fs.PackageJSON
+}
+
+// HasScript returns true if the task's workspace defines a script for it. Tasks
+// without one are never run.
+func (g *CompleteGraph) HasScript(taskID string) bool {
+	pkg, taskName := util.GetPackageTaskFromId(taskID)
+	pkgJSON, ok := g.PackageInfos[pkg]
+	if !ok {
+		return false
+	}
+	_, ok = pkgJSON.Scripts[taskName]
+	return ok
+}
+
+// ValidateOptions configures the checks made by Validate
+type ValidateOptions struct {
+	// Strict also fails on configuration that can't run as intended but that is
+	// otherwise skipped: dependencies on tasks that no workspace has a script
+	// for, and root tasks without a script in the root package.json
+	Strict bool
+	// OutputOverlaps also fails if two tasks in a workspace, that may run at
+	// the same time, have overlapping outputs
+	OutputOverlaps bool
+}
+
+// Validate runs every check that the prepared TaskGraph must pass before a run,
+// and returns an error describing each check that failed, in this order:
+//   - a cycle in the task graph
+//   - tasks that depend on a persistent task
+//   - with opts.Strict, dependencies on tasks that no workspace implements
+//   - root tasks that invoke turbo, or, with opts.Strict, that have no script
+//   - with opts.OutputOverlaps, tasks that may run at the same time and whose outputs overlap
+//
+// Each check can also be made on its own.
+func (e *Engine) Validate(graph *CompleteGraph, opts ValidateOptions) error {
+	checks := []struct {
+		heading string
+		check   func() error
+	}{
+		{"Invalid task dependency graph", e.ValidateNoCycles},
+		{"Invalid persistent task configuration", func() error { return e.ValidatePersistentDependencies(graph.HasScript) }},
+		{"Missing tasks", func() error {
+			if !opts.Strict {
+				return nil
+			}
+			return e.ValidateMissingTasks(graph)
+		}},
+		{"Invalid root task configuration", func() error { return e.ValidateRootTasks(graph, opts.Strict) }},
+		{"Overlapping task outputs", func() error {
+			if !opts.OutputOverlaps {
+				return nil
+			}
+			return e.ValidateOutputOverlaps(graph.HasScript)
+		}},
+	}
+	failures := []string{}
+	for _, c := range checks {
+		if err := c.check(); err != nil {
+			failures = append(failures, fmt.Sprintf("%v:\n%v", c.heading, err))
+		}
+	}
+	if len(failures) == 0 {
+		return nil
+	}
+	return errors.New(strings.Join(failures, "\n"))
+}
+
+// ValidateMissingTasks checks that every task that is depended on, in dependsOn,
+// is implemented by a script in at least one workspace. Prepare only requires
+// such tasks to be configured, and a task without a script is skipped, so the
+// dependency silently does nothing. A dependency on a
+// workspace's task, or on a pattern of tasks, must be implemented by a matching
+// workspace and script. Every missing task is reported, one per line, sorted by
+// the task that depends on it.
+func (e *Engine) ValidateMissingTasks(graph *CompleteGraph) error {
+	type missing struct {
+		taskName string
+		dep      string
+	}
+	missingTasks := []missing{}
+	seen := make(map[missing]bool)
+	check := func(taskName string, deps []string) {
+		for _, dep := range deps {
+			if seen[missing{taskName, dep}] || graph.implements(dep) {
+				continue
+			}
+			seen[missing{taskName, dep}] = true
+			missingTasks = append(missingTasks, missing{taskName: taskName, dep: dep})
+		}
+	}
+	for taskName, task := range e.tasks {
+		check(taskName, task.Deps.UnsafeListOfStrings())
+		check(taskName, task.TopoDeps.UnsafeListOfStrings())
+	}
+	for taskID, deps := range e.PackageTaskDeps {
+		check(taskID, deps)
+	}
+	if len(missingTasks) == 0 {
+		return nil
+	}
+	sort.Slice(missingTasks, func(i, j int) bool {
+		if missingTasks[i].taskName != missingTasks[j].taskName {
+			return missingTasks[i].taskName < missingTasks[j].taskName
+		}
+		return missingTasks[i].dep < missingTasks[j].dep
+	})
+	errs := make([]string, len(missingTasks))
+	for i, m := range missingTasks {
+		errs[i] = fmt.Sprintf("\"%v\" depends on \"%v\", but no workspace has a script for it", m.taskName, m.dep)
+	}
+	return errors.New(strings.Join(errs, "\n"))
+}
+
+// implements returns true if a workspace has a script for the dependency, which
+// is a task name, or a task in a specific workspace, either of which can be a
+// pattern
+func (g *CompleteGraph) implements(dep string) bool {
+	pkgPattern := ""
+	taskName := dep
+	if util.IsPackageTask(dep) {
+		pkgPattern, taskName = util.GetPackageTaskFromId(dep)
+	}
+	for key, pkgJSON := range g.PackageInfos {
+		pkg, ok := key.(string)
+		if !ok || (pkgPattern != "" && !matchesPackage(pkgPattern, pkg)) {
+			continue
+		}
+		for script := range pkgJSON.Scripts {
+			if script == taskName {
+				return true
+			}
+			if isScriptPattern(taskName) {
+				// A malformed pattern matches no scripts
+				if matched, _ := path.Match(taskName, script); matched {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+var _isTurbo = regexp.MustCompile(fmt.Sprintf("(?:^|%v|\\s)turbo(?:$|\\s)", regexp.QuoteMeta(string(filepath.Separator))))
+
+// CommandLooksLikeTurbo returns true if the script runs turbo, which a root task
+// can't do without running itself again
+func CommandLooksLikeTurbo(command string) bool {
+	return _isTurbo.MatchString(command)
+}
+
+// ValidateRootTasks checks that no task in the TaskGraph from the root package
+// runs a script that invokes turbo, which would run the task again, in a loop.
+// If strict, root tasks must also have a script in the root package.json. Every
+// problem is reported, one per line, sorted by task id.
+func (e *Engine) ValidateRootTasks(graph *CompleteGraph, strict bool) error {
+	rootPrefix := util.RootPkgName + util.TaskDelimiter
+	taskIDs := []string{}
+	for _, v := range e.TaskGraph.Vertices() {
+		taskID := dag.VertexName(v)
+		if strings.HasPrefix(taskID, rootPrefix) {
+			taskIDs = append(taskIDs, taskID)
+		}
+	}
+	sort.Strings(taskIDs)
+
+	errs := []string{}
+	for _, taskID := range taskIDs {
+		taskName := util.RootTaskTaskName(taskID)
+		var command string
+		var ok bool
+		if rootPkg, hasRoot := graph.PackageInfos[util.RootPkgName]; hasRoot {
+			command, ok = rootPkg.Scripts[taskName]
+		}
+		if !ok {
+			if strict {
+				errs = append(errs, fmt.Sprintf("root task %v has no script in the root package.json", taskID))
+			}
+			continue
+		}
+		if CommandLooksLikeTurbo(command) {
+			errs = append(errs, fmt.Sprintf("root task %v (%v) looks like it invokes turbo and might cause a loop", taskName, command))
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errors.New(strings.Join(errs, "\n"))
+}
+
+// ValidateOnly prepares the TaskGraph and runs Validate over it, including the
+// check for overlapping outputs, without running any tasks, calculating hashes
+// or touching the cache. Tasks must have been added to the Engine, and
+// options.PackageInfos must be set. A target that isn't configured in the
+// pipeline is reported on its own, since there is no graph to validate.
+func (e *Engine) ValidateOnly(options *EngineBuildingOptions) error {
+	for _, taskName := range options.TaskNames {
+		if !e.hasTask(taskName) {
+			return fmt.Errorf("task `%v` not found in turbo `pipeline` in \"turbo.json\". Are you sure you added it?", taskName)
+		}
+	}
+	if err := e.Prepare(options); err != nil {
+		return err
+	}
+	return e.Validate(&CompleteGraph{PackageInfos: options.PackageInfos}, ValidateOptions{OutputOverlaps: true})
+}
+
+// hasTask returns true if the task is configured, either for every workspace or
+// for a specific one
+func (e *Engine) hasTask(taskName string) bool {
+	for name := range e.tasks {
+		if name == taskName {
+			return true
+		}
+		if util.IsPackageTask(name) {
+			if _, task := util.GetPackageTaskFromId(name); task == taskName {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ValidateOutputOverlaps returns an error if two tasks in the same workspace
+// declare outputs that overlap, unless one of them depends on the other. Tasks
+// that may run at the same time would race to write those files, and each
+// would cache the other's outputs.
+func (e *Engine) ValidateOutputOverlaps(hasScript func(taskID string) bool) error {
+	tasksByPkg := make(map[string][]string)
+	for _, v := range e.TaskGraph.Vertices() {
+		taskID := dag.VertexName(v)
+		if strings.Contains(taskID, ROOT_NODE_NAME) || !hasScript(taskID) {
+			continue
+		}
+		pkg, _ := util.GetPackageTaskFromId(taskID)
+		tasksByPkg[pkg] = append(tasksByPkg[pkg], taskID)
+	}
+	pkgs := make([]string, 0, len(tasksByPkg))
+	for pkg := range tasksByPkg {
+		pkgs = append(pkgs, pkg)
+	}
+	sort.Strings(pkgs)
+
+	for _, pkg := range pkgs {
+		taskIDs := tasksByPkg[pkg]
+		sort.Strings(taskIDs)
+		for i, taskID := range taskIDs {
+			for _, otherTaskID := range taskIDs[i+1:] {
+				glob, ok := e.overlappingOutput(taskID, otherTaskID)
+				if !ok {
+					continue
+				}
+				ordered, err := e.dependsOn(taskID, otherTaskID)
+				if err != nil {
+					return err
+				}
+				if !ordered {
+					return fmt.Errorf("\"%v\" and \"%v\" can run at the same time, but both have outputs matching \"%v\"", taskID, otherTaskID, glob)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// overlappingOutput returns an output glob of one task that matches an output
+// glob of the other
+func (e *Engine) overlappingOutput(taskID string, otherTaskID string) (string, bool) {
+	outputs := e.taskOutputs(taskID)
+	otherOutputs := e.taskOutputs(otherTaskID)
+	for _, output := range outputs {
+		for _, otherOutput := range otherOutputs {
+			if output == otherOutput {
+				return output, true
+			}
+			// Treating one glob as a path is enough to catch nested outputs,
+			// like "dist/**" and "dist/types/**"
+			if matches, _ := doublestar.Match(output, otherOutput); matches {
+				return output, true
+			}
+			if matches, _ := doublestar.Match(otherOutput, output); matches {
+				return otherOutput, true
+			}
+		}
+	}
+	return "", false
+}
+
+func (e *Engine) taskOutputs(taskID string) []string {
+	pkg, taskName := util.GetPackageTaskFromId(taskID)
+	task, err := e.getTaskDefinition(pkg, taskName, taskID)
+	if err != nil {
+		return nil
+	}
+	return task.Outputs
+}
+
+// dependsOn returns true if either task depends on the other, directly or transitively
+func (e *Engine) dependsOn(taskID string, otherTaskID string) (bool, error) {
+	deps, err := e.TaskGraph.Ancestors(taskID)
+	if err != nil {
+		return false, err
+	}
+	if deps.Include(otherTaskID) {
+		return true, nil
+	}
+	otherDeps, err := e.TaskGraph.Ancestors(otherTaskID)
+	if err != nil {
+		return false, err
+	}
+	return otherDeps.Include(taskID), nil
+}
@@ -0,0 +1,174 @@
+package core
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/util"
+	"gotest.tools/v3/assert"
+
+	"github.com/pyr-sh/dag"
+)
+
+// setupValidateEngine returns an Engine for a repo with a web workspace, with the
+// tasks added, and the options to prepare it with
+func setupValidateEngine(rootScripts map[string]string, taskNames []string, tasks ...*Task) (*Engine, *EngineBuildingOptions) {
+	graph := &dag.AcyclicGraph{}
+	graph.Add("web")
+	graph.Add(util.RootPkgName)
+	graph.Add(ROOT_NODE_NAME)
+	graph.Connect(dag.BasicEdge("web", ROOT_NODE_NAME))
+	graph.Connect(dag.BasicEdge(util.RootPkgName, ROOT_NODE_NAME))
+
+	options := &EngineBuildingOptions{
+		Packages:  []string{"web", util.RootPkgName},
+		TaskNames: taskNames,
+		PackageInfos: map[interface{}]*fs.PackageJSON{
+			util.RootPkgName: {Scripts: rootScripts},
+			"web":            {Scripts: map[string]string{"build": "next build", "types": "tsc", "dev": "next dev"}},
+		},
+	}
+	return newTestEngine(graph, tasks), options
+}
+
+func TestValidateOnly(t *testing.T) {
+	deps := make(util.Set)
+	deps.Add("types")
+	p, options := setupValidateEngine(nil, []string{"build"},
+		&Task{Name: "build", Deps: deps, Outputs: []string{"dist/**"}},
+		// build depends on types, so they never write to dist at the same time
+		&Task{Name: "types", Outputs: []string{"dist/types/**"}},
+	)
+	assert.NilError(t, p.ValidateOnly(options))
+}
+
+func TestValidateOnlyUnknownTarget(t *testing.T) {
+	p, options := setupValidateEngine(nil, []string{"build"}, &Task{Name: "types"})
+	assert.Error(t, p.ValidateOnly(options), "task `build` not found in turbo `pipeline` in \"turbo.json\". Are you sure you added it?")
+}
+
+func TestValidateOnlyPersistentDependency(t *testing.T) {
+	deps := make(util.Set)
+	deps.Add("dev")
+	p, options := setupValidateEngine(nil, []string{"build"},
+		&Task{Name: "build", Deps: deps},
+		&Task{Name: "dev", Persistent: true},
+	)
+	assert.Error(t, p.ValidateOnly(options), "Invalid persistent task configuration:\n\"web#dev\" is a persistent task, \"web#build\" cannot depend on it")
+}
+
+func TestValidateOnlyOutputOverlap(t *testing.T) {
+	p, options := setupValidateEngine(nil, []string{"build", "types"},
+		&Task{Name: "build", Outputs: []string{"dist/**"}},
+		&Task{Name: "types", Outputs: []string{"dist/types/**"}},
+	)
+	assert.Error(t, p.ValidateOnly(options), "Overlapping task outputs:\n\"web#build\" and \"web#types\" can run at the same time, but both have outputs matching \"dist/**\"")
+
+	// Validate only checks outputs when asked to
+	graph := &CompleteGraph{PackageInfos: options.PackageInfos}
+	assert.NilError(t, p.Validate(graph, ValidateOptions{}))
+	assert.Error(t, p.ValidateOutputOverlaps(graph.HasScript), "\"web#build\" and \"web#types\" can run at the same time, but both have outputs matching \"dist/**\"")
+}
+
+func TestValidate(t *testing.T) {
+	deps := make(util.Set)
+	deps.Add("types")
+	p, options := setupValidateEngine(map[string]string{"lint": "eslint ."}, []string{"build", "lint"},
+		&Task{Name: "build", Deps: deps},
+		&Task{Name: "types"},
+		&Task{Name: "//#lint"},
+	)
+	assert.NilError(t, p.Prepare(options), "Prepare")
+	graph := &CompleteGraph{PackageInfos: options.PackageInfos}
+	assert.NilError(t, p.Validate(graph, ValidateOptions{Strict: true}))
+}
+
+func TestValidateCycle(t *testing.T) {
+	deps := make(util.Set)
+	deps.Add("types")
+	p, options := setupValidateEngine(nil, []string{"build"},
+		&Task{Name: "build", Deps: deps},
+		&Task{Name: "types"},
+	)
+	assert.NilError(t, p.Prepare(options), "Prepare")
+	graph := &CompleteGraph{PackageInfos: options.PackageInfos}
+	// A dependency added after the TaskGraph was prepared
+	p.TaskGraph.Connect(dag.BasicEdge("web#types", "web#build"))
+
+	var cycleErr *CycleError
+	assert.Assert(t, errors.As(p.ValidateNoCycles(), &cycleErr))
+	assert.DeepEqual(t, cycleErr.Path, []string{"web#build", "web#types", "web#build"})
+	assert.Error(t, p.Validate(graph, ValidateOptions{}), "Invalid task dependency graph:\ncycle detected: web#build -> web#types -> web#build")
+}
+
+func TestValidatePersistentDependency(t *testing.T) {
+	deps := make(util.Set)
+	deps.Add("dev")
+	p, options := setupValidateEngine(nil, []string{"build"},
+		&Task{Name: "build", Deps: deps},
+		&Task{Name: "dev", Persistent: true},
+	)
+	assert.NilError(t, p.Prepare(options), "Prepare")
+	graph := &CompleteGraph{PackageInfos: options.PackageInfos}
+	assert.Error(t, p.Validate(graph, ValidateOptions{}), "Invalid persistent task configuration:\n\"web#dev\" is a persistent task, \"web#build\" cannot depend on it")
+}
+
+func TestValidateMissingTasks(t *testing.T) {
+	deps := make(util.Set)
+	deps.Add("codegen")
+	deps.Add("types")
+	deps.Add("lint:*")
+	topoDeps := make(util.Set)
+	topoDeps.Add("ui#build")
+	p, options := setupValidateEngine(nil, []string{"build"},
+		&Task{Name: "build", Deps: deps, TopoDeps: topoDeps},
+		&Task{Name: "types"},
+		// codegen is configured in turbo.json, but no workspace has the script
+		&Task{Name: "codegen"},
+	)
+	assert.NilError(t, p.Prepare(options), "Prepare")
+	graph := &CompleteGraph{PackageInfos: options.PackageInfos}
+
+	assert.Error(t, p.ValidateMissingTasks(graph), "\"build\" depends on \"codegen\", but no workspace has a script for it\n"+
+		"\"build\" depends on \"lint:*\", but no workspace has a script for it\n"+
+		"\"build\" depends on \"ui#build\", but no workspace has a script for it")
+	// Missing tasks are skipped when running, so they only fail strict validation
+	assert.NilError(t, p.Validate(graph, ValidateOptions{}))
+	assert.ErrorContains(t, p.Validate(graph, ValidateOptions{Strict: true}), "Missing tasks:\n\"build\" depends on \"codegen\"")
+}
+
+func TestValidateRootTasks(t *testing.T) {
+	p, options := setupValidateEngine(map[string]string{"build": "turbo run build"}, []string{"build", "lint"},
+		&Task{Name: "build"},
+		&Task{Name: "//#build"},
+		&Task{Name: "//#lint"},
+	)
+	assert.NilError(t, p.Prepare(options), "Prepare")
+	graph := &CompleteGraph{PackageInfos: options.PackageInfos}
+
+	assert.Error(t, p.ValidateRootTasks(graph, false), "root task build (turbo run build) looks like it invokes turbo and might cause a loop")
+	assert.Error(t, p.Validate(graph, ValidateOptions{Strict: true}), "Invalid root task configuration:\n"+
+		"root task build (turbo run build) looks like it invokes turbo and might cause a loop\n"+
+		"root task //#lint has no script in the root package.json")
+}
+
+func TestValidateCombined(t *testing.T) {
+	deps := make(util.Set)
+	deps.Add("dev")
+	deps.Add("codegen")
+	p, options := setupValidateEngine(map[string]string{"build": "turbo build"}, []string{"build"},
+		&Task{Name: "build", Deps: deps},
+		&Task{Name: "dev", Persistent: true},
+		&Task{Name: "codegen"},
+		&Task{Name: "//#build"},
+	)
+	assert.NilError(t, p.Prepare(options), "Prepare")
+	graph := &CompleteGraph{PackageInfos: options.PackageInfos}
+	assert.Error(t, p.Validate(graph, ValidateOptions{Strict: true}), "Invalid persistent task configuration:\n"+
+		"\"web#dev\" is a persistent task, \"web#build\" cannot depend on it\n"+
+		"Missing tasks:\n"+
+		"\"build\" depends on \"codegen\", but no workspace has a script for it\n"+
+		"Invalid root task configuration:\n"+
+		"root task build (turbo build) looks like it invokes turbo and might cause a loop")
+}
@@ -75,8 +75,9 @@ func (d *daemon) logError(err error) {
 // we do not need to read the log file.
 var _logFileFlags = os.O_WRONLY | os.O_APPEND | os.O_CREATE
 
-// GetCmd returns the root daemon command
-func GetCmd(helper *cmdutil.Helper, signalWatcher *signals.Watcher) *cobra.Command {
+// GetCmd returns the root daemon command. taskGraph builds the task graphs that
+// the daemon serves.
+func GetCmd(helper *cmdutil.Helper, signalWatcher *signals.Watcher, taskGraph server.TaskGraphFunc) *cobra.Command {
 	var idleTimeout time.Duration
 	cmd := &cobra.Command{
 		Use:           "daemon",
@@ -115,7 +116,7 @@ func GetCmd(helper *cmdutil.Helper, signalWatcher *signals.Watcher) *cobra.Comma
 				timedOutCh: make(chan struct{}),
 			}
 			serverName := getRepoHash(base.RepoRoot)
-			turboServer, err := server.New(serverName, d.logger.Named("rpc server"), base.RepoRoot, base.TurboVersion, logFilePath)
+			turboServer, err := server.New(serverName, d.logger.Named("rpc server"), base.RepoRoot, base.TurboVersion, logFilePath, taskGraph)
 			if err != nil {
 				d.logError(err)
 				return err
@@ -0,0 +1,96 @@
+// Package dispatch defines the boundary at which turbo hands a task off to be run,
+// so that tasks can be run on this machine or sent to a worker elsewhere.
+package dispatch
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/vercel/turbo/cli/internal/process"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+// TaskSpec holds everything needed to run a task, independent of where it runs
+type TaskSpec struct {
+	// Command is the executable to run, e.g. the package manager
+	Command string
+	Args    []string
+	// Env is the complete environment of the task's process
+	Env []string
+	// Dir is the working directory of the task, relative to the repository root
+	Dir turbopath.AnchoredSystemPath
+	// Hash is the hash of the task's inputs
+	Hash string
+	// Stdout and Stderr receive the task's output as it is produced
+	Stdout io.Writer
+	Stderr io.Writer
+	// Stdin, if set, is the task's input
+	Stdin io.Reader
+	// Timeout, if set, is how long the task can run for before it is stopped
+	Timeout time.Duration
+}
+
+// timeoutGracePeriod is how long a task that timed out has to exit after
+// being asked to, before it is killed
+const timeoutGracePeriod = 10 * time.Second
+
+// Result describes how a dispatched task's process finished
+type Result struct {
+	// Ran is true if the task's process was started, in which case the
+	// rest of the result is populated
+	Ran      bool
+	ExitCode int
+	// PeakMemory is the maximum resident set size of the process in bytes,
+	// or 0 if it is unavailable
+	PeakMemory uint64
+}
+
+// Dispatcher runs a task and waits for it to finish. If the task exits with a
+// non-zero exit code, Dispatch returns a *process.ChildExit error, and if it is
+// stopped for exceeding its timeout, a *process.ChildTimeout error. If ctx is
+// done before the task exits, the task is stopped and ctx's error is returned. If
+// turbo is shutting down, it returns process.ErrClosing.
+type Dispatcher interface {
+	Dispatch(ctx context.Context, taskID string, spec TaskSpec) (Result, error)
+}
+
+// localDispatcher runs tasks as child processes on this machine
+type localDispatcher struct {
+	processes *process.Manager
+	repoRoot  turbopath.AbsoluteSystemPath
+}
+
+// NewLocalDispatcher returns a Dispatcher that runs tasks as child processes
+// managed by the given process manager
+func NewLocalDispatcher(processes *process.Manager, repoRoot turbopath.AbsoluteSystemPath) Dispatcher {
+	return &localDispatcher{
+		processes: processes,
+		repoRoot:  repoRoot,
+	}
+}
+
+// Dispatch implements Dispatcher.Dispatch
+func (d *localDispatcher) Dispatch(ctx context.Context, taskID string, spec TaskSpec) (Result, error) {
+	cmd := exec.Command(spec.Command, spec.Args...)
+	cmd.Dir = spec.Dir.RestoreAnchor(d.repoRoot).ToString()
+	cmd.Env = spec.Env
+	cmd.Stdout = spec.Stdout
+	cmd.Stderr = spec.Stderr
+	cmd.Stdin = spec.Stdin
+	err := d.processes.ExecContext(ctx, cmd, spec.Timeout, timeoutGracePeriod)
+	return resultFromProcessState(cmd.ProcessState), err
+}
+
+func resultFromProcessState(state *os.ProcessState) Result {
+	if state == nil {
+		return Result{}
+	}
+	return Result{
+		Ran:        true,
+		ExitCode:   state.ExitCode(),
+		PeakMemory: peakMemory(state),
+	}
+}
@@ -0,0 +1,59 @@
+//go:build !windows
+// +build !windows
+
+package dispatch
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/process"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"gotest.tools/v3/assert"
+)
+
+func TestLocalDispatcher(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	assert.NilError(t, os.MkdirAll(filepath.Join(repoRoot.ToString(), "packages", "ui"), 0755))
+	d := NewLocalDispatcher(process.NewManager(hclog.Default()), repoRoot)
+
+	stdout := &bytes.Buffer{}
+	result, err := d.Dispatch(context.Background(), "ui#build", TaskSpec{
+		Command: "sh",
+		Args:    []string{"-c", "echo $GREETING from $(basename $PWD); exit 3"},
+		Env:     []string{"GREETING=hello"},
+		Dir:     turbopath.AnchoredUnixPath("packages/ui").ToSystemPath(),
+		Stdout:  stdout,
+	})
+
+	exitErr := &process.ChildExit{}
+	assert.Assert(t, errors.As(err, &exitErr), "expected a ChildExit error, got %v", err)
+	assert.Equal(t, exitErr.ExitCode, 3)
+	assert.Equal(t, result.Ran, true)
+	assert.Equal(t, result.ExitCode, 3)
+	assert.Equal(t, stdout.String(), "hello from ui\n")
+}
+
+func TestLocalDispatcherTimeout(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	d := NewLocalDispatcher(process.NewManager(hclog.Default()), repoRoot)
+
+	result, err := d.Dispatch(context.Background(), "ui#build", TaskSpec{
+		Command: "sh",
+		Args:    []string{"-c", "sleep 10"},
+		Dir:     turbopath.AnchoredUnixPath("").ToSystemPath(),
+		Timeout: 100 * time.Millisecond,
+	})
+
+	timeoutErr := &process.ChildTimeout{}
+	assert.Assert(t, errors.As(err, &timeoutErr), "expected a ChildTimeout error, got %v", err)
+	assert.Equal(t, timeoutErr.Timeout, 100*time.Millisecond)
+	assert.Equal(t, result.Ran, true)
+}
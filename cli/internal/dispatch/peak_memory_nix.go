@@ -0,0 +1,24 @@
+//go:build !windows
+// +build !windows
+
+package dispatch
+
+import (
+	"os"
+	"runtime"
+	"syscall"
+)
+
+// peakMemory returns the maximum resident set size, in bytes, of an exited
+// process and the descendants it waited for, or 0 if it is unavailable.
+func peakMemory(state *os.ProcessState) uint64 {
+	rusage, ok := state.SysUsage().(*syscall.Rusage)
+	if !ok || rusage == nil {
+		return 0
+	}
+	// ru_maxrss is reported in bytes on macOS, and kilobytes elsewhere
+	if runtime.GOOS == "darwin" {
+		return uint64(rusage.Maxrss)
+	}
+	return uint64(rusage.Maxrss) * 1024
+}
@@ -0,0 +1,12 @@
+//go:build windows
+// +build windows
+
+package dispatch
+
+import "os"
+
+// peakMemory is not available on windows, as the process state does not
+// include memory usage.
+func peakMemory(state *os.ProcessState) uint64 {
+	return 0
+}
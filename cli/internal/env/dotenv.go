@@ -0,0 +1,77 @@
+package env
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+// ReadDotEnv returns the sorted key=value pairs of the env vars set by the given
+// env files, such as .env and .env.local, relative to dir. Files that don't exist
+// are skipped, and a var set by more than one file takes its value from the last.
+func ReadDotEnv(dir turbopath.AbsoluteSystemPath, files []string) ([]string, error) {
+	vars := make(map[string]string)
+	for _, file := range files {
+		contents, err := dir.UntypedJoin(file).ReadFile()
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if err := parseDotEnv(contents, vars); err != nil {
+			return nil, fmt.Errorf("invalid env file %v: %w", file, err)
+		}
+	}
+	pairs := make([]string, 0, len(vars))
+	for k, v := range vars {
+		pairs = append(pairs, fmt.Sprintf("%v=%v", k, v))
+	}
+	sort.Strings(pairs)
+	return pairs, nil
+}
+
+// parseDotEnv adds the env vars set by each KEY=value line of contents to vars.
+// Blank lines and lines starting with # are ignored, a line can start with
+// "export ", and values can be single- or double-quoted. Escapes such as \n are
+// only expanded in double-quoted values.
+func parseDotEnv(contents []byte, vars map[string]string) error {
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" || strings.ContainsAny(key, " \t") {
+			return fmt.Errorf("line %v: expected KEY=value", lineNumber)
+		}
+		value = strings.TrimSpace(value)
+		switch {
+		case len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"':
+			unquoted, err := strconv.Unquote(value)
+			if err != nil {
+				return fmt.Errorf("line %v: invalid double-quoted value", lineNumber)
+			}
+			value = unquoted
+		case len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'':
+			value = value[1 : len(value)-1]
+		default:
+			// Unquoted values can end with a comment
+			if i := strings.Index(value, " #"); i >= 0 {
+				value = strings.TrimSpace(value[:i])
+			}
+		}
+		vars[key] = value
+	}
+	return scanner.Err()
+}
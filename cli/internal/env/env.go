@@ -3,6 +3,7 @@ package env
 import (
 	"fmt"
 	"os"
+	"runtime"
 	"sort"
 	"strings"
 
@@ -20,11 +21,98 @@ func getEnvMap() map[string]string {
 	return envMap
 }
 
-// getEnvPairsFromKeys returns a slice of key=value pairs for all env var keys specified in envKeys
-func getEnvPairsFromKeys(envKeys []string, allEnvVars map[string]string) []string {
+// normalizeEnvMap returns a copy of the env var map with every key upper-cased,
+// for platforms where env var names are case-insensitive
+func normalizeEnvMap(envMap map[string]string) map[string]string {
+	normalized := make(map[string]string, len(envMap))
+	for k, v := range envMap {
+		normalized[strings.ToUpper(k)] = v
+	}
+	return normalized
+}
+
+// normalizeEnvKeys returns a copy of the env var keys, upper-cased
+func normalizeEnvKeys(keys []string) []string {
+	normalized := make([]string, len(keys))
+	for i, key := range keys {
+		normalized[i] = strings.ToUpper(key)
+	}
+	return normalized
+}
+
+// envKeyPattern is an env var key in which each * matches any sequence of characters
+// (e.g. AWS_* matches AWS_REGION)
+type envKeyPattern struct {
+	key string
+	// parts are the key split at each *, or nil if the key has none
+	parts []string
+}
+
+// compileEnvKeys compiles each of the given env var keys once, so that they can be
+// matched against every env var. It errors if a key can't be an env var name.
+func compileEnvKeys(keys []string) ([]envKeyPattern, error) {
+	patterns := make([]envKeyPattern, len(keys))
+	for i, key := range keys {
+		if key == "" || strings.ContainsAny(key, "= \t\n") {
+			return nil, fmt.Errorf("invalid env var %q: must be non-empty and can't contain \"=\" or whitespace", key)
+		}
+		patterns[i] = envKeyPattern{key: key}
+		if strings.Contains(key, "*") {
+			patterns[i].parts = strings.Split(key, "*")
+		}
+	}
+	return patterns, nil
+}
+
+// isWildcard returns true if the pattern's key contains a *
+func (p envKeyPattern) isWildcard() bool {
+	return p.parts != nil
+}
+
+// matches returns true if the env var key matches the pattern
+func (p envKeyPattern) matches(envVar string) bool {
+	if !p.isWildcard() {
+		return p.key == envVar
+	}
+	first, last := p.parts[0], p.parts[len(p.parts)-1]
+	if !strings.HasPrefix(envVar, first) {
+		return false
+	}
+	rest := envVar[len(first):]
+	for _, part := range p.parts[1 : len(p.parts)-1] {
+		i := strings.Index(rest, part)
+		if i < 0 {
+			return false
+		}
+		rest = rest[i+len(part):]
+	}
+	return strings.HasSuffix(rest, last)
+}
+
+// matchesAnyEnvKey returns true if the env var key matches any of the given patterns
+func matchesAnyEnvKey(patterns []envKeyPattern, envVar string) bool {
+	for _, pattern := range patterns {
+		if pattern.matches(envVar) {
+			return true
+		}
+	}
+	return false
+}
+
+// getEnvPairsFromKeys returns a slice of key=value pairs for all env var keys specified in envKeys.
+// Keys containing a * are wildcards, and add a pair for each env var that is set and matches them.
+func getEnvPairsFromKeys(envKeys []envKeyPattern, allEnvVars map[string]string) []string {
 	hashableConfigEnvPairs := []string{}
-	for _, envVar := range envKeys {
-		hashableConfigEnvPairs = append(hashableConfigEnvPairs, fmt.Sprintf("%v=%v", envVar, allEnvVars[envVar]))
+	for _, envKey := range envKeys {
+		if envKey.isWildcard() {
+			for k, v := range allEnvVars {
+				if envKey.matches(k) {
+					hashableConfigEnvPairs = append(hashableConfigEnvPairs, fmt.Sprintf("%v=%v", k, v))
+				}
+			}
+			continue
+		}
+		hashableConfigEnvPairs = append(hashableConfigEnvPairs, fmt.Sprintf("%v=%v", envKey.key, allEnvVars[envKey.key]))
 	}
 
 	return hashableConfigEnvPairs
@@ -54,12 +142,41 @@ func getEnvPairsFromPrefixes(includePrefixes []string, excludePrefix string, all
 	return allEnvPairs
 }
 
-// GetHashableEnvPairs returns all sorted key=value env var pairs for both frameworks and from envKeys
-func GetHashableEnvPairs(envKeys []string, envPrefixes []string) []string {
-	allEnvVars := getEnvMap()
+// GetHashableEnvPairs returns all sorted key=value env var pairs for both frameworks and from envKeys.
+// Env vars matching passThroughKeys are left out of the framework env vars, so that their values
+// don't affect the hash unless they are also listed in envKeys. On Windows, where env var names are
+// case-insensitive, the names are upper-cased so that e.g. Path and PATH hash the same. It errors
+// if a key can't be an env var name.
+func GetHashableEnvPairs(envKeys []string, envPrefixes []string, passThroughKeys []string) ([]string, error) {
+	return getHashableEnvPairs(envKeys, envPrefixes, passThroughKeys, getEnvMap(), runtime.GOOS == "windows")
+}
+
+func getHashableEnvPairs(envKeys []string, envPrefixes []string, passThroughKeys []string, allEnvVars map[string]string, caseInsensitive bool) ([]string, error) {
+	if caseInsensitive {
+		allEnvVars = normalizeEnvMap(allEnvVars)
+		envKeys = normalizeEnvKeys(envKeys)
+		envPrefixes = normalizeEnvKeys(envPrefixes)
+		passThroughKeys = normalizeEnvKeys(passThroughKeys)
+	}
 	excludePrefix := allEnvVars["TURBO_CI_VENDOR_ENV_KEY"]
-	hashableEnvFromKeys := getEnvPairsFromKeys(envKeys, allEnvVars)
-	hashableEnvFromPrefixes := getEnvPairsFromPrefixes(envPrefixes, excludePrefix, allEnvVars)
+	if caseInsensitive {
+		excludePrefix = strings.ToUpper(excludePrefix)
+	}
+	envKeyPatterns, err := compileEnvKeys(envKeys)
+	if err != nil {
+		return nil, err
+	}
+	passThroughPatterns, err := compileEnvKeys(passThroughKeys)
+	if err != nil {
+		return nil, err
+	}
+	hashableEnvFromKeys := getEnvPairsFromKeys(envKeyPatterns, allEnvVars)
+	hashableEnvFromPrefixes := []string{}
+	for _, pair := range getEnvPairsFromPrefixes(envPrefixes, excludePrefix, allEnvVars) {
+		if !matchesAnyEnvKey(passThroughPatterns, strings.SplitN(pair, "=", 2)[0]) {
+			hashableEnvFromPrefixes = append(hashableEnvFromPrefixes, pair)
+		}
+	}
 
 	// convert to set to eliminate duplicates, then cast back to slice to sort for stable hashing
 	uniqueHashableEnvPairs := make(util.Set, len(hashableEnvFromKeys)+len(hashableEnvFromPrefixes))
@@ -72,5 +189,58 @@ func GetHashableEnvPairs(envKeys []string, envPrefixes []string) []string {
 
 	allHashableEnvPairs := uniqueHashableEnvPairs.UnsafeListOfStrings()
 	sort.Strings(allHashableEnvPairs)
-	return allHashableEnvPairs
+	return allHashableEnvPairs, nil
+}
+
+// systemEnvKeys are the env vars that processes commonly need to run at all, so
+// they are kept under StrictEnv
+var systemEnvKeys = []string{
+	"PATH",
+	"HOME",
+	"USER",
+	"SHELL",
+	"TERM",
+	"LANG",
+	"TMPDIR",
+	"TMP",
+	"TEMP",
+	"CI",
+	// Windows
+	"APPDATA",
+	"COMSPEC",
+	"LOCALAPPDATA",
+	"PATHEXT",
+	"SYSTEMROOT",
+	"USERPROFILE",
+	"WINDIR",
+}
+
+// StrictEnv returns the key=value pairs of environ whose keys match allowedKeys, or
+// are one of the system env vars that processes need, such as PATH and HOME. Keys
+// containing a * are wildcards. On Windows, keys are matched case-insensitively. It
+// errors if a key can't be an env var name.
+func StrictEnv(environ []string, allowedKeys []string) ([]string, error) {
+	return strictEnv(environ, allowedKeys, runtime.GOOS == "windows")
+}
+
+func strictEnv(environ []string, allowedKeys []string, caseInsensitive bool) ([]string, error) {
+	keys := append(append([]string{}, systemEnvKeys...), allowedKeys...)
+	if caseInsensitive {
+		keys = normalizeEnvKeys(keys)
+	}
+	patterns, err := compileEnvKeys(keys)
+	if err != nil {
+		return nil, err
+	}
+	allowed := []string{}
+	for _, pair := range environ {
+		key := strings.SplitN(pair, "=", 2)[0]
+		if caseInsensitive {
+			key = strings.ToUpper(key)
+		}
+		if matchesAnyEnvKey(patterns, key) {
+			allowed = append(allowed, pair)
+		}
+	}
+	return allowed, nil
 }
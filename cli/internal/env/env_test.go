@@ -5,6 +5,8 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+
+	"github.com/vercel/turbo/cli/internal/turbopath"
 )
 
 func setEnvs(envVars []string) {
@@ -32,8 +34,9 @@ var _envVarPrefixes = []string{
 
 func TestGetHashableEnvPairs(t *testing.T) {
 	type args struct {
-		envKeys     []string
-		envPrefixes []string
+		envKeys         []string
+		envPrefixes     []string
+		passThroughKeys []string
 	}
 	tests := []struct {
 		env  []string
@@ -275,13 +278,46 @@ func TestGetHashableEnvPairs(t *testing.T) {
 			},
 			want: []string{"MANUAL=true", "NEXT_PUBLIC_VERCEL_ENV=true"},
 		},
+		{
+			env:  []string{"AWS_REGION=us-east-1", "AWS_PROFILE=dev", "MY_AWS_KEY=nope"},
+			name: "wildcard env keys match every env var that is set",
+			args: args{
+				envKeys:     []string{"AWS_*", "MISSING_*"},
+				envPrefixes: []string{},
+			},
+			want: []string{"AWS_PROFILE=dev", "AWS_REGION=us-east-1"},
+		},
+		{
+			env:  []string{"NEXT_PUBLIC_TOKEN=secret", "NEXT_PUBLIC_URL=example.com", "GITHUB_TOKEN=secret"},
+			name: "pass through env vars are left out of framework env vars",
+			args: args{
+				envKeys:         []string{},
+				envPrefixes:     []string{"NEXT_PUBLIC_"},
+				passThroughKeys: []string{"*_TOKEN"},
+			},
+			want: []string{"NEXT_PUBLIC_URL=example.com"},
+		},
+		{
+			env:  []string{"GITHUB_TOKEN=secret"},
+			name: "pass through env vars are hashed if also specified",
+			args: args{
+				envKeys:         []string{"GITHUB_TOKEN"},
+				envPrefixes:     []string{},
+				passThroughKeys: []string{"GITHUB_TOKEN"},
+			},
+			want: []string{"GITHUB_TOKEN=secret"},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			// set the env vars
 			setEnvs(tt.env)
 			// test
-			if got := GetHashableEnvPairs(tt.args.envKeys, tt.args.envPrefixes); !reflect.DeepEqual(got, tt.want) {
+			got, err := GetHashableEnvPairs(tt.args.envKeys, tt.args.envPrefixes, tt.args.passThroughKeys)
+			if err != nil {
+				t.Fatalf("GetHashableEnvPairs() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("GetHashableEnvPairs() = %v, want %v", got, tt.want)
 			}
 			// clean up the env for the next run
@@ -289,3 +325,126 @@ func TestGetHashableEnvPairs(t *testing.T) {
 		})
 	}
 }
+
+func TestGetHashableEnvPairsCaseInsensitive(t *testing.T) {
+	envKeys := []string{"PATH", "AWS_*"}
+	envPrefixes := []string{"NEXT_PUBLIC_"}
+	windows := map[string]string{"Path": "/bin", "aws_region": "us-east-1", "next_public_url": "https://example.com"}
+	linux := map[string]string{"PATH": "/bin", "AWS_REGION": "us-east-1", "NEXT_PUBLIC_URL": "https://example.com"}
+
+	got, _ := getHashableEnvPairs(envKeys, envPrefixes, nil, windows, true)
+	want, _ := getHashableEnvPairs(envKeys, envPrefixes, nil, linux, true)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("getHashableEnvPairs() = %v, want %v", got, want)
+	}
+	if !reflect.DeepEqual(want, []string{"AWS_REGION=us-east-1", "NEXT_PUBLIC_URL=https://example.com", "PATH=/bin"}) {
+		t.Errorf("getHashableEnvPairs() = %v", want)
+	}
+
+	// Without case-insensitivity, Path is a different variable from PATH
+	got, _ = getHashableEnvPairs([]string{"PATH"}, nil, nil, map[string]string{"Path": "/bin"}, false)
+	if !reflect.DeepEqual(got, []string{"PATH="}) {
+		t.Errorf("getHashableEnvPairs() = %v, want [PATH=]", got)
+	}
+}
+
+func TestStrictEnv(t *testing.T) {
+	environ := []string{"PATH=/bin", "HOME=/home/me", "AWS_REGION=us-east-1", "API_TOKEN=secret", "NODE_ENV=production"}
+
+	got, _ := strictEnv(environ, []string{"AWS_*", "NODE_ENV"}, false)
+	want := []string{"PATH=/bin", "HOME=/home/me", "AWS_REGION=us-east-1", "NODE_ENV=production"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("strictEnv() = %v, want %v", got, want)
+	}
+
+	// On Windows, Path is PATH, and keys are declared in any case
+	got, _ = strictEnv([]string{"Path=C:\\Windows", "node_env=production", "API_TOKEN=secret"}, []string{"NODE_ENV"}, true)
+	want = []string{"Path=C:\\Windows", "node_env=production"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("strictEnv() = %v, want %v", got, want)
+	}
+}
+
+func TestEnvKeyPattern(t *testing.T) {
+	tests := []struct {
+		key    string
+		envVar string
+		want   bool
+	}{
+		{key: "AWS_REGION", envVar: "AWS_REGION", want: true},
+		{key: "AWS_REGION", envVar: "AWS_REGION_2", want: false},
+		{key: "AWS_*", envVar: "AWS_REGION", want: true},
+		{key: "AWS_*", envVar: "AWS_", want: true},
+		{key: "AWS_*", envVar: "MY_AWS_REGION", want: false},
+		{key: "*_TOKEN", envVar: "GITHUB_TOKEN", want: true},
+		{key: "*_TOKEN", envVar: "GITHUB_TOKEN_FILE", want: false},
+		{key: "NEXT_PUBLIC_*_URL", envVar: "NEXT_PUBLIC_API_URL", want: true},
+		{key: "NEXT_PUBLIC_*_URL", envVar: "NEXT_PUBLIC_URL", want: false},
+		{key: "A*B*C", envVar: "ABBC", want: true},
+		{key: "A*B*C", envVar: "ACB", want: false},
+		{key: "A.*", envVar: "AB", want: false},
+		{key: "*", envVar: "ANYTHING", want: true},
+	}
+	for _, tt := range tests {
+		patterns, err := compileEnvKeys([]string{tt.key})
+		if err != nil {
+			t.Fatalf("compileEnvKeys(%q) error = %v", tt.key, err)
+		}
+		if got := patterns[0].matches(tt.envVar); got != tt.want {
+			t.Errorf("%q matches %q = %v, want %v", tt.key, tt.envVar, got, tt.want)
+		}
+	}
+
+	for _, key := range []string{"", "A=B", "AWS REGION"} {
+		if _, err := compileEnvKeys([]string{key}); err == nil {
+			t.Errorf("compileEnvKeys(%q) didn't error", key)
+		}
+	}
+	if _, err := GetHashableEnvPairs([]string{"A=B"}, nil, nil); err == nil {
+		t.Errorf("GetHashableEnvPairs() didn't error on a malformed key")
+	}
+	if _, err := StrictEnv(nil, []string{"A=B"}); err == nil {
+		t.Errorf("StrictEnv() didn't error on a malformed key")
+	}
+}
+
+func TestReadDotEnv(t *testing.T) {
+	dir := turbopath.AbsoluteSystemPathFromUpstream(t.TempDir())
+	writeFile := func(name string, contents string) {
+		t.Helper()
+		if err := dir.UntypedJoin(name).WriteFile([]byte(contents), 0644); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+	}
+	writeFile(".env", strings.Join([]string{
+		"# comment",
+		"API_URL=https://example.com",
+		"export NODE_ENV=production",
+		"",
+		`GREETING="hello\nworld"`,
+		"SINGLE='$not expanded'",
+		"DEBUG=false # off by default",
+	}, "\n"))
+	writeFile(".env.local", "API_URL=http://localhost:3000\n")
+
+	// .env.production doesn't exist, and .env.local overrides .env
+	got, err := ReadDotEnv(dir, []string{".env", ".env.production", ".env.local"})
+	if err != nil {
+		t.Fatalf("ReadDotEnv: %v", err)
+	}
+	want := []string{
+		"API_URL=http://localhost:3000",
+		"DEBUG=false",
+		"GREETING=hello\nworld",
+		"NODE_ENV=production",
+		"SINGLE=$not expanded",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadDotEnv() = %q, want %q", got, want)
+	}
+
+	writeFile(".env.broken", "NOT A VAR\n")
+	if _, err := ReadDotEnv(dir, []string{".env.broken"}); err == nil || err.Error() != "invalid env file .env.broken: line 1: expected KEY=value" {
+		t.Errorf("ReadDotEnv() error = %v, want the invalid line reported", err)
+	}
+}
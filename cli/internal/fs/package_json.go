@@ -30,12 +30,22 @@ type PackageJSON struct {
 	// relative path from repo root to the package
 	Dir                    turbopath.AnchoredSystemPath `json:"-"`
 	InternalDeps           []string                     `json:"-"`
+	InternalDepProtocols   map[string]string            `json:"-"`
 	UnresolvedExternalDeps map[string]string            `json:"-"`
 	ExternalDeps           []string                     `json:"-"`
 	TransitiveDeps         []string                     `json:"-"`
 	LegacyTurboConfig      *TurboJSON                   `json:"turbo"`
 	Mu                     sync.Mutex                   `json:"-"`
 	ExternalDepsHash       string                       `json:"-"`
+	// Tags are the labels from turbo.tags that --filter=tag:<name> selects by
+	Tags []string `json:"-"`
+}
+
+// packageTurboConfig is the workspace configuration in the "turbo" key of package.json
+type packageTurboConfig struct {
+	Turbo struct {
+		Tags []string `json:"tags"`
+	} `json:"turbo"`
 }
 
 type Workspaces []string
@@ -80,6 +90,12 @@ func UnmarshalPackageJSON(data []byte) (*PackageJSON, error) {
 	}
 	pkgJSON.RawJSON = rawJSON
 
+	turboConfig := &packageTurboConfig{}
+	if err := json.Unmarshal(data, turboConfig); err != nil {
+		return nil, err
+	}
+	pkgJSON.Tags = turboConfig.Turbo.Tags
+
 	return pkgJSON, nil
 }
 
@@ -48,6 +48,20 @@ func Test_UnmarshalPackageJSON(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "tags are read from the turbo key",
+			json: `{"name":"web","turbo":{"tags":["app", "public"]}}`,
+			expectedFields: &PackageJSON{
+				Name: "web",
+				Tags: []string{"app", "public"},
+				RawJSON: map[string]interface{}{
+					"name": "web",
+					"turbo": map[string]interface{}{
+						"tags": []interface{}{"app", "public"},
+					},
+				},
+			},
+		},
 	}
 
 	for _, testCase := range testCases {
@@ -171,4 +185,5 @@ func assertPackageJSONEqual(t *testing.T, x *PackageJSON, y *PackageJSON) {
 	assert.DeepEqual(t, x.Workspaces, y.Workspaces)
 	assert.DeepEqual(t, x.Private, y.Private)
 	assert.DeepEqual(t, x.RawJSON, y.RawJSON)
+	assert.DeepEqual(t, x.Tags, y.Tags)
 }
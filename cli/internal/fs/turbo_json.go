@@ -6,8 +6,11 @@ import (
 	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
+	"regexp"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/vercel/turbo/cli/internal/turbopath"
@@ -56,6 +59,95 @@ type rawTask struct {
 	Inputs     []string            `json:"inputs,omitempty"`
 	OutputMode util.TaskOutputMode `json:"outputMode,omitempty"`
 	Env        []string            `json:"env,omitempty"`
+	Shell      string              `json:"shell,omitempty"`
+	// InputsFromDependencyOutputs includes the outputs of the task's dependencies in its inputs
+	InputsFromDependencyOutputs bool `json:"inputsFromDependencyOutputs,omitempty"`
+	// DependencyQuorum is the minimum number of the task's dependencies that must succeed
+	DependencyQuorum int  `json:"dependencyQuorum,omitempty"`
+	Persistent       bool `json:"persistent,omitempty"`
+	// StartsAfter are persistent tasks that must be ready before the task is started
+	StartsAfter []string `json:"startsAfter,omitempty"`
+	// ReadinessPattern is a regular expression matched against a persistent task's output
+	// to determine when it is ready
+	ReadinessPattern  string `json:"readinessPattern,omitempty"`
+	StreamingOutputs  bool   `json:"streamingOutputs,omitempty"`
+	ConsumesStreaming bool   `json:"consumesStreaming,omitempty"`
+	// ReadinessProbe is how to check that a persistent task is ready
+	ReadinessProbe *rawReadinessProbe `json:"readinessProbe,omitempty"`
+	// CacheTTL is how long cached outputs are valid for, as a Go duration (e.g. "24h")
+	CacheTTL string `json:"cacheTTL,omitempty"`
+	// Tags are arbitrary labels that other tasks can depend on as a group
+	Tags []string `json:"tags,omitempty"`
+	// DependsOnTag are tags whose tasks must all succeed before the task is run
+	DependsOnTag []string `json:"dependsOnTag,omitempty"`
+	// OutputChecksums is a manifest of the expected checksums of the task's outputs
+	OutputChecksums string `json:"outputChecksums,omitempty"`
+	// SkipIfOutputNewerThan is an output that, if newer than all of the task's inputs,
+	// means the task doesn't need to run
+	SkipIfOutputNewerThan string `json:"skipIfOutputNewerThan,omitempty"`
+	// GitEnv are facts about the current commit to inject into the task's environment
+	GitEnv []string `json:"gitEnv,omitempty"`
+	// HashGitEnv includes the injected git facts in the task's hash
+	HashGitEnv bool `json:"hashGitEnv,omitempty"`
+	// RerunOnDepExecution runs the task, regardless of its cache, whenever one of
+	// its dependencies was executed
+	RerunOnDepExecution bool `json:"rerunOnDepExecution,omitempty"`
+	// OutputExclude are globs of outputs that are neither cached nor restored
+	OutputExclude []string `json:"outputExclude,omitempty"`
+	// RunWhen is the caching configuration the task runs under
+	RunWhen string `json:"runWhen,omitempty"`
+	// Foreground tasks own the terminal while they run
+	Foreground bool `json:"foreground,omitempty"`
+	// MinTurboVersion is the oldest version of turbo that can run the task
+	MinTurboVersion string `json:"minTurboVersion,omitempty"`
+	// FollowSymlinks matches the task's inputs inside of symlinked directories
+	FollowSymlinks bool `json:"followSymlinks,omitempty"`
+	// Port is the port a persistent task listens on
+	Port int `json:"port,omitempty"`
+	// Weight is the number of concurrency slots the task takes up
+	Weight int `json:"weight,omitempty"`
+	// AllowDependents lets other tasks depend on a persistent task
+	AllowDependents bool `json:"allowDependents,omitempty"`
+	// Timeout is how long the task can run for, as a Go duration (e.g. "5m")
+	Timeout string `json:"timeout,omitempty"`
+	// Retry is how many times the task is run again after failing
+	Retry int `json:"retry,omitempty"`
+	// PassThroughEnv are env vars the task can read that don't affect its hash
+	PassThroughEnv []string `json:"passThroughEnv,omitempty"`
+	// DotEnv are env files whose vars are loaded into the task's environment and hash
+	DotEnv []string `json:"dotEnv,omitempty"`
+	// OutputLogs is the task's output mode, which takes precedence over --output-logs
+	OutputLogs *util.TaskOutputMode `json:"outputLogs,omitempty"`
+	// Hook is "pre" or "post" for a root task that runs before or after every other task
+	Hook string `json:"hook,omitempty"`
+}
+
+// rawReadinessProbe is how to check that a persistent task is ready, as it is
+// configured in turbo.json
+type rawReadinessProbe struct {
+	// Pattern is the same as readinessPattern
+	Pattern  string `json:"pattern,omitempty"`
+	Command  string `json:"command,omitempty"`
+	Port     int    `json:"port,omitempty"`
+	URL      string `json:"url,omitempty"`
+	Interval string `json:"interval,omitempty"`
+	Timeout  string `json:"timeout,omitempty"`
+}
+
+// ReadinessProbe is polled once a persistent task starts, and the task is ready
+// once the probe passes. Exactly one of Command, Port and URL is set.
+type ReadinessProbe struct {
+	// Command is a shell command that exits successfully once the task is ready
+	Command string
+	// Port is a local TCP port that accepts connections once the task is ready
+	Port int
+	// URL is an HTTP URL that responds with a status below 400 once the task is ready
+	URL string
+	// Interval is how long to wait between polls. If zero, the default is used.
+	Interval time.Duration
+	// Timeout is how long the task has to pass the probe before it is stopped,
+	// and fails. If zero, the default is used.
+	Timeout time.Duration
 }
 
 // Pipeline is a struct for deserializing .pipeline in configFile
@@ -70,6 +162,111 @@ type TaskDefinition struct {
 	TaskDependencies        []string
 	Inputs                  []string
 	OutputMode              util.TaskOutputMode
+	Shell                   string
+	// InputsFromDepOutputs includes the outputs of the task's dependencies in its inputs
+	InputsFromDepOutputs bool
+	// DepQuorum is the minimum number of the task's dependencies that must succeed.
+	// If zero, all of them must succeed.
+	DepQuorum int
+	// Persistent tasks are long-running processes that other tasks cannot depend on
+	Persistent bool
+	// StartsAfter are persistent tasks that must be ready before this persistent task is started
+	StartsAfter []string
+	// ReadinessPattern is a regular expression that a line of a persistent task's output
+	// matches once the task is ready. If empty, the task is ready once it is started.
+	ReadinessPattern string
+	// ReadinessProbe, if set on a persistent task, is polled once it starts, and
+	// the task is ready once the probe passes. If it doesn't pass in time, the
+	// task is stopped. A probe that matches a pattern sets ReadinessPattern instead.
+	ReadinessProbe *ReadinessProbe
+	// StreamingOutputs tasks signal when enough of their outputs are ready for
+	// dependents that consume streaming outputs to start
+	StreamingOutputs bool
+	// ConsumesStreaming tasks start once their dependencies with streaming outputs
+	// are ready, rather than when they finish
+	ConsumesStreaming bool
+	// CacheTTL is how long cached outputs are valid for. Older cached outputs are
+	// treated as a cache miss. If zero, cached outputs never expire.
+	CacheTTL time.Duration
+	// Tags are labels that group tasks together, so they can be depended on with DependsOnTag
+	Tags []string
+	// DependsOnTag are tags whose tasks must all succeed before this task is run
+	DependsOnTag []string
+	// OutputChecksums is the package-relative path to a manifest, in the format of
+	// sha256sum, of the expected checksums of the task's outputs. If set, the task
+	// fails when its outputs don't match.
+	OutputChecksums string
+	// SkipIfOutputNewerThan is the package-relative path to the task's primary output.
+	// If set, the task is skipped when that file was modified more recently than all
+	// of its inputs.
+	SkipIfOutputNewerThan string
+	// GitEnv are facts about the current commit, from GitEnvVars, that are injected
+	// into the task's environment
+	GitEnv []string
+	// HashGitEnv includes the values of the GitEnv vars in the task's hash. Since
+	// they change with every commit, they are excluded by default.
+	HashGitEnv bool
+	// RerunOnDepExecution bypasses the task's cache whenever one of its direct
+	// dependencies was executed, rather than restored from the cache, in the same
+	// run, even if the dependency's outputs didn't change
+	RerunOnDepExecution bool
+	// RunWhen is "cache-enabled" or "cache-disabled" if the task is only included
+	// in runs that do, or don't, use a cache. If empty or "always", it is always included.
+	RunWhen string
+	// Foreground tasks own the terminal while they run, and can read from stdin.
+	// The output of other tasks is held back until they finish.
+	Foreground bool
+	// MinTurboVersion is the oldest version of turbo that can run the task, as a
+	// semver version (e.g. "1.8.0"). Running the task with an older turbo is an error.
+	MinTurboVersion string
+	// FollowSymlinks matches the task's input globs against the files inside of
+	// symlinked directories, rather than the symlinks themselves. It is part of
+	// the task's hash, since it changes which files are inputs.
+	FollowSymlinks bool
+	// Port is the port a persistent task listens on. No two persistent tasks in a
+	// run can declare the same port. Zero means the port isn't declared, or is
+	// picked dynamically.
+	Port int
+	// Weight is the number of concurrency slots the task takes up while it runs,
+	// out of the run's --concurrency. Zero means the default of 1.
+	Weight int
+	// AllowDependents lets other tasks depend on this persistent task. They start
+	// once it is ready, rather than waiting for it to exit.
+	AllowDependents bool
+	// Timeout is how long the task can run for before it is stopped, and fails.
+	// If zero, it can run forever.
+	Timeout time.Duration
+	// Retries is how many more times the task is run after failing, before it is
+	// considered failed. Its outputs are only cached if an attempt succeeds.
+	// Persistent tasks are never retried.
+	Retries int
+	// PassThroughEnv are env vars the task's process can read, but whose values
+	// aren't part of its hash, unless they are also listed in "env". A * matches
+	// any part of a name (e.g. AWS_*).
+	PassThroughEnv []string
+	// DotEnv are package-relative env files, such as .env and .env.local, whose
+	// contents are part of the task's hash and whose vars are loaded into its
+	// process's environment. Files that don't exist are skipped, and a var set by
+	// more than one file takes its value from the last.
+	DotEnv []string
+	// OutputLogs, if set, is how the task's output is displayed, regardless of
+	// --output-logs. Unlike OutputMode, it can't be overridden for a single run.
+	OutputLogs *util.TaskOutputMode
+	// OutputsDeclared is true if the task's outputs were set in turbo.json, rather
+	// than being the defaults
+	OutputsDeclared bool
+	// Hook is "pre" for a root task (e.g. //#predeploy) that every other task in a
+	// run depends on, or "post" for one that depends on every other task. Hooks
+	// are only run if their script is defined in the root package.json.
+	Hook string
+}
+
+// GitEnvVars maps each fact about the current commit that a task can request
+// with gitEnv to the env var it is injected as
+var GitEnvVars = map[string]string{
+	"sha":    "TURBO_GIT_SHA",
+	"branch": "TURBO_GIT_BRANCH",
+	"tag":    "TURBO_GIT_TAG",
 }
 
 // LoadTurboConfig loads, or optionally, synthesizes a TurboJSON instance
@@ -119,6 +316,11 @@ func LoadTurboConfig(rootPath turbopath.AbsoluteSystemPath, rootPackageJSON *Pac
 type TaskOutputs struct {
 	Inclusions []string
 	Exclusions []string
+	// Ordered holds the globs in the order they apply, with negated globs prefixed
+	// with !, so that of the globs that match a file, the last one decides whether
+	// it is an output. It is only set if there are exclusions, and if empty, every
+	// exclusion applies to every inclusion.
+	Ordered []string
 }
 
 // Sort contents of task outputs
@@ -132,6 +334,23 @@ func (to TaskOutputs) Sort() TaskOutputs {
 	return TaskOutputs{Inclusions: inclusions, Exclusions: exclusions}
 }
 
+// rootOutputPrefix marks an output glob that is relative to the root of the
+// monorepo, rather than to the task's workspace, e.g. "$ROOT$/generated/**". A
+// leading "/" does the same.
+const rootOutputPrefix = "$ROOT$/"
+
+// RepoRelativeOutput returns the output glob of a task in the workspace at pkgDir
+// relative to the root of the monorepo
+func RepoRelativeOutput(pkgDir turbopath.AnchoredSystemPath, glob string) string {
+	if strings.HasPrefix(glob, rootOutputPrefix) {
+		return filepath.Join(strings.TrimPrefix(glob, rootOutputPrefix))
+	}
+	if strings.HasPrefix(glob, "/") {
+		return filepath.Join(strings.TrimPrefix(glob, "/"))
+	}
+	return filepath.Join(pkgDir.ToStringDuringMigration(), glob)
+}
+
 // ReadTurboConfig toggles between reading from package.json or the configFile to support early adopters.
 func ReadTurboConfig(rootPath turbopath.AbsoluteSystemPath, rootPackageJSON *PackageJSON) (*TurboJSON, error) {
 
@@ -216,6 +435,23 @@ func (pc Pipeline) HasTask(task string) bool {
 	return false
 }
 
+// TaskNames returns the names of the tasks defined in the pipeline, either directly
+// or via a package task (`pkg#task`), sorted
+func (pc Pipeline) TaskNames() []string {
+	names := make(util.Set)
+	for key := range pc {
+		if util.IsPackageTask(key) {
+			_, taskName := util.GetPackageTaskFromId(key)
+			names.Add(taskName)
+		} else {
+			names.Add(key)
+		}
+	}
+	taskNames := names.UnsafeListOfStrings()
+	sort.Strings(taskNames)
+	return taskNames
+}
+
 // UnmarshalJSON deserializes JSON into a TaskDefinition
 func (c *TaskDefinition) UnmarshalJSON(data []byte) error {
 	task := rawTask{}
@@ -237,14 +473,47 @@ func (c *TaskDefinition) UnmarshalJSON(data []byte) error {
 				inclusions = append(inclusions, glob)
 			}
 		}
+		// Negated globs only remove files from the ones matched by the globs before
+		// them, and later globs can add the files back
+		if len(inclusions) == 0 && len(exclusions) > 0 {
+			return fmt.Errorf("\"outputs\" only contains negated globs, so it matches no files. Add the globs to cache alongside the ones to exclude, e.g. [\"dist/**\", \"!dist/**/*.map\"]")
+		}
 
 		c.Outputs = TaskOutputs{
 			Inclusions: inclusions,
 			Exclusions: exclusions,
 		}
+		// Without negated globs, the order doesn't matter
+		if len(exclusions) > 0 {
+			c.Outputs.Ordered = append([]string{}, *task.Outputs...)
+		}
+		c.OutputsDeclared = true
 	} else {
 		c.Outputs = defaultOutputs
 	}
+	if len(task.OutputExclude) > 0 {
+		// Copy, rather than append to, the exclusions in case they are the defaults
+		exclusions := make([]string, 0, len(c.Outputs.Exclusions)+len(task.OutputExclude))
+		exclusions = append(exclusions, c.Outputs.Exclusions...)
+		for _, glob := range task.OutputExclude {
+			if strings.HasPrefix(glob, "!") {
+				return fmt.Errorf("You specified \"%s\" in the \"outputExclude\" key. Globs in \"outputExclude\" are already excluded, so they should not be prefixed with \"!\"", glob)
+			}
+			exclusions = append(exclusions, glob)
+		}
+		c.Outputs.Exclusions = exclusions
+		// Excluded outputs win over every glob in outputs, so they apply last
+		ordered := make([]string, 0, len(c.Outputs.Inclusions)+len(exclusions))
+		if c.Outputs.Ordered != nil {
+			ordered = append(ordered, c.Outputs.Ordered...)
+		} else {
+			ordered = append(ordered, c.Outputs.Inclusions...)
+		}
+		for _, glob := range task.OutputExclude {
+			ordered = append(ordered, "!"+glob)
+		}
+		c.Outputs.Ordered = ordered
+	}
 	sort.Strings(c.Outputs.Inclusions)
 	sort.Strings(c.Outputs.Exclusions)
 	if task.Cache == nil {
@@ -287,9 +556,170 @@ func (c *TaskDefinition) UnmarshalJSON(data []byte) error {
 	// hash the resulting files and sort that instead
 	c.Inputs = task.Inputs
 	c.OutputMode = task.OutputMode
+	c.Shell = task.Shell
+	c.InputsFromDepOutputs = task.InputsFromDependencyOutputs
+	c.DepQuorum = task.DependencyQuorum
+	c.Persistent = task.Persistent
+	c.StartsAfter = task.StartsAfter
+	if task.ReadinessPattern != "" {
+		if _, err := regexp.Compile(task.ReadinessPattern); err != nil {
+			return fmt.Errorf("invalid readinessPattern %q: %w", task.ReadinessPattern, err)
+		}
+	}
+	c.ReadinessPattern = task.ReadinessPattern
+	if task.ReadinessProbe != nil {
+		if !task.Persistent {
+			return fmt.Errorf("readinessProbe can only be set on persistent tasks")
+		}
+		probe, pattern, err := parseReadinessProbe(task.ReadinessProbe)
+		if err != nil {
+			return err
+		}
+		if pattern != "" {
+			if task.ReadinessPattern != "" {
+				return fmt.Errorf("readinessPattern and the pattern of readinessProbe can't both be set")
+			}
+			c.ReadinessPattern = pattern
+		} else if task.ReadinessPattern != "" {
+			return fmt.Errorf("readinessPattern can't be set with a readinessProbe that doesn't match a pattern")
+		}
+		c.ReadinessProbe = probe
+	}
+	c.StreamingOutputs = task.StreamingOutputs
+	c.ConsumesStreaming = task.ConsumesStreaming
+	if task.CacheTTL != "" {
+		ttl, err := time.ParseDuration(task.CacheTTL)
+		if err != nil {
+			return fmt.Errorf("invalid cacheTTL %q: %w", task.CacheTTL, err)
+		}
+		if ttl <= 0 {
+			return fmt.Errorf("invalid cacheTTL %q: must be positive", task.CacheTTL)
+		}
+		c.CacheTTL = ttl
+	}
+	c.Tags = task.Tags
+	c.DependsOnTag = task.DependsOnTag
+	c.OutputChecksums = task.OutputChecksums
+	c.SkipIfOutputNewerThan = task.SkipIfOutputNewerThan
+	for _, fact := range task.GitEnv {
+		if _, ok := GitEnvVars[fact]; !ok {
+			return fmt.Errorf("invalid gitEnv value %q: must be one of \"sha\", \"branch\" or \"tag\"", fact)
+		}
+	}
+	c.GitEnv = task.GitEnv
+	sort.Strings(c.GitEnv)
+	c.HashGitEnv = task.HashGitEnv
+	c.RerunOnDepExecution = task.RerunOnDepExecution
+	switch task.RunWhen {
+	case "", "always", "cache-enabled", "cache-disabled":
+	default:
+		return fmt.Errorf("invalid runWhen value %q: must be one of \"always\", \"cache-enabled\" or \"cache-disabled\"", task.RunWhen)
+	}
+	c.RunWhen = task.RunWhen
+	c.Foreground = task.Foreground
+	c.MinTurboVersion = task.MinTurboVersion
+	c.FollowSymlinks = task.FollowSymlinks
+	if task.Port < 0 || task.Port > 65535 {
+		return fmt.Errorf("invalid port %v: must be between 0 and 65535", task.Port)
+	}
+	c.Port = task.Port
+	if task.Weight < 0 {
+		return fmt.Errorf("invalid weight %v: must be at least 1", task.Weight)
+	}
+	c.Weight = task.Weight
+	if task.AllowDependents && !task.Persistent {
+		return fmt.Errorf("allowDependents can only be set on persistent tasks")
+	}
+	c.AllowDependents = task.AllowDependents
+	if task.Timeout != "" {
+		timeout, err := time.ParseDuration(task.Timeout)
+		if err != nil {
+			return fmt.Errorf("invalid timeout %q: %w", task.Timeout, err)
+		}
+		if timeout <= 0 {
+			return fmt.Errorf("invalid timeout %q: must be positive", task.Timeout)
+		}
+		c.Timeout = timeout
+	}
+	if task.Retry < 0 {
+		return fmt.Errorf("invalid retry %v: must not be negative", task.Retry)
+	}
+	c.Retries = task.Retry
+	for _, value := range task.PassThroughEnv {
+		if strings.HasPrefix(value, envPipelineDelimiter) {
+			return fmt.Errorf("You specified \"%s\" in the \"passThroughEnv\" key. You should not prefix your environment variables with \"$\"", value)
+		}
+	}
+	c.PassThroughEnv = task.PassThroughEnv
+	sort.Strings(c.PassThroughEnv)
+	for _, file := range task.DotEnv {
+		if filepath.IsAbs(file) {
+			return fmt.Errorf("invalid dotEnv file %q: must be relative to the package", file)
+		}
+	}
+	c.DotEnv = task.DotEnv
+	c.OutputLogs = task.OutputLogs
+	switch task.Hook {
+	case "", "pre", "post":
+	default:
+		return fmt.Errorf("invalid hook value %q: must be \"pre\" or \"post\"", task.Hook)
+	}
+	c.Hook = task.Hook
 	return nil
 }
 
+// parseReadinessProbe returns the readiness probe configured in turbo.json, or
+// the pattern it matches output against, if that is how it checks readiness
+func parseReadinessProbe(raw *rawReadinessProbe) (*ReadinessProbe, string, error) {
+	set := 0
+	for _, isSet := range []bool{raw.Pattern != "", raw.Command != "", raw.Port != 0, raw.URL != ""} {
+		if isSet {
+			set++
+		}
+	}
+	if set != 1 {
+		return nil, "", fmt.Errorf("readinessProbe needs exactly one of pattern, command, port or url")
+	}
+	if raw.Pattern != "" {
+		if raw.Interval != "" || raw.Timeout != "" {
+			return nil, "", fmt.Errorf("readinessProbe can't have an interval or timeout with a pattern")
+		}
+		if _, err := regexp.Compile(raw.Pattern); err != nil {
+			return nil, "", fmt.Errorf("invalid readinessProbe pattern %q: %w", raw.Pattern, err)
+		}
+		return nil, raw.Pattern, nil
+	}
+	if raw.Port < 0 || raw.Port > 65535 {
+		return nil, "", fmt.Errorf("invalid readinessProbe port %v: must be between 1 and 65535", raw.Port)
+	}
+	probe := &ReadinessProbe{
+		Command: raw.Command,
+		Port:    raw.Port,
+		URL:     raw.URL,
+	}
+	for _, duration := range []struct {
+		name  string
+		value string
+		field *time.Duration
+	}{
+		{"interval", raw.Interval, &probe.Interval},
+		{"timeout", raw.Timeout, &probe.Timeout},
+	} {
+		if duration.value == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(duration.value)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid readinessProbe %v %q: %w", duration.name, duration.value, err)
+		}
+		if parsed <= 0 {
+			return nil, "", fmt.Errorf("invalid readinessProbe %v %q: must be positive", duration.name, duration.value)
+		}
+		*duration.field = parsed
+	}
+	return probe, "", nil
+}
+
 // UnmarshalJSON deserializes TurboJSON objects into struct
 func (c *TurboJSON) UnmarshalJSON(data []byte) error {
 	raw := &rawTurboJSON{}
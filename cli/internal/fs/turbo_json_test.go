@@ -1,11 +1,13 @@
 package fs
 
 import (
+	"encoding/json"
 	"os"
 	"reflect"
 	"sort"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/vercel/turbo/cli/internal/turbopath"
@@ -43,22 +45,30 @@ func Test_ReadTurboConfig(t *testing.T) {
 		t.Fatalf("invalid parse: %#v", turboJSONReadErr)
 	}
 
+	errorsOnly := util.ErrorTaskOutput
 	pipelineExpected := map[string]TaskDefinition{
 		"build": {
-			Outputs:                 TaskOutputs{Inclusions: []string{".next/**", "dist/**"}, Exclusions: []string{"dist/assets/**"}},
+			Outputs:                 TaskOutputs{Inclusions: []string{".next/**", "dist/**"}, Exclusions: []string{"dist/assets/**"}, Ordered: []string{"dist/**", "!dist/assets/**", ".next/**"}},
+			OutputsDeclared:         true,
 			TopologicalDependencies: []string{"build"},
 			EnvVarDependencies:      []string{},
 			TaskDependencies:        []string{},
 			ShouldCache:             true,
 			OutputMode:              util.NewTaskOutput,
+			StreamingOutputs:        true,
+			SkipIfOutputNewerThan:   "dist/index.js",
 		},
 		"lint": {
 			Outputs:                 TaskOutputs{},
+			OutputsDeclared:         true,
 			TopologicalDependencies: []string{},
 			EnvVarDependencies:      []string{"MY_VAR"},
+			CacheTTL:                24 * time.Hour,
+			Tags:                    []string{"ci-critical"},
 			TaskDependencies:        []string{},
 			ShouldCache:             true,
 			OutputMode:              util.NewTaskOutput,
+			OutputLogs:              &errorsOnly,
 		},
 		"dev": {
 			Outputs:                 defaultOutputs,
@@ -67,15 +77,38 @@ func Test_ReadTurboConfig(t *testing.T) {
 			TaskDependencies:        []string{},
 			ShouldCache:             false,
 			OutputMode:              util.FullTaskOutput,
+			Shell:                   "bash",
+			Persistent:              true,
+			StartsAfter:             []string{"api#dev"},
+			ReadinessPattern:        `ready on port \d+`,
+			AllowDependents:         true,
 		},
 		"publish": {
 			Outputs:                 TaskOutputs{Inclusions: []string{"dist/**"}},
+			OutputsDeclared:         true,
 			TopologicalDependencies: []string{"build", "publish"},
 			EnvVarDependencies:      []string{},
 			TaskDependencies:        []string{"admin#lint", "build"},
 			ShouldCache:             false,
 			Inputs:                  []string{"build/**/*"},
 			OutputMode:              util.FullTaskOutput,
+			InputsFromDepOutputs:    true,
+			DepQuorum:               2,
+			ConsumesStreaming:       true,
+			DependsOnTag:            []string{"ci-critical"},
+			OutputChecksums:         "dist.sha256",
+			GitEnv:                  []string{"sha", "tag"},
+			RerunOnDepExecution:     true,
+			RunWhen:                 "cache-disabled",
+			Foreground:              true,
+			MinTurboVersion:         "1.8.0",
+			FollowSymlinks:          true,
+			Port:                    3000,
+			Weight:                  2,
+			Timeout:                 5 * time.Minute,
+			Retries:                 2,
+			PassThroughEnv:          []string{"AWS_*", "GITHUB_TOKEN"},
+			DotEnv:                  []string{".env", ".env.local"},
 		},
 	}
 
@@ -134,7 +167,8 @@ func Test_ReadTurboConfig_BothCorrectAndLegacy(t *testing.T) {
 
 	pipelineExpected := map[string]TaskDefinition{
 		"build": {
-			Outputs:                 TaskOutputs{Inclusions: []string{".next/**", "dist/**"}, Exclusions: []string{"dist/assets/**"}},
+			Outputs:                 TaskOutputs{Inclusions: []string{".next/**", "dist/**"}, Exclusions: []string{"dist/assets/**"}, Ordered: []string{"dist/**", ".next/**", "!dist/assets/**"}},
+			OutputsDeclared:         true,
 			TopologicalDependencies: []string{"build"},
 			EnvVarDependencies:      []string{},
 			TaskDependencies:        []string{},
@@ -245,6 +279,58 @@ func Test_TaskOutputsSort(t *testing.T) {
 	assert.False(t, cmp.DeepEqual(taskOutputs, sortedOutputs)().Success())
 }
 
+func Test_TaskDefinition_OnlyNegatedOutputs(t *testing.T) {
+	taskDefinition := TaskDefinition{}
+	err := json.Unmarshal([]byte(`{"outputs": ["!dist/**/*.map"]}`), &taskDefinition)
+	assert.EqualError(t, err, "\"outputs\" only contains negated globs, so it matches no files. Add the globs to cache alongside the ones to exclude, e.g. [\"dist/**\", \"!dist/**/*.map\"]")
+
+	err = json.Unmarshal([]byte(`{"outputs": ["!dist/**/*.map", "dist/**"]}`), &taskDefinition)
+	assert.NoError(t, err)
+	assert.Equal(t, TaskOutputs{Inclusions: []string{"dist/**"}, Exclusions: []string{"dist/**/*.map"}, Ordered: []string{"!dist/**/*.map", "dist/**"}}, taskDefinition.Outputs)
+}
+
+func Test_TaskDefinition_OutputExclude(t *testing.T) {
+	taskDefinition := TaskDefinition{}
+	err := json.Unmarshal([]byte(`{"outputs": ["dist/**"], "outputExclude": ["!dist/**/*.map"]}`), &taskDefinition)
+	assert.EqualError(t, err, "You specified \"!dist/**/*.map\" in the \"outputExclude\" key. Globs in \"outputExclude\" are already excluded, so they should not be prefixed with \"!\"")
+
+	taskDefinition = TaskDefinition{}
+	err = json.Unmarshal([]byte(`{"outputs": ["dist/**"], "outputExclude": ["dist/**/*.map"]}`), &taskDefinition)
+	assert.NoError(t, err)
+	assert.Equal(t, TaskOutputs{Inclusions: []string{"dist/**"}, Exclusions: []string{"dist/**/*.map"}, Ordered: []string{"dist/**", "!dist/**/*.map"}}, taskDefinition.Outputs)
+}
+
+func Test_TaskDefinition_ReadinessProbe(t *testing.T) {
+	taskDefinition := TaskDefinition{}
+	err := json.Unmarshal([]byte(`{"persistent": true, "readinessProbe": {"url": "http://localhost:3000/health", "interval": "1s", "timeout": "2m"}}`), &taskDefinition)
+	assert.NoError(t, err)
+	assert.Equal(t, &ReadinessProbe{URL: "http://localhost:3000/health", Interval: time.Second, Timeout: 2 * time.Minute}, taskDefinition.ReadinessProbe)
+
+	// A pattern is the same as readinessPattern
+	taskDefinition = TaskDefinition{}
+	err = json.Unmarshal([]byte(`{"persistent": true, "readinessProbe": {"pattern": "ready on port \\d+"}}`), &taskDefinition)
+	assert.NoError(t, err)
+	assert.Nil(t, taskDefinition.ReadinessProbe)
+	assert.Equal(t, `ready on port \d+`, taskDefinition.ReadinessPattern)
+
+	for _, tc := range []struct {
+		config string
+		err    string
+	}{
+		{`{"readinessProbe": {"port": 3000}}`, "readinessProbe can only be set on persistent tasks"},
+		{`{"persistent": true, "readinessProbe": {"port": 3000, "url": "http://localhost:3000"}}`, "readinessProbe needs exactly one of pattern, command, port or url"},
+		{`{"persistent": true, "readinessProbe": {}}`, "readinessProbe needs exactly one of pattern, command, port or url"},
+		{`{"persistent": true, "readinessProbe": {"port": 70000}}`, "invalid readinessProbe port 70000: must be between 1 and 65535"},
+		{`{"persistent": true, "readinessProbe": {"command": "curl -f localhost:3000", "timeout": "soon"}}`, "invalid readinessProbe timeout \"soon\": time: invalid duration \"soon\""},
+		{`{"persistent": true, "readinessProbe": {"pattern": "ready", "timeout": "1m"}}`, "readinessProbe can't have an interval or timeout with a pattern"},
+		{`{"persistent": true, "readinessPattern": "ready", "readinessProbe": {"port": 3000}}`, "readinessPattern can't be set with a readinessProbe that doesn't match a pattern"},
+	} {
+		taskDefinition = TaskDefinition{}
+		err = json.Unmarshal([]byte(tc.config), &taskDefinition)
+		assert.EqualError(t, err, tc.err, tc.config)
+	}
+}
+
 // Helpers
 func validateOutput(t *testing.T, turboJSON *TurboJSON, expectedPipeline map[string]TaskDefinition) {
 	t.Helper()
@@ -0,0 +1,144 @@
+package fs
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/util"
+	"muzzammil.xyz/jsonc"
+)
+
+const (
+	// rootConfigExtends is the "extends" entry of a workspace's turbo.json that
+	// extends the root turbo.json
+	rootConfigExtends = "//"
+	// extendsToken in an array of a workspace's task definition appends the
+	// array to the root's, rather than replacing it
+	extendsToken = "$TURBO_EXTENDS$"
+)
+
+// rawPipelineConfig is a turbo.json with each task definition left unparsed, key by key
+type rawPipelineConfig struct {
+	Extends  []string                              `json:"extends,omitempty"`
+	Pipeline map[string]map[string]json.RawMessage `json:"pipeline"`
+}
+
+// readRawPipelineConfig reads the turbo.json at path, returning false if it doesn't exist
+func readRawPipelineConfig(path turbopath.AbsoluteSystemPath) (*rawPipelineConfig, bool, error) {
+	if !path.FileExists() {
+		return nil, false, nil
+	}
+	data, err := path.ReadFile()
+	if err != nil {
+		return nil, false, err
+	}
+	config := &rawPipelineConfig{}
+	if err := jsonc.Unmarshal(data, config); err != nil {
+		return nil, false, fmt.Errorf("%v: %w", path, err)
+	}
+	return config, true, nil
+}
+
+// MergeWorkspaceConfigs returns a copy of the pipeline, with the task definitions
+// in each workspace's own turbo.json merged over the root turbo.json's as
+// workspace tasks (e.g. web#build). A workspace's turbo.json must extend the root
+// with `"extends": ["//"]`. Each key of a workspace's task definition replaces the
+// root's, including arrays, unless the array contains "$TURBO_EXTENDS$", which
+// appends it to the root's array instead.
+func (pc Pipeline) MergeWorkspaceConfigs(rootPath turbopath.AbsoluteSystemPath, packageInfos map[interface{}]*PackageJSON) (Pipeline, error) {
+	pkgs := []string{}
+	for name := range packageInfos {
+		if pkg := fmt.Sprintf("%v", name); pkg != util.RootPkgName {
+			pkgs = append(pkgs, pkg)
+		}
+	}
+	sort.Strings(pkgs)
+
+	merged := make(Pipeline, len(pc))
+	for taskID, taskDefinition := range pc {
+		merged[taskID] = taskDefinition
+	}
+	var root *rawPipelineConfig
+	for _, pkg := range pkgs {
+		configPath := rootPath.UntypedJoin(packageInfos[pkg].Dir.ToStringDuringMigration(), configFile)
+		config, ok, err := readRawPipelineConfig(configPath)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		if len(config.Extends) != 1 || config.Extends[0] != rootConfigExtends {
+			return nil, fmt.Errorf("%v: workspace %v must extend the root %v with \"extends\": [\"%v\"]", configPath, pkg, configFile, rootConfigExtends)
+		}
+		if root == nil {
+			rootConfig, ok, err := readRawPipelineConfig(rootPath.UntypedJoin(configFile))
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				return nil, fmt.Errorf("%v: workspace %v extends the root %v, which doesn't exist", configPath, pkg, configFile)
+			}
+			root = rootConfig
+		}
+		for taskName, workspaceTask := range config.Pipeline {
+			if util.IsPackageTask(taskName) {
+				return nil, fmt.Errorf("%v: workspace tasks (<package>#<task>) are not allowed in a workspace's %v: found %v", configPath, configFile, taskName)
+			}
+			taskID := util.GetTaskId(pkg, taskName)
+			// A workspace task in the root is more specific than a task for every workspace
+			rootTask, ok := root.Pipeline[taskID]
+			if !ok {
+				rootTask = root.Pipeline[taskName]
+			}
+			data, err := json.Marshal(mergeRawTask(rootTask, workspaceTask))
+			if err != nil {
+				return nil, err
+			}
+			var taskDefinition TaskDefinition
+			if err := json.Unmarshal(data, &taskDefinition); err != nil {
+				return nil, fmt.Errorf("%v: %v: %w", configPath, taskName, err)
+			}
+			merged[taskID] = taskDefinition
+		}
+	}
+	return merged, nil
+}
+
+// mergeRawTask returns the keys of the root's task definition, replaced by the
+// keys of the workspace's task definition
+func mergeRawTask(rootTask map[string]json.RawMessage, workspaceTask map[string]json.RawMessage) map[string]json.RawMessage {
+	merged := make(map[string]json.RawMessage, len(rootTask)+len(workspaceTask))
+	for key, value := range rootTask {
+		merged[key] = value
+	}
+	for key, value := range workspaceTask {
+		merged[key] = value
+		var items []string
+		if err := json.Unmarshal(value, &items); err != nil {
+			// Only arrays of strings can extend the root's
+			continue
+		}
+		extended := []string{}
+		extends := false
+		for _, item := range items {
+			if item == extendsToken {
+				extends = true
+			} else {
+				extended = append(extended, item)
+			}
+		}
+		if !extends {
+			continue
+		}
+		var rootItems []string
+		if rootValue, ok := rootTask[key]; ok {
+			_ = json.Unmarshal(rootValue, &rootItems)
+		}
+		// Marshalling a slice of strings can't fail
+		merged[key], _ = json.Marshal(append(rootItems, extended...))
+	}
+	return merged
+}
@@ -0,0 +1,116 @@
+package fs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+func testPackageInfos(dirs map[string]string) map[interface{}]*PackageJSON {
+	packageInfos := map[interface{}]*PackageJSON{
+		util.RootPkgName: {Name: util.RootPkgName, Dir: turbopath.AnchoredSystemPath("")},
+	}
+	for name, dir := range dirs {
+		packageInfos[name] = &PackageJSON{Name: name, Dir: turbopath.AnchoredUnixPath(dir).ToSystemPath()}
+	}
+	return packageInfos
+}
+
+func Test_MergeWorkspaceConfigs(t *testing.T) {
+	testDir := getTestDir(t, "workspace-configs")
+	turboJSON, err := readTurboJSON(testDir.UntypedJoin(configFile))
+	if err != nil {
+		t.Fatalf("invalid parse: %v", err)
+	}
+	packageInfos := testPackageInfos(map[string]string{
+		"web":  "apps/web",
+		"docs": "apps/docs",
+		"ui":   "packages/ui",
+	})
+
+	pipeline, err := turboJSON.Pipeline.MergeWorkspaceConfigs(testDir, packageInfos)
+	if err != nil {
+		t.Fatalf("failed to merge workspace configs: %v", err)
+	}
+
+	// the root pipeline is unchanged
+	assert.Equal(t, turboJSON.Pipeline["build"], pipeline["build"])
+	assert.Equal(t, turboJSON.Pipeline["lint"], pipeline["lint"])
+	assert.NotContains(t, pipeline, "ui#build")
+	assert.Len(t, pipeline, 6)
+
+	// extend-append: web's outputs are added to the root's build outputs
+	webBuild := pipeline["web#build"]
+	assert.Equal(t, TaskOutputs{Inclusions: []string{".next/**", "dist/**"}, Exclusions: []string{".next/cache/**"}, Ordered: []string{"dist/**", ".next/**", "!.next/cache/**"}}, webBuild.Outputs)
+	assert.Equal(t, []string{"build"}, webBuild.TopologicalDependencies)
+	assert.Equal(t, []string{"API_URL"}, webBuild.EnvVarDependencies)
+
+	// add: web's e2e task isn't in the root pipeline
+	webE2E := pipeline["web#e2e"]
+	assert.Equal(t, []string{"build"}, webE2E.TaskDependencies)
+	assert.False(t, webE2E.ShouldCache)
+
+	// override: docs's outputs replace the root's docs#build outputs, rather than build's
+	docsBuild := pipeline["docs#build"]
+	assert.Equal(t, TaskOutputs{Inclusions: []string{"public/**"}}, docsBuild.Outputs)
+	assert.Equal(t, []string{"build"}, docsBuild.TopologicalDependencies)
+	assert.Equal(t, []string{}, docsBuild.EnvVarDependencies)
+
+	// override: keys that docs doesn't set are kept from the root's lint
+	docsLint := pipeline["docs#lint"]
+	assert.Equal(t, TaskOutputs{}, docsLint.Outputs)
+	assert.Equal(t, []string{"DOCS_LINT"}, docsLint.EnvVarDependencies)
+	assert.True(t, docsLint.ShouldCache)
+}
+
+func Test_MergeWorkspaceConfigs_Invalid(t *testing.T) {
+	testCases := []struct {
+		name            string
+		rootConfig      string
+		workspaceConfig string
+		wantErr         string
+	}{
+		{
+			name:            "missing extends",
+			rootConfig:      `{"pipeline": {"build": {}}}`,
+			workspaceConfig: `{"pipeline": {"build": {}}}`,
+			wantErr:         `workspace web must extend the root turbo.json with "extends": ["//"]`,
+		},
+		{
+			name:            "workspace task",
+			rootConfig:      `{"pipeline": {"build": {}}}`,
+			workspaceConfig: `{"extends": ["//"], "pipeline": {"docs#build": {}}}`,
+			wantErr:         "workspace tasks (<package>#<task>) are not allowed in a workspace's turbo.json: found docs#build",
+		},
+		{
+			name:            "missing root config",
+			workspaceConfig: `{"extends": ["//"], "pipeline": {"build": {}}}`,
+			wantErr:         "workspace web extends the root turbo.json, which doesn't exist",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repoRoot := AbsoluteSystemPathFromUpstream(t.TempDir())
+			if tc.rootConfig != "" {
+				if err := repoRoot.UntypedJoin(configFile).WriteFile([]byte(tc.rootConfig), 0644); err != nil {
+					t.Fatalf("failed to write root config: %v", err)
+				}
+			}
+			workspaceDir := repoRoot.UntypedJoin("apps", "web")
+			if err := workspaceDir.MkdirAll(0755); err != nil {
+				t.Fatalf("failed to create workspace: %v", err)
+			}
+			if err := workspaceDir.UntypedJoin(configFile).WriteFile([]byte(tc.workspaceConfig), 0644); err != nil {
+				t.Fatalf("failed to write workspace config: %v", err)
+			}
+
+			_, err := Pipeline{}.MergeWorkspaceConfigs(repoRoot, testPackageInfos(map[string]string{"web": "apps/web"}))
+			if err == nil {
+				t.Fatal("expected an error merging workspace configs")
+			}
+			assert.Contains(t, err.Error(), tc.wantErr)
+		})
+	}
+}
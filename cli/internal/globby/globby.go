@@ -27,6 +27,43 @@ func GlobAll(basePath string, includePatterns []string, excludePatterns []string
 	return output, err
 }
 
+// GlobAllOrdered is like GlobAll, but takes globs in the order they apply, with the
+// patterns to exclude prefixed with !. Of the globs that match a file or folder, the
+// last one decides whether it is included, so an excluded file can be included
+// again by a glob after the one that excluded it.
+func GlobAllOrdered(basePath string, globs []string) ([]string, error) {
+	matched := make(util.Set)
+	for start := 0; start < len(globs); {
+		// Consecutive globs to include share the exclusions that come after them
+		end := start
+		for end < len(globs) && !strings.HasPrefix(globs[end], "!") {
+			end++
+		}
+		if end > start {
+			excludePatterns := []string{}
+			for _, glob := range globs[end:] {
+				if strings.HasPrefix(glob, "!") {
+					excludePatterns = append(excludePatterns, glob[1:])
+				}
+			}
+			output, err := GlobAll(basePath, globs[start:end], excludePatterns)
+			if err != nil {
+				return nil, err
+			}
+			for _, path := range output {
+				matched.Add(path)
+			}
+		}
+		start = end
+		for start < len(globs) && strings.HasPrefix(globs[start], "!") {
+			start++
+		}
+	}
+	output := matched.UnsafeListOfStrings()
+	sort.Strings(output)
+	return output, nil
+}
+
 // GlobFiles returns an array of files that match the specified set of glob patterns.
 // The return files are absolute paths, assuming that basePath is an absolute path.
 func GlobFiles(basePath string, includePatterns []string, excludePatterns []string) ([]string, error) {
@@ -40,6 +77,20 @@ func GlobFiles(basePath string, includePatterns []string, excludePatterns []stri
 	return output, err
 }
 
+// GlobFilesFollowingSymlinks is like GlobFiles, but also matches files inside of
+// symlinked directories. Files are returned at their paths through the symlinks.
+// Symlinks that lead back to a directory containing them are not followed.
+func GlobFilesFollowingSymlinks(basePath string, includePatterns []string, excludePatterns []string) ([]string, error) {
+	fsys := fs.CreateDirFSAtRoot(basePath)
+	fsysRoot := fs.GetDirFSRootPath(fsys)
+	output, err := globFilesFs(newSymlinkFollowingFS(fsys, fsysRoot), fsysRoot, basePath, includePatterns, excludePatterns)
+
+	// Because this is coming out of a map output is in no way ordered.
+	// Sorting will put the files in a depth-first order.
+	sort.Strings(output)
+	return output, err
+}
+
 // checkRelativePath ensures that the the requested file path is a child of `from`.
 func checkRelativePath(from string, to string) error {
 	relativePath, err := filepath.Rel(from, to)
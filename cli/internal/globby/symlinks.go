@@ -0,0 +1,101 @@
+package globby
+
+import (
+	iofs "io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// symlinkFollowingFS wraps an os.dirFS so that directory listings report
+// symlinks as the files or directories they point to, which makes globs
+// descend into symlinked directories. A symlink to a directory that contains
+// it, directly or through other symlinks, is left out of its listing, since
+// following it would never end.
+type symlinkFollowingFS struct {
+	fsys     iofs.FS
+	fsysRoot string
+	// realPaths caches the result of resolving every symlink in a path
+	realPaths map[string]string
+}
+
+var _ iofs.ReadDirFS = (*symlinkFollowingFS)(nil)
+
+func newSymlinkFollowingFS(fsys iofs.FS, fsysRoot string) *symlinkFollowingFS {
+	return &symlinkFollowingFS{
+		fsys:      fsys,
+		fsysRoot:  fsysRoot,
+		realPaths: make(map[string]string),
+	}
+}
+
+// Open implements fs.FS
+func (f *symlinkFollowingFS) Open(name string) (iofs.File, error) {
+	return f.fsys.Open(name)
+}
+
+// ReadDir implements fs.ReadDirFS
+func (f *symlinkFollowingFS) ReadDir(name string) ([]iofs.DirEntry, error) {
+	entries, err := iofs.ReadDir(f.fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	var ancestors map[string]bool
+	followed := make([]iofs.DirEntry, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Type()&iofs.ModeSymlink == 0 {
+			followed = append(followed, entry)
+			continue
+		}
+		entryPath := path.Join(name, entry.Name())
+		info, err := iofs.Stat(f.fsys, entryPath)
+		if err != nil {
+			// Leave broken symlinks as they are
+			followed = append(followed, entry)
+			continue
+		}
+		if info.IsDir() {
+			if ancestors == nil {
+				ancestors = f.ancestorRealPaths(name)
+			}
+			realPath, err := f.realPath(entryPath)
+			if err != nil || ancestors[realPath] {
+				continue
+			}
+		}
+		followed = append(followed, iofs.FileInfoToDirEntry(info))
+	}
+	return followed, nil
+}
+
+// realPath returns the path on disk of the given fs path, with every symlink resolved
+func (f *symlinkFollowingFS) realPath(name string) (string, error) {
+	if realPath, ok := f.realPaths[name]; ok {
+		return realPath, nil
+	}
+	realPath, err := filepath.EvalSymlinks(filepath.Join(f.fsysRoot, filepath.FromSlash(name)))
+	if err != nil {
+		return "", err
+	}
+	f.realPaths[name] = realPath
+	return realPath, nil
+}
+
+// ancestorRealPaths returns the real paths of the given fs path and of every
+// directory above it
+func (f *symlinkFollowingFS) ancestorRealPaths(name string) map[string]bool {
+	ancestors := make(map[string]bool)
+	if realPath, err := f.realPath("."); err == nil {
+		ancestors[realPath] = true
+	}
+	if name == "." {
+		return ancestors
+	}
+	segments := strings.Split(name, "/")
+	for i := range segments {
+		if realPath, err := f.realPath(strings.Join(segments[:i+1], "/")); err == nil {
+			ancestors[realPath] = true
+		}
+	}
+	return ancestors
+}
@@ -0,0 +1,50 @@
+package globby
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGlobFilesFollowingSymlinks(t *testing.T) {
+	root := t.TempDir()
+	for _, file := range []string{"pkg/src/index.ts", "shared/util.ts"} {
+		path := filepath.Join(root, filepath.FromSlash(file))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(file), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A shared directory, linked into the package
+	if err := os.Symlink(filepath.Join("..", "..", "shared"), filepath.Join(root, "pkg", "src", "shared")); err != nil {
+		t.Skipf("cannot create symlinks: %v", err)
+	}
+	// A link back to the package, which must not be followed forever
+	if err := os.Symlink("..", filepath.Join(root, "pkg", "src", "loop")); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := GlobFiles(root, []string{"pkg/src/**/*.ts"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{filepath.Join(root, "pkg", "src", "index.ts")}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GlobFiles() = %v, want %v", got, want)
+	}
+
+	got, err = GlobFilesFollowingSymlinks(root, []string{"pkg/src/**/*.ts"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []string{
+		filepath.Join(root, "pkg", "src", "index.ts"),
+		filepath.Join(root, "pkg", "src", "shared", "util.ts"),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GlobFilesFollowingSymlinks() = %v, want %v", got, want)
+	}
+}
@@ -19,8 +19,17 @@ type GraphVisualizer struct {
 	repoRoot  turbopath.AbsoluteSystemPath
 	ui        cli.Ui
 	TaskGraph *dag.AcyclicGraph
+	// isPersistent reports whether a task in the TaskGraph is persistent
+	isPersistent func(taskID string) bool
+	// criticalPath is the chain of task ids highlighted in the graph, starting
+	// with the task that runs first
+	criticalPath []string
 }
 
+// criticalPathDotAttrs are the dot attributes of the tasks and edges on the
+// critical path, the same as dag uses for cycles
+const criticalPathDotAttrs = ` [color = "red", penwidth = "2.0"]`
+
 // hasGraphViz checks for the presence of https://graphviz.org/
 func hasGraphViz() bool {
 	err := exec.Command("dot", "-V").Run()
@@ -28,20 +37,61 @@ func hasGraphViz() bool {
 }
 
 // New creates an instance of ColorCache with helpers for adding colors to task outputs
-func New(repoRoot turbopath.AbsoluteSystemPath, ui cli.Ui, TaskGraph *dag.AcyclicGraph) *GraphVisualizer {
+func New(repoRoot turbopath.AbsoluteSystemPath, ui cli.Ui, TaskGraph *dag.AcyclicGraph, isPersistent func(taskID string) bool) *GraphVisualizer {
 	return &GraphVisualizer{
-		repoRoot:  repoRoot,
-		ui:        ui,
-		TaskGraph: TaskGraph,
+		repoRoot:     repoRoot,
+		ui:           ui,
+		TaskGraph:    TaskGraph,
+		isPersistent: isPersistent,
 	}
 }
 
+// HighlightCriticalPath highlights the given chain of task ids, starting with
+// the task that runs first, in the graph
+func (g *GraphVisualizer) HighlightCriticalPath(taskIDs []string) {
+	g.criticalPath = taskIDs
+}
+
+// criticalPathEdges returns each edge on the critical path, from the task to the
+// task it depends on
+func (g *GraphVisualizer) criticalPathEdges() map[[2]string]bool {
+	edges := make(map[[2]string]bool)
+	for i := 1; i < len(g.criticalPath); i++ {
+		edges[[2]string{g.criticalPath[i], g.criticalPath[i-1]}] = true
+	}
+	return edges
+}
+
 // Converts the TaskGraph dag into a string
 func (g *GraphVisualizer) generateDotString() string {
-	return string(g.TaskGraph.Dot(&dag.DotOpts{
+	dot := string(g.TaskGraph.Dot(&dag.DotOpts{
 		Verbose:    true,
 		DrawCycles: true,
 	}))
+	if len(g.criticalPath) == 0 {
+		return dot
+	}
+
+	// dag has no way to style single edges and vertices, so the attributes are
+	// added to the edges on the critical path, and its tasks are declared again
+	// with attributes at the end of the graph
+	dotEdges := make(map[string]bool)
+	for edge := range g.criticalPathEdges() {
+		dotEdges[fmt.Sprintf(`"[root] %s" -> "[root] %s"`, edge[0], edge[1])] = true
+	}
+	lines := strings.SplitAfter(strings.TrimSuffix(dot, "}\n"), "\n")
+	var b strings.Builder
+	for _, line := range lines {
+		if dotEdges[strings.TrimSpace(line)] {
+			line = strings.TrimSuffix(line, "\n") + criticalPathDotAttrs + "\n"
+		}
+		b.WriteString(line)
+	}
+	for _, taskID := range g.criticalPath {
+		fmt.Fprintf(&b, "\t\"[root] %s\"%s\n", taskID, criticalPathDotAttrs)
+	}
+	b.WriteString("}\n")
+	return b.String()
 }
 
 // Outputs a warning when a file was requested, but graphviz is not available
@@ -65,6 +115,14 @@ func (g *GraphVisualizer) GenerateGraphFile(outputName string) error {
 		ext = ".jpg"
 		outputFilename = g.repoRoot.UntypedJoin(outputName + ext)
 	}
+	if isMermaidExt(ext) {
+		if err := outputFilename.WriteFile([]byte(g.generateMermaidString()), 0644); err != nil {
+			return fmt.Errorf("error writing graph contents: %w", err)
+		}
+		g.ui.Output("")
+		g.ui.Output(fmt.Sprintf("✔ Generated task graph in %s", ui.Bold(outputFilename.ToString())))
+		return nil
+	}
 	if ext == ".html" {
 		f, err := outputFilename.Create()
 		if err != nil {
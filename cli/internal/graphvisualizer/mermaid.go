@@ -0,0 +1,98 @@
+package graphvisualizer
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pyr-sh/dag"
+	"github.com/vercel/turbo/cli/internal/core"
+)
+
+// invalidMermaidID matches the characters that can't be used in a Mermaid node id
+var invalidMermaidID = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// isMermaidExt returns true if a graph file with the given extension should be
+// written as a Mermaid flowchart
+func isMermaidExt(ext string) bool {
+	return ext == ".mmd" || ext == ".mermaid"
+}
+
+// generateMermaidString converts the TaskGraph into a Mermaid flowchart. Each
+// task is a node labeled with its task id, and each dependency an edge from the
+// task to the task it depends on, as in the dot graph. Persistent tasks are
+// styled differently, and so are the tasks and edges on the critical path. The
+// root node is left out.
+func (g *GraphVisualizer) generateMermaidString() string {
+	taskIDs := []string{}
+	for _, v := range g.TaskGraph.Vertices() {
+		taskID := dag.VertexName(v)
+		if taskID == core.ROOT_NODE_NAME {
+			continue
+		}
+		taskIDs = append(taskIDs, taskID)
+	}
+	sort.Strings(taskIDs)
+
+	// Sanitizing can make two task ids the same, so later ones get a suffix
+	nodeIDs := make(map[string]string, len(taskIDs))
+	used := make(map[string]bool, len(taskIDs))
+	for _, taskID := range taskIDs {
+		base := invalidMermaidID.ReplaceAllString(taskID, "_")
+		nodeID := base
+		for i := 2; used[nodeID]; i++ {
+			nodeID = fmt.Sprintf("%v_%v", base, i)
+		}
+		used[nodeID] = true
+		nodeIDs[taskID] = nodeID
+	}
+
+	var b strings.Builder
+	b.WriteString("graph TD\n")
+	b.WriteString("\tclassDef persistent stroke-dasharray: 5 5\n")
+	for _, taskID := range taskIDs {
+		label := strings.ReplaceAll(taskID, `"`, "#quot;")
+		class := ""
+		if g.isPersistent != nil && g.isPersistent(taskID) {
+			class = ":::persistent"
+		}
+		fmt.Fprintf(&b, "\t%v[\"%v\"]%v\n", nodeIDs[taskID], label, class)
+	}
+	criticalEdges := g.criticalPathEdges()
+	criticalLinks := []string{}
+	links := 0
+	for _, taskID := range taskIDs {
+		deps := []string{}
+		for _, dep := range g.TaskGraph.DownEdges(taskID) {
+			depTaskID := dag.VertexName(dep)
+			if depTaskID == core.ROOT_NODE_NAME {
+				continue
+			}
+			deps = append(deps, depTaskID)
+		}
+		sort.Strings(deps)
+		for _, depTaskID := range deps {
+			fmt.Fprintf(&b, "\t%v --> %v\n", nodeIDs[taskID], nodeIDs[depTaskID])
+			if criticalEdges[[2]string{taskID, depTaskID}] {
+				criticalLinks = append(criticalLinks, fmt.Sprint(links))
+			}
+			links++
+		}
+	}
+
+	criticalNodes := []string{}
+	for _, taskID := range g.criticalPath {
+		if nodeID, ok := nodeIDs[taskID]; ok {
+			criticalNodes = append(criticalNodes, nodeID)
+		}
+	}
+	if len(criticalNodes) > 0 {
+		b.WriteString("\tclassDef critical stroke:#f00,stroke-width:2px\n")
+		fmt.Fprintf(&b, "\tclass %v critical\n", strings.Join(criticalNodes, ","))
+	}
+	if len(criticalLinks) > 0 {
+		fmt.Fprintf(&b, "\tlinkStyle %v stroke:#f00,stroke-width:2px\n", strings.Join(criticalLinks, ","))
+	}
+	return b.String()
+}
@@ -0,0 +1,54 @@
+package graphvisualizer
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/pyr-sh/dag"
+	"github.com/vercel/turbo/cli/internal/core"
+	"gotest.tools/v3/assert"
+)
+
+func TestGenerateMermaidString(t *testing.T) {
+	graph := &dag.AcyclicGraph{}
+	for _, taskID := range []string{"@scope/ui#build", "@scope/ui#dev", "docs#build", "docs_build", "web#build", "web#dev", core.ROOT_NODE_NAME} {
+		graph.Add(taskID)
+	}
+	graph.Connect(dag.BasicEdge("web#build", "@scope/ui#build"))
+	graph.Connect(dag.BasicEdge("docs#build", "@scope/ui#build"))
+	graph.Connect(dag.BasicEdge("web#dev", "web#build"))
+	graph.Connect(dag.BasicEdge("@scope/ui#build", core.ROOT_NODE_NAME))
+	graph.Connect(dag.BasicEdge("@scope/ui#dev", core.ROOT_NODE_NAME))
+	graph.Connect(dag.BasicEdge("docs_build", core.ROOT_NODE_NAME))
+
+	g := New("", nil, graph, func(taskID string) bool {
+		return strings.HasSuffix(taskID, "#dev")
+	})
+
+	expected, err := os.ReadFile("testdata/graph.mmd")
+	assert.NilError(t, err)
+	assert.Equal(t, g.generateMermaidString(), string(expected))
+}
+
+func TestGenerateMermaidStringCriticalPath(t *testing.T) {
+	graph := &dag.AcyclicGraph{}
+	for _, taskID := range []string{"ui#build", "docs#build", "web#build", core.ROOT_NODE_NAME} {
+		graph.Add(taskID)
+	}
+	graph.Connect(dag.BasicEdge("docs#build", "ui#build"))
+	graph.Connect(dag.BasicEdge("web#build", "ui#build"))
+	graph.Connect(dag.BasicEdge("ui#build", core.ROOT_NODE_NAME))
+
+	g := New("", nil, graph, nil)
+	g.HighlightCriticalPath([]string{"ui#build", "web#build"})
+
+	expected, err := os.ReadFile("testdata/graph_critical_path.mmd")
+	assert.NilError(t, err)
+	assert.Equal(t, g.generateMermaidString(), string(expected))
+
+	dot := g.generateDotString()
+	assert.Assert(t, strings.Contains(dot, `"[root] web#build" -> "[root] ui#build" [color = "red", penwidth = "2.0"]`), dot)
+	assert.Assert(t, !strings.Contains(dot, `"[root] docs#build" -> "[root] ui#build" [`), dot)
+	assert.Assert(t, strings.HasSuffix(dot, "\t\"[root] ui#build\" [color = \"red\", penwidth = \"2.0\"]\n\t\"[root] web#build\" [color = \"red\", penwidth = \"2.0\"]\n}\n"), dot)
+}
@@ -0,0 +1,220 @@
+package hashing
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/vercel/turbo/cli/internal/globby"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+// InputFileCache persists the files matched by a package's input globs, so that
+// they don't need to be re-globbed while the package's directory structure is
+// unchanged. The directory structure is fingerprinted by the modification time
+// of every directory in the package, since adding, removing, or renaming an
+// entry updates the modification time of the directory containing it. Installed
+// packages aren't walked, so node_modules directories are only fingerprinted by
+// their own modification time.
+type InputFileCache struct {
+	path    turbopath.AbsoluteSystemPath
+	mu      sync.Mutex
+	entries map[string]*inputFileCacheEntry
+	dirty   bool
+}
+
+type inputFileCacheEntry struct {
+	// Dirs maps each directory in the package, relative to the repo root, to its
+	// modification time in nanoseconds
+	Dirs map[string]int64 `json:"dirs"`
+	// Files are the files matched by the input globs, relative to the repo root
+	Files []string `json:"files"`
+}
+
+// GetInputFileCachePath returns the path to the input file cache for the given repository
+func GetInputFileCachePath(repoRoot turbopath.AbsoluteSystemPath) turbopath.AbsoluteSystemPath {
+	return repoRoot.UntypedJoin(".turbo", "input-files.json")
+}
+
+// LoadInputFileCache reads the input file cache at the given path. A missing or
+// unreadable cache results in an empty cache, rather than an error, since it
+// can always be rebuilt.
+func LoadInputFileCache(path turbopath.AbsoluteSystemPath) *InputFileCache {
+	c := &InputFileCache{
+		path:    path,
+		entries: make(map[string]*inputFileCacheEntry),
+	}
+	if contents, err := path.ReadFile(); err == nil {
+		if err := json.Unmarshal(contents, &c.entries); err != nil {
+			c.entries = make(map[string]*inputFileCacheEntry)
+		}
+	}
+	return c
+}
+
+// Save writes the cache back to disk, if it has changed
+func (c *InputFileCache) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.dirty {
+		return nil
+	}
+	contents, err := json.Marshal(c.entries)
+	if err != nil {
+		return err
+	}
+	if err := c.path.EnsureDir(); err != nil {
+		return err
+	}
+	if err := c.path.WriteFile(contents, 0644); err != nil {
+		return err
+	}
+	c.dirty = false
+	return nil
+}
+
+// GlobFiles returns the absolute paths of the files in pkgPath matched by the given
+// repo-relative patterns, reusing the cached result if the package's directory
+// structure has not changed. A nil cache always globs, as do patterns that reach
+// outside of the package or into node_modules, since only the package's own
+// directories are fingerprinted.
+func (c *InputFileCache) GlobFiles(rootPath turbopath.AbsoluteSystemPath, pkgPath turbopath.AbsoluteSystemPath, patterns []string) ([]string, error) {
+	if c == nil {
+		return globby.GlobFiles(rootPath.ToStringDuringMigration(), patterns, nil)
+	}
+	cacheable, err := patternsAreFingerprinted(rootPath, pkgPath, patterns)
+	if err != nil {
+		return nil, err
+	} else if !cacheable {
+		return globby.GlobFiles(rootPath.ToStringDuringMigration(), patterns, nil)
+	}
+	key := inputFileCacheKey(patterns)
+
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && entry.isValid(rootPath) {
+		files := make([]string, len(entry.Files))
+		for i, file := range entry.Files {
+			files[i] = rootPath.UntypedJoin(file).ToString()
+		}
+		return files, nil
+	}
+
+	// Fingerprint the directories before globbing, so that any change made while
+	// globbing invalidates the entry next time.
+	dirs, err := directoryModTimes(rootPath, pkgPath)
+	if err != nil {
+		return nil, err
+	}
+	files, err := globby.GlobFiles(rootPath.ToStringDuringMigration(), patterns, nil)
+	if err != nil {
+		return nil, err
+	}
+	entry = &inputFileCacheEntry{
+		Dirs:  dirs,
+		Files: make([]string, len(files)),
+	}
+	for i, file := range files {
+		relativePath, err := filepath.Rel(rootPath.ToString(), file)
+		if err != nil {
+			return nil, err
+		}
+		entry.Files[i] = relativePath
+	}
+
+	c.mu.Lock()
+	c.entries[key] = entry
+	c.dirty = true
+	c.mu.Unlock()
+	return files, nil
+}
+
+// isValid returns true if none of the directories recorded for the entry have changed
+func (e *inputFileCacheEntry) isValid(rootPath turbopath.AbsoluteSystemPath) bool {
+	if len(e.Dirs) == 0 {
+		return false
+	}
+	for dir, modTime := range e.Dirs {
+		info, err := rootPath.UntypedJoin(dir).Lstat()
+		if err != nil || !info.IsDir() || info.ModTime().UnixNano() != modTime {
+			return false
+		}
+	}
+	return true
+}
+
+// inputFileCacheKey fingerprints a set of repo-relative input globs. Since the
+// globs include the package path, this is unique per package and input globs.
+func inputFileCacheKey(patterns []string) string {
+	sorted := make([]string, len(patterns))
+	copy(sorted, patterns)
+	sort.Strings(sorted)
+	hash := sha256.Sum256([]byte(strings.Join(sorted, "\x00")))
+	return hex.EncodeToString(hash[:])
+}
+
+// unwalkedDirs are directories whose contents aren't fingerprinted, because they
+// are large and not part of the package's own sources
+var unwalkedDirs = map[string]bool{
+	"node_modules": true,
+	".turbo":       true,
+}
+
+// patternsAreFingerprinted returns true if the repo-relative patterns only match
+// files that directoryModTimes fingerprints, namely those in the package and not
+// in node_modules
+func patternsAreFingerprinted(rootPath turbopath.AbsoluteSystemPath, pkgPath turbopath.AbsoluteSystemPath, patterns []string) (bool, error) {
+	pkgRelativePath, err := rootPath.PathTo(pkgPath)
+	if err != nil {
+		return false, err
+	}
+	for _, pattern := range patterns {
+		if pkgRelativePath != "." && pattern != pkgRelativePath && !strings.HasPrefix(pattern, pkgRelativePath+string(filepath.Separator)) {
+			return false, nil
+		}
+		for _, segment := range strings.Split(filepath.ToSlash(pattern), "/") {
+			if unwalkedDirs[segment] {
+				return false, nil
+			}
+		}
+	}
+	return true, nil
+}
+
+// directoryModTimes returns the modification time of every directory under pkgPath,
+// keyed by its path relative to rootPath. The contents of unwalkedDirs, and of
+// .git, aren't included.
+func directoryModTimes(rootPath turbopath.AbsoluteSystemPath, pkgPath turbopath.AbsoluteSystemPath) (map[string]int64, error) {
+	dirs := make(map[string]int64)
+	err := filepath.WalkDir(pkgPath.ToString(), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		relativePath, err := filepath.Rel(rootPath.ToString(), path)
+		if err != nil {
+			return err
+		}
+		dirs[relativePath] = info.ModTime().UnixNano()
+		if unwalkedDirs[d.Name()] && path != pkgPath.ToString() {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	return dirs, err
+}
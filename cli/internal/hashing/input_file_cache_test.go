@@ -0,0 +1,107 @@
+package hashing
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"gotest.tools/v3/assert"
+)
+
+func TestInputFileCache(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	pkgDir := repoRoot.UntypedJoin("my-pkg")
+	srcFile := pkgDir.UntypedJoin("src", "index.js")
+	assert.NilError(t, srcFile.EnsureDir(), "EnsureDir")
+	assert.NilError(t, srcFile.WriteFile([]byte("index"), 0644), "WriteFile")
+	patterns := []string{"my-pkg/src/**"}
+	cachePath := GetInputFileCachePath(repoRoot)
+
+	cache := LoadInputFileCache(cachePath)
+	files, err := cache.GlobFiles(repoRoot, pkgDir, patterns)
+	assert.NilError(t, err, "GlobFiles")
+	assert.DeepEqual(t, files, []string{srcFile.ToString()})
+	assert.NilError(t, cache.Save(), "Save")
+
+	// Tamper with the saved file list, so we can tell whether it is reused
+	cache = LoadInputFileCache(cachePath)
+	key := inputFileCacheKey(patterns)
+	cache.entries[key].Files = []string{filepath.Join("my-pkg", "src", "cached.js")}
+	files, err = cache.GlobFiles(repoRoot, pkgDir, patterns)
+	assert.NilError(t, err, "GlobFiles")
+	assert.DeepEqual(t, files, []string{repoRoot.UntypedJoin("my-pkg", "src", "cached.js").ToString()})
+
+	// Adding a file changes the directory structure, so the cached list is discarded
+	otherFile := pkgDir.UntypedJoin("src", "other.js")
+	assert.NilError(t, otherFile.WriteFile([]byte("other"), 0644), "WriteFile")
+	later := time.Now().Add(time.Minute)
+	assert.NilError(t, os.Chtimes(srcFile.Dir().ToString(), later, later), "Chtimes")
+	files, err = cache.GlobFiles(repoRoot, pkgDir, patterns)
+	assert.NilError(t, err, "GlobFiles")
+	assert.DeepEqual(t, files, []string{srcFile.ToString(), otherFile.ToString()})
+}
+
+func TestInputFileCacheNil(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	pkgDir := repoRoot.UntypedJoin("my-pkg")
+	srcFile := pkgDir.UntypedJoin("index.js")
+	assert.NilError(t, srcFile.EnsureDir(), "EnsureDir")
+	assert.NilError(t, srcFile.WriteFile([]byte("index"), 0644), "WriteFile")
+
+	var cache *InputFileCache
+	files, err := cache.GlobFiles(repoRoot, pkgDir, []string{"my-pkg/*.js"})
+	assert.NilError(t, err, "GlobFiles")
+	assert.DeepEqual(t, files, []string{srcFile.ToString()})
+}
+
+func TestInputFileCacheSkipsNodeModules(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	pkgDir := repoRoot.UntypedJoin("my-pkg")
+	srcFile := pkgDir.UntypedJoin("src", "index.js")
+	assert.NilError(t, srcFile.EnsureDir(), "EnsureDir")
+	assert.NilError(t, srcFile.WriteFile([]byte("index"), 0644), "WriteFile")
+	depFile := pkgDir.UntypedJoin("node_modules", "dep", "lib", "index.js")
+	assert.NilError(t, depFile.EnsureDir(), "EnsureDir")
+	assert.NilError(t, depFile.WriteFile([]byte("dep"), 0644), "WriteFile")
+
+	dirs, err := directoryModTimes(repoRoot, pkgDir)
+	assert.NilError(t, err, "directoryModTimes")
+	_, ok := dirs[filepath.Join("my-pkg", "node_modules")]
+	assert.Assert(t, ok, "node_modules itself should be fingerprinted")
+	_, ok = dirs[filepath.Join("my-pkg", "node_modules", "dep")]
+	assert.Assert(t, !ok, "the contents of node_modules shouldn't be walked")
+
+	// Patterns that reach into node_modules aren't cached
+	cache := LoadInputFileCache(GetInputFileCachePath(repoRoot))
+	files, err := cache.GlobFiles(repoRoot, pkgDir, []string{"my-pkg/node_modules/dep/**"})
+	assert.NilError(t, err, "GlobFiles")
+	assert.DeepEqual(t, files, []string{depFile.ToString()})
+	assert.Equal(t, len(cache.entries), 0)
+}
+
+func TestInputFileCacheOutsidePackage(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	pkgDir := repoRoot.UntypedJoin("packages", "my-pkg")
+	sharedFile := repoRoot.UntypedJoin("packages", "shared", "index.js")
+	assert.NilError(t, sharedFile.EnsureDir(), "EnsureDir")
+	assert.NilError(t, sharedFile.WriteFile([]byte("shared"), 0644), "WriteFile")
+	assert.NilError(t, pkgDir.MkdirAll(0755), "MkdirAll")
+	// As the input ../shared/** of my-pkg
+	patterns := []string{filepath.Join("packages", "shared", "**")}
+
+	cache := LoadInputFileCache(GetInputFileCachePath(repoRoot))
+	files, err := cache.GlobFiles(repoRoot, pkgDir, patterns)
+	assert.NilError(t, err, "GlobFiles")
+	assert.DeepEqual(t, files, []string{sharedFile.ToString()})
+	assert.Equal(t, len(cache.entries), 0)
+
+	// A file added outside of the package is found, even though the package's
+	// directories haven't changed
+	otherFile := repoRoot.UntypedJoin("packages", "shared", "other.js")
+	assert.NilError(t, otherFile.WriteFile([]byte("other"), 0644), "WriteFile")
+	files, err = cache.GlobFiles(repoRoot, pkgDir, patterns)
+	assert.NilError(t, err, "GlobFiles")
+	assert.DeepEqual(t, files, []string{sharedFile.ToString(), otherFile.ToString()})
+}
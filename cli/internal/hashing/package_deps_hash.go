@@ -10,6 +10,7 @@ import (
 	"sync"
 
 	"github.com/pkg/errors"
+	"github.com/vercel/turbo/cli/internal/doublestar"
 	"github.com/vercel/turbo/cli/internal/encoding/gitoutput"
 	"github.com/vercel/turbo/cli/internal/fs"
 	"github.com/vercel/turbo/cli/internal/globby"
@@ -24,73 +25,144 @@ type PackageDepsOptions struct {
 	PackagePath turbopath.AnchoredSystemPath
 
 	InputPatterns []string
+
+	// InputFileCache, if set, is used to avoid re-globbing InputPatterns
+	InputFileCache *InputFileCache
+
+	// FollowSymlinks matches InputPatterns against the files inside of symlinked
+	// directories. The InputFileCache can't tell when those files change, so it
+	// isn't used.
+	FollowSymlinks bool
+}
+
+// DefaultInputsToken is an input pattern that stands for the files hashed when
+// a task doesn't specify its inputs, so that other patterns can add to or
+// exclude from them (e.g. ["$TURBO_DEFAULT$", "!**/*.test.ts"])
+const DefaultInputsToken = "$TURBO_DEFAULT$"
+
+// SplitInputPatterns separates input patterns into the globs of files to include
+// and the globs, without their leading !, of files to exclude. useDefault is true
+// if the default files are included, either because of DefaultInputsToken or
+// because no files are included otherwise.
+func SplitInputPatterns(patterns []string) (includes []string, excludes []string, useDefault bool) {
+	for _, pattern := range patterns {
+		if pattern == DefaultInputsToken {
+			useDefault = true
+		} else if strings.HasPrefix(pattern, "!") {
+			excludes = append(excludes, pattern[1:])
+		} else {
+			includes = append(includes, pattern)
+		}
+	}
+	return includes, excludes, useDefault || len(includes) == 0
 }
 
 // GetPackageDeps Builds an object containing git hashes for the files under the specified `packagePath` folder.
 func GetPackageDeps(rootPath turbopath.AbsoluteSystemPath, p *PackageDepsOptions) (map[turbopath.AnchoredUnixPath]string, error) {
 	pkgPath := rootPath.UntypedJoin(p.PackagePath.ToStringDuringMigration())
-	// Add all the checked in hashes.
-	var result map[turbopath.AnchoredUnixPath]string
+	includes, excludes, useDefault := SplitInputPatterns(p.InputPatterns)
 
-	// make a copy of the inputPatterns array, because we may be appending to it later.
-	calculatedInputs := make([]string, len(p.InputPatterns))
-	copy(calculatedInputs, p.InputPatterns)
-
-	if len(calculatedInputs) == 0 {
-		gitLsTreeOutput, err := gitLsTree(pkgPath)
+	result := make(map[turbopath.AnchoredUnixPath]string)
+	if useDefault {
+		hashes, err := getDefaultPackageDeps(pkgPath, p)
 		if err != nil {
-			return nil, fmt.Errorf("could not get git hashes for files in package %s: %w", p.PackagePath, err)
+			return nil, err
 		}
-		result = gitLsTreeOutput
-	} else {
-
-		// Add in package.json to input patterns because if the `scripts` in
-		// the package.json change (i.e. the tasks that turbo executes), we want
-		// a cache miss, since any existing cache could be invalid.
-		// Note this package.json will be resolved relative to the pkgPath.
-		calculatedInputs = append(calculatedInputs, "package.json")
-
-		// The input patterns are relative to the package.
-		// However, we need to change the globbing to be relative to the repo root.
-		// Prepend the package path to each of the input patterns.
-		prefixedInputPatterns := make([]string, len(calculatedInputs))
-		for index, pattern := range calculatedInputs {
-			rerooted, err := rootPath.PathTo(pkgPath.UntypedJoin(pattern))
-			if err != nil {
-				return nil, err
-			}
-			prefixedInputPatterns[index] = rerooted
+		for filePath, hash := range hashes {
+			result[filePath] = hash
 		}
+	}
+	if len(includes) > 0 {
+		hashes, err := getGlobbedPackageDeps(rootPath, pkgPath, includes, p)
+		if err != nil {
+			return nil, err
+		}
+		for filePath, hash := range hashes {
+			result[filePath] = hash
+		}
+	}
+	if err := removeExcludedFiles(result, excludes); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
 
-		absoluteFilesToHash, err := globby.GlobFiles(rootPath.ToStringDuringMigration(), prefixedInputPatterns, nil)
+// getDefaultPackageDeps hashes all of the files in the package that aren't ignored by git
+func getDefaultPackageDeps(pkgPath turbopath.AbsoluteSystemPath, p *PackageDepsOptions) (map[turbopath.AnchoredUnixPath]string, error) {
+	result, err := gitLsTree(pkgPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not get git hashes for files in package %s: %w", p.PackagePath, err)
+	}
+	if err := updateFromGitStatus(result, pkgPath, nil); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
 
+// getGlobbedPackageDeps hashes the files in the package matching the given globs
+func getGlobbedPackageDeps(rootPath turbopath.AbsoluteSystemPath, pkgPath turbopath.AbsoluteSystemPath, includes []string, p *PackageDepsOptions) (map[turbopath.AnchoredUnixPath]string, error) {
+	// make a copy of the includes, because we're appending to it
+	calculatedInputs := make([]string, len(includes), len(includes)+1)
+	copy(calculatedInputs, includes)
+
+	// Add in package.json to input patterns because if the `scripts` in
+	// the package.json change (i.e. the tasks that turbo executes), we want
+	// a cache miss, since any existing cache could be invalid.
+	// Note this package.json will be resolved relative to the pkgPath.
+	calculatedInputs = append(calculatedInputs, "package.json")
+
+	// The input patterns are relative to the package.
+	// However, we need to change the globbing to be relative to the repo root.
+	// Prepend the package path to each of the input patterns.
+	prefixedInputPatterns := make([]string, len(calculatedInputs))
+	for index, pattern := range calculatedInputs {
+		rerooted, err := rootPath.PathTo(pkgPath.UntypedJoin(pattern))
 		if err != nil {
-			return nil, errors.Wrapf(err, "failed to resolve input globs %v", calculatedInputs)
+			return nil, err
 		}
+		prefixedInputPatterns[index] = rerooted
+	}
 
-		filesToHash := make([]turbopath.AnchoredSystemPath, len(absoluteFilesToHash))
-		for i, rawPath := range absoluteFilesToHash {
-			relativePathString, err := pkgPath.RelativePathString(rawPath)
+	var absoluteFilesToHash []string
+	var err error
+	if p.FollowSymlinks {
+		absoluteFilesToHash, err = globby.GlobFilesFollowingSymlinks(rootPath.ToStringDuringMigration(), prefixedInputPatterns, nil)
+	} else {
+		absoluteFilesToHash, err = p.InputFileCache.GlobFiles(rootPath, pkgPath, prefixedInputPatterns)
+	}
 
-			if err != nil {
-				return nil, errors.Wrapf(err, "not relative to package: %v", rawPath)
-			}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to resolve input globs %v", calculatedInputs)
+	}
 
-			filesToHash[i] = turbopath.AnchoredSystemPathFromUpstream(relativePathString)
-		}
+	filesToHash := make([]turbopath.AnchoredSystemPath, len(absoluteFilesToHash))
+	for i, rawPath := range absoluteFilesToHash {
+		relativePathString, err := pkgPath.RelativePathString(rawPath)
 
-		hashes, err := gitHashObject(turbopath.AbsoluteSystemPathFromUpstream(pkgPath.ToStringDuringMigration()), filesToHash)
 		if err != nil {
-			return nil, errors.Wrap(err, "failed hashing resolved inputs globs")
+			return nil, errors.Wrapf(err, "not relative to package: %v", rawPath)
 		}
-		result = hashes
+
+		filesToHash[i] = turbopath.AnchoredSystemPathFromUpstream(relativePathString)
 	}
 
-	// Update the checked in hashes with the current repo status
+	result, err := gitHashObject(turbopath.AbsoluteSystemPathFromUpstream(pkgPath.ToStringDuringMigration()), filesToHash)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed hashing resolved inputs globs")
+	}
+	if err := updateFromGitStatus(result, pkgPath, calculatedInputs); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// updateFromGitStatus updates the checked in hashes with the current repo status
+// of the files matching the given patterns, or of all files if there are none
+func updateFromGitStatus(result map[turbopath.AnchoredUnixPath]string, pkgPath turbopath.AbsoluteSystemPath, patterns []string) error {
 	// The paths returned from this call are anchored at the package directory
-	gitStatusOutput, err := gitStatus(pkgPath, calculatedInputs)
+	gitStatusOutput, err := gitStatus(pkgPath, patterns)
 	if err != nil {
-		return nil, fmt.Errorf("Could not get git hashes from git status: %v", err)
+		return fmt.Errorf("Could not get git hashes from git status: %v", err)
 	}
 
 	var filesToHash []turbopath.AnchoredSystemPath
@@ -104,15 +176,32 @@ func GetPackageDeps(rootPath turbopath.AbsoluteSystemPath, p *PackageDepsOptions
 
 	hashes, err := gitHashObject(turbopath.AbsoluteSystemPathFromUpstream(pkgPath.ToString()), filesToHash)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	// Zip up file paths and hashes together
 	for filePath, hash := range hashes {
 		result[filePath] = hash
 	}
+	return nil
+}
 
-	return result, nil
+// removeExcludedFiles removes the files matching any of the given package-relative
+// globs from the hashes
+func removeExcludedFiles(result map[turbopath.AnchoredUnixPath]string, excludes []string) error {
+	for filePath := range result {
+		for _, exclude := range excludes {
+			excluded, err := doublestar.Match(exclude, filePath.ToString())
+			if err != nil {
+				return errors.Wrapf(err, "invalid input glob !%v", exclude)
+			}
+			if excluded {
+				delete(result, filePath)
+				break
+			}
+		}
+	}
+	return nil
 }
 
 func manuallyHashFiles(rootPath turbopath.AbsoluteSystemPath, files []turbopath.AnchoredSystemPath) (map[turbopath.AnchoredUnixPath]string, error) {
@@ -365,6 +365,43 @@ func TestGetPackageDeps(t *testing.T) {
 				"uncommitted-file": "4e56ad89387e6379e4e91ddfe9872cf6a72c9976",
 			},
 		},
+		// the default inputs token hashes the same files as not specifying inputs
+		{
+			opts: &PackageDepsOptions{
+				PackagePath:   "my-pkg",
+				InputPatterns: []string{"$TURBO_DEFAULT$"},
+			},
+			expected: map[turbopath.AnchoredUnixPath]string{
+				"committed-file":   "3a29e62ea9ba15c4a4009d1f605d391cdd262033",
+				"uncommitted-file": "4e56ad89387e6379e4e91ddfe9872cf6a72c9976",
+				"package.json":     "9e26dfeeb6e641a33dae4961196235bdb965b21b",
+				"dir/nested-file":  "bfe53d766e64d78f80050b73cd1c88095bc70abb",
+			},
+		},
+		// negated inputs exclude files from the default inputs
+		{
+			opts: &PackageDepsOptions{
+				PackagePath:   "my-pkg",
+				InputPatterns: []string{"$TURBO_DEFAULT$", "!dir/**", "!uncommitted-file"},
+			},
+			expected: map[turbopath.AnchoredUnixPath]string{
+				"committed-file": "3a29e62ea9ba15c4a4009d1f605d391cdd262033",
+				"package.json":   "9e26dfeeb6e641a33dae4961196235bdb965b21b",
+			},
+		},
+		// the default inputs can be combined with files outside of the package
+		{
+			opts: &PackageDepsOptions{
+				PackagePath:   "my-pkg",
+				InputPatterns: []string{"$TURBO_DEFAULT$", "../new-root-file", "!**/nested-file"},
+			},
+			expected: map[turbopath.AnchoredUnixPath]string{
+				"../new-root-file": "8906ddcdd634706188bd8ef1c98ac07b9be3425e",
+				"committed-file":   "3a29e62ea9ba15c4a4009d1f605d391cdd262033",
+				"uncommitted-file": "4e56ad89387e6379e4e91ddfe9872cf6a72c9976",
+				"package.json":     "9e26dfeeb6e641a33dae4961196235bdb965b21b",
+			},
+		},
 	}
 	for _, tt := range tests {
 		got, err := GetPackageDeps(repoRoot, tt.opts)
@@ -384,3 +421,39 @@ func Test_memoizedGetTraversePath(t *testing.T) {
 
 	assert.Check(t, gotOne == gotTwo, "The strings are identical.")
 }
+
+func TestGetPackageDepsExcludesFromDefaultInputs(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	srcPath := repoRoot.UntypedJoin("my-pkg", "src", "index.ts")
+	testPath := repoRoot.UntypedJoin("my-pkg", "src", "index.test.ts")
+	assert.NilError(t, srcPath.EnsureDir(), "EnsureDir")
+	assert.NilError(t, srcPath.WriteFile([]byte("export const a = 1"), 0644), "WriteFile")
+	assert.NilError(t, testPath.WriteFile([]byte("test('a')"), 0644), "WriteFile")
+	assert.NilError(t, repoRoot.UntypedJoin("my-pkg", "package.json").WriteFile([]byte("{}"), 0644), "WriteFile")
+
+	requireGitCmd(t, repoRoot, "init", ".")
+	requireGitCmd(t, repoRoot, "config", "--local", "user.name", "test")
+	requireGitCmd(t, repoRoot, "config", "--local", "user.email", "test@example.com")
+	requireGitCmd(t, repoRoot, "add", ".")
+	requireGitCmd(t, repoRoot, "commit", "-m", "foo")
+
+	opts := &PackageDepsOptions{
+		PackagePath:   "my-pkg",
+		InputPatterns: []string{"$TURBO_DEFAULT$", "!**/*.test.ts"},
+	}
+	hash := func() string {
+		t.Helper()
+		hashes, err := GetPackageDeps(repoRoot, opts)
+		assert.NilError(t, err, "GetPackageDeps")
+		hash, err := fs.HashObject(hashes)
+		assert.NilError(t, err, "HashObject")
+		return hash
+	}
+
+	before := hash()
+	assert.NilError(t, testPath.WriteFile([]byte("test('b')"), 0644), "WriteFile")
+	assert.Equal(t, hash(), before, "changing an excluded test file changed the hash")
+
+	assert.NilError(t, srcPath.WriteFile([]byte("export const a = 2"), 0644), "WriteFile")
+	assert.Assert(t, hash() != before, "changing a source file didn't change the hash")
+}
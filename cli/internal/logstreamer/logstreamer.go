@@ -141,8 +141,14 @@ var _ io.Writer = (*PrettyStdoutWriter)(nil)
 
 // NewPrettyStdoutWriter returns an instance of PrettyStdoutWriter
 func NewPrettyStdoutWriter(prefix string) *PrettyStdoutWriter {
+	return NewPrettyWriter(os.Stdout, prefix)
+}
+
+// NewPrettyWriter returns an instance of PrettyStdoutWriter that writes to w
+// rather than to stdout
+func NewPrettyWriter(w io.Writer, prefix string) *PrettyStdoutWriter {
 	return &PrettyStdoutWriter{
-		w:      os.Stdout,
+		w:      w,
 		Prefix: prefix,
 	}
 }
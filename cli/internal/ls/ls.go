@@ -0,0 +1,149 @@
+// Package ls implements `turbo ls`, which lists the workspaces of a repo
+package ls
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/vercel/turbo/cli/internal/cmdutil"
+	"github.com/vercel/turbo/cli/internal/context"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/scm"
+	"github.com/vercel/turbo/cli/internal/scope"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// Workspace is a workspace as listed by `turbo ls`
+type Workspace struct {
+	Name string `json:"name"`
+	// Path is the directory of the workspace, relative to the repo root
+	Path    string            `json:"path"`
+	Scripts map[string]string `json:"scripts"`
+	// Tasks are the tasks in the pipeline that run a script of the workspace
+	Tasks []string `json:"tasks"`
+}
+
+// List is the output of `turbo ls`
+type List struct {
+	Workspaces []Workspace `json:"workspaces"`
+}
+
+// GetCmd returns the ls subcommand for use with cobra
+func GetCmd(helper *cmdutil.Helper) *cobra.Command {
+	opts := &scope.Opts{}
+	cmd := &cobra.Command{
+		Use:                   "ls [<flags>]",
+		Short:                 "List the workspaces of your monorepo and their tasks as JSON.",
+		SilenceUsage:          true,
+		SilenceErrors:         true,
+		DisableFlagsInUseLine: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base, err := helper.GetCmdBase(cmd.Flags())
+			if err != nil {
+				return err
+			}
+			list, err := listWorkspaces(base, opts)
+			if err != nil {
+				base.LogError("%v", err)
+				return err
+			}
+			bytes, err := json.MarshalIndent(list, "", "  ")
+			if err != nil {
+				return errors.Wrap(err, "failed to render JSON")
+			}
+			base.UI.Output(string(bytes))
+			return nil
+		},
+	}
+	scope.AddFlags(opts, cmd.Flags())
+	return cmd
+}
+
+// listWorkspaces lists the workspaces that the filters of opts select, or every
+// workspace, including the root, if there are none
+func listWorkspaces(base *cmdutil.CmdBase, opts *scope.Opts) (*List, error) {
+	rootPackageJSON, err := fs.ReadPackageJSON(base.RepoRoot.UntypedJoin("package.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read package.json: %w", err)
+	}
+	turboJSON, err := fs.LoadTurboConfig(base.RepoRoot, rootPackageJSON, false)
+	if err != nil {
+		return nil, err
+	}
+	pkgDepGraph, err := context.BuildPackageGraph(base.RepoRoot, rootPackageJSON)
+	if err != nil {
+		var warnings *context.Warnings
+		if !errors.As(err, &warnings) {
+			return nil, err
+		}
+		base.LogWarning("Issues occurred when constructing package graph", err)
+	}
+	pipeline, err := turboJSON.Pipeline.MergeWorkspaceConfigs(base.RepoRoot, pkgDepGraph.PackageInfos)
+	if err != nil {
+		return nil, err
+	}
+
+	scmInstance, err := scm.FromInRepo(base.RepoRoot)
+	if err != nil {
+		if !errors.Is(err, scm.ErrFallback) {
+			return nil, errors.Wrap(err, "failed to create SCM")
+		}
+		base.LogWarning("", err)
+	}
+	filteredPkgs, isAllPackages, err := scope.ResolvePackages(opts, base.RepoRoot.ToStringDuringMigration(), scmInstance, pkgDepGraph, base.UI, base.Logger)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to resolve packages")
+	}
+	if isAllPackages {
+		filteredPkgs.Add(util.RootPkgName)
+	}
+	return newList(filteredPkgs.UnsafeListOfStrings(), pkgDepGraph.PackageInfos, pipeline), nil
+}
+
+// newList lists the given workspaces, sorted by name
+func newList(names []string, packageInfos map[interface{}]*fs.PackageJSON, pipeline fs.Pipeline) *List {
+	sort.Strings(names)
+	list := &List{Workspaces: []Workspace{}}
+	for _, name := range names {
+		pkg, ok := packageInfos[name]
+		if !ok {
+			continue
+		}
+		scripts := pkg.Scripts
+		if scripts == nil {
+			scripts = map[string]string{}
+		}
+		path := pkg.Dir.ToUnixPath().ToString()
+		if path == "" {
+			path = "."
+		}
+		list.Workspaces = append(list.Workspaces, Workspace{
+			Name:    name,
+			Path:    path,
+			Scripts: scripts,
+			Tasks:   workspaceTasks(name, scripts, pipeline),
+		})
+	}
+	return list
+}
+
+// workspaceTasks returns the tasks in the pipeline that run one of the given
+// scripts in the named workspace, sorted. Like in `turbo run`, the root workspace
+// only runs the tasks that are configured for it as //#<task>.
+func workspaceTasks(name string, scripts map[string]string, pipeline fs.Pipeline) []string {
+	tasks := []string{}
+	for script := range scripts {
+		_, ok := pipeline[util.GetTaskId(name, script)]
+		if !ok && name != util.RootPkgName {
+			_, ok = pipeline[script]
+		}
+		if ok {
+			tasks = append(tasks, script)
+		}
+	}
+	sort.Strings(tasks)
+	return tasks
+}
@@ -0,0 +1,102 @@
+package ls
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/mitchellh/cli"
+	"github.com/vercel/turbo/cli/internal/cmdutil"
+	"github.com/vercel/turbo/cli/internal/scope"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"gotest.tools/v3/assert"
+)
+
+func newTestRepo(t *testing.T) *cmdutil.CmdBase {
+	t.Helper()
+	repoRoot := turbopath.AbsoluteSystemPathFromUpstream(t.TempDir())
+	writeFile := func(file string, contents string) {
+		t.Helper()
+		path := repoRoot.UntypedJoin(filepath.FromSlash(file))
+		assert.NilError(t, path.EnsureDir(), "EnsureDir")
+		assert.NilError(t, path.WriteFile([]byte(contents), 0644), "WriteFile")
+	}
+	writeFile("package.json", `{"name": "root", "packageManager": "npm@8.19.2", "workspaces": ["apps/*", "packages/*"], "scripts": {"lint": "eslint ."}}`)
+	writeFile("package-lock.json", "{}")
+	writeFile("turbo.json", `{
+		"pipeline": {
+			"build": {"dependsOn": ["^build"]},
+			"dev": {"cache": false, "persistent": true},
+			"docs#typecheck": {},
+			"//#lint": {}
+		}
+	}`)
+	writeFile("apps/web/package.json", `{"name": "web", "dependencies": {"ui": "*"}, "scripts": {"build": "next build", "dev": "next dev", "start": "next start"}}`)
+	writeFile("apps/docs/package.json", `{"name": "docs", "scripts": {"build": "next build", "dev": "next dev", "typecheck": "tsc"}}`)
+	writeFile("packages/ui/package.json", `{"name": "ui", "scripts": {"build": "tsc", "dev": "tsc --watch"}}`)
+	return &cmdutil.CmdBase{
+		UI:       cli.NewMockUi(),
+		Logger:   hclog.NewNullLogger(),
+		RepoRoot: repoRoot,
+	}
+}
+
+func TestListWorkspaces(t *testing.T) {
+	base := newTestRepo(t)
+	list, err := listWorkspaces(base, &scope.Opts{})
+	assert.NilError(t, err, "listWorkspaces")
+
+	bytes, err := json.Marshal(list)
+	assert.NilError(t, err, "Marshal")
+	var parsed List
+	assert.NilError(t, json.Unmarshal(bytes, &parsed), "Unmarshal")
+	assert.DeepEqual(t, parsed, List{
+		Workspaces: []Workspace{
+			{
+				Name:    "//",
+				Path:    ".",
+				Scripts: map[string]string{"lint": "eslint ."},
+				Tasks:   []string{"lint"},
+			},
+			{
+				Name:    "docs",
+				Path:    "apps/docs",
+				Scripts: map[string]string{"build": "next build", "dev": "next dev", "typecheck": "tsc"},
+				Tasks:   []string{"build", "dev", "typecheck"},
+			},
+			{
+				Name:    "ui",
+				Path:    "packages/ui",
+				Scripts: map[string]string{"build": "tsc", "dev": "tsc --watch"},
+				Tasks:   []string{"build", "dev"},
+			},
+			{
+				Name:    "web",
+				Path:    "apps/web",
+				Scripts: map[string]string{"build": "next build", "dev": "next dev", "start": "next start"},
+				Tasks:   []string{"build", "dev"},
+			},
+		},
+	})
+}
+
+func TestListWorkspacesWithFilter(t *testing.T) {
+	base := newTestRepo(t)
+	list, err := listWorkspaces(base, &scope.Opts{FilterPatterns: []string{"web..."}})
+	assert.NilError(t, err, "listWorkspaces")
+
+	names := []string{}
+	for _, workspace := range list.Workspaces {
+		names = append(names, workspace.Name)
+	}
+	assert.DeepEqual(t, names, []string{"ui", "web"})
+
+	list, err = listWorkspaces(base, &scope.Opts{FilterPatterns: []string{"./apps/*"}})
+	assert.NilError(t, err, "listWorkspaces")
+	names = []string{}
+	for _, workspace := range list.Workspaces {
+		names = append(names, workspace.Name)
+	}
+	assert.DeepEqual(t, names, []string{"docs", "web"})
+}
@@ -17,6 +17,13 @@ type PackageTask struct {
 	PackageName    string
 	Pkg            *fs.PackageJSON
 	TaskDefinition *fs.TaskDefinition
+	// Shell is the shell the package manager should use to run this task's script.
+	// If empty, the package manager's default is used.
+	Shell string
+	// DependencyOutputs are the sorted repo-relative globs for the outputs of this task's
+	// dependencies, populated if the task uses its dependencies' outputs as inputs. The
+	// globs are hashed along with the dependencies' hashes, rather than the files they match.
+	DependencyOutputs []string
 }
 
 // Command returns the script for this task from package.json and a boolean indicating
@@ -40,14 +47,36 @@ func (pt *PackageTask) RepoRelativeLogFile() string {
 	return filepath.Join(pt.Pkg.Dir.ToStringDuringMigration(), ".turbo", fmt.Sprintf("turbo-%v.log", pt.Task))
 }
 
+// RepoRelativeReadyFile returns the path to the file a task with streaming outputs
+// creates to signal that it is ready for its dependents, as a relative path from
+// the root of the monorepo.
+func (pt *PackageTask) RepoRelativeReadyFile() string {
+	return filepath.Join(pt.Pkg.Dir.ToStringDuringMigration(), ".turbo", fmt.Sprintf("turbo-%v.ready", pt.Task))
+}
+
+// RepoRelativeCachedAtFile returns the path to the file recording when this task's
+// outputs were cached, for tasks with a cache TTL, as a relative path from the root
+// of the monorepo.
+func (pt *PackageTask) RepoRelativeCachedAtFile() string {
+	return filepath.Join(pt.Pkg.Dir.ToStringDuringMigration(), ".turbo", fmt.Sprintf("turbo-%v.cached-at", pt.Task))
+}
+
 // HashableOutputs returns the package-relative globs for files to be considered outputs
 // of this task
 func (pt *PackageTask) HashableOutputs() fs.TaskOutputs {
 	inclusionOutputs := []string{fmt.Sprintf(".turbo/turbo-%v.log", pt.Task)}
+	if pt.TaskDefinition.CacheTTL > 0 {
+		inclusionOutputs = append(inclusionOutputs, fmt.Sprintf(".turbo/turbo-%v.cached-at", pt.Task))
+	}
+	var ordered []string
+	if len(pt.TaskDefinition.Outputs.Ordered) > 0 {
+		ordered = append(append([]string{}, inclusionOutputs...), pt.TaskDefinition.Outputs.Ordered...)
+	}
 	inclusionOutputs = append(inclusionOutputs, pt.TaskDefinition.Outputs.Inclusions...)
 
 	return fs.TaskOutputs{
 		Inclusions: inclusionOutputs,
 		Exclusions: pt.TaskDefinition.Outputs.Exclusions,
+		Ordered:    ordered,
 	}
 }
@@ -7,6 +7,7 @@ package packagemanager
 import (
 	"errors"
 	"fmt"
+	"os/exec"
 	"path/filepath"
 	"regexp"
 	"strings"
@@ -134,6 +135,30 @@ func detectPackageManager(projectDirectory turbopath.AbsoluteSystemPath) (packag
 	return nil, errors.New(util.Sprintf("We did not detect an in-use package manager for your project. Please set the \"packageManager\" property in your root package.json (${UNDERLINE}https://nodejs.org/api/packages.html#packagemanager)${RESET} or run `npx @turbo/codemod add-package-manager` in the root of your monorepo."))
 }
 
+// ScriptArgs returns the arguments to the Package Manager's Command that run the
+// given script, passing the given arguments through to it
+func (pm PackageManager) ScriptArgs(script string, passThroughArgs []string) []string {
+	args := []string{"run", script}
+	if len(passThroughArgs) > 0 {
+		// This will be either '--' or a typed nil
+		args = append(args, pm.ArgSeparator...)
+		args = append(args, passThroughArgs...)
+	}
+	return args
+}
+
+// InstalledVersion returns the version of the Package Manager that runs when
+// it is invoked in the given directory
+func (pm PackageManager) InstalledVersion(dir turbopath.AbsoluteSystemPath) (string, error) {
+	cmd := exec.Command(pm.Command, "--version")
+	cmd.Dir = dir.ToString()
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("could not detect %v version: %w", pm.Command, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
 // GetWorkspaces returns the list of package.json files for the current repository.
 func (pm PackageManager) GetWorkspaces(rootpath turbopath.AbsoluteSystemPath) ([]string, error) {
 	globs, err := pm.getWorkspaceGlobs(rootpath)
@@ -403,3 +403,35 @@ func Test_CanPrune(t *testing.T) {
 		})
 	}
 }
+
+func TestScriptArgs(t *testing.T) {
+	tests := []struct {
+		name            string
+		packageManager  PackageManager
+		passThroughArgs []string
+		want            []string
+	}{
+		{
+			name:           "npm without arguments",
+			packageManager: nodejsNpm,
+			want:           []string{"run", "build"},
+		},
+		{
+			name:            "npm with arguments",
+			packageManager:  nodejsNpm,
+			passThroughArgs: []string{"--watch"},
+			want:            []string{"run", "build", "--", "--watch"},
+		},
+		{
+			name:            "pnpm with arguments",
+			packageManager:  nodejsPnpm,
+			passThroughArgs: []string{"--watch"},
+			want:            []string{"run", "build", "--watch"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.DeepEqual(t, tt.packageManager.ScriptArgs("build", tt.passThroughArgs), tt.want)
+		})
+	}
+}
@@ -329,6 +329,27 @@ func (c *Child) signal(s os.Signal) error {
 	return p.Signal(sig)
 }
 
+// signalGroup sends the signal to the child's process group. Unlike signal, it
+// doesn't check whether the child is running first, which would consume its
+// exit code from the exit channel. Signaling a group that has exited fails
+// harmlessly.
+func (c *Child) signalGroup(s syscall.Signal) error {
+	c.RLock()
+	defer c.RUnlock()
+	if c.cmd == nil || c.cmd.Process == nil {
+		return nil
+	}
+	pid := c.cmd.Process.Pid
+	if c.setpgid {
+		pid = -(pid)
+	}
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return p.Signal(s)
+}
+
 // kill sends the signal to kill the process using the configured signal
 // if set, else the default system signal
 func (c *Child) kill(immediately bool) {
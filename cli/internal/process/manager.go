@@ -1,11 +1,13 @@
 package process
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/hashicorp/go-hclog"
@@ -26,6 +28,17 @@ func (ce *ChildExit) Error() string {
 	return fmt.Sprintf("command %s exited (%d)", ce.Command, ce.ExitCode)
 }
 
+// ChildTimeout is returned when a child process is stopped for running longer
+// than its timeout
+type ChildTimeout struct {
+	Timeout time.Duration
+	Command string
+}
+
+func (ct *ChildTimeout) Error() string {
+	return fmt.Sprintf("command %s timed out after %v", ct.Command, ct.Timeout)
+}
+
 // Manager tracks all of the child processes that have been spawned
 type Manager struct {
 	done     bool
@@ -49,6 +62,20 @@ func NewManager(logger hclog.Logger) *Manager {
 // successfully, ErrClosing if the manager closed during execution, and
 // a ChildExit error if the child process exited with a non-zero exit code.
 func (m *Manager) Exec(cmd *exec.Cmd) error {
+	return m.ExecWithTimeout(cmd, 0, 0)
+}
+
+// ExecWithTimeout behaves like Exec, but stops the child process if it runs for
+// longer than timeout. Its process group is sent SIGTERM, then SIGKILL if it
+// hasn't exited after gracePeriod, and a ChildTimeout error is returned. A
+// timeout of 0 lets the child process run forever.
+func (m *Manager) ExecWithTimeout(cmd *exec.Cmd, timeout time.Duration, gracePeriod time.Duration) error {
+	return m.ExecContext(context.Background(), cmd, timeout, gracePeriod)
+}
+
+// ExecContext behaves like ExecWithTimeout, but also stops the child process,
+// in the same way, once ctx is done, returning ctx's error
+func (m *Manager) ExecContext(ctx context.Context, cmd *exec.Cmd, timeout time.Duration, gracePeriod time.Duration) error {
 	m.mu.Lock()
 	if m.done {
 		m.mu.Unlock()
@@ -79,9 +106,34 @@ func (m *Manager) Exec(cmd *exec.Cmd) error {
 		return err
 	}
 	err = nil
-	exitCode, ok := <-child.ExitCh()
+	var timeoutCh <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		timeoutCh = timer.C
+	}
+	timedOut := false
+	stopped := false
+	var exitCode int
+	var ok bool
+	select {
+	case exitCode, ok = <-child.ExitCh():
+	case <-timeoutCh:
+		timedOut = true
+		exitCode, ok = m.terminate(child, gracePeriod)
+	case <-ctx.Done():
+		stopped = true
+		exitCode, ok = m.terminate(child, gracePeriod)
+	}
 	if !ok {
 		err = ErrClosing
+	} else if stopped {
+		err = ctx.Err()
+	} else if timedOut {
+		err = &ChildTimeout{
+			Timeout: timeout,
+			Command: child.Command(),
+		}
 	} else if exitCode != ExitCodeOK {
 		err = &ChildExit{
 			ExitCode: exitCode,
@@ -95,6 +147,26 @@ func (m *Manager) Exec(cmd *exec.Cmd) error {
 	return err
 }
 
+// terminate sends SIGTERM to the child's process group, then SIGKILL if it
+// doesn't exit within gracePeriod, and waits for it to exit
+func (m *Manager) terminate(child *Child, gracePeriod time.Duration) (int, bool) {
+	if err := child.signalGroup(syscall.SIGTERM); err != nil {
+		m.logger.Debug("failed to send SIGTERM", "error", err)
+	}
+	grace := time.NewTimer(gracePeriod)
+	defer grace.Stop()
+	select {
+	case exitCode, ok := <-child.ExitCh():
+		return exitCode, ok
+	case <-grace.C:
+	}
+	if err := child.signalGroup(syscall.SIGKILL); err != nil {
+		m.logger.Debug("failed to send SIGKILL", "error", err)
+	}
+	exitCode, ok := <-child.ExitCh()
+	return exitCode, ok
+}
+
 // Close sends SIGINT to all child processes if it hasn't been done yet,
 // and in either case blocks until they all exit or timeout
 func (m *Manager) Close() {
@@ -0,0 +1,82 @@
+//go:build !windows
+// +build !windows
+
+package process
+
+/**
+ * Tests in this file use signals or pgid features not available on windows
+ */
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestExecWithTimeout_finishes(t *testing.T) {
+	mgr := newManager()
+
+	err := mgr.ExecWithTimeout(exec.Command("sleep", "0.05"), 5*time.Second, time.Second)
+	if err != nil {
+		t.Errorf("expected %q to be nil", err)
+	}
+}
+
+func TestExecContext_stops(t *testing.T) {
+	mgr := newManager()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	start := time.Now()
+	err := mgr.ExecContext(ctx, exec.Command("sh", "-c", "sleep 10"), 0, 5*time.Second)
+	duration := time.Since(start)
+
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the context's error, found %q", err)
+	}
+	if duration >= 5*time.Second {
+		t.Errorf("expected SIGTERM to stop the process group, total time was %q", duration)
+	}
+}
+
+func TestExecWithTimeout_terminates(t *testing.T) {
+	mgr := newManager()
+
+	start := time.Now()
+	err := mgr.ExecWithTimeout(exec.Command("sh", "-c", "sleep 10"), 100*time.Millisecond, 5*time.Second)
+	duration := time.Since(start)
+
+	timeoutErr := &ChildTimeout{}
+	if !errors.As(err, &timeoutErr) {
+		t.Fatalf("expected a timeout error, found %q", err)
+	}
+	if timeoutErr.Timeout != 100*time.Millisecond {
+		t.Errorf("expected timeout of 100ms, found %v", timeoutErr.Timeout)
+	}
+	// SIGTERM stops both sh and sleep, without waiting out the grace period
+	if duration >= 5*time.Second {
+		t.Errorf("expected SIGTERM to stop the process group, total time was %q", duration)
+	}
+}
+
+func TestExecWithTimeout_kills(t *testing.T) {
+	mgr := newManager()
+
+	start := time.Now()
+	// Both sh and sleep inherit ignoring SIGTERM, so only SIGKILL stops them
+	err := mgr.ExecWithTimeout(exec.Command("sh", "-c", "trap '' TERM; sleep 10"), 100*time.Millisecond, 200*time.Millisecond)
+	duration := time.Since(start)
+
+	timeoutErr := &ChildTimeout{}
+	if !errors.As(err, &timeoutErr) {
+		t.Fatalf("expected a timeout error, found %q", err)
+	}
+	if duration < 300*time.Millisecond {
+		t.Errorf("expected to wait out the grace period, total time was %q", duration)
+	}
+	if duration >= 5*time.Second {
+		t.Errorf("expected SIGKILL to stop the process group, total time was %q", duration)
+	}
+}
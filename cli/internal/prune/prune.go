@@ -3,6 +3,8 @@ package prune
 import (
 	"bufio"
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
@@ -11,21 +13,25 @@ import (
 	"github.com/vercel/turbo/cli/internal/fs"
 	"github.com/vercel/turbo/cli/internal/turbopath"
 	"github.com/vercel/turbo/cli/internal/ui"
+	"github.com/vercel/turbo/cli/internal/util"
 
 	"github.com/fatih/color"
 	"github.com/hashicorp/go-hclog"
 	"github.com/mitchellh/cli"
 	"github.com/pkg/errors"
+	"github.com/pyr-sh/dag"
 )
 
 type opts struct {
-	scope     string
+	scope string
+	// targets are the packages to act as entry points, from the arguments and --scope
+	targets   []string
 	docker    bool
 	outputDir string
 }
 
 func addPruneFlags(opts *opts, flags *pflag.FlagSet) {
-	flags.StringVar(&opts.scope, "scope", "", "Specify package to act as entry point for pruned monorepo (required).")
+	flags.StringVar(&opts.scope, "scope", "", "Specify package to act as entry point for pruned monorepo. Packages can also be given as arguments.")
 	flags.BoolVar(&opts.docker, "docker", false, "Output pruned workspace into 'full' and 'json' directories optimized for Docker layer caching.")
 	flags.StringVar(&opts.outputDir, "out-dir", "out", "Set the root directory for files output by this command")
 	// No-op the cwd flag while the root level command is not yet cobra
@@ -40,7 +46,7 @@ func addPruneFlags(opts *opts, flags *pflag.FlagSet) {
 func GetCmd(helper *cmdutil.Helper) *cobra.Command {
 	opts := &opts{}
 	cmd := &cobra.Command{
-		Use:                   "prune --scope=<package name> [<flags>]",
+		Use:                   "prune [<package name>...] [<flags>]",
 		Short:                 "Prepare a subset of your monorepo.",
 		SilenceUsage:          true,
 		SilenceErrors:         true,
@@ -50,7 +56,11 @@ func GetCmd(helper *cmdutil.Helper) *cobra.Command {
 			if err != nil {
 				return err
 			}
-			if opts.scope == "" {
+			opts.targets = append([]string{}, args...)
+			if opts.scope != "" {
+				opts.targets = append(opts.targets, opts.scope)
+			}
+			if len(opts.targets) == 0 {
 				err := errors.New("at least one target must be specified")
 				base.LogError(err.Error())
 				return err
@@ -90,10 +100,10 @@ func (p *prune) prune(opts *opts) error {
 	if err != nil {
 		return errors.Wrap(err, "could not construct graph")
 	}
-	p.base.Logger.Trace("scope", "value", opts.scope)
-	target, scopeIsValid := ctx.PackageInfos[opts.scope]
-	if !scopeIsValid {
-		return errors.Errorf("invalid scope: package %v not found", opts.scope)
+	p.base.Logger.Trace("targets", "value", opts.targets)
+	targets, err := prunedPackages(&ctx.TopologicalGraph, ctx.PackageInfos, ctx.RootNode, opts.targets)
+	if err != nil {
+		return err
 	}
 	outDir := p.base.RepoRoot.UntypedJoin(opts.outputDir)
 	fullDir := outDir
@@ -101,10 +111,13 @@ func (p *prune) prune(opts *opts) error {
 		fullDir = fullDir.UntypedJoin("full")
 	}
 
-	p.base.Logger.Trace("target", "value", target.Name)
-	p.base.Logger.Trace("directory", "value", target.Dir)
-	p.base.Logger.Trace("external deps", "value", target.UnresolvedExternalDeps)
-	p.base.Logger.Trace("internal deps", "value", target.InternalDeps)
+	for _, name := range opts.targets {
+		target := ctx.PackageInfos[name]
+		p.base.Logger.Trace("target", "value", target.Name)
+		p.base.Logger.Trace("directory", "value", target.Dir)
+		p.base.Logger.Trace("external deps", "value", target.UnresolvedExternalDeps)
+		p.base.Logger.Trace("internal deps", "value", target.InternalDeps)
+	}
 	p.base.Logger.Trace("docker", "value", opts.docker)
 	p.base.Logger.Trace("out dir", "value", outDir.ToString())
 
@@ -119,7 +132,7 @@ func (p *prune) prune(opts *opts) error {
 		return errors.New("Cannot prune without parsed lockfile")
 	}
 
-	p.base.UI.Output(fmt.Sprintf("Generating pruned monorepo for %v in %v", ui.Bold(opts.scope), ui.Bold(outDir.ToString())))
+	p.base.UI.Output(fmt.Sprintf("Generating pruned monorepo for %v in %v", ui.Bold(strings.Join(opts.targets, ", ")), ui.Bold(outDir.ToString())))
 
 	packageJSONPath := outDir.UntypedJoin("package.json")
 	if err := packageJSONPath.EnsureDir(); err != nil {
@@ -139,21 +152,13 @@ func (p *prune) prune(opts *opts) error {
 			}
 		}
 	}
+	// The root package.json is copied as is, so its workspaces globs still cover
+	// every package that is kept
 	workspaces := []turbopath.AnchoredSystemPath{}
-	targets := []interface{}{opts.scope}
-	internalDeps, err := ctx.TopologicalGraph.Ancestors(opts.scope)
-	if err != nil {
-		return errors.Wrap(err, "could find traverse the dependency graph to find topological dependencies")
-	}
-	targets = append(targets, internalDeps.List()...)
-
 	lockfileKeys := make([]string, 0, len(rootPackageJSON.TransitiveDeps))
 	lockfileKeys = append(lockfileKeys, rootPackageJSON.TransitiveDeps...)
 
 	for _, internalDep := range targets {
-		if internalDep == ctx.RootNode {
-			continue
-		}
 		workspaces = append(workspaces, ctx.PackageInfos[internalDep].Dir)
 		originalDir := ctx.PackageInfos[internalDep].Dir.RestoreAnchor(p.base.RepoRoot)
 		info, err := originalDir.Lstat()
@@ -277,3 +282,27 @@ func (p *prune) prune(opts *opts) error {
 
 	return nil
 }
+
+// prunedPackages returns the given target packages and every package that any
+// of them depends on, directly or transitively, without duplicates, sorted by name
+func prunedPackages(graph *dag.AcyclicGraph, packageInfos map[interface{}]*fs.PackageJSON, rootNode string, targets []string) ([]string, error) {
+	packages := make(util.Set)
+	for _, target := range targets {
+		if _, ok := packageInfos[target]; !ok {
+			return nil, errors.Errorf("invalid scope: package %v not found", target)
+		}
+		packages.Add(target)
+		internalDeps, err := graph.Ancestors(target)
+		if err != nil {
+			return nil, errors.Wrap(err, "could find traverse the dependency graph to find topological dependencies")
+		}
+		for _, dep := range internalDeps.List() {
+			if dep != rootNode {
+				packages.Add(dep)
+			}
+		}
+	}
+	names := packages.UnsafeListOfStrings()
+	sort.Strings(names)
+	return names, nil
+}
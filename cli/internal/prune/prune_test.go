@@ -0,0 +1,75 @@
+package prune
+
+import (
+	"testing"
+
+	"github.com/pyr-sh/dag"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/util"
+	"gotest.tools/v3/assert"
+)
+
+func TestPrunedPackages(t *testing.T) {
+	// app-a and app-b share utils, and each has dependencies of its own
+	deps := map[string][]string{
+		"app-a":      {"ui"},
+		"app-b":      {"api-client", "config"},
+		"app-c":      {"legacy"},
+		"ui":         {"utils"},
+		"api-client": {"utils"},
+		"config":     {},
+		"legacy":     {},
+		"utils":      {},
+	}
+	graph := &dag.AcyclicGraph{}
+	graph.Add(util.RootPkgName)
+	packageInfos := map[interface{}]*fs.PackageJSON{}
+	for pkg, pkgDeps := range deps {
+		graph.Add(pkg)
+		packageInfos[pkg] = &fs.PackageJSON{Name: pkg}
+		for _, dep := range pkgDeps {
+			graph.Add(dep)
+			graph.Connect(dag.BasicEdge(pkg, dep))
+		}
+		if len(pkgDeps) == 0 {
+			graph.Connect(dag.BasicEdge(pkg, util.RootPkgName))
+		}
+	}
+
+	testCases := []struct {
+		name    string
+		targets []string
+		want    []string
+	}{
+		{
+			name:    "single target",
+			targets: []string{"app-a"},
+			want:    []string{"app-a", "ui", "utils"},
+		},
+		{
+			name:    "overlapping dependencies",
+			targets: []string{"app-a", "app-b"},
+			want:    []string{"api-client", "app-a", "app-b", "config", "ui", "utils"},
+		},
+		{
+			name:    "disjoint dependencies",
+			targets: []string{"app-a", "app-c"},
+			want:    []string{"app-a", "app-c", "legacy", "ui", "utils"},
+		},
+		{
+			name:    "target that another target depends on",
+			targets: []string{"ui", "app-a", "app-a"},
+			want:    []string{"app-a", "ui", "utils"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := prunedPackages(graph, packageInfos, util.RootPkgName, tc.targets)
+			assert.NilError(t, err)
+			assert.DeepEqual(t, got, tc.want)
+		})
+	}
+
+	_, err := prunedPackages(graph, packageInfos, util.RootPkgName, []string{"app-a", "missing"})
+	assert.ErrorContains(t, err, "package missing not found")
+}
@@ -0,0 +1,161 @@
+package run
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pyr-sh/dag"
+	"github.com/vercel/turbo/cli/internal/core"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+// checkpoint persists the tasks that have completed during a run, so that a run
+// that is interrupted can be resumed without repeating them. A nil *checkpoint
+// records nothing.
+type checkpoint struct {
+	path turbopath.AbsoluteSystemPath
+	mu   sync.Mutex
+	// previous holds the tasks completed by the run being resumed, if any
+	previous map[string]checkpointEntry
+	contents checkpointContents
+}
+
+type checkpointContents struct {
+	// GraphHash fingerprints the task graph, so that a checkpoint is only
+	// resumed by a run of the same tasks
+	GraphHash string                     `json:"graphHash"`
+	Tasks     map[string]checkpointEntry `json:"tasks"`
+}
+
+type checkpointEntry struct {
+	Hash   string          `json:"hash"`
+	Status RunResultStatus `json:"status"`
+}
+
+// getCheckpointPath returns the path to the checkpoint for the given repository
+func getCheckpointPath(repoRoot turbopath.AbsoluteSystemPath) turbopath.AbsoluteSystemPath {
+	return repoRoot.UntypedJoin(".turbo", "checkpoint.json")
+}
+
+// newCheckpoint starts a checkpoint for a run of the task graph with the given hash.
+// If resume is set, tasks completed by the previous run of the same graph can be
+// skipped. It returns a warning if there was a checkpoint that can't be resumed.
+func newCheckpoint(path turbopath.AbsoluteSystemPath, graphHash string, resume bool) (*checkpoint, string) {
+	c := &checkpoint{
+		path:     path,
+		previous: make(map[string]checkpointEntry),
+		contents: checkpointContents{
+			GraphHash: graphHash,
+			Tasks:     make(map[string]checkpointEntry),
+		},
+	}
+	if !resume {
+		return c, ""
+	}
+	contents, err := path.ReadFile()
+	if err != nil {
+		return c, "no checkpoint found, running all tasks"
+	}
+	previous := checkpointContents{}
+	if err := json.Unmarshal(contents, &previous); err != nil {
+		return c, fmt.Sprintf("ignoring invalid checkpoint: %v", err)
+	}
+	if previous.GraphHash != graphHash {
+		return c, "the task graph has changed since the checkpoint was written, running all tasks"
+	}
+	if previous.Tasks != nil {
+		c.previous = previous.Tasks
+	}
+	return c, ""
+}
+
+// completed returns true if the resumed run completed the task with the same hash
+func (c *checkpoint) completed(taskID string, hash string) bool {
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.previous[taskID]
+	return ok && entry.Hash == hash
+}
+
+// record adds a task to the checkpoint if it completed successfully, and saves the
+// checkpoint, so that the task isn't repeated however the run is interrupted. Saving
+// is a small write and a rename, which is cheap next to running a task.
+func (c *checkpoint) record(taskID string, hash string, status RunResultStatus) error {
+	if c == nil {
+		return nil
+	}
+	switch status {
+	case TargetBuilt, TargetCached, TargetFresh, TargetResumed:
+	default:
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.contents.Tasks[taskID] = checkpointEntry{Hash: hash, Status: status}
+	return c.saveLocked()
+}
+
+// save writes the checkpoint to disk
+func (c *checkpoint) save() error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.saveLocked()
+}
+
+func (c *checkpoint) saveLocked() error {
+	contents, err := json.Marshal(c.contents)
+	if err != nil {
+		return err
+	}
+	if err := c.path.EnsureDir(); err != nil {
+		return err
+	}
+	// Write to a temporary file first, so that an interruption while saving
+	// doesn't leave a partial checkpoint behind
+	tmpPath := c.path.Dir().UntypedJoin(c.path.Base() + ".tmp")
+	if err := tmpPath.WriteFile(contents, 0644); err != nil {
+		return err
+	}
+	return tmpPath.Rename(c.path)
+}
+
+// remove deletes the checkpoint, once there is nothing left to resume
+func (c *checkpoint) remove() error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.path.Remove(); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// taskGraphHash fingerprints the tasks in the engine's TaskGraph and the
+// dependencies between them
+func taskGraphHash(engine *core.Engine) (string, error) {
+	tasks := []string{}
+	for _, v := range engine.TaskGraph.Vertices() {
+		taskID := dag.VertexName(v)
+		deps := []string{}
+		for _, dep := range engine.TaskGraph.DownEdges(taskID) {
+			deps = append(deps, dag.VertexName(dep))
+		}
+		sort.Strings(deps)
+		tasks = append(tasks, fmt.Sprintf("%v:%v", taskID, strings.Join(deps, ",")))
+	}
+	sort.Strings(tasks)
+	return fs.HashObject(tasks)
+}
@@ -0,0 +1,75 @@
+package run
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vercel/turbo/cli/internal/fs"
+)
+
+func TestCheckpointResume(t *testing.T) {
+	path := fs.AbsoluteSystemPathFromUpstream(t.TempDir()).UntypedJoin(".turbo", "checkpoint.json")
+
+	c, warning := newCheckpoint(path, "graph-hash", false)
+	assert.Equal(t, "", warning)
+	assert.NoError(t, c.record("web#build", "web-hash", TargetBuilt))
+	assert.NoError(t, c.record("docs#build", "docs-hash", TargetCached))
+	assert.NoError(t, c.record("web#deploy", "deploy-hash", TargetBuildFailed))
+	assert.NoError(t, c.save())
+
+	c, warning = newCheckpoint(path, "graph-hash", true)
+	assert.Equal(t, "", warning)
+	assert.True(t, c.completed("web#build", "web-hash"))
+	assert.True(t, c.completed("docs#build", "docs-hash"))
+	// Failed tasks aren't completed, and tasks whose hash changed need to run again
+	assert.False(t, c.completed("web#deploy", "deploy-hash"))
+	assert.False(t, c.completed("web#build", "other-hash"))
+
+	// Without resuming, nothing is completed
+	c, warning = newCheckpoint(path, "graph-hash", false)
+	assert.Equal(t, "", warning)
+	assert.False(t, c.completed("web#build", "web-hash"))
+}
+
+func TestCheckpointSavesEveryTask(t *testing.T) {
+	path := fs.AbsoluteSystemPathFromUpstream(t.TempDir()).UntypedJoin(".turbo", "checkpoint.json")
+
+	// Quick tasks complete, and then the run is interrupted without saving
+	c, _ := newCheckpoint(path, "graph-hash", false)
+	for _, pkg := range []string{"a", "b", "c"} {
+		assert.NoError(t, c.record(pkg+"#lint", pkg+"-hash", TargetBuilt))
+	}
+
+	c, warning := newCheckpoint(path, "graph-hash", true)
+	assert.Equal(t, "", warning)
+	for _, pkg := range []string{"a", "b", "c"} {
+		assert.True(t, c.completed(pkg+"#lint", pkg+"-hash"), "%v#lint wasn't saved", pkg)
+	}
+}
+
+func TestCheckpointGraphChanged(t *testing.T) {
+	path := fs.AbsoluteSystemPathFromUpstream(t.TempDir()).UntypedJoin(".turbo", "checkpoint.json")
+
+	c, _ := newCheckpoint(path, "graph-hash", false)
+	assert.NoError(t, c.record("web#build", "web-hash", TargetBuilt))
+	assert.NoError(t, c.save())
+
+	c, warning := newCheckpoint(path, "other-graph-hash", true)
+	assert.Equal(t, "the task graph has changed since the checkpoint was written, running all tasks", warning)
+	assert.False(t, c.completed("web#build", "web-hash"))
+}
+
+func TestCheckpointRemove(t *testing.T) {
+	path := fs.AbsoluteSystemPathFromUpstream(t.TempDir()).UntypedJoin(".turbo", "checkpoint.json")
+
+	c, _ := newCheckpoint(path, "graph-hash", false)
+	assert.NoError(t, c.record("web#build", "web-hash", TargetBuilt))
+	assert.True(t, path.FileExists())
+	assert.NoError(t, c.remove())
+	assert.False(t, path.FileExists())
+	// Removing a checkpoint that doesn't exist is fine
+	assert.NoError(t, c.remove())
+
+	_, warning := newCheckpoint(path, "graph-hash", true)
+	assert.Equal(t, "no checkpoint found, running all tasks", warning)
+}
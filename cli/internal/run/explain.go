@@ -0,0 +1,30 @@
+package run
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mitchellh/cli"
+	"github.com/vercel/turbo/cli/internal/core"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// displayDecisions prints the timeline of scheduling decisions made during a run,
+// relative to when the run started
+func displayDecisions(ui cli.Ui, decisions []core.SchedulingDecision, startAt time.Time) {
+	ui.Output("")
+	ui.Info(util.Sprintf("${CYAN}${BOLD}Scheduling Decisions${RESET}"))
+	for _, decision := range decisions {
+		ui.Output(formatDecision(decision, startAt))
+	}
+	ui.Output("")
+}
+
+func formatDecision(decision core.SchedulingDecision, startAt time.Time) string {
+	offset := decision.Time.Sub(startAt).Truncate(time.Millisecond)
+	line := util.Sprintf("${GREY}+%v${RESET} ${BOLD}%v${RESET} %v", offset, decision.TaskID, decision.Kind)
+	if decision.Reason != "" {
+		line += fmt.Sprintf(": %v", decision.Reason)
+	}
+	return line
+}
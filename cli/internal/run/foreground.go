@@ -0,0 +1,68 @@
+package run
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// terminal serializes the output of tasks to stdout, and lets a foreground task
+// own the terminal while it runs. Output from other tasks is held back until the
+// foreground task finishes, so that it isn't buried while the task interacts
+// with the user.
+type terminal struct {
+	w io.Writer
+	// foreground is held by the foreground task that owns the terminal, so that
+	// foreground tasks take turns
+	foreground sync.Mutex
+	mu         sync.Mutex
+	owned      bool
+	buffered   bytes.Buffer
+}
+
+func newTerminal(w io.Writer) *terminal {
+	return &terminal{w: w}
+}
+
+// acquire waits for any other foreground task to finish, then gives the
+// terminal to the caller. The returned function gives it back, and writes
+// the output that was held back in the meantime.
+func (t *terminal) acquire() (release func() error) {
+	t.foreground.Lock()
+	t.mu.Lock()
+	t.owned = true
+	t.mu.Unlock()
+	return func() error {
+		defer t.foreground.Unlock()
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		t.owned = false
+		_, err := t.buffered.WriteTo(t.w)
+		return err
+	}
+}
+
+// backgroundWriter returns a writer for a task that doesn't own the terminal.
+// Its output is held back while a foreground task owns the terminal.
+func (t *terminal) backgroundWriter() io.Writer {
+	return &terminalWriter{t: t}
+}
+
+// foregroundWriter returns a writer for the task that owns the terminal
+func (t *terminal) foregroundWriter() io.Writer {
+	return &terminalWriter{t: t, foreground: true}
+}
+
+type terminalWriter struct {
+	t          *terminal
+	foreground bool
+}
+
+func (tw *terminalWriter) Write(p []byte) (int, error) {
+	tw.t.mu.Lock()
+	defer tw.t.mu.Unlock()
+	if tw.t.owned && !tw.foreground {
+		return tw.t.buffered.Write(p)
+	}
+	return tw.t.w.Write(p)
+}
@@ -0,0 +1,55 @@
+package run
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTerminalHoldsBackgroundOutput(t *testing.T) {
+	out := &bytes.Buffer{}
+	term := newTerminal(out)
+	background := term.backgroundWriter()
+	foreground := term.foregroundWriter()
+
+	_, err := io.WriteString(background, "building\n")
+	assert.NoError(t, err)
+	assert.Equal(t, "building\n", out.String())
+
+	release := term.acquire()
+	_, err = io.WriteString(background, "still building\n")
+	assert.NoError(t, err)
+	_, err = io.WriteString(foreground, "Approve? [y/N] ")
+	assert.NoError(t, err)
+	// Only the foreground task's output is shown while it owns the terminal
+	assert.Equal(t, "building\nApprove? [y/N] ", out.String())
+
+	assert.NoError(t, release())
+	assert.Equal(t, "building\nApprove? [y/N] still building\n", out.String())
+
+	_, err = io.WriteString(background, "done\n")
+	assert.NoError(t, err)
+	assert.Equal(t, "building\nApprove? [y/N] still building\ndone\n", out.String())
+}
+
+func TestTerminalForegroundTasksTakeTurns(t *testing.T) {
+	term := newTerminal(&bytes.Buffer{})
+	release := term.acquire()
+
+	acquired := make(chan struct{})
+	go func() {
+		releaseNext := term.acquire()
+		close(acquired)
+		_ = releaseNext()
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("terminal acquired while another foreground task owns it")
+	default:
+	}
+	assert.NoError(t, release())
+	<-acquired
+}
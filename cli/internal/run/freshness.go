@@ -0,0 +1,38 @@
+package run
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+// outputIsNewer returns true if the package-relative output file exists and was
+// modified more recently than every one of the package-relative input files.
+// The output itself is not considered one of the inputs.
+func outputIsNewer(pkgDir turbopath.AbsoluteSystemPath, output string, inputs []turbopath.AnchoredUnixPath) (bool, error) {
+	outputInfo, err := os.Stat(pkgDir.UntypedJoin(output).ToString())
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	outputPath := filepath.Clean(output)
+	for _, input := range inputs {
+		inputPath := input.ToSystemPath().ToString()
+		if filepath.Clean(inputPath) == outputPath {
+			continue
+		}
+		inputInfo, err := os.Stat(pkgDir.UntypedJoin(inputPath).ToString())
+		if os.IsNotExist(err) {
+			// Deleted inputs can't be newer than the output
+			continue
+		} else if err != nil {
+			return false, err
+		}
+		if !outputInfo.ModTime().After(inputInfo.ModTime()) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,40 @@
+package run
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+func TestOutputIsNewer(t *testing.T) {
+	pkgDir := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	now := time.Now()
+	writeFile := func(name string, modTime time.Time) {
+		path := filepath.Join(pkgDir.ToString(), name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(t, os.WriteFile(path, []byte(name), 0644))
+		assert.NoError(t, os.Chtimes(path, modTime, modTime))
+	}
+	writeFile("src/index.ts", now.Add(-2*time.Hour))
+	writeFile("src/util.ts", now.Add(-time.Hour))
+	writeFile("dist/index.js", now)
+	inputs := []turbopath.AnchoredUnixPath{"src/index.ts", "src/util.ts", "src/deleted.ts", "dist/index.js"}
+
+	fresh, err := outputIsNewer(pkgDir, "dist/index.js", inputs)
+	assert.NoError(t, err)
+	assert.True(t, fresh, "output is newer than all inputs")
+
+	writeFile("src/util.ts", now.Add(time.Hour))
+	fresh, err = outputIsNewer(pkgDir, "dist/index.js", inputs)
+	assert.NoError(t, err)
+	assert.False(t, fresh, "an input is newer than the output")
+
+	fresh, err = outputIsNewer(pkgDir, "dist/missing.js", inputs)
+	assert.NoError(t, err)
+	assert.False(t, fresh, "missing outputs are never fresh")
+}
@@ -1,8 +1,19 @@
 package run
 
 import (
+	"fmt"
+	"path/filepath"
 	"reflect"
 	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/pyr-sh/dag"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/nodes"
+	"github.com/vercel/turbo/cli/internal/packagemanager"
+	"github.com/vercel/turbo/cli/internal/scm"
+	"github.com/vercel/turbo/cli/internal/taskhash"
+	"github.com/vercel/turbo/cli/internal/turbopath"
 )
 
 func Test_getHashableTurboEnvVarsFromOs(t *testing.T) {
@@ -24,3 +35,153 @@ func Test_getHashableTurboEnvVarsFromOs(t *testing.T) {
 		t.Errorf("getHashableTurboEnvVarsFromOs() env pairs got = %v, want %v", gotPairs, wantPairs)
 	}
 }
+
+// writeTestRepo writes the given files, keyed by their repo-relative unix paths, to
+// repoRoot.
+func writeTestRepo(t *testing.T, repoRoot turbopath.AbsoluteSystemPath, files map[string]string) {
+	t.Helper()
+	for file, contents := range files {
+		path := repoRoot.UntypedJoin(filepath.FromSlash(file))
+		if err := path.EnsureDir(); err != nil {
+			t.Fatalf("EnsureDir: %v", err)
+		}
+		if err := path.WriteFile([]byte(contents), 0644); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+	}
+}
+
+// readTestRepo reads the root package.json of a repo written by writeTestRepo, and
+// detects its package manager.
+func readTestRepo(t *testing.T, repoRoot turbopath.AbsoluteSystemPath) (*fs.PackageJSON, *packagemanager.PackageManager) {
+	t.Helper()
+	rootPackageJSON, err := fs.ReadPackageJSON(repoRoot.UntypedJoin("package.json"))
+	if err != nil {
+		t.Fatalf("ReadPackageJSON: %v", err)
+	}
+	packageManager, err := packagemanager.GetPackageManager(repoRoot, rootPackageJSON)
+	if err != nil {
+		t.Fatalf("GetPackageManager: %v", err)
+	}
+	return rootPackageJSON, packageManager
+}
+
+// buildTaskHashes returns the hash of the build task of each of the given packages,
+// keyed by task ID.
+func buildTaskHashes(t *testing.T, repoRoot turbopath.AbsoluteSystemPath, globalHash string, pipeline fs.Pipeline, packageInfos map[interface{}]*fs.PackageJSON, gitMetadata scm.Metadata) map[string]string {
+	t.Helper()
+	tracker := taskhash.NewTracker("___ROOT___", globalHash, pipeline, packageInfos, nil, gitMetadata)
+	var taskIDs []dag.Vertex
+	for pkg := range packageInfos {
+		taskIDs = append(taskIDs, fmt.Sprintf("%v#build", pkg))
+	}
+	if err := tracker.CalculateFileHashes(taskIDs, 1, repoRoot); err != nil {
+		t.Fatalf("CalculateFileHashes: %v", err)
+	}
+	hashes := make(map[string]string)
+	for name, pkg := range packageInfos {
+		taskDefinition := pipeline["build"]
+		taskID := fmt.Sprintf("%v#build", name)
+		hash, err := tracker.CalculateTaskHash(&nodes.PackageTask{
+			TaskID:         taskID,
+			Task:           "build",
+			PackageName:    pkg.Name,
+			Pkg:            pkg,
+			TaskDefinition: &taskDefinition,
+		}, dag.Set{}, hclog.NewNullLogger(), nil)
+		if err != nil {
+			t.Fatalf("CalculateTaskHash: %v", err)
+		}
+		hashes[taskID] = hash
+	}
+	return hashes
+}
+
+func Test_globalDependenciesChangeEveryTaskHash(t *testing.T) {
+	repoRoot := turbopath.AbsoluteSystemPathFromUpstream(t.TempDir())
+	writeTestRepo(t, repoRoot, map[string]string{
+		"package.json":             `{"name": "root", "packageManager": "npm@8.19.2", "workspaces": ["apps/*", "packages/*"]}`,
+		"package-lock.json":        "{}",
+		"tsconfig.base.json":       `{"compilerOptions": {"strict": true}}`,
+		".tool-versions":           "nodejs 18.12.0",
+		"README.md":                "# repo",
+		"apps/web/package.json":    `{"name": "web"}`,
+		"packages/ui/package.json": `{"name": "ui"}`,
+	})
+	rootPackageJSON, packageManager := readTestRepo(t, repoRoot)
+	packageInfos := map[interface{}]*fs.PackageJSON{
+		"web": {Name: "web", Dir: turbopath.AnchoredUnixPath("apps/web").ToSystemPath()},
+		"ui":  {Name: "ui", Dir: turbopath.AnchoredUnixPath("packages/ui").ToSystemPath()},
+	}
+	pipeline := fs.Pipeline{"build": fs.TaskDefinition{ShouldCache: true}}
+
+	taskHashes := func() map[string]string {
+		t.Helper()
+		globalHash, err := calculateGlobalHash(repoRoot, rootPackageJSON, pipeline, nil, []string{"tsconfig.base.json", ".tool-versions"}, packageManager, nil, hclog.NewNullLogger(), nil)
+		if err != nil {
+			t.Fatalf("calculateGlobalHash: %v", err)
+		}
+		return buildTaskHashes(t, repoRoot, globalHash, pipeline, packageInfos, scm.Metadata{})
+	}
+
+	initial := taskHashes()
+	writeTestRepo(t, repoRoot, map[string]string{"README.md": "# repo, with more docs"})
+	if afterRandomFile := taskHashes(); !reflect.DeepEqual(afterRandomFile, initial) {
+		t.Errorf("task hashes changed from %v to %v when a file that isn't a global dependency changed", initial, afterRandomFile)
+	}
+
+	for _, globalDep := range []string{"tsconfig.base.json", ".tool-versions"} {
+		before := taskHashes()
+		writeTestRepo(t, repoRoot, map[string]string{globalDep: "changed " + globalDep})
+		after := taskHashes()
+		for taskID, hash := range before {
+			if after[taskID] == hash {
+				t.Errorf("hash of %v didn't change when global dependency %v changed", taskID, globalDep)
+			}
+		}
+	}
+}
+
+func Test_envOrderIsNotHashed(t *testing.T) {
+	t.Setenv("A", "a-value")
+	t.Setenv("B", "b-value")
+	hashes := func(turboJSON string) (string, string) {
+		t.Helper()
+		repoRoot := turbopath.AbsoluteSystemPathFromUpstream(t.TempDir())
+		writeTestRepo(t, repoRoot, map[string]string{
+			"package.json":          `{"name": "root", "packageManager": "npm@8.19.2", "workspaces": ["apps/*"]}`,
+			"package-lock.json":     "{}",
+			"turbo.json":            turboJSON,
+			"apps/web/package.json": `{"name": "web"}`,
+		})
+		rootPackageJSON, packageManager := readTestRepo(t, repoRoot)
+		turbo, err := fs.ReadTurboConfig(repoRoot, rootPackageJSON)
+		if err != nil {
+			t.Fatalf("ReadTurboConfig: %v", err)
+		}
+		globalHash, err := calculateGlobalHash(repoRoot, rootPackageJSON, turbo.Pipeline, turbo.GlobalEnv, nil, packageManager, nil, hclog.NewNullLogger(), nil)
+		if err != nil {
+			t.Fatalf("calculateGlobalHash: %v", err)
+		}
+		packageInfos := map[interface{}]*fs.PackageJSON{
+			"web": {Name: "web", Dir: turbopath.AnchoredUnixPath("apps/web").ToSystemPath()},
+		}
+		taskHashes := buildTaskHashes(t, repoRoot, globalHash, turbo.Pipeline, packageInfos, scm.Metadata{SHA: "abc123", Branch: "main"})
+		return globalHash, taskHashes["web#build"]
+	}
+
+	globalHash, taskHash := hashes(`{
+		"globalEnv": ["A", "B"],
+		"pipeline": {"build": {"env": ["A", "B"], "passThroughEnv": ["A_TOKEN", "B_TOKEN"], "gitEnv": ["branch", "sha"], "hashGitEnv": true}}
+	}`)
+	reorderedGlobalHash, reorderedTaskHash := hashes(`{
+		"globalEnv": ["B", "A"],
+		"pipeline": {"build": {"env": ["B", "A"], "passThroughEnv": ["B_TOKEN", "A_TOKEN"], "gitEnv": ["sha", "branch"], "hashGitEnv": true}}
+	}`)
+	if reorderedGlobalHash != globalHash {
+		t.Errorf("global hash changed from %v to %v when env vars were reordered", globalHash, reorderedGlobalHash)
+	}
+	if reorderedTaskHash != taskHash {
+		t.Errorf("task hash changed from %v to %v when env vars were reordered", taskHash, reorderedTaskHash)
+	}
+}
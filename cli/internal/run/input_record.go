@@ -0,0 +1,139 @@
+package run
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/mitchellh/cli"
+	"github.com/vercel/turbo/cli/internal/nodes"
+	"github.com/vercel/turbo/cli/internal/taskhash"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// inputRecord is the input files of a task, with their hashes, that produced the
+// task's hash. The inputs of the last run of each task are recorded, so that
+// --inspect-hash can show which of them changed since.
+type inputRecord struct {
+	TaskID string `json:"taskId"`
+	Hash   string `json:"hash"`
+	// Files maps each package-relative input file to its hash
+	Files map[turbopath.AnchoredUnixPath]string `json:"files"`
+}
+
+// newInputRecord returns the record of the input files of a task with the given hash
+func newInputRecord(taskHashes *taskhash.Tracker, packageTask *nodes.PackageTask, hash string) (*inputRecord, error) {
+	files, err := taskHashes.InputFileHashes(packageTask)
+	if err != nil {
+		return nil, err
+	}
+	return &inputRecord{
+		TaskID: packageTask.TaskID,
+		Hash:   hash,
+		Files:  files,
+	}, nil
+}
+
+// getInputRecordPath returns the path that the inputs of the last run of a task
+// are recorded at
+func getInputRecordPath(repoRoot turbopath.AbsoluteSystemPath, packageTask *nodes.PackageTask) turbopath.AbsoluteSystemPath {
+	return repoRoot.UntypedJoin(".turbo", "inputs", packageTask.PackageName, packageTask.Task+".json")
+}
+
+// readInputRecord reads the input record at path, which is nil if there isn't one
+func readInputRecord(path turbopath.AbsoluteSystemPath) (*inputRecord, error) {
+	contents, err := path.ReadFile()
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	record := &inputRecord{}
+	if err := json.Unmarshal(contents, record); err != nil {
+		return nil, fmt.Errorf("invalid input record %v: %w", path, err)
+	}
+	return record, nil
+}
+
+// saveInputRecord records the inputs at path, unless they were already recorded
+// for the same hash
+func saveInputRecord(path turbopath.AbsoluteSystemPath, record *inputRecord) error {
+	if previous, err := readInputRecord(path); err == nil && previous != nil && previous.Hash == record.Hash {
+		return nil
+	}
+	contents, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := path.EnsureDir(); err != nil {
+		return err
+	}
+	return path.WriteFile(contents, 0644)
+}
+
+// inputDiff is how the input files of a task differ between two records
+type inputDiff struct {
+	Added   []turbopath.AnchoredUnixPath
+	Removed []turbopath.AnchoredUnixPath
+	Changed []turbopath.AnchoredUnixPath
+}
+
+// diffInputs returns the input files that were added, removed or changed from
+// before to after, each sorted
+func diffInputs(before *inputRecord, after *inputRecord) inputDiff {
+	diff := inputDiff{
+		Added:   []turbopath.AnchoredUnixPath{},
+		Removed: []turbopath.AnchoredUnixPath{},
+		Changed: []turbopath.AnchoredUnixPath{},
+	}
+	for file, hash := range after.Files {
+		previousHash, ok := before.Files[file]
+		if !ok {
+			diff.Added = append(diff.Added, file)
+		} else if previousHash != hash {
+			diff.Changed = append(diff.Changed, file)
+		}
+	}
+	for file := range before.Files {
+		if _, ok := after.Files[file]; !ok {
+			diff.Removed = append(diff.Removed, file)
+		}
+	}
+	for _, files := range [][]turbopath.AnchoredUnixPath{diff.Added, diff.Removed, diff.Changed} {
+		sort.Slice(files, func(i, j int) bool { return files[i] < files[j] })
+	}
+	return diff
+}
+
+// displayInputDiff shows how the inputs of a task differ from those recorded by
+// its last run, which is nil if there was none
+func displayInputDiff(ui cli.Ui, current *inputRecord, previous *inputRecord) {
+	ui.Info(util.Sprintf("${BOLD}%s${RESET}", current.TaskID))
+	ui.Output(fmt.Sprintf("  Hash          = %v", current.Hash))
+	if previous == nil {
+		ui.Output("  Last run hash = none recorded")
+		ui.Output(fmt.Sprintf("  %v input files, which can be compared once the task has run", len(current.Files)))
+		return
+	}
+	ui.Output(fmt.Sprintf("  Last run hash = %v", previous.Hash))
+	if previous.Hash == current.Hash {
+		ui.Output("  The task's hash is unchanged since it last ran")
+		return
+	}
+	diff := diffInputs(previous, current)
+	if len(diff.Added)+len(diff.Removed)+len(diff.Changed) == 0 {
+		ui.Output(fmt.Sprintf("  None of its %v input files changed, so its dependencies, env vars or configuration did", len(current.Files)))
+		return
+	}
+	for _, file := range diff.Changed {
+		ui.Output(fmt.Sprintf("  changed  %v", file))
+	}
+	for _, file := range diff.Added {
+		ui.Output(fmt.Sprintf("  added    %v", file))
+	}
+	for _, file := range diff.Removed {
+		ui.Output(fmt.Sprintf("  removed  %v", file))
+	}
+}
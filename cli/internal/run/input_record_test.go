@@ -0,0 +1,106 @@
+package run
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/pyr-sh/dag"
+	"github.com/vercel/turbo/cli/internal/core"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/nodes"
+	"github.com/vercel/turbo/cli/internal/scm"
+	"github.com/vercel/turbo/cli/internal/taskhash"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+func Test_inputRecordDiff(t *testing.T) {
+	repoRoot := turbopath.AbsoluteSystemPathFromUpstream(t.TempDir())
+	pkgDir := repoRoot.UntypedJoin("packages", "workspace-a")
+	if err := pkgDir.UntypedJoin("src").MkdirAll(0755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	writeFile := func(name string, contents string) {
+		t.Helper()
+		if err := pkgDir.UntypedJoin(name).WriteFile([]byte(contents), 0644); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+	}
+	writeFile("src/index.ts", "export * from './util'")
+	writeFile("src/util.ts", "export const a = 1")
+
+	topoGraph := dag.AcyclicGraph{}
+	topoGraph.Add("workspace-a")
+	g := &completeGraph{
+		TopologicalGraph: topoGraph,
+		Pipeline:         fs.Pipeline{"build": fs.TaskDefinition{ShouldCache: true}},
+		PackageInfos: map[interface{}]*fs.PackageJSON{
+			"workspace-a": {
+				Name:    "workspace-a",
+				Dir:     turbopath.AnchoredUnixPath("packages/workspace-a").ToSystemPath(),
+				Scripts: map[string]string{"build": "tsc"},
+			},
+		},
+		GlobalHash: "global-hash",
+		RootNode:   util.RootPkgName,
+	}
+	engine := core.NewEngine(&g.TopologicalGraph)
+
+	// hashTask returns the input record of workspace-a#build, and where a run records it
+	hashTask := func() (*inputRecord, turbopath.AbsoluteSystemPath) {
+		t.Helper()
+		tracker := taskhash.NewTracker(g.RootNode, g.GlobalHash, g.Pipeline, g.PackageInfos, nil, scm.Metadata{})
+		if err := tracker.CalculateFileHashes([]dag.Vertex{"workspace-a#build"}, 1, repoRoot); err != nil {
+			t.Fatalf("CalculateFileHashes: %v", err)
+		}
+		var record *inputRecord
+		var path turbopath.AbsoluteSystemPath
+		visitor := g.getPackageTaskVisitor(context.Background(), engine, func(ctx context.Context, packageTask *nodes.PackageTask) error {
+			hash, err := tracker.CalculateTaskHash(packageTask, dag.Set{}, hclog.NewNullLogger(), nil)
+			if err != nil {
+				return err
+			}
+			path = getInputRecordPath(repoRoot, packageTask)
+			record, err = newInputRecord(tracker, packageTask, hash)
+			return err
+		})
+		if err := visitor("workspace-a#build"); err != nil {
+			t.Fatalf("visitor: %v", err)
+		}
+		return record, path
+	}
+
+	ran, path := hashTask()
+	if err := saveInputRecord(path, ran); err != nil {
+		t.Fatalf("saveInputRecord: %v", err)
+	}
+	for _, file := range []turbopath.AnchoredUnixPath{"src/index.ts", "src/util.ts"} {
+		if _, ok := ran.Files[file]; !ok {
+			t.Errorf("recorded inputs %v are missing %v", ran.Files, file)
+		}
+	}
+
+	writeFile("src/util.ts", "export const a = 2")
+	current, _ := hashTask()
+	previous, err := readInputRecord(repoRoot.UntypedJoin(".turbo", "inputs", "workspace-a", "build.json"))
+	if err != nil {
+		t.Fatalf("readInputRecord: %v", err)
+	}
+	if !reflect.DeepEqual(previous, ran) {
+		t.Errorf("recorded inputs = %+v, want %+v", previous, ran)
+	}
+	if current.Hash == previous.Hash {
+		t.Errorf("hash of workspace-a#build didn't change when src/util.ts changed")
+	}
+	diff := diffInputs(previous, current)
+	want := inputDiff{
+		Added:   []turbopath.AnchoredUnixPath{},
+		Removed: []turbopath.AnchoredUnixPath{},
+		Changed: []turbopath.AnchoredUnixPath{"src/util.ts"},
+	}
+	if !reflect.DeepEqual(diff, want) {
+		t.Errorf("diffInputs() = %+v, want %+v", diff, want)
+	}
+}
@@ -0,0 +1,107 @@
+package run
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+// ChecksumMismatch describes an output file that doesn't match the checksum
+// expected for it in the task's checksum manifest
+type ChecksumMismatch struct {
+	// File is the package-relative path of the output file
+	File string
+	// Expected is the checksum from the manifest
+	Expected string
+	// Actual is the checksum of the file that was produced, or empty if it is missing
+	Actual string
+}
+
+func (m ChecksumMismatch) String() string {
+	if m.Actual == "" {
+		return fmt.Sprintf("%v: missing, expected %v", m.File, m.Expected)
+	}
+	return fmt.Sprintf("%v: expected %v, got %v", m.File, m.Expected, m.Actual)
+}
+
+// readChecksumManifest parses a manifest in the format of sha256sum, returning
+// the expected checksum for each file. Blank lines and lines starting with #
+// are ignored.
+func readChecksumManifest(r io.Reader) (map[string]string, error) {
+	checksums := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, " ", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("line %v: expected \"<checksum>  <file>\"", lineNumber)
+		}
+		checksum := strings.ToLower(parts[0])
+		if _, err := hex.DecodeString(checksum); err != nil || len(checksum) != sha256.Size*2 {
+			return nil, fmt.Errorf("line %v: invalid SHA-256 checksum %q", lineNumber, parts[0])
+		}
+		// sha256sum separates the checksum from the file with a space and a mode
+		// character, which is a space for text mode or * for binary mode
+		file := strings.TrimPrefix(strings.TrimPrefix(parts[1], " "), "*")
+		checksums[file] = checksum
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return checksums, nil
+}
+
+// verifyOutputChecksums compares the files in pkgDir that are listed in the
+// manifest at manifestPath against their expected checksums, and returns the
+// files that don't match, sorted by path.
+func verifyOutputChecksums(pkgDir turbopath.AbsoluteSystemPath, manifestPath string) ([]ChecksumMismatch, error) {
+	manifest, err := os.Open(pkgDir.UntypedJoin(manifestPath).ToString())
+	if err != nil {
+		return nil, fmt.Errorf("reading checksum manifest: %w", err)
+	}
+	defer func() { _ = manifest.Close() }()
+	expected, err := readChecksumManifest(manifest)
+	if err != nil {
+		return nil, fmt.Errorf("invalid checksum manifest %v: %w", manifestPath, err)
+	}
+
+	mismatches := []ChecksumMismatch{}
+	for file, checksum := range expected {
+		actual, err := sha256File(pkgDir.UntypedJoin(file).ToString())
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		if actual != checksum {
+			mismatches = append(mismatches, ChecksumMismatch{File: file, Expected: checksum, Actual: actual})
+		}
+	}
+	sort.Slice(mismatches, func(i, j int) bool {
+		return mismatches[i].File < mismatches[j].File
+	})
+	return mismatches, nil
+}
+
+func sha256File(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,59 @@
+package run
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vercel/turbo/cli/internal/fs"
+)
+
+// sha256 of "hello\n"
+const helloChecksum = "5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03"
+
+func TestReadChecksumManifest(t *testing.T) {
+	manifest := strings.Join([]string{
+		"# generated by sha256sum",
+		helloChecksum + "  dist/index.js",
+		"",
+		strings.ToUpper(helloChecksum) + " *dist/logo.png",
+	}, "\n")
+	checksums, err := readChecksumManifest(strings.NewReader(manifest))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"dist/index.js": helloChecksum,
+		"dist/logo.png": helloChecksum,
+	}, checksums)
+
+	_, err = readChecksumManifest(strings.NewReader("not-a-checksum  dist/index.js"))
+	assert.EqualError(t, err, `line 1: invalid SHA-256 checksum "not-a-checksum"`)
+}
+
+func TestVerifyOutputChecksums(t *testing.T) {
+	pkgDir := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	writeFile := func(name string, contents string) {
+		path := filepath.Join(pkgDir.ToString(), name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(t, os.WriteFile(path, []byte(contents), 0644))
+	}
+	writeFile("dist/index.js", "hello\n")
+	writeFile("dist/other.js", "tampered\n")
+	writeFile("dist.sha256", strings.Join([]string{
+		helloChecksum + "  dist/index.js",
+		helloChecksum + "  dist/other.js",
+		helloChecksum + "  dist/missing.js",
+	}, "\n"))
+
+	mismatches, err := verifyOutputChecksums(pkgDir, "dist.sha256")
+	assert.NoError(t, err)
+	assert.Equal(t, []ChecksumMismatch{
+		{File: "dist/missing.js", Expected: helloChecksum},
+		{File: "dist/other.js", Expected: helloChecksum, Actual: "92e78d0b032962f47792a9fa95fd981ef63e1e3ef074d536d6304c75eddbe29f"},
+	}, mismatches)
+	assert.Equal(t, "dist/missing.js: missing, expected "+helloChecksum, mismatches[0].String())
+
+	_, err = verifyOutputChecksums(pkgDir, "nonexistent.sha256")
+	assert.ErrorContains(t, err, "reading checksum manifest")
+}
@@ -0,0 +1,31 @@
+package run
+
+import (
+	"fmt"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/packagemanager"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+// resolvePackageManagerVersion returns the version of the package manager that
+// runs tasks. That is the version declared in the root package.json's
+// packageManager field, or the installed version if none is declared. If the
+// declared version isn't the one that is installed, it also returns a warning.
+func resolvePackageManagerVersion(packageManager *packagemanager.PackageManager, repoRoot turbopath.AbsoluteSystemPath, rootPackageJSON *fs.PackageJSON) (string, error) {
+	installed, installedErr := packageManager.InstalledVersion(repoRoot)
+	if rootPackageJSON.PackageManager == "" {
+		return installed, installedErr
+	}
+	_, declared, err := packagemanager.ParsePackageManagerString(rootPackageJSON.PackageManager)
+	if err != nil {
+		return installed, err
+	}
+	if installedErr != nil {
+		return declared, installedErr
+	}
+	if installed != declared {
+		return declared, fmt.Errorf("package.json declares %v, but %v@%v is installed", rootPackageJSON.PackageManager, packageManager.Command, installed)
+	}
+	return declared, nil
+}
@@ -0,0 +1,124 @@
+package run
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// prometheusMetric describes a single metric family in the Prometheus exposition format
+type prometheusMetric struct {
+	name    string
+	help    string
+	samples []prometheusSample
+}
+
+type prometheusSample struct {
+	labels string
+	value  string
+}
+
+// WritePrometheusMetrics writes per-task and aggregate metrics for the run in the
+// Prometheus text exposition format, suitable for node_exporter's textfile collector.
+// Tasks are labeled by workspace and task name.
+func (r *RunState) WritePrometheusMetrics(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	taskIDs := make([]string, 0, len(r.state))
+	for taskID, state := range r.state {
+		// Tasks that never finished, such as tasks without a script, have nothing to report
+		if state.Status == TargetBuilding || state.Status == TargetBuildStopped {
+			continue
+		}
+		taskIDs = append(taskIDs, taskID)
+	}
+	sort.Strings(taskIDs)
+
+	duration := &prometheusMetric{name: "turbo_task_duration_seconds", help: "Time taken to run the task, or to restore it from cache."}
+	cacheHit := &prometheusMetric{name: "turbo_task_cache_hit", help: "Whether the task was restored from cache (1) or run (0)."}
+	peakMem := &prometheusMetric{name: "turbo_task_peak_memory_bytes", help: "Peak resident memory of the task's process."}
+	exitCode := &prometheusMetric{name: "turbo_task_exit_code", help: "Exit code of the task's process."}
+	for _, taskID := range taskIDs {
+		state := r.state[taskID]
+		pkg, task := util.GetPackageTaskFromId(taskID)
+		labels := fmt.Sprintf("workspace=\"%v\",task=\"%v\"", escapePrometheusLabel(pkg), escapePrometheusLabel(task))
+		duration.samples = append(duration.samples, prometheusSample{labels, formatSeconds(state.Duration)})
+		hit := "0"
+		if state.Status == TargetCached {
+			hit = "1"
+		}
+		cacheHit.samples = append(cacheHit.samples, prometheusSample{labels, hit})
+		if state.ranProcess {
+			peakMem.samples = append(peakMem.samples, prometheusSample{labels, fmt.Sprintf("%v", state.PeakMemory)})
+			exitCode.samples = append(exitCode.samples, prometheusSample{labels, fmt.Sprintf("%v", state.ExitCode)})
+		}
+	}
+
+	metrics := []*prometheusMetric{
+		duration,
+		cacheHit,
+		peakMem,
+		exitCode,
+		{name: "turbo_run_duration_seconds", help: "Time taken by the whole run.", samples: []prometheusSample{{"", formatSeconds(time.Since(r.startedAt))}}},
+		{name: "turbo_run_tasks_attempted", help: "Number of tasks attempted.", samples: []prometheusSample{{"", fmt.Sprintf("%v", r.Attempted)}}},
+		{name: "turbo_run_tasks_cached", help: "Number of tasks restored from cache.", samples: []prometheusSample{{"", fmt.Sprintf("%v", r.Cached)}}},
+		{name: "turbo_run_tasks_failed", help: "Number of tasks that failed.", samples: []prometheusSample{{"", fmt.Sprintf("%v", r.Failure)}}},
+		{name: "turbo_run_cache_uploaded_bytes", help: "Bytes uploaded to the remote cache.", samples: []prometheusSample{{"", fmt.Sprintf("%v", r.CacheBytesUploaded)}}},
+		{name: "turbo_run_cache_downloaded_bytes", help: "Bytes downloaded from the remote cache.", samples: []prometheusSample{{"", fmt.Sprintf("%v", r.CacheBytesDownloaded)}}},
+	}
+	for _, metric := range metrics {
+		if len(metric.samples) == 0 {
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "# HELP %v %v\n# TYPE %v gauge\n", metric.name, metric.help, metric.name); err != nil {
+			return err
+		}
+		for _, sample := range metric.samples {
+			line := metric.name
+			if sample.labels != "" {
+				line += "{" + sample.labels + "}"
+			}
+			if _, err := fmt.Fprintf(w, "%v %v\n", line, sample.value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writePrometheusFile writes the run's metrics to the given file. The metrics are
+// written to a temporary file first and then renamed, so that a collector never
+// reads a partially written file.
+func writePrometheusFile(r *RunState, filename string) error {
+	tmpFilename := filename + ".tmp"
+	f, err := os.Create(tmpFilename)
+	if err != nil {
+		return err
+	}
+	if err := r.WritePrometheusMetrics(f); err != nil {
+		_ = f.Close()
+		_ = os.Remove(tmpFilename)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(tmpFilename)
+		return err
+	}
+	return os.Rename(tmpFilename, filename)
+}
+
+func formatSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+var prometheusLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapePrometheusLabel(value string) string {
+	return prometheusLabelEscaper.Replace(value)
+}
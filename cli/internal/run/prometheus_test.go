@@ -0,0 +1,64 @@
+package run
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWritePrometheusMetrics(t *testing.T) {
+	r := NewRunState(time.Now())
+	r.state["web#build"] = &BuildTargetState{
+		Label:      "web#build",
+		Status:     TargetBuilt,
+		Duration:   1500 * time.Millisecond,
+		ExitCode:   0,
+		PeakMemory: 2048,
+		ranProcess: true,
+	}
+	r.state["docs#build"] = &BuildTargetState{
+		Label:    "docs#build",
+		Status:   TargetCached,
+		Duration: 20 * time.Millisecond,
+	}
+	r.state["docs#lint"] = &BuildTargetState{
+		Label:  "docs#lint",
+		Status: TargetBuilding,
+	}
+	r.Attempted = 2
+	r.Cached = 1
+	r.Success = 1
+	r.CacheBytesUploaded = 4096
+
+	buf := &bytes.Buffer{}
+	err := r.WritePrometheusMetrics(buf)
+	assert.NoError(t, err)
+
+	output := buf.String()
+	expectedLines := []string{
+		"# TYPE turbo_task_duration_seconds gauge",
+		`turbo_task_duration_seconds{workspace="docs",task="build"} 0.020`,
+		`turbo_task_duration_seconds{workspace="web",task="build"} 1.500`,
+		`turbo_task_cache_hit{workspace="docs",task="build"} 1`,
+		`turbo_task_cache_hit{workspace="web",task="build"} 0`,
+		`turbo_task_peak_memory_bytes{workspace="web",task="build"} 2048`,
+		`turbo_task_exit_code{workspace="web",task="build"} 0`,
+		"turbo_run_tasks_attempted 2",
+		"turbo_run_tasks_cached 1",
+		"turbo_run_tasks_failed 0",
+		"turbo_run_cache_uploaded_bytes 4096",
+		"turbo_run_cache_downloaded_bytes 0",
+	}
+	for _, line := range expectedLines {
+		assert.Contains(t, output, line+"\n")
+	}
+	assert.NotContains(t, output, `task="lint"`, "unfinished tasks should not be reported")
+	assert.NotContains(t, output, `turbo_task_exit_code{workspace="docs"`, "cached tasks did not run a process")
+}
+
+func TestEscapePrometheusLabel(t *testing.T) {
+	assert.Equal(t, `a\"b\\c\nd`, escapePrometheusLabel(strings.Join([]string{`a"b\c`, "d"}, "\n")))
+}
@@ -0,0 +1,91 @@
+package run
+
+import (
+	"bytes"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+// readyFilePollInterval is how often to check whether a ready file exists
+var readyFilePollInterval = 100 * time.Millisecond
+
+// readinessWriter watches the output of a persistent task for a line matching
+// its readiness pattern, and calls onReady the first time one does.
+type readinessWriter struct {
+	pattern *regexp.Regexp
+	onReady func()
+
+	mu sync.Mutex
+	// line holds output received since the last newline
+	line  []byte
+	ready bool
+}
+
+func newReadinessWriter(pattern *regexp.Regexp, onReady func()) *readinessWriter {
+	return &readinessWriter{
+		pattern: pattern,
+		onReady: onReady,
+	}
+}
+
+// Write implements io.Writer. It never fails, so that it can be used alongside
+// the task's log output in an io.MultiWriter.
+func (w *readinessWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.ready {
+		return len(p), nil
+	}
+	w.line = append(w.line, p...)
+	for {
+		i := bytes.IndexByte(w.line, '\n')
+		if i < 0 {
+			break
+		}
+		line := w.line[:i]
+		w.line = w.line[i+1:]
+		if w.pattern.Match(line) {
+			w.ready = true
+			w.line = nil
+			w.onReady()
+			break
+		}
+	}
+	// A line without a newline yet may already match, e.g. a prompt
+	if !w.ready && len(w.line) > 0 && w.pattern.Match(w.line) {
+		w.ready = true
+		w.line = nil
+		w.onReady()
+	}
+	return len(p), nil
+}
+
+// watchReadyFile polls for the given file to exist, and calls onReady once it does.
+// The returned function stops watching.
+func watchReadyFile(path turbopath.AbsoluteSystemPath, onReady func()) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(readyFilePollInterval)
+		defer ticker.Stop()
+		for {
+			if path.FileExists() {
+				onReady()
+				return
+			}
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
@@ -0,0 +1,46 @@
+package run
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadinessWriter(t *testing.T) {
+	calls := 0
+	w := newReadinessWriter(regexp.MustCompile(`listening on port \d+`), func() {
+		calls++
+	})
+
+	_, err := w.Write([]byte("starting server\nlisten"))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, calls)
+
+	_, err = w.Write([]byte("ing on port 3000\nrequest received\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, calls)
+
+	_, err = w.Write([]byte("listening on port 3001\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, calls, "onReady should only be called once")
+}
+
+func TestWatchReadyFile(t *testing.T) {
+	readyFile := fs.AbsoluteSystemPathFromUpstream(t.TempDir()).UntypedJoin("turbo-build.ready")
+	ready := make(chan struct{})
+	stop := watchReadyFile(readyFile, func() {
+		close(ready)
+	})
+	defer stop()
+
+	assert.NoError(t, readyFile.WriteFile([]byte{}, 0644))
+	select {
+	case <-ready:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected ready file to be noticed")
+	}
+}
@@ -4,10 +4,9 @@ import (
 	gocontext "context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"os"
-	"os/exec"
-	"path/filepath"
 	"regexp"
 	"sort"
 	"strconv"
@@ -27,8 +26,11 @@ import (
 	"github.com/vercel/turbo/cli/internal/core"
 	"github.com/vercel/turbo/cli/internal/daemon"
 	"github.com/vercel/turbo/cli/internal/daemonclient"
+	"github.com/vercel/turbo/cli/internal/dispatch"
+	"github.com/vercel/turbo/cli/internal/env"
 	"github.com/vercel/turbo/cli/internal/fs"
 	"github.com/vercel/turbo/cli/internal/graphvisualizer"
+	"github.com/vercel/turbo/cli/internal/hashing"
 	"github.com/vercel/turbo/cli/internal/logstreamer"
 	"github.com/vercel/turbo/cli/internal/nodes"
 	"github.com/vercel/turbo/cli/internal/packagemanager"
@@ -57,6 +59,19 @@ type completeGraph struct {
 	PackageInfos     map[interface{}]*fs.PackageJSON
 	GlobalHash       string
 	RootNode         string
+	// GlobalEnv are the env vars declared in turbo.json that affect every task
+	GlobalEnv []string
+	// GitMetadata is the current commit, only read if a task uses gitEnv
+	GitMetadata scm.Metadata
+	// packageManager runs each task's script
+	packageManager        *packagemanager.PackageManager
+	packageManagerVersion string
+}
+
+// PackageManager returns the name (e.g. pnpm) and version of the package manager
+// that runs tasks' scripts. The version is empty if it couldn't be determined.
+func (g *completeGraph) PackageManager() (string, string) {
+	return g.packageManager.Slug, g.packageManagerVersion
 }
 
 // runSpec contains the run-specific configuration elements that come from a particular
@@ -65,6 +80,8 @@ type runSpec struct {
 	Targets      []string
 	FilteredPkgs util.Set
 	Opts         *Opts
+	// TurboVersion is the version of turbo that is running
+	TurboVersion string
 }
 
 func (rs *runSpec) ArgsForTask(task string) []string {
@@ -114,6 +131,7 @@ func GetCmd(helper *cmdutil.Helper, signalWatcher *signals.Watcher) *cobra.Comma
 			opts.runOpts.singlePackage = packageMode == packagemanager.Single
 
 			opts.runOpts.passThroughArgs = passThroughArgs
+			opts.runOpts.flagValues = changedFlags(flags)
 			run := configureRun(base, opts, signalWatcher)
 			ctx := cmd.Context()
 			if err := run.run(ctx, tasks); err != nil {
@@ -161,12 +179,18 @@ func configureRun(base *cmdutil.CmdBase, opts *Opts, signalWatcher *signals.Watc
 		opts.cacheOpts.SkipFilesystem = true
 	}
 
+	// --no-cache turns off reading from, as well as writing to, every cache
+	if opts.runcacheOpts.SkipWrites {
+		opts.runcacheOpts.SkipReads = true
+	}
+
 	processes := process.NewManager(base.Logger.Named("processes"))
 	signalWatcher.AddOnClose(processes.Close)
 	return &run{
-		base:      base,
-		opts:      opts,
-		processes: processes,
+		base:       base,
+		opts:       opts,
+		processes:  processes,
+		dispatcher: dispatch.NewLocalDispatcher(processes, base.RepoRoot),
 	}
 }
 
@@ -174,6 +198,8 @@ type run struct {
 	base      *cmdutil.CmdBase
 	opts      *Opts
 	processes *process.Manager
+	// dispatcher runs each task once turbo has decided it needs to run
+	dispatcher dispatch.Dispatcher
 }
 
 func (r *run) run(ctx gocontext.Context, targets []string) error {
@@ -224,6 +250,17 @@ func (r *run) run(ctx gocontext.Context, targets []string) error {
 	}
 
 	pipeline := turboJSON.Pipeline
+	if !r.opts.runOpts.singlePackage {
+		pipeline, err = pipeline.MergeWorkspaceConfigs(r.base.RepoRoot, pkgDepGraph.PackageInfos)
+		if err != nil {
+			return err
+		}
+	}
+	// Task names like "test:*" run every task in the pipeline that they match
+	targets, err = core.ExpandTaskNames(targets, pipeline.TaskNames())
+	if err != nil {
+		return err
+	}
 	if err := validateTasks(pipeline, targets); err != nil {
 		return err
 	}
@@ -266,20 +303,48 @@ func (r *run) run(ctx gocontext.Context, targets []string) error {
 		return fmt.Errorf("failed to calculate global hash: %v", err)
 	}
 	r.base.Logger.Debug("global hash", "value", globalHash)
+
+	packageManagerVersion, err := resolvePackageManagerVersion(pkgDepGraph.PackageManager, r.base.RepoRoot, rootPackageJSON)
+	if err != nil {
+		r.base.LogWarning("", err)
+	}
+	r.base.Logger.Debug("package manager", "name", pkgDepGraph.PackageManager.Slug, "version", packageManagerVersion)
 	r.base.Logger.Debug("local cache folder", "path", r.opts.cacheOpts.OverrideDir)
 
+	// Read git metadata once for all of the tasks that inject it into their environment
+	var gitMetadata scm.Metadata
+	for _, taskDefinition := range pipeline {
+		if len(taskDefinition.GitEnv) > 0 {
+			gitMetadata, err = scmInstance.Metadata()
+			if err != nil {
+				r.base.LogWarning("Failed to read git metadata for gitEnv", err)
+			}
+			break
+		}
+	}
+
 	// TODO: consolidate some of these arguments
 	g := &completeGraph{
-		TopologicalGraph: pkgDepGraph.TopologicalGraph,
-		Pipeline:         pipeline,
-		PackageInfos:     pkgDepGraph.PackageInfos,
-		GlobalHash:       globalHash,
-		RootNode:         pkgDepGraph.RootNode,
+		TopologicalGraph:      pkgDepGraph.TopologicalGraph,
+		Pipeline:              pipeline,
+		PackageInfos:          pkgDepGraph.PackageInfos,
+		GlobalHash:            globalHash,
+		RootNode:              pkgDepGraph.RootNode,
+		GlobalEnv:             turboJSON.GlobalEnv,
+		GitMetadata:           gitMetadata,
+		packageManager:        pkgDepGraph.PackageManager,
+		packageManagerVersion: packageManagerVersion,
+	}
+	// The remote cache can't be used unless the repo is linked. Knowing this
+	// before the engine is built decides which tasks with runWhen are included.
+	if !r.base.APIClient.IsLinked() {
+		r.opts.cacheOpts.SkipRemote = true
 	}
 	rs := &runSpec{
 		Targets:      targets,
 		FilteredPkgs: filteredPkgs,
 		Opts:         r.opts,
+		TurboVersion: r.base.TurboVersion,
 	}
 	packageManager := pkgDepGraph.PackageManager
 	return r.runOperation(ctx, g, rs, packageManager, startAt)
@@ -291,15 +356,40 @@ func (r *run) runOperation(ctx gocontext.Context, g *completeGraph, rs *runSpec,
 		vertexSet.Add(v)
 	}
 
-	engine, err := buildTaskGraphEngine(&g.TopologicalGraph, g.Pipeline, rs)
+	engine, err := buildTaskGraphEngine(&g.TopologicalGraph, g.Pipeline, g.PackageInfos, rs)
 	if err != nil {
 		return errors.Wrap(err, "error preparing engine")
 	}
-	tracker := taskhash.NewTracker(g.RootNode, g.GlobalHash, g.Pipeline, g.PackageInfos)
-	err = tracker.CalculateFileHashes(engine.TaskGraph.Vertices(), rs.Opts.runOpts.concurrency, r.base.RepoRoot)
+	if err := engine.Validate(&core.CompleteGraph{PackageInfos: g.PackageInfos}, core.ValidateOptions{}); err != nil {
+		return err
+	}
+	if err := validateScriptShells(engine, packageManager); err != nil {
+		return err
+	}
+	for _, warning := range engine.Warnings() {
+		r.base.LogWarning("", errors.New(warning))
+	}
+	var inputFileCache *hashing.InputFileCache
+	if rs.Opts.runOpts.cacheInputFiles {
+		inputFileCache = hashing.LoadInputFileCache(hashing.GetInputFileCachePath(r.base.RepoRoot))
+	}
+	tracker := taskhash.NewTracker(g.RootNode, g.GlobalHash, g.Pipeline, g.PackageInfos, inputFileCache, g.GitMetadata)
+	if rs.Opts.runOpts.dryRunJSON {
+		tracker.KeepInputFiles()
+	}
+	if !rs.Opts.runOpts.frameworkInference {
+		tracker.DisableFrameworkInference()
+	}
+	err = tracker.CalculateFileHashes(engine.HashGraph().Vertices(), rs.Opts.runOpts.concurrency, r.base.RepoRoot)
 	if err != nil {
 		return errors.Wrap(err, "error hashing package files")
 	}
+	// A dry run doesn't write to any cache
+	if inputFileCache != nil && !rs.Opts.runOpts.dryRun {
+		if err := inputFileCache.Save(); err != nil {
+			r.base.LogWarning("Failed to save the input file cache", err)
+		}
+	}
 
 	// If we are running in parallel, then we remove all the edges in the graph
 	// except for the root. Rebuild the task graph for backwards compatibility.
@@ -310,7 +400,7 @@ func (r *run) runOperation(ctx gocontext.Context, g *completeGraph, rs *runSpec,
 				g.TopologicalGraph.RemoveEdge(edge)
 			}
 		}
-		engine, err = buildTaskGraphEngine(&g.TopologicalGraph, g.Pipeline, rs)
+		engine, err = buildTaskGraphEngine(&g.TopologicalGraph, g.Pipeline, g.PackageInfos, rs)
 		if err != nil {
 			return errors.Wrap(err, "error preparing engine")
 		}
@@ -321,7 +411,21 @@ func (r *run) runOperation(ctx gocontext.Context, g *completeGraph, rs *runSpec,
 		if r.opts.runOpts.singlePackage {
 			graph = filterSinglePackageGraphForDisplay(engine.TaskGraph)
 		}
-		visualizer := graphvisualizer.New(r.base.RepoRoot, r.base.UI, graph)
+		visualizer := graphvisualizer.New(r.base.RepoRoot, r.base.UI, graph, engine.IsPersistent)
+		var durations map[string]time.Duration
+		if rs.Opts.runOpts.graphTimingsFile != "" {
+			durations, err = readSummaryDurations(rs.Opts.runOpts.graphTimingsFile)
+			if err != nil {
+				return errors.Wrap(err, "failed to read task durations for the graph")
+			}
+		}
+		criticalPath := engine.CriticalPath(durations)
+		if r.opts.runOpts.singlePackage {
+			for i, taskID := range criticalPath {
+				criticalPath[i] = util.StripPackageName(taskID)
+			}
+		}
+		visualizer.HighlightCriticalPath(criticalPath)
 
 		if rs.Opts.runOpts.graphDot {
 			visualizer.RenderDotGraph()
@@ -331,6 +435,8 @@ func (r *run) runOperation(ctx gocontext.Context, g *completeGraph, rs *runSpec,
 				return err
 			}
 		}
+	} else if rs.Opts.runOpts.inspectHash != "" {
+		return r.inspectHash(ctx, engine, g, tracker, rs)
 	} else if rs.Opts.runOpts.dryRun {
 		tasksRun, err := r.executeDryRun(ctx, engine, g, tracker, rs)
 		if err != nil {
@@ -338,19 +444,21 @@ func (r *run) runOperation(ctx gocontext.Context, g *completeGraph, rs *runSpec,
 		}
 		packagesInScope := rs.FilteredPkgs.UnsafeListOfStrings()
 		sort.Strings(packagesInScope)
+		runWhenDecisions := engine.RunWhenDecisions()
 		if rs.Opts.runOpts.dryRunJSON {
 			var rendered string
 			if r.opts.runOpts.singlePackage {
-				rendered, err = renderDryRunSinglePackageJSON(tasksRun)
+				rendered, err = renderDryRunSinglePackageJSON(tasksRun, runWhenDecisions)
 			} else {
-				rendered, err = renderDryRunFullJSON(tasksRun, packagesInScope)
+				name, version := g.PackageManager()
+				rendered, err = renderDryRunFullJSON(tasksRun, packagesInScope, name, version, runWhenDecisions)
 			}
 			if err != nil {
 				return err
 			}
 			r.base.UI.Output(rendered)
 		} else {
-			if err := displayDryTextRun(r.base.UI, tasksRun, packagesInScope, g.PackageInfos, r.opts.runOpts.singlePackage); err != nil {
+			if err := displayDryTextRun(r.base.UI, tasksRun, runWhenDecisions, packagesInScope, g.PackageInfos, r.opts.runOpts.singlePackage); err != nil {
 				return err
 			}
 		}
@@ -368,14 +476,63 @@ func (r *run) runOperation(ctx gocontext.Context, g *completeGraph, rs *runSpec,
 	return nil
 }
 
-func renderDryRunSinglePackageJSON(tasksRun []hashedTask) (string, error) {
+// inspectHash shows how the input files of the task given to --inspect-hash
+// differ from those recorded by its last run, without running any tasks
+func (r *run) inspectHash(ctx gocontext.Context, engine *core.Engine, g *completeGraph, taskHashes *taskhash.Tracker, rs *runSpec) error {
+	taskID := rs.Opts.runOpts.inspectHash
+	if r.opts.runOpts.singlePackage && !util.IsPackageTask(taskID) {
+		taskID = util.RootTaskID(taskID)
+	}
+	if err := g.hashAssumedBuiltTasks(ctx, engine, taskHashes, rs, r.base.Logger); err != nil {
+		return err
+	}
+	var current *inputRecord
+	var previous *inputRecord
+	errs := engine.Execute(g.getPackageTaskVisitor(ctx, engine, func(ctx gocontext.Context, packageTask *nodes.PackageTask) error {
+		deps := taskDependencies(engine, packageTask.TaskID)
+		hash, err := taskHashes.CalculateTaskHash(packageTask, deps, r.base.Logger, rs.ArgsForTask(packageTask.Task))
+		if err != nil {
+			return err
+		}
+		if packageTask.TaskID != taskID {
+			return nil
+		}
+		current, err = newInputRecord(taskHashes, packageTask, hash)
+		if err != nil {
+			return err
+		}
+		previous, err = readInputRecord(getInputRecordPath(r.base.RepoRoot, packageTask))
+		return err
+	}), core.EngineExecutionOptions{
+		Concurrency: 1,
+	})
+	if len(errs) > 0 {
+		for _, err := range errs {
+			r.base.UI.Error(err.Error())
+		}
+		return errors.New("errors occurred while hashing tasks")
+	}
+	if current == nil {
+		return fmt.Errorf("--inspect-hash: %v is not one of the tasks being run", rs.Opts.runOpts.inspectHash)
+	}
+	displayInputDiff(r.base.UI, current, previous)
+	return nil
+}
+
+func renderDryRunSinglePackageJSON(tasksRun []hashedTask, runWhenDecisions []core.RunWhenDecision) (string, error) {
 	singlePackageTasks := make([]hashedSinglePackageTask, len(tasksRun))
 	for i, ht := range tasksRun {
 		singlePackageTasks[i] = ht.toSinglePackageTask()
 	}
+	singlePackageDecisions := make([]core.RunWhenDecision, len(runWhenDecisions))
+	for i, decision := range runWhenDecisions {
+		decision.TaskID = util.RootTaskTaskName(decision.TaskID)
+		singlePackageDecisions[i] = decision
+	}
 	dryRun := &struct {
-		Tasks []hashedSinglePackageTask `json:"tasks"`
-	}{singlePackageTasks}
+		Tasks            []hashedSinglePackageTask `json:"tasks"`
+		ConditionalTasks []core.RunWhenDecision    `json:"conditionalTasks,omitempty"`
+	}{singlePackageTasks, singlePackageDecisions}
 	bytes, err := json.MarshalIndent(dryRun, "", "  ")
 	if err != nil {
 		return "", errors.Wrap(err, "failed to render JSON")
@@ -383,13 +540,21 @@ func renderDryRunSinglePackageJSON(tasksRun []hashedTask) (string, error) {
 	return string(bytes), nil
 }
 
-func renderDryRunFullJSON(tasksRun []hashedTask, packagesInScope []string) (string, error) {
+func renderDryRunFullJSON(tasksRun []hashedTask, packagesInScope []string, packageManager string, packageManagerVersion string, runWhenDecisions []core.RunWhenDecision) (string, error) {
+	type packageManagerSummary struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}
 	dryRun := &struct {
-		Packages []string     `json:"packages"`
-		Tasks    []hashedTask `json:"tasks"`
+		Packages         []string               `json:"packages"`
+		PackageManager   packageManagerSummary  `json:"packageManager"`
+		Tasks            []hashedTask           `json:"tasks"`
+		ConditionalTasks []core.RunWhenDecision `json:"conditionalTasks,omitempty"`
 	}{
-		Packages: packagesInScope,
-		Tasks:    tasksRun,
+		Packages:         packagesInScope,
+		PackageManager:   packageManagerSummary{Name: packageManager, Version: packageManagerVersion},
+		Tasks:            tasksRun,
+		ConditionalTasks: runWhenDecisions,
 	}
 	bytes, err := json.MarshalIndent(dryRun, "", "  ")
 	if err != nil {
@@ -398,7 +563,7 @@ func renderDryRunFullJSON(tasksRun []hashedTask, packagesInScope []string) (stri
 	return string(bytes), nil
 }
 
-func displayDryTextRun(ui cli.Ui, tasksRun []hashedTask, packagesInScope []string, packageInfos map[interface{}]*fs.PackageJSON, isSinglePackage bool) error {
+func displayDryTextRun(ui cli.Ui, tasksRun []hashedTask, runWhenDecisions []core.RunWhenDecision, packagesInScope []string, packageInfos map[interface{}]*fs.PackageJSON, isSinglePackage bool) error {
 	if !isSinglePackage {
 		ui.Output("")
 		ui.Info(util.Sprintf("${CYAN}${BOLD}Packages in Scope${RESET}"))
@@ -447,6 +612,7 @@ func displayDryTextRun(ui cli.Ui, tasksRun []hashedTask, packagesInScope []strin
 		}
 		fmt.Fprintln(w, util.Sprintf("  ${GREY}Command\t=\t%s\t${RESET}", task.Command))
 		fmt.Fprintln(w, util.Sprintf("  ${GREY}Outputs\t=\t%s\t${RESET}", strings.Join(task.Outputs, ", ")))
+		fmt.Fprintln(w, util.Sprintf("  ${GREY}Excluded Outputs\t=\t%s\t${RESET}", strings.Join(task.ExcludedOutputs, ", ")))
 		fmt.Fprintln(w, util.Sprintf("  ${GREY}Log File\t=\t%s\t${RESET}", task.LogFile))
 		fmt.Fprintln(w, util.Sprintf("  ${GREY}Dependencies\t=\t%s\t${RESET}", strings.Join(dependencies, ", ")))
 		fmt.Fprintln(w, util.Sprintf("  ${GREY}Dependendents\t=\t%s\t${RESET}", strings.Join(dependents, ", ")))
@@ -454,6 +620,27 @@ func displayDryTextRun(ui cli.Ui, tasksRun []hashedTask, packagesInScope []strin
 			return err
 		}
 	}
+
+	if len(runWhenDecisions) > 0 {
+		ui.Output("")
+		ui.Info(util.Sprintf("${CYAN}${BOLD}Conditional Tasks${RESET}"))
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+		fmt.Fprintln(w, "Task\tRun When\tDecision\t")
+		for _, decision := range runWhenDecisions {
+			taskName := decision.TaskID
+			if isSinglePackage {
+				taskName = util.RootTaskTaskName(taskName)
+			}
+			outcome := "omitted"
+			if decision.Included {
+				outcome = "included"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t\n", taskName, decision.RunWhen, outcome)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -473,7 +660,22 @@ func filterSinglePackageGraphForDisplay(originalGraph *dag.AcyclicGraph) *dag.Ac
 	return graph
 }
 
-func buildTaskGraphEngine(topoGraph *dag.AcyclicGraph, pipeline fs.Pipeline, rs *runSpec) (*core.Engine, error) {
+// readinessProbe returns the engine's readiness probe for the given one from
+// turbo.json, which is nil if the task doesn't have one
+func readinessProbe(probe *fs.ReadinessProbe) *core.ReadinessProbe {
+	if probe == nil {
+		return nil
+	}
+	return &core.ReadinessProbe{
+		Command:  probe.Command,
+		Port:     probe.Port,
+		URL:      probe.URL,
+		Interval: probe.Interval,
+		Timeout:  probe.Timeout,
+	}
+}
+
+func buildTaskGraphEngine(topoGraph *dag.AcyclicGraph, pipeline fs.Pipeline, packageInfos map[interface{}]*fs.PackageJSON, rs *runSpec) (*core.Engine, error) {
 	engine := core.NewEngine(topoGraph)
 
 	for taskName, taskDefinition := range pipeline {
@@ -494,16 +696,50 @@ func buildTaskGraphEngine(topoGraph *dag.AcyclicGraph, pipeline fs.Pipeline, rs
 			topoDeps.Add(dependency)
 		}
 		engine.AddTask(&core.Task{
-			Name:     taskName,
-			TopoDeps: topoDeps,
-			Deps:     deps,
+			Name:                 taskName,
+			TopoDeps:             topoDeps,
+			Deps:                 deps,
+			Shell:                taskDefinition.Shell,
+			Outputs:              taskDefinition.Outputs.Inclusions,
+			Inputs:               taskDefinition.Inputs,
+			ShouldCache:          taskDefinition.ShouldCache,
+			InputsFromDepOutputs: taskDefinition.InputsFromDepOutputs,
+			DepQuorum:            taskDefinition.DepQuorum,
+			Persistent:           taskDefinition.Persistent,
+			StartsAfter:          taskDefinition.StartsAfter,
+			StreamingOutputs:     taskDefinition.StreamingOutputs,
+			ConsumesStreaming:    taskDefinition.ConsumesStreaming,
+			Tags:                 taskDefinition.Tags,
+			DependsOnTag:         taskDefinition.DependsOnTag,
+			RunWhen:              taskDefinition.RunWhen,
+			Foreground:           taskDefinition.Foreground,
+			MinTurboVersion:      taskDefinition.MinTurboVersion,
+			Port:                 taskDefinition.Port,
+			Weight:               taskDefinition.Weight,
+			AllowDependents:      taskDefinition.AllowDependents,
+			Timeout:              taskDefinition.Timeout,
+			Retries:              taskDefinition.Retries,
+			Hook:                 taskDefinition.Hook,
+			DotEnv:               taskDefinition.DotEnv,
+			ReadinessProbe:       readinessProbe(taskDefinition.ReadinessProbe),
 		})
 	}
 
 	if err := engine.Prepare(&core.EngineBuildingOptions{
-		Packages:  rs.FilteredPkgs.UnsafeListOfStrings(),
-		TaskNames: rs.Targets,
-		TasksOnly: rs.Opts.runOpts.only,
+		Packages:          rs.FilteredPkgs.UnsafeListOfStrings(),
+		TaskNames:         rs.Targets,
+		TasksOnly:         rs.Opts.runOpts.only,
+		Shell:             rs.Opts.runOpts.shell,
+		BreakpointTasks:   rs.Opts.runOpts.breakpoints,
+		ExplainMode:       rs.Opts.runOpts.explain,
+		WorkspaceProtocol: rs.Opts.runOpts.workspaceProtocol,
+		PackageInfos:      packageInfos,
+		AllowMissingTasks: rs.Opts.runOpts.allowMissingTasks,
+		ShuffleSeed:       rs.Opts.runOpts.shuffleSeed,
+		CacheEnabled:      rs.Opts.cacheEnabled(),
+		ColorPaletteSize:  colorcache.PaletteSize(),
+		TurboVersion:      rs.TurboVersion,
+		OutputOrder:       rs.Opts.runOpts.outputOrder,
 	}); err != nil {
 		return nil, err
 	}
@@ -515,6 +751,26 @@ func buildTaskGraphEngine(topoGraph *dag.AcyclicGraph, pipeline fs.Pipeline, rs
 	return engine, nil
 }
 
+// validateScriptShells errors if a task has a shell configured but the package manager
+// is yarn 2+, which ignores the npm_config_script_shell setting that the shell is
+// passed with.
+func validateScriptShells(engine *core.Engine, packageManager *packagemanager.PackageManager) error {
+	if packageManager.Name != "nodejs-berry" {
+		return nil
+	}
+	var taskIDs []string
+	for _, v := range engine.TaskGraph.Vertices() {
+		taskIDs = append(taskIDs, dag.VertexName(v))
+	}
+	sort.Strings(taskIDs)
+	for _, taskID := range taskIDs {
+		if shell := engine.TaskShell(taskID); shell != "" {
+			return fmt.Errorf("cannot run %v with shell \"%v\": yarn 2+ doesn't support setting the shell that runs scripts", taskID, shell)
+		}
+	}
+	return nil
+}
+
 // Opts holds the current run operations configuration
 type Opts struct {
 	runOpts      runOpts
@@ -523,6 +779,14 @@ type Opts struct {
 	scopeOpts    scope.Opts
 }
 
+// cacheEnabled returns true if the run reads from or writes to any cache
+func (o *Opts) cacheEnabled() bool {
+	if !o.cacheOpts.UsesFilesystem() && !o.cacheOpts.UsesRemote() {
+		return false
+	}
+	return !o.runcacheOpts.SkipReads || !o.runcacheOpts.SkipWrites
+}
+
 // runOpts holds the options that control the execution of a turbo run
 type runOpts struct {
 	// Show a dot graph
@@ -546,24 +810,131 @@ type runOpts struct {
 	graphFile     string
 	noDaemon      bool
 	singlePackage bool
+	// The shell the package manager should use to run task scripts
+	shell string
+	// Tasks to pause execution at before running them
+	breakpoints []string
+	// Whether a breakpoint pauses all task scheduling, rather than just the breakpoint task
+	breakpointPauseAll bool
+	// File to write Prometheus metrics for the run into
+	prometheusFile string
+	// Whether to reuse the files matched by task inputs from previous runs
+	cacheInputFiles bool
+	// Whether to explain each scheduling decision made during the run
+	explain bool
+	// Only follow dependencies between workspaces that use this protocol (e.g. workspace)
+	workspaceProtocol string
+	// Seed for starting tasks in a reproducible, shuffled order
+	shuffleSeed int64
+	// Whether to skip tasks completed by a previous, interrupted run
+	resume bool
+	// Task names in the order their output is flushed in, regardless of when they run
+	outputOrder []string
+	// File to write a JSON summary of the run into
+	summaryFile string
+	// The flags set on the command line, recorded in the run summary
+	flagValues map[string]string
+	// Whether to fail the run if any cacheable task misses the cache
+	cacheStrict bool
+	// How many tasks can be restored from the cache at once. If zero, it is the
+	// same as concurrency
+	restoreConcurrency int
+	// Whether to hash the env vars of the framework each workspace is inferred
+	// to use
+	frameworkInference bool
+	// Run summary whose task durations weigh the critical path shown in the graph
+	graphTimingsFile string
+	// Whether tasks run with only the env vars declared in turbo.json, and the
+	// ones every process needs
+	strictEnv bool
+	// Task whose input files are compared with those of its last run, instead of running
+	inspectHash string
+	// Number of failed tasks after which no more tasks are started, or 0 for no limit
+	maxErrors uint
+	// Whether a requested task that no workspace in scope implements is skipped
+	// with a warning, rather than being an error
+	allowMissingTasks bool
 }
 
 var (
-	_profileHelp = `File to write turbo's performance profile output into.
-You can load the file up in chrome://tracing to see
-which parts of your build were slow.`
+	_profileHelp = `File to write a profile of the run's tasks into, in the Chrome
+trace event format. You can load the file up in chrome://tracing
+or Perfetto to see which tasks were slow and how many ran at once.`
 	_continueHelp = `Continue execution even if a task exits with an error
 or non-zero exit code. The default behavior is to bail`
+	_maxErrorsHelp = `Stop starting new tasks once this many tasks have failed,
+even with --continue. Tasks that are already running finish.
+0 means there is no limit.`
+	_allowMissingTasksHelp = `Skip the given tasks that no workspace in scope implements,
+with a warning, rather than failing. Useful for scripts that
+run tasks that only some monorepos define.`
 	_dryRunHelp = `List the packages in scope and the tasks that would be run,
 but don't actually run them. Passing --dry=json or
 --dry-run=json will render the output in JSON format.`
-	_graphHelp = `Generate a graph of the task execution and output to a file when a filename is specified (.svg, .png, .jpg, .pdf, .json, .html).
+	_graphHelp = `Generate a graph of the task execution and output to a file when a filename is specified (.svg, .png, .jpg, .pdf, .json, .html, .mmd).
 Outputs dot graph to stdout when if no filename is provided`
-	_concurrencyHelp = `Limit the concurrency of task execution. Use 1 for serial (i.e. one-at-a-time) execution.`
-	_parallelHelp    = `Execute all tasks in parallel.`
-	_onlyHelp        = `Run only the specified tasks, not their dependencies.`
+	_concurrencyHelp = `Limit the concurrency of task execution. Use 1 for serial (i.e. one-at-a-time) execution,
+or a percentage of CPUs (e.g. 50%).`
+	_parallelHelp = `Execute all tasks in parallel.`
+	_onlyHelp     = `Run only the specified tasks, not their dependencies, which
+are assumed to be built already.`
+	_shellHelp = `Shell used by the package manager to run task scripts
+(e.g. bash). Tasks can override this with the "shell" key in turbo.json.
+Not supported with yarn 2+.`
+	_breakpointHelp = `Pause execution before running the given task name (e.g. build)
+or task id (e.g. web#build) and wait for input before continuing.
+Can be passed multiple times.`
+	_breakpointPauseAllHelp = `Don't start any other tasks while paused at a breakpoint.`
+	_prometheusFileHelp     = `File to write per-task and aggregate metrics for the run into,
+in Prometheus text format (e.g. for node_exporter's textfile collector).`
+	_explainHelp = `Print a timeline of every scheduling decision made during
+the run, and the reason for it.`
+	_cacheInputFilesHelp = `Reuse the files matched by each task's "inputs" globs from
+previous runs while the directory structure of its package is unchanged.`
+	_workspaceProtocolHelp = `Only follow dependencies between workspaces that reference
+each other with the given protocol (e.g. workspace, file, link) when
+running dependent tasks.`
+	_shuffleSeedHelp = `Start tasks in a shuffled order, while still respecting their
+dependencies, to find tasks that depend on the order they happen
+to run in. The same seed always gives the same order.`
+	_resumeHelp = `Resume a run that was interrupted, skipping uncached tasks that
+completed before the interruption. Cached tasks are restored from
+the cache as usual. Persistent tasks are always restarted.`
+	_outputOrderHelp = `Comma-separated task names (e.g. typecheck,test) in the order
+their output is shown in. Each task's output is held back until it
+finishes and the tasks listed before it have been shown, regardless
+of the order the tasks run in.`
+	_summarizeHelp = `File to write a JSON summary of the run into, with the hash,
+cache status, timing, exit code and attempts of each task.`
+	_cacheStrictHelp = `Exit with code 3 if any task missed the cache, e.g. to check
+that a clean checkout reproduces the cached outputs. Tasks
+that never cache, such as persistent tasks, are ignored.`
+	_restoreConcurrencyHelp = `Limit how many tasks are restored from the cache at once,
+separately from the tasks that are running. Defaults to
+--concurrency.`
+	_frameworkInferenceHelp = `Include the env vars of the framework each workspace uses
+(e.g. NEXT_PUBLIC_* for Next.js) in its task hashes.
+Use --framework-inference=false to hash only the env vars
+declared in turbo.json.`
+	_strictEnvHelp = `Run tasks with only the env vars declared in env,
+passThroughEnv and globalEnv, plus system env vars such
+as PATH and HOME, so that tasks can't depend on env vars
+that aren't declared.`
+	_inspectHashHelp = `Instead of running tasks, show which input files of the
+given task (e.g. web#build) changed since it last ran,
+which explains why its hash changed.`
+	_graphTimingsHelp = `Highlight the critical path in --graph using the task
+durations in a run summary written by --summarize. Without
+it, the path with the most dependencies is highlighted.`
 )
 
+// errRestored is returned when restoring a task means it doesn't need to run
+var errRestored = errors.New("task is done without running")
+
+// cacheMissExitCode is the exit code of a --cache-strict run with cache misses,
+// which is distinct from the exit codes of failed tasks
+const cacheMissExitCode = 3
+
 func addRunOpts(opts *runOpts, flags *pflag.FlagSet, aliases map[string]string) {
 	flags.AddFlag(&pflag.Flag{
 		Name:     "concurrency",
@@ -576,18 +947,41 @@ func addRunOpts(opts *runOpts, flags *pflag.FlagSet, aliases map[string]string)
 	flags.BoolVar(&opts.parallel, "parallel", false, _parallelHelp)
 	flags.StringVar(&opts.profile, "profile", "", _profileHelp)
 	flags.BoolVar(&opts.continueOnError, "continue", false, _continueHelp)
+	flags.UintVar(&opts.maxErrors, "max-errors", 0, _maxErrorsHelp)
+	flags.BoolVar(&opts.allowMissingTasks, "allow-missing-tasks", false, _allowMissingTasksHelp)
 	flags.BoolVar(&opts.only, "only", false, _onlyHelp)
 	flags.BoolVar(&opts.noDaemon, "no-daemon", false, "Run without using turbo's daemon process")
 	flags.BoolVar(&opts.singlePackage, "single-package", false, "Run turbo in single-package mode")
+	flags.StringVar(&opts.shell, "shell", "", _shellHelp)
+	flags.StringArrayVar(&opts.breakpoints, "breakpoint", nil, _breakpointHelp)
+	flags.BoolVar(&opts.breakpointPauseAll, "breakpoint-pause-all", false, _breakpointPauseAllHelp)
+	flags.StringVar(&opts.prometheusFile, "prometheus-file", "", _prometheusFileHelp)
+	flags.BoolVar(&opts.cacheInputFiles, "cache-input-files", false, _cacheInputFilesHelp)
+	flags.BoolVar(&opts.explain, "explain", false, _explainHelp)
+	flags.StringVar(&opts.workspaceProtocol, "workspace-protocol", "", _workspaceProtocolHelp)
+	flags.Int64Var(&opts.shuffleSeed, "shuffle-seed", 0, _shuffleSeedHelp)
+	flags.BoolVar(&opts.resume, "resume", false, _resumeHelp)
+	flags.StringSliceVar(&opts.outputOrder, "output-order", nil, _outputOrderHelp)
+	flags.StringVar(&opts.summaryFile, "summarize", "", _summarizeHelp)
+	flags.BoolVar(&opts.cacheStrict, "cache-strict", false, _cacheStrictHelp)
+	flags.BoolVar(&opts.frameworkInference, "framework-inference", true, _frameworkInferenceHelp)
+	flags.StringVar(&opts.graphTimingsFile, "graph-timings", "", _graphTimingsHelp)
+	flags.BoolVar(&opts.strictEnv, "strict-env", false, _strictEnvHelp)
+	flags.StringVar(&opts.inspectHash, "inspect-hash", "", _inspectHashHelp)
+	flags.AddFlag(&pflag.Flag{
+		Name:  "restore-concurrency",
+		Usage: _restoreConcurrencyHelp,
+		Value: &util.ConcurrencyValue{
+			Value: &opts.restoreConcurrency,
+		},
+	})
+	aliases["summary-file"] = "summarize"
+	aliases["chrome-trace"] = "profile"
 	// This is a no-op flag, we don't need it anymore
 	flags.Bool("experimental-use-daemon", false, "Use the experimental turbo daemon")
 	if err := flags.MarkHidden("experimental-use-daemon"); err != nil {
 		panic(err)
 	}
-	if err := flags.MarkHidden("only"); err != nil {
-		// fail fast if we've messed up our flag configuration
-		panic(err)
-	}
 	if err := flags.MarkHidden("single-package"); err != nil {
 		panic(err)
 	}
@@ -720,12 +1114,14 @@ func (r *run) initAnalyticsClient(ctx gocontext.Context) analytics.Client {
 	return analyticsClient
 }
 
-func (r *run) initCache(ctx gocontext.Context, rs *runSpec, analyticsClient analytics.Client) (cache.Cache, error) {
+func (r *run) initCache(ctx gocontext.Context, rs *runSpec, analyticsClient analytics.Client, transferStats *cache.TransferStats) (cache.Cache, error) {
 	apiClient := r.base.APIClient
 	// Theoretically this is overkill, but bias towards not spamming the console
 	once := &sync.Once{}
 
-	return cache.New(rs.Opts.cacheOpts, r.base.RepoRoot, apiClient, analyticsClient, func(_cache cache.Cache, err error) {
+	cacheOpts := rs.Opts.cacheOpts
+	cacheOpts.TransferStats = transferStats
+	return cache.New(cacheOpts, r.base.RepoRoot, apiClient, analyticsClient, func(_cache cache.Cache, err error) {
 		// Currently the HTTP Cache is the only one that can be disabled.
 		// With a cache system refactor, we might consider giving names to the caches so
 		// we can accurately report them here.
@@ -736,17 +1132,25 @@ func (r *run) initCache(ctx gocontext.Context, rs *runSpec, analyticsClient anal
 }
 
 func (r *run) executeTasks(ctx gocontext.Context, g *completeGraph, rs *runSpec, engine *core.Engine, packageManager *packagemanager.PackageManager, hashes *taskhash.Tracker, startAt time.Time) error {
+	if err := g.hashAssumedBuiltTasks(ctx, engine, hashes, rs, r.base.Logger); err != nil {
+		return err
+	}
+
 	analyticsClient := r.initAnalyticsClient(ctx)
 	defer analyticsClient.CloseWithTimeout(50 * time.Millisecond)
 
-	useHTTPCache := !rs.Opts.cacheOpts.SkipRemote
+	useHTTPCache := rs.Opts.cacheOpts.UsesRemote()
 	if useHTTPCache {
 		r.base.UI.Info(ui.Dim("• Remote caching enabled"))
 	} else {
 		r.base.UI.Info(ui.Dim("• Remote caching disabled"))
 	}
+	if rs.Opts.runOpts.shuffleSeed != 0 {
+		r.base.UI.Info(ui.Dim(fmt.Sprintf("• Shuffling task order with seed %v", rs.Opts.runOpts.shuffleSeed)))
+	}
 
-	turboCache, err := r.initCache(ctx, rs, analyticsClient)
+	transferStats := cache.NewTransferStats()
+	turboCache, err := r.initCache(ctx, rs, analyticsClient, transferStats)
 	if err != nil {
 		if errors.Is(err, cache.ErrNoCachesEnabled) {
 			r.base.LogWarning("No caches are enabled. You can try \"turbo login\", \"turbo link\", or ensuring you are not passing --remote-only to enable caching", nil)
@@ -754,12 +1158,26 @@ func (r *run) executeTasks(ctx gocontext.Context, g *completeGraph, rs *runSpec,
 			return errors.Wrap(err, "failed to set up caching")
 		}
 	}
-	defer func() {
-		_ = spinner.WaitFor(ctx, turboCache.Shutdown, r.base.UI, "...writing to cache...", 1500*time.Millisecond)
-	}()
+	// The cache is shut down before the run summary, so that it includes uploads
+	// that were still in flight when the last task finished
+	shutdownOnce := &sync.Once{}
+	shutdownCache := func() {
+		shutdownOnce.Do(func() {
+			_ = spinner.WaitFor(ctx, turboCache.Shutdown, r.base.UI, "...writing to cache...", 1500*time.Millisecond)
+		})
+	}
+	defer shutdownCache()
 	colorCache := colorcache.New()
-	runState := NewRunState(startAt, rs.Opts.runOpts.profile)
+	runState := NewRunState(startAt)
 	runCache := runcache.New(turboCache, r.base.RepoRoot, rs.Opts.runcacheOpts, colorCache)
+	graphHash, err := taskGraphHash(engine)
+	if err != nil {
+		return errors.Wrap(err, "failed to hash the task graph")
+	}
+	runCheckpoint, warning := newCheckpoint(getCheckpointPath(r.base.RepoRoot), graphHash, rs.Opts.runOpts.resume)
+	if warning != "" {
+		r.base.LogWarning("Cannot resume", errors.New(warning))
+	}
 
 	ec := &execContext{
 		colorCache:      colorCache,
@@ -770,21 +1188,71 @@ func (r *run) executeTasks(ctx gocontext.Context, g *completeGraph, rs *runSpec,
 		logger:          r.base.Logger,
 		packageManager:  packageManager,
 		processes:       r.processes,
+		dispatcher:      r.dispatcher,
 		taskHashes:      hashes,
 		repoRoot:        r.base.RepoRoot,
 		isSinglePackage: r.opts.runOpts.singlePackage,
+		engine:          engine,
+		gitMetadata:     g.GitMetadata,
+		checkpoint:      runCheckpoint,
+		terminal:        newTerminal(os.Stdout),
+		globalEnv:       g.GlobalEnv,
 	}
 
 	// run the thing
 	execOpts := core.EngineExecutionOptions{
-		Parallel:    rs.Opts.runOpts.parallel,
-		Concurrency: rs.Opts.runOpts.concurrency,
+		Parallel:        rs.Opts.runOpts.parallel,
+		Concurrency:     rs.Opts.runOpts.concurrency,
+		ContinueOnError: rs.Opts.runOpts.continueOnError,
+		MaxErrors:       int(rs.Opts.runOpts.maxErrors),
+	}
+	if len(rs.Opts.runOpts.breakpoints) > 0 {
+		if !ui.IsTTY {
+			return fmt.Errorf("--breakpoint requires an interactive terminal")
+		}
+		execOpts.PauseAllOnBreakpoint = rs.Opts.runOpts.breakpointPauseAll
+		execOpts.BreakpointHandler = func(taskID string) error {
+			_, err := ec.ui.Ask(fmt.Sprintf("%s %s", ui.Dim("• Paused at breakpoint"), ui.Bold(taskID)) + ui.Dim(". Press enter to continue."))
+			return err
+		}
 	}
-	visitor := g.getPackageTaskVisitor(ctx, func(ctx gocontext.Context, packageTask *nodes.PackageTask) error {
-		deps := engine.TaskGraph.DownEdges(packageTask.TaskID)
+	// Tasks are restored from the cache before they wait for a concurrency slot.
+	// Those that missed the cache are held on to until they run.
+	var prepared sync.Map
+	execOpts.RestoreConcurrency = rs.Opts.runOpts.restoreConcurrency
+	restoreVisitor := g.getPackageTaskVisitor(ctx, engine, func(ctx gocontext.Context, packageTask *nodes.PackageTask) error {
+		deps := taskDependencies(engine, packageTask.TaskID)
+		t, done := ec.restore(ctx, packageTask, deps)
+		if done {
+			return errRestored
+		}
+		prepared.Store(packageTask.TaskID, t)
+		return nil
+	})
+	execOpts.Restore = func(taskID string) bool {
+		return errors.Is(restoreVisitor(taskID), errRestored)
+	}
+	visitor := g.getPackageTaskVisitor(ctx, engine, func(ctx gocontext.Context, packageTask *nodes.PackageTask) error {
+		deps := taskDependencies(engine, packageTask.TaskID)
+		if failedDeps := engine.FailedDependencies(packageTask.TaskID); len(failedDeps) > 0 {
+			ec.ui.Warn(fmt.Sprintf("%v: running despite failed dependencies: %v", packageTask.TaskID, strings.Join(failedDeps, ", ")))
+		}
+		// Retries start over from the cache, like the first attempt
+		if t, ok := prepared.LoadAndDelete(packageTask.TaskID); ok {
+			return ec.execute(ctx, t.(*preparedTask))
+		}
 		return ec.exec(ctx, packageTask, deps)
 	})
 	errs := engine.Execute(visitor, execOpts)
+	// Keep the checkpoint around to resume from, unless every task succeeded
+	if len(errs) == 0 {
+		err = runCheckpoint.remove()
+	} else {
+		err = runCheckpoint.save()
+	}
+	if err != nil {
+		r.base.LogWarning("Failed to update the checkpoint", err)
+	}
 
 	// Track if we saw any child with a non-zero exit code
 	exitCode := 0
@@ -800,10 +1268,42 @@ func (r *run) executeTasks(ctx gocontext.Context, g *completeGraph, rs *runSpec,
 		}
 		r.base.UI.Error(err.Error())
 	}
+	// Task failures take precedence over cache misses
+	if rs.Opts.runOpts.cacheStrict && exitCode == 0 {
+		if misses := runState.cacheMisses(); len(misses) > 0 {
+			r.base.UI.Error(fmt.Sprintf("--cache-strict: tasks missed the cache: %v", strings.Join(misses, ", ")))
+			exitCode = cacheMissExitCode
+		}
+	}
 
-	if err := runState.Close(r.base.UI, rs.Opts.runOpts.profile); err != nil {
+	shutdownCache()
+	runState.recordCacheTransfers(transferStats)
+	if rs.Opts.runOpts.profile != "" {
+		if err := writeProfile(engine, runState, rs.Opts.runOpts.profile); err != nil {
+			r.base.UI.Error(fmt.Sprintf("Error writing profile: %v", err))
+		}
+	}
+	if err := runState.Close(r.base.UI); err != nil {
 		return errors.Wrap(err, "error with profiler")
 	}
+	if rs.Opts.runOpts.explain {
+		displayDecisions(r.base.UI, engine.Decisions(), startAt)
+	}
+	if rs.Opts.runOpts.prometheusFile != "" {
+		if err := writePrometheusFile(runState, rs.Opts.runOpts.prometheusFile); err != nil {
+			r.base.LogWarning("Failed to write Prometheus metrics", err)
+		}
+	}
+	if rs.Opts.runOpts.summaryFile != "" {
+		summary := runState.Summary(g.GlobalHash, SummaryCommand{
+			Tasks:           rs.Targets,
+			Flags:           rs.Opts.runOpts.flagValues,
+			PassThroughArgs: rs.Opts.runOpts.passThroughArgs,
+		}, time.Now())
+		if err := writeSummaryFile(summary, rs.Opts.runOpts.summaryFile); err != nil {
+			r.base.LogWarning("Failed to write run summary", err)
+		}
+	}
 	if exitCode != 0 {
 		return &process.ChildExit{
 			ExitCode: exitCode,
@@ -812,6 +1312,48 @@ func (r *run) executeTasks(ctx gocontext.Context, g *completeGraph, rs *runSpec,
 	return nil
 }
 
+// withStop returns a copy of ctx that is also done once stop is done
+func withStop(ctx gocontext.Context, stop gocontext.Context) (gocontext.Context, gocontext.CancelFunc) {
+	stoppable, cancel := gocontext.WithCancel(ctx)
+	go func() {
+		select {
+		case <-stop.Done():
+			cancel()
+		case <-stoppable.Done():
+		}
+	}()
+	return stoppable, cancel
+}
+
+// writeProfile writes the timing of each task run by the engine, with where its
+// outputs were restored from, to the given file in the Chrome trace event format
+func writeProfile(engine *core.Engine, runState *RunState, filename string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := engine.WriteChromeTrace(f, runState.traceArgs); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// groupedUI returns a ui that writes everything, including errors, to w
+func groupedUI(w io.Writer) cli.Ui {
+	return &cli.ColoredUi{
+		Ui: &cli.BasicUi{
+			Reader:      os.Stdin,
+			Writer:      w,
+			ErrorWriter: w,
+		},
+		OutputColor: cli.UiColorNone,
+		InfoColor:   cli.UiColorNone,
+		WarnColor:   cli.UiColorYellow,
+		ErrorColor:  cli.UiColorRed,
+	}
+}
+
 type hashedTask struct {
 	TaskID          string           `json:"taskId"`
 	Task            string           `json:"task"`
@@ -819,6 +1361,7 @@ type hashedTask struct {
 	Hash            string           `json:"hash"`
 	CacheState      cache.ItemStatus `json:"cacheState"`
 	Command         string           `json:"command"`
+	Inputs          []string         `json:"inputs"`
 	Outputs         []string         `json:"outputs"`
 	ExcludedOutputs []string         `json:"excludedOutputs"`
 	LogFile         string           `json:"logFile"`
@@ -837,13 +1380,15 @@ func (ht *hashedTask) toSinglePackageTask() hashedSinglePackageTask {
 		dependents[i] = util.StripPackageName(dependent)
 	}
 	return hashedSinglePackageTask{
-		Task:         util.RootTaskTaskName(ht.TaskID),
-		Hash:         ht.Hash,
-		Command:      ht.Command,
-		Outputs:      ht.Outputs,
-		LogFile:      ht.LogFile,
-		Dependencies: dependencies,
-		Dependents:   dependents,
+		Task:            util.RootTaskTaskName(ht.TaskID),
+		Hash:            ht.Hash,
+		Command:         ht.Command,
+		Inputs:          ht.Inputs,
+		Outputs:         ht.Outputs,
+		ExcludedOutputs: ht.ExcludedOutputs,
+		LogFile:         ht.LogFile,
+		Dependencies:    dependencies,
+		Dependents:      dependents,
 	}
 }
 
@@ -851,6 +1396,7 @@ type hashedSinglePackageTask struct {
 	Task            string   `json:"task"`
 	Hash            string   `json:"hash"`
 	Command         string   `json:"command"`
+	Inputs          []string `json:"inputs"`
 	Outputs         []string `json:"outputs"`
 	ExcludedOutputs []string `json:"excludedOutputs"`
 	LogFile         string   `json:"logFile"`
@@ -861,7 +1407,7 @@ type hashedSinglePackageTask struct {
 func (r *run) executeDryRun(ctx gocontext.Context, engine *core.Engine, g *completeGraph, taskHashes *taskhash.Tracker, rs *runSpec) ([]hashedTask, error) {
 	analyticsClient := r.initAnalyticsClient(ctx)
 	defer analyticsClient.CloseWithTimeout(50 * time.Millisecond)
-	turboCache, err := r.initCache(ctx, rs, analyticsClient)
+	turboCache, err := r.initCache(ctx, rs, analyticsClient, nil)
 	defer turboCache.Shutdown()
 
 	if err != nil {
@@ -872,11 +1418,15 @@ func (r *run) executeDryRun(ctx gocontext.Context, engine *core.Engine, g *compl
 		}
 	}
 
+	if err := g.hashAssumedBuiltTasks(ctx, engine, taskHashes, rs, r.base.Logger); err != nil {
+		return nil, err
+	}
+
 	taskIDs := []hashedTask{}
 
-	errs := engine.Execute(g.getPackageTaskVisitor(ctx, func(ctx gocontext.Context, packageTask *nodes.PackageTask) error {
+	errs := engine.Execute(g.getPackageTaskVisitor(ctx, engine, func(ctx gocontext.Context, packageTask *nodes.PackageTask) error {
 		passThroughArgs := rs.ArgsForTask(packageTask.Task)
-		deps := engine.TaskGraph.DownEdges(packageTask.TaskID)
+		deps := taskDependencies(engine, packageTask.TaskID)
 		hash, err := taskHashes.CalculateTaskHash(packageTask, deps, r.base.Logger, passThroughArgs)
 		if err != nil {
 			return err
@@ -886,7 +1436,7 @@ func (r *run) executeDryRun(ctx gocontext.Context, engine *core.Engine, g *compl
 			command = "<NONEXISTENT>"
 		}
 		isRootTask := packageTask.PackageName == util.RootPkgName
-		if isRootTask && commandLooksLikeTurbo(command) {
+		if isRootTask && core.CommandLooksLikeTurbo(command) {
 			return fmt.Errorf("root task %v (%v) looks like it invokes turbo and might cause a loop", packageTask.Task, command)
 		}
 		ancestors, err := engine.TaskGraph.Ancestors(packageTask.TaskID)
@@ -917,6 +1467,18 @@ func (r *run) executeDryRun(ctx gocontext.Context, engine *core.Engine, g *compl
 		if err != nil {
 			return err
 		}
+		// Input files are only recorded for JSON output
+		var inputs []string
+		if rs.Opts.runOpts.dryRunJSON {
+			inputFiles, err := taskHashes.InputFiles(packageTask)
+			if err != nil {
+				return err
+			}
+			inputs = make([]string, len(inputFiles))
+			for i, file := range inputFiles {
+				inputs[i] = file.ToString()
+			}
+		}
 
 		taskIDs = append(taskIDs, hashedTask{
 			TaskID:          packageTask.TaskID,
@@ -925,6 +1487,7 @@ func (r *run) executeDryRun(ctx gocontext.Context, engine *core.Engine, g *compl
 			Hash:            hash,
 			CacheState:      itemStatus,
 			Command:         command,
+			Inputs:          inputs,
 			Dir:             packageTask.Pkg.Dir.ToString(),
 			Outputs:         packageTask.TaskDefinition.Outputs.Inclusions,
 			ExcludedOutputs: packageTask.TaskDefinition.Outputs.Exclusions,
@@ -947,12 +1510,6 @@ func (r *run) executeDryRun(ctx gocontext.Context, engine *core.Engine, g *compl
 	return taskIDs, nil
 }
 
-var _isTurbo = regexp.MustCompile(fmt.Sprintf("(?:^|%v|\\s)turbo(?:$|\\s)", regexp.QuoteMeta(string(filepath.Separator))))
-
-func commandLooksLikeTurbo(command string) bool {
-	return _isTurbo.MatchString(command)
-}
-
 func validateTasks(pipeline fs.Pipeline, tasks []string) error {
 	for _, task := range tasks {
 		if !pipeline.HasTask(task) {
@@ -971,9 +1528,16 @@ type execContext struct {
 	logger          hclog.Logger
 	packageManager  *packagemanager.PackageManager
 	processes       *process.Manager
+	dispatcher      dispatch.Dispatcher
 	taskHashes      *taskhash.Tracker
 	repoRoot        turbopath.AbsoluteSystemPath
 	isSinglePackage bool
+	engine          *core.Engine
+	gitMetadata     scm.Metadata
+	checkpoint      *checkpoint
+	terminal        *terminal
+	// globalEnv are the env vars declared for every task in turbo.json
+	globalEnv []string
 }
 
 func (ec *execContext) logError(log hclog.Logger, prefix string, err error) {
@@ -986,25 +1550,93 @@ func (ec *execContext) logError(log hclog.Logger, prefix string, err error) {
 	ec.ui.Error(fmt.Sprintf("%s%s%s", ui.ERROR_PREFIX, prefix, color.RedString(" %v", err)))
 }
 
+// taskEnviron returns the env vars, from turbo's own, that the process of a task
+// with the given definition starts with. With --strict-env, they are only the
+// ones the task or turbo.json declare, and the ones every process needs.
+func (ec *execContext) taskEnviron(taskDefinition *fs.TaskDefinition) ([]string, error) {
+	if !ec.rs.Opts.runOpts.strictEnv {
+		return os.Environ(), nil
+	}
+	allowed := append([]string{}, ec.globalEnv...)
+	allowed = append(allowed, taskDefinition.EnvVarDependencies...)
+	allowed = append(allowed, taskDefinition.PassThroughEnv...)
+	return env.StrictEnv(os.Environ(), allowed)
+}
+
 func (ec *execContext) exec(ctx gocontext.Context, packageTask *nodes.PackageTask, deps dag.Set) error {
+	t, done := ec.restore(ctx, packageTask, deps)
+	if done {
+		return nil
+	}
+	return ec.execute(ctx, t)
+}
+
+// preparedTask is a task whose outputs weren't restored from the cache, ready for
+// its script to run
+type preparedTask struct {
+	packageTask     *nodes.PackageTask
+	prettyPrefix    string
+	progressLogger  hclog.Logger
+	tracer          func(outcome RunResultStatus, err error)
+	retrying        func(err error) bool
+	passThroughArgs []string
+	hash            string
+	taskCache       runcache.TaskCache
+	foreground      bool
+	terminalWriter  io.Writer
+	prefixedUI      *cli.PrefixedUi
+}
+
+// restore hashes the given task and restores its outputs from the cache. It
+// returns true if the task is done, either because it was restored or doesn't
+// need to run, and otherwise the task, ready to be executed. Failing to restore
+// from the cache counts as a cache miss.
+func (ec *execContext) restore(ctx gocontext.Context, packageTask *nodes.PackageTask, deps dag.Set) (*preparedTask, bool) {
 	cmdTime := time.Now()
 
 	prefix := packageTask.OutputPrefix(ec.isSinglePackage)
-	prettyPrefix := ec.colorCache.PrefixWithColor(packageTask.PackageName, prefix)
+	prettyPrefix := ec.colorCache.PrefixWithColorIndex(ec.engine.TaskColor(packageTask.TaskID), prefix)
 
 	progressLogger := ec.logger.Named("")
 	progressLogger.Debug("start")
 
 	// Setup tracer
 	tracer := ec.runState.Run(packageTask.TaskID)
+	ec.runState.recordAttempts(packageTask.TaskID, ec.engine.Attempts(packageTask.TaskID))
+	// retrying reports whether the engine will run the task again after the given
+	// failure, in which case the failure isn't final
+	retrying := func(err error) bool {
+		if ec.engine.RetriesLeft(packageTask.TaskID) == 0 {
+			return false
+		}
+		tracer(TargetRetrying, err)
+		progressLogger.Warn("attempt failed, retrying", "attempt", ec.engine.Attempts(packageTask.TaskID), "error", err)
+		return true
+	}
 
 	passThroughArgs := ec.rs.ArgsForTask(packageTask.Task)
 	hash, err := ec.taskHashes.CalculateTaskHash(packageTask, deps, ec.logger, passThroughArgs)
 	ec.logger.Debug("task hash", "value", hash)
+	ec.runState.recordHash(packageTask.TaskID, hash)
+	ec.runState.recordExcludedOutputs(packageTask.TaskID, packageTask.TaskDefinition.Outputs.Exclusions)
+	if packageTask.TaskDefinition.Persistent || !packageTask.TaskDefinition.ShouldCache {
+		ec.runState.recordUncacheable(packageTask.TaskID)
+	}
 	if err != nil {
 		ec.ui.Error(fmt.Sprintf("Hashing error: %v", err))
 		// @TODO probably should abort fatally???
 	}
+	// Record completed tasks so that an interrupted run can be resumed. Persistent
+	// tasks never complete, so they are always restarted.
+	if !packageTask.TaskDefinition.Persistent {
+		runTracer := tracer
+		tracer = func(outcome RunResultStatus, err error) {
+			runTracer(outcome, err)
+			if err := ec.checkpoint.record(packageTask.TaskID, hash, outcome); err != nil {
+				progressLogger.Warn("failed to save checkpoint", "error", err)
+			}
+		}
+	}
 	// TODO(gsoltis): if/when we fix https://github.com/vercel/turbo/issues/937
 	// the following block should never get hit. In the meantime, keep it after hashing
 	// so that downstream tasks can count on the hash existing
@@ -1013,46 +1645,186 @@ func (ec *execContext) exec(ctx gocontext.Context, packageTask *nodes.PackageTas
 	if _, ok := packageTask.Command(); !ok {
 		progressLogger.Debug("no task in package, skipping")
 		progressLogger.Debug("done", "status", "skipped", "duration", time.Since(cmdTime))
-		return nil
+		return nil, true
+	}
+	// Record the input files that produced the hash, so that --inspect-hash can
+	// show which of them change before the next run
+	if hash != "" {
+		record, err := newInputRecord(ec.taskHashes, packageTask, hash)
+		if err == nil {
+			err = saveInputRecord(getInputRecordPath(ec.repoRoot, packageTask), record)
+		}
+		if err != nil {
+			progressLogger.Warn("failed to record input files", "error", err)
+		}
 	}
 	// Cache ---------------------------------------------
 	taskCache := ec.runCache.TaskCache(packageTask, hash)
+	foreground := ec.engine.IsForeground(packageTask.TaskID)
+	terminalWriter := ec.terminal.backgroundWriter()
+	taskUI := ec.ui
+	if len(ec.rs.Opts.runOpts.outputOrder) > 0 && !foreground {
+		// Hold back everything the task prints, including replayed logs, so that
+		// it is flushed in the configured order
+		terminalWriter = ec.engine.GroupedOutput(packageTask.TaskID, terminalWriter)
+		taskUI = groupedUI(terminalWriter)
+	}
 	// Create a logger for replaying
 	prefixedUI := &cli.PrefixedUi{
-		Ui:           ec.ui,
+		Ui:           taskUI,
 		OutputPrefix: prettyPrefix,
 		InfoPrefix:   prettyPrefix,
 		ErrorPrefix:  prettyPrefix,
 		WarnPrefix:   prettyPrefix,
 	}
-	hit, err := taskCache.RestoreOutputs(ctx, prefixedUI, progressLogger)
-	if err != nil {
-		prefixedUI.Error(fmt.Sprintf("error fetching from cache: %s", err))
-	} else if hit {
-		tracer(TargetCached, nil)
-		return nil
+	// Uncached tasks that completed before the resumed run was interrupted don't
+	// need to run again. Cached tasks are restored from the cache, which verifies
+	// that their outputs are still valid.
+	if !packageTask.TaskDefinition.ShouldCache && ec.checkpoint.completed(packageTask.TaskID, hash) {
+		prefixedUI.Output(fmt.Sprintf("completed before the run was interrupted, skipping %v", ui.Dim(hash)))
+		ec.engine.RecordDecision(packageTask.TaskID, core.DecisionSkipped, "completed before the resumed run was interrupted")
+		tracer(TargetResumed, nil)
+		return nil, true
+	}
+	// Some tasks don't trust their dependencies' outputs to be the same when the
+	// dependencies were executed, so they run regardless of their cache
+	var executedDeps []string
+	if packageTask.TaskDefinition.RerunOnDepExecution {
+		executedDeps = ec.engine.ExecutedDependencies(packageTask.TaskID)
+	}
+	forceRun := len(executedDeps) > 0
+	// Like make, tasks don't need to run if their output is newer than their inputs
+	if output := packageTask.TaskDefinition.SkipIfOutputNewerThan; output != "" && !forceRun {
+		inputFiles, err := ec.taskHashes.InputFiles(packageTask)
+		var fresh bool
+		if err == nil {
+			fresh, err = outputIsNewer(ec.repoRoot.UntypedJoin(packageTask.Pkg.Dir.ToStringDuringMigration()), output, inputFiles)
+		}
+		if err != nil {
+			prefixedUI.Warn(fmt.Sprintf("could not check whether %v is up to date: %v", output, err))
+		} else if fresh {
+			prefixedUI.Output(fmt.Sprintf("%v is newer than all inputs, skipping %v", output, ui.Dim(hash)))
+			ec.engine.RecordDecision(packageTask.TaskID, core.DecisionSkipped, fmt.Sprintf("%v is newer than all of its inputs", output))
+			tracer(TargetFresh, nil)
+			return nil, true
+		}
+	}
+	if forceRun {
+		prefixedUI.Output(fmt.Sprintf("%v executed, bypassing cache %v", strings.Join(executedDeps, ", "), ui.Dim(hash)))
+		ec.runState.recordCacheSource(packageTask.TaskID, cache.ItemStatus{})
+		ec.engine.RecordDecision(packageTask.TaskID, core.DecisionCacheMiss, fmt.Sprintf("dependencies were executed: %v", strings.Join(executedDeps, ", ")))
+	} else {
+		cacheStatus, err := taskCache.RestoreOutputs(ctx, prefixedUI, progressLogger)
+		ec.runState.recordCacheSource(packageTask.TaskID, cacheStatus)
+		if err != nil {
+			prefixedUI.Error(fmt.Sprintf("error fetching from cache: %s", err))
+			ec.engine.RecordDecision(packageTask.TaskID, core.DecisionCacheMiss, fmt.Sprintf("fetching from cache failed: %v", err))
+		} else if cacheStatus.Hit() {
+			ec.engine.RecordDecision(packageTask.TaskID, core.DecisionCached, fmt.Sprintf("found outputs for hash %v in cache", hash))
+			tracer(TargetCached, nil)
+			return nil, true
+		} else {
+			ec.engine.RecordDecision(packageTask.TaskID, core.DecisionCacheMiss, cacheMissReason(ec.rs, packageTask, hash))
+		}
 	}
 
+	return &preparedTask{
+		packageTask:     packageTask,
+		prettyPrefix:    prettyPrefix,
+		progressLogger:  progressLogger,
+		tracer:          tracer,
+		retrying:        retrying,
+		passThroughArgs: passThroughArgs,
+		hash:            hash,
+		taskCache:       taskCache,
+		foreground:      foreground,
+		terminalWriter:  terminalWriter,
+		prefixedUI:      prefixedUI,
+	}, false
+}
+
+// execute runs the script of a task that wasn't restored from the cache
+func (ec *execContext) execute(ctx gocontext.Context, t *preparedTask) error {
+	// The task's duration doesn't include waiting for a concurrency slot
+	cmdTime := time.Now()
+	packageTask := t.packageTask
+	prettyPrefix := t.prettyPrefix
+	progressLogger := t.progressLogger
+	tracer := t.tracer
+	retrying := t.retrying
+	passThroughArgs := t.passThroughArgs
+	hash := t.hash
+	taskCache := t.taskCache
+	foreground := t.foreground
+	terminalWriter := t.terminalWriter
+	prefixedUI := t.prefixedUI
+
 	// Setup command execution
-	argsactual := append([]string{"run"}, packageTask.Task)
-	if len(passThroughArgs) > 0 {
-		// This will be either '--' or a typed nil
-		argsactual = append(argsactual, ec.packageManager.ArgSeparator...)
-		argsactual = append(argsactual, passThroughArgs...)
-	}
-
-	cmd := exec.Command(ec.packageManager.Command, argsactual...)
-	// TODO: repoRoot probably should be AbsoluteSystemPath, but it's Join method
-	// takes a RelativeSystemPath. Resolve during migration from turbopath.AbsoluteSystemPath to
-	// AbsoluteSystemPath
-	cmd.Dir = ec.repoRoot.UntypedJoin(packageTask.Pkg.Dir.ToStringDuringMigration()).ToString()
+	argsactual := ec.packageManager.ScriptArgs(packageTask.Task, passThroughArgs)
+	// The task was hashed with its original command
+	command, argsactual, err := ec.engine.TransformCommand(packageTask.TaskID, ec.packageManager.Command, argsactual)
+	if err != nil {
+		tracer(TargetBuildFailed, err)
+		progressLogger.Error(fmt.Sprintf("Error: %v", err))
+		if !ec.rs.Opts.runOpts.continueOnError {
+			prefixedUI.Error(fmt.Sprintf("ERROR: %s", err))
+			ec.processes.Close()
+		} else {
+			prefixedUI.Warn("transforming the command failed, but continuing...")
+		}
+		return err
+	}
+
+	spec := dispatch.TaskSpec{
+		Command: command,
+		Args:    argsactual,
+		Dir:     packageTask.Pkg.Dir,
+		Hash:    hash,
+		Timeout: ec.engine.TaskTimeout(packageTask.TaskID),
+	}
+	environ, err := ec.taskEnviron(packageTask.TaskDefinition)
+	if err != nil {
+		tracer(TargetBuildFailed, err)
+		progressLogger.Error(fmt.Sprintf("Error: %v", err))
+		if !ec.rs.Opts.runOpts.continueOnError {
+			prefixedUI.Error(fmt.Sprintf("ERROR: %s", err))
+			ec.processes.Close()
+		} else {
+			prefixedUI.Warn("filtering env vars failed, but continuing...")
+		}
+		return err
+	}
 	envs := fmt.Sprintf("TURBO_HASH=%v", hash)
-	cmd.Env = append(os.Environ(), envs)
+	spec.Env = append(environ, envs)
+	if packageTask.Shell != "" {
+		// npm, pnpm and yarn v1 all read the script-shell setting from the environment
+		spec.Env = append(spec.Env, fmt.Sprintf("npm_config_script_shell=%v", packageTask.Shell))
+	}
+	spec.Env = append(spec.Env, ec.gitMetadata.EnvPairs(packageTask.TaskDefinition.GitEnv)...)
+	// The vars loaded from env files override turbo's own, since they are what the task was hashed with
+	dotEnvPairs, err := env.ReadDotEnv(packageTask.Pkg.Dir.RestoreAnchor(ec.repoRoot), ec.engine.TaskDotEnv(packageTask.TaskID))
+	if err != nil {
+		tracer(TargetBuildFailed, err)
+		progressLogger.Error(fmt.Sprintf("Error: %v", err))
+		if !ec.rs.Opts.runOpts.continueOnError {
+			prefixedUI.Error(fmt.Sprintf("ERROR: %s", err))
+			ec.processes.Close()
+		} else {
+			prefixedUI.Warn("loading env files failed, but continuing...")
+		}
+		return err
+	}
+	spec.Env = append(spec.Env, dotEnvPairs...)
 
 	// Setup stdout/stderr
+	if foreground {
+		// Foreground tasks write to the terminal directly below, and read from it
+		terminalWriter = io.Discard
+		spec.Stdin = os.Stdin
+	}
 	// If we are not caching anything, then we don't need to write logs to disk
 	// be careful about this conditional given the default of cache = true
-	writer, err := taskCache.OutputWriter(prettyPrefix)
+	writer, err := taskCache.OutputWriter(prettyPrefix, terminalWriter)
 	if err != nil {
 		tracer(TargetBuildFailed, err)
 		ec.logError(progressLogger, prettyPrefix, err)
@@ -1067,8 +1839,25 @@ func (ec *execContext) exec(ctx gocontext.Context, packageTask *nodes.PackageTas
 	logStreamerOut := logstreamer.NewLogstreamer(logger, prettyPrefix, false)
 	// Setup a streamer that we'll pipe cmd.Stderr to.
 	logStreamerErr := logstreamer.NewLogstreamer(logger, prettyPrefix, false)
-	cmd.Stderr = logStreamerErr
-	cmd.Stdout = logStreamerOut
+	spec.Stderr = logStreamerErr
+	spec.Stdout = logStreamerOut
+	if foreground {
+		// Output is shown as soon as it is written, rather than a line at a time,
+		// so that prompts without a trailing newline are visible
+		spec.Stdout = io.MultiWriter(spec.Stdout, ec.terminal.foregroundWriter())
+		spec.Stderr = io.MultiWriter(spec.Stderr, ec.terminal.foregroundWriter())
+	}
+	readinessPattern := packageTask.TaskDefinition.ReadinessPattern
+	if packageTask.TaskDefinition.Persistent && readinessPattern != "" {
+		var once sync.Once
+		onReady := func() {
+			once.Do(func() { ec.engine.MarkReady(packageTask.TaskID) })
+		}
+		// the pattern was validated when reading turbo.json
+		pattern := regexp.MustCompile(readinessPattern)
+		spec.Stdout = io.MultiWriter(spec.Stdout, newReadinessWriter(pattern, onReady))
+		spec.Stderr = io.MultiWriter(spec.Stderr, newReadinessWriter(pattern, onReady))
+	}
 	// Flush/Reset any error we recorded
 	logStreamerErr.FlushRecord()
 	logStreamerOut.FlushRecord()
@@ -1096,8 +1885,48 @@ func (ec *execContext) exec(ctx gocontext.Context, packageTask *nodes.PackageTas
 		return nil
 	}
 
+	// Persistent tasks without a readiness pattern or probe are ready as soon as
+	// they start. The engine marks tasks with a probe ready once it passes.
+	if packageTask.TaskDefinition.Persistent && readinessPattern == "" && packageTask.TaskDefinition.ReadinessProbe == nil {
+		ec.engine.MarkReady(packageTask.TaskID)
+	}
+
+	// Tasks with streaming outputs signal that they're ready by creating a file
+	if packageTask.TaskDefinition.StreamingOutputs {
+		readyFile := ec.repoRoot.UntypedJoin(packageTask.RepoRelativeReadyFile())
+		// A ready file left over from a previous run doesn't mean this run is ready
+		if err := readyFile.Remove(); err != nil && !os.IsNotExist(err) {
+			ec.logError(progressLogger, prettyPrefix, fmt.Errorf("removing stale ready file: %w", err))
+		}
+		if err := readyFile.EnsureDir(); err != nil {
+			ec.logError(progressLogger, prettyPrefix, err)
+		}
+		spec.Env = append(spec.Env, fmt.Sprintf("TURBO_READY_FILE=%v", readyFile))
+		stopWatching := watchReadyFile(readyFile, func() {
+			ec.engine.MarkReady(packageTask.TaskID)
+		})
+		defer stopWatching()
+	}
+
 	// Run the command
-	if err := ec.processes.Exec(cmd); err != nil {
+	var releaseTerminal func() error
+	if foreground {
+		releaseTerminal = ec.terminal.acquire()
+	}
+	// The engine stops a persistent task whose readiness probe times out
+	dispatchCtx, cancelDispatch := withStop(ctx, ec.engine.TaskContext(packageTask.TaskID))
+	result, err := ec.dispatcher.Dispatch(dispatchCtx, packageTask.TaskID, spec)
+	cancelDispatch()
+	if releaseTerminal != nil {
+		if err := releaseTerminal(); err != nil {
+			progressLogger.Warn("failed to write held back output", "error", err)
+		}
+	}
+	ec.runState.recordProcess(packageTask.TaskID, result)
+	if result.Ran {
+		ec.engine.MarkExecuted(packageTask.TaskID)
+	}
+	if err != nil {
 		// close off our outputs. We errored, so we mostly don't care if we fail to close
 		_ = closeOutputs()
 		// if we already know we're in the process of exiting,
@@ -1105,7 +1934,16 @@ func (ec *execContext) exec(ctx gocontext.Context, packageTask *nodes.PackageTas
 		if errors.Is(err, process.ErrClosing) {
 			return nil
 		}
-		tracer(TargetBuildFailed, err)
+		if retrying(err) {
+			prefixedUI.Warn(fmt.Sprintf("command finished with error, retrying: %s", err))
+			return err
+		}
+		if timeoutErr := (&process.ChildTimeout{}); errors.As(err, &timeoutErr) {
+			tracer(TargetTimedOut, err)
+		} else {
+			tracer(TargetBuildFailed, err)
+		}
+		taskCache.OnError(prefixedUI, progressLogger)
 		progressLogger.Error(fmt.Sprintf("Error: command finished with error: %v", err))
 		if !ec.rs.Opts.runOpts.continueOnError {
 			prefixedUI.Error(fmt.Sprintf("ERROR: command finished with error: %s", err))
@@ -1116,13 +1954,61 @@ func (ec *execContext) exec(ctx gocontext.Context, packageTask *nodes.PackageTas
 		return err
 	}
 
+	// Outputs that don't match their expected checksums must not be cached
+	if manifest := packageTask.TaskDefinition.OutputChecksums; manifest != "" {
+		mismatches, err := verifyOutputChecksums(ec.repoRoot.UntypedJoin(packageTask.Pkg.Dir.ToStringDuringMigration()), manifest)
+		if err == nil && len(mismatches) > 0 {
+			ec.runState.recordChecksumMismatches(packageTask.TaskID, mismatches)
+			for _, mismatch := range mismatches {
+				prefixedUI.Error(fmt.Sprintf("checksum mismatch: %v", mismatch))
+			}
+			err = fmt.Errorf("%v output files do not match the checksums in %v", len(mismatches), manifest)
+		}
+		if err != nil {
+			_ = closeOutputs()
+			if retrying(err) {
+				prefixedUI.Warn(fmt.Sprintf("verifying output checksums failed, retrying: %s", err))
+				return err
+			}
+			tracer(TargetBuildFailed, err)
+			taskCache.OnError(prefixedUI, progressLogger)
+			progressLogger.Error(fmt.Sprintf("Error: verifying output checksums: %v", err))
+			if !ec.rs.Opts.runOpts.continueOnError {
+				prefixedUI.Error(fmt.Sprintf("ERROR: verifying output checksums: %s", err))
+				ec.processes.Close()
+			} else {
+				prefixedUI.Warn("verifying output checksums failed, but continuing...")
+			}
+			return err
+		}
+	}
+
 	duration := time.Since(cmdTime)
 	// Close off our outputs and cache them
 	if err := closeOutputs(); err != nil {
 		ec.logError(progressLogger, "", err)
 	} else {
-		if err = taskCache.SaveOutputs(ctx, progressLogger, prefixedUI, int(duration.Milliseconds())); err != nil {
-			ec.logError(progressLogger, "", fmt.Errorf("error caching output: %w", err))
+		cachedOutputs, err := taskCache.SaveOutputs(ctx, progressLogger, prefixedUI, int(duration.Milliseconds()))
+		ec.runState.recordCachedOutputs(packageTask.TaskID, cachedOutputs)
+		if err != nil {
+			noOutputsErr := &runcache.NoOutputsError{}
+			if !errors.As(err, &noOutputsErr) {
+				ec.logError(progressLogger, "", fmt.Errorf("error caching output: %w", err))
+			} else if !ec.rs.Opts.runcacheOpts.StrictOutputs {
+				ec.runState.recordWarning(packageTask.TaskID, noOutputsErr.Error())
+			} else {
+				// With --strict-outputs, a task that produced none of its outputs fails
+				tracer(TargetBuildFailed, err)
+				taskCache.OnError(prefixedUI, progressLogger)
+				progressLogger.Error(fmt.Sprintf("Error: %v", err))
+				if !ec.rs.Opts.runOpts.continueOnError {
+					prefixedUI.Error(fmt.Sprintf("ERROR: %s", err))
+					ec.processes.Close()
+				} else {
+					prefixedUI.Warn("task produced no outputs, but continuing...")
+				}
+				return err
+			}
 		}
 	}
 
@@ -1132,7 +2018,59 @@ func (ec *execContext) exec(ctx gocontext.Context, packageTask *nodes.PackageTas
 	return nil
 }
 
-func (g *completeGraph) getPackageTaskVisitor(ctx gocontext.Context, visitor func(ctx gocontext.Context, packageTask *nodes.PackageTask) error) func(taskID string) error {
+// cacheMissReason explains why a task's outputs could not be restored from cache
+func cacheMissReason(rs *runSpec, packageTask *nodes.PackageTask, hash string) string {
+	if !packageTask.TaskDefinition.ShouldCache {
+		return "caching is disabled for this task"
+	}
+	if rs.Opts.runcacheOpts.SkipWrites {
+		return "the cache is disabled by --no-cache"
+	}
+	if rs.Opts.runcacheOpts.SkipReads {
+		return "reading from cache is disabled by --force"
+	}
+	if rs.Opts.cacheOpts.SkipLocalReads && rs.Opts.cacheOpts.SkipRemoteReads {
+		return "reading from cache is disabled by --local-cache-read and --remote-cache-read"
+	}
+	return fmt.Sprintf("no outputs for hash %v in cache", hash)
+}
+
+// taskDependencies returns the tasks the given task depends on, including the
+// streaming dependencies that aren't edges in the task graph, and the dependencies
+// that --only assumes are built
+func taskDependencies(engine *core.Engine, taskID string) dag.Set {
+	streamingDeps := engine.StreamingDependencies(taskID)
+	if len(streamingDeps) == 0 {
+		return engine.HashGraph().DownEdges(taskID)
+	}
+	deps := make(dag.Set)
+	for _, dep := range engine.HashGraph().DownEdges(taskID).List() {
+		deps.Add(dep)
+	}
+	for _, dep := range streamingDeps {
+		deps.Add(dep)
+	}
+	return deps
+}
+
+// hashAssumedBuiltTasks calculates the hashes of the tasks that --only assumes are
+// built, without running them, so that they are part of the hashes of the tasks
+// that depend on them
+func (g *completeGraph) hashAssumedBuiltTasks(ctx gocontext.Context, engine *core.Engine, taskHashes *taskhash.Tracker, rs *runSpec, logger hclog.Logger) error {
+	visitor := g.getPackageTaskVisitor(ctx, engine, func(ctx gocontext.Context, packageTask *nodes.PackageTask) error {
+		deps := taskDependencies(engine, packageTask.TaskID)
+		_, err := taskHashes.CalculateTaskHash(packageTask, deps, logger, rs.ArgsForTask(packageTask.Task))
+		return err
+	})
+	for _, taskID := range engine.AssumedBuilt() {
+		if err := visitor(taskID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *completeGraph) getPackageTaskVisitor(ctx gocontext.Context, engine *core.Engine, visitor func(ctx gocontext.Context, packageTask *nodes.PackageTask) error) func(taskID string) error {
 	return func(taskID string) error {
 
 		name, task := util.GetPackageTaskFromId(taskID)
@@ -1153,12 +2091,26 @@ func (g *completeGraph) getPackageTaskVisitor(ctx gocontext.Context, visitor fun
 			// override if we need to...
 			taskDefinition = fallbackTaskDefinition
 		}
+		var dependencyOutputs []string
+		for depTaskID, outputs := range engine.DependencyOutputs(taskID) {
+			depName, _ := util.GetPackageTaskFromId(depTaskID)
+			depPkg, ok := g.PackageInfos[depName]
+			if !ok {
+				return fmt.Errorf("cannot find package %v for task %v", depName, depTaskID)
+			}
+			for _, output := range outputs {
+				dependencyOutputs = append(dependencyOutputs, fs.RepoRelativeOutput(depPkg.Dir, output))
+			}
+		}
+		sort.Strings(dependencyOutputs)
 		return visitor(ctx, &nodes.PackageTask{
-			TaskID:         taskID,
-			Task:           task,
-			PackageName:    name,
-			Pkg:            pkg,
-			TaskDefinition: &taskDefinition,
+			TaskID:            taskID,
+			Task:              task,
+			PackageName:       name,
+			Pkg:               pkg,
+			TaskDefinition:    &taskDefinition,
+			Shell:             engine.TaskShell(taskID),
+			DependencyOutputs: dependencyOutputs,
 		})
 	}
 }
@@ -2,11 +2,14 @@ package run
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
-	"github.com/vercel/turbo/cli/internal/chrometracing"
-	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/cache"
+	"github.com/vercel/turbo/cli/internal/dispatch"
+	"github.com/vercel/turbo/cli/internal/turbopath"
 	"github.com/vercel/turbo/cli/internal/ui"
 	"github.com/vercel/turbo/cli/internal/util"
 
@@ -38,8 +41,62 @@ const (
 	TargetBuilt
 	TargetCached
 	TargetBuildFailed
+	// TargetFresh is a target that was skipped because its output was newer than its inputs
+	TargetFresh
+	// TargetResumed is a target that was skipped because it completed in the
+	// interrupted run being resumed
+	TargetResumed
+	// TargetTimedOut is a target that failed by running longer than its timeout
+	TargetTimedOut
+	// TargetRetrying is a target whose attempt at running failed, and that will
+	// be run again
+	TargetRetrying
 )
 
+func (s RunResultStatus) String() string {
+	switch s {
+	case TargetBuilding:
+		return "building"
+	case TargetBuildStopped:
+		return "stopped"
+	case TargetBuilt:
+		return "built"
+	case TargetCached:
+		return "cached"
+	case TargetBuildFailed:
+		return "failed"
+	case TargetFresh:
+		return "fresh"
+	case TargetResumed:
+		return "resumed"
+	case TargetTimedOut:
+		return "timedOut"
+	case TargetRetrying:
+		return "retrying"
+	}
+	return "unknown"
+}
+
+// CacheSource is where a target's outputs were restored from, if anywhere
+type CacheSource int
+
+// The possible cache sources of a target
+const (
+	CacheMiss CacheSource = iota
+	CacheHitLocal
+	CacheHitRemote
+)
+
+func (c CacheSource) String() string {
+	switch c {
+	case CacheHitLocal:
+		return "HitLocal"
+	case CacheHitRemote:
+		return "HitRemote"
+	}
+	return "Miss"
+}
+
 type BuildTargetState struct {
 	StartAt time.Time
 
@@ -50,6 +107,46 @@ type BuildTargetState struct {
 	Status RunResultStatus
 	// Error, only populated for failure statuses
 	Err error
+	// ExitCode of the task's process, only populated if a process was run
+	ExitCode int
+	// PeakMemory is the maximum resident set size in bytes of the task's process,
+	// only populated if a process was run and the platform reports it
+	PeakMemory uint64
+	// ranProcess is true if a process was run for this target
+	ranProcess bool
+	// Attempts is how many times the target was run, more than once if it was
+	// retried after failing
+	Attempts int
+	// CacheBytesUploaded is the number of bytes of this target's outputs uploaded
+	// to the remote cache. Zero if remote caching was skipped.
+	CacheBytesUploaded int64
+	// CacheBytesDownloaded is the number of bytes of this target's outputs downloaded
+	// from the remote cache. Zero if remote caching was skipped.
+	CacheBytesDownloaded int64
+	// Cache is where the target's outputs were restored from, only populated
+	// if the cache was checked
+	Cache CacheSource
+	// cacheChecked is true if the target's outputs were looked up in the cache
+	cacheChecked bool
+	// uncacheable is true if the target never caches its outputs, such as a
+	// persistent task
+	uncacheable bool
+	// CacheDownloadTime is how long it took to restore the target's outputs from
+	// the remote cache, only populated for remote hits
+	CacheDownloadTime time.Duration
+	// hash is the target's task hash, used to look up its cache transfers
+	hash string
+	// ChecksumMismatches are the outputs that didn't match the target's checksum manifest
+	ChecksumMismatches []ChecksumMismatch
+	// ExcludedOutputs are the globs of the target's outputs that are neither
+	// cached nor restored
+	ExcludedOutputs []string
+	// CachedOutputs are the files and folders saved to the cache for the target,
+	// after exclusions, relative to the repo root
+	CachedOutputs []string
+	// Warnings are problems with the target that didn't fail it, such as declared
+	// outputs that matched no files
+	Warnings []string
 }
 
 type RunState struct {
@@ -57,19 +154,27 @@ type RunState struct {
 	state   map[string]*BuildTargetState
 	Success int
 	Failure int
+	// TimedOut counts the failures that were due to a timeout
+	TimedOut int
 	// Is the output streaming?
 	Cached    int
+	Fresh     int
+	Resumed   int
 	Attempted int
+	// Bytes transferred to and from the remote cache across all targets
+	CacheBytesUploaded   int64
+	CacheBytesDownloaded int64
+	// Cache lookups across all targets, by where the outputs were found
+	LocalHits  int
+	RemoteHits int
+	Misses     int
 
 	startedAt time.Time
 }
 
 // NewRunState creates a RunState instance for tracking events during the
 // course of a run.
-func NewRunState(startedAt time.Time, tracingProfile string) *RunState {
-	if tracingProfile != "" {
-		chrometracing.EnableTracing()
-	}
+func NewRunState(startedAt time.Time) *RunState {
 	return &RunState{
 		Success:   0,
 		Failure:   0,
@@ -88,9 +193,7 @@ func (r *RunState) Run(label string) func(outcome RunResultStatus, err error) {
 		Label:  label,
 		Status: TargetBuilding,
 	}, label, true)
-	tracer := chrometracing.Event(label)
 	return func(outcome RunResultStatus, err error) {
-		defer tracer.Done()
 		now := time.Now()
 		result := &RunResult{
 			Time:     now,
@@ -125,56 +228,280 @@ func (r *RunState) add(result *RunResult, previous string, active bool) {
 	case result.Status == TargetBuildFailed:
 		r.Failure++
 		r.Attempted++
+	case result.Status == TargetTimedOut:
+		r.Failure++
+		r.TimedOut++
+		r.Attempted++
 	case result.Status == TargetCached:
 		r.Cached++
 		r.Attempted++
+	case result.Status == TargetFresh:
+		r.Fresh++
+		r.Attempted++
+	case result.Status == TargetResumed:
+		r.Resumed++
+		r.Attempted++
 	case result.Status == TargetBuilt:
 		r.Success++
 		r.Attempted++
 	}
 }
 
-// Close finishes a trace of a turbo run. The tracing file will be written if applicable,
-// and run stats are written to the terminal
-func (r *RunState) Close(terminal cli.Ui, filename string) error {
-	if err := writeChrometracing(filename, terminal); err != nil {
-		terminal.Error(fmt.Sprintf("Error writing tracing data: %v", err))
+// recordProcess records the exit code and resource usage of the process run for
+// the given target
+func (r *RunState) recordProcess(label string, result dispatch.Result) {
+	if !result.Ran {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.state[label]
+	if !ok {
+		return
+	}
+	s.ranProcess = true
+	s.ExitCode = result.ExitCode
+	s.PeakMemory = result.PeakMemory
+}
+
+// recordAttempts records how many times the given target has been run
+func (r *RunState) recordAttempts(label string, attempts int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if s, ok := r.state[label]; ok {
+		s.Attempts = attempts
+	}
+}
+
+// recordHash records the task hash calculated for the given target
+func (r *RunState) recordHash(label string, hash string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if s, ok := r.state[label]; ok {
+		s.hash = hash
+	}
+}
+
+// traceArgs returns the args of the given target's event in a profile of the
+// run: where its outputs were restored from, if its cache was checked
+func (r *RunState) traceArgs(label string) map[string]string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.state[label]
+	if !ok || !s.cacheChecked {
+		return nil
+	}
+	return map[string]string{"cache": s.Cache.String()}
+}
+
+// recordCacheSource records where the given target's outputs were restored from,
+// given the status of its cache lookup
+func (r *RunState) recordCacheSource(label string, status cache.ItemStatus) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	source := CacheMiss
+	switch {
+	case status.Local:
+		source = CacheHitLocal
+		r.LocalHits++
+	case status.Remote:
+		source = CacheHitRemote
+		r.RemoteHits++
+	default:
+		r.Misses++
+	}
+	if s, ok := r.state[label]; ok {
+		s.Cache = source
+		s.cacheChecked = true
+	}
+}
+
+// recordUncacheable records that the given target never caches its outputs, so
+// it can't be expected to be a cache hit
+func (r *RunState) recordUncacheable(label string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if s, ok := r.state[label]; ok {
+		s.uncacheable = true
+	}
+}
+
+// recordCacheTransfers records the bytes transferred to and from the remote cache
+// for each target, by its hash. It should be called once all cache operations
+// have finished.
+func (r *RunState) recordCacheTransfers(stats *cache.TransferStats) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.CacheBytesUploaded = 0
+	r.CacheBytesDownloaded = 0
+	for _, s := range r.state {
+		if s.hash == "" {
+			continue
+		}
+		s.CacheBytesUploaded = stats.Uploaded(s.hash)
+		s.CacheBytesDownloaded = stats.Downloaded(s.hash)
+		if s.Cache == CacheHitRemote {
+			s.CacheDownloadTime = stats.DownloadTime(s.hash)
+		}
+		r.CacheBytesUploaded += s.CacheBytesUploaded
+		r.CacheBytesDownloaded += s.CacheBytesDownloaded
+	}
+}
+
+// recordExcludedOutputs records the globs of the given target's outputs that
+// are neither cached nor restored
+func (r *RunState) recordExcludedOutputs(label string, excludedOutputs []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if s, ok := r.state[label]; ok {
+		s.ExcludedOutputs = excludedOutputs
+	}
+}
+
+// recordCachedOutputs records the files and folders saved to the cache for the
+// given target
+func (r *RunState) recordCachedOutputs(label string, cachedOutputs []turbopath.AnchoredSystemPath) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if s, ok := r.state[label]; ok {
+		s.CachedOutputs = make([]string, len(cachedOutputs))
+		for i, output := range cachedOutputs {
+			s.CachedOutputs[i] = output.ToUnixPath().ToString()
+		}
+		sort.Strings(s.CachedOutputs)
 	}
+}
 
+// recordChecksumMismatches records the outputs of the given target that didn't
+// match its checksum manifest
+func (r *RunState) recordChecksumMismatches(label string, mismatches []ChecksumMismatch) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if s, ok := r.state[label]; ok {
+		s.ChecksumMismatches = mismatches
+	}
+}
+
+// recordWarning records a problem with the given target that didn't fail it
+func (r *RunState) recordWarning(label string, warning string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if s, ok := r.state[label]; ok {
+		s.Warnings = append(s.Warnings, warning)
+	}
+}
+
+// Close finishes a turbo run. The profile of the run is written to filename if
+// it isn't empty, and run stats are written to the terminal
+func (r *RunState) Close(terminal cli.Ui) error {
 	maybeFullTurbo := ""
 	if r.Cached == r.Attempted && r.Attempted > 0 {
 		maybeFullTurbo = ui.Rainbow(">>> FULL TURBO")
 	}
 	terminal.Output("") // Clear the line
-	terminal.Output(util.Sprintf("${BOLD} Tasks:${BOLD_GREEN}    %v successful${RESET}${GRAY}, %v total${RESET}", r.Cached+r.Success, r.Attempted))
+	r.outputChecksumMismatches(terminal)
+	terminal.Output(util.Sprintf("${BOLD} Tasks:${BOLD_GREEN}    %v successful${RESET}${GRAY}, %v total${RESET}", r.Cached+r.Fresh+r.Resumed+r.Success, r.Attempted))
 	terminal.Output(util.Sprintf("${BOLD}Cached:    %v cached${RESET}${GRAY}, %v total${RESET}", r.Cached, r.Attempted))
+	if r.LocalHits+r.RemoteHits+r.Misses > 0 {
+		terminal.Output(util.Sprintf("${BOLD}Source:    %v local hits${RESET}${GRAY}, %v remote hits, %v misses${RESET}", r.LocalHits, r.RemoteHits, r.Misses))
+	}
+	if r.Fresh > 0 {
+		terminal.Output(util.Sprintf("${BOLD} Fresh:    %v up to date${RESET}${GRAY}, %v total${RESET}", r.Fresh, r.Attempted))
+	}
+	if r.Resumed > 0 {
+		terminal.Output(util.Sprintf("${BOLD}Resume:    %v from checkpoint${RESET}${GRAY}, %v total${RESET}", r.Resumed, r.Attempted))
+	}
+	if retried := r.retried(); retried > 0 {
+		terminal.Output(util.Sprintf("${BOLD} Retry:    %v retried${RESET}${GRAY}, %v total${RESET}", retried, r.Attempted))
+	}
+	if r.TimedOut > 0 {
+		terminal.Output(util.Sprintf("${BOLD}Killed:    %v timed out${RESET}${GRAY}, %v total${RESET}", r.TimedOut, r.Attempted))
+	}
+	if failed := r.failed(); len(failed) > 0 {
+		terminal.Output(util.Sprintf("${BOLD}Failed:    ${BOLD_RED}%v${RESET}", strings.Join(failed, ", ")))
+	}
 	terminal.Output(util.Sprintf("${BOLD}  Time:    %v${RESET} %v${RESET}", time.Since(r.startedAt).Truncate(time.Millisecond), maybeFullTurbo))
+	if r.CacheBytesUploaded > 0 || r.CacheBytesDownloaded > 0 {
+		terminal.Output(util.Sprintf("${BOLD}Remote:    %v uploaded${RESET}${GRAY}, %v downloaded${RESET}", formatBytes(r.CacheBytesUploaded), formatBytes(r.CacheBytesDownloaded)))
+	}
 	terminal.Output("")
 	return nil
 }
 
-func writeChrometracing(filename string, terminal cli.Ui) error {
-	outputPath := chrometracing.Path()
-	if outputPath == "" {
-		// tracing wasn't enabled
-		return nil
+// failed returns the sorted labels of the targets that failed
+func (r *RunState) failed() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	failed := []string{}
+	for label, s := range r.state {
+		if s.Status == TargetBuildFailed || s.Status == TargetTimedOut {
+			failed = append(failed, label)
+		}
+	}
+	sort.Strings(failed)
+	return failed
+}
+
+// cacheMisses returns the sorted labels of the targets that were looked up in the
+// cache and missed, ignoring the targets that never cache their outputs
+func (r *RunState) cacheMisses() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	misses := []string{}
+	for label, s := range r.state {
+		if s.cacheChecked && s.Cache == CacheMiss && !s.uncacheable {
+			misses = append(misses, label)
+		}
+	}
+	sort.Strings(misses)
+	return misses
+}
+
+// retried returns the number of targets that were run more than once
+func (r *RunState) retried() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	retried := 0
+	for _, s := range r.state {
+		if s.Attempts > 1 {
+			retried++
+		}
 	}
+	return retried
+}
 
-	name := fmt.Sprintf("turbo-%s.trace", time.Now().Format(time.RFC3339))
-	if filename != "" {
-		name = filename
+// formatBytes formats a number of bytes using binary units (e.g. 1.5 MiB)
+func formatBytes(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
 	}
-	if err := chrometracing.Close(); err != nil {
-		terminal.Warn(fmt.Sprintf("Failed to flush tracing data: %v", err))
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
 	}
-	root, err := fs.GetCwd()
-	if err != nil {
-		return err
+	return fmt.Sprintf("%.1f %ciB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
+
+// outputChecksumMismatches writes the outputs that didn't match their checksum
+// manifest for each target to the terminal
+func (r *RunState) outputChecksumMismatches(terminal cli.Ui) {
+	labels := []string{}
+	for label, s := range r.state {
+		if len(s.ChecksumMismatches) > 0 {
+			labels = append(labels, label)
+		}
 	}
-	// chrometracing.Path() is absolute by default, but can still be relative if overriden via $CHROMETRACING_DIR
-	// so we have to account for that before converting to turbopath.AbsoluteSystemPath
-	if err := fs.CopyFile(&fs.LstatCachedFile{Path: fs.ResolveUnknownPath(root, outputPath)}, name); err != nil {
-		return err
+	if len(labels) == 0 {
+		return
 	}
-	return nil
+	sort.Strings(labels)
+	for _, label := range labels {
+		terminal.Output(util.Sprintf("${BOLD}Checksum mismatches in %v:${RESET}", label))
+		for _, mismatch := range r.state[label].ChecksumMismatches {
+			terminal.Output(util.Sprintf("${RED}  %v${RESET}", mismatch))
+		}
+	}
+	terminal.Output("")
 }
@@ -0,0 +1,99 @@
+package run
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vercel/turbo/cli/internal/cache"
+)
+
+func TestRecordCacheTransfersWithoutRemoteCache(t *testing.T) {
+	r := NewRunState(time.Now())
+	r.Run("web#build")(TargetBuilt, nil)
+	r.recordHash("web#build", "some-hash")
+
+	r.recordCacheTransfers(cache.NewTransferStats())
+	assert.Equal(t, int64(0), r.state["web#build"].CacheBytesUploaded)
+	assert.Equal(t, int64(0), r.state["web#build"].CacheBytesDownloaded)
+	assert.Equal(t, int64(0), r.CacheBytesUploaded)
+	assert.Equal(t, int64(0), r.CacheBytesDownloaded)
+}
+
+func TestRecordCacheSource(t *testing.T) {
+	r := NewRunState(time.Now())
+	r.Run("web#build")(TargetCached, nil)
+	r.recordHash("web#build", "web-hash")
+	r.recordCacheSource("web#build", cache.ItemStatus{Local: true})
+	r.Run("docs#build")(TargetCached, nil)
+	r.recordHash("docs#build", "docs-hash")
+	r.recordCacheSource("docs#build", cache.ItemStatus{Remote: true})
+	r.Run("ui#build")(TargetBuilt, nil)
+	r.recordHash("ui#build", "ui-hash")
+	r.recordCacheSource("ui#build", cache.ItemStatus{})
+
+	assert.Equal(t, CacheHitLocal, r.state["web#build"].Cache)
+	assert.Equal(t, CacheHitRemote, r.state["docs#build"].Cache)
+	assert.Equal(t, CacheMiss, r.state["ui#build"].Cache)
+	assert.Equal(t, "HitRemote", r.state["docs#build"].Cache.String())
+	assert.Equal(t, 1, r.LocalHits)
+	assert.Equal(t, 1, r.RemoteHits)
+	assert.Equal(t, 1, r.Misses)
+
+	// Only remote hits have a download time
+	r.recordCacheTransfers(cache.NewTransferStats())
+	assert.Equal(t, time.Duration(0), r.state["web#build"].CacheDownloadTime)
+}
+
+func TestTraceArgs(t *testing.T) {
+	r := NewRunState(time.Now())
+	r.Run("web#build")(TargetCached, nil)
+	r.recordCacheSource("web#build", cache.ItemStatus{Local: true})
+	r.Run("docs#build")(TargetCached, nil)
+	r.recordCacheSource("docs#build", cache.ItemStatus{Remote: true})
+	r.Run("ui#build")(TargetBuilt, nil)
+	r.recordCacheSource("ui#build", cache.ItemStatus{})
+	// Fresh tasks are never looked up in the cache
+	r.Run("docs#lint")(TargetFresh, nil)
+
+	assert.Equal(t, map[string]string{"cache": "HitLocal"}, r.traceArgs("web#build"))
+	assert.Equal(t, map[string]string{"cache": "HitRemote"}, r.traceArgs("docs#build"))
+	assert.Equal(t, map[string]string{"cache": "Miss"}, r.traceArgs("ui#build"))
+	assert.Nil(t, r.traceArgs("docs#lint"))
+	assert.Nil(t, r.traceArgs("api#build"))
+}
+
+func TestFormatBytes(t *testing.T) {
+	assert.Equal(t, "512 B", formatBytes(512))
+	assert.Equal(t, "1.5 KiB", formatBytes(1536))
+	assert.Equal(t, "3.0 MiB", formatBytes(3*1024*1024))
+}
+
+func TestFailedTasks(t *testing.T) {
+	r := NewRunState(time.Now())
+	r.Run("web#build")(TargetBuildFailed, nil)
+	r.Run("docs#build")(TargetBuilt, nil)
+	r.Run("api#test")(TargetTimedOut, nil)
+
+	assert.Equal(t, []string{"api#test", "web#build"}, r.failed())
+}
+
+func TestCacheMisses(t *testing.T) {
+	r := NewRunState(time.Now())
+	r.Run("web#build")(TargetCached, nil)
+	r.recordCacheSource("web#build", cache.ItemStatus{Local: true})
+	r.Run("docs#build")(TargetBuilt, nil)
+	r.recordCacheSource("docs#build", cache.ItemStatus{})
+	r.Run("api#build")(TargetCached, nil)
+	r.recordCacheSource("api#build", cache.ItemStatus{Remote: true})
+	r.Run("api#test")(TargetBuilt, nil)
+	r.recordCacheSource("api#test", cache.ItemStatus{})
+	// Persistent tasks never cache, so they always miss
+	r.Run("web#dev")(TargetBuilding, nil)
+	r.recordUncacheable("web#dev")
+	r.recordCacheSource("web#dev", cache.ItemStatus{})
+	// Fresh tasks are never looked up in the cache
+	r.Run("docs#lint")(TargetFresh, nil)
+
+	assert.Equal(t, []string{"api#test", "docs#build"}, r.cacheMisses())
+}
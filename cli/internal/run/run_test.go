@@ -2,13 +2,16 @@ package run
 
 import (
 	"fmt"
+	"os"
 	"runtime"
 	"testing"
 
 	"github.com/pyr-sh/dag"
 	"github.com/spf13/pflag"
 	"github.com/vercel/turbo/cli/internal/cache"
+	"github.com/vercel/turbo/cli/internal/cacheitem"
 	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/packagemanager"
 	"github.com/vercel/turbo/cli/internal/runcache"
 	"github.com/vercel/turbo/cli/internal/scope"
 	"github.com/vercel/turbo/cli/internal/util"
@@ -33,10 +36,12 @@ func TestParseConfig(t *testing.T) {
 			[]string{"foo"},
 			&Opts{
 				runOpts: runOpts{
-					concurrency: 10,
+					frameworkInference: true,
+					concurrency:        10,
 				},
 				cacheOpts: cache.Opts{
-					Workers: 10,
+					Workers:     10,
+					Compression: cacheitem.Compression{Algorithm: cacheitem.CompressionZstd},
 				},
 				runcacheOpts: runcache.Opts{},
 				scopeOpts:    scope.Opts{},
@@ -48,10 +53,12 @@ func TestParseConfig(t *testing.T) {
 			[]string{"foo", "--scope=foo", "--scope=blah"},
 			&Opts{
 				runOpts: runOpts{
-					concurrency: 10,
+					frameworkInference: true,
+					concurrency:        10,
 				},
 				cacheOpts: cache.Opts{
-					Workers: 10,
+					Workers:     10,
+					Compression: cacheitem.Compression{Algorithm: cacheitem.CompressionZstd},
 				},
 				runcacheOpts: runcache.Opts{},
 				scopeOpts: scope.Opts{
@@ -67,10 +74,12 @@ func TestParseConfig(t *testing.T) {
 			[]string{"foo", "--concurrency=12"},
 			&Opts{
 				runOpts: runOpts{
-					concurrency: 12,
+					frameworkInference: true,
+					concurrency:        12,
 				},
 				cacheOpts: cache.Opts{
-					Workers: 10,
+					Workers:     10,
+					Compression: cacheitem.Compression{Algorithm: cacheitem.CompressionZstd},
 				},
 				runcacheOpts: runcache.Opts{},
 				scopeOpts:    scope.Opts{},
@@ -82,10 +91,12 @@ func TestParseConfig(t *testing.T) {
 			[]string{"foo", "--concurrency=100%"},
 			&Opts{
 				runOpts: runOpts{
-					concurrency: cpus,
+					frameworkInference: true,
+					concurrency:        cpus,
 				},
 				cacheOpts: cache.Opts{
-					Workers: 10,
+					Workers:     10,
+					Compression: cacheitem.Compression{Algorithm: cacheitem.CompressionZstd},
 				},
 				runcacheOpts: runcache.Opts{},
 				scopeOpts:    scope.Opts{},
@@ -97,12 +108,14 @@ func TestParseConfig(t *testing.T) {
 			[]string{"foo", "--graph=g.png"},
 			&Opts{
 				runOpts: runOpts{
-					concurrency: 10,
-					graphFile:   "g.png",
-					graphDot:    false,
+					frameworkInference: true,
+					concurrency:        10,
+					graphFile:          "g.png",
+					graphDot:           false,
 				},
 				cacheOpts: cache.Opts{
-					Workers: 10,
+					Workers:     10,
+					Compression: cacheitem.Compression{Algorithm: cacheitem.CompressionZstd},
 				},
 				runcacheOpts: runcache.Opts{},
 				scopeOpts:    scope.Opts{},
@@ -114,12 +127,14 @@ func TestParseConfig(t *testing.T) {
 			[]string{"foo", "--graph"},
 			&Opts{
 				runOpts: runOpts{
-					concurrency: 10,
-					graphFile:   "",
-					graphDot:    true,
+					frameworkInference: true,
+					concurrency:        10,
+					graphFile:          "",
+					graphDot:           true,
 				},
 				cacheOpts: cache.Opts{
-					Workers: 10,
+					Workers:     10,
+					Compression: cacheitem.Compression{Algorithm: cacheitem.CompressionZstd},
 				},
 				runcacheOpts: runcache.Opts{},
 				scopeOpts:    scope.Opts{},
@@ -131,13 +146,15 @@ func TestParseConfig(t *testing.T) {
 			[]string{"foo", "--graph=g.png", "--", "--boop", "zoop"},
 			&Opts{
 				runOpts: runOpts{
-					concurrency:     10,
-					graphFile:       "g.png",
-					graphDot:        false,
-					passThroughArgs: []string{"--boop", "zoop"},
+					frameworkInference: true,
+					concurrency:        10,
+					graphFile:          "g.png",
+					graphDot:           false,
+					passThroughArgs:    []string{"--boop", "zoop"},
 				},
 				cacheOpts: cache.Opts{
-					Workers: 10,
+					Workers:     10,
+					Compression: cacheitem.Compression{Algorithm: cacheitem.CompressionZstd},
 				},
 				runcacheOpts: runcache.Opts{},
 				scopeOpts:    scope.Opts{},
@@ -149,10 +166,12 @@ func TestParseConfig(t *testing.T) {
 			[]string{"foo", "--force"},
 			&Opts{
 				runOpts: runOpts{
-					concurrency: 10,
+					frameworkInference: true,
+					concurrency:        10,
 				},
 				cacheOpts: cache.Opts{
-					Workers: 10,
+					Workers:     10,
+					Compression: cacheitem.Compression{Algorithm: cacheitem.CompressionZstd},
 				},
 				runcacheOpts: runcache.Opts{
 					SkipReads: true,
@@ -166,10 +185,12 @@ func TestParseConfig(t *testing.T) {
 			[]string{"foo", "--remote-only"},
 			&Opts{
 				runOpts: runOpts{
-					concurrency: 10,
+					frameworkInference: true,
+					concurrency:        10,
 				},
 				cacheOpts: cache.Opts{
 					Workers:        10,
+					Compression:    cacheitem.Compression{Algorithm: cacheitem.CompressionZstd},
 					SkipFilesystem: true,
 				},
 				runcacheOpts: runcache.Opts{},
@@ -177,15 +198,36 @@ func TestParseConfig(t *testing.T) {
 			},
 			[]string{"foo"},
 		},
+		{
+			"cache permissions",
+			[]string{"foo", "--remote-cache-read=false", "--local-cache-write=false", "--local-cache-read"},
+			&Opts{
+				runOpts: runOpts{
+					frameworkInference: true,
+					concurrency:        10,
+				},
+				cacheOpts: cache.Opts{
+					Workers:         10,
+					Compression:     cacheitem.Compression{Algorithm: cacheitem.CompressionZstd},
+					SkipLocalWrites: true,
+					SkipRemoteReads: true,
+				},
+				runcacheOpts: runcache.Opts{},
+				scopeOpts:    scope.Opts{},
+			},
+			[]string{"foo"},
+		},
 		{
 			"no-cache",
 			[]string{"foo", "--no-cache"},
 			&Opts{
 				runOpts: runOpts{
-					concurrency: 10,
+					frameworkInference: true,
+					concurrency:        10,
 				},
 				cacheOpts: cache.Opts{
-					Workers: 10,
+					Workers:     10,
+					Compression: cacheitem.Compression{Algorithm: cacheitem.CompressionZstd},
 				},
 				runcacheOpts: runcache.Opts{
 					SkipWrites: true,
@@ -199,13 +241,15 @@ func TestParseConfig(t *testing.T) {
 			[]string{"foo", "--graph=g.png", "--"},
 			&Opts{
 				runOpts: runOpts{
-					concurrency:     10,
-					graphFile:       "g.png",
-					graphDot:        false,
-					passThroughArgs: []string{},
+					frameworkInference: true,
+					concurrency:        10,
+					graphFile:          "g.png",
+					graphDot:           false,
+					passThroughArgs:    []string{},
 				},
 				cacheOpts: cache.Opts{
-					Workers: 10,
+					Workers:     10,
+					Compression: cacheitem.Compression{Algorithm: cacheitem.CompressionZstd},
 				},
 				runcacheOpts: runcache.Opts{},
 				scopeOpts:    scope.Opts{},
@@ -217,10 +261,12 @@ func TestParseConfig(t *testing.T) {
 			[]string{"foo", "--filter=bar", "--filter=...[main]"},
 			&Opts{
 				runOpts: runOpts{
-					concurrency: 10,
+					frameworkInference: true,
+					concurrency:        10,
 				},
 				cacheOpts: cache.Opts{
-					Workers: 10,
+					Workers:     10,
+					Compression: cacheitem.Compression{Algorithm: cacheitem.CompressionZstd},
 				},
 				runcacheOpts: runcache.Opts{},
 				scopeOpts: scope.Opts{
@@ -234,11 +280,13 @@ func TestParseConfig(t *testing.T) {
 			[]string{"foo", "--continue"},
 			&Opts{
 				runOpts: runOpts{
-					continueOnError: true,
-					concurrency:     10,
+					frameworkInference: true,
+					continueOnError:    true,
+					concurrency:        10,
 				},
 				cacheOpts: cache.Opts{
-					Workers: 10,
+					Workers:     10,
+					Compression: cacheitem.Compression{Algorithm: cacheitem.CompressionZstd},
 				},
 				runcacheOpts: runcache.Opts{},
 				scopeOpts:    scope.Opts{},
@@ -250,12 +298,14 @@ func TestParseConfig(t *testing.T) {
 			[]string{"foo", "--continue", "--cache-dir=bar"},
 			&Opts{
 				runOpts: runOpts{
-					continueOnError: true,
-					concurrency:     10,
+					frameworkInference: true,
+					continueOnError:    true,
+					concurrency:        10,
 				},
 				cacheOpts: cache.Opts{
 					OverrideDir: "bar",
 					Workers:     10,
+					Compression: cacheitem.Compression{Algorithm: cacheitem.CompressionZstd},
 				},
 				runcacheOpts: runcache.Opts{},
 				scopeOpts:    scope.Opts{},
@@ -267,12 +317,14 @@ func TestParseConfig(t *testing.T) {
 			[]string{"foo", "--continue", "--cache-dir=" + defaultCwd.UntypedJoin("bar").ToString()},
 			&Opts{
 				runOpts: runOpts{
-					continueOnError: true,
-					concurrency:     10,
+					frameworkInference: true,
+					continueOnError:    true,
+					concurrency:        10,
 				},
 				cacheOpts: cache.Opts{
 					OverrideDir: defaultCwd.UntypedJoin("bar").ToString(),
 					Workers:     10,
+					Compression: cacheitem.Compression{Algorithm: cacheitem.CompressionZstd},
 				},
 				runcacheOpts: runcache.Opts{},
 				scopeOpts:    scope.Opts{},
@@ -324,7 +376,7 @@ func Test_dontSquashTasks(t *testing.T) {
 		Targets:      []string{"build"},
 		Opts:         &Opts{},
 	}
-	engine, err := buildTaskGraphEngine(topoGraph, pipeline, rs)
+	engine, err := buildTaskGraphEngine(topoGraph, pipeline, nil, rs)
 	if err != nil {
 		t.Fatalf("failed to build task graph: %v", err)
 	}
@@ -334,7 +386,7 @@ func Test_dontSquashTasks(t *testing.T) {
 		t.Errorf("expected 4 tasks, got %v", len(toRun))
 	}
 	for task := range pipeline {
-		if _, ok := engine.Tasks[task]; !ok {
+		if _, ok := engine.TaskByID(task); !ok {
 			t.Errorf("expected to find task %v in the task graph, but it is missing", task)
 		}
 	}
@@ -357,8 +409,91 @@ func Test_taskSelfRef(t *testing.T) {
 		Targets:      []string{"build"},
 		Opts:         &Opts{},
 	}
-	_, err := buildTaskGraphEngine(topoGraph, pipeline, rs)
+	_, err := buildTaskGraphEngine(topoGraph, pipeline, nil, rs)
 	if err == nil {
 		t.Fatalf("expected to failed to build task graph: %v", err)
 	}
 }
+
+func Test_missingTasks(t *testing.T) {
+	topoGraph := &dag.AcyclicGraph{}
+	topoGraph.Add("a")
+	topoGraph.Add("b")
+
+	// Only b implements deploy, and it isn't in scope
+	pipeline := map[string]fs.TaskDefinition{
+		"build":    {},
+		"b#deploy": {},
+	}
+	filteredPkgs := make(util.Set)
+	filteredPkgs.Add("a")
+	rs := &runSpec{
+		FilteredPkgs: filteredPkgs,
+		Targets:      []string{"build", "deploy"},
+		Opts:         &Opts{},
+	}
+	_, err := buildTaskGraphEngine(topoGraph, pipeline, nil, rs)
+	assert.EqualError(t, err, "no workspace in scope implements deploy")
+
+	rs.Opts.runOpts.allowMissingTasks = true
+	engine, err := buildTaskGraphEngine(topoGraph, pipeline, nil, rs)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"no workspace in scope implements deploy, so it is skipped"}, engine.Warnings())
+	assert.True(t, engine.TaskGraph.HasVertex("a#build"))
+}
+
+func Test_validateScriptShells(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("looks up sh")
+	}
+	topoGraph := &dag.AcyclicGraph{}
+	topoGraph.Add("a")
+	topoGraph.Add("b")
+
+	pipeline := map[string]fs.TaskDefinition{
+		"build":   {},
+		"b#build": {Shell: "sh"},
+	}
+	filteredPkgs := make(util.Set)
+	filteredPkgs.Add("a")
+	filteredPkgs.Add("b")
+	rs := &runSpec{
+		FilteredPkgs: filteredPkgs,
+		Targets:      []string{"build"},
+		Opts:         &Opts{},
+	}
+	engine, err := buildTaskGraphEngine(topoGraph, pipeline, nil, rs)
+	assert.NoError(t, err)
+
+	assert.NoError(t, validateScriptShells(engine, &packagemanager.PackageManager{Name: "nodejs-pnpm"}))
+	err = validateScriptShells(engine, &packagemanager.PackageManager{Name: "nodejs-berry"})
+	assert.EqualError(t, err, `cannot run b#build with shell "sh": yarn 2+ doesn't support setting the shell that runs scripts`)
+}
+
+func TestTaskEnvironStrictEnv(t *testing.T) {
+	t.Setenv("DECLARED_VAR", "declared")
+	t.Setenv("PASS_THROUGH_VAR", "passed")
+	t.Setenv("GLOBAL_VAR", "global")
+	t.Setenv("UNDECLARED_VAR", "undeclared")
+	taskDefinition := &fs.TaskDefinition{
+		EnvVarDependencies: []string{"DECLARED_VAR"},
+		PassThroughEnv:     []string{"PASS_THROUGH_VAR"},
+	}
+	ec := &execContext{
+		rs:        &runSpec{Opts: &Opts{}},
+		globalEnv: []string{"GLOBAL_VAR"},
+	}
+
+	environ, err := ec.taskEnviron(taskDefinition)
+	assert.NoError(t, err)
+	assert.Contains(t, environ, "UNDECLARED_VAR=undeclared")
+
+	ec.rs.Opts.runOpts.strictEnv = true
+	environ, err = ec.taskEnviron(taskDefinition)
+	assert.NoError(t, err)
+	assert.NotContains(t, environ, "UNDECLARED_VAR=undeclared")
+	assert.Contains(t, environ, "DECLARED_VAR=declared")
+	assert.Contains(t, environ, "PASS_THROUGH_VAR=passed")
+	assert.Contains(t, environ, "GLOBAL_VAR=global")
+	assert.Contains(t, environ, fmt.Sprintf("PATH=%v", os.Getenv("PATH")))
+}
@@ -0,0 +1,183 @@
+package run
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// runSummarySchemaVersion is bumped whenever the shape of RunSummary changes in a
+// way that readers of it need to know about
+const runSummarySchemaVersion = "1"
+
+// RunSummary is a machine-readable summary of a run, written to the file given
+// by --summarize
+type RunSummary struct {
+	SchemaVersion string         `json:"schemaVersion"`
+	GlobalHash    string         `json:"globalHash"`
+	Command       SummaryCommand `json:"command"`
+	StartTime     time.Time      `json:"startTime"`
+	EndTime       time.Time      `json:"endTime"`
+	DurationMs    int64          `json:"durationMs"`
+	Tasks         []*TaskSummary `json:"tasks"`
+	// Failures are the ids of the tasks that failed, sorted
+	Failures []string `json:"failures"`
+}
+
+// SummaryCommand is the command line of a run, after turbo parsed it
+type SummaryCommand struct {
+	Tasks           []string          `json:"tasks"`
+	Flags           map[string]string `json:"flags"`
+	PassThroughArgs []string          `json:"passThroughArgs"`
+}
+
+// TaskSummary is the outcome of a single task in a RunSummary
+type TaskSummary struct {
+	TaskID  string `json:"taskId"`
+	Package string `json:"package"`
+	Task    string `json:"task"`
+	Hash    string `json:"hash"`
+	// Status is how the task finished, such as "built", "cached", "fresh",
+	// "failed" or "timedOut"
+	Status string `json:"status"`
+	// Cache is where the task's outputs were restored from: "HitLocal", "HitRemote"
+	// or "Miss". It is empty if the cache wasn't checked, such as for fresh or
+	// resumed tasks.
+	Cache string `json:"cache,omitempty"`
+	// CacheBytesUploaded and CacheBytesDownloaded are the size of the task's
+	// outputs transferred to and from the remote cache
+	CacheBytesUploaded   int64     `json:"cacheBytesUploaded,omitempty"`
+	CacheBytesDownloaded int64     `json:"cacheBytesDownloaded,omitempty"`
+	StartTime            time.Time `json:"startTime"`
+	EndTime              time.Time `json:"endTime"`
+	DurationMs           int64     `json:"durationMs"`
+	// ExitCode is only set if a process was run for the task
+	ExitCode *int `json:"exitCode,omitempty"`
+	Attempts int  `json:"attempts"`
+	// Warnings are problems with the task that didn't fail it, such as declared
+	// outputs that matched no files
+	Warnings []string `json:"warnings,omitempty"`
+	// ChecksumMismatches are the outputs that didn't match the task's checksum manifest
+	ChecksumMismatches []string `json:"checksumMismatches,omitempty"`
+	// ExcludedOutputs are the globs of the task's outputs that are neither cached nor restored
+	ExcludedOutputs []string `json:"excludedOutputs,omitempty"`
+	// CachedOutputs are the files and folders the task saved to the cache, after
+	// exclusions, relative to the repo root
+	CachedOutputs []string `json:"cachedOutputs,omitempty"`
+}
+
+// Summary returns a summary of every task that finished during the run, sorted
+// by task id
+func (r *RunState) Summary(globalHash string, command SummaryCommand, endTime time.Time) *RunSummary {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	taskIDs := make([]string, 0, len(r.state))
+	for taskID, state := range r.state {
+		if state.Status == TargetBuilding || state.Status == TargetBuildStopped {
+			continue
+		}
+		taskIDs = append(taskIDs, taskID)
+	}
+	sort.Strings(taskIDs)
+
+	tasks := make([]*TaskSummary, 0, len(taskIDs))
+	failures := []string{}
+	for _, taskID := range taskIDs {
+		state := r.state[taskID]
+		if state.Status == TargetBuildFailed || state.Status == TargetTimedOut {
+			failures = append(failures, taskID)
+		}
+		pkg, task := util.GetPackageTaskFromId(taskID)
+		attempts := state.Attempts
+		if attempts == 0 {
+			attempts = 1
+		}
+		summary := &TaskSummary{
+			TaskID:               taskID,
+			Package:              pkg,
+			Task:                 task,
+			Hash:                 state.hash,
+			Status:               state.Status.String(),
+			CacheBytesUploaded:   state.CacheBytesUploaded,
+			CacheBytesDownloaded: state.CacheBytesDownloaded,
+			StartTime:            state.StartAt,
+			EndTime:              state.StartAt.Add(state.Duration),
+			DurationMs:           state.Duration.Milliseconds(),
+			Attempts:             attempts,
+			Warnings:             state.Warnings,
+			ExcludedOutputs:      state.ExcludedOutputs,
+			CachedOutputs:        state.CachedOutputs,
+		}
+		if state.cacheChecked {
+			summary.Cache = state.Cache.String()
+		}
+		for _, mismatch := range state.ChecksumMismatches {
+			summary.ChecksumMismatches = append(summary.ChecksumMismatches, mismatch.String())
+		}
+		if state.ranProcess {
+			exitCode := state.ExitCode
+			summary.ExitCode = &exitCode
+		}
+		tasks = append(tasks, summary)
+	}
+
+	return &RunSummary{
+		SchemaVersion: runSummarySchemaVersion,
+		GlobalHash:    globalHash,
+		Command:       command,
+		StartTime:     r.startedAt,
+		EndTime:       endTime,
+		DurationMs:    endTime.Sub(r.startedAt).Milliseconds(),
+		Tasks:         tasks,
+		Failures:      failures,
+	}
+}
+
+// secretFlags are the flags whose values are credentials, which are left out of
+// the run summary since it is meant to be uploaded
+var secretFlags = util.SetFromStrings([]string{"token", "remote-header"})
+
+// changedFlags returns the value of each flag set on the command line, by its
+// normalized name, except for secretFlags
+func changedFlags(flags *pflag.FlagSet) map[string]string {
+	values := make(map[string]string)
+	flags.Visit(func(f *pflag.Flag) {
+		if secretFlags.Includes(f.Name) {
+			return
+		}
+		values[f.Name] = f.Value.String()
+	})
+	return values
+}
+
+// readSummaryDurations returns how long each task took in the run summary
+// written to the given file by a previous run
+func readSummaryDurations(filename string) (map[string]time.Duration, error) {
+	contents, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	summary := &RunSummary{}
+	if err := json.Unmarshal(contents, summary); err != nil {
+		return nil, err
+	}
+	durations := make(map[string]time.Duration, len(summary.Tasks))
+	for _, task := range summary.Tasks {
+		durations[task.TaskID] = time.Duration(task.DurationMs) * time.Millisecond
+	}
+	return durations, nil
+}
+
+// writeSummaryFile writes the run summary to the given file as JSON
+func writeSummaryFile(summary *RunSummary, filename string) error {
+	bytes, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, append(bytes, '\n'), 0644)
+}
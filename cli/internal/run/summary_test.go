@@ -0,0 +1,180 @@
+package run
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/vercel/turbo/cli/internal/cache"
+	"github.com/vercel/turbo/cli/internal/dispatch"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+func TestRunSummary(t *testing.T) {
+	startedAt := time.Now()
+	r := NewRunState(startedAt)
+
+	// docs#build is restored from the remote cache, then web#build runs and fails
+	// twice, api#test times out, docs#lint is fresh and ui#build's outputs don't
+	// match its checksum manifest
+	done := r.Run("docs#build")
+	r.recordAttempts("docs#build", 1)
+	r.recordHash("docs#build", "docs-hash")
+	r.recordCacheSource("docs#build", cache.ItemStatus{Remote: true})
+	done(TargetCached, nil)
+	r.state["docs#build"].CacheBytesDownloaded = 2048
+
+	done = r.Run("web#build")
+	r.recordAttempts("web#build", 2)
+	r.recordHash("web#build", "web-hash")
+	r.recordCacheSource("web#build", cache.ItemStatus{})
+	r.recordProcess("web#build", dispatch.Result{Ran: true, ExitCode: 1})
+	done(TargetBuildFailed, errors.New("exit status 1"))
+
+	done = r.Run("api#test")
+	r.recordHash("api#test", "api-hash")
+	r.recordCacheSource("api#test", cache.ItemStatus{})
+	r.recordProcess("api#test", dispatch.Result{Ran: true, ExitCode: -1})
+	done(TargetTimedOut, errors.New("api#test timed out after 1m0s"))
+
+	done = r.Run("docs#lint")
+	done(TargetFresh, nil)
+
+	done = r.Run("ui#build")
+	r.recordHash("ui#build", "ui-hash")
+	r.recordCacheSource("ui#build", cache.ItemStatus{})
+	r.recordExcludedOutputs("ui#build", []string{"dist/**/*.map"})
+	r.recordCachedOutputs("ui#build", []turbopath.AnchoredSystemPath{
+		turbopath.AnchoredUnixPath("packages/ui/dist/index.js").ToSystemPath(),
+		turbopath.AnchoredUnixPath("packages/ui/.turbo/turbo-build.log").ToSystemPath(),
+	})
+	r.recordChecksumMismatches("ui#build", []ChecksumMismatch{{File: "dist/index.js", Expected: "abc", Actual: "def"}})
+	done(TargetBuilt, nil)
+
+	filename := filepath.Join(t.TempDir(), "summary.json")
+	summary := r.Summary("global-hash", SummaryCommand{
+		Tasks:           []string{"build"},
+		Flags:           map[string]string{"continue": "true"},
+		PassThroughArgs: []string{"--verbose"},
+	}, startedAt.Add(time.Second))
+	assert.NoError(t, writeSummaryFile(summary, filename))
+
+	contents, err := os.ReadFile(filename)
+	assert.NoError(t, err)
+	var parsed map[string]interface{}
+	assert.NoError(t, json.Unmarshal(contents, &parsed))
+
+	assert.Equal(t, "1", parsed["schemaVersion"])
+	assert.Equal(t, "global-hash", parsed["globalHash"])
+	assert.Equal(t, float64(1000), parsed["durationMs"])
+	assert.Equal(t, []interface{}{"api#test", "web#build"}, parsed["failures"])
+	assert.Equal(t, map[string]interface{}{
+		"tasks":           []interface{}{"build"},
+		"flags":           map[string]interface{}{"continue": "true"},
+		"passThroughArgs": []interface{}{"--verbose"},
+	}, parsed["command"])
+
+	tasks := parsed["tasks"].([]interface{})
+	assert.Len(t, tasks, 5)
+	api := tasks[0].(map[string]interface{})
+	assert.Equal(t, "api#test", api["taskId"])
+	assert.Equal(t, "timedOut", api["status"])
+	assert.Equal(t, "Miss", api["cache"])
+
+	docs := tasks[1].(map[string]interface{})
+	assert.Equal(t, "docs#build", docs["taskId"])
+	assert.Equal(t, "docs", docs["package"])
+	assert.Equal(t, "build", docs["task"])
+	assert.Equal(t, "docs-hash", docs["hash"])
+	assert.Equal(t, "cached", docs["status"])
+	assert.Equal(t, "HitRemote", docs["cache"])
+	assert.Equal(t, float64(2048), docs["cacheBytesDownloaded"])
+	assert.NotContains(t, docs, "cacheBytesUploaded")
+	assert.Equal(t, float64(1), docs["attempts"])
+	assert.NotContains(t, docs, "exitCode", "cached tasks did not run a process")
+
+	lint := tasks[2].(map[string]interface{})
+	assert.Equal(t, "fresh", lint["status"])
+	assert.NotContains(t, lint, "cache", "fresh tasks are never looked up in the cache")
+
+	ui := tasks[3].(map[string]interface{})
+	assert.Equal(t, "built", ui["status"])
+	assert.Equal(t, []interface{}{"dist/index.js: expected abc, got def"}, ui["checksumMismatches"])
+	assert.Equal(t, []interface{}{"dist/**/*.map"}, ui["excludedOutputs"])
+	assert.Equal(t, []interface{}{"packages/ui/.turbo/turbo-build.log", "packages/ui/dist/index.js"}, ui["cachedOutputs"])
+
+	web := tasks[4].(map[string]interface{})
+	assert.Equal(t, "web#build", web["taskId"])
+	assert.Equal(t, "web-hash", web["hash"])
+	assert.Equal(t, "failed", web["status"])
+	assert.Equal(t, "Miss", web["cache"])
+	assert.Equal(t, float64(1), web["exitCode"])
+	assert.Equal(t, float64(2), web["attempts"])
+	assert.NotContains(t, web, "cachedOutputs", "failed tasks are not cached")
+	for _, key := range []string{"startTime", "endTime", "durationMs"} {
+		assert.Contains(t, web, key)
+	}
+	startTime, err := time.Parse(time.RFC3339Nano, web["startTime"].(string))
+	assert.NoError(t, err)
+	endTime, err := time.Parse(time.RFC3339Nano, web["endTime"].(string))
+	assert.NoError(t, err)
+	assert.False(t, endTime.Before(startTime))
+}
+
+func TestRunSummaryWarnings(t *testing.T) {
+	startedAt := time.Now()
+	r := NewRunState(startedAt)
+
+	done := r.Run("web#build")
+	r.recordWarning("web#build", "no output files found for task web#build. Please check your `outputs` key in `turbo.json`")
+	done(TargetBuilt, nil)
+	done = r.Run("docs#build")
+	done(TargetBuilt, nil)
+
+	summary := r.Summary("global-hash", SummaryCommand{}, startedAt.Add(time.Second))
+	assert.Len(t, summary.Tasks, 2)
+	assert.Empty(t, summary.Tasks[0].Warnings)
+	assert.Equal(t, []string{"no output files found for task web#build. Please check your `outputs` key in `turbo.json`"}, summary.Tasks[1].Warnings)
+}
+
+func TestReadSummaryDurations(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "summary.json")
+	summary := &RunSummary{
+		SchemaVersion: runSummarySchemaVersion,
+		Tasks: []*TaskSummary{
+			{TaskID: "docs#build", DurationMs: 1500},
+			{TaskID: "web#build", DurationMs: 20},
+		},
+	}
+	assert.NoError(t, writeSummaryFile(summary, filename))
+
+	durations, err := readSummaryDurations(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]time.Duration{
+		"docs#build": 1500 * time.Millisecond,
+		"web#build":  20 * time.Millisecond,
+	}, durations)
+
+	_, err = readSummaryDurations(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+}
+
+func TestChangedFlagsLeavesOutSecrets(t *testing.T) {
+	flags := pflag.NewFlagSet("run", pflag.ContinueOnError)
+	flags.Int("concurrency", 10, "")
+	flags.Bool("continue", false, "")
+	flags.String("token", "", "")
+	flags.StringToString("remote-header", nil, "")
+	assert.NoError(t, flags.Parse([]string{"--concurrency=2", "--token=secret-token", "--remote-header", "X-Proxy-Auth=secret-header"}))
+
+	values := changedFlags(flags)
+	assert.Equal(t, map[string]string{"concurrency": "2"}, values)
+	bytes, err := json.Marshal(values)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(bytes), "secret")
+}
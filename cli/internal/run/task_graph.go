@@ -0,0 +1,73 @@
+package run
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/vercel/turbo/cli/internal/context"
+	"github.com/vercel/turbo/cli/internal/core"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// TaskGraph builds the task graph that `turbo run` would run for the given task
+// names in every workspace of the repo, without running anything. It defaults
+// to every task in the pipeline.
+func TaskGraph(repoRoot turbopath.AbsoluteSystemPath, tasks []string) (*core.SerializedTaskGraph, error) {
+	rootPackageJSON, err := fs.ReadPackageJSON(repoRoot.UntypedJoin("package.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read package.json: %w", err)
+	}
+	turboJSON, err := fs.LoadTurboConfig(repoRoot, rootPackageJSON, false)
+	if err != nil {
+		return nil, err
+	}
+	pkgDepGraph, err := context.BuildPackageGraph(repoRoot, rootPackageJSON)
+	if err != nil {
+		// Like a run, the graph can still be built in spite of warnings
+		var warnings *context.Warnings
+		if !errors.As(err, &warnings) {
+			return nil, err
+		}
+	}
+	if err := util.ValidateGraph(&pkgDepGraph.TopologicalGraph); err != nil {
+		return nil, errors.Wrap(err, "Invalid package dependency graph")
+	}
+
+	pipeline, err := turboJSON.Pipeline.MergeWorkspaceConfigs(repoRoot, pkgDepGraph.PackageInfos)
+	if err != nil {
+		return nil, err
+	}
+	if len(tasks) == 0 {
+		taskNames := make(util.Set)
+		for key := range pipeline {
+			taskNames.Add(util.StripPackageName(key))
+		}
+		tasks = taskNames.UnsafeListOfStrings()
+	}
+	if err := validateTasks(pipeline, tasks); err != nil {
+		return nil, err
+	}
+
+	pkgs := make(util.Set)
+	for _, pkg := range pkgDepGraph.PackageNames {
+		pkgs.Add(pkg)
+	}
+	for _, task := range tasks {
+		if _, ok := pipeline[util.RootTaskID(task)]; ok {
+			pkgs.Add(util.RootPkgName)
+			break
+		}
+	}
+	rs := &runSpec{
+		Targets:      tasks,
+		FilteredPkgs: pkgs,
+		Opts:         getDefaultOptions(),
+	}
+	engine, err := buildTaskGraphEngine(&pkgDepGraph.TopologicalGraph, pipeline, pkgDepGraph.PackageInfos, rs)
+	if err != nil {
+		return nil, errors.Wrap(err, "error preparing engine")
+	}
+	return engine.Serialize(), nil
+}
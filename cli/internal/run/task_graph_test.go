@@ -0,0 +1,119 @@
+package run
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/vercel/turbo/cli/internal/server"
+	"github.com/vercel/turbo/cli/internal/turbodprotocol"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func Test_GetTaskGraph(t *testing.T) {
+	repoRoot := turbopath.AbsoluteSystemPathFromUpstream(t.TempDir())
+	writeFile := func(file string, contents string) {
+		t.Helper()
+		path := repoRoot.UntypedJoin(filepath.FromSlash(file))
+		if err := path.EnsureDir(); err != nil {
+			t.Fatalf("EnsureDir: %v", err)
+		}
+		if err := path.WriteFile([]byte(contents), 0644); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+	}
+	writeFile("package.json", `{"name": "root", "packageManager": "npm@8.19.2", "workspaces": ["apps/*", "packages/*"]}`)
+	writeFile("package-lock.json", "{}")
+	writeFile("turbo.json", `{
+		"pipeline": {
+			"build": {"dependsOn": ["^build"]},
+			"test": {"dependsOn": ["build"]},
+			"dev": {"dependsOn": ["^build"], "cache": false, "persistent": true}
+		}
+	}`)
+	writeFile("apps/web/package.json", `{"name": "web", "dependencies": {"ui": "*"}}`)
+	writeFile("packages/ui/package.json", `{"name": "ui"}`)
+
+	turboServer, err := server.New("testServer", hclog.NewNullLogger(), repoRoot, "some-version", "/log/file/path", TaskGraph)
+	if err != nil {
+		t.Fatalf("server.New: %v", err)
+	}
+	defer func() { _ = turboServer.Close() }()
+	listener := bufconn.Listen(1024 * 1024)
+	grpcServer := grpc.NewServer()
+	turboServer.Register(grpcServer)
+	go func() { _ = grpcServer.Serve(listener) }()
+	defer grpcServer.Stop()
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("grpc.Dial: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+	client := turbodprotocol.NewTurbodClient(conn)
+
+	resp, err := client.GetTaskGraph(context.Background(), &turbodprotocol.GetTaskGraphRequest{
+		RepoRoot: repoRoot.ToString(),
+	})
+	if err != nil {
+		t.Fatalf("GetTaskGraph: %v", err)
+	}
+	type task struct {
+		taskID     string
+		persistent bool
+	}
+	tasks := []task{}
+	for _, tt := range resp.Tasks {
+		tasks = append(tasks, task{tt.TaskId, tt.Persistent})
+	}
+	assert.Equal(t, []task{
+		{"ui#build", false},
+		{"ui#dev", true},
+		{"ui#test", false},
+		{"web#build", false},
+		{"web#dev", true},
+		{"web#test", false},
+	}, tasks)
+	dependencies := [][2]string{}
+	for _, dep := range resp.Dependencies {
+		dependencies = append(dependencies, [2]string{dep.From, dep.To})
+	}
+	assert.Equal(t, [][2]string{
+		{"ui#test", "ui#build"},
+		{"web#build", "ui#build"},
+		{"web#dev", "ui#build"},
+		{"web#test", "web#build"},
+	}, dependencies)
+
+	// Only the requested tasks and their dependencies are included
+	resp, err = client.GetTaskGraph(context.Background(), &turbodprotocol.GetTaskGraphRequest{
+		Tasks: []string{"test"},
+	})
+	if err != nil {
+		t.Fatalf("GetTaskGraph: %v", err)
+	}
+	taskIDs := []string{}
+	for _, tt := range resp.Tasks {
+		taskIDs = append(taskIDs, tt.TaskId)
+	}
+	assert.Equal(t, []string{"ui#build", "ui#test", "web#build", "web#test"}, taskIDs)
+
+	_, err = client.GetTaskGraph(context.Background(), &turbodprotocol.GetTaskGraphRequest{
+		Tasks: []string{"lint"},
+	})
+	assert.ErrorContains(t, err, "task `lint` not found")
+
+	_, err = client.GetTaskGraph(context.Background(), &turbodprotocol.GetTaskGraphRequest{
+		RepoRoot: filepath.Join(repoRoot.ToString(), "other"),
+	})
+	assert.ErrorContains(t, err, "repo root mismatch")
+}
@@ -0,0 +1,139 @@
+package run
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/pyr-sh/dag"
+	"github.com/vercel/turbo/cli/internal/core"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/nodes"
+	"github.com/vercel/turbo/cli/internal/scm"
+	"github.com/vercel/turbo/cli/internal/taskhash"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+func Test_scriptChangesTaskHash(t *testing.T) {
+	repoRoot := turbopath.AbsoluteSystemPathFromUpstream(t.TempDir())
+	webDir := repoRoot.UntypedJoin("apps", "web")
+	if err := webDir.MkdirAll(0755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	if err := webDir.UntypedJoin("index.ts").WriteFile([]byte("export {}"), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	topoGraph := dag.AcyclicGraph{}
+	topoGraph.Add("web")
+	topoGraph.Add(util.RootPkgName)
+	g := &completeGraph{
+		TopologicalGraph: topoGraph,
+		Pipeline:         fs.Pipeline{"build": fs.TaskDefinition{ShouldCache: true}},
+		PackageInfos: map[interface{}]*fs.PackageJSON{
+			"web": {
+				Name:    "web",
+				Dir:     turbopath.AnchoredUnixPath("apps/web").ToSystemPath(),
+				Scripts: map[string]string{"build": "tsc"},
+			},
+		},
+		GlobalHash: "global-hash",
+		RootNode:   util.RootPkgName,
+	}
+	engine := core.NewEngine(&g.TopologicalGraph)
+
+	taskHash := func() string {
+		t.Helper()
+		tracker := taskhash.NewTracker(g.RootNode, g.GlobalHash, g.Pipeline, g.PackageInfos, nil, scm.Metadata{})
+		if err := tracker.CalculateFileHashes([]dag.Vertex{"web#build"}, 1, repoRoot); err != nil {
+			t.Fatalf("CalculateFileHashes: %v", err)
+		}
+		var hash string
+		visitor := g.getPackageTaskVisitor(context.Background(), engine, func(ctx context.Context, packageTask *nodes.PackageTask) error {
+			var err error
+			hash, err = tracker.CalculateTaskHash(packageTask, dag.Set{}, hclog.NewNullLogger(), nil)
+			return err
+		})
+		if err := visitor("web#build"); err != nil {
+			t.Fatalf("CalculateTaskHash: %v", err)
+		}
+		return hash
+	}
+
+	initial := taskHash()
+	if unchanged := taskHash(); unchanged != initial {
+		t.Errorf("hash of web#build changed from %v to %v without any changes", initial, unchanged)
+	}
+	g.PackageInfos["web"].Scripts["build"] = "tsc --strict"
+	if changed := taskHash(); changed == initial {
+		t.Errorf("hash of web#build didn't change when its script changed")
+	}
+}
+
+func Test_commandTransformerKeepsTaskHash(t *testing.T) {
+	repoRoot := turbopath.AbsoluteSystemPathFromUpstream(t.TempDir())
+	topoGraph := dag.AcyclicGraph{}
+	topoGraph.Add("web")
+	g := &completeGraph{
+		TopologicalGraph: topoGraph,
+		Pipeline:         fs.Pipeline{"build": fs.TaskDefinition{ShouldCache: true}},
+		PackageInfos: map[interface{}]*fs.PackageJSON{
+			"web": {
+				Name:    "web",
+				Dir:     turbopath.AnchoredUnixPath("apps/web").ToSystemPath(),
+				Scripts: map[string]string{"build": "tsc"},
+			},
+		},
+		GlobalHash: "global-hash",
+		RootNode:   util.RootPkgName,
+	}
+	engine := core.NewEngine(&g.TopologicalGraph)
+	engine.AddTask(&core.Task{Name: "build", TopoDeps: make(util.Set), Deps: make(util.Set)})
+	if err := engine.Prepare(&core.EngineBuildingOptions{
+		Packages:  []string{"web"},
+		TaskNames: []string{"build"},
+	}); err != nil {
+		t.Fatalf("Prepare: %v", err)
+	}
+
+	// run returns the hash and the command of web#build
+	run := func(transformer core.CommandTransformer) (string, string) {
+		t.Helper()
+		tracker := taskhash.NewTracker(g.RootNode, g.GlobalHash, g.Pipeline, g.PackageInfos, nil, scm.Metadata{})
+		if err := tracker.CalculateFileHashes([]dag.Vertex{"web#build"}, 1, repoRoot); err != nil {
+			t.Fatalf("CalculateFileHashes: %v", err)
+		}
+		var hash string
+		var cmd []string
+		visitor := g.getPackageTaskVisitor(context.Background(), engine, func(ctx context.Context, packageTask *nodes.PackageTask) error {
+			var err error
+			hash, err = tracker.CalculateTaskHash(packageTask, dag.Set{}, hclog.NewNullLogger(), nil)
+			if err != nil {
+				return err
+			}
+			command, args, err := engine.TransformCommand(packageTask.TaskID, "npm", []string{"run", packageTask.Task})
+			cmd = append([]string{command}, args...)
+			return err
+		})
+		if errs := engine.Execute(visitor, core.EngineExecutionOptions{Concurrency: 1, CommandTransformer: transformer}); len(errs) > 0 {
+			t.Fatalf("Execute: %v", errs)
+		}
+		return hash, strings.Join(cmd, " ")
+	}
+
+	hash, cmd := run(nil)
+	if cmd != "npm run build" {
+		t.Errorf("command of web#build is %q, want %q", cmd, "npm run build")
+	}
+	transformedHash, transformedCmd := run(func(task *core.Task, cmd string) (string, error) {
+		return "nice -n 10 " + cmd, nil
+	})
+	if transformedCmd != "nice -n 10 npm run build" {
+		t.Errorf("transformed command of web#build is %q, want %q", transformedCmd, "nice -n 10 npm run build")
+	}
+	if transformedHash != hash {
+		t.Errorf("hash of web#build changed from %v to %v when its command was transformed", hash, transformedHash)
+	}
+}
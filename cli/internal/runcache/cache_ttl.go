@@ -0,0 +1,35 @@
+package runcache
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+// alias so we can mock in tests
+var now = time.Now
+
+// writeCachedAt records when a task's outputs were saved, in a file that is
+// cached alongside them, so that the entry's age is known wherever it is restored.
+func writeCachedAt(path turbopath.AbsoluteSystemPath) error {
+	if err := path.EnsureDir(); err != nil {
+		return err
+	}
+	return path.WriteFile([]byte(now().UTC().Format(time.RFC3339)), 0644)
+}
+
+// cacheEntryAge returns how long ago the restored outputs with the given cached-at
+// file were saved
+func cacheEntryAge(path turbopath.AbsoluteSystemPath) (time.Duration, error) {
+	contents, err := path.ReadFile()
+	if err != nil {
+		return 0, err
+	}
+	cachedAt, err := time.Parse(time.RFC3339, strings.TrimSpace(string(contents)))
+	if err != nil {
+		return 0, fmt.Errorf("invalid cache timestamp: %w", err)
+	}
+	return now().Sub(cachedAt), nil
+}
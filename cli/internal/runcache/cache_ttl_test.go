@@ -0,0 +1,28 @@
+package runcache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"gotest.tools/v3/assert"
+)
+
+func TestCacheEntryAge(t *testing.T) {
+	oldNow := now
+	defer func() { now = oldNow }()
+
+	cachedAtFile := fs.AbsoluteSystemPathFromUpstream(t.TempDir()).UntypedJoin(".turbo", "turbo-build.cached-at")
+	savedAt := time.Date(2022, time.November, 1, 12, 0, 0, 0, time.UTC)
+	now = func() time.Time { return savedAt }
+	assert.NilError(t, writeCachedAt(cachedAtFile), "writeCachedAt")
+
+	now = func() time.Time { return savedAt.Add(26 * time.Hour) }
+	age, err := cacheEntryAge(cachedAtFile)
+	assert.NilError(t, err, "cacheEntryAge")
+	assert.Equal(t, age, 26*time.Hour)
+
+	assert.NilError(t, cachedAtFile.WriteFile([]byte("yesterday"), 0644), "WriteFile")
+	_, err = cacheEntryAge(cachedAtFile)
+	assert.ErrorContains(t, err, "invalid cache timestamp")
+}
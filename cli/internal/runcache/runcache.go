@@ -6,8 +6,8 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/hashicorp/go-hclog"
@@ -34,12 +34,22 @@ type Opts struct {
 	TaskOutputModeOverride *util.TaskOutputMode
 	LogReplayer            LogReplayer
 	OutputWatcher          OutputWatcher
+	// LogToFile writes the output of every task to .turbo/logs/<package>/<task>.log,
+	// whether it runs or is restored from the cache
+	LogToFile bool
+	// StrictOutputs fails tasks whose declared outputs match no files, rather
+	// than only warning about them
+	StrictOutputs bool
 }
 
 // AddFlags adds the flags relevant to the runcache package to the given FlagSet
 func AddFlags(opts *Opts, flags *pflag.FlagSet) {
 	flags.BoolVar(&opts.SkipReads, "force", false, "Ignore the existing cache (to force execution).")
-	flags.BoolVar(&opts.SkipWrites, "no-cache", false, "Avoid saving task results to the cache. Useful for development/watch tasks.")
+	flags.BoolVar(&opts.SkipWrites, "no-cache", false, "Neither restore task results from, nor save them to, any cache. Useful for development/watch tasks.")
+	flags.BoolVar(&opts.LogToFile, "log-to-file", false, `Also write the output of every task to .turbo/logs/<package>/<task>.log,
+including tasks restored from the cache, e.g. to upload as CI artifacts.`)
+	flags.BoolVar(&opts.StrictOutputs, "strict-outputs", false, `Fail tasks that declare outputs in turbo.json, but
+produce no files matching them, instead of warning.`)
 
 	defaultTaskOutputMode, err := util.ToTaskOutputModeString(util.FullTaskOutput)
 	if err != nil {
@@ -51,7 +61,8 @@ func AddFlags(opts *Opts, flags *pflag.FlagSet) {
 		Usage: `Set type of process output logging. Use "full" to show
 all output. Use "hash-only" to show only turbo-computed
 task hashes. Use "new-only" to show only new output with
-only hashes for cached tasks. Use "none" to hide process
+only hashes for cached tasks. Use "errors-only" to show
+output only for tasks that fail. Use "none" to hide process
 output.`,
 		DefValue: defaultTaskOutputMode,
 		Value:    &taskOutputModeValue{opts: opts},
@@ -114,6 +125,8 @@ type RunCache struct {
 	logReplayer            LogReplayer
 	outputWatcher          OutputWatcher
 	colorCache             *colorcache.ColorCache
+	logToFile              bool
+	strictOutputs          bool
 }
 
 // New returns a new instance of RunCache, wrapping the given cache
@@ -127,6 +140,8 @@ func New(cache cache.Cache, repoRoot turbopath.AbsoluteSystemPath, opts Opts, co
 		logReplayer:            opts.LogReplayer,
 		outputWatcher:          opts.OutputWatcher,
 		colorCache:             colorCache,
+		logToFile:              opts.LogToFile,
+		strictOutputs:          opts.StrictOutputs,
 	}
 
 	if rc.logReplayer == nil {
@@ -148,16 +163,22 @@ type TaskCache struct {
 	taskOutputMode    util.TaskOutputMode
 	cachingDisabled   bool
 	LogFileName       turbopath.AbsoluteSystemPath
+	// cachedAtFileName records when the outputs were saved, for tasks with a cache TTL
+	cachedAtFileName turbopath.AbsoluteSystemPath
+	// capturedLogFileName, if set, is where the task's output is also written with --log-to-file
+	capturedLogFileName turbopath.AbsoluteSystemPath
 }
 
 // RestoreOutputs attempts to restore output for the corresponding task from the cache.
-// Returns true if successful.
-func (tc TaskCache) RestoreOutputs(ctx context.Context, prefixedUI *cli.PrefixedUi, progressLogger hclog.Logger) (bool, error) {
+// Returns which cache the outputs were restored from, which is empty on a miss. Outputs
+// that are already in place because they haven't changed since the previous run count
+// as a local hit.
+func (tc TaskCache) RestoreOutputs(ctx context.Context, prefixedUI *cli.PrefixedUi, progressLogger hclog.Logger) (cache.ItemStatus, error) {
 	if tc.cachingDisabled || tc.rc.readsDisabled {
-		if tc.taskOutputMode != util.NoTaskOutput {
+		if tc.showsStatus() {
 			prefixedUI.Output(fmt.Sprintf("cache bypass, force executing %s", ui.Dim(tc.hash)))
 		}
-		return false, nil
+		return cache.ItemStatus{}, nil
 	}
 	changedOutputGlobs, err := tc.rc.outputWatcher.GetChangedOutputs(ctx, tc.hash, tc.repoRelativeGlobs.Inclusions)
 	if err != nil {
@@ -166,19 +187,20 @@ func (tc TaskCache) RestoreOutputs(ctx context.Context, prefixedUI *cli.Prefixed
 		changedOutputGlobs = tc.repoRelativeGlobs.Inclusions
 	}
 
+	status := cache.ItemStatus{Local: true}
 	hasChangedOutputs := len(changedOutputGlobs) > 0
 	if hasChangedOutputs {
 		// Note that we currently don't use the output globs when restoring, but we could in the
 		// future to avoid doing unnecessary file I/O. We also need to pass along the exclusion
 		// globs as well.
-		hit, _, _, err := tc.rc.cache.Fetch(tc.rc.repoRoot, tc.hash, nil)
+		status, _, _, err = tc.rc.cache.Fetch(tc.rc.repoRoot, tc.hash, nil)
 		if err != nil {
-			return false, err
-		} else if !hit {
-			if tc.taskOutputMode != util.NoTaskOutput {
+			return cache.ItemStatus{}, err
+		} else if !status.Hit() {
+			if tc.showsStatus() {
 				prefixedUI.Output(fmt.Sprintf("cache miss, executing %s", ui.Dim(tc.hash)))
 			}
-			return false, nil
+			return cache.ItemStatus{}, nil
 		}
 
 		if err := tc.rc.outputWatcher.NotifyOutputsWritten(ctx, tc.hash, tc.repoRelativeGlobs); err != nil {
@@ -189,6 +211,25 @@ func (tc TaskCache) RestoreOutputs(ctx context.Context, prefixedUI *cli.Prefixed
 		prefixedUI.Warn(fmt.Sprintf("Skipping cache check for %v, outputs have not changed since previous run.", tc.pt.TaskID))
 	}
 
+	if ttl := tc.pt.TaskDefinition.CacheTTL; ttl > 0 {
+		age, err := cacheEntryAge(tc.cachedAtFileName)
+		if err != nil {
+			progressLogger.Debug("could not determine age of cached outputs", "error", err)
+			if tc.showsStatus() {
+				prefixedUI.Output(fmt.Sprintf("cache expired, executing %s (age unknown, cacheTTL is %v)", ui.Dim(tc.hash), ttl))
+			}
+			return cache.ItemStatus{}, nil
+		}
+		if age > ttl {
+			if tc.showsStatus() {
+				prefixedUI.Output(fmt.Sprintf("cache expired, executing %s (saved %v ago, cacheTTL is %v)", ui.Dim(tc.hash), age.Truncate(time.Second), ttl))
+			}
+			return cache.ItemStatus{}, nil
+		}
+	}
+
+	tc.captureRestoredLog(progressLogger, prefixedUI)
+
 	switch tc.taskOutputMode {
 	// When only showing new task output, cached output should only show the computed hash
 	case util.NewTaskOutput:
@@ -206,7 +247,43 @@ func (tc TaskCache) RestoreOutputs(ctx context.Context, prefixedUI *cli.Prefixed
 		// NoLogs, do not output anything
 	}
 
-	return true, nil
+	return status, nil
+}
+
+// captureRestoredLog copies the log of a task restored from the cache to where
+// --log-to-file writes it. Failing to do so doesn't fail the task.
+func (tc TaskCache) captureRestoredLog(logger hclog.Logger, prefixedUI *cli.PrefixedUi) {
+	if tc.capturedLogFileName == "" || !tc.LogFileName.FileExists() {
+		return
+	}
+	contents, err := tc.LogFileName.ReadFile()
+	if err == nil {
+		if err = tc.capturedLogFileName.EnsureDir(); err == nil {
+			err = tc.capturedLogFileName.WriteFile(contents, 0644)
+		}
+	}
+	if err != nil {
+		logger.Warn("failed to write log file", "path", tc.capturedLogFileName, "error", err)
+		prefixedUI.Warn(ui.Dim(fmt.Sprintf("Failed to write log file %v: %v", tc.capturedLogFileName, err)))
+	}
+}
+
+// showsStatus returns whether the task's cache status, such as a cache miss, is shown.
+// Tasks that only show output on error stay silent unless they fail.
+func (tc TaskCache) showsStatus() bool {
+	return tc.taskOutputMode != util.NoTaskOutput && tc.taskOutputMode != util.ErrorTaskOutput
+}
+
+// OnError shows the output of a failed task whose output was held back because it
+// only shows output on error. Its writer must be closed first.
+func (tc TaskCache) OnError(terminal *cli.PrefixedUi, logger hclog.Logger) {
+	if tc.taskOutputMode != util.ErrorTaskOutput {
+		return
+	}
+	terminal.Output(fmt.Sprintf("cache miss, executing %s", ui.Dim(tc.hash)))
+	if tc.LogFileName.FileExists() {
+		tc.rc.logReplayer(logger, terminal, tc.LogFileName)
+	}
 }
 
 // nopWriteCloser is modeled after io.NopCloser, which is for Readers
@@ -216,6 +293,46 @@ type nopWriteCloser struct {
 
 func (nopWriteCloser) Close() error { return nil }
 
+// teeWriteCloser writes to and closes both of its writers
+type teeWriteCloser struct {
+	io.Writer
+	first  io.WriteCloser
+	second io.WriteCloser
+}
+
+func newTeeWriteCloser(first io.WriteCloser, second io.WriteCloser) *teeWriteCloser {
+	return &teeWriteCloser{
+		Writer: io.MultiWriter(first, second),
+		first:  first,
+		second: second,
+	}
+}
+
+func (t *teeWriteCloser) Close() error {
+	firstErr := t.first.Close()
+	if err := t.second.Close(); err != nil {
+		return err
+	}
+	return firstErr
+}
+
+// createLogFile creates a buffered log file, and the directories it is in
+func createLogFile(path turbopath.AbsoluteSystemPath) (*fileWriterCloser, error) {
+	if err := path.EnsureDir(); err != nil {
+		return nil, err
+	}
+	output, err := path.Create()
+	if err != nil {
+		return nil, err
+	}
+	bufWriter := bufio.NewWriter(output)
+	return &fileWriterCloser{
+		Writer: bufWriter,
+		file:   output,
+		bufio:  bufWriter,
+	}, nil
+}
+
 type fileWriterCloser struct {
 	io.Writer
 	file  *os.File
@@ -231,33 +348,36 @@ func (fwc *fileWriterCloser) Close() error {
 
 // OutputWriter creates a sink suitable for handling the output of the command associated
 // with this task.
-func (tc TaskCache) OutputWriter(prefix string) (io.WriteCloser, error) {
-	// an os.Stdout wrapper that will add prefixes before printing to stdout
-	stdoutWriter := logstreamer.NewPrettyStdoutWriter(prefix)
-
-	if tc.cachingDisabled || tc.rc.writesDisabled {
-		return nopWriteCloser{stdoutWriter}, nil
+func (tc TaskCache) OutputWriter(prefix string, terminal io.Writer) (io.WriteCloser, error) {
+	writer, err := tc.outputWriter(prefix, terminal)
+	if err != nil || tc.capturedLogFileName == "" {
+		return writer, err
 	}
-	// Setup log file
-	if err := tc.LogFileName.EnsureDir(); err != nil {
+	captured, err := createLogFile(tc.capturedLogFileName)
+	if err != nil {
+		_ = writer.Close()
 		return nil, err
 	}
+	return newTeeWriteCloser(writer, captured), nil
+}
+
+func (tc TaskCache) outputWriter(prefix string, terminal io.Writer) (io.WriteCloser, error) {
+	// a terminal wrapper that will add prefixes before printing
+	stdoutWriter := logstreamer.NewPrettyWriter(terminal, prefix)
 
-	output, err := tc.LogFileName.Create()
+	// Output that is only shown on error is held back in the log file, so it
+	// is needed even if it won't be cached
+	if (tc.cachingDisabled || tc.rc.writesDisabled) && tc.taskOutputMode != util.ErrorTaskOutput {
+		return nopWriteCloser{stdoutWriter}, nil
+	}
+	// Setup log file
+	fwc, err := createLogFile(tc.LogFileName)
 	if err != nil {
 		return nil, err
 	}
-
-	bufWriter := bufio.NewWriter(output)
-	fwc := &fileWriterCloser{
-		file:  output,
-		bufio: bufWriter,
-	}
-	if tc.taskOutputMode == util.NoTaskOutput || tc.taskOutputMode == util.HashTaskOutput {
-		// only write to log file, not to stdout
-		fwc.Writer = bufWriter
-	} else {
-		fwc.Writer = io.MultiWriter(stdoutWriter, bufWriter)
+	if tc.taskOutputMode != util.NoTaskOutput && tc.taskOutputMode != util.HashTaskOutput && tc.taskOutputMode != util.ErrorTaskOutput {
+		// write to stdout as well as the log file
+		fwc.Writer = io.MultiWriter(stdoutWriter, fwc.bufio)
 	}
 
 	return fwc, nil
@@ -265,33 +385,66 @@ func (tc TaskCache) OutputWriter(prefix string) (io.WriteCloser, error) {
 
 var _emptyIgnore []string
 
-// SaveOutputs is responsible for saving the outputs of task to the cache, after the task has completed
-func (tc TaskCache) SaveOutputs(ctx context.Context, logger hclog.Logger, terminal cli.Ui, duration int) error {
-	if tc.cachingDisabled || tc.rc.writesDisabled {
-		return nil
+// NoOutputsError is returned by SaveOutputs when a task declares outputs in
+// turbo.json, but produced no files matching them. Unless outputs are strict,
+// the task's outputs are still cached, and the error is only a warning.
+type NoOutputsError struct {
+	TaskID  string
+	Outputs []string
+}
+
+func (e *NoOutputsError) Error() string {
+	return fmt.Sprintf("no output files found for task %v. Please check your `outputs` key in `turbo.json`", e.TaskID)
+}
+
+// SaveOutputs is responsible for saving the outputs of task to the cache, after the task has completed.
+// It returns the files and folders it cached, after exclusions, relative to the repo root, and
+// a *NoOutputsError if the task's declared outputs matched no files.
+func (tc TaskCache) SaveOutputs(ctx context.Context, logger hclog.Logger, terminal cli.Ui, duration int) ([]turbopath.AnchoredSystemPath, error) {
+	if tc.cachingDisabled {
+		return nil, nil
 	}
 
-	logger.Debug("caching output", "outputs", tc.repoRelativeGlobs)
+	if tc.pt.TaskDefinition.CacheTTL > 0 && !tc.rc.writesDisabled {
+		if err := writeCachedAt(tc.cachedAtFileName); err != nil {
+			return nil, err
+		}
+	}
 
-	filesToBeCached, err := globby.GlobAll(tc.rc.repoRoot.ToStringDuringMigration(), tc.repoRelativeGlobs.Inclusions, tc.repoRelativeGlobs.Exclusions)
+	filesToBeCached, err := tc.outputFiles()
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	var noOutputsErr error
+	if tc.pt.TaskDefinition.OutputsDeclared && !tc.hasDeclaredOutputFile(filesToBeCached) {
+		noOutputsErr = &NoOutputsError{TaskID: tc.pt.TaskID, Outputs: tc.pt.TaskDefinition.Outputs.Inclusions}
+		logger.Warn(noOutputsErr.Error())
+		if tc.rc.strictOutputs {
+			return nil, noOutputsErr
+		}
+		terminal.Warn(fmt.Sprintf("WARNING: %v", noOutputsErr))
+	}
+	if tc.rc.writesDisabled {
+		return nil, noOutputsErr
 	}
 
-	relativePaths := make([]turbopath.AnchoredSystemPath, len(filesToBeCached))
+	logger.Debug("caching output", "outputs", tc.repoRelativeGlobs)
+
+	relativePaths := make([]turbopath.AnchoredSystemPath, 0, len(filesToBeCached))
 
-	for index, value := range filesToBeCached {
+	for _, value := range filesToBeCached {
 		relativePath, err := tc.rc.repoRoot.RelativePathString(value)
 		if err != nil {
 			logger.Error(fmt.Sprintf("error: %v", err))
 			terminal.Error(fmt.Sprintf("%s%s", ui.ERROR_PREFIX, color.RedString(" %v", fmt.Errorf("File path cannot be made relative: %w", err))))
 			continue
 		}
-		relativePaths[index] = fs.UnsafeToAnchoredSystemPath(relativePath)
+		relativePaths = append(relativePaths, fs.UnsafeToAnchoredSystemPath(relativePath))
 	}
 
 	if err = tc.rc.cache.Put(tc.rc.repoRoot, tc.hash, duration, relativePaths); err != nil {
-		return err
+		return nil, err
 	}
 	err = tc.rc.outputWatcher.NotifyOutputsWritten(ctx, tc.hash, tc.repoRelativeGlobs)
 	if err != nil {
@@ -300,7 +453,31 @@ func (tc TaskCache) SaveOutputs(ctx context.Context, logger hclog.Logger, termin
 		logger.Warn(fmt.Sprintf("Failed to mark outputs as cached for %v: %v", tc.pt.TaskID, err))
 		terminal.Warn(ui.Dim(fmt.Sprintf("Failed to mark outputs as cached for %v: %v", tc.pt.TaskID, err)))
 	}
-	return nil
+	return relativePaths, noOutputsErr
+}
+
+// hasDeclaredOutputFile returns true if any of the matched output files, other
+// than the ones turbo writes itself, is a file rather than a directory
+func (tc TaskCache) hasDeclaredOutputFile(outputFiles []string) bool {
+	for _, file := range outputFiles {
+		if file == tc.LogFileName.ToStringDuringMigration() || file == tc.cachedAtFileName.ToStringDuringMigration() {
+			continue
+		}
+		if info, err := os.Lstat(file); err == nil && !info.IsDir() {
+			return true
+		}
+	}
+	return false
+}
+
+// outputFiles returns the absolute paths of the files and folders matched by the
+// task's outputs. Of the globs that match a file, the last one decides whether it
+// is left out, so a negated glob only removes files from the globs before it.
+func (tc TaskCache) outputFiles() ([]string, error) {
+	if len(tc.repoRelativeGlobs.Ordered) > 0 {
+		return globby.GlobAllOrdered(tc.rc.repoRoot.ToStringDuringMigration(), tc.repoRelativeGlobs.Ordered)
+	}
+	return globby.GlobAll(tc.rc.repoRoot.ToStringDuringMigration(), tc.repoRelativeGlobs.Inclusions, tc.repoRelativeGlobs.Exclusions)
 }
 
 // TaskCache returns a TaskCache instance, providing an interface to the underlying cache specific
@@ -314,25 +491,44 @@ func (rc *RunCache) TaskCache(pt *nodes.PackageTask, hash string) TaskCache {
 	}
 
 	for index, output := range hashableOutputs.Inclusions {
-		repoRelativeGlobs.Inclusions[index] = filepath.Join(pt.Pkg.Dir.ToStringDuringMigration(), output)
+		repoRelativeGlobs.Inclusions[index] = fs.RepoRelativeOutput(pt.Pkg.Dir, output)
 	}
 	for index, output := range hashableOutputs.Exclusions {
-		repoRelativeGlobs.Exclusions[index] = filepath.Join(pt.Pkg.Dir.ToStringDuringMigration(), output)
+		repoRelativeGlobs.Exclusions[index] = fs.RepoRelativeOutput(pt.Pkg.Dir, output)
+	}
+	for _, output := range hashableOutputs.Ordered {
+		if strings.HasPrefix(output, "!") {
+			repoRelativeGlobs.Ordered = append(repoRelativeGlobs.Ordered, "!"+fs.RepoRelativeOutput(pt.Pkg.Dir, output[1:]))
+		} else {
+			repoRelativeGlobs.Ordered = append(repoRelativeGlobs.Ordered, fs.RepoRelativeOutput(pt.Pkg.Dir, output))
+		}
 	}
 
+	// outputLogs in turbo.json takes precedence over --output-logs, which takes
+	// precedence over outputMode
 	taskOutputMode := pt.TaskDefinition.OutputMode
-	if rc.taskOutputModeOverride != nil {
+	if pt.TaskDefinition.OutputLogs != nil {
+		taskOutputMode = *pt.TaskDefinition.OutputLogs
+	} else if rc.taskOutputModeOverride != nil {
 		taskOutputMode = *rc.taskOutputModeOverride
 	}
 
+	var capturedLogFileName turbopath.AbsoluteSystemPath
+	if rc.logToFile {
+		// A scoped package name, e.g. @acme/ui, is a directory of its own
+		capturedLogFileName = rc.repoRoot.UntypedJoin(".turbo", "logs", pt.PackageName, pt.Task+".log")
+	}
+
 	return TaskCache{
-		rc:                rc,
-		repoRelativeGlobs: repoRelativeGlobs,
-		hash:              hash,
-		pt:                pt,
-		taskOutputMode:    taskOutputMode,
-		cachingDisabled:   !pt.TaskDefinition.ShouldCache,
-		LogFileName:       logFileName,
+		rc:                  rc,
+		repoRelativeGlobs:   repoRelativeGlobs,
+		hash:                hash,
+		pt:                  pt,
+		taskOutputMode:      taskOutputMode,
+		cachingDisabled:     !pt.TaskDefinition.ShouldCache,
+		LogFileName:         logFileName,
+		cachedAtFileName:    rc.repoRoot.UntypedJoin(pt.RepoRelativeCachedAtFile()),
+		capturedLogFileName: capturedLogFileName,
 	}
 }
 
@@ -0,0 +1,465 @@
+package runcache
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/mitchellh/cli"
+	"github.com/vercel/turbo/cli/internal/analytics"
+	"github.com/vercel/turbo/cli/internal/cache"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/nodes"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/util"
+	"gotest.tools/v3/assert"
+)
+
+func TestOutputFilesWithNegatedGlobs(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	for _, file := range []string{
+		"apps/web/.turbo/turbo-build.log",
+		"apps/web/dist/index.js",
+		"apps/web/dist/index.js.map",
+		"apps/web/dist/chunks/a.js",
+		"apps/web/dist/chunks/a.js.map",
+		"apps/web/src/index.ts",
+	} {
+		path := repoRoot.UntypedJoin(filepath.FromSlash(file))
+		assert.NilError(t, path.EnsureDir(), "EnsureDir")
+		assert.NilError(t, path.WriteFile([]byte(file), 0644), "WriteFile")
+	}
+
+	testCases := []struct {
+		name          string
+		outputs       []string
+		outputExclude []string
+		want          []string
+	}{
+		{
+			name:    "negation last",
+			outputs: []string{"dist/**", "!dist/**/*.map"},
+			want:    []string{"apps/web/.turbo/turbo-build.log", "apps/web/dist/chunks/a.js", "apps/web/dist/index.js"},
+		},
+		{
+			// A negation only removes files from the globs before it
+			name:    "negation first",
+			outputs: []string{"!dist/**/*.map", "dist/**"},
+			want: []string{
+				"apps/web/.turbo/turbo-build.log",
+				"apps/web/dist/chunks/a.js",
+				"apps/web/dist/chunks/a.js.map",
+				"apps/web/dist/index.js",
+				"apps/web/dist/index.js.map",
+			},
+		},
+		{
+			// A later glob includes a file again after a negation removed it
+			name:    "re-included",
+			outputs: []string{"dist/**", "!dist/**/*.map", "dist/index.js.map"},
+			want: []string{
+				"apps/web/.turbo/turbo-build.log",
+				"apps/web/dist/chunks/a.js",
+				"apps/web/dist/index.js",
+				"apps/web/dist/index.js.map",
+			},
+		},
+		{
+			// Excluded outputs win over every glob in outputs
+			name:          "outputExclude",
+			outputs:       []string{"dist/**", "!dist/**/*.map", "dist/index.js.map"},
+			outputExclude: []string{"dist/index.js.map"},
+			want:          []string{"apps/web/.turbo/turbo-build.log", "apps/web/dist/chunks/a.js", "apps/web/dist/index.js"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			taskDefinition := &fs.TaskDefinition{}
+			raw, err := json.Marshal(map[string]interface{}{"outputs": tc.outputs, "outputExclude": tc.outputExclude})
+			assert.NilError(t, err, "Marshal")
+			assert.NilError(t, json.Unmarshal(raw, taskDefinition), "Unmarshal")
+
+			rc := &RunCache{repoRoot: repoRoot}
+			taskCache := rc.TaskCache(&nodes.PackageTask{
+				TaskID:         "web#build",
+				Task:           "build",
+				PackageName:    "web",
+				Pkg:            &fs.PackageJSON{Dir: turbopath.AnchoredSystemPath(filepath.Join("apps", "web"))},
+				TaskDefinition: taskDefinition,
+			}, "hash")
+			files, err := taskCache.outputFiles()
+			assert.NilError(t, err, "outputFiles")
+
+			got := []string{}
+			for _, file := range files {
+				rel, err := filepath.Rel(repoRoot.ToStringDuringMigration(), file)
+				assert.NilError(t, err, "Rel")
+				if info, err := os.Stat(file); err == nil && !info.IsDir() {
+					got = append(got, filepath.ToSlash(rel))
+				}
+			}
+			assert.DeepEqual(t, got, tc.want)
+		})
+	}
+}
+
+// missCache is a cache that never has the outputs of a task
+type missCache struct{}
+
+func (missCache) Fetch(anchor turbopath.AbsoluteSystemPath, hash string, files []string) (cache.ItemStatus, []turbopath.AnchoredSystemPath, int, error) {
+	return cache.ItemStatus{}, nil, 0, nil
+}
+func (missCache) Exists(hash string) (cache.ItemStatus, error) { return cache.ItemStatus{}, nil }
+func (missCache) Put(anchor turbopath.AbsoluteSystemPath, hash string, duration int, files []turbopath.AnchoredSystemPath) error {
+	return nil
+}
+func (missCache) Clean(anchor turbopath.AbsoluteSystemPath) {}
+func (missCache) CleanAll()                                 {}
+func (missCache) Shutdown()                                 {}
+
+// runTask runs a fake task through the task cache and returns what it printed
+func runTask(t *testing.T, taskDefinition *fs.TaskDefinition, override *util.TaskOutputMode, fail bool) string {
+	t.Helper()
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	rc := New(missCache{}, repoRoot, Opts{TaskOutputModeOverride: override}, nil)
+	taskCache := rc.TaskCache(&nodes.PackageTask{
+		TaskID:         "web#build",
+		Task:           "build",
+		PackageName:    "web",
+		Pkg:            &fs.PackageJSON{Dir: turbopath.AnchoredSystemPath("web")},
+		TaskDefinition: taskDefinition,
+	}, "the-hash")
+
+	var terminal bytes.Buffer
+	prefixedUI := &cli.PrefixedUi{
+		Ui:           &cli.BasicUi{Writer: &terminal, ErrorWriter: &terminal},
+		OutputPrefix: "web:build: ",
+		InfoPrefix:   "web:build: ",
+		ErrorPrefix:  "web:build: ",
+		WarnPrefix:   "web:build: ",
+	}
+	logger := hclog.NewNullLogger()
+	status, err := taskCache.RestoreOutputs(context.Background(), prefixedUI, logger)
+	assert.NilError(t, err, "RestoreOutputs")
+	assert.Assert(t, !status.Hit())
+
+	writer, err := taskCache.OutputWriter("web:build: ", &terminal)
+	assert.NilError(t, err, "OutputWriter")
+	_, err = writer.Write([]byte("task output\n"))
+	assert.NilError(t, err, "Write")
+	assert.NilError(t, writer.Close(), "Close")
+	if fail {
+		taskCache.OnError(prefixedUI, logger)
+	} else {
+		_, err := taskCache.SaveOutputs(context.Background(), logger, prefixedUI, 0)
+		assert.NilError(t, err, "SaveOutputs")
+	}
+	return terminal.String()
+}
+
+func TestTaskOutputModes(t *testing.T) {
+	testCases := []struct {
+		mode util.TaskOutputMode
+		// whether the cache status and the task's output are shown when it succeeds or fails
+		succeededStatus bool
+		succeededOutput bool
+		failedStatus    bool
+		failedOutput    bool
+	}{
+		{util.FullTaskOutput, true, true, true, true},
+		{util.HashTaskOutput, true, false, true, false},
+		{util.NewTaskOutput, true, true, true, true},
+		{util.ErrorTaskOutput, false, false, true, true},
+		{util.NoTaskOutput, false, false, false, false},
+	}
+	for _, tc := range testCases {
+		mode, err := util.ToTaskOutputModeString(tc.mode)
+		assert.NilError(t, err)
+		t.Run(mode, func(t *testing.T) {
+			for _, fail := range []bool{false, true} {
+				expectStatus, expectOutput := tc.succeededStatus, tc.succeededOutput
+				if fail {
+					expectStatus, expectOutput = tc.failedStatus, tc.failedOutput
+				}
+				// The mode is the same whether it comes from outputLogs or --output-logs
+				for _, source := range []string{"outputLogs", "--output-logs"} {
+					taskDefinition := &fs.TaskDefinition{ShouldCache: true}
+					var override *util.TaskOutputMode
+					mode := tc.mode
+					if source == "outputLogs" {
+						taskDefinition.OutputLogs = &mode
+					} else {
+						override = &mode
+					}
+					printed := runTask(t, taskDefinition, override, fail)
+					assert.Equal(t, strings.Contains(printed, "cache miss, executing"), expectStatus, "%v (fail: %v): %q", source, fail, printed)
+					assert.Equal(t, strings.Contains(printed, "task output"), expectOutput, "%v (fail: %v): %q", source, fail, printed)
+				}
+			}
+		})
+	}
+}
+
+func TestOutputLogsTakesPrecedence(t *testing.T) {
+	errorsOnly := util.ErrorTaskOutput
+	full := util.FullTaskOutput
+	rc := New(missCache{}, fs.AbsoluteSystemPathFromUpstream(t.TempDir()), Opts{TaskOutputModeOverride: &full}, nil)
+	pt := &nodes.PackageTask{
+		TaskID:         "web#build",
+		Task:           "build",
+		PackageName:    "web",
+		Pkg:            &fs.PackageJSON{Dir: turbopath.AnchoredSystemPath("web")},
+		TaskDefinition: &fs.TaskDefinition{OutputMode: util.NoTaskOutput, OutputLogs: &errorsOnly},
+	}
+	assert.Equal(t, rc.TaskCache(pt, "hash").taskOutputMode, util.ErrorTaskOutput)
+
+	// Without outputLogs, --output-logs overrides outputMode
+	pt.TaskDefinition.OutputLogs = nil
+	assert.Equal(t, rc.TaskCache(pt, "hash").taskOutputMode, util.FullTaskOutput)
+}
+
+// hitCache is a cache that always has the outputs of a task, which are already in place
+type hitCache struct {
+	missCache
+}
+
+func (hitCache) Fetch(anchor turbopath.AbsoluteSystemPath, hash string, files []string) (cache.ItemStatus, []turbopath.AnchoredSystemPath, int, error) {
+	return cache.ItemStatus{Local: true}, nil, 0, nil
+}
+
+func TestLogToFile(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	pt := &nodes.PackageTask{
+		TaskID:         "@acme/ui#build",
+		Task:           "build",
+		PackageName:    "@acme/ui",
+		Pkg:            &fs.PackageJSON{Dir: turbopath.AnchoredSystemPath(filepath.Join("packages", "ui"))},
+		TaskDefinition: &fs.TaskDefinition{ShouldCache: true},
+	}
+	capturedLogFile := repoRoot.UntypedJoin(".turbo", "logs", "@acme", "ui", "build.log")
+	var terminal bytes.Buffer
+	prefixedUI := &cli.PrefixedUi{Ui: &cli.BasicUi{Writer: &terminal, ErrorWriter: &terminal}}
+	logger := hclog.NewNullLogger()
+
+	// The output of a task that runs is written to the file as well as the terminal
+	rc := New(missCache{}, repoRoot, Opts{LogToFile: true}, nil)
+	taskCache := rc.TaskCache(pt, "the-hash")
+	writer, err := taskCache.OutputWriter("ui:build: ", &terminal)
+	assert.NilError(t, err, "OutputWriter")
+	_, err = writer.Write([]byte("task output\n"))
+	assert.NilError(t, err, "Write")
+	assert.NilError(t, writer.Close(), "Close")
+	contents, err := capturedLogFile.ReadFile()
+	assert.NilError(t, err, "ReadFile")
+	assert.Equal(t, string(contents), "task output\n")
+	assert.Assert(t, strings.Contains(terminal.String(), "task output"))
+
+	// The replayed log of a task restored from the cache is written to the file
+	assert.NilError(t, capturedLogFile.Remove(), "Remove")
+	rc = New(hitCache{}, repoRoot, Opts{LogToFile: true}, nil)
+	status, err := rc.TaskCache(pt, "the-hash").RestoreOutputs(context.Background(), prefixedUI, logger)
+	assert.NilError(t, err, "RestoreOutputs")
+	assert.Assert(t, status.Hit())
+	contents, err = capturedLogFile.ReadFile()
+	assert.NilError(t, err, "ReadFile")
+	assert.Equal(t, string(contents), "task output\n")
+
+	// Without --log-to-file, nothing is written
+	assert.NilError(t, capturedLogFile.Remove(), "Remove")
+	rc = New(hitCache{}, repoRoot, Opts{}, nil)
+	_, err = rc.TaskCache(pt, "the-hash").RestoreOutputs(context.Background(), prefixedUI, logger)
+	assert.NilError(t, err, "RestoreOutputs")
+	assert.Assert(t, !capturedLogFile.FileExists())
+}
+
+// putCache is a cache that records whether outputs were put into it
+type putCache struct {
+	missCache
+	put *bool
+}
+
+func (c putCache) Put(anchor turbopath.AbsoluteSystemPath, hash string, duration int, files []turbopath.AnchoredSystemPath) error {
+	*c.put = true
+	return nil
+}
+
+func TestSaveOutputsWithoutOutputFiles(t *testing.T) {
+	taskDefinition := &fs.TaskDefinition{}
+	assert.NilError(t, json.Unmarshal([]byte(`{"outputs": ["dist/**"]}`), taskDefinition), "Unmarshal")
+	pt := &nodes.PackageTask{
+		TaskID:         "web#build",
+		Task:           "build",
+		PackageName:    "web",
+		Pkg:            &fs.PackageJSON{Dir: turbopath.AnchoredSystemPath("web")},
+		TaskDefinition: taskDefinition,
+	}
+	logger := hclog.NewNullLogger()
+
+	testCases := []struct {
+		name          string
+		files         []string
+		strict        bool
+		wantWarning   bool
+		wantCached    bool
+		wantErrorText string
+	}{
+		// The task's log, and an empty output directory, aren't outputs it produced
+		{name: "no output files", files: []string{"web/.turbo/turbo-build.log"}, wantWarning: true, wantCached: true, wantErrorText: "no output files found for task web#build"},
+		{name: "an output file", files: []string{"web/.turbo/turbo-build.log", "web/dist/index.js"}, wantCached: true},
+		{name: "strict outputs", files: []string{"web/.turbo/turbo-build.log"}, strict: true, wantErrorText: "no output files found for task web#build"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+			assert.NilError(t, repoRoot.UntypedJoin("web", "dist").MkdirAll(0755), "MkdirAll")
+			for _, file := range tc.files {
+				path := repoRoot.UntypedJoin(filepath.FromSlash(file))
+				assert.NilError(t, path.EnsureDir(), "EnsureDir")
+				assert.NilError(t, path.WriteFile([]byte(file), 0644), "WriteFile")
+			}
+
+			put := false
+			rc := New(putCache{put: &put}, repoRoot, Opts{StrictOutputs: tc.strict}, nil)
+			var terminal bytes.Buffer
+			prefixedUI := &cli.PrefixedUi{Ui: &cli.BasicUi{Writer: &terminal, ErrorWriter: &terminal}}
+			_, err := rc.TaskCache(pt, "the-hash").SaveOutputs(context.Background(), logger, prefixedUI, 0)
+			if tc.wantErrorText == "" {
+				assert.NilError(t, err, "SaveOutputs")
+			} else {
+				assert.ErrorContains(t, err, tc.wantErrorText)
+				noOutputsErr := &NoOutputsError{}
+				assert.Assert(t, errors.As(err, &noOutputsErr))
+				assert.DeepEqual(t, noOutputsErr.Outputs, []string{"dist/**"})
+			}
+			assert.Equal(t, strings.Contains(terminal.String(), "WARNING: no output files found"), tc.wantWarning)
+			assert.Equal(t, put, tc.wantCached)
+		})
+	}
+}
+
+func TestSaveOutputsWithDefaultOutputs(t *testing.T) {
+	taskDefinition := &fs.TaskDefinition{}
+	assert.NilError(t, json.Unmarshal([]byte(`{}`), taskDefinition), "Unmarshal")
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	rc := New(missCache{}, repoRoot, Opts{StrictOutputs: true}, nil)
+	taskCache := rc.TaskCache(&nodes.PackageTask{
+		TaskID:         "web#lint",
+		Task:           "lint",
+		PackageName:    "web",
+		Pkg:            &fs.PackageJSON{Dir: turbopath.AnchoredSystemPath("web")},
+		TaskDefinition: taskDefinition,
+	}, "the-hash")
+
+	// Only outputs declared in turbo.json are expected to match files
+	var terminal bytes.Buffer
+	prefixedUI := &cli.PrefixedUi{Ui: &cli.BasicUi{Writer: &terminal, ErrorWriter: &terminal}}
+	_, err := taskCache.SaveOutputs(context.Background(), hclog.NewNullLogger(), prefixedUI, 0)
+	assert.NilError(t, err, "SaveOutputs")
+	assert.Equal(t, terminal.String(), "")
+}
+
+// nullRecorder ignores the events of a cache
+type nullRecorder struct{}
+
+func (nullRecorder) LogEvent(analytics.EventPayload) {}
+
+func TestRootRelativeOutputs(t *testing.T) {
+	taskDefinition := &fs.TaskDefinition{}
+	assert.NilError(t, json.Unmarshal([]byte(`{"outputs": ["dist/**", "$ROOT$/generated/web/**", "/shared/**", "!/shared/**/*.map"]}`), taskDefinition), "Unmarshal")
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	outputs := []string{
+		"apps/web/dist/index.js",
+		"generated/web/schema.ts",
+		"shared/types.d.ts",
+	}
+	for _, file := range append([]string{"generated/other.ts", "shared/types.d.ts.map", "apps/web/generated/local.ts"}, outputs...) {
+		path := repoRoot.UntypedJoin(filepath.FromSlash(file))
+		assert.NilError(t, path.EnsureDir(), "EnsureDir")
+		assert.NilError(t, path.WriteFile([]byte(file), 0644), "WriteFile")
+	}
+
+	turboCache, err := cache.New(cache.Opts{
+		OverrideDir: repoRoot.UntypedJoin("cache").ToString(),
+		SkipRemote:  true,
+	}, repoRoot, nil, nullRecorder{}, func(cache.Cache, error) {})
+	assert.NilError(t, err, "cache.New")
+	rc := New(turboCache, repoRoot, Opts{}, nil)
+	taskCache := rc.TaskCache(&nodes.PackageTask{
+		TaskID:         "web#build",
+		Task:           "build",
+		PackageName:    "web",
+		Pkg:            &fs.PackageJSON{Dir: turbopath.AnchoredSystemPath(filepath.Join("apps", "web"))},
+		TaskDefinition: taskDefinition,
+	}, "the-hash")
+	var terminal bytes.Buffer
+	prefixedUI := &cli.PrefixedUi{Ui: &cli.BasicUi{Writer: &terminal, ErrorWriter: &terminal}}
+	logger := hclog.NewNullLogger()
+	_, err = taskCache.SaveOutputs(context.Background(), logger, prefixedUI, 0)
+	assert.NilError(t, err, "SaveOutputs")
+
+	// Restoring recreates the outputs relative to the root, and only the outputs
+	for _, dir := range []string{"apps", "generated", "shared"} {
+		assert.NilError(t, repoRoot.UntypedJoin(dir).RemoveAll(), "RemoveAll")
+	}
+	status, err := taskCache.RestoreOutputs(context.Background(), prefixedUI, logger)
+	assert.NilError(t, err, "RestoreOutputs")
+	assert.Assert(t, status.Local)
+	for _, file := range outputs {
+		contents, err := repoRoot.UntypedJoin(filepath.FromSlash(file)).ReadFile()
+		assert.NilError(t, err, "ReadFile")
+		assert.Equal(t, string(contents), file)
+	}
+	for _, file := range []string{"generated/other.ts", "shared/types.d.ts.map", "apps/web/generated/local.ts"} {
+		assert.Assert(t, !repoRoot.UntypedJoin(filepath.FromSlash(file)).FileExists(), "%v was restored", file)
+	}
+}
+
+func TestSaveOutputsLeavesOutExcludedOutputs(t *testing.T) {
+	taskDefinition := &fs.TaskDefinition{}
+	assert.NilError(t, json.Unmarshal([]byte(`{"outputs": ["dist/**"], "outputExclude": ["dist/**/*.map"]}`), taskDefinition), "Unmarshal")
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	for _, file := range []string{"web/dist/index.js", "web/dist/index.js.map"} {
+		path := repoRoot.UntypedJoin(filepath.FromSlash(file))
+		assert.NilError(t, path.EnsureDir(), "EnsureDir")
+		assert.NilError(t, path.WriteFile([]byte(file), 0644), "WriteFile")
+	}
+
+	turboCache, err := cache.New(cache.Opts{
+		OverrideDir: repoRoot.UntypedJoin("cache").ToString(),
+		SkipRemote:  true,
+	}, repoRoot, nil, nullRecorder{}, func(cache.Cache, error) {})
+	assert.NilError(t, err, "cache.New")
+	rc := New(turboCache, repoRoot, Opts{}, nil)
+	taskCache := rc.TaskCache(&nodes.PackageTask{
+		TaskID:         "web#build",
+		Task:           "build",
+		PackageName:    "web",
+		Pkg:            &fs.PackageJSON{Dir: turbopath.AnchoredSystemPath("web")},
+		TaskDefinition: taskDefinition,
+	}, "the-hash")
+	var terminal bytes.Buffer
+	prefixedUI := &cli.PrefixedUi{Ui: &cli.BasicUi{Writer: &terminal, ErrorWriter: &terminal}}
+	logger := hclog.NewNullLogger()
+	cachedOutputs, err := taskCache.SaveOutputs(context.Background(), logger, prefixedUI, 0)
+	assert.NilError(t, err, "SaveOutputs")
+	cachedFiles := make([]string, len(cachedOutputs))
+	for i, output := range cachedOutputs {
+		cachedFiles[i] = output.ToUnixPath().ToString()
+	}
+	assert.DeepEqual(t, cachedFiles, []string{"web/dist", "web/dist/index.js"})
+
+	// The excluded file is left on disk, but restoring doesn't bring it back
+	assert.Assert(t, repoRoot.UntypedJoin("web", "dist", "index.js.map").FileExists())
+	assert.NilError(t, repoRoot.UntypedJoin("web", "dist").RemoveAll(), "RemoveAll")
+	status, err := taskCache.RestoreOutputs(context.Background(), prefixedUI, logger)
+	assert.NilError(t, err, "RestoreOutputs")
+	assert.Assert(t, status.Local)
+	assert.Assert(t, repoRoot.UntypedJoin("web", "dist", "index.js").FileExists())
+	assert.Assert(t, !repoRoot.UntypedJoin("web", "dist", "index.js.map").FileExists(), "excluded output was restored")
+}
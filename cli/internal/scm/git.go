@@ -22,6 +22,9 @@ type git struct {
 
 // ChangedFiles returns a list of modified files since the given commit, optionally including untracked files.
 func (g *git) ChangedFiles(fromCommit string, toCommit string, includeUntracked bool, relativeTo string) ([]string, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, errors.Wrap(err, "finding changed files requires git to be installed")
+	}
 	if relativeTo == "" {
 		relativeTo = g.repoRoot
 	}
@@ -75,6 +78,43 @@ func (g *git) ChangedFiles(fromCommit string, toCommit string, includeUntracked
 	return normalized, nil
 }
 
+// Metadata returns the SHA, branch and tag of the commit that is checked out
+func (g *git) Metadata() (Metadata, error) {
+	sha, err := g.output("rev-parse", "HEAD")
+	if err != nil {
+		return Metadata{}, errors.Wrap(err, "reading current commit")
+	}
+	branch, err := g.output("rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		return Metadata{}, errors.Wrap(err, "reading current branch")
+	}
+	if branch == "HEAD" {
+		// HEAD is detached, so there is no branch
+		branch = ""
+	}
+	tag, err := g.output("describe", "--tags", "--exact-match", "HEAD")
+	if err != nil {
+		exitErr := &exec.ExitError{}
+		if !errors.As(err, &exitErr) {
+			return Metadata{}, errors.Wrap(err, "reading current tag")
+		}
+		// git describe exits with an error if the commit isn't tagged
+		tag = ""
+	}
+	return Metadata{SHA: sha, Branch: branch, Tag: tag}, nil
+}
+
+// output runs git in the repository root and returns its trimmed output
+func (g *git) output(args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = g.repoRoot
+	out, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
 func commitExists(commit string) (bool, error) {
 	err := exec.Command("git", "cat-file", "-t", commit).Run()
 	if err != nil {
@@ -7,7 +7,9 @@
 package scm
 
 import (
+	"fmt"
 	"path/filepath"
+	"sort"
 
 	"github.com/pkg/errors"
 
@@ -21,6 +23,34 @@ var ErrFallback = errors.New("cannot find a .git folder. Falling back to manual
 type SCM interface {
 	// ChangedFiles returns a list of modified files since the given commit, optionally including untracked files.*/
 	ChangedFiles(fromCommit string, toCommit string, includeUntracked bool, relativeTo string) ([]string, error)
+	// Metadata returns facts about the commit that is checked out
+	Metadata() (Metadata, error)
+}
+
+// Metadata describes the commit that is checked out. Branch is empty if HEAD is
+// detached, and Tag is empty if the commit isn't tagged.
+type Metadata struct {
+	SHA    string
+	Branch string
+	Tag    string
+}
+
+// EnvPairs returns sorted key=value env var pairs for the given facts, which
+// are keys of fs.GitEnvVars
+func (m Metadata) EnvPairs(facts []string) []string {
+	values := map[string]string{
+		"sha":    m.SHA,
+		"branch": m.Branch,
+		"tag":    m.Tag,
+	}
+	pairs := make([]string, 0, len(facts))
+	for _, fact := range facts {
+		if envVar, ok := fs.GitEnvVars[fact]; ok {
+			pairs = append(pairs, fmt.Sprintf("%v=%v", envVar, values[fact]))
+		}
+	}
+	sort.Strings(pairs)
+	return pairs
 }
 
 // newGitSCM returns a new SCM instance for this repo root.
@@ -3,8 +3,14 @@
 // SPDX-License-Identifier: Apache-2.0
 package scm
 
+import "errors"
+
 type stub struct{}
 
 func (s *stub) ChangedFiles(fromCommit string, toCommit string, includeUntracked bool, relativeTo string) ([]string, error) {
-	return nil, nil
+	return nil, errors.New("cannot find changed files without a .git folder")
+}
+
+func (s *stub) Metadata() (Metadata, error) {
+	return Metadata{}, errors.New("cannot read git metadata without a .git folder")
 }
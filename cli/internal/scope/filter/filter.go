@@ -188,6 +188,30 @@ func (r *Resolver) filterGraphWithSelector(selector *TargetSelector) (util.Set,
 	return r.filterNodesWithSelector(selector)
 }
 
+// resolveParentDir returns the directory pattern that a parentDir selector
+// matches workspaces with. Globs, the root and workspace directories match as
+// they are, while any other directory resolves to the workspace that contains
+// it, so that a directory inside of a workspace selects that workspace.
+func (r *Resolver) resolveParentDir(parentDir string) (string, error) {
+	if parentDir == r.Cwd || strings.ContainsAny(parentDir, "*?[{") {
+		return parentDir, nil
+	}
+	owner := ""
+	for _, pkg := range r.PackageInfos {
+		pkgDir := filepath.Join(r.Cwd, pkg.Dir.ToStringDuringMigration())
+		if pkgDir == parentDir {
+			return parentDir, nil
+		}
+		if strings.HasPrefix(parentDir, pkgDir+string(filepath.Separator)) && len(pkgDir) > len(owner) {
+			owner = pkgDir
+		}
+	}
+	if owner == "" {
+		return "", fmt.Errorf("no workspace found for directory %v", parentDir)
+	}
+	return owner, nil
+}
+
 // filterNodesWithSelector returns the set of nodes that match a given selector
 func (r *Resolver) filterNodesWithSelector(selector *TargetSelector) (util.Set, error) {
 	entryPackages := make(util.Set)
@@ -200,6 +224,12 @@ func (r *Resolver) filterNodesWithSelector(selector *TargetSelector) (util.Set,
 			return nil, err
 		}
 		parentDir := selector.parentDir
+		if parentDir != "" {
+			parentDir, err = r.resolveParentDir(parentDir)
+			if err != nil {
+				return nil, err
+			}
+		}
 		for pkgName := range changedPkgs {
 			if parentDir != "" {
 				if pkgName == util.RootPkgName {
@@ -221,10 +251,24 @@ func (r *Resolver) filterNodesWithSelector(selector *TargetSelector) (util.Set,
 				entryPackages.Add(pkgName)
 			}
 		}
+	} else if selector.tag != "" {
+		// get packages by tag
+		selectorWasUsed = true
+		for name, pkg := range r.PackageInfos {
+			for _, tag := range pkg.Tags {
+				if tag == selector.tag {
+					entryPackages.Add(name)
+					break
+				}
+			}
+		}
 	} else if selector.parentDir != "" {
 		// get packages by path
 		selectorWasUsed = true
-		parentDir := selector.parentDir
+		parentDir, err := r.resolveParentDir(selector.parentDir)
+		if err != nil {
+			return nil, err
+		}
 		if parentDir == r.Cwd {
 			entryPackages.Add(util.RootPkgName)
 		} else {
@@ -246,6 +246,52 @@ func Test_filter(t *testing.T) {
 			},
 			[]string{util.RootPkgName},
 		},
+		{
+			"select package by a directory inside of it",
+			[]*TargetSelector{
+				{
+					parentDir: filepath.Join(root, "/packages/project-1/src/components"),
+				},
+			},
+			[]string{"project-1"},
+		},
+		{
+			"select package by a file inside of it",
+			[]*TargetSelector{
+				{
+					parentDir: filepath.Join(root, "/project-2/index.ts"),
+				},
+			},
+			[]string{"project-2"},
+		},
+		{
+			"select nested package by a directory inside of it",
+			[]*TargetSelector{
+				{
+					parentDir: filepath.Join(root, "/project-5/packages/project-6/src"),
+				},
+			},
+			[]string{"project-6"},
+		},
+		{
+			"select outer package by a directory beside a nested package",
+			[]*TargetSelector{
+				{
+					parentDir: filepath.Join(root, "/project-5/src"),
+				},
+			},
+			[]string{"project-5"},
+		},
+		{
+			"select package with dependencies by a directory inside of it",
+			[]*TargetSelector{
+				{
+					includeDependencies: true,
+					parentDir:           filepath.Join(root, "/packages/project-1/src"),
+				},
+			},
+			[]string{"project-1", "project-2", "project-4"},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -276,6 +322,26 @@ func Test_filter(t *testing.T) {
 			t.Errorf("unmatched filter expected to report one unused filter, got %v", len(pkgs.unusedFilters))
 		}
 	})
+
+	t.Run("error on a directory outside of every workspace", func(t *testing.T) {
+		for _, dir := range []string{
+			filepath.Join(root, "/packages"),
+			filepath.Join(root, "/project-10"),
+			filepath.Dir(root),
+		} {
+			_, err := r.GetFilteredPackages([]*TargetSelector{
+				{
+					parentDir: dir,
+				},
+			})
+			if err == nil {
+				t.Fatalf("expected an error selecting directory %v", dir)
+			}
+			if !strings.Contains(err.Error(), "no workspace found for directory") {
+				t.Errorf("expected a missing workspace error selecting directory %v, got %v", dir, err)
+			}
+		}
+	})
 }
 
 func Test_matchScopedPackage(t *testing.T) {
@@ -531,3 +597,84 @@ func Test_SCM(t *testing.T) {
 		})
 	}
 }
+
+func Test_filterByTag(t *testing.T) {
+	root, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("failed to get working directory: %v", err)
+	}
+	// web -> ui -> utils
+	// docs -> ui
+	// scripts
+	graph := &dag.AcyclicGraph{}
+	packageJSONs := make(map[interface{}]*fs.PackageJSON)
+	for name, tags := range map[string][]string{
+		"web":     {"app"},
+		"docs":    {"app"},
+		"ui":      {"lib"},
+		"utils":   {"lib"},
+		"scripts": {"tool"},
+	} {
+		graph.Add(name)
+		packageJSONs[name] = &fs.PackageJSON{
+			Name: name,
+			Dir:  turbopath.AnchoredSystemPath(name),
+			Tags: tags,
+		}
+	}
+	graph.Connect(dag.BasicEdge("web", "ui"))
+	graph.Connect(dag.BasicEdge("docs", "ui"))
+	graph.Connect(dag.BasicEdge("ui", "utils"))
+
+	r := &Resolver{
+		Graph:        graph,
+		PackageInfos: packageJSONs,
+		Cwd:          root,
+	}
+
+	testCases := []struct {
+		Name     string
+		Patterns []string
+		Expected []string
+	}{
+		{
+			"tag only",
+			[]string{"tag:app"},
+			[]string{"web", "docs"},
+		},
+		{
+			"tag with dependents",
+			[]string{"...tag:lib"},
+			[]string{"web", "docs", "ui", "utils"},
+		},
+		{
+			"tag with dependencies, excluding the tagged packages",
+			[]string{"tag:app^..."},
+			[]string{"ui", "utils"},
+		},
+		{
+			"tag combined with a package name",
+			[]string{"tag:lib", "scripts"},
+			[]string{"ui", "utils", "scripts"},
+		},
+		{
+			"excluding a tag",
+			[]string{"!tag:app"},
+			[]string{"ui", "utils", "scripts"},
+		},
+		{
+			"unknown tag",
+			[]string{"tag:unknown"},
+			[]string{},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			pkgs, err := r.GetPackagesFromPatterns(tc.Patterns)
+			if err != nil {
+				t.Fatalf("%v failed to filter packages: %v", tc.Name, err)
+			}
+			setMatches(t, tc.Name, pkgs, tc.Expected)
+		})
+	}
+}
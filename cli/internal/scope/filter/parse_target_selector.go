@@ -16,13 +16,14 @@ type TargetSelector struct {
 	followProdDepsOnly  bool
 	parentDir           string
 	namePattern         string
+	tag                 string
 	fromRef             string
 	toRefOverride       string
 	raw                 string
 }
 
 func (ts *TargetSelector) IsValid() bool {
-	return ts.fromRef != "" || ts.parentDir != "" || ts.namePattern != ""
+	return ts.fromRef != "" || ts.parentDir != "" || ts.namePattern != "" || ts.tag != ""
 }
 
 // getToRef returns the git ref to use for upper bound of the comparison when finding changed
@@ -36,6 +37,11 @@ func (ts *TargetSelector) getToRef() string {
 
 var errCantMatchDependencies = errors.New("cannot use match dependencies without specifying either a directory or package")
 
+var errMissingTag = errors.New("tag selector is missing a tag name")
+
+// tagSelectorPrefix marks a selector for the packages with a given tag
+const tagSelectorPrefix = "tag:"
+
 var targetSelectorRegex = regexp.MustCompile(`^([^.](?:[^{}[\]]*[^{}[\].])?)?(\{[^}]+\})?((?:\.{3})?\[[^\]]+\])?$`)
 
 // ParseTargetSelector is a function that returns pnpm compatible --filter command line flags
@@ -65,6 +71,21 @@ func ParseTargetSelector(rawSelector string, prefix string) (TargetSelector, err
 		}
 	}
 
+	if strings.HasPrefix(selector, tagSelectorPrefix) {
+		tag := strings.TrimPrefix(selector, tagSelectorPrefix)
+		if tag == "" {
+			return TargetSelector{}, errMissingTag
+		}
+		return TargetSelector{
+			exclude:             exclude,
+			excludeSelf:         excludeSelf,
+			includeDependencies: includeDependencies,
+			includeDependents:   includeDependents,
+			tag:                 tag,
+			raw:                 rawSelector,
+		}, nil
+	}
+
 	matches := targetSelectorRegex.FindAllStringSubmatch(selector, -1)
 
 	if len(matches) == 0 {
@@ -73,7 +94,7 @@ func ParseTargetSelector(rawSelector string, prefix string) (TargetSelector, err
 				exclude:             exclude,
 				includeDependencies: includeDependencies,
 				includeDependents:   includeDependents,
-				parentDir:           filepath.Join(prefix, selector),
+				parentDir:           joinParentDir(prefix, selector),
 				raw:                 rawSelector,
 			}, nil
 		}
@@ -98,7 +119,7 @@ func ParseTargetSelector(rawSelector string, prefix string) (TargetSelector, err
 		}
 		if len(matches[0][2]) > 0 {
 			parentDir = matches[0][2]
-			parentDir = filepath.Join(prefix, parentDir[1:len(parentDir)-1])
+			parentDir = joinParentDir(prefix, parentDir[1:len(parentDir)-1])
 		}
 		if len(matches[0][3]) > 0 {
 			fromRef = matches[0][3]
@@ -133,6 +154,15 @@ func ParseTargetSelector(rawSelector string, prefix string) (TargetSelector, err
 	}, nil
 }
 
+// joinParentDir returns the directory of a selector, which is relative to prefix
+// unless it is absolute
+func joinParentDir(prefix string, dir string) string {
+	if filepath.IsAbs(dir) {
+		return filepath.Clean(dir)
+	}
+	return filepath.Join(prefix, dir)
+}
+
 // isSelectorByLocation returns true if the selector is by filesystem location
 func isSelectorByLocation(rawSelector string) bool {
 	if rawSelector[0:1] != "." {
@@ -312,6 +312,48 @@ func TestParseTargetSelector(t *testing.T) {
 			TargetSelector{},
 			true,
 		},
+		{
+			"tag:app",
+			args{"tag:app", "."},
+			TargetSelector{
+				tag: "app",
+			},
+			false,
+		},
+		{
+			"...tag:lib",
+			args{"...tag:lib", "."},
+			TargetSelector{
+				includeDependents: true,
+				tag:               "lib",
+			},
+			false,
+		},
+		{
+			"tag:app^...",
+			args{"tag:app^...", "."},
+			TargetSelector{
+				excludeSelf:         true,
+				includeDependencies: true,
+				tag:                 "app",
+			},
+			false,
+		},
+		{
+			"!tag:tool",
+			args{"!tag:tool", "."},
+			TargetSelector{
+				exclude: true,
+				tag:     "tool",
+			},
+			false,
+		},
+		{
+			"tag:",
+			args{"tag:", "."},
+			TargetSelector{},
+			true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -1,6 +1,7 @@
 package scope
 
 import (
+	"errors"
 	"fmt"
 	"path/filepath"
 	"reflect"
@@ -11,6 +12,7 @@ import (
 	"github.com/vercel/turbo/cli/internal/context"
 	"github.com/vercel/turbo/cli/internal/fs"
 	"github.com/vercel/turbo/cli/internal/packagemanager"
+	"github.com/vercel/turbo/cli/internal/scm"
 	"github.com/vercel/turbo/cli/internal/turbopath"
 	"github.com/vercel/turbo/cli/internal/ui"
 	"github.com/vercel/turbo/cli/internal/util"
@@ -18,10 +20,20 @@ import (
 
 type mockSCM struct {
 	changed []string
+	err     error
+	// fromCommit and toCommit record the refs of the last comparison
+	fromCommit string
+	toCommit   string
 }
 
-func (m *mockSCM) ChangedFiles(_fromCommit string, _toCommit string, _includeUntracked bool, _relativeTo string) ([]string, error) {
-	return m.changed, nil
+func (m *mockSCM) ChangedFiles(fromCommit string, toCommit string, _includeUntracked bool, _relativeTo string) ([]string, error) {
+	m.fromCommit = fromCommit
+	m.toCommit = toCommit
+	return m.changed, m.err
+}
+
+func (m *mockSCM) Metadata() (scm.Metadata, error) {
+	return scm.Metadata{}, nil
 }
 
 func TestResolvePackages(t *testing.T) {
@@ -296,3 +308,128 @@ func TestResolvePackages(t *testing.T) {
 		})
 	}
 }
+
+func TestResolvePackagesChangedInRange(t *testing.T) {
+	tui := ui.Default()
+	logger := hclog.Default()
+	// web -> ui -> config
+	// docs -> ui
+	graph := dag.AcyclicGraph{}
+	graph.Add("web")
+	graph.Add("docs")
+	graph.Add("ui")
+	graph.Add("config")
+	graph.Connect(dag.BasicEdge("web", "ui"))
+	graph.Connect(dag.BasicEdge("docs", "ui"))
+	graph.Connect(dag.BasicEdge("ui", "config"))
+	packageInfos := map[interface{}]*fs.PackageJSON{
+		"web": {
+			Dir: turbopath.AnchoredUnixPath("apps/web").ToSystemPath(),
+		},
+		"docs": {
+			Dir: turbopath.AnchoredUnixPath("apps/docs").ToSystemPath(),
+		},
+		"ui": {
+			Dir: turbopath.AnchoredUnixPath("packages/ui").ToSystemPath(),
+		},
+		"config": {
+			Dir: turbopath.AnchoredUnixPath("packages/config").ToSystemPath(),
+		},
+	}
+	packageNames := []string{}
+	for name := range packageInfos {
+		packageNames = append(packageNames, name.(string))
+	}
+
+	testCases := []struct {
+		name     string
+		filter   string
+		changed  []string
+		err      error
+		expected []string
+		fromRef  string
+		toRef    string
+	}{
+		{
+			name:     "changed since a ref",
+			filter:   "[main]",
+			changed:  []string{"packages/ui/src/button.tsx"},
+			expected: []string{"ui"},
+			fromRef:  "main",
+			toRef:    "HEAD",
+		},
+		{
+			name:     "changed between two refs",
+			filter:   "[origin/main...feature]",
+			changed:  []string{"apps/docs/README.md"},
+			expected: []string{"docs"},
+			fromRef:  "origin/main",
+			toRef:    "feature",
+		},
+		{
+			name:     "changed since a ref, with dependents",
+			filter:   "...[origin/main]",
+			changed:  []string{"packages/ui/src/button.tsx"},
+			expected: []string{"ui", "web", "docs"},
+			fromRef:  "origin/main",
+			toRef:    "HEAD",
+		},
+		{
+			name:     "changed between two refs, with dependents",
+			filter:   "...[origin/main...HEAD]",
+			changed:  []string{"packages/config/tsconfig.json", "apps/web/next.config.js"},
+			expected: []string{"config", "ui", "web", "docs"},
+			fromRef:  "origin/main",
+			toRef:    "HEAD",
+		},
+		{
+			name:    "git is unavailable",
+			filter:  "[main]",
+			err:     errors.New("git is unavailable"),
+			fromRef: "main",
+			toRef:   "HEAD",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			changed := make([]string, len(tc.changed))
+			for index, path := range tc.changed {
+				changed[index] = filepath.FromSlash(path)
+			}
+			scm := &mockSCM{
+				changed: changed,
+				err:     tc.err,
+			}
+			pkgs, isAllPackages, err := ResolvePackages(&Opts{
+				FilterPatterns: []string{tc.filter},
+			}, filepath.FromSlash("/dummy/repo/root"), scm, &context.Context{
+				PackageInfos:     packageInfos,
+				PackageNames:     packageNames,
+				PackageManager:   &packagemanager.PackageManager{Lockfile: "package-lock.json"},
+				TopologicalGraph: graph,
+			}, tui, logger)
+			if scm.fromCommit != tc.fromRef || scm.toCommit != tc.toRef {
+				t.Errorf("compared %v...%v, want %v...%v", scm.fromCommit, scm.toCommit, tc.fromRef, tc.toRef)
+			}
+			if tc.err != nil {
+				if !errors.Is(err, tc.err) {
+					t.Errorf("expected error %v, got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			expected := make(util.Set)
+			for _, pkg := range tc.expected {
+				expected.Add(pkg)
+			}
+			if !reflect.DeepEqual(pkgs, expected) {
+				t.Errorf("ResolvePackages got %v, want %v", pkgs, expected)
+			}
+			if isAllPackages {
+				t.Error("isAllPackages got true, want false")
+			}
+		})
+	}
+}
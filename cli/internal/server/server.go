@@ -7,6 +7,7 @@ import (
 
 	"github.com/hashicorp/go-hclog"
 	"github.com/pkg/errors"
+	"github.com/vercel/turbo/cli/internal/core"
 	"github.com/vercel/turbo/cli/internal/filewatcher"
 	"github.com/vercel/turbo/cli/internal/fs"
 	"github.com/vercel/turbo/cli/internal/globwatcher"
@@ -31,10 +32,15 @@ type Server struct {
 	started      time.Time
 	logFilePath  turbopath.AbsoluteSystemPath
 	repoRoot     turbopath.AbsoluteSystemPath
+	taskGraph    TaskGraphFunc
 	closerMu     sync.Mutex
 	closer       *closer
 }
 
+// TaskGraphFunc builds the task graph for the given task names in the repo at
+// repoRoot, defaulting to every task in the pipeline
+type TaskGraphFunc = func(repoRoot turbopath.AbsoluteSystemPath, tasks []string) (*core.SerializedTaskGraph, error)
+
 // GRPCServer is the interface that the turbo server needs to the underlying
 // GRPC server. This lets the turbo server register itself, as well as provides
 // a hook for shutting down the server.
@@ -62,8 +68,9 @@ func (c *closer) close() {
 
 var _defaultCookieTimeout = 500 * time.Millisecond
 
-// New returns a new instance of Server
-func New(serverName string, logger hclog.Logger, repoRoot turbopath.AbsoluteSystemPath, turboVersion string, logFilePath turbopath.AbsoluteSystemPath) (*Server, error) {
+// New returns a new instance of Server. taskGraph builds the task graph for the
+// GetTaskGraph rpc, which is unimplemented if it is nil.
+func New(serverName string, logger hclog.Logger, repoRoot turbopath.AbsoluteSystemPath, turboVersion string, logFilePath turbopath.AbsoluteSystemPath, taskGraph TaskGraphFunc) (*Server, error) {
 	cookieDir := fs.GetTurboDataDir().UntypedJoin("cookies", serverName)
 	cookieJar, err := filewatcher.NewCookieJar(cookieDir, _defaultCookieTimeout)
 	if err != nil {
@@ -82,6 +89,7 @@ func New(serverName string, logger hclog.Logger, repoRoot turbopath.AbsoluteSyst
 		started:      time.Now(),
 		logFilePath:  logFilePath,
 		repoRoot:     repoRoot,
+		taskGraph:    taskGraph,
 	}
 	server.watcher.AddClient(cookieJar)
 	server.watcher.AddClient(globWatcher)
@@ -190,3 +198,31 @@ func (s *Server) Status(ctx context.Context, req *turbodprotocol.StatusRequest)
 		},
 	}, nil
 }
+
+// GetTaskGraph implements the GetTaskGraph rpc from turbo.proto
+func (s *Server) GetTaskGraph(ctx context.Context, req *turbodprotocol.GetTaskGraphRequest) (*turbodprotocol.GetTaskGraphResponse, error) {
+	if s.taskGraph == nil {
+		return nil, status.Error(codes.Unimplemented, "method GetTaskGraph not implemented")
+	}
+	if req.RepoRoot != "" && req.RepoRoot != s.repoRoot.ToString() {
+		return nil, status.Errorf(codes.InvalidArgument, "repo root mismatch. Client %v Server %v", req.RepoRoot, s.repoRoot)
+	}
+	graph, err := s.taskGraph(s.repoRoot, req.Tasks)
+	if err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	resp := &turbodprotocol.GetTaskGraphResponse{}
+	for _, task := range graph.Tasks {
+		resp.Tasks = append(resp.Tasks, &turbodprotocol.TaskGraphTask{
+			TaskId:     task.TaskID,
+			Persistent: task.Persistent,
+		})
+	}
+	for _, dep := range graph.Dependencies {
+		resp.Dependencies = append(resp.Dependencies, &turbodprotocol.TaskGraphDependency{
+			From: dep.From,
+			To:   dep.To,
+		})
+	}
+	return resp, nil
+}
@@ -33,7 +33,7 @@ func TestDeleteRepoRoot(t *testing.T) {
 		stopped: make(chan struct{}),
 	}
 
-	s, err := New("testServer", logger, repoRoot, "some-version", "/log/file/path")
+	s, err := New("testServer", logger, repoRoot, "some-version", "/log/file/path", nil)
 	assert.NilError(t, err, "New")
 	s.Register(grpcServer)
 
@@ -57,7 +57,7 @@ func TestShutdown(t *testing.T) {
 		stopped: make(chan struct{}),
 	}
 
-	s, err := New("testServer", logger, repoRoot, "some-version", "/log/file/path")
+	s, err := New("testServer", logger, repoRoot, "some-version", "/log/file/path", nil)
 	assert.NilError(t, err, "New")
 	s.Register(grpcServer)
 
@@ -18,6 +18,7 @@ import (
 	"github.com/vercel/turbo/cli/internal/hashing"
 	"github.com/vercel/turbo/cli/internal/inference"
 	"github.com/vercel/turbo/cli/internal/nodes"
+	"github.com/vercel/turbo/cli/internal/scm"
 	"github.com/vercel/turbo/cli/internal/turbopath"
 	"github.com/vercel/turbo/cli/internal/util"
 	"golang.org/x/sync/errgroup"
@@ -36,30 +37,82 @@ type Tracker struct {
 	mu                  sync.RWMutex
 	packageInputsHashes packageFileHashes
 	packageTaskHashes   map[string]string // taskID -> hash
+	// repoRoot is recorded when calculating file hashes, so that task hashes can
+	// include files outside of the task's package
+	repoRoot turbopath.AbsoluteSystemPath
+	// inputFileCache, if set, avoids re-globbing task inputs between runs
+	inputFileCache *hashing.InputFileCache
+	// packageInputsFiles holds the package-relative input files of the package-inputs
+	// combinations used by tasks that skip running when their output is newer, or
+	// of every combination if keepAllInputFiles is set
+	packageInputsFiles map[packageFileHashKey][]turbopath.AnchoredUnixPath
+	keepAllInputFiles  bool
+	// packageInputsFileHashes holds the hash of each input file of every
+	// package-inputs combination
+	packageInputsFileHashes map[packageFileHashKey]map[turbopath.AnchoredUnixPath]string
+	// gitMetadata is hashed for tasks that opt in to hashing their git env vars
+	gitMetadata scm.Metadata
+	// skipFrameworkInference leaves the env vars of each package's framework out
+	// of its task hashes, unless they are declared in env
+	skipFrameworkInference bool
 }
 
 // NewTracker creates a tracker for package-inputs combinations and package-task combinations.
-func NewTracker(rootNode string, globalHash string, pipeline fs.Pipeline, packageInfos map[interface{}]*fs.PackageJSON) *Tracker {
+// inputFileCache may be nil, in which case task inputs are always re-globbed.
+// gitMetadata is the current commit, which is hashed for tasks with HashGitEnv.
+func NewTracker(rootNode string, globalHash string, pipeline fs.Pipeline, packageInfos map[interface{}]*fs.PackageJSON, inputFileCache *hashing.InputFileCache, gitMetadata scm.Metadata) *Tracker {
 	return &Tracker{
 		rootNode:          rootNode,
 		globalHash:        globalHash,
 		pipeline:          pipeline,
 		packageInfos:      packageInfos,
 		packageTaskHashes: make(map[string]string),
+		inputFileCache:    inputFileCache,
+		gitMetadata:       gitMetadata,
 	}
 }
 
+// KeepInputFiles records the input files of every task when calculating file
+// hashes, rather than only those of tasks that need them to run. It must be
+// called before CalculateFileHashes.
+func (th *Tracker) KeepInputFiles() {
+	th.keepAllInputFiles = true
+}
+
+// DisableFrameworkInference stops the env vars with the prefix of the framework
+// a package uses, like NEXT_PUBLIC_ for Next.js, from being included in the hashes
+// of its tasks automatically
+func (th *Tracker) DisableFrameworkInference() {
+	th.skipFrameworkInference = true
+}
+
 // packageFileSpec defines a combination of a package and optional set of input globs
 type packageFileSpec struct {
 	pkg    string
 	inputs []string
+	// keepFiles records which files were hashed, for tasks that need to know
+	keepFiles bool
+	// followSymlinks matches the input globs inside of symlinked directories
+	followSymlinks bool
 }
 
 func specFromPackageTask(packageTask *nodes.PackageTask) packageFileSpec {
 	return packageFileSpec{
-		pkg:    packageTask.PackageName,
-		inputs: packageTask.TaskDefinition.Inputs,
+		pkg:            packageTask.PackageName,
+		inputs:         taskInputs(packageTask.TaskDefinition),
+		followSymlinks: packageTask.TaskDefinition.FollowSymlinks,
+	}
+}
+
+// taskInputs returns the input globs to hash for a task
+func taskInputs(taskDefinition *fs.TaskDefinition) []string {
+	inputs := taskDefinition.Inputs
+	// The checksum manifest decides whether the task succeeds, so changes to it
+	// must invalidate the cache even if it isn't one of the task's inputs
+	if len(inputs) > 0 && taskDefinition.OutputChecksums != "" {
+		inputs = append(append([]string{}, inputs...), taskDefinition.OutputChecksums)
 	}
+	return inputs
 }
 
 // packageFileHashKey is a hashable representation of a packageFileSpec.
@@ -68,7 +121,11 @@ type packageFileHashKey string
 // hashes the inputs for a packageTask
 func (pfs packageFileSpec) ToKey() packageFileHashKey {
 	sort.Strings(pfs.inputs)
-	return packageFileHashKey(fmt.Sprintf("%v#%v", pfs.pkg, strings.Join(pfs.inputs, "!")))
+	key := fmt.Sprintf("%v#%v", pfs.pkg, strings.Join(pfs.inputs, "!"))
+	if pfs.followSymlinks {
+		key += "#followSymlinks"
+	}
+	return packageFileHashKey(key)
 }
 
 func safeCompileIgnoreFile(filepath string) (*gitignore.GitIgnore, error) {
@@ -79,23 +136,25 @@ func safeCompileIgnoreFile(filepath string) (*gitignore.GitIgnore, error) {
 	return gitignore.CompileIgnoreLines([]string{}...), nil
 }
 
-func (pfs *packageFileSpec) hash(pkg *fs.PackageJSON, repoRoot turbopath.AbsoluteSystemPath) (string, error) {
+func (pfs *packageFileSpec) hash(pkg *fs.PackageJSON, repoRoot turbopath.AbsoluteSystemPath, inputFileCache *hashing.InputFileCache) (string, map[turbopath.AnchoredUnixPath]string, error) {
 	hashObject, pkgDepsErr := hashing.GetPackageDeps(repoRoot, &hashing.PackageDepsOptions{
-		PackagePath:   pkg.Dir,
-		InputPatterns: pfs.inputs,
+		PackagePath:    pkg.Dir,
+		InputPatterns:  pfs.inputs,
+		InputFileCache: inputFileCache,
+		FollowSymlinks: pfs.followSymlinks,
 	})
 	if pkgDepsErr != nil {
 		manualHashObject, err := manuallyHashPackage(pkg, pfs.inputs, repoRoot)
 		if err != nil {
-			return "", err
+			return "", nil, err
 		}
 		hashObject = manualHashObject
 	}
 	hashOfFiles, otherErr := fs.HashObject(hashObject)
 	if otherErr != nil {
-		return "", otherErr
+		return "", nil, otherErr
 	}
-	return hashOfFiles, nil
+	return hashOfFiles, hashObject, nil
 }
 
 func manuallyHashPackage(pkg *fs.PackageJSON, inputs []string, rootPath turbopath.AbsoluteSystemPath) (map[turbopath.AnchoredUnixPath]string, error) {
@@ -112,9 +171,14 @@ func manuallyHashPackage(pkg *fs.PackageJSON, inputs []string, rootPath turbopat
 		return nil, err
 	}
 
+	includes, excludes, useDefault := hashing.SplitInputPatterns(inputs)
 	includePattern := ""
-	if len(inputs) > 0 {
-		includePattern = "{" + strings.Join(inputs, ",") + "}"
+	if !useDefault {
+		includePattern = "{" + strings.Join(includes, ",") + "}"
+	}
+	excludePattern := ""
+	if len(excludes) > 0 {
+		excludePattern = "{" + strings.Join(excludes, ",") + "}"
 	}
 
 	pathPrefix := rootPath.UntypedJoin(pkg.Dir.ToStringDuringMigration()).ToString()
@@ -134,15 +198,23 @@ func manuallyHashPackage(pkg *fs.PackageJSON, inputs []string, rootPath turbopat
 						return nil
 					}
 				}
-				hash, err := fs.GitLikeHashFile(convertedName.ToString())
-				if err != nil {
-					return fmt.Errorf("could not hash file %v. \n%w", convertedName.ToString(), err)
-				}
-
 				relativePath, err := convertedName.RelativeTo(convertedPathPrefix)
 				if err != nil {
 					return fmt.Errorf("File path cannot be made relative: %w", err)
 				}
+				if excludePattern != "" {
+					excluded, err := doublestar.Match(excludePattern, relativePath.ToUnixPath().ToString())
+					if err != nil {
+						return err
+					}
+					if excluded {
+						return nil
+					}
+				}
+				hash, err := fs.GitLikeHashFile(convertedName.ToString())
+				if err != nil {
+					return fmt.Errorf("could not hash file %v. \n%w", convertedName.ToString(), err)
+				}
 				hashObject[relativePath.ToUnixPath()] = hash
 			}
 		}
@@ -179,14 +251,18 @@ func (th *Tracker) CalculateFileHashes(allTasks []dag.Vertex, workerCount int, r
 		}
 
 		pfs := &packageFileSpec{
-			pkg:    pkgName,
-			inputs: taskDefinition.Inputs,
+			pkg:            pkgName,
+			inputs:         taskInputs(&taskDefinition),
+			keepFiles:      taskDefinition.SkipIfOutputNewerThan != "",
+			followSymlinks: taskDefinition.FollowSymlinks,
 		}
 
 		hashTasks.Add(pfs)
 	}
 
 	hashes := make(map[packageFileHashKey]string)
+	files := make(map[packageFileHashKey][]turbopath.AnchoredUnixPath)
+	fileHashes := make(map[packageFileHashKey]map[turbopath.AnchoredUnixPath]string)
 	hashQueue := make(chan *packageFileSpec, workerCount)
 	hashErrs := &errgroup.Group{}
 
@@ -197,13 +273,23 @@ func (th *Tracker) CalculateFileHashes(allTasks []dag.Vertex, workerCount int, r
 				if !ok {
 					return fmt.Errorf("cannot find package %v", packageFileSpec.pkg)
 				}
-				hash, err := packageFileSpec.hash(pkg, repoRoot)
+				hash, hashObject, err := packageFileSpec.hash(pkg, repoRoot, th.inputFileCache)
 				if err != nil {
 					return err
 				}
 				th.mu.Lock()
 				pfsKey := packageFileSpec.ToKey()
 				hashes[pfsKey] = hash
+				fileHashes[pfsKey] = hashObject
+				// Several tasks can share a package-inputs combination
+				if _, ok := files[pfsKey]; !ok && (packageFileSpec.keepFiles || th.keepAllInputFiles) {
+					inputFiles := make([]turbopath.AnchoredUnixPath, 0, len(hashObject))
+					for file := range hashObject {
+						inputFiles = append(inputFiles, file)
+					}
+					sort.Slice(inputFiles, func(i, j int) bool { return inputFiles[i] < inputFiles[j] })
+					files[pfsKey] = inputFiles
+				}
 				th.mu.Unlock()
 			}
 			return nil
@@ -218,18 +304,52 @@ func (th *Tracker) CalculateFileHashes(allTasks []dag.Vertex, workerCount int, r
 		return err
 	}
 	th.packageInputsHashes = hashes
+	th.packageInputsFiles = files
+	th.packageInputsFileHashes = fileHashes
+	th.repoRoot = repoRoot
 	return nil
 }
 
+// hashDotEnv hashes the env files of a task that exist, which aren't necessarily
+// among its inputs, since they are usually gitignored. It also returns the env
+// vars that they set.
+func (th *Tracker) hashDotEnv(packageTask *nodes.PackageTask) (map[turbopath.AnchoredUnixPath]string, []string, error) {
+	files := packageTask.TaskDefinition.DotEnv
+	if len(files) == 0 {
+		return nil, nil, nil
+	}
+	pkgDir := packageTask.Pkg.Dir.RestoreAnchor(th.repoRoot)
+	paths := []turbopath.AbsoluteSystemPath{}
+	for _, file := range files {
+		if path := pkgDir.UntypedJoin(file); path.FileExists() {
+			paths = append(paths, path)
+		}
+	}
+	fileHashes, err := hashing.GetHashableDeps(th.repoRoot, paths)
+	if err != nil {
+		return nil, nil, err
+	}
+	envPairs, err := env.ReadDotEnv(pkgDir, files)
+	if err != nil {
+		return nil, nil, err
+	}
+	return fileHashes, envPairs, nil
+}
+
 type taskHashInputs struct {
 	hashOfFiles          string
 	externalDepsHash     string
 	task                 string
+	command              string
 	outputs              fs.TaskOutputs
 	passThruArgs         []string
 	hashableEnvPairs     []string
 	globalHash           string
 	taskDependencyHashes []string
+	shell                string
+	dependencyOutputs    []string
+	followSymlinks       bool
+	dotEnv               map[turbopath.AnchoredUnixPath]string
 }
 
 func (th *Tracker) calculateDependencyHashes(dependencySet dag.Set) ([]string, error) {
@@ -260,6 +380,32 @@ func (th *Tracker) calculateDependencyHashes(dependencySet dag.Set) ([]string, e
 	return dependenciesHashList, nil
 }
 
+// InputFiles returns the sorted package-relative input files of a task that skips
+// running when its output is newer than its inputs, or of any task if KeepInputFiles
+// was called. File hashes must be calculated first.
+func (th *Tracker) InputFiles(packageTask *nodes.PackageTask) ([]turbopath.AnchoredUnixPath, error) {
+	pkgFileHashKey := specFromPackageTask(packageTask).ToKey()
+	th.mu.RLock()
+	defer th.mu.RUnlock()
+	if _, ok := th.packageInputsHashes[pkgFileHashKey]; !ok {
+		return nil, fmt.Errorf("cannot find package-file hash for %v", pkgFileHashKey)
+	}
+	return th.packageInputsFiles[pkgFileHashKey], nil
+}
+
+// InputFileHashes returns the hash of each package-relative input file of a task.
+// File hashes must be calculated first.
+func (th *Tracker) InputFileHashes(packageTask *nodes.PackageTask) (map[turbopath.AnchoredUnixPath]string, error) {
+	pkgFileHashKey := specFromPackageTask(packageTask).ToKey()
+	th.mu.RLock()
+	defer th.mu.RUnlock()
+	fileHashes, ok := th.packageInputsFileHashes[pkgFileHashKey]
+	if !ok {
+		return nil, fmt.Errorf("cannot find package-file hash for %v", pkgFileHashKey)
+	}
+	return fileHashes, nil
+}
+
 // CalculateTaskHash calculates the hash for package-task combination. It is threadsafe, provided
 // that it has previously been called on its task-graph dependencies. File hashes must be calculated
 // first.
@@ -273,31 +419,60 @@ func (th *Tracker) CalculateTaskHash(packageTask *nodes.PackageTask, dependencyS
 	}
 
 	var envPrefixes []string
-	framework := inference.InferFramework(packageTask.Pkg)
-	if framework != nil && framework.EnvPrefix != "" {
-		// log auto detected framework and env prefix
-		logger.Debug(fmt.Sprintf("auto detected framework for %s", packageTask.PackageName), "framework", framework.Slug, "env_prefix", framework.EnvPrefix)
-		envPrefixes = append(envPrefixes, framework.EnvPrefix)
+	if !th.skipFrameworkInference {
+		framework := inference.InferFramework(packageTask.Pkg)
+		if framework != nil && framework.EnvPrefix != "" {
+			// log auto detected framework and env prefix
+			logger.Debug(fmt.Sprintf("auto detected framework for %s", packageTask.PackageName), "framework", framework.Slug, "env_prefix", framework.EnvPrefix)
+			envPrefixes = append(envPrefixes, framework.EnvPrefix)
+		}
 	}
 
-	hashableEnvPairs := env.GetHashableEnvPairs(packageTask.TaskDefinition.EnvVarDependencies, envPrefixes)
+	hashableEnvPairs, err := env.GetHashableEnvPairs(packageTask.TaskDefinition.EnvVarDependencies, envPrefixes, packageTask.TaskDefinition.PassThroughEnv)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash env vars for %v: %w", packageTask.TaskID, err)
+	}
+	if packageTask.TaskDefinition.HashGitEnv {
+		// The injected git env vars aren't in the environment, so hash their values directly
+		hashableEnvPairs = append(hashableEnvPairs, th.gitMetadata.EnvPairs(packageTask.TaskDefinition.GitEnv)...)
+		sort.Strings(hashableEnvPairs)
+	}
+	dotEnv, dotEnvPairs, err := th.hashDotEnv(packageTask)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash env files for %v: %w", packageTask.TaskID, err)
+	}
+	if len(dotEnvPairs) > 0 {
+		// The vars loaded from env files are hashed like those listed in env
+		hashableEnvPairs = append(hashableEnvPairs, dotEnvPairs...)
+		sort.Strings(hashableEnvPairs)
+	}
 	outputs := packageTask.HashableOutputs()
 	taskDependencyHashes, err := th.calculateDependencyHashes(dependencySet)
 	if err != nil {
 		return "", err
 	}
+	// Changing the script changes what the task does, even if its inputs are the same
+	command, _ := packageTask.Command()
 	// log any auto detected env vars
 	logger.Debug(fmt.Sprintf("task hash env vars for %s:%s", packageTask.PackageName, packageTask.Task), "vars", hashableEnvPairs)
 
+	// The dependencies' outputs are hashed as their globs rather than the files on
+	// disk, since the dependencies' hashes already determine what the files are. That
+	// keeps the hash the same whether or not the dependencies have run yet, or --dry.
 	hash, err := fs.HashObject(&taskHashInputs{
 		hashOfFiles:          hashOfFiles,
 		externalDepsHash:     packageTask.Pkg.ExternalDepsHash,
 		task:                 packageTask.Task,
+		command:              command,
 		outputs:              outputs.Sort(),
 		passThruArgs:         args,
 		hashableEnvPairs:     hashableEnvPairs,
 		globalHash:           th.globalHash,
 		taskDependencyHashes: taskDependencyHashes,
+		shell:                packageTask.Shell,
+		dependencyOutputs:    packageTask.DependencyOutputs,
+		followSymlinks:       packageTask.TaskDefinition.FollowSymlinks,
+		dotEnv:               dotEnv,
 	})
 	if err != nil {
 		return "", fmt.Errorf("failed to hash task %v: %v", packageTask.TaskID, hash)
@@ -1,11 +1,20 @@
 package taskhash
 
 import (
+	"os"
+	"os/exec"
 	"path/filepath"
+	"reflect"
+	"runtime"
 	"strings"
 	"testing"
 
+	"github.com/hashicorp/go-hclog"
+	"github.com/pyr-sh/dag"
+	"github.com/vercel/turbo/cli/internal/env"
 	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/nodes"
+	"github.com/vercel/turbo/cli/internal/scm"
 	"github.com/vercel/turbo/cli/internal/turbopath"
 )
 
@@ -135,3 +144,278 @@ func Test_manuallyHashPackage(t *testing.T) {
 		t.Errorf("found extra hashes in %v", hashes)
 	}
 }
+
+func Test_taskInputs(t *testing.T) {
+	inputs := taskInputs(&fs.TaskDefinition{Inputs: []string{"src/**"}, OutputChecksums: "dist.sha256"})
+	if strings.Join(inputs, ",") != "src/**,dist.sha256" {
+		t.Errorf("taskInputs got %v, want the checksum manifest added to the inputs", inputs)
+	}
+	inputs = taskInputs(&fs.TaskDefinition{OutputChecksums: "dist.sha256"})
+	if len(inputs) != 0 {
+		t.Errorf("taskInputs got %v, want no inputs so that all files are hashed", inputs)
+	}
+}
+
+func Test_passThroughEnvIsNotHashed(t *testing.T) {
+	packageTask := &nodes.PackageTask{
+		TaskID:      "web#build",
+		Task:        "build",
+		PackageName: "web",
+		// A Next.js app, whose NEXT_PUBLIC_ env vars are hashed automatically
+		Pkg: &fs.PackageJSON{Name: "web", UnresolvedExternalDeps: map[string]string{"next": "13.0.0"}},
+		TaskDefinition: &fs.TaskDefinition{
+			EnvVarDependencies: []string{"API_URL"},
+			PassThroughEnv:     []string{"GITHUB_TOKEN", "NEXT_PUBLIC_*_TOKEN"},
+		},
+	}
+	tracker := NewTracker("___ROOT___", "global-hash", fs.Pipeline{}, map[interface{}]*fs.PackageJSON{}, nil, scm.Metadata{})
+	tracker.packageInputsHashes = packageFileHashes{specFromPackageTask(packageTask).ToKey(): "files-hash"}
+	hash := func() string {
+		t.Helper()
+		hash, err := tracker.CalculateTaskHash(packageTask, dag.Set{}, hclog.NewNullLogger(), nil)
+		if err != nil {
+			t.Fatalf("CalculateTaskHash: %v", err)
+		}
+		return hash
+	}
+
+	t.Setenv("API_URL", "https://example.com")
+	t.Setenv("GITHUB_TOKEN", "first-token")
+	t.Setenv("NEXT_PUBLIC_SENTRY_TOKEN", "first-token")
+	first := hash()
+
+	t.Setenv("GITHUB_TOKEN", "second-token")
+	t.Setenv("NEXT_PUBLIC_SENTRY_TOKEN", "second-token")
+	if second := hash(); second != first {
+		t.Errorf("hash changed from %v to %v when only pass through env vars changed", first, second)
+	}
+
+	t.Setenv("API_URL", "https://example.org")
+	if third := hash(); third == first {
+		t.Errorf("hash didn't change when an env var in env changed")
+	}
+}
+
+func Test_dotEnv(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("runs the task with sh")
+	}
+	repoRoot := turbopath.AbsoluteSystemPathFromUpstream(t.TempDir())
+	pkgDir := turbopath.AnchoredUnixPath("apps/web").ToSystemPath()
+	if err := pkgDir.RestoreAnchor(repoRoot).MkdirAll(0755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	writeFile := func(name string, contents string) {
+		t.Helper()
+		if err := pkgDir.RestoreAnchor(repoRoot).UntypedJoin(name).WriteFile([]byte(contents), 0644); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+	}
+	packageTask := &nodes.PackageTask{
+		TaskID:         "web#build",
+		Task:           "build",
+		PackageName:    "web",
+		Pkg:            &fs.PackageJSON{Name: "web", Dir: pkgDir},
+		TaskDefinition: &fs.TaskDefinition{DotEnv: []string{".env", ".env.local"}},
+	}
+	tracker := NewTracker("___ROOT___", "global-hash", fs.Pipeline{}, map[interface{}]*fs.PackageJSON{}, nil, scm.Metadata{})
+	tracker.packageInputsHashes = packageFileHashes{specFromPackageTask(packageTask).ToKey(): "files-hash"}
+	tracker.repoRoot = repoRoot
+	hash := func() string {
+		t.Helper()
+		hash, err := tracker.CalculateTaskHash(packageTask, dag.Set{}, hclog.NewNullLogger(), nil)
+		if err != nil {
+			t.Fatalf("CalculateTaskHash: %v", err)
+		}
+		return hash
+	}
+	// greeting runs a process with the env that turbo gives the task, and returns
+	// the value of GREETING that it sees
+	greeting := func() string {
+		t.Helper()
+		dotEnvPairs, err := env.ReadDotEnv(pkgDir.RestoreAnchor(repoRoot), packageTask.TaskDefinition.DotEnv)
+		if err != nil {
+			t.Fatalf("ReadDotEnv: %v", err)
+		}
+		cmd := exec.Command("sh", "-c", `printf %s "$GREETING"`)
+		cmd.Env = append(os.Environ(), dotEnvPairs...)
+		out, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("running sh: %v", err)
+		}
+		return string(out)
+	}
+
+	// .env.local doesn't exist yet
+	writeFile(".env", "GREETING=hello\n")
+	first := hash()
+	if got := greeting(); got != "hello" {
+		t.Errorf("GREETING = %q, want hello", got)
+	}
+
+	writeFile(".env.local", "GREETING=hi\n")
+	second := hash()
+	if second == first {
+		t.Errorf("hash didn't change when .env.local was created")
+	}
+	if got := greeting(); got != "hi" {
+		t.Errorf("GREETING = %q, want .env.local to override .env", got)
+	}
+
+	writeFile(".env.local", "GREETING=hey\n")
+	third := hash()
+	if third == second || third == first {
+		t.Errorf("hash didn't change when .env.local was edited")
+	}
+	if got := greeting(); got != "hey" {
+		t.Errorf("GREETING = %q, want hey", got)
+	}
+
+	// Comments don't change the vars, but are still part of the file's contents
+	writeFile(".env.local", "# friendlier\nGREETING=hey\n")
+	if fourth := hash(); fourth == third {
+		t.Errorf("hash didn't change when a comment was added to .env.local")
+	}
+}
+
+func Test_dependencyOutputs(t *testing.T) {
+	repoRoot := turbopath.AbsoluteSystemPathFromUpstream(t.TempDir())
+	packageTask := &nodes.PackageTask{
+		TaskID:            "web#lint",
+		Task:              "lint",
+		PackageName:       "web",
+		Pkg:               &fs.PackageJSON{Name: "web", Dir: turbopath.AnchoredUnixPath("apps/web").ToSystemPath()},
+		TaskDefinition:    &fs.TaskDefinition{InputsFromDepOutputs: true},
+		DependencyOutputs: []string{"packages/lib/dist/**"},
+	}
+	tracker := NewTracker("___ROOT___", "global-hash", fs.Pipeline{}, map[interface{}]*fs.PackageJSON{}, nil, scm.Metadata{})
+	tracker.packageInputsHashes = packageFileHashes{specFromPackageTask(packageTask).ToKey(): "files-hash"}
+	tracker.repoRoot = repoRoot
+	hash := func() string {
+		t.Helper()
+		hash, err := tracker.CalculateTaskHash(packageTask, dag.Set{"lib#build": "lib#build"}, hclog.NewNullLogger(), nil)
+		if err != nil {
+			t.Fatalf("CalculateTaskHash: %v", err)
+		}
+		return hash
+	}
+
+	tracker.packageTaskHashes["lib#build"] = "lib-hash"
+	// As in a dry run, or a real run before lib#build has run or been restored
+	cold := hash()
+
+	dist := repoRoot.UntypedJoin("packages", "lib", "dist")
+	if err := dist.MkdirAll(0755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	if err := dist.UntypedJoin("index.js").WriteFile([]byte("module.exports = 1\n"), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if warm := hash(); warm != cold {
+		t.Errorf("hash changed from %v to %v when the dependency's outputs were written", cold, warm)
+	}
+
+	tracker.packageTaskHashes["lib#build"] = "other-lib-hash"
+	if changed := hash(); changed == cold {
+		t.Errorf("hash didn't change when the dependency's hash changed")
+	}
+
+	tracker.packageTaskHashes["lib#build"] = "lib-hash"
+	packageTask.DependencyOutputs = []string{"packages/lib/build/**"}
+	if changed := hash(); changed == cold {
+		t.Errorf("hash didn't change when the dependency's outputs changed")
+	}
+}
+
+func Test_frameworkInference(t *testing.T) {
+	cases := []struct {
+		name   string
+		dep    string
+		envVar string
+	}{
+		{"nextjs", "next", "NEXT_PUBLIC_API_URL"},
+		{"vite", "vite", "VITE_API_URL"},
+	}
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			packageTask := &nodes.PackageTask{
+				TaskID:         "web#build",
+				Task:           "build",
+				PackageName:    "web",
+				Pkg:            &fs.PackageJSON{Name: "web", UnresolvedExternalDeps: map[string]string{tc.dep: "1.0.0"}},
+				TaskDefinition: &fs.TaskDefinition{},
+			}
+			hash := func(tracker *Tracker) string {
+				t.Helper()
+				tracker.packageInputsHashes = packageFileHashes{specFromPackageTask(packageTask).ToKey(): "files-hash"}
+				hash, err := tracker.CalculateTaskHash(packageTask, dag.Set{}, hclog.NewNullLogger(), nil)
+				if err != nil {
+					t.Fatalf("CalculateTaskHash: %v", err)
+				}
+				return hash
+			}
+			inferred := NewTracker("___ROOT___", "global-hash", fs.Pipeline{}, map[interface{}]*fs.PackageJSON{}, nil, scm.Metadata{})
+			notInferred := NewTracker("___ROOT___", "global-hash", fs.Pipeline{}, map[interface{}]*fs.PackageJSON{}, nil, scm.Metadata{})
+			notInferred.DisableFrameworkInference()
+
+			t.Setenv(tc.envVar, "https://example.com")
+			first, firstNotInferred := hash(inferred), hash(notInferred)
+			t.Setenv(tc.envVar, "https://example.org")
+			if second := hash(inferred); second == first {
+				t.Errorf("hash didn't change when %v changed", tc.envVar)
+			}
+			if second := hash(notInferred); second != firstNotInferred {
+				t.Errorf("hash changed from %v to %v when %v changed with framework inference disabled", firstNotInferred, second, tc.envVar)
+			}
+		})
+	}
+}
+
+func Test_KeepInputFiles(t *testing.T) {
+	repoRoot := turbopath.AbsoluteSystemPathFromUpstream(t.TempDir())
+	for _, file := range []string{"web/package.json", "web/src/index.js", "web/src/util.js"} {
+		path := repoRoot.UntypedJoin(filepath.FromSlash(file))
+		if err := path.EnsureDir(); err != nil {
+			t.Fatalf("EnsureDir: %v", err)
+		}
+		if err := path.WriteFile([]byte(file), 0644); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+	}
+	pkg := &fs.PackageJSON{Name: "web", Dir: turbopath.AnchoredUnixPath("web").ToSystemPath()}
+	pipeline := fs.Pipeline{"build": fs.TaskDefinition{}}
+	packageTask := &nodes.PackageTask{
+		TaskID:         "web#build",
+		Task:           "build",
+		PackageName:    "web",
+		Pkg:            pkg,
+		TaskDefinition: &fs.TaskDefinition{},
+	}
+
+	tracker := NewTracker("___ROOT___", "global-hash", pipeline, map[interface{}]*fs.PackageJSON{"web": pkg}, nil, scm.Metadata{})
+	if err := tracker.CalculateFileHashes([]dag.Vertex{"web#build"}, 1, repoRoot); err != nil {
+		t.Fatalf("CalculateFileHashes: %v", err)
+	}
+	files, err := tracker.InputFiles(packageTask)
+	if err != nil {
+		t.Fatalf("InputFiles: %v", err)
+	}
+	if len(files) != 0 {
+		t.Errorf("InputFiles got %v, want no files without KeepInputFiles", files)
+	}
+
+	tracker = NewTracker("___ROOT___", "global-hash", pipeline, map[interface{}]*fs.PackageJSON{"web": pkg}, nil, scm.Metadata{})
+	tracker.KeepInputFiles()
+	if err := tracker.CalculateFileHashes([]dag.Vertex{"web#build"}, 1, repoRoot); err != nil {
+		t.Fatalf("CalculateFileHashes: %v", err)
+	}
+	files, err = tracker.InputFiles(packageTask)
+	if err != nil {
+		t.Fatalf("InputFiles: %v", err)
+	}
+	expected := []turbopath.AnchoredUnixPath{"package.json", "src/index.js", "src/util.js"}
+	if !reflect.DeepEqual(files, expected) {
+		t.Errorf("InputFiles got %v, want %v", files, expected)
+	}
+}
@@ -2,6 +2,7 @@ package util
 
 import (
 	"fmt"
+	"runtime"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -16,10 +17,6 @@ func TestParseConcurrency(t *testing.T) {
 			"12",
 			12,
 		},
-		{
-			"200%",
-			20,
-		},
 		{
 			"100%",
 			10,
@@ -36,6 +33,14 @@ func TestParseConcurrency(t *testing.T) {
 			"1%",
 			1,
 		},
+		{
+			"33%", // rounds down
+			3,
+		},
+		{
+			"0.5%", // clamped to a minimum of 1
+			1,
+		},
 		{
 			"0644", // we parse in base 10
 			644,
@@ -66,6 +71,11 @@ func TestInvalidPercents(t *testing.T) {
 		"infinity%",
 		"-infinity%",
 		"nan%",
+		"0%",
+		"150%",
+		"100.5%",
+		"abc%",
+		"%",
 		"0b01",
 		"0o644",
 		"0xFF",
@@ -77,3 +87,33 @@ func TestInvalidPercents(t *testing.T) {
 		})
 	}
 }
+
+func TestParseConcurrencyOnSingleCPU(t *testing.T) {
+	runtimeNumCPU = func() int {
+		return 1
+	}
+	defer func() { runtimeNumCPU = runtime.NumCPU }()
+
+	for _, input := range []string{"1%", "50%", "100%"} {
+		result, err := parseConcurrency(input)
+		assert.NoError(t, err, input)
+		assert.Equal(t, 1, result, input)
+	}
+}
+
+func TestConcurrencyValue(t *testing.T) {
+	runtimeNumCPU = func() int {
+		return 8
+	}
+	defer func() { runtimeNumCPU = runtime.NumCPU }()
+
+	concurrency := 10
+	value := &ConcurrencyValue{Value: &concurrency}
+	assert.NoError(t, value.Set("50%"))
+	assert.Equal(t, 4, concurrency)
+	assert.Equal(t, "50%", value.String())
+
+	err := value.Set("150%")
+	assert.EqualError(t, err, "invalid percentage value 150% for --concurrency CLI flag. This should be a percentage of CPU cores, greater than 0% and at most 100%")
+	assert.Equal(t, 4, concurrency, "an invalid value leaves the concurrency unchanged")
+}
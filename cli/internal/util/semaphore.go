@@ -1,5 +1,7 @@
 package util
 
+import "sync"
+
 // Semaphore is a wrapper around a channel to provide
 // utility methods to clarify that we are treating the
 // channel as a semaphore
@@ -41,3 +43,67 @@ func (s Semaphore) Release() {
 		panic("release without an acquire")
 	}
 }
+
+// WeightedSemaphore allows acquisitions of differing sizes
+// against a total limit
+type WeightedSemaphore struct {
+	limit int
+	used  int
+	mu    sync.Mutex
+	cond  *sync.Cond
+}
+
+// NewWeightedSemaphore creates a semaphore that allows
+// acquisitions totaling up to the given limit at once
+func NewWeightedSemaphore(n int) *WeightedSemaphore {
+	if n <= 0 {
+		panic("semaphore with limit <=0")
+	}
+	s := &WeightedSemaphore{limit: n}
+	s.cond = sync.NewCond(&s.mu)
+	return s
+}
+
+// Limit returns the total weight that can be acquired at once
+func (s *WeightedSemaphore) Limit() int {
+	return s.limit
+}
+
+// Acquire is used to acquire n slots. Blocks until they
+// are available. n must not exceed the limit, or it would
+// block forever.
+func (s *WeightedSemaphore) Acquire(n int) {
+	if n > s.limit {
+		panic("acquire exceeds the semaphore limit")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for s.used+n > s.limit {
+		s.cond.Wait()
+	}
+	s.used += n
+}
+
+// TryAcquire is used to do a non-blocking acquire of n slots.
+// Returns a bool indicating success
+func (s *WeightedSemaphore) TryAcquire(n int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.used+n > s.limit {
+		return false
+	}
+	s.used += n
+	return true
+}
+
+// Release is used to return n slots. Acquire must
+// be called as a pre-condition.
+func (s *WeightedSemaphore) Release(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if n > s.used {
+		panic("release without an acquire")
+	}
+	s.used -= n
+	s.cond.Broadcast()
+}
@@ -63,12 +63,21 @@ export interface Pipeline {
    * package level (e.g. "a package's test and lint commands depend on build being
    * completed first").
    *
+   * Items prefixed with path: refer to a task in the workspace at the given directory,
+   * relative to the repository root, rather than by workspace name
+   * (e.g. "path:packages/ui#build").
+   *
+   * Items containing *, ? or [ are patterns that match the scripts in each workspace's
+   * package.json (e.g. "lint:*" runs lint:js and lint:css in a workspace that has
+   * both). A workspace with no matching script adds no dependencies.
+   *
    * @default []
    */
   dependsOn?: string[];
 
   /**
    * A list of environment variables, **not** prefixed with $ (e.g. $GITHUB_TOKEN), that this task depends on.
+   * A * matches any part of a name (e.g. AWS_*).
    *
    * @default []
    */
@@ -84,10 +93,27 @@ export interface Pipeline {
    * thus doesn't emit any filesystem artifacts (e.g. like a linter), but you still want
    * to cache its logs (and treat them like an artifact).
    *
+   * Globs prefixed with ! exclude the files they match from the globs listed before
+   * them (e.g. ["dist/**", "!dist/**\/*.map"] leaves out source maps). Order matters:
+   * when several globs match a file, the last one wins, so a later glob can add back
+   * files an earlier negation left out. A list of only negated globs is an error.
+   *
    * @default ["dist/**", "build/**"]
    */
   outputs?: string[];
 
+  /**
+   * Glob patterns of files matched by `outputs` that should be neither cached
+   * nor restored, such as source maps (e.g. "dist/*.map"). Excluded files
+   * are left on disk. This is equivalent to appending the globs to the end of
+   * `outputs` prefixed with `!`, so they always win over `outputs`.
+   *
+   * `--dry-run` shows the excluded outputs of each task.
+   *
+   * @default []
+   */
+  outputExclude?: string[];
+
   /**
    * Whether or not to cache the task outputs. Setting cache to false is useful for daemon
    * or long-running "watch" or development mode tasks that you don't want to cache.
@@ -104,6 +130,10 @@ export interface Pipeline {
    * will not cause a cache miss.
    *
    * If omitted or empty, all files in the package are considered as inputs.
+   *
+   * "$TURBO_DEFAULT$" stands for all files in the package, so that other globs can add
+   * to them. Globs prefixed with ! exclude the files they match
+   * (e.g. ["$TURBO_DEFAULT$", "!**\/*.test.ts"] considers everything but tests).
    * @default []
    */
   inputs?: string[];
@@ -112,11 +142,316 @@ export interface Pipeline {
    * The style of output for this task. Use "full" to display the entire output of
    * the task. Use "hash-only" to show only the computed task hashes. Use "new-only" to
    * show the full output of cache misses and the computed hashes for cache hits. Use
-   * "none" to hide task output.
+   * "errors-only" to show output only for tasks that fail. Use "none" to hide task output.
    *
    * @default full
    */
   outputMode?: string;
+
+  /**
+   * The shell the package manager should use to run this task's script
+   * (e.g. "bash"). Overrides the --shell flag. If omitted, the package
+   * manager's default shell is used. The shell can't take arguments, since
+   * package managers run it as an executable path.
+   *
+   * Supported by npm, pnpm and yarn 1. Setting a shell is an error with
+   * yarn 2+, which ignores it.
+   *
+   * Changing the shell changes the task's hash.
+   */
+  shell?: string;
+
+  /**
+   * Whether the outputs declared by this task's dependencies should be considered
+   * inputs to this task. When true, the declared outputs are hashed along with the
+   * dependencies' hashes, so that a change to a dependency that changes its outputs,
+   * or a change to the outputs it declares, causes a cache miss for this task. The
+   * files on disk aren't hashed, so the hash is the same before and after the
+   * dependencies run, and with `--dry`.
+   *
+   * At least one of the task's dependencies must declare outputs.
+   *
+   * @default false
+   */
+  inputsFromDependencyOutputs?: boolean;
+
+  /**
+   * The minimum number of this task's dependencies that must succeed for it to
+   * run. Useful for tasks that aggregate the results of many dependencies and
+   * can tolerate some of them failing. Has no effect unless turbo is run with
+   * `--continue`, since otherwise the first failure stops the run.
+   *
+   * If not set, all of the task's dependencies must succeed.
+   */
+  dependencyQuorum?: number;
+
+  /**
+   * Whether this task is a long-running process, such as a dev server, that
+   * does not exit. Other tasks cannot depend on a persistent task.
+   *
+   * @default false
+   */
+  persistent?: boolean;
+
+  /**
+   * Persistent tasks that must be ready before this persistent task is
+   * started, e.g. `["api#dev"]`. Entries without a workspace name refer to a
+   * task in the same workspace. Unlike `dependsOn`, this does not wait for
+   * the other tasks to exit.
+   *
+   * Only persistent tasks can declare or be named in a start ordering.
+   */
+  startsAfter?: string[];
+
+  /**
+   * A regular expression matched against each line of a persistent task's
+   * output. The task is considered ready once a line matches, which allows
+   * tasks that start after it to be started.
+   *
+   * If not set, the task is considered ready as soon as it is started.
+   */
+  readinessPattern?: string;
+
+  /**
+   * How to check that a persistent task is ready, with exactly one of:
+   * - `pattern`: the same as `readinessPattern`
+   * - `command`: a shell command that exits successfully once the task is ready
+   * - `port`: a local TCP port that accepts connections once the task is ready
+   * - `url`: an HTTP URL that responds with a status below 400 once the task is ready
+   *
+   * A command, port or URL is polled every `interval` (default `"500ms"`) once the
+   * task starts. If it doesn't pass within `timeout` (default `"1m"`), the task is
+   * stopped, and it and the tasks waiting for it fail.
+   */
+  readinessProbe?: {
+    pattern?: string;
+    command?: string;
+    port?: number;
+    url?: string;
+    interval?: string;
+    timeout?: string;
+  };
+
+  /**
+   * Whether this task produces its outputs incrementally. The task signals
+   * that enough of its outputs are ready for dependents with
+   * `consumesStreaming` to start by creating the file at the path given in
+   * the `TURBO_READY_FILE` environment variable. If it never does, those
+   * dependents start once it finishes.
+   *
+   * @default false
+   */
+  streamingOutputs?: boolean;
+
+  /**
+   * Whether this task can start as soon as its dependencies with
+   * `streamingOutputs` are ready, rather than waiting for them to finish.
+   *
+   * @default false
+   */
+  consumesStreaming?: boolean;
+
+  /**
+   * How long this task's cached outputs remain valid, as a duration such as
+   * `"30m"` or `"24h"`. Cached outputs older than this are treated as a cache
+   * miss and the task is re-run, even if its inputs have not changed. Useful
+   * for tasks that depend on external, time-sensitive data.
+   *
+   * If not set, cached outputs never expire.
+   */
+  cacheTTL?: string;
+
+  /**
+   * Labels for grouping this task with others, so that other tasks can
+   * depend on all of them at once with `dependsOnTag`.
+   *
+   * @default []
+   */
+  tags?: string[];
+
+  /**
+   * Tags whose tasks must all complete successfully before this task can be
+   * executed. Each tag is expanded to every task in the run's workspaces
+   * that lists it in `tags`, so newly tagged tasks are picked up
+   * automatically.
+   *
+   * @default []
+   */
+  dependsOnTag?: string[];
+
+  /**
+   * Path, relative to the workspace, to a manifest of the expected SHA-256
+   * checksums of this task's outputs, in the format produced by `sha256sum`.
+   * After the task runs, each file listed in the manifest is compared against
+   * it, and the task fails without being cached if any file is missing or
+   * differs.
+   */
+  outputChecksums?: string;
+
+  /**
+   * Path, relative to the workspace, to this task's primary output. If the
+   * file exists and was modified more recently than every one of the task's
+   * inputs, the task is skipped, like a target in make. This is a cheaper
+   * alternative to caching for tasks where modification times are enough to
+   * tell whether the output is up to date.
+   *
+   * Skipped tasks are reported as up to date, rather than as cache hits.
+   */
+  skipIfOutputNewerThan?: string;
+
+  /**
+   * Facts about the current commit to inject into this task's environment.
+   * Each fact is read from git once per run and set as an environment
+   * variable:
+   *
+   * - `sha`: `TURBO_GIT_SHA`, the full commit SHA
+   * - `branch`: `TURBO_GIT_BRANCH`, empty if HEAD is detached
+   * - `tag`: `TURBO_GIT_TAG`, empty if the commit isn't tagged
+   *
+   * These variables are not included in the task's hash, since they change
+   * with every commit. Set `hashGitEnv` to include them.
+   */
+  gitEnv?: Array<"sha" | "branch" | "tag">;
+
+  /**
+   * Include the values of the `gitEnv` variables in this task's hash, so that
+   * the task is re-run when they change.
+   *
+   * @default false
+   */
+  hashGitEnv?: boolean;
+
+  /**
+   * Run this task, bypassing its cache, whenever one of its direct
+   * dependencies was executed in the same run rather than restored from the
+   * cache, even if the dependency's outputs are unchanged. Use this when the
+   * dependency's outputs can't be trusted to capture everything this task
+   * depends on.
+   *
+   * @default false
+   */
+  rerunOnDepExecution?: boolean;
+
+  /**
+   * The caching configuration this task runs under. With `"cache-enabled"`,
+   * the task is left out of runs that don't use a cache, such as when no cache
+   * is available or both `--force` and `--no-cache` are passed. With
+   * `"cache-disabled"`, it is only included in those runs. `--dry-run` shows
+   * whether each conditional task was included.
+   *
+   * @default "always"
+   */
+  runWhen?: "always" | "cache-enabled" | "cache-disabled";
+
+  /**
+   * Give this task the terminal while it runs, so that it can prompt the
+   * user. It reads from stdin, and the output of other tasks is held back
+   * until it finishes. Other tasks keep running in the meantime. Only one
+   * foreground task owns the terminal at a time.
+   *
+   * @default false
+   */
+  foreground?: boolean;
+
+  /**
+   * The oldest version of turbo that can run this task, such as `"1.8.0"`.
+   * Running the task with an older turbo fails with an error, rather than
+   * ignoring configuration that turbo doesn't understand. Other tasks in the
+   * pipeline are unaffected when this task isn't being run.
+   */
+  minTurboVersion?: string;
+
+  /**
+   * Match `inputs` globs against the files inside of symlinked directories,
+   * rather than the symlinks themselves. Symlinks that lead back to a
+   * directory containing them are not followed. Only applies when `inputs`
+   * is set, and changing it changes the task's hash.
+   *
+   * @default false
+   */
+  followSymlinks?: boolean;
+
+  /**
+   * The port that a persistent task listens on. Running two persistent tasks
+   * that declare the same port is an error, caught before either is started.
+   * Use `0`, or leave it unset, for tasks that pick a port dynamically.
+   *
+   * @default 0
+   */
+  port?: number;
+
+  /**
+   * The number of concurrency slots that the task takes up while it runs,
+   * out of the total set by `--concurrency`. Give memory or CPU heavy tasks
+   * a higher weight so that fewer of them run at once. A task that weighs
+   * more than the total concurrency fails rather than waiting forever.
+   *
+   * @default 1
+   */
+  weight?: number;
+
+  /**
+   * Lets other tasks depend on this persistent task. Rather than waiting for it
+   * to exit, which it never does, its dependents start once it is ready: as
+   * soon as it starts, or once its output matches its `readinessPattern` or its
+   * `readinessProbe` passes.
+   * Only applies to persistent tasks.
+   *
+   * @default false
+   */
+  allowDependents?: boolean;
+
+  /**
+   * How long the task can run for, as a duration such as `"30s"` or `"5m"`.
+   * A task that runs for longer is sent SIGTERM, then SIGKILL if it hasn't
+   * exited 10 seconds later, and fails. Tasks, including persistent ones,
+   * can run forever unless a timeout is set.
+   */
+  timeout?: string;
+
+  /**
+   * How many times to run the task again after it fails, before considering
+   * it failed. Its outputs are only cached once an attempt succeeds, and its
+   * dependents only run if one does. Persistent tasks are never retried.
+   *
+   * @default 0
+   */
+  retry?: number;
+
+  /**
+   * A list of environment variables, **not** prefixed with $, that this task reads but
+   * that shouldn't affect its hash (e.g. GITHUB_TOKEN). A * matches any part of a name
+   * (e.g. AWS_*). Variables that are also listed in env are still hashed.
+   *
+   * @default []
+   */
+  passThroughEnv?: string[];
+
+  /**
+   * A list of env files, relative to the workspace (e.g. [".env", ".env.local"]),
+   * whose variables are loaded into the task's environment. Their contents, and the
+   * values of the variables they set, are part of the task's hash, even if the files
+   * are gitignored. Files that don't exist are skipped, and a variable set by more
+   * than one file takes its value from the last.
+   *
+   * @default []
+   */
+  dotEnv?: string[];
+
+  /**
+   * The style of output for this task, accepting the same values as outputMode.
+   * Unlike outputMode, it takes precedence over the --output-logs flag, so that a
+   * noisy task can stay quiet (e.g. "errors-only") while the rest of the run uses
+   * the flag.
+   */
+  outputLogs?: string;
+
+  /**
+   * Makes a root task (e.g. "//#predeploy") run before ("pre") or after ("post")
+   * every other task in a run. The task is skipped if the root package.json
+   * doesn't define its script.
+   */
+  hook?: "pre" | "post";
 }
 
 export interface RemoteCache {